@@ -2,20 +2,699 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
+	"github.com/coreos/go-systemd/v22/daemon"
+	"github.com/ivanglie/coinmon/internal/alert"
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/ivanglie/coinmon/internal/config"
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/ivanglie/coinmon/internal/feed"
+	"github.com/ivanglie/coinmon/internal/index"
+	"github.com/ivanglie/coinmon/internal/scheduler"
 	"github.com/ivanglie/coinmon/internal/server"
+	"github.com/ivanglie/coinmon/internal/sink"
+	"github.com/ivanglie/coinmon/internal/telemetry"
+	"github.com/ivanglie/coinmon/internal/usage"
+	"github.com/ivanglie/coinmon/internal/vcr"
+	"github.com/ivanglie/coinmon/internal/watchlist"
 	"github.com/ivanglie/coinmon/pkg/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
 )
 
 func main() {
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		_, _ = fmt.Fprintf(out, "Usage: %s [flags]\n\n", os.Args[0])
+		_, _ = fmt.Fprintln(out, "Serves cryptocurrency spot prices of the fastest responding exchange.")
+		_, _ = fmt.Fprintln(out, "Settings are read from the JSON file of -config and COINMON_ environment variables, flags override both.")
+		_, _ = fmt.Fprintln(out, "\nFlags:")
+		flag.PrintDefaults()
+	}
+
+	configPath := flag.String("config", os.Getenv("COINMON_CONFIG"), "path of the JSON configuration file, $COINMON_CONFIG by default")
+	listen := flag.String("listen", "", "address to serve HTTP on instead of the configured one, e.g. :8080 or unix:///run/coinmon.sock")
+	domain := flag.String("domain", "", "comma separated domains to serve HTTPS for with certificates from Let's Encrypt")
+	exchanges := flag.String("exchanges", "", "comma separated exchanges to call instead of the configured ones, e.g. binance,kraken")
+	upstreamTimeout := flag.Duration("upstream-timeout", 0, "timeout of a call to an exchange instead of the configured one")
+	drainDelay := flag.Duration("drain-delay", 0, "time to fail readiness for before shutting down instead of the configured one")
+	logLevel := flag.String("log-level", "info", "minimum level of logged messages: debug, info or error")
+	webDir := flag.String("web-dir", "", "read templates and static assets from the directory instead of the embedded ones, e.g. web while developing the dashboard")
+	dev := flag.Bool("dev", false, "re-read templates and static assets on every request, from web unless -web-dir is set")
+	record := flag.String("record", "", "record the responses of exchanges to the cassette file, e.g. testdata/upstream.json")
+	replay := flag.String("replay", "", "respond to exchange calls from the cassette file recorded with -record instead of calling the exchanges")
+	chaos := flag.Bool("chaos", false, "inject the faults of upstream chaos into exchange calls even if it is not enabled, for resilience testing in staging")
+
+	var logFile log.FileConfig
+	flag.StringVar(&logFile.Path, "log-file", "", "write logs to the file instead of stdout")
+	flag.IntVar(&logFile.MaxSize, "log-max-size", log.DefaultMaxSize, "size in megabytes the log file is rotated at")
+	flag.IntVar(&logFile.MaxAge, "log-max-age", 0, "days to keep rotated log files for, 0 keeps them forever")
+	flag.IntVar(&logFile.MaxBackups, "log-max-backups", 0, "number of rotated log files to keep, 0 keeps all")
+	flag.BoolVar(&logFile.Compress, "log-compress", false, "gzip rotated log files")
+	sampleEvery := flag.Int("log-sample-every", log.DefaultSampleEvery, "log identical errors once a minute, then every nth time, 1 logs all")
+	flag.Parse()
+
 	log.SetDefaultLogConfig()
+	level, err := zerolog.ParseLevel(*logLevel)
+	if err != nil || level == zerolog.NoLevel {
+		log.Error(fmt.Sprintf("invalid log level %q", *logLevel))
+		os.Exit(2)
+	}
+	var logOut io.Writer = os.Stdout
+	if logFile.Path != "" {
+		logOut = log.NewFile(logFile)
+	}
+	log.SetLogConfig(level, logOut)
+	log.SetErrorSampling(*sampleEvery, log.DefaultSampleWindow)
+
+	// load reads the configuration overridden by flags, on start and on SIGHUP
+	load := func() (*config.Config, error) {
+		c, loadErr := config.Load(*configPath)
+		if loadErr != nil {
+			return nil, loadErr
+		}
+		if *listen != "" {
+			c.Addr = *listen
+		}
+		if *domain != "" {
+			c.ACME.Domains = strings.Split(*domain, ",")
+		}
+		if *exchanges != "" {
+			c.Exchanges = strings.Split(*exchanges, ",")
+		}
+		if *upstreamTimeout > 0 {
+			c.Upstream.Timeout = config.Duration(*upstreamTimeout)
+		}
+		if *drainDelay > 0 {
+			c.DrainDelay = config.Duration(*drainDelay)
+		}
+		if *chaos {
+			c.Upstream.Chaos.Enabled = true
+		}
+		if loadErr = c.Validate(); loadErr != nil {
+			return nil, fmt.Errorf("invalid config: %w", loadErr)
+		}
+		return c, nil
+	}
+
+	cfg, err := load()
+	if err != nil {
+		logError(err)
+		os.Exit(1)
+	}
+
+	enabled, err := parseExchanges(cfg.Exchanges)
+	if err != nil {
+		log.Error(err.Error())
+		os.Exit(1)
+	}
+
+	sched := scheduler.New()
+	events := bus.New()
+	registry := prometheus.NewRegistry()
+	opts := []server.Option{
+		server.WithScheduler(sched),
+		server.WithBus(events),
+		server.WithMetrics(registry),
+		server.WithDrainDelay(time.Duration(cfg.DrainDelay)),
+		server.WithSecurityTxtExpiry(time.Duration(cfg.SecurityTxtExpiry)),
+		server.WithUpstreamTimeout(time.Duration(cfg.Upstream.Timeout)),
+		server.WithMaxRequestTimeout(time.Duration(cfg.Upstream.MaxRequestTimeout)),
+		server.WithMaxStaleAge(time.Duration(cfg.Upstream.MaxStaleAge)),
+		server.WithUpstreamRetries(cfg.Upstream.Retries),
+		server.WithHedge(time.Duration(cfg.Upstream.Hedge)),
+		server.WithMaintenanceBench(time.Duration(cfg.Upstream.MaintenanceBench)),
+	}
+	if len(cfg.Uniswap.Pools) > 0 {
+		opts = append(opts, server.WithUniswap(cfg.Uniswap.Pools))
+	}
+	if len(enabled) > 0 {
+		opts = append(opts, server.WithExchanges(enabled...))
+	}
+
+	transport, err := server.NewTransport(server.Transport{
+		MaxIdleConnsPerHost: cfg.Upstream.MaxIdleConnsPerHost,
+		IdleConnTimeout:     time.Duration(cfg.Upstream.IdleConnTimeout),
+		TLSHandshakeTimeout: time.Duration(cfg.Upstream.TLSHandshakeTimeout),
+		ForceAttemptHTTP2:   cfg.Upstream.ForceAttemptHTTP2,
+		DNSCacheTTL:         time.Duration(cfg.Upstream.DNSCacheTTL),
+		Proxy:               cfg.Upstream.Proxy,
+		Proxies:             cfg.Upstream.Proxies,
+		BaseURLs:            cfg.Upstream.BaseURLs,
+		Mirrors:             cfg.Upstream.Mirrors,
+		CAFile:              cfg.Upstream.TLS.CAFile,
+		CertFile:            cfg.Upstream.TLS.Cert,
+		KeyFile:             cfg.Upstream.TLS.Key,
+		MinTLSVersion:       cfg.Upstream.TLS.MinVersion,
+	})
+	if err != nil {
+		log.Error(err.Error())
+		os.Exit(1)
+	}
+	var (
+		rt       http.RoundTripper = transport
+		recorder *vcr.Recorder
+	)
+	switch {
+	case *record != "" && *replay != "":
+		log.Error("-record and -replay are mutually exclusive")
+		os.Exit(2)
+	case *record != "":
+		recorder = vcr.NewRecorder(*record, transport)
+		rt = recorder
+	case *replay != "":
+		if rt, err = vcr.NewReplayer(*replay); err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+	}
+	if c := cfg.Upstream.Chaos; c.Enabled {
+		log.Info(fmt.Sprintf("Injecting faults into exchange calls: latency %s at %g, errors at %g, malformed bodies at %g",
+			time.Duration(c.Latency), c.LatencyRate, c.ErrorRate, c.MalformedRate))
+		rt = server.NewChaosTransport(rt, server.Chaos{
+			Latency:       time.Duration(c.Latency),
+			LatencyRate:   c.LatencyRate,
+			ErrorRate:     c.ErrorRate,
+			MalformedRate: c.MalformedRate,
+		})
+	}
+	opts = append(opts, server.WithTransport(rt))
+	for name, headers := range cfg.Upstream.Headers {
+		var n exchange.Name
+		if n, err = exchange.ParseName(name); err != nil {
+			log.Error(fmt.Sprintf("parse upstream headers: %v", err))
+			os.Exit(1)
+		}
+		opts = append(opts, server.WithExchangeHeaders(n, headers))
+	}
+	for name, baseURL := range cfg.Upstream.BaseURLs {
+		var n exchange.Name
+		if n, err = exchange.ParseName(name); err != nil {
+			log.Error(fmt.Sprintf("parse upstream base URLs: %v", err))
+			os.Exit(1)
+		}
+		opts = append(opts, server.WithExchangeBaseURL(n, baseURL))
+	}
+	for name, mirrors := range cfg.Upstream.Mirrors {
+		var n exchange.Name
+		if n, err = exchange.ParseName(name); err != nil {
+			log.Error(fmt.Sprintf("parse upstream mirrors: %v", err))
+			os.Exit(1)
+		}
+		opts = append(opts, server.WithExchangeMirrors(n, mirrors))
+	}
+	if cfg.Upstream.MaxConcurrent > 0 {
+		opts = append(opts, server.WithUpstreamLimit(cfg.Upstream.MaxConcurrent, time.Duration(cfg.Upstream.QueueTimeout)))
+	}
+
+	httpLn, grpcLn, err := systemdListeners()
+	if err != nil {
+		log.Error(err.Error())
+		os.Exit(1)
+	}
+	if httpLn != nil {
+		opts = append(opts, server.WithListener(httpLn))
+	}
+	if grpcLn != nil {
+		opts = append(opts, server.WithGRPCListener(grpcLn))
+	}
+	opts = append(opts, server.WithOnListen(func() { sdNotify(daemon.SdNotifyReady) }))
+
+	if len(cfg.ACME.Domains) > 0 {
+		opts = append(opts, server.WithAutocert(cfg.ACME.Domains, cfg.ACME.Email, cfg.ACME.CacheDir))
+	} else if cfg.TLS.Cert != "" || cfg.TLS.Key != "" {
+		opts = append(opts, server.WithTLS(cfg.TLS.Cert, cfg.TLS.Key))
+	}
+	if cfg.TLS.ClientCA != "" {
+		opts = append(opts, server.WithClientCA(cfg.TLS.ClientCA))
+	}
+
+	if cfg.HTTP3Addr != "" {
+		opts = append(opts, server.WithHTTP3(cfg.HTTP3Addr))
+	}
+
+	if cfg.ReusePort {
+		opts = append(opts, server.WithReusePort())
+	}
+
+	var wl *watchlist.Watchlist
+	if len(cfg.APIKeys.Keys) > 0 {
+		var wlOpts []watchlist.Option
+		if cfg.Watchlist.StateFile != "" {
+			wlOpts = append(wlOpts, watchlist.WithStore(watchlist.NewFileStore(cfg.Watchlist.StateFile)))
+		}
+		wl = watchlist.New(wlOpts...)
+		opts = append(opts, server.WithWatchlist(wl))
+	}
+
+	if len(cfg.APIKeys.Keys) > 0 || cfg.APIKeys.Required {
+		keys := make([]server.APIKey, 0, len(cfg.APIKeys.Keys))
+		for _, k := range cfg.APIKeys.Keys {
+			keys = append(keys, server.APIKey{Name: k.Name, Key: k.Key, Rate: k.Rate, Burst: k.Burst})
+		}
+		opts = append(opts, server.WithAPIKeys(keys, cfg.APIKeys.Required))
+	}
+
+	if cfg.JWT.Secret != "" || cfg.JWT.JWKSURL != "" {
+		var verifier *server.JWTVerifier
+		verifier, err = server.NewJWTVerifier(context.Background(), server.JWT{
+			Secret:   cfg.JWT.Secret,
+			JWKSURL:  cfg.JWT.JWKSURL,
+			Issuer:   cfg.JWT.Issuer,
+			Audience: cfg.JWT.Audience,
+		})
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+		opts = append(opts, server.WithJWT(verifier, cfg.JWT.Required))
+	}
+
+	for name, account := range cfg.Accounts {
+		var n exchange.Name
+		if n, err = exchange.ParseName(name); err != nil {
+			log.Error(fmt.Sprintf("parse accounts: %v", err))
+			os.Exit(1)
+		}
+
+		var signer *exchange.Signer
+		signer, err = exchange.NewSigner(n, exchange.Credentials{Key: account.Key, Secret: account.Secret, Passphrase: account.Passphrase})
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+		opts = append(opts, server.WithExchangeSigner(n, signer))
+	}
+
+	if cfg.BasicAuth.User != "" || cfg.BasicAuth.Password != "" {
+		opts = append(opts, server.WithBasicAuth(cfg.BasicAuth.User, cfg.BasicAuth.Password))
+	}
+
+	if cfg.H2C {
+		opts = append(opts, server.WithH2C())
+	}
+
+	if cfg.Debug.Enabled {
+		opts = append(opts, server.WithDebug(cfg.Debug.Token))
+	}
+
+	opts = append(opts, server.WithDashboard(server.Dashboard{
+		Title: cfg.Dashboard.Title,
+		Logo:  cfg.Dashboard.Logo,
+		Pairs: cfg.Dashboard.Pairs,
+		Theme: cfg.Dashboard.Theme,
+	}))
+
+	if *dev {
+		if *webDir == "" {
+			*webDir = "web"
+		}
+		opts = append(opts, server.WithDev())
+	}
+	if *webDir != "" {
+		opts = append(opts, server.WithWebDir(*webDir))
+	}
+
+	for v, d := range cfg.Deprecations {
+		opts = append(opts, server.WithDeprecation(v, server.Deprecation{Date: d.Date, Sunset: d.Sunset, Link: d.Link}))
+	}
+	opts = append(opts, server.WithLimits(server.Limits{
+		MaxHeaderBytes:    cfg.Limits.MaxHeaderBytes,
+		ReadHeaderTimeout: time.Duration(cfg.Limits.ReadHeaderTimeout),
+		MaxConns:          cfg.Limits.MaxConns,
+		MaxConnsPerIP:     cfg.Limits.MaxConnsPerIP,
+		MaxStreamsPerConn: cfg.Limits.MaxStreamsPerConn,
+		MaxSubscribers:    cfg.Limits.MaxSubscribers,
+	}))
+	if len(cfg.CORSOrigins) > 0 {
+		opts = append(opts, server.WithCORS(cfg.CORSOrigins...))
+	}
+	var tracker *usage.Tracker
+	if cfg.Usage.Enabled {
+		var usageOpts []usage.Option
+		if cfg.Usage.StateFile != "" {
+			usageOpts = append(usageOpts, usage.WithStore(usage.NewFileStore(cfg.Usage.StateFile)))
+		}
+		if cfg.Usage.MaxConsumers > 0 {
+			usageOpts = append(usageOpts, usage.WithMaxConsumers(cfg.Usage.MaxConsumers))
+		}
+		tracker = usage.New(usageOpts...)
+		opts = append(opts, server.WithUsage(tracker))
+	}
+	if len(cfg.AllowPairs) > 0 || len(cfg.DenyPairs) > 0 {
+		opts = append(opts, server.WithPairPolicy(cfg.AllowPairs, cfg.DenyPairs))
+	}
+
+	var evaluator *alert.Evaluator
+	if cfg.Alerts.PagerDuty.RoutingKey != "" {
+		var alertOpts []alert.Option
+		if cfg.Alerts.StateFile != "" {
+			alertOpts = append(alertOpts, alert.WithStore(alert.NewFileStore(cfg.Alerts.StateFile)))
+		}
+
+		pd := alert.NewPagerDuty(cfg.Alerts.PagerDuty.RoutingKey)
+		evaluator = alert.NewEvaluator(cfg.Alerts.AlertRules(), pd, alertOpts...)
+		opts = append(opts, server.WithAlerts(evaluator))
+	}
+
+	var indices *index.Calculator
+	if len(cfg.Indices) > 0 {
+		indices = index.NewCalculator(cfg.Indices.List(), 0)
+		opts = append(opts, server.WithIndices(indices))
+	}
+
+	var f *feed.Feed
+	if cfg.Feed.Enabled {
+		var streamed []*exchange.Exchange
+		for _, n := range []exchange.Name{exchange.BINANCE, exchange.BYBIT, exchange.BITGET} {
+			if len(enabled) == 0 || slices.Contains(enabled, n) {
+				streamed = append(streamed, exchange.New(n))
+			}
+		}
+		f = feed.New(streamed)
+		f.SetHTTPClient(&http.Client{Transport: transport})
+		f.Subscribe(cfg.Pairs...)
+		if evaluator != nil {
+			f.Subscribe(evaluator.Pairs()...)
+		}
+		if indices != nil {
+			f.Subscribe(indices.Pairs()...)
+		}
+		opts = append(opts, server.WithFeed(f, time.Duration(cfg.Feed.MaxAge)))
+	}
+
+	sinks, err := newSinks(cfg)
+	if err != nil {
+		log.Error(err.Error())
+		os.Exit(1)
+	}
+
+	if cfg.Exporter.Enabled {
+		sinks = append(sinks, sink.NewPrometheus(registry, cfg.Pairs))
+	}
+
+	if cfg.OTLP.Endpoint != "" {
+		// Metrics are pushed in the background for the lifetime of the process
+		if _, err = telemetry.NewOTLP(context.Background(), cfg.OTLP.Endpoint, cfg.OTLP.Headers, time.Duration(cfg.OTLP.Interval), registry); err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+	}
+
+	s := server.New(cfg.Addr, opts...)
+
+	addJob := func(name string, run func(context.Context) error) {
+		if err := sched.Add(job(cfg, name, run)); err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+	}
+
+	var pairs atomic.Pointer[[]string]
+	pairs.Store(&cfg.Pairs)
+	addJob("poll", pollPairs(s, func() []string {
+		polled := slices.Clone(*pairs.Load())
+		if wl != nil {
+			polled = append(polled, wl.Pairs()...)
+		}
+		slices.Sort(polled)
+		return slices.Compact(polled)
+	}))
+
+	if evaluator != nil {
+		addJob("alerts", pollPairs(s, evaluator.Pairs))
+		addJob("compact", func(context.Context) error {
+			evaluator.Compact()
+			return nil
+		})
+	}
+
+	if indices != nil {
+		addJob("indices", pollPairs(s, indices.Pairs))
+	}
+
+	if tracker != nil {
+		addJob("usage", func(context.Context) error {
+			return tracker.Save()
+		})
+	}
+
+	// Recorded and replayed calls are prices only
+	if cfg.Upstream.Prewarm && *record == "" && *replay == "" {
+		go func() {
+			if err := s.Prewarm(context.Background()); err != nil {
+				log.Error("Failed to prewarm exchange connections: " + err.Error())
+			}
+		}()
+		addJob("prewarm", s.Prewarm)
+	}
+	if *record == "" && *replay == "" {
+		addJob("symbols", s.RefreshSymbols)
+	}
 
-	s := server.New(":8080")
+	sched.Start(context.Background())
+	if f != nil {
+		f.Start(context.Background())
+	}
+	if evaluator != nil {
+		evaluator.Watch(context.Background(), events)
+	}
+	if indices != nil {
+		indices.Watch(context.Background(), events)
+	}
+	for _, sk := range sinks {
+		sk.Start(context.Background(), events)
+	}
 
-	log.Info("Starting server on :8080")
-	if err := s.Start(); err != nil {
+	if len(cfg.ACME.Domains) > 0 {
+		log.Info("Starting ACME challenge server on " + cfg.ACME.HTTPAddr)
+		serveInBackground(func() error { return s.StartACMEChallenge(cfg.ACME.HTTPAddr) })
+	}
+
+	if cfg.HTTP3Addr != "" {
+		log.Info("Starting HTTP/3 server on " + cfg.HTTP3Addr)
+		serveInBackground(s.StartHTTP3)
+	}
+
+	if cfg.GRPCAddr != "" || grpcLn != nil {
+		log.Info("Starting gRPC server on " + listenAddr(grpcLn, cfg.GRPCAddr))
+		serveInBackground(func() error { return s.StartGRPC(cfg.GRPCAddr) })
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			sdNotify(daemon.SdNotifyReloading)
+			next, reloadErr := load()
+			if reloadErr == nil {
+				reloadErr = reload(next, s, &pairs, evaluator, f)
+			}
+			if reloadErr != nil {
+				logError(fmt.Errorf("reload config: %w", reloadErr))
+			} else {
+				log.Info("Reloaded config")
+			}
+			sdNotify(daemon.SdNotifyReady)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
+	errc := make(chan error, 1)
+	go func() {
+		log.Info("Starting server on " + listenAddr(httpLn, cfg.Addr))
+		errc <- s.Start()
+	}()
+
+	select {
+	case err = <-errc:
 		log.Error(err.Error())
 		os.Exit(1)
+	case <-ctx.Done():
+		stop()
+	}
+
+	log.Info("Shutting down server")
+	sdNotify(daemon.SdNotifyStopping)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.DrainDelay)+shutdownTimeout)
+	err = s.Shutdown(shutdownCtx)
+	cancel()
+	if tracker != nil {
+		if saveErr := tracker.Save(); saveErr != nil {
+			log.Error("Failed to save usage: " + saveErr.Error())
+		}
+	}
+	if recorder != nil {
+		if closeErr := recorder.Close(); closeErr != nil {
+			log.Error("Failed to close cassette: " + closeErr.Error())
+		}
+	}
+	if err != nil {
+		log.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+// reload applies the exchanges, pairs, alert rules and feed max age of
+// cfg to the running service. Other settings take effect on restart.
+func reload(cfg *config.Config, s *server.Server, pairs *atomic.Pointer[[]string], evaluator *alert.Evaluator, f *feed.Feed) error {
+	enabled, err := parseExchanges(cfg.Exchanges)
+	if err != nil {
+		return err
+	}
+
+	s.SetExchanges(enabled...)
+	s.SetFeedMaxAge(time.Duration(cfg.Feed.MaxAge))
+	pairs.Store(&cfg.Pairs)
+
+	switch {
+	case evaluator != nil:
+		evaluator.SetRules(context.Background(), cfg.Alerts.AlertRules())
+	case len(cfg.Alerts.Rules) > 0:
+		log.Error("Alert rules are ignored until restart, alerting was not configured on start")
+	}
+
+	if f != nil {
+		f.Subscribe(cfg.Pairs...)
+		if evaluator != nil {
+			f.Subscribe(evaluator.Pairs()...)
+		}
+	}
+
+	return nil
+}
+
+// parseExchanges parses exchange names, empty meaning all exchanges
+func parseExchanges(names []string) ([]exchange.Name, error) {
+	var enabled []exchange.Name
+	for _, name := range names {
+		n, err := exchange.ParseName(strings.TrimSpace(name))
+		if err != nil {
+			return nil, fmt.Errorf("parse exchanges: %w", err)
+		}
+		enabled = append(enabled, n)
+	}
+
+	return enabled, nil
+}
+
+// logError logs every line of err, e.g. one per invalid setting
+func logError(err error) {
+	for line := range strings.SplitSeq(err.Error(), "\n") {
+		log.Error(line)
+	}
+}
+
+// serveInBackground runs a server with start, exiting when it fails
+func serveInBackground(start func() error) {
+	go func() {
+		if err := start(); err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+	}()
+}
+
+// listenAddr describes where a server listens, on ln when set or on addr
+func listenAddr(ln net.Listener, addr string) string {
+	if ln == nil {
+		return addr
+	}
+
+	return ln.Addr().String() + " (systemd socket)"
+}
+
+// shutdownTimeout is how long active requests may take to complete after
+// the drain delay
+const shutdownTimeout = 10 * time.Second
+
+// priceSink delivers price updates published on the bus to an external system
+type priceSink interface {
+	Start(ctx context.Context, b *bus.Bus)
+}
+
+// newSinks creates the sinks enabled in cfg
+func newSinks(cfg *config.Config) ([]priceSink, error) {
+	var sinks []priceSink
+
+	if cfg.MQTT.Broker != "" {
+		mq, err := sink.NewMQTT(sink.MQTTConfig{
+			Broker:   cfg.MQTT.Broker,
+			ClientID: cfg.MQTT.ClientID,
+			Username: cfg.MQTT.Username,
+			Password: cfg.MQTT.Password,
+			Topic:    cfg.MQTT.Topic,
+			QoS:      cfg.MQTT.QoS,
+			Retained: cfg.MQTT.Retained,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("create mqtt sink: %w", err)
+		}
+		sinks = append(sinks, mq)
+	}
+
+	if len(cfg.Kafka.Brokers) > 0 {
+		kf, err := sink.NewKafka(cfg.Kafka.Brokers, cfg.Kafka.Topic)
+		if err != nil {
+			return nil, fmt.Errorf("create kafka sink: %w", err)
+		}
+		sinks = append(sinks, kf)
+	}
+
+	if cfg.NATS.URL != "" {
+		nc, err := sink.NewNATS(cfg.NATS.URL, cfg.NATS.Subject)
+		if err != nil {
+			return nil, fmt.Errorf("create nats sink: %w", err)
+		}
+		sinks = append(sinks, nc)
+	}
+
+	if cfg.Redis.URL != "" {
+		rd, err := sink.NewRedis(cfg.Redis.URL, cfg.Redis.Channel)
+		if err != nil {
+			return nil, fmt.Errorf("create redis sink: %w", err)
+		}
+		sinks = append(sinks, rd)
+	}
+
+	return sinks, nil
+}
+
+func job(cfg *config.Config, name string, run func(context.Context) error) scheduler.Job {
+	j := cfg.Jobs[name]
+	return scheduler.Job{
+		Name:     name,
+		Interval: time.Duration(j.Interval),
+		Jitter:   time.Duration(j.Jitter),
+		Run:      run,
+	}
+}
+
+// pollPairs returns a job polling the pairs returned by pairs at every run
+func pollPairs(s *server.Server, pairs func() []string) func(context.Context) error {
+	return func(ctx context.Context) error {
+		var errs []error
+		for _, pair := range pairs() {
+			if err := s.Poll(ctx, pair); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", pair, err))
+			}
+		}
+
+		return errors.Join(errs...)
 	}
 }
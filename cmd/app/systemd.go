@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/coreos/go-systemd/v22/activation"
+	"github.com/coreos/go-systemd/v22/daemon"
+	"github.com/ivanglie/coinmon/pkg/log"
+)
+
+// systemdListeners returns the sockets passed by systemd socket activation.
+// A single socket serves HTTP, several are told apart by their
+// FileDescriptorName, http and grpc.
+func systemdListeners() (httpLn, grpcLn net.Listener, err error) {
+	named, err := activation.ListenersWithNames()
+	if err != nil {
+		return nil, nil, fmt.Errorf("get systemd sockets: %w", err)
+	}
+
+	var all []net.Listener
+	for _, lns := range named {
+		all = append(all, lns...)
+	}
+	if len(all) == 1 {
+		return all[0], nil, nil
+	}
+
+	if lns := named["http"]; len(lns) > 0 {
+		httpLn = lns[0]
+	}
+	if lns := named["grpc"]; len(lns) > 0 {
+		grpcLn = lns[0]
+	}
+	if len(all) > 0 && httpLn == nil && grpcLn == nil {
+		return nil, nil, fmt.Errorf("name systemd sockets http and grpc, got %d sockets", len(all))
+	}
+
+	return httpLn, grpcLn, nil
+}
+
+// sdNotify reports state to systemd when run as a Type=notify service
+func sdNotify(state string) {
+	if _, err := daemon.SdNotify(false, state); err != nil {
+		log.Error("Failed to notify systemd: " + err.Error())
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ivanglie/coinmon/pkg/client"
+)
+
+// benchResult represents the outcomes of the requests of a benchmark
+type benchResult struct {
+	elapsed   time.Duration
+	latencies []time.Duration // of successful requests
+	errors    map[string]int  // by kind, e.g. "status 429"
+	skipped   int             // requests not sent, with too many in flight
+}
+
+// bench requests the prices of pairs in turn at rps requests per second for
+// d. Requests are sent on schedule whether or not earlier ones completed, so
+// a slow instance shows up as latency rather than as a lower rate, up to
+// concurrency requests in flight. Requests due past it are skipped.
+func bench(ctx context.Context, c *client.Client, pairs []string, rps float64, d time.Duration, concurrency int) benchResult {
+	res := benchResult{errors: make(map[string]int)}
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		inFlight = make(chan struct{}, concurrency)
+	)
+
+	ctx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / rps))
+	defer ticker.Stop()
+
+	start := time.Now()
+	for i := 0; ; i++ {
+		select {
+		case inFlight <- struct{}{}:
+			pair := pairs[i%len(pairs)]
+			wg.Go(func() {
+				defer func() { <-inFlight }()
+				t := time.Now()
+				// Requests in flight at the end of the run complete on their own
+				_, err := c.GetSpot(context.WithoutCancel(ctx), pair)
+				latency := time.Since(t)
+
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					res.errors[errorKind(err)]++
+					return
+				}
+				res.latencies = append(res.latencies, latency)
+			})
+		default:
+			res.skipped++
+		}
+
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			res.elapsed = time.Since(start)
+			return res
+		case <-ticker.C:
+		}
+	}
+}
+
+// errorKind classifies err of a request for the report
+func errorKind(err error) string {
+	var apiErr *client.Error
+	switch {
+	case errors.As(err, &apiErr):
+		return fmt.Sprintf("status %d", apiErr.StatusCode)
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	default:
+		return "network"
+	}
+}
+
+// percentile returns the latency p percent of sorted are at or below
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	i := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	return sorted[max(i, 0)]
+}
+
+// report writes the request rate, error rate and latency percentiles of r
+func (r benchResult) report(w io.Writer) error {
+	failed := 0
+	for _, n := range r.errors {
+		failed += n
+	}
+	total := len(r.latencies) + failed
+	if total == 0 {
+		_, err := fmt.Fprintln(w, "no requests sent")
+		return err
+	}
+
+	latencies := slices.Clone(r.latencies)
+	slices.Sort(latencies)
+
+	lines := []string{
+		fmt.Sprintf("requests  %d in %s (%.1f/s)", total, r.elapsed.Round(time.Millisecond), float64(total)/r.elapsed.Seconds()),
+		fmt.Sprintf("errors    %d (%.2f%%)", failed, 100*float64(failed)/float64(total)),
+	}
+	for _, kind := range slices.Sorted(maps.Keys(r.errors)) {
+		lines = append(lines, fmt.Sprintf("  %-14s %d", kind, r.errors[kind]))
+	}
+	if r.skipped > 0 {
+		lines = append(lines, fmt.Sprintf("skipped   %d (concurrency reached)", r.skipped))
+	}
+	if len(latencies) > 0 {
+		lines = append(lines, fmt.Sprintf("latency   p50 %s  p90 %s  p99 %s  max %s",
+			percentile(latencies, 50).Round(time.Microsecond),
+			percentile(latencies, 90).Round(time.Microsecond),
+			percentile(latencies, 99).Round(time.Microsecond),
+			latencies[len(latencies)-1].Round(time.Microsecond)))
+	}
+
+	for _, l := range lines {
+		if _, err := fmt.Fprintln(w, l); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
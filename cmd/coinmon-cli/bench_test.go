@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ivanglie/coinmon/pkg/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBench_Concurrency(t *testing.T) {
+	unblock := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+		_, _ = w.Write([]byte(`{"data":{"pair":"BTCUSDT","price":"97000.01","source":"binance","time":"2025-01-01T00:00:00Z"}}`))
+	}))
+	defer ts.Close()
+
+	// Requests complete only once the run is over, so all but two are skipped
+	time.AfterFunc(100*time.Millisecond, func() { close(unblock) })
+	c := client.New(ts.URL, client.WithRetries(0, 0))
+	res := bench(context.Background(), c, []string{"BTCUSDT"}, 200, 50*time.Millisecond, 2)
+
+	assert.Len(t, res.latencies, 2)
+	assert.Empty(t, res.errors)
+	assert.Positive(t, res.skipped)
+}
+
+func TestErrorKind(t *testing.T) {
+	assert.Equal(t, "status 429", errorKind(&client.Error{StatusCode: http.StatusTooManyRequests}))
+	assert.Equal(t, "timeout", errorKind(context.DeadlineExceeded))
+	assert.Equal(t, "network", errorKind(errors.New("connection refused")))
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	tests := []struct {
+		name     string
+		sorted   []time.Duration
+		p        float64
+		expected time.Duration
+	}{
+		{name: "empty", p: 50, expected: 0},
+		{name: "single", sorted: []time.Duration{7}, p: 99, expected: 7},
+		{name: "p0", sorted: sorted, p: 0, expected: 1},
+		{name: "p50", sorted: sorted, p: 50, expected: 5},
+		{name: "p90", sorted: sorted, p: 90, expected: 9},
+		{name: "p99", sorted: sorted, p: 99, expected: 10},
+		{name: "p100", sorted: sorted, p: 100, expected: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, percentile(tt.sorted, tt.p))
+		})
+	}
+}
+
+func TestBenchResult_Report(t *testing.T) {
+	tests := []struct {
+		name     string
+		result   benchResult
+		expected string
+	}{
+		{
+			name:     "no requests",
+			result:   benchResult{elapsed: time.Second},
+			expected: "no requests sent\n",
+		},
+		{
+			name: "latencies",
+			result: benchResult{
+				elapsed:   2 * time.Second,
+				latencies: []time.Duration{3 * time.Millisecond, time.Millisecond, 2 * time.Millisecond, 4 * time.Millisecond},
+			},
+			expected: "requests  4 in 2s (2.0/s)\n" +
+				"errors    0 (0.00%)\n" +
+				"latency   p50 2ms  p90 4ms  p99 4ms  max 4ms\n",
+		},
+		{
+			name: "errors and skipped",
+			result: benchResult{
+				elapsed:   time.Second,
+				latencies: []time.Duration{1500 * time.Microsecond},
+				errors:    map[string]int{"status 429": 2, "network": 1},
+				skipped:   5,
+			},
+			expected: "requests  4 in 1s (4.0/s)\n" +
+				"errors    3 (75.00%)\n" +
+				"  network        1\n" +
+				"  status 429     2\n" +
+				"skipped   5 (concurrency reached)\n" +
+				"latency   p50 1.5ms  p90 1.5ms  p99 1.5ms  max 1.5ms\n",
+		},
+		{
+			name: "only errors",
+			result: benchResult{
+				elapsed: time.Second,
+				errors:  map[string]int{"timeout": 1},
+			},
+			expected: "requests  1 in 1s (1.0/s)\n" +
+				"errors    1 (100.00%)\n" +
+				"  timeout        1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			assert.NoError(t, tt.result.report(&buf))
+			assert.Equal(t, tt.expected, buf.String())
+		})
+	}
+}
@@ -0,0 +1,219 @@
+// Package main is the entry point of the coinmon command-line tool.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/ivanglie/coinmon/internal/server"
+	"github.com/ivanglie/coinmon/pkg/client"
+	"github.com/ivanglie/coinmon/pkg/log"
+	"github.com/rs/zerolog"
+)
+
+// errUsage is returned for invalid command lines, which exit with status 2
+var errUsage = errors.New("usage")
+
+// maxBenchRPS bounds -rps well below the rate a ticker can tick at
+const maxBenchRPS = 10000
+
+// options represents the flags of the command line
+type options struct {
+	server      string
+	apiKey      string
+	timeout     time.Duration
+	exchanges   string
+	details     bool
+	verbose     bool
+	pairs       string
+	rps         float64
+	duration    time.Duration
+	concurrency int
+}
+
+// quoter resolves prices from a coinmon instance or from the exchanges
+type quoter interface {
+	spot(ctx context.Context, pair string) (client.Quote, error)
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if errors.Is(err, errUsage) {
+			os.Exit(2)
+		}
+		_, _ = fmt.Fprintln(os.Stderr, "coinmon-cli: "+err.Error())
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout, stderr io.Writer) error {
+	var o options
+	fs := flag.NewFlagSet("coinmon-cli", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&o.server, "server", os.Getenv("COINMON_SERVER"), "URL of a coinmon instance to query, e.g. https://coinmon.cc, $COINMON_SERVER by default; the exchanges are called directly when empty")
+	fs.StringVar(&o.apiKey, "api-key", os.Getenv("COINMON_API_KEY"), "API key sent to the instance, $COINMON_API_KEY by default")
+	fs.DurationVar(&o.timeout, "timeout", 10*time.Second, "timeout of a query")
+	fs.StringVar(&o.exchanges, "exchanges", "", "comma separated exchanges to call without -server, e.g. binance,kraken, all by default")
+	fs.BoolVar(&o.details, "details", false, "print the pair, price and source as JSON")
+	fs.BoolVar(&o.verbose, "verbose", false, "log exchange calls to stderr")
+	fs.StringVar(&o.pairs, "pairs", "BTCUSDT", "comma separated pairs bench requests in turn")
+	fs.Float64Var(&o.rps, "rps", 10, fmt.Sprintf("requests per second of bench, up to %d", maxBenchRPS))
+	fs.DurationVar(&o.duration, "duration", 10*time.Second, "duration of bench")
+	fs.IntVar(&o.concurrency, "concurrency", 100, "requests of bench in flight at most, requests due past it are skipped")
+	fs.Usage = func() {
+		_, _ = fmt.Fprintln(stderr, "Usage: coinmon-cli [flags] spot <pair>...")
+		_, _ = fmt.Fprintln(stderr, "       coinmon-cli -server <url> [flags] bench")
+		_, _ = fmt.Fprintln(stderr, "\nspot prints spot prices of the pairs, from a coinmon instance with -server or from the fastest responding exchange.")
+		_, _ = fmt.Fprintln(stderr, "bench requests prices of -pairs from the instance at -rps for -duration and reports error rates and latency percentiles.")
+		_, _ = fmt.Fprintln(stderr, "\nFlags:")
+		fs.PrintDefaults()
+	}
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return errUsage
+	}
+	switch {
+	case len(positional) == 1 && positional[0] == "bench":
+		return runBench(o, stdout, fs.Usage)
+	case len(positional) < 2 || positional[0] != "spot":
+		fs.Usage()
+		return errUsage
+	}
+
+	q, err := newQuoter(o)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+
+	var errs []error
+	for _, pair := range positional[1:] {
+		quote, spotErr := q.spot(ctx, strings.ToUpper(pair))
+		if spotErr != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", pair, spotErr))
+			continue
+		}
+		if err = printQuote(stdout, quote, o.details); err != nil {
+			return err
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// runBench drives the instance of o.server and reports the outcomes
+func runBench(o options, stdout io.Writer, usage func()) error {
+	var pairs []string
+	for pair := range strings.SplitSeq(o.pairs, ",") {
+		if pair = strings.TrimSpace(pair); pair != "" {
+			pairs = append(pairs, strings.ToUpper(pair))
+		}
+	}
+	if o.server == "" || len(pairs) == 0 || !(o.rps > 0 && o.rps <= maxBenchRPS) || o.duration <= 0 || o.concurrency <= 0 {
+		usage()
+		return errUsage
+	}
+
+	// Failed requests are counted, not retried
+	opts := []client.Option{client.WithTimeout(o.timeout), client.WithRetries(0, 0)}
+	if o.apiKey != "" {
+		opts = append(opts, client.WithAPIKey(o.apiKey))
+	}
+
+	res := bench(context.Background(), client.New(o.server, opts...), pairs, o.rps, o.duration, o.concurrency)
+	return res.report(stdout)
+}
+
+// parseInterspersed parses flags given before, between and after the
+// positional arguments, e.g. spot BTCUSDT -details, and returns the latter
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+func newQuoter(o options) (quoter, error) {
+	if o.server != "" {
+		var opts []client.Option
+		if o.apiKey != "" {
+			opts = append(opts, client.WithAPIKey(o.apiKey))
+		}
+		return &remote{c: client.New(o.server, opts...)}, nil
+	}
+
+	if o.verbose {
+		log.SetLogConfig(zerolog.DebugLevel, os.Stderr)
+	}
+
+	var names []exchange.Name
+	for name := range strings.SplitSeq(o.exchanges, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		n, err := exchange.ParseName(name)
+		if err != nil {
+			return nil, fmt.Errorf("parse exchanges: %w", err)
+		}
+		names = append(names, n)
+	}
+
+	opts := []server.Option{server.WithUpstreamTimeout(o.timeout)}
+	if len(names) > 0 {
+		opts = append(opts, server.WithExchanges(names...))
+	}
+
+	return &standalone{s: server.New("", opts...)}, nil
+}
+
+// remote queries a coinmon instance
+type remote struct {
+	c *client.Client
+}
+
+func (r *remote) spot(ctx context.Context, pair string) (client.Quote, error) {
+	return r.c.GetSpot(ctx, pair)
+}
+
+// standalone races the exchanges itself
+type standalone struct {
+	s *server.Server
+}
+
+func (st *standalone) spot(ctx context.Context, pair string) (client.Quote, error) {
+	price, source, err := st.s.Spot(ctx, pair)
+	if err != nil {
+		return client.Quote{}, err
+	}
+
+	return client.Quote{Pair: pair, Price: price, Source: source, Time: time.Now().UTC()}, nil
+}
+
+// printQuote writes the price of q like /api/v1/spot/{pair} does, as JSON with
+// its pair and source with details
+func printQuote(w io.Writer, q client.Quote, details bool) error {
+	if !details {
+		_, err := fmt.Fprintf(w, "%g\n", q.Price)
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(server.DetailedResponse{Pair: q.Pair, Price: q.Price, Source: q.Source})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseInterspersed(t *testing.T) {
+	tests := []struct {
+		name               string
+		args               []string
+		expectedPositional []string
+		expectedDetails    bool
+		expectedServer     string
+		expectedError      bool
+	}{
+		{name: "no args"},
+		{name: "flags first", args: []string{"-details", "spot", "BTCUSDT"}, expectedPositional: []string{"spot", "BTCUSDT"}, expectedDetails: true},
+		{name: "flags last", args: []string{"spot", "BTCUSDT", "-details"}, expectedPositional: []string{"spot", "BTCUSDT"}, expectedDetails: true},
+		{
+			name:               "flags between",
+			args:               []string{"spot", "-server", "http://localhost:8080", "BTCUSDT", "ETHUSDT"},
+			expectedPositional: []string{"spot", "BTCUSDT", "ETHUSDT"},
+			expectedServer:     "http://localhost:8080",
+		},
+		{name: "terminator", args: []string{"spot", "--", "-details"}, expectedPositional: []string{"spot", "-details"}},
+		{name: "unknown flag", args: []string{"spot", "-unknown"}, expectedError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				details bool
+				server  string
+			)
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			fs.BoolVar(&details, "details", false, "")
+			fs.StringVar(&server, "server", "", "")
+
+			positional, err := parseInterspersed(fs, tt.args)
+			if tt.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedPositional, positional)
+			assert.Equal(t, tt.expectedDetails, details)
+			assert.Equal(t, tt.expectedServer, server)
+		})
+	}
+}
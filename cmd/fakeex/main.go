@@ -0,0 +1,66 @@
+// Package main serves a fake exchange for local development without
+// internet access.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/fakeex"
+	"github.com/ivanglie/coinmon/pkg/log"
+)
+
+func main() {
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		_, _ = fmt.Fprintf(out, "Usage: %s [flags]\n\n", os.Args[0])
+		_, _ = fmt.Fprintln(out, "Serves Binance, Bybit, Bitget and Kraken shaped prices, for coinmon with upstream base_urls pointing at it.")
+		_, _ = fmt.Fprintln(out, "POST /_fakeex/price?pair=BTCUSDT&price=97000 changes a price, POST /_fakeex/exchange?name=binance&delay=200ms&failure=unavailable")
+		_, _ = fmt.Fprintln(out, "delays or fails an exchange, with failure unavailable, rate_limited, unknown_symbol, malformed, hang or empty to recover.")
+		_, _ = fmt.Fprintln(out, "\nFlags:")
+		flag.PrintDefaults()
+	}
+
+	addr := flag.String("listen", ":9090", "address to serve on")
+	prices := flag.String("prices", "BTCUSDT=97000,ETHUSDT=3500,SOLUSDT=180", "comma separated prices of pairs")
+	flag.Parse()
+
+	log.SetDefaultLogConfig()
+
+	ex := fakeex.New()
+	if err := setPrices(ex, *prices); err != nil {
+		log.Error(err.Error())
+		os.Exit(2)
+	}
+
+	srv := &http.Server{Addr: *addr, Handler: ex, ReadHeaderTimeout: 10 * time.Second}
+	log.Info("Serving fake exchanges on " + *addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Error("Failed to serve: " + err.Error())
+		os.Exit(1)
+	}
+}
+
+// setPrices sets the prices of s, e.g. BTCUSDT=97000,ETHUSDT=3500
+func setPrices(s *fakeex.Server, prices string) error {
+	for p := range strings.SplitSeq(prices, ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+
+		pair, v, ok := strings.Cut(p, "=")
+		price, err := strconv.ParseFloat(v, 64)
+		if !ok || err != nil {
+			return fmt.Errorf("invalid price %q", p)
+		}
+		s.SetPrice(pair, price)
+	}
+
+	return nil
+}
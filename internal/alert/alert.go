@@ -0,0 +1,489 @@
+// Package alert evaluates price rules and dispatches notifications.
+package alert
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/ivanglie/coinmon/pkg/log"
+)
+
+// DefaultSpreadWindow is the maximum quote age considered by spread rules without a window
+const DefaultSpreadWindow = time.Minute
+
+const watchBuffer = 256
+
+// Rule represents a price alert rule.
+// Above and Below are absolute price thresholds, Change is a percent move
+// (in either direction) within Window, Spread is a percent difference between
+// any two exchanges quoting the pair within Window.
+// Cooldown suppresses re-triggering after the rule last fired, Hysteresis is
+// a percent margin the value must cross back over before the rule resolves.
+type Rule struct {
+	ID         string
+	Pair       string
+	Above      float64
+	Below      float64
+	Change     float64
+	Spread     float64
+	Window     time.Duration
+	Cooldown   time.Duration
+	Hysteresis float64
+}
+
+// State represents firing state of a rule
+type State struct {
+	Firing       bool      `json:"firing"`
+	LastFired    time.Time `json:"last_fired,omitzero"`
+	LastResolved time.Time `json:"last_resolved,omitzero"`
+}
+
+// Event represents a rule state change delivered to a notifier
+type Event struct {
+	Rule    Rule
+	Pair    string
+	Source  string
+	Price   float64
+	Time    time.Time
+	Summary string
+}
+
+// Notifier is the interface that wraps alert delivery methods
+type Notifier interface {
+	Trigger(ctx context.Context, e Event) error
+	Resolve(ctx context.Context, e Event) error
+}
+
+type sample struct {
+	price float64
+	time  time.Time
+}
+
+// series identifies the prices of a pair reported by one exchange, so
+// changes are never measured between prices of different exchanges
+type series struct {
+	pair, name string
+}
+
+// Evaluator checks observed prices against rules and notifies on state changes
+type Evaluator struct {
+	mu       sync.Mutex
+	rules    []Rule
+	notifier Notifier
+	store    Store
+	states   map[string]State
+	version  uint64 // of states, counting changes
+	saveMu   sync.Mutex
+	saved    uint64 // version of the states in store, with saveMu held
+	history  map[series][]sample
+	quotes   map[string]map[string]sample
+	window   time.Duration
+	now      func() time.Time
+}
+
+// Option configures an Evaluator
+type Option func(*Evaluator)
+
+// WithStore persists rule states in store and restores them on creation
+func WithStore(store Store) Option {
+	return func(e *Evaluator) {
+		e.store = store
+	}
+}
+
+// NewEvaluator creates a new evaluator for the given rules
+func NewEvaluator(rules []Rule, notifier Notifier, opts ...Option) *Evaluator {
+	e := &Evaluator{
+		rules:    rules,
+		notifier: notifier,
+		states:   make(map[string]State),
+		history:  make(map[series][]sample),
+		quotes:   make(map[string]map[string]sample),
+		now:      time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	e.window = maxWindow(rules)
+
+	if e.store != nil {
+		states, err := e.store.Load()
+		if err != nil {
+			log.Error("Failed to load alert states: " + err.Error())
+		}
+
+		for id, st := range states {
+			e.states[id] = st
+		}
+	}
+
+	return e
+}
+
+// Observe evaluates rules for pair against the latest price
+func (e *Evaluator) Observe(ctx context.Context, pair, source string, price float64) {
+	now := e.now()
+
+	var trigger, resolve []Event
+	e.mu.Lock()
+	hist := e.record(pair, source, price, now)
+	quotes := e.quote(pair, source, price, now)
+	for _, r := range e.rules {
+		if r.Pair != pair {
+			continue
+		}
+
+		st := e.states[r.ID]
+		breached, summary := r.check(price, hist, quotes, now, st.Firing)
+		ev := Event{Rule: r, Pair: pair, Source: source, Price: price, Time: now, Summary: summary}
+		switch {
+		case breached && !st.Firing:
+			if r.Cooldown > 0 && now.Sub(st.LastFired) < r.Cooldown {
+				continue
+			}
+
+			st.Firing, st.LastFired = true, now
+			trigger = append(trigger, ev)
+		case !breached && st.Firing:
+			st.Firing, st.LastResolved = false, now
+			ev.Summary = fmt.Sprintf("%s recovered at %g", pair, price)
+			resolve = append(resolve, ev)
+		default:
+			continue
+		}
+
+		e.states[r.ID] = st
+	}
+
+	var (
+		snapshot map[string]State
+		version  uint64
+	)
+	if len(trigger)+len(resolve) > 0 {
+		snapshot, version = e.snapshot()
+	}
+	e.mu.Unlock()
+
+	e.save(snapshot, version)
+
+	for _, ev := range trigger {
+		log.Info("Alert triggered: " + ev.Summary)
+		if err := e.notifier.Trigger(ctx, ev); err != nil {
+			log.Error(fmt.Sprintf("Failed to trigger alert %s: %v", ev.Rule.ID, err))
+		}
+	}
+
+	for _, ev := range resolve {
+		log.Info("Alert resolved: " + ev.Summary)
+		if err := e.notifier.Resolve(ctx, ev); err != nil {
+			log.Error(fmt.Sprintf("Failed to resolve alert %s: %v", ev.Rule.ID, err))
+		}
+	}
+}
+
+// Watch evaluates rules against every price event published to b until ctx
+// is canceled
+func (e *Evaluator) Watch(ctx context.Context, b *bus.Bus) {
+	events, unsubscribe := b.SubscribeAll(watchBuffer)
+
+	go func() {
+		defer unsubscribe()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev := <-events:
+				if e.watches(ev.Pair) {
+					e.Observe(ctx, ev.Pair, ev.Source, ev.Price)
+				}
+			}
+		}
+	}()
+}
+
+// SetRules replaces the rules, keeping the states of rules whose id is
+// kept. Incidents of removed rules in alarm are resolved.
+func (e *Evaluator) SetRules(ctx context.Context, rules []Rule) {
+	now := e.now()
+	kept := make(map[string]bool, len(rules))
+	for _, r := range rules {
+		kept[r.ID] = true
+	}
+
+	var resolve []Event
+	e.mu.Lock()
+	for _, r := range e.rules {
+		if kept[r.ID] {
+			continue
+		}
+
+		if e.states[r.ID].Firing {
+			resolve = append(resolve, Event{Rule: r, Pair: r.Pair, Time: now, Summary: fmt.Sprintf("rule %s removed", r.ID)})
+		}
+		delete(e.states, r.ID)
+	}
+	e.rules = rules
+	e.window = maxWindow(rules)
+
+	snapshot, version := e.snapshot()
+	e.mu.Unlock()
+
+	e.save(snapshot, version)
+
+	for _, ev := range resolve {
+		log.Info("Alert resolved: " + ev.Summary)
+		if err := e.notifier.Resolve(ctx, ev); err != nil {
+			log.Error(fmt.Sprintf("Failed to resolve alert %s: %v", ev.Rule.ID, err))
+		}
+	}
+}
+
+// maxWindow returns the longest window of rules
+func maxWindow(rules []Rule) time.Duration {
+	var window time.Duration
+	for _, r := range rules {
+		window = max(window, r.Window)
+	}
+
+	return window
+}
+
+// watches reports whether any rule refers to pair
+func (e *Evaluator) watches(pair string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, r := range e.rules {
+		if r.Pair == pair {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Rules returns the rules evaluated
+func (e *Evaluator) Rules() []Rule {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return slices.Clone(e.rules)
+}
+
+// Firing reports whether the rule with id is currently in alarm
+func (e *Evaluator) Firing(id string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.states[id].Firing
+}
+
+// State returns the state of the rule with id
+func (e *Evaluator) State(id string) State {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.states[id]
+}
+
+// Pairs returns pairs referenced by rules
+func (e *Evaluator) Pairs() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var pairs []string
+	seen := make(map[string]bool)
+	for _, r := range e.rules {
+		if !seen[r.Pair] {
+			seen[r.Pair] = true
+			pairs = append(pairs, r.Pair)
+		}
+	}
+
+	return pairs
+}
+
+// Compact drops price history and exchange quotes that no rule can use anymore
+func (e *Evaluator) Compact() {
+	now := e.now()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	window := max(e.window, DefaultSpreadWindow)
+
+	for src, hist := range e.history {
+		if len(hist) == 0 || now.Sub(hist[len(hist)-1].time) > window {
+			delete(e.history, src)
+		}
+	}
+
+	for pair, quotes := range e.quotes {
+		for source, q := range quotes {
+			if now.Sub(q.time) > window {
+				delete(quotes, source)
+			}
+		}
+
+		if len(quotes) == 0 {
+			delete(e.quotes, pair)
+		}
+	}
+}
+
+// WantsAllSources reports whether pair has rules comparing quotes across exchanges
+func (e *Evaluator) WantsAllSources(pair string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, r := range e.rules {
+		if r.Pair == pair && r.Spread != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// snapshot returns a copy of the changed states to save and their version,
+// with mu held
+func (e *Evaluator) snapshot() (map[string]State, uint64) {
+	e.version++
+	if e.store == nil {
+		return nil, e.version
+	}
+
+	snapshot := make(map[string]State, len(e.states))
+	for id, st := range e.states {
+		snapshot[id] = st
+	}
+
+	return snapshot, e.version
+}
+
+// save persists states of version unless a later version has been saved,
+// so saves racing after the lock is released never overwrite newer states
+func (e *Evaluator) save(states map[string]State, version uint64) {
+	if states == nil {
+		return
+	}
+
+	e.saveMu.Lock()
+	defer e.saveMu.Unlock()
+	if version <= e.saved {
+		return
+	}
+
+	if err := e.store.Save(states); err != nil {
+		log.Error("Failed to save alert states: " + err.Error())
+		return
+	}
+	e.saved = version
+}
+
+// quote stores the latest price of pair reported by source
+func (e *Evaluator) quote(pair, source string, price float64, now time.Time) map[string]sample {
+	quotes, ok := e.quotes[pair]
+	if !ok {
+		quotes = make(map[string]sample)
+		e.quotes[pair] = quotes
+	}
+
+	quotes[source] = sample{price: price, time: now}
+	return quotes
+}
+
+// record appends price to the history of pair reported by name and drops
+// samples older than the longest rule window
+func (e *Evaluator) record(pair, name string, price float64, now time.Time) []sample {
+	src := series{pair: pair, name: name}
+	hist := append(e.history[src], sample{price: price, time: now})
+
+	i := 0
+	for i < len(hist)-1 && now.Sub(hist[i].time) > e.window {
+		i++
+	}
+	hist = hist[i:]
+
+	e.history[src] = hist
+	return hist
+}
+
+// check reports whether the rule is breached. While firing, thresholds are
+// relaxed by Hysteresis so the rule does not flap around the boundary.
+func (r *Rule) check(price float64, hist []sample, quotes map[string]sample, now time.Time, firing bool) (breached bool, summary string) {
+	margin := 0.0
+	if firing {
+		margin = r.Hysteresis / 100
+	}
+
+	switch {
+	case r.Above != 0 && price > r.Above*(1-margin):
+		return true, fmt.Sprintf("%s is above %g: %g", r.Pair, r.Above, price)
+	case r.Below != 0 && price < r.Below*(1+margin):
+		return true, fmt.Sprintf("%s is below %g: %g", r.Pair, r.Below, price)
+	case r.Change != 0:
+		return r.checkChange(price, hist, now, r.Change*(1-margin))
+	case r.Spread != 0:
+		return r.checkSpread(quotes, now, r.Spread*(1-margin))
+	}
+
+	return false, ""
+}
+
+func (r *Rule) checkChange(price float64, hist []sample, now time.Time, limit float64) (breached bool, summary string) {
+	for _, s := range hist {
+		if now.Sub(s.time) > r.Window {
+			continue
+		}
+
+		// The oldest sample within the window is the reference price
+		if s.price == 0 {
+			return false, ""
+		}
+
+		change := (price - s.price) / s.price * 100
+		if math.Abs(change) >= limit {
+			return true, fmt.Sprintf("%s moved %+.2f%% in %s: %g", r.Pair, change, r.Window, price)
+		}
+
+		return false, ""
+	}
+
+	return false, ""
+}
+
+func (r *Rule) checkSpread(quotes map[string]sample, now time.Time, limit float64) (breached bool, summary string) {
+	window := r.Window
+	if window == 0 {
+		window = DefaultSpreadWindow
+	}
+
+	var low, high string
+	for source, q := range quotes {
+		if now.Sub(q.time) > window || q.price <= 0 {
+			continue
+		}
+
+		if low == "" || q.price < quotes[low].price {
+			low = source
+		}
+		if high == "" || q.price > quotes[high].price {
+			high = source
+		}
+	}
+
+	if low == "" || low == high {
+		return false, ""
+	}
+
+	lp, hp := quotes[low].price, quotes[high].price
+	spread := (hp - lp) / lp * 100
+	if spread >= limit {
+		return true, fmt.Sprintf("%s spread %.2f%% between %s (%g) and %s (%g)", r.Pair, spread, low, lp, high, hp)
+	}
+
+	return false, ""
+}
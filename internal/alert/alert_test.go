@@ -0,0 +1,442 @@
+package alert
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockNotifier struct {
+	mu        sync.Mutex
+	triggered []Event
+	resolved  []Event
+}
+
+func (m *mockNotifier) Trigger(_ context.Context, e Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.triggered = append(m.triggered, e)
+	return nil
+}
+
+func (m *mockNotifier) Resolve(_ context.Context, e Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.resolved = append(m.resolved, e)
+	return nil
+}
+
+func TestEvaluator_Observe(t *testing.T) {
+	tests := []struct {
+		name              string
+		rule              Rule
+		prices            []float64
+		expectedTriggered int
+		expectedResolved  int
+		expectedFiring    bool
+	}{
+		{
+			name:              "below threshold triggers once",
+			rule:              Rule{ID: "usdt-depeg", Pair: "USDCUSDT", Below: 0.99},
+			prices:            []float64{1.0, 0.98, 0.97},
+			expectedTriggered: 1,
+			expectedFiring:    true,
+		},
+		{
+			name:              "recovery resolves",
+			rule:              Rule{ID: "usdt-depeg", Pair: "USDCUSDT", Below: 0.99},
+			prices:            []float64{0.98, 1.0},
+			expectedTriggered: 1,
+			expectedResolved:  1,
+		},
+		{
+			name:              "above threshold",
+			rule:              Rule{ID: "btc-ath", Pair: "BTCUSDT", Above: 100000},
+			prices:            []float64{99999.99, 100000.01},
+			expectedTriggered: 1,
+			expectedFiring:    true,
+		},
+		{
+			name:   "no breach",
+			rule:   Rule{ID: "btc-ath", Pair: "BTCUSDT", Above: 100000},
+			prices: []float64{99999.99},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &mockNotifier{}
+			e := NewEvaluator([]Rule{tt.rule}, n)
+
+			for _, p := range tt.prices {
+				e.Observe(context.Background(), tt.rule.Pair, "binance", p)
+			}
+
+			assert.Len(t, n.triggered, tt.expectedTriggered)
+			assert.Len(t, n.resolved, tt.expectedResolved)
+			assert.Equal(t, tt.expectedFiring, e.Firing(tt.rule.ID))
+		})
+	}
+}
+
+func TestEvaluator_Observe_OtherPair(t *testing.T) {
+	n := &mockNotifier{}
+	e := NewEvaluator([]Rule{{ID: "btc", Pair: "BTCUSDT", Below: 1}}, n)
+
+	e.Observe(context.Background(), "ETHUSDT", "binance", 0.5)
+
+	assert.Empty(t, n.triggered)
+	assert.False(t, e.Firing("btc"))
+}
+
+func TestEvaluator_Observe_PercentChange(t *testing.T) {
+	type tick struct {
+		after time.Duration
+		price float64
+	}
+
+	tests := []struct {
+		name              string
+		ticks             []tick
+		expectedTriggered int
+		expectedResolved  int
+	}{
+		{
+			name:              "rise within window",
+			ticks:             []tick{{0, 3000}, {5 * time.Minute, 3100}, {10 * time.Minute, 3160}},
+			expectedTriggered: 1,
+		},
+		{
+			name:              "drop within window",
+			ticks:             []tick{{0, 3000}, {10 * time.Minute, 2840}},
+			expectedTriggered: 1,
+		},
+		{
+			name:  "move spread over longer period",
+			ticks: []tick{{0, 3000}, {10 * time.Minute, 3100}, {20 * time.Minute, 3200}, {30 * time.Minute, 3300}},
+		},
+		{
+			name:              "resolves when move falls out of window",
+			ticks:             []tick{{0, 3000}, {5 * time.Minute, 3200}, {25 * time.Minute, 3200}},
+			expectedTriggered: 1,
+			expectedResolved:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &mockNotifier{}
+			e := NewEvaluator([]Rule{{ID: "eth-move", Pair: "ETHUSDT", Change: 5, Window: 15 * time.Minute}}, n)
+
+			start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+			for _, tk := range tt.ticks {
+				e.now = func() time.Time { return start.Add(tk.after) }
+				e.Observe(context.Background(), "ETHUSDT", "binance", tk.price)
+			}
+
+			assert.Len(t, n.triggered, tt.expectedTriggered)
+			assert.Len(t, n.resolved, tt.expectedResolved)
+		})
+	}
+}
+
+func TestEvaluator_Observe_ChangeSources(t *testing.T) {
+	n := &mockNotifier{}
+	e := NewEvaluator([]Rule{{ID: "eth-move", Pair: "ETHUSDT", Change: 5, Window: 15 * time.Minute}}, n)
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	e.now = func() time.Time { return start }
+	e.Observe(context.Background(), "ETHUSDT", "binance", 3000)
+	e.Observe(context.Background(), "ETHUSDT", "kraken", 3200)
+	e.Observe(context.Background(), "ETHUSDT", "binance", 3000)
+	assert.Empty(t, n.triggered, "prices of different exchanges are no change")
+
+	e.now = func() time.Time { return start.Add(5 * time.Minute) }
+	e.Observe(context.Background(), "ETHUSDT", "kraken", 3400)
+	assert.Len(t, n.triggered, 1)
+}
+
+func TestEvaluator_Watch(t *testing.T) {
+	n := &mockNotifier{}
+	e := NewEvaluator([]Rule{{ID: "btc", Pair: "BTCUSDT", Above: 100000}}, n)
+	b := bus.New()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	e.Watch(ctx, b)
+
+	b.Publish(bus.PriceUpdated{Pair: "ETHUSDT", Price: 200000, Source: "binance"})
+	b.Publish(bus.PriceUpdated{Pair: "BTCUSDT", Price: 100000.01, Source: "bybit"})
+
+	assert.Eventually(t, func() bool { return e.Firing("btc") }, time.Second, 5*time.Millisecond)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	assert.Len(t, n.triggered, 1)
+	assert.Equal(t, "bybit", n.triggered[0].Source)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	assert.NotContains(t, e.history, series{"ETHUSDT", "binance"}, "pairs without rules are not recorded")
+}
+
+func TestEvaluator_record(t *testing.T) {
+	e := NewEvaluator([]Rule{{ID: "r", Pair: "ETHUSDT", Change: 1, Window: time.Minute}}, &mockNotifier{})
+	start := time.Now()
+
+	e.record("ETHUSDT", "binance", 1, start)
+	e.record("ETHUSDT", "binance", 2, start.Add(30*time.Second))
+	e.record("ETHUSDT", "kraken", 10, start.Add(60*time.Second))
+	hist := e.record("ETHUSDT", "binance", 3, start.Add(90*time.Second))
+
+	assert.Len(t, hist, 2)
+	assert.Equal(t, 2.0, hist[0].price)
+	assert.Len(t, e.history[series{"ETHUSDT", "kraken"}], 1, "sources keep their own history")
+}
+
+func TestEvaluator_Observe_Spread(t *testing.T) {
+	type quote struct {
+		after  time.Duration
+		source string
+		price  float64
+	}
+
+	tests := []struct {
+		name              string
+		quotes            []quote
+		expectedTriggered int
+		expectedResolved  int
+		expectedSummary   string
+	}{
+		{
+			name:              "spread above threshold",
+			quotes:            []quote{{0, "binance", 100000}, {0, "kraken", 101500}},
+			expectedTriggered: 1,
+			expectedSummary:   "BTCUSDT spread 1.50% between binance (100000) and kraken (101500)",
+		},
+		{
+			name:   "spread below threshold",
+			quotes: []quote{{0, "binance", 100000}, {0, "bybit", 100500}},
+		},
+		{
+			name:   "single source",
+			quotes: []quote{{0, "binance", 100000}, {time.Second, "binance", 120000}},
+		},
+		{
+			name:   "stale quote is ignored",
+			quotes: []quote{{0, "kraken", 90000}, {2 * time.Minute, "binance", 100000}},
+		},
+		{
+			name:              "resolves when exchanges converge",
+			quotes:            []quote{{0, "binance", 100000}, {0, "kraken", 102000}, {time.Second, "kraken", 100100}},
+			expectedTriggered: 1,
+			expectedResolved:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &mockNotifier{}
+			e := NewEvaluator([]Rule{{ID: "btc-spread", Pair: "BTCUSDT", Spread: 1}}, n)
+
+			start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+			for _, q := range tt.quotes {
+				e.now = func() time.Time { return start.Add(q.after) }
+				e.Observe(context.Background(), "BTCUSDT", q.source, q.price)
+			}
+
+			assert.Len(t, n.triggered, tt.expectedTriggered)
+			assert.Len(t, n.resolved, tt.expectedResolved)
+			if tt.expectedSummary != "" {
+				assert.Equal(t, tt.expectedSummary, n.triggered[0].Summary)
+			}
+		})
+	}
+}
+
+func TestEvaluator_WantsAllSources(t *testing.T) {
+	e := NewEvaluator([]Rule{
+		{ID: "btc-spread", Pair: "BTCUSDT", Spread: 1},
+		{ID: "eth-below", Pair: "ETHUSDT", Below: 1000},
+	}, &mockNotifier{})
+
+	assert.True(t, e.WantsAllSources("BTCUSDT"))
+	assert.False(t, e.WantsAllSources("ETHUSDT"))
+	assert.False(t, e.WantsAllSources("SOLUSDT"))
+}
+
+func TestEvaluator_Restore(t *testing.T) {
+	store := NewFileStore(filepath.Join(t.TempDir(), "alerts.json"))
+	rule := Rule{ID: "depeg", Pair: "USDCUSDT", Below: 0.99}
+
+	n := &mockNotifier{}
+	NewEvaluator([]Rule{rule}, n, WithStore(store)).Observe(context.Background(), "USDCUSDT", "binance", 0.98)
+	assert.Len(t, n.triggered, 1)
+
+	// A restarted evaluator must not re-fire the rule that is already in alarm
+	n = &mockNotifier{}
+	e := NewEvaluator([]Rule{rule}, n, WithStore(store))
+	assert.True(t, e.Firing("depeg"))
+
+	e.Observe(context.Background(), "USDCUSDT", "binance", 0.97)
+	assert.Empty(t, n.triggered)
+
+	e.Observe(context.Background(), "USDCUSDT", "binance", 1.0)
+	assert.Len(t, n.resolved, 1)
+	assert.False(t, e.State("depeg").LastResolved.IsZero())
+}
+
+// blockingStore holds the first save until released
+type blockingStore struct {
+	mu      sync.Mutex
+	states  map[string]State
+	saves   int
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingStore) Load() (map[string]State, error) {
+	return map[string]State{}, nil
+}
+
+func (s *blockingStore) Save(states map[string]State) error {
+	s.mu.Lock()
+	s.saves++
+	first := s.saves == 1
+	s.mu.Unlock()
+	if first {
+		close(s.entered)
+		<-s.release
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states = states
+	return nil
+}
+
+func TestEvaluator_Observe_SaveOrder(t *testing.T) {
+	store := &blockingStore{entered: make(chan struct{}), release: make(chan struct{})}
+	e := NewEvaluator([]Rule{{ID: "depeg", Pair: "USDCUSDT", Below: 0.99}}, &mockNotifier{}, WithStore(store))
+
+	var wg sync.WaitGroup
+	wg.Go(func() { e.Observe(context.Background(), "USDCUSDT", "binance", 0.98) })
+	<-store.entered
+
+	// The recovery observed while the trigger is being saved is saved last
+	wg.Go(func() { e.Observe(context.Background(), "USDCUSDT", "binance", 1.0) })
+	time.Sleep(20 * time.Millisecond)
+	close(store.release)
+	wg.Wait()
+
+	assert.False(t, e.Firing("depeg"))
+	assert.False(t, store.states["depeg"].Firing)
+}
+
+func TestEvaluator_Observe_Cooldown(t *testing.T) {
+	n := &mockNotifier{}
+	e := NewEvaluator([]Rule{{ID: "depeg", Pair: "USDCUSDT", Below: 0.99, Cooldown: 10 * time.Minute}}, n)
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, p := range []float64{0.98, 1.0, 0.98, 1.0, 0.98} {
+		e.now = func() time.Time { return start.Add(time.Duration(i) * 3 * time.Minute) }
+		e.Observe(context.Background(), "USDCUSDT", "binance", p)
+	}
+
+	// Fired at 0m, suppressed at 6m, fired again at 12m
+	assert.Len(t, n.triggered, 2)
+	assert.Len(t, n.resolved, 1)
+}
+
+func TestEvaluator_Observe_Hysteresis(t *testing.T) {
+	n := &mockNotifier{}
+	e := NewEvaluator([]Rule{{ID: "btc", Pair: "BTCUSDT", Above: 100000, Hysteresis: 1}}, n)
+
+	for _, p := range []float64{100001, 99999, 100001, 99500, 98999} {
+		e.Observe(context.Background(), "BTCUSDT", "binance", p)
+	}
+
+	assert.Len(t, n.triggered, 1)
+	assert.Len(t, n.resolved, 1)
+	assert.Equal(t, 98999.0, n.resolved[0].Price)
+}
+
+func TestEvaluator_Pairs(t *testing.T) {
+	e := NewEvaluator([]Rule{
+		{ID: "a", Pair: "BTCUSDT", Above: 1},
+		{ID: "b", Pair: "ETHUSDT", Above: 1},
+		{ID: "c", Pair: "BTCUSDT", Below: 1},
+	}, &mockNotifier{})
+
+	assert.Equal(t, []string{"BTCUSDT", "ETHUSDT"}, e.Pairs())
+}
+
+func TestEvaluator_SetRules(t *testing.T) {
+	store := NewFileStore(filepath.Join(t.TempDir(), "alerts.json"))
+	n := &mockNotifier{}
+	e := NewEvaluator([]Rule{
+		{ID: "depeg", Pair: "USDCUSDT", Below: 0.99},
+		{ID: "btc", Pair: "BTCUSDT", Above: 100000},
+	}, n, WithStore(store))
+	e.Observe(context.Background(), "USDCUSDT", "binance", 0.98)
+	e.Observe(context.Background(), "BTCUSDT", "binance", 100001)
+	assert.Len(t, n.triggered, 2)
+
+	e.SetRules(context.Background(), []Rule{
+		{ID: "btc", Pair: "BTCUSDT", Above: 200000},
+		{ID: "eth", Pair: "ETHUSDT", Change: 5, Window: 15 * time.Minute},
+	})
+
+	// The removed rule is resolved, the kept one stays in alarm
+	assert.Len(t, n.resolved, 1)
+	assert.Equal(t, "depeg", n.resolved[0].Rule.ID)
+	assert.False(t, e.Firing("depeg"))
+	assert.True(t, e.Firing("btc"))
+	assert.Equal(t, []string{"BTCUSDT", "ETHUSDT"}, e.Pairs())
+	assert.Equal(t, []string{"btc", "eth"}, []string{e.Rules()[0].ID, e.Rules()[1].ID})
+	assert.Equal(t, 15*time.Minute, e.window)
+
+	states, err := store.Load()
+	assert.NoError(t, err)
+	assert.NotContains(t, states, "depeg")
+	assert.True(t, states["btc"].Firing)
+
+	// The kept rule is evaluated with its new threshold
+	e.Observe(context.Background(), "BTCUSDT", "binance", 150000)
+	assert.Len(t, n.resolved, 2)
+}
+
+func TestEvaluator_Compact(t *testing.T) {
+	e := NewEvaluator([]Rule{{ID: "r", Pair: "BTCUSDT", Change: 1, Window: 5 * time.Minute}}, &mockNotifier{})
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	e.now = func() time.Time { return start }
+	e.Observe(context.Background(), "BTCUSDT", "binance", 1)
+	e.Observe(context.Background(), "ETHUSDT", "kraken", 1)
+
+	e.now = func() time.Time { return start.Add(4 * time.Minute) }
+	e.Observe(context.Background(), "ETHUSDT", "bybit", 1)
+
+	e.now = func() time.Time { return start.Add(6 * time.Minute) }
+	e.Compact()
+
+	assert.NotContains(t, e.history, series{"BTCUSDT", "binance"})
+	assert.NotContains(t, e.quotes, "BTCUSDT")
+	assert.NotContains(t, e.history, series{"ETHUSDT", "kraken"})
+	assert.Contains(t, e.history, series{"ETHUSDT", "bybit"})
+	assert.Equal(t, []string{"bybit"}, keys(e.quotes["ETHUSDT"]))
+}
+
+func keys(m map[string]sample) []string {
+	var k []string
+	for key := range m {
+		k = append(k, key)
+	}
+
+	return k
+}
@@ -0,0 +1,109 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// PagerDutyURL is the PagerDuty Events API v2 endpoint
+const PagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+
+type httpClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// PagerDuty delivers alerts to PagerDuty via the Events API v2
+type PagerDuty struct {
+	RoutingKey string
+	URL        string
+	client     httpClient
+}
+
+type pagerDutyPayload struct {
+	Summary       string         `json:"summary"`
+	Source        string         `json:"source"`
+	Severity      string         `json:"severity"`
+	Timestamp     string         `json:"timestamp"`
+	CustomDetails map[string]any `json:"custom_details,omitempty"`
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+// NewPagerDuty creates a new PagerDuty notifier for the given routing key
+func NewPagerDuty(routingKey string) *PagerDuty {
+	return &PagerDuty{
+		RoutingKey: routingKey,
+		URL:        PagerDutyURL,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Trigger opens (or updates) the incident for the rule
+func (p *PagerDuty) Trigger(ctx context.Context, e Event) error {
+	return p.send(ctx, &pagerDutyEvent{
+		RoutingKey:  p.RoutingKey,
+		EventAction: "trigger",
+		DedupKey:    dedupKey(e.Rule),
+		Payload: &pagerDutyPayload{
+			Summary:   e.Summary,
+			Source:    "coinmon",
+			Severity:  "critical",
+			Timestamp: e.Time.UTC().Format(time.RFC3339),
+			CustomDetails: map[string]any{
+				"pair":     e.Pair,
+				"price":    e.Price,
+				"exchange": e.Source,
+			},
+		},
+	})
+}
+
+// Resolve closes the incident for the rule
+func (p *PagerDuty) Resolve(ctx context.Context, e Event) error {
+	return p.send(ctx, &pagerDutyEvent{
+		RoutingKey:  p.RoutingKey,
+		EventAction: "resolve",
+		DedupKey:    dedupKey(e.Rule),
+	})
+}
+
+func (p *PagerDuty) send(ctx context.Context, ev *pagerDutyEvent) error {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("do request: %w", err)
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusAccepted {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, body)
+	}
+
+	return nil
+}
+
+func dedupKey(r Rule) string {
+	return "coinmon-" + r.ID
+}
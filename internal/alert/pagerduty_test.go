@@ -0,0 +1,98 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockHTTPClient struct {
+	doFunc func(req *http.Request) (*http.Response, error)
+}
+
+func (m *mockHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	return m.doFunc(req)
+}
+
+func TestPagerDuty(t *testing.T) {
+	e := Event{
+		Rule:    Rule{ID: "usdt-depeg", Pair: "USDCUSDT", Below: 0.99},
+		Pair:    "USDCUSDT",
+		Source:  "binance",
+		Price:   0.98,
+		Time:    time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		Summary: "USDCUSDT is below 0.99: 0.98",
+	}
+
+	tests := []struct {
+		name           string
+		send           func(p *PagerDuty) error
+		status         int
+		expectedAction string
+		expectPayload  bool
+		expectError    bool
+	}{
+		{
+			name:           "trigger",
+			send:           func(p *PagerDuty) error { return p.Trigger(context.Background(), e) },
+			status:         http.StatusAccepted,
+			expectedAction: "trigger",
+			expectPayload:  true,
+		},
+		{
+			name:           "resolve",
+			send:           func(p *PagerDuty) error { return p.Resolve(context.Background(), e) },
+			status:         http.StatusAccepted,
+			expectedAction: "resolve",
+		},
+		{
+			name:           "rejected",
+			send:           func(p *PagerDuty) error { return p.Trigger(context.Background(), e) },
+			status:         http.StatusBadRequest,
+			expectedAction: "trigger",
+			expectPayload:  true,
+			expectError:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got pagerDutyEvent
+			p := NewPagerDuty("routing-key")
+			p.client = &mockHTTPClient{
+				doFunc: func(req *http.Request) (*http.Response, error) {
+					assert.Equal(t, PagerDutyURL, req.URL.String())
+					assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+					assert.NoError(t, json.NewDecoder(req.Body).Decode(&got))
+					return &http.Response{
+						StatusCode: tt.status,
+						Body:       io.NopCloser(bytes.NewReader([]byte(`{"status":"success"}`))),
+					}, nil
+				},
+			}
+
+			err := tt.send(p)
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, "routing-key", got.RoutingKey)
+			assert.Equal(t, tt.expectedAction, got.EventAction)
+			assert.Equal(t, "coinmon-usdt-depeg", got.DedupKey)
+			if tt.expectPayload {
+				assert.Equal(t, e.Summary, got.Payload.Summary)
+				assert.Equal(t, "2025-01-01T00:00:00Z", got.Payload.Timestamp)
+			} else {
+				assert.Nil(t, got.Payload)
+			}
+		})
+	}
+}
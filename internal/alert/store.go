@@ -0,0 +1,81 @@
+package alert
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Store is the interface that wraps rule state persistence methods
+type Store interface {
+	Load() (map[string]State, error)
+	Save(states map[string]State) error
+}
+
+// FileStore persists rule states as a JSON file
+type FileStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileStore creates a new file store at path
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// Load reads states from the file. Missing file yields no states.
+func (f *FileStore) Load() (map[string]State, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]State{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read states: %w", err)
+	}
+
+	states := map[string]State{}
+	if err := json.Unmarshal(b, &states); err != nil {
+		return nil, fmt.Errorf("parse states: %w", err)
+	}
+
+	return states, nil
+}
+
+// Save writes states to the file atomically
+func (f *FileStore) Save(states map[string]State) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b, err := json.MarshalIndent(states, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal states: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write states: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close temp file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("rename temp file: %w", err)
+	}
+
+	return nil
+}
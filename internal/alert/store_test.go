@@ -0,0 +1,39 @@
+package alert
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alerts.json")
+	f := NewFileStore(path)
+
+	states, err := f.Load()
+	assert.NoError(t, err)
+	assert.Empty(t, states)
+
+	fired := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, f.Save(map[string]State{"depeg": {Firing: true, LastFired: fired}}))
+
+	states, err = f.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]State{"depeg": {Firing: true, LastFired: fired}}, states)
+}
+
+func TestFileStore_LoadInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alerts.json")
+	assert.NoError(t, os.WriteFile(path, []byte("{"), 0o600))
+
+	_, err := NewFileStore(path).Load()
+	assert.Error(t, err)
+}
+
+func TestFileStore_SaveMissingDir(t *testing.T) {
+	f := NewFileStore(filepath.Join(t.TempDir(), "missing", "alerts.json"))
+	assert.Error(t, f.Save(map[string]State{}))
+}
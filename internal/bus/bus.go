@@ -0,0 +1,167 @@
+// Package bus provides a typed in-process pub/sub for price events.
+package bus
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	subscriberBuffer = 8
+	historySize      = 100
+)
+
+// PriceUpdated is published whenever a price of a pair is resolved,
+// polled or streamed from an exchange
+type PriceUpdated struct {
+	Pair   string    `json:"pair"`
+	Price  float64   `json:"price"`
+	Source string    `json:"source"`
+	Time   time.Time `json:"time"`
+}
+
+// Bus fans out PriceUpdated events to subscribers of a pair and to
+// subscribers of all pairs. It keeps the most recent events of every pair.
+// Publishing never blocks: slow subscribers miss events instead.
+type Bus struct {
+	mu   sync.Mutex
+	subs map[string]map[chan PriceUpdated]struct{}
+	all  map[chan PriceUpdated]struct{}
+	last map[string]PriceUpdated
+	hist map[string][]PriceUpdated
+}
+
+// New creates a new bus
+func New() *Bus {
+	return &Bus{
+		subs: make(map[string]map[chan PriceUpdated]struct{}),
+		all:  make(map[chan PriceUpdated]struct{}),
+		last: make(map[string]PriceUpdated),
+		hist: make(map[string][]PriceUpdated),
+	}
+}
+
+// Publish delivers e to subscribers of its pair and of all pairs
+func (b *Bus) Publish(e PriceUpdated) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if e.Time.After(b.last[e.Pair].Time) {
+		b.last[e.Pair] = e
+	}
+
+	hist := append(b.hist[e.Pair], e)
+	if len(hist) > historySize {
+		hist = hist[len(hist)-historySize:]
+	}
+	b.hist[e.Pair] = hist
+
+	for ch := range b.subs[e.Pair] {
+		deliver(ch, e)
+	}
+	for ch := range b.all {
+		deliver(ch, e)
+	}
+}
+
+func deliver(ch chan PriceUpdated, e PriceUpdated) {
+	select {
+	case ch <- e:
+	default:
+	}
+}
+
+// Subscribe returns a channel receiving events of pair and a function
+// that cancels the subscription
+func (b *Bus) Subscribe(pair string) (<-chan PriceUpdated, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subs[pair] == nil {
+		b.subs[pair] = make(map[chan PriceUpdated]struct{})
+	}
+
+	ch := make(chan PriceUpdated, subscriberBuffer)
+	b.subs[pair][ch] = struct{}{}
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		delete(b.subs[pair], ch)
+		if len(b.subs[pair]) == 0 {
+			delete(b.subs, pair)
+		}
+	}
+}
+
+// SubscribeAll returns a channel buffering up to size events of every pair
+// and a function that cancels the subscription
+func (b *Bus) SubscribeAll(size int) (<-chan PriceUpdated, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan PriceUpdated, size)
+	b.all[ch] = struct{}{}
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.all, ch)
+	}
+}
+
+// Subscribers returns the number of subscribers of pair
+func (b *Bus) Subscribers(pair string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs[pair])
+}
+
+// Latest returns the most recent event published for pair
+func (b *Bus) Latest(pair string) (PriceUpdated, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e, ok := b.last[pair]
+	return e, ok
+}
+
+// History returns up to limit most recent events of pair, newest first
+func (b *Bus) History(pair string, limit int) []PriceUpdated {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	hist := b.hist[pair]
+	n := min(max(limit, 0), len(hist))
+	events := make([]PriceUpdated, 0, n)
+	for i := len(hist) - 1; i >= len(hist)-n; i-- {
+		events = append(events, hist[i])
+	}
+
+	return events
+}
+
+// Stats represents sizes of the bus state
+type Stats struct {
+	Pairs          int `json:"pairs"`
+	Subscribers    int `json:"subscribers"`
+	SubscribersAll int `json:"subscribers_all"`
+	History        int `json:"history"`
+}
+
+// Stats returns sizes of the bus state
+func (b *Bus) Stats() Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	st := Stats{Pairs: len(b.last), SubscribersAll: len(b.all)}
+	for _, subs := range b.subs {
+		st.Subscribers += len(subs)
+	}
+	for _, hist := range b.hist {
+		st.History += len(hist)
+	}
+
+	return st
+}
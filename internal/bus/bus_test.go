@@ -0,0 +1,95 @@
+package bus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBus_Subscribe(t *testing.T) {
+	b := New()
+
+	btc, unsubscribeBTC := b.Subscribe("BTCUSDT")
+	eth, unsubscribeETH := b.Subscribe("ETHUSDT")
+	assert.Equal(t, 1, b.Subscribers("BTCUSDT"))
+
+	b.Publish(PriceUpdated{Pair: "BTCUSDT", Price: 1})
+	assert.Equal(t, 1.0, (<-btc).Price)
+	assert.Empty(t, eth)
+
+	// Full subscriber buffers drop events without blocking
+	for range 20 {
+		b.Publish(PriceUpdated{Pair: "ETHUSDT", Price: 2})
+	}
+	assert.Len(t, eth, subscriberBuffer)
+
+	unsubscribeBTC()
+	unsubscribeETH()
+	assert.Equal(t, 0, b.Subscribers("BTCUSDT"))
+	assert.Empty(t, b.subs)
+}
+
+func TestBus_SubscribeAll(t *testing.T) {
+	b := New()
+
+	all, unsubscribe := b.SubscribeAll(2)
+	b.Publish(PriceUpdated{Pair: "BTCUSDT", Price: 1})
+	b.Publish(PriceUpdated{Pair: "ETHUSDT", Price: 2})
+	b.Publish(PriceUpdated{Pair: "SOLUSDT", Price: 3})
+
+	assert.Equal(t, "BTCUSDT", (<-all).Pair)
+	assert.Equal(t, "ETHUSDT", (<-all).Pair)
+	assert.Empty(t, all)
+
+	unsubscribe()
+	b.Publish(PriceUpdated{Pair: "BTCUSDT", Price: 1})
+	assert.Empty(t, all)
+}
+
+func TestBus_Latest(t *testing.T) {
+	b := New()
+	t0 := time.Now()
+
+	_, ok := b.Latest("BTCUSDT")
+	assert.False(t, ok)
+
+	b.Publish(PriceUpdated{Pair: "BTCUSDT", Price: 2, Time: t0.Add(time.Second)})
+	b.Publish(PriceUpdated{Pair: "BTCUSDT", Price: 1, Time: t0})
+
+	e, ok := b.Latest("BTCUSDT")
+	assert.True(t, ok)
+	assert.Equal(t, 2.0, e.Price, "late events do not replace newer ones")
+}
+
+func TestBus_History(t *testing.T) {
+	b := New()
+	assert.Empty(t, b.History("BTCUSDT", 10))
+
+	for i := range historySize + 10 {
+		b.Publish(PriceUpdated{Pair: "BTCUSDT", Price: float64(i)})
+	}
+
+	h := b.History("BTCUSDT", 3)
+	assert.Len(t, h, 3)
+	assert.Equal(t, float64(historySize+9), h[0].Price)
+	assert.Equal(t, float64(historySize+7), h[2].Price)
+
+	assert.Len(t, b.History("BTCUSDT", 1000), historySize)
+	assert.Empty(t, b.History("BTCUSDT", -1))
+}
+
+func TestBus_Stats(t *testing.T) {
+	b := New()
+	assert.Equal(t, Stats{}, b.Stats())
+
+	_, unsubscribe := b.Subscribe("BTCUSDT")
+	_, _ = b.Subscribe("ETHUSDT")
+	_, _ = b.SubscribeAll(1)
+	b.Publish(PriceUpdated{Pair: "BTCUSDT", Price: 1, Time: time.Now()})
+	b.Publish(PriceUpdated{Pair: "BTCUSDT", Price: 2, Time: time.Now()})
+	b.Publish(PriceUpdated{Pair: "ETHUSDT", Price: 3, Time: time.Now()})
+	unsubscribe()
+
+	assert.Equal(t, Stats{Pairs: 2, Subscribers: 1, SubscribersAll: 1, History: 3}, b.Stats())
+}
@@ -0,0 +1,410 @@
+// Package config provides application configuration loading.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/alert"
+	"github.com/ivanglie/coinmon/internal/index"
+)
+
+// Config represents application configuration
+type Config struct {
+	Addr       string             `json:"addr"`
+	GRPCAddr   string             `json:"grpc_addr"`
+	HTTP3Addr  string             `json:"http3_addr"`
+	ReusePort  bool               `json:"reuse_port"`
+	H2C        bool               `json:"h2c"`
+	DrainDelay Duration           `json:"drain_delay"`
+	TLS        TLS                `json:"tls"`
+	ACME       ACME               `json:"acme"`
+	APIKeys    APIKeys            `json:"api_keys"`
+	JWT        JWT                `json:"jwt"`
+	BasicAuth  BasicAuth          `json:"basic_auth"`
+	Accounts   map[string]Account `json:"accounts"`
+	Watchlist  Watchlist          `json:"watchlist"`
+	Exchanges  []string           `json:"exchanges"`
+	Pairs      []string           `json:"pairs"`
+	Jobs       map[string]Job     `json:"jobs"`
+	Feed       Feed               `json:"feed"`
+	Upstream   Upstream           `json:"upstream"`
+	Alerts     Alerts             `json:"alerts"`
+	MQTT       MQTT               `json:"mqtt"`
+	Kafka      Kafka              `json:"kafka"`
+	NATS       NATS               `json:"nats"`
+	Redis      Redis              `json:"redis"`
+	Exporter   Exporter           `json:"exporter"`
+	OTLP       OTLP               `json:"otlp"`
+	Debug      Debug              `json:"debug"`
+	Dashboard  Dashboard          `json:"dashboard"`
+	// SecurityTxtExpiry is how long /.well-known/security.txt is valid from
+	// the time it is served
+	SecurityTxtExpiry Duration `json:"security_txt_expiry"`
+	// Deprecations announce API versions being retired by version, e.g. "v1"
+	Deprecations map[string]Deprecation `json:"deprecations"`
+	Limits       Limits                 `json:"limits"`
+	// Indices are composite indices served at /api/v1/index/{name} by name,
+	// e.g. "BTC-INDEX"
+	Indices Indices `json:"indices"`
+	// CORSOrigins are the origins browsers may call the API from, "*" for any
+	CORSOrigins []string `json:"cors_origins"`
+	// AllowPairs are the patterns of the pairs served, e.g. "BTC*", any pair
+	// when empty; DenyPairs are the patterns of pairs never served
+	AllowPairs []string `json:"allow_pairs"`
+	DenyPairs  []string `json:"deny_pairs"`
+	Usage      Usage    `json:"usage"`
+	Uniswap    Uniswap  `json:"uniswap"`
+}
+
+// TLS represents HTTPS settings
+type TLS struct {
+	Cert     string `json:"cert"`
+	Key      string `json:"key"`
+	ClientCA string `json:"client_ca"`
+}
+
+// APIKeys represents consumers of the API identified by keys
+type APIKeys struct {
+	Required bool     `json:"required"`
+	Keys     []APIKey `json:"keys"`
+}
+
+// APIKey represents a consumer of the API with its rate limit
+type APIKey struct {
+	Name  string  `json:"name"`
+	Key   string  `json:"key"`
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// JWT represents settings of bearer tokens issued by an identity provider
+type JWT struct {
+	Secret   string `json:"secret"`
+	JWKSURL  string `json:"jwks_url"`
+	Issuer   string `json:"issuer"`
+	Audience string `json:"audience"`
+	Required bool   `json:"required"`
+}
+
+// Watchlist represents persistence of the watchlists of API key consumers
+type Watchlist struct {
+	StateFile string `json:"state_file"`
+}
+
+// Usage represents accounting of the requests of API consumers
+type Usage struct {
+	Enabled bool `json:"enabled"`
+	// StateFile persists usage across restarts, saved by the usage job
+	StateFile    string `json:"state_file"`
+	MaxConsumers int    `json:"max_consumers"`
+}
+
+// Uniswap represents the Uniswap v3 pools prices of pairs are fetched from,
+// its subgraph being reached with the base_urls and headers of upstream
+type Uniswap struct {
+	// Pools are the ids of pools by pair, e.g. {"PEPEETH": "0x1195..."}
+	Pools map[string]string `json:"pools"`
+}
+
+// Account represents API credentials of an exchange account
+type Account struct {
+	Key        string `json:"key"`
+	Secret     string `json:"secret"`
+	Passphrase string `json:"passphrase"`
+}
+
+// BasicAuth represents credentials required on the index page and admin routes
+type BasicAuth struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
+}
+
+// ACME represents settings of certificates obtained from Let's Encrypt
+type ACME struct {
+	Domains  []string `json:"domains"`
+	Email    string   `json:"email"`
+	CacheDir string   `json:"cache_dir"`
+	HTTPAddr string   `json:"http_addr"`
+}
+
+// Deprecation represents the deprecation policy of an API version sent to
+// its clients in response headers
+type Deprecation struct {
+	Date   time.Time `json:"date"`
+	Sunset time.Time `json:"sunset"`
+	Link   string    `json:"link"`
+}
+
+// Dashboard represents the branding of the index page. Theme is light,
+// dark or auto, following the preference of the browser.
+type Dashboard struct {
+	Title string   `json:"title"`
+	Logo  string   `json:"logo"`
+	Pairs []string `json:"pairs"`
+	Theme string   `json:"theme"`
+}
+
+// Limits represents bounds of the resources a client may take of the
+// server. Zero fields fall back to defaults or lift the limit.
+type Limits struct {
+	MaxHeaderBytes    int      `json:"max_header_bytes"`
+	ReadHeaderTimeout Duration `json:"read_header_timeout"`
+	MaxConns          int      `json:"max_conns"`
+	MaxConnsPerIP     int      `json:"max_conns_per_ip"`
+	MaxStreamsPerConn int      `json:"max_streams_per_conn"`
+	MaxSubscribers    int      `json:"max_subscribers"`
+}
+
+// Debug represents settings of the profiling and diagnostic endpoints
+type Debug struct {
+	Enabled bool   `json:"enabled"`
+	Token   string `json:"token"`
+}
+
+// OTLP represents OTLP metrics push settings
+type OTLP struct {
+	Endpoint string            `json:"endpoint"`
+	Headers  map[string]string `json:"headers"`
+	Interval Duration          `json:"interval"`
+}
+
+// Exporter represents Prometheus exporter settings
+type Exporter struct {
+	Enabled bool `json:"enabled"`
+}
+
+// Redis represents Redis publisher settings
+type Redis struct {
+	URL     string `json:"url"`
+	Channel string `json:"channel"`
+}
+
+// NATS represents NATS publisher settings
+type NATS struct {
+	URL     string `json:"url"`
+	Subject string `json:"subject"`
+}
+
+// Kafka represents Kafka producer settings
+type Kafka struct {
+	Brokers []string `json:"brokers"`
+	Topic   string   `json:"topic"`
+}
+
+// MQTT represents MQTT publisher settings
+type MQTT struct {
+	Broker   string `json:"broker"`
+	ClientID string `json:"client_id"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Topic    string `json:"topic"`
+	QoS      byte   `json:"qos"`
+	Retained bool   `json:"retained"`
+}
+
+// Feed represents exchange WebSocket stream settings
+type Feed struct {
+	Enabled bool     `json:"enabled"`
+	MaxAge  Duration `json:"max_age"`
+}
+
+// Upstream represents limits and connection settings of exchange calls.
+// Zero MaxConcurrent lifts the limit. MaxRequestTimeout caps the ?timeout
+// clients bound the resolution of a price by, which Retries of failing
+// exchanges and Hedge calls of exchanges of lower priority share.
+// MaxStaleAge bounds the age of prices returned as stale past the timeout.
+type Upstream struct {
+	Timeout             Duration `json:"timeout"`
+	MaxRequestTimeout   Duration `json:"max_request_timeout"`
+	MaxStaleAge         Duration `json:"max_stale_age"`
+	Retries             int      `json:"retries"`
+	Hedge               Duration `json:"hedge"`
+	MaxConcurrent       int      `json:"max_concurrent"`
+	QueueTimeout        Duration `json:"queue_timeout"`
+	MaxIdleConnsPerHost int      `json:"max_idle_conns_per_host"`
+	IdleConnTimeout     Duration `json:"idle_conn_timeout"`
+	TLSHandshakeTimeout Duration `json:"tls_handshake_timeout"`
+	ForceAttemptHTTP2   bool     `json:"force_attempt_http2"`
+	// DNSCacheTTL is how long addresses of exchange hosts are cached, not at
+	// all when zero
+	DNSCacheTTL Duration `json:"dns_cache_ttl"`
+	// Prewarm opens connections to exchanges at startup and keeps them open
+	// with the prewarm job
+	Prewarm bool `json:"prewarm"`
+	// MaintenanceBench is how long an exchange down for maintenance is not
+	// called, 5 minutes when zero
+	MaintenanceBench Duration `json:"maintenance_bench"`
+	// Proxy is a http, https or socks5 proxy URL, Proxies override it by
+	// exchange name with a proxy URL or "direct"
+	Proxy   string            `json:"proxy"`
+	Proxies map[string]string `json:"proxies"`
+	TLS     UpstreamTLS       `json:"tls"`
+	// Headers are sent with calls to exchanges by exchange name
+	Headers map[string]map[string]string `json:"headers"`
+	// BaseURLs replace the public APIs of exchanges by exchange name, e.g.
+	// with a fake exchange in development
+	BaseURLs map[string]string `json:"base_urls"`
+	// Mirrors replace the public mirrors of exchanges by exchange name, tried
+	// in order when the base URL fails. An empty list disables failover.
+	Mirrors map[string][]string `json:"mirrors"`
+	Chaos   Chaos               `json:"chaos"`
+}
+
+// Chaos represents faults injected into calls to exchanges for resilience
+// testing. Rates are probabilities from 0 to 1.
+type Chaos struct {
+	Enabled       bool     `json:"enabled"`
+	Latency       Duration `json:"latency"`
+	LatencyRate   float64  `json:"latency_rate"`
+	ErrorRate     float64  `json:"error_rate"`
+	MalformedRate float64  `json:"malformed_rate"`
+}
+
+// UpstreamTLS represents TLS settings of exchange calls
+type UpstreamTLS struct {
+	CAFile     string `json:"ca_file"`
+	Cert       string `json:"cert"`
+	Key        string `json:"key"`
+	MinVersion string `json:"min_version"`
+}
+
+// JobNames are the background jobs of the service, each scheduled by its
+// entry of jobs
+var JobNames = []string{"poll", "alerts", "indices", "compact", "usage", "prewarm", "symbols"}
+
+// Job represents background job schedule
+type Job struct {
+	Interval Duration `json:"interval"`
+	Jitter   Duration `json:"jitter"`
+}
+
+// Alerts represents alerting configuration
+type Alerts struct {
+	PagerDuty PagerDuty `json:"pagerduty"`
+	Rules     []Rule    `json:"rules"`
+	StateFile string    `json:"state_file"`
+}
+
+// Rule represents an alert rule definition
+type Rule struct {
+	ID         string   `json:"id"`
+	Pair       string   `json:"pair"`
+	Above      float64  `json:"above,omitempty"`
+	Below      float64  `json:"below,omitempty"`
+	Change     float64  `json:"change,omitempty"`
+	Spread     float64  `json:"spread,omitempty"`
+	Window     Duration `json:"window,omitempty"`
+	Cooldown   Duration `json:"cooldown,omitempty"`
+	Hysteresis float64  `json:"hysteresis,omitempty"`
+}
+
+// PagerDuty represents PagerDuty integration settings
+type PagerDuty struct {
+	RoutingKey string `json:"routing_key"`
+}
+
+// AlertRules returns configured rules converted to alert rules
+func (a *Alerts) AlertRules() []alert.Rule {
+	rules := make([]alert.Rule, 0, len(a.Rules))
+	for _, r := range a.Rules {
+		rules = append(rules, alert.Rule{
+			ID:         r.ID,
+			Pair:       r.Pair,
+			Above:      r.Above,
+			Below:      r.Below,
+			Change:     r.Change,
+			Spread:     r.Spread,
+			Window:     time.Duration(r.Window),
+			Cooldown:   time.Duration(r.Cooldown),
+			Hysteresis: r.Hysteresis,
+		})
+	}
+
+	return rules
+}
+
+// IndexComponent represents the price of a pair on an exchange weighted in
+// a composite index
+type IndexComponent struct {
+	Exchange string  `json:"exchange"`
+	Pair     string  `json:"pair"`
+	Weight   float64 `json:"weight"`
+}
+
+// Indices represents the components of composite indices by name
+type Indices map[string][]IndexComponent
+
+// List returns the configured indices converted to indices, sorted by name
+func (ix Indices) List() []index.Index {
+	list := make([]index.Index, 0, len(ix))
+	for _, name := range slices.Sorted(maps.Keys(ix)) {
+		idx := index.Index{Name: name}
+		for _, c := range ix[name] {
+			idx.Components = append(idx.Components, index.Component{Exchange: c.Exchange, Pair: c.Pair, Weight: c.Weight})
+		}
+		list = append(list, idx)
+	}
+
+	return list
+}
+
+// Default returns configuration with default values
+func Default() *Config {
+	return &Config{
+		Addr:              ":8080",
+		DrainDelay:        Duration(5 * time.Second),
+		SecurityTxtExpiry: Duration(365 * 24 * time.Hour),
+		ACME:              ACME{HTTPAddr: ":80"},
+		MQTT:              MQTT{ClientID: "coinmon"},
+		Feed:              Feed{MaxAge: Duration(10 * time.Second)},
+		Upstream: Upstream{
+			Timeout:             Duration(5 * time.Second),
+			MaxRequestTimeout:   Duration(5 * time.Second),
+			MaxStaleAge:         Duration(time.Minute),
+			MaxConcurrent:       64,
+			QueueTimeout:        Duration(2 * time.Second),
+			MaxIdleConnsPerHost: 16,
+			IdleConnTimeout:     Duration(90 * time.Second),
+			TLSHandshakeTimeout: Duration(5 * time.Second),
+			ForceAttemptHTTP2:   true,
+			DNSCacheTTL:         Duration(time.Minute),
+			Prewarm:             true,
+		},
+		Jobs: map[string]Job{
+			"poll":    {Interval: Duration(30 * time.Second), Jitter: Duration(5 * time.Second)},
+			"alerts":  {Interval: Duration(15 * time.Second), Jitter: Duration(2 * time.Second)},
+			"indices": {Interval: Duration(10 * time.Second), Jitter: Duration(time.Second)},
+			"compact": {Interval: Duration(5 * time.Minute), Jitter: Duration(30 * time.Second)},
+			"usage":   {Interval: Duration(time.Minute), Jitter: Duration(5 * time.Second)},
+			"prewarm": {Interval: Duration(30 * time.Second), Jitter: Duration(5 * time.Second)},
+			"symbols": {Interval: Duration(time.Hour), Jitter: Duration(time.Minute)},
+		},
+	}
+}
+
+// Load reads configuration from a JSON file at path, overridden by
+// COINMON_ environment variables. Empty path starts from default configuration.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	if path != "" {
+		b, err := os.ReadFile(path) //nolint:gosec // path is provided by the operator
+		if err != nil {
+			return nil, fmt.Errorf("read config: %w", err)
+		}
+
+		if err := json.Unmarshal(b, cfg); err != nil {
+			return nil, fmt.Errorf("parse config: %w", err)
+		}
+	}
+
+	if err := applyEnv(cfg, os.LookupEnv); err != nil {
+		return nil, fmt.Errorf("parse environment: %w", err)
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,296 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/index"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name         string
+		content      string
+		expectedAddr string
+		expectError  bool
+	}{
+		{
+			name:         "valid config",
+			content:      `{"addr":":9090","alerts":{"pagerduty":{"routing_key":"key"},"rules":[{"id":"r1","pair":"BTCUSDT","below":1}]}}`,
+			expectedAddr: ":9090",
+		},
+		{
+			name:         "defaults are kept",
+			content:      `{}`,
+			expectedAddr: ":8080",
+		},
+		{
+			name:        "invalid json",
+			content:     `{`,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.json")
+			assert.NoError(t, os.WriteFile(path, []byte(tt.content), 0o600))
+
+			cfg, err := Load(path)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedAddr, cfg.Addr)
+		})
+	}
+}
+
+func TestLoad_Jobs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"pairs":["BTCUSDT"],"jobs":{"poll":{"interval":"10s"}}}`), 0o600))
+
+	cfg, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"BTCUSDT"}, cfg.Pairs)
+	assert.Equal(t, Job{Interval: Duration(10 * time.Second)}, cfg.Jobs["poll"])
+	assert.Equal(t, Default().Jobs["compact"], cfg.Jobs["compact"])
+}
+
+func TestLoad_DrainDelay(t *testing.T) {
+	cfg, err := Load("")
+	assert.NoError(t, err)
+	assert.Equal(t, Duration(5*time.Second), cfg.DrainDelay)
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"drain_delay":"0s"}`), 0o600))
+
+	cfg, err = Load(path)
+	assert.NoError(t, err)
+	assert.Zero(t, cfg.DrainDelay)
+}
+
+func TestLoad_Upstream(t *testing.T) {
+	cfg, err := Load("")
+	assert.NoError(t, err)
+	assert.Equal(t, 64, cfg.Upstream.MaxConcurrent)
+	assert.Equal(t, Duration(5*time.Second), cfg.Upstream.Timeout)
+	assert.Equal(t, Duration(2*time.Second), cfg.Upstream.QueueTimeout)
+	assert.Equal(t, 16, cfg.Upstream.MaxIdleConnsPerHost)
+	assert.True(t, cfg.Upstream.ForceAttemptHTTP2)
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"upstream":{"max_concurrent":8,"idle_conn_timeout":"30s","force_attempt_http2":false}}`), 0o600))
+
+	cfg, err = Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 8, cfg.Upstream.MaxConcurrent)
+	assert.Equal(t, Duration(2*time.Second), cfg.Upstream.QueueTimeout)
+	assert.Equal(t, Duration(30*time.Second), cfg.Upstream.IdleConnTimeout)
+	assert.False(t, cfg.Upstream.ForceAttemptHTTP2)
+}
+
+func TestLoad_Exchanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"exchanges":["binance","kraken"]}`), 0o600))
+
+	cfg, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"binance", "kraken"}, cfg.Exchanges)
+}
+
+func TestLoad_ReusePort(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"reuse_port":true}`), 0o600))
+
+	cfg, err := Load(path)
+	assert.NoError(t, err)
+	assert.True(t, cfg.ReusePort)
+}
+
+func TestLoad_H2C(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"h2c":true}`), 0o600))
+
+	cfg, err := Load(path)
+	assert.NoError(t, err)
+	assert.True(t, cfg.H2C)
+}
+
+func TestLoad_HTTP3(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"http3_addr":":443"}`), 0o600))
+
+	cfg, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, ":443", cfg.HTTP3Addr)
+}
+
+func TestLoad_TLS(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"tls":{"cert":"/etc/coinmon/cert.pem","key":"/etc/coinmon/key.pem","client_ca":"/etc/coinmon/ca.pem"}}`), 0o600))
+
+	cfg, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, TLS{Cert: "/etc/coinmon/cert.pem", Key: "/etc/coinmon/key.pem", ClientCA: "/etc/coinmon/ca.pem"}, cfg.TLS)
+}
+
+func TestLoad_ACME(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"acme":{"domains":["price.example.com"],"email":"ops@example.com"}}`), 0o600))
+
+	cfg, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, ACME{Domains: []string{"price.example.com"}, Email: "ops@example.com", HTTPAddr: ":80"}, cfg.ACME)
+}
+
+func TestLoad_APIKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"api_keys":{"required":true,"keys":[{"name":"partner","key":"secret","rate":5,"burst":10}]}}`), 0o600))
+
+	cfg, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, APIKeys{Required: true, Keys: []APIKey{{Name: "partner", Key: "secret", Rate: 5, Burst: 10}}}, cfg.APIKeys)
+}
+
+func TestLoad_MQTT(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"mqtt":{"broker":"tcp://localhost:1883","qos":1,"retained":true}}`), 0o600))
+
+	cfg, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, MQTT{Broker: "tcp://localhost:1883", ClientID: "coinmon", QoS: 1, Retained: true}, cfg.MQTT)
+}
+
+func TestLoad_Kafka(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"kafka":{"brokers":["kafka-1:9092","kafka-2:9092"],"topic":"prices"}}`), 0o600))
+
+	cfg, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, Kafka{Brokers: []string{"kafka-1:9092", "kafka-2:9092"}, Topic: "prices"}, cfg.Kafka)
+}
+
+func TestLoad_NATS(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"nats":{"url":"nats://localhost:4222"}}`), 0o600))
+
+	cfg, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, NATS{URL: "nats://localhost:4222"}, cfg.NATS)
+}
+
+func TestLoad_Redis(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"redis":{"url":"redis://localhost:6379/0","channel":"prices"}}`), 0o600))
+
+	cfg, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, Redis{URL: "redis://localhost:6379/0", Channel: "prices"}, cfg.Redis)
+}
+
+func TestLoad_Exporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"pairs":["BTCUSDT"],"exporter":{"enabled":true}}`), 0o600))
+
+	cfg, err := Load(path)
+	assert.NoError(t, err)
+	assert.True(t, cfg.Exporter.Enabled)
+}
+
+func TestLoad_OTLP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"otlp":{"endpoint":"https://otlp.example.com/v1/metrics","headers":{"api-key":"secret"},"interval":"30s"}}`), 0o600))
+
+	cfg, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, OTLP{
+		Endpoint: "https://otlp.example.com/v1/metrics",
+		Headers:  map[string]string{"api-key": "secret"},
+		Interval: Duration(30 * time.Second),
+	}, cfg.OTLP)
+}
+
+func TestLoad_Debug(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"debug":{"enabled":true,"token":"secret"}}`), 0o600))
+
+	cfg, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, Debug{Enabled: true, Token: "secret"}, cfg.Debug)
+}
+
+func TestLoad_Dashboard(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"dashboard":{"title":"Acme Prices","logo":"https://acme.example.com/logo.svg","pairs":["BTCUSDT","ETHUSDT"],"theme":"dark"}}`), 0o600))
+
+	cfg, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, Dashboard{
+		Title: "Acme Prices",
+		Logo:  "https://acme.example.com/logo.svg",
+		Pairs: []string{"BTCUSDT", "ETHUSDT"},
+		Theme: "dark",
+	}, cfg.Dashboard)
+}
+
+func TestLoad_EmptyPath(t *testing.T) {
+	cfg, err := Load("")
+	assert.NoError(t, err)
+	assert.Equal(t, Default(), cfg)
+}
+
+func TestLoad_MissingFile(t *testing.T) {
+	_, err := Load(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestAlerts_AlertRules(t *testing.T) {
+	a := Alerts{Rules: []Rule{
+		{ID: "depeg", Pair: "USDCUSDT", Below: 0.99},
+		{ID: "eth-move", Pair: "ETHUSDT", Change: 5, Window: Duration(15 * time.Minute)},
+	}}
+
+	rules := a.AlertRules()
+	assert.Len(t, rules, 2)
+	assert.Equal(t, 0.99, rules[0].Below)
+	assert.Equal(t, 5.0, rules[1].Change)
+	assert.Equal(t, 15*time.Minute, rules[1].Window)
+}
+
+func TestIndices_List(t *testing.T) {
+	ix := Indices{
+		"ETH-INDEX": {{Exchange: "kraken", Pair: "ETHUSDT", Weight: 1}},
+		"BTC-INDEX": {{Exchange: "binance", Pair: "BTCUSDT", Weight: 0.6}, {Exchange: "bybit", Pair: "BTCUSDT", Weight: 0.4}},
+	}
+
+	list := ix.List()
+	assert.Len(t, list, 2)
+	assert.Equal(t, "BTC-INDEX", list[0].Name)
+	assert.Equal(t, index.Component{Exchange: "bybit", Pair: "BTCUSDT", Weight: 0.4}, list[0].Components[1])
+	assert.Equal(t, "ETH-INDEX", list[1].Name)
+}
+
+func TestLoad_Env(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"addr":":9090","pairs":["BTCUSDT"]}`), 0o600))
+	t.Setenv("COINMON_ADDR", ":7070")
+
+	cfg, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, ":7070", cfg.Addr)
+	assert.Equal(t, []string{"BTCUSDT"}, cfg.Pairs)
+
+	cfg, err = Load("")
+	assert.NoError(t, err)
+	assert.Equal(t, ":7070", cfg.Addr)
+
+	t.Setenv("COINMON_UPSTREAM_QUEUE_TIMEOUT", "soon")
+	_, err = Load(path)
+	assert.Error(t, err)
+}
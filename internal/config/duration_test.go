@@ -0,0 +1,59 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDuration_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    time.Duration
+		expectError bool
+	}{
+		{
+			name:     "minutes",
+			input:    `"15m"`,
+			expected: 15 * time.Minute,
+		},
+		{
+			name:     "composite",
+			input:    `"1h30s"`,
+			expected: time.Hour + 30*time.Second,
+		},
+		{
+			name:        "number is rejected",
+			input:       `900`,
+			expectError: true,
+		},
+		{
+			name:        "invalid string",
+			input:       `"soon"`,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d Duration
+			err := json.Unmarshal([]byte(tt.input), &d)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, time.Duration(d))
+		})
+	}
+}
+
+func TestDuration_MarshalJSON(t *testing.T) {
+	b, err := json.Marshal(Duration(15 * time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, `"15m0s"`, string(b))
+}
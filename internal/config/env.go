@@ -0,0 +1,109 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envPrefix prefixes environment variables overriding configuration
+const envPrefix = "COINMON"
+
+var durationType = reflect.TypeFor[Duration]()
+
+// applyEnv overrides fields of cfg set in the environment. A field is named
+// by the JSON keys of its path, upper-cased and joined by underscores, e.g.
+// COINMON_ADDR or COINMON_UPSTREAM_MAX_CONCURRENT. Lists of strings are
+// comma-separated, maps and lists of objects are JSON.
+func applyEnv(cfg *Config, lookup func(string) (string, bool)) error {
+	return applyEnvStruct(reflect.ValueOf(cfg).Elem(), envPrefix, lookup)
+}
+
+func applyEnvStruct(v reflect.Value, prefix string, lookup func(string) (string, bool)) error {
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+		key, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if key == "" || key == "-" {
+			continue
+		}
+
+		name := prefix + "_" + strings.ToUpper(key)
+		if field.Type.Kind() == reflect.Struct {
+			if err := applyEnvStruct(v.Field(i), name, lookup); err != nil {
+				return err
+			}
+			continue
+		}
+
+		s, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		if err := setEnvValue(v.Field(i), s); err != nil {
+			return fmt.Errorf("parse %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func setEnvValue(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint8:
+		n, err := strconv.ParseUint(s, 10, 8)
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.String {
+			var items []string
+			for item := range strings.SplitSeq(s, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			v.Set(reflect.ValueOf(items))
+			return nil
+		}
+		return json.Unmarshal([]byte(s), v.Addr().Interface())
+	default:
+		// Values replace the ones of the config file rather than merging
+		v.Set(reflect.Zero(v.Type()))
+		return json.Unmarshal([]byte(s), v.Addr().Interface())
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyEnv(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		check       func(t *testing.T, cfg *Config)
+		expectError bool
+	}{
+		{
+			name: "scalars",
+			env: map[string]string{
+				"COINMON_ADDR":                     ":9090",
+				"COINMON_REUSE_PORT":               "true",
+				"COINMON_DRAIN_DELAY":              "1s",
+				"COINMON_UPSTREAM_MAX_CONCURRENT":  "8",
+				"COINMON_UPSTREAM_TLS_MIN_VERSION": "1.3",
+				"COINMON_MQTT_QOS":                 "1",
+			},
+			check: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, ":9090", cfg.Addr)
+				assert.True(t, cfg.ReusePort)
+				assert.Equal(t, Duration(time.Second), cfg.DrainDelay)
+				assert.Equal(t, 8, cfg.Upstream.MaxConcurrent)
+				assert.Equal(t, "1.3", cfg.Upstream.TLS.MinVersion)
+				assert.Equal(t, byte(1), cfg.MQTT.QoS)
+			},
+		},
+		{
+			name: "lists and maps",
+			env: map[string]string{
+				"COINMON_PAIRS":            "BTCUSDT, ETHUSDT,",
+				"COINMON_UPSTREAM_PROXIES": `{"kraken":"direct"}`,
+				"COINMON_ALERTS_RULES":     `[{"id":"r1","pair":"BTCUSDT","below":1}]`,
+			},
+			check: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, []string{"BTCUSDT", "ETHUSDT"}, cfg.Pairs)
+				assert.Equal(t, map[string]string{"kraken": "direct"}, cfg.Upstream.Proxies)
+				assert.Equal(t, []Rule{{ID: "r1", Pair: "BTCUSDT", Below: 1}}, cfg.Alerts.Rules)
+			},
+		},
+		{
+			name: "maps are replaced",
+			env:  map[string]string{"COINMON_JOBS": `{"poll":{"interval":"10s"}}`},
+			check: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, map[string]Job{"poll": {Interval: Duration(10 * time.Second)}}, cfg.Jobs)
+			},
+		},
+		{
+			name: "unset keeps defaults",
+			env:  map[string]string{},
+			check: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, Default(), cfg)
+			},
+		},
+		{
+			name:        "invalid bool",
+			env:         map[string]string{"COINMON_H2C": "maybe"},
+			expectError: true,
+		},
+		{
+			name:        "invalid duration",
+			env:         map[string]string{"COINMON_FEED_MAX_AGE": "10"},
+			expectError: true,
+		},
+		{
+			name:        "invalid json",
+			env:         map[string]string{"COINMON_ACCOUNTS": `{`},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			err := applyEnv(cfg, func(key string) (string, bool) {
+				v, ok := tt.env[key]
+				return v, ok
+			})
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			tt.check(t, cfg)
+		})
+	}
+}
@@ -0,0 +1,314 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/exchange"
+)
+
+// apiVersions are the versions of the REST API
+var apiVersions = []string{"v1", "v2"}
+
+// poolID matches the ids of Uniswap pools, their contract addresses
+var poolID = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+
+// Validate reports every invalid setting of c, naming the setting and the
+// reason of each, so they can be fixed at once before the service starts
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	exchangeName := func(setting, name string) {
+		if _, err := exchange.ParseName(name); err != nil {
+			add("%s: %w", setting, err)
+		}
+	}
+
+	for _, name := range c.Exchanges {
+		exchangeName("exchanges", strings.TrimSpace(name))
+	}
+	for _, name := range slices.Sorted(maps.Keys(c.Upstream.Proxies)) {
+		exchangeName("upstream.proxies", name)
+	}
+	for _, name := range slices.Sorted(maps.Keys(c.Upstream.Headers)) {
+		exchangeName("upstream.headers", name)
+	}
+	for _, name := range slices.Sorted(maps.Keys(c.Upstream.BaseURLs)) {
+		exchangeName("upstream.base_urls", name)
+		if u, err := url.Parse(c.Upstream.BaseURLs[name]); err != nil || u.Scheme == "" || u.Host == "" {
+			add("upstream.base_urls.%s: invalid URL %q", name, c.Upstream.BaseURLs[name])
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(c.Upstream.Mirrors)) {
+		exchangeName("upstream.mirrors", name)
+		for i, mirror := range c.Upstream.Mirrors[name] {
+			if u, err := url.Parse(mirror); err != nil || u.Scheme == "" || u.Host == "" {
+				add("upstream.mirrors.%s[%d]: invalid URL %q", name, i, mirror)
+			}
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(c.Accounts)) {
+		a := c.Accounts[name]
+		exchangeName("accounts", name)
+		if a.Key == "" || a.Secret == "" {
+			add("accounts.%s: key and secret required", name)
+		}
+	}
+	if len(c.Accounts) > 0 && c.BasicAuth.Password == "" {
+		add("accounts: basic_auth password required to serve balances")
+	}
+
+	for i, pair := range c.Pairs {
+		if strings.TrimSpace(pair) == "" {
+			add("pairs[%d]: empty pair", i)
+		}
+	}
+	pairPatterns := func(setting string, patterns []string) {
+		for i, p := range patterns {
+			if _, err := path.Match(p, ""); p == "" || err != nil {
+				add("%s[%d]: invalid pattern %q", setting, i, p)
+			}
+		}
+	}
+	pairPatterns("allow_pairs", c.AllowPairs)
+	pairPatterns("deny_pairs", c.DenyPairs)
+
+	// Dashboard
+	for i, pair := range c.Dashboard.Pairs {
+		if strings.TrimSpace(pair) == "" {
+			add("dashboard.pairs[%d]: empty pair", i)
+		}
+	}
+	switch c.Dashboard.Theme {
+	case "", "auto", "light", "dark":
+	default:
+		add("dashboard.theme: %q is not light, dark or auto", c.Dashboard.Theme)
+	}
+
+	// Listeners
+	listeners := map[string]string{}
+	listen := func(setting, addr string) {
+		if addr == "" {
+			return
+		}
+		if other, ok := listeners[addr]; ok {
+			add("%s: %s is used by %s too", setting, addr, other)
+			return
+		}
+		listeners[addr] = setting
+		if !strings.HasPrefix(addr, "unix://") {
+			if _, _, err := net.SplitHostPort(addr); err != nil {
+				add("%s: %w", setting, err)
+			}
+		}
+	}
+	listen("addr", c.Addr)
+	listen("grpc_addr", c.GRPCAddr)
+	if len(c.ACME.Domains) > 0 {
+		listen("acme.http_addr", c.ACME.HTTPAddr)
+	}
+	if c.HTTP3Addr != "" {
+		// HTTP/3 listens on UDP and may share the port of addr
+		if _, _, err := net.SplitHostPort(c.HTTP3Addr); err != nil {
+			add("http3_addr: %w", err)
+		}
+		if len(c.ACME.Domains) == 0 && c.TLS.Cert == "" {
+			add("http3_addr: tls or acme required")
+		}
+	}
+
+	// TLS
+	if (c.TLS.Cert == "") != (c.TLS.Key == "") {
+		add("tls: cert and key required together")
+	}
+	if c.TLS.Cert != "" && len(c.ACME.Domains) > 0 {
+		add("tls: conflicts with acme, set one of them")
+	}
+	if c.TLS.ClientCA != "" && c.TLS.Cert == "" && len(c.ACME.Domains) == 0 {
+		add("tls.client_ca: tls or acme required")
+	}
+	if (c.Upstream.TLS.Cert == "") != (c.Upstream.TLS.Key == "") {
+		add("upstream.tls: cert and key required together")
+	}
+	switch c.Upstream.TLS.MinVersion {
+	case "", "1.2", "1.3":
+	default:
+		add("upstream.tls.min_version: %q is not 1.2 or 1.3", c.Upstream.TLS.MinVersion)
+	}
+
+	// Durations and limits
+	nonNegative := func(setting string, d Duration) {
+		if d < 0 {
+			add("%s: negative duration %s", setting, time.Duration(d))
+		}
+	}
+	nonNegative("drain_delay", c.DrainDelay)
+	nonNegative("security_txt_expiry", c.SecurityTxtExpiry)
+	nonNegative("feed.max_age", c.Feed.MaxAge)
+	nonNegative("upstream.queue_timeout", c.Upstream.QueueTimeout)
+	nonNegative("upstream.idle_conn_timeout", c.Upstream.IdleConnTimeout)
+	nonNegative("upstream.tls_handshake_timeout", c.Upstream.TLSHandshakeTimeout)
+	nonNegative("upstream.dns_cache_ttl", c.Upstream.DNSCacheTTL)
+	nonNegative("otlp.interval", c.OTLP.Interval)
+	if c.Upstream.Timeout <= 0 {
+		add("upstream.timeout: must be positive")
+	}
+	if c.Upstream.MaxRequestTimeout <= 0 {
+		add("upstream.max_request_timeout: must be positive")
+	}
+	if c.Upstream.MaxStaleAge <= 0 {
+		add("upstream.max_stale_age: must be positive")
+	}
+	if c.Upstream.Retries < 0 {
+		add("upstream.retries: must not be negative")
+	}
+	nonNegative("upstream.hedge", c.Upstream.Hedge)
+	if c.Upstream.MaxConcurrent < 0 {
+		add("upstream.max_concurrent: must not be negative")
+	}
+	nonNegative("upstream.chaos.latency", c.Upstream.Chaos.Latency)
+	nonNegative("upstream.maintenance_bench", c.Upstream.MaintenanceBench)
+	nonNegative("limits.read_header_timeout", c.Limits.ReadHeaderTimeout)
+	nonNegativeInt := func(setting string, n int) {
+		if n < 0 {
+			add("%s: must not be negative", setting)
+		}
+	}
+	nonNegativeInt("limits.max_header_bytes", c.Limits.MaxHeaderBytes)
+	nonNegativeInt("limits.max_conns", c.Limits.MaxConns)
+	nonNegativeInt("limits.max_conns_per_ip", c.Limits.MaxConnsPerIP)
+	nonNegativeInt("limits.max_streams_per_conn", c.Limits.MaxStreamsPerConn)
+	nonNegativeInt("limits.max_subscribers", c.Limits.MaxSubscribers)
+	nonNegativeInt("usage.max_consumers", c.Usage.MaxConsumers)
+	probability := func(setting string, p float64) {
+		if p < 0 || p > 1 {
+			add("%s: %g is not between 0 and 1", setting, p)
+		}
+	}
+	probability("upstream.chaos.latency_rate", c.Upstream.Chaos.LatencyRate)
+	probability("upstream.chaos.error_rate", c.Upstream.Chaos.ErrorRate)
+	probability("upstream.chaos.malformed_rate", c.Upstream.Chaos.MalformedRate)
+	for _, name := range JobNames {
+		if _, ok := c.Jobs[name]; !ok {
+			add("jobs.%s: schedule required", name)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(c.Jobs)) {
+		j := c.Jobs[name]
+		if j.Interval <= 0 {
+			add("jobs.%s.interval: must be positive", name)
+		}
+		nonNegative("jobs."+name+".jitter", j.Jitter)
+	}
+	if c.MQTT.QoS > 2 {
+		add("mqtt.qos: %d is not 0, 1 or 2", c.MQTT.QoS)
+	}
+
+	// Consumers
+	names, keys := map[string]bool{}, map[string]bool{}
+	for i, k := range c.APIKeys.Keys {
+		switch {
+		case k.Name == "" || k.Key == "":
+			add("api_keys.keys[%d]: name and key required", i)
+		case names[k.Name]:
+			add("api_keys.keys[%d]: duplicate name %q", i, k.Name)
+		case keys[k.Key]:
+			add("api_keys.keys[%d]: duplicate key of %q", i, k.Name)
+		}
+		names[k.Name], keys[k.Key] = true, true
+		if k.Rate < 0 || k.Burst < 0 {
+			add("api_keys.keys[%d]: negative rate or burst", i)
+		}
+	}
+	if c.JWT.Secret != "" && c.JWT.JWKSURL != "" {
+		add("jwt: secret conflicts with jwks_url, set one of them")
+	}
+	if c.JWT.Secret == "" && c.JWT.JWKSURL == "" && (c.JWT.Issuer != "" || c.JWT.Audience != "" || c.JWT.Required) {
+		add("jwt: secret or jwks_url required")
+	}
+	if (c.BasicAuth.User == "") != (c.BasicAuth.Password == "") {
+		add("basic_auth: user and password required together")
+	}
+
+	// API versions
+	for _, v := range slices.Sorted(maps.Keys(c.Deprecations)) {
+		d := c.Deprecations[v]
+		if !slices.Contains(apiVersions, v) {
+			add("deprecations: unknown API version %q", v)
+		}
+		if d.Date.IsZero() && d.Sunset.IsZero() {
+			add("deprecations.%s: date or sunset required", v)
+		}
+		if !d.Date.IsZero() && !d.Sunset.IsZero() && d.Sunset.Before(d.Date) {
+			add("deprecations.%s: sunset before date", v)
+		}
+		if d.Link != "" {
+			if u, err := url.Parse(d.Link); err != nil || !u.IsAbs() {
+				add("deprecations.%s.link: %q is not an absolute URL", v, d.Link)
+			}
+		}
+	}
+
+	// Alerts
+	ids := map[string]bool{}
+	for i, r := range c.Alerts.Rules {
+		switch {
+		case r.ID == "":
+			add("alerts.rules[%d]: id required", i)
+		case ids[r.ID]:
+			add("alerts.rules[%d]: duplicate id %q", i, r.ID)
+		}
+		ids[r.ID] = true
+		if r.Pair == "" {
+			add("alerts.rules[%d]: pair required", i)
+		}
+		if r.Above == 0 && r.Below == 0 && r.Change == 0 && r.Spread == 0 {
+			add("alerts.rules[%d]: one of above, below, change or spread required", i)
+		}
+		if r.Change != 0 && r.Window <= 0 {
+			add("alerts.rules[%d]: change requires a window", i)
+		}
+		nonNegative(fmt.Sprintf("alerts.rules[%d].cooldown", i), r.Cooldown)
+	}
+
+	// Indices
+	for _, name := range slices.Sorted(maps.Keys(c.Indices)) {
+		if strings.TrimSpace(name) == "" || strings.ToUpper(name) != name {
+			add("indices: name %q is not upper case", name)
+		}
+		if len(c.Indices[name]) == 0 {
+			add("indices.%s: components required", name)
+		}
+		for i, comp := range c.Indices[name] {
+			exchangeName(fmt.Sprintf("indices.%s[%d].exchange", name, i), comp.Exchange)
+			if strings.TrimSpace(comp.Pair) == "" {
+				add("indices.%s[%d]: empty pair", name, i)
+			}
+			if comp.Weight <= 0 {
+				add("indices.%s[%d].weight: must be positive", name, i)
+			}
+		}
+	}
+
+	// Uniswap
+	for _, pair := range slices.Sorted(maps.Keys(c.Uniswap.Pools)) {
+		if strings.TrimSpace(pair) == "" {
+			add("uniswap.pools: empty pair")
+		}
+		if !poolID.MatchString(c.Uniswap.Pools[pair]) {
+			add("uniswap.pools.%s: invalid pool id %q", pair, c.Uniswap.Pools[pair])
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,244 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name           string
+		modify         func(c *Config)
+		expectedErrors []string
+	}{
+		{
+			name:   "defaults",
+			modify: func(*Config) {},
+		},
+		{
+			name: "valid settings",
+			modify: func(c *Config) {
+				c.Exchanges = []string{"binance", " kraken"}
+				c.GRPCAddr = ":9090"
+				c.HTTP3Addr = ":8080"
+				c.TLS = TLS{Cert: "cert.pem", Key: "key.pem"}
+				c.Accounts = map[string]Account{"binance": {Key: "k", Secret: "s"}}
+				c.BasicAuth = BasicAuth{User: "admin", Password: "p"}
+				c.Alerts.Rules = []Rule{{ID: "r", Pair: "BTCUSDT", Change: 5, Window: Duration(time.Minute)}}
+				c.Deprecations = map[string]Deprecation{"v1": {Sunset: time.Now(), Link: "https://coinmon.cc/docs/v2"}}
+				c.Dashboard = Dashboard{Title: "Acme Prices", Pairs: []string{"BTCUSDT"}, Theme: "dark"}
+				c.Upstream.BaseURLs = map[string]string{"binance": "http://localhost:9090"}
+				c.Upstream.Mirrors = map[string][]string{"binance": {"https://api1.binance.com"}, "bybit": nil}
+				c.Indices = Indices{"BTC-INDEX": {{Exchange: "binance", Pair: "BTCUSDT", Weight: 0.6}, {Exchange: "bybit", Pair: "BTCUSDT", Weight: 0.4}}}
+				c.Uniswap.Pools = map[string]string{"PEPEETH": "0x11950d141EcB863F01007AdD7D1A342041227b58"}
+			},
+		},
+		{
+			name: "uniswap",
+			modify: func(c *Config) {
+				c.Uniswap.Pools = map[string]string{" ": "0x11950d141ecb863f01007add7d1a342041227b58", "PEPEETH": "0x1195"}
+			},
+			expectedErrors: []string{
+				"uniswap.pools: empty pair",
+				`uniswap.pools.PEPEETH: invalid pool id "0x1195"`,
+			},
+		},
+		{
+			name: "indices",
+			modify: func(c *Config) {
+				c.Indices = Indices{
+					"btc": {{Exchange: "binanse", Pair: " ", Weight: 0}},
+					"ETH": nil,
+				}
+			},
+			expectedErrors: []string{
+				"indices.ETH: components required",
+				`indices: name "btc" is not upper case`,
+				`indices.btc[0].exchange: unknown exchange "binanse"`,
+				"indices.btc[0]: empty pair",
+				"indices.btc[0].weight: must be positive",
+			},
+		},
+		{
+			name: "unknown exchanges",
+			modify: func(c *Config) {
+				c.Exchanges = []string{"binanse"}
+				c.Upstream.Proxies = map[string]string{"krakn": "direct"}
+				c.Upstream.BaseURLs = map[string]string{"bybit": "localhost:9090"}
+				c.Upstream.Mirrors = map[string][]string{"bybit": {"https://api.bytick.com", "api.bybit.com"}, "okx": nil}
+			},
+			expectedErrors: []string{
+				`exchanges: unknown exchange "binanse"`,
+				`upstream.proxies: unknown exchange "krakn"`,
+				`upstream.base_urls.bybit: invalid URL "localhost:9090"`,
+				`upstream.mirrors.bybit[1]: invalid URL "api.bybit.com"`,
+				`upstream.mirrors: unknown exchange "okx"`,
+			},
+		},
+		{
+			name: "dashboard",
+			modify: func(c *Config) {
+				c.Dashboard = Dashboard{Pairs: []string{"BTCUSDT", " "}, Theme: "solarized"}
+			},
+			expectedErrors: []string{
+				"dashboard.pairs[1]: empty pair",
+				`dashboard.theme: "solarized" is not light, dark or auto`,
+			},
+		},
+		{
+			name: "pair patterns",
+			modify: func(c *Config) {
+				c.AllowPairs = []string{"BTC*", "ETH[USDT"}
+				c.DenyPairs = []string{""}
+			},
+			expectedErrors: []string{
+				`allow_pairs[1]: invalid pattern "ETH[USDT"`,
+				`deny_pairs[0]: invalid pattern ""`,
+			},
+		},
+		{
+			name: "conflicting listeners",
+			modify: func(c *Config) {
+				c.GRPCAddr = ":8080"
+				c.ACME = ACME{Domains: []string{"example.com"}, HTTPAddr: "80"}
+				c.TLS = TLS{Cert: "cert.pem"}
+			},
+			expectedErrors: []string{
+				"grpc_addr: :8080 is used by addr too",
+				"acme.http_addr: address 80: missing port in address",
+				"tls: cert and key required together",
+				"tls: conflicts with acme, set one of them",
+			},
+		},
+		{
+			name: "http3 without tls",
+			modify: func(c *Config) {
+				c.HTTP3Addr = ":443"
+			},
+			expectedErrors: []string{"http3_addr: tls or acme required"},
+		},
+		{
+			name: "chaos",
+			modify: func(c *Config) {
+				c.Upstream.Chaos = Chaos{Enabled: true, Latency: Duration(-time.Second), ErrorRate: 1.5, MalformedRate: 0.1}
+			},
+			expectedErrors: []string{
+				"upstream.chaos.latency: negative duration -1s",
+				"upstream.chaos.error_rate: 1.5 is not between 0 and 1",
+			},
+		},
+		{
+			name: "invalid durations",
+			modify: func(c *Config) {
+				c.DrainDelay = Duration(-time.Second)
+				c.SecurityTxtExpiry = Duration(-time.Hour)
+				c.Upstream.Timeout = 0
+				c.Jobs["poll"] = Job{}
+				c.Limits = Limits{ReadHeaderTimeout: Duration(-time.Second), MaxConnsPerIP: -1}
+				c.Usage.MaxConsumers = -1
+				c.Upstream.MaintenanceBench = Duration(-time.Minute)
+			},
+			expectedErrors: []string{
+				"drain_delay: negative duration -1s",
+				"security_txt_expiry: negative duration -1h0m0s",
+				"upstream.timeout: must be positive",
+				"upstream.maintenance_bench: negative duration -1m0s",
+				"limits.read_header_timeout: negative duration -1s",
+				"limits.max_conns_per_ip: must not be negative",
+				"usage.max_consumers: must not be negative",
+				"jobs.poll.interval: must be positive",
+			},
+		},
+		{
+			name: "missing jobs",
+			modify: func(c *Config) {
+				c.Jobs = map[string]Job{"poll": {Interval: Duration(time.Minute)}}
+			},
+			expectedErrors: []string{
+				"jobs.alerts: schedule required",
+				"jobs.indices: schedule required",
+				"jobs.compact: schedule required",
+				"jobs.usage: schedule required",
+				"jobs.prewarm: schedule required",
+				"jobs.symbols: schedule required",
+			},
+		},
+		{
+			name: "accounts without basic auth",
+			modify: func(c *Config) {
+				c.Accounts = map[string]Account{"bybit": {Key: "k"}}
+			},
+			expectedErrors: []string{
+				"accounts.bybit: key and secret required",
+				"accounts: basic_auth password required to serve balances",
+			},
+		},
+		{
+			name: "consumers",
+			modify: func(c *Config) {
+				c.APIKeys.Keys = []APIKey{{Name: "a", Key: "k"}, {Name: "a", Key: "l"}, {Name: "b", Key: "k"}}
+				c.JWT = JWT{Required: true}
+			},
+			expectedErrors: []string{
+				`api_keys.keys[1]: duplicate name "a"`,
+				`api_keys.keys[2]: duplicate key of "b"`,
+				"jwt: secret or jwks_url required",
+			},
+		},
+		{
+			name: "deprecations",
+			modify: func(c *Config) {
+				now := time.Now()
+				c.Deprecations = map[string]Deprecation{
+					"v0": {Date: now},
+					"v1": {Date: now, Sunset: now.Add(-time.Hour), Link: "/docs/v2"},
+					"v2": {},
+				}
+			},
+			expectedErrors: []string{
+				`deprecations: unknown API version "v0"`,
+				"deprecations.v1: sunset before date",
+				`deprecations.v1.link: "/docs/v2" is not an absolute URL`,
+				"deprecations.v2: date or sunset required",
+			},
+		},
+		{
+			name: "alert rules",
+			modify: func(c *Config) {
+				c.Alerts.Rules = []Rule{
+					{ID: "r", Pair: "BTCUSDT", Above: 1},
+					{ID: "r", Pair: "ETHUSDT", Change: 5},
+					{Pair: "BTCUSDT"},
+				}
+			},
+			expectedErrors: []string{
+				`alerts.rules[1]: duplicate id "r"`,
+				"alerts.rules[1]: change requires a window",
+				"alerts.rules[2]: id required",
+				"alerts.rules[2]: one of above, below, change or spread required",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Default()
+			tt.modify(c)
+
+			err := c.Validate()
+			if len(tt.expectedErrors) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+
+			var lines []string
+			if err != nil {
+				lines = strings.Split(err.Error(), "\n")
+			}
+			assert.Equal(t, tt.expectedErrors, lines)
+		})
+	}
+}
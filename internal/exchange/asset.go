@@ -0,0 +1,153 @@
+package exchange
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// assetNames are the full names of common assets by symbol
+var assetNames = map[string]string{
+	"ADA":  "Cardano",
+	"ATOM": "Cosmos",
+	"AVAX": "Avalanche",
+	"BCH":  "Bitcoin Cash",
+	"BNB":  "BNB",
+	"BTC":  "Bitcoin",
+	"DAI":  "Dai",
+	"DOGE": "Dogecoin",
+	"DOT":  "Polkadot",
+	"ETC":  "Ethereum Classic",
+	"ETH":  "Ethereum",
+	"LINK": "Chainlink",
+	"LTC":  "Litecoin",
+	"PEPE": "Pepe",
+	"POL":  "Polygon",
+	"SHIB": "Shiba Inu",
+	"SOL":  "Solana",
+	"TON":  "Toncoin",
+	"TRX":  "TRON",
+	"UNI":  "Uniswap",
+	"USDC": "USD Coin",
+	"USDT": "Tether",
+	"XLM":  "Stellar",
+	"XMR":  "Monero",
+	"XRP":  "XRP",
+}
+
+// AssetName returns the full name of the asset symbol, empty when unknown
+func AssetName(symbol string) string {
+	return assetNames[symbol]
+}
+
+// BinanceExchangeInfoResponse represents Binance exchange information
+// response
+type BinanceExchangeInfoResponse struct {
+	Symbols []struct {
+		Symbol             string `json:"symbol"`
+		BaseAsset          string `json:"baseAsset"`
+		BaseAssetPrecision int    `json:"baseAssetPrecision"`
+	} `json:"symbols"`
+}
+
+// BybitInstrumentsResponse represents Bybit instruments information response
+type BybitInstrumentsResponse struct {
+	RetCode int    `json:"retCode"`
+	RetMsg  string `json:"retMsg"`
+	Result  struct {
+		List []struct {
+			Symbol        string `json:"symbol"`
+			BaseCoin      string `json:"baseCoin"`
+			LotSizeFilter struct {
+				BasePrecision string `json:"basePrecision"`
+			} `json:"lotSizeFilter"`
+		} `json:"list"`
+	} `json:"result"`
+}
+
+// BitgetSymbolsResponse represents Bitget symbol information response
+type BitgetSymbolsResponse struct {
+	Code string `json:"code"`
+	Msg  string `json:"msg"`
+	Data []struct {
+		Symbol            string `json:"symbol"`
+		BaseCoin          string `json:"baseCoin"`
+		QuantityPrecision string `json:"quantityPrecision"`
+	} `json:"data"`
+}
+
+// KrakenAssetsResponse represents Kraken asset information response
+type KrakenAssetsResponse struct {
+	Error  []string `json:"error"`
+	Result map[string]struct {
+		Altname  string `json:"altname"`
+		Decimals int    `json:"decimals"`
+	} `json:"result"`
+}
+
+// ParseAssetDecimals returns the decimals an asset is accounted in on n from
+// body, a response of its asset endpoint. Unknown assets are ErrEmpty, and
+// other errors reported in responses *Error.
+func ParseAssetDecimals(n Name, body []byte) (int, error) {
+	switch n {
+	case BINANCE:
+		var r BinanceExchangeInfoResponse
+		if err := json.Unmarshal(body, &r); err != nil {
+			return 0, fmt.Errorf("decode response: %w", err)
+		}
+		if len(r.Symbols) == 0 {
+			return 0, ErrEmpty
+		}
+		return r.Symbols[0].BaseAssetPrecision, nil
+	case BYBIT:
+		var r BybitInstrumentsResponse
+		if err := json.Unmarshal(body, &r); err != nil {
+			return 0, fmt.Errorf("decode response: %w", err)
+		}
+		if r.RetCode != 0 || len(r.Result.List) == 0 {
+			return 0, ErrEmpty
+		}
+		return stepDecimals(r.Result.List[0].LotSizeFilter.BasePrecision), nil
+	case BITGET:
+		var r BitgetSymbolsResponse
+		if err := json.Unmarshal(body, &r); err != nil {
+			return 0, fmt.Errorf("decode response: %w", err)
+		}
+		if len(r.Data) == 0 {
+			return 0, ErrEmpty
+		}
+		decimals, err := strconv.Atoi(r.Data[0].QuantityPrecision)
+		if err != nil {
+			return 0, fmt.Errorf("parse decimals: %w", err)
+		}
+		return decimals, nil
+	case KRAKEN:
+		var r KrakenAssetsResponse
+		if err := json.Unmarshal(body, &r); err != nil {
+			return 0, fmt.Errorf("decode response: %w", err)
+		}
+		if len(r.Error) > 0 {
+			if apiErr := krakenError(r.Error[0]); apiErr.Code != "EQuery" {
+				return 0, apiErr
+			}
+			return 0, ErrEmpty
+		}
+		for _, asset := range r.Result {
+			return asset.Decimals, nil
+		}
+		return 0, ErrEmpty
+	}
+
+	return 0, fmt.Errorf("assets are not supported for %s", n)
+}
+
+// stepDecimals returns the decimals of a step, e.g. 6 for 0.000001
+func stepDecimals(step string) int {
+	_, frac, ok := strings.Cut(step, ".")
+	if !ok {
+		return 0
+	}
+
+	return len(strings.TrimRight(frac, "0"))
+}
@@ -0,0 +1,50 @@
+package exchange
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExchange_AssetURL(t *testing.T) {
+	assert.Equal(t, "https://api.binance.com/api/v3/exchangeInfo?symbol=BTCUSDT", New(BINANCE).AssetURL("BTC"))
+	assert.Equal(t, "https://api.bybit.com/v5/market/instruments-info?category=spot&symbol=BTCUSDT", New(BYBIT).AssetURL("BTC"))
+	assert.Equal(t, "https://api.bitget.com/api/v2/spot/public/symbols?symbol=BTCUSDT", New(BITGET).AssetURL("BTC"))
+	assert.Equal(t, "https://api.kraken.com/0/public/Assets?asset=XBT", New(KRAKEN).AssetURL("BTC"))
+	assert.Empty(t, New(UNISWAP).AssetURL("BTC"))
+}
+
+func TestParseAssetDecimals(t *testing.T) {
+	tests := []struct {
+		name             string
+		exchange         Name
+		body             string
+		expectedDecimals int
+		expectedError    string
+	}{
+		{name: "binance", exchange: BINANCE, body: `{"symbols":[{"symbol":"BTCUSDT","baseAsset":"BTC","baseAssetPrecision":8}]}`, expectedDecimals: 8},
+		{name: "binance unknown", exchange: BINANCE, body: `{"symbols":[]}`, expectedError: "empty response"},
+		{name: "bybit", exchange: BYBIT, body: `{"retCode":0,"result":{"list":[{"symbol":"BTCUSDT","baseCoin":"BTC","lotSizeFilter":{"basePrecision":"0.000001"}}]}}`, expectedDecimals: 6},
+		{name: "bybit whole units", exchange: BYBIT, body: `{"retCode":0,"result":{"list":[{"lotSizeFilter":{"basePrecision":"1"}}]}}`, expectedDecimals: 0},
+		{name: "bybit unknown", exchange: BYBIT, body: `{"retCode":10001,"retMsg":"Not supported symbols","result":{}}`, expectedError: "empty response"},
+		{name: "bitget", exchange: BITGET, body: `{"code":"00000","data":[{"symbol":"BTCUSDT","baseCoin":"BTC","quantityPrecision":"6"}]}`, expectedDecimals: 6},
+		{name: "bitget invalid decimals", exchange: BITGET, body: `{"code":"00000","data":[{"quantityPrecision":"six"}]}`, expectedError: `parse decimals: strconv.Atoi: parsing "six": invalid syntax`},
+		{name: "kraken", exchange: KRAKEN, body: `{"error":[],"result":{"XXBT":{"aclass":"currency","altname":"XBT","decimals":10,"display_decimals":5}}}`, expectedDecimals: 10},
+		{name: "kraken unknown", exchange: KRAKEN, body: `{"error":["EQuery:Unknown asset"]}`, expectedError: "empty response"},
+		{name: "kraken error", exchange: KRAKEN, body: `{"error":["EService:Unavailable"]}`, expectedError: "code=EService, msg=Unavailable"},
+		{name: "malformed", exchange: BINANCE, body: `{`, expectedError: "decode response: unexpected end of JSON input"},
+		{name: "unsupported", exchange: UNISWAP, body: `{}`, expectedError: "assets are not supported for uniswap"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decimals, err := ParseAssetDecimals(tt.exchange, []byte(tt.body))
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedDecimals, decimals)
+		})
+	}
+}
@@ -0,0 +1,520 @@
+package exchange
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"unicode/utf8"
+)
+
+// The responses to price calls are decoded by hand rather than with
+// encoding/json, which dominated CPU when fanning out to the exchanges at high
+// request rates. The decoders only fill the fields of the response types,
+// skipping all others, and match keys case-sensitively. BenchmarkDecode
+// compares them with encoding/json, and FuzzDecode checks they decode the same.
+
+// Decode decodes the JSON data into r
+func (r *BinanceResponse) Decode(data []byte) error {
+	return decode(&decoder{data: data}, r.read)
+}
+
+func (r *BinanceResponse) read(d *decoder) error {
+	return d.object(func(key []byte) (err error) {
+		switch string(key) {
+		case "symbol":
+			r.Symbol, err = d.str()
+		case "price":
+			r.Price, err = d.str()
+		default:
+			err = d.skip()
+		}
+		return err
+	})
+}
+
+// Decode decodes the JSON data into r
+func (r *BinanceErrorResponse) Decode(data []byte) error {
+	return decode(&decoder{data: data}, r.read)
+}
+
+func (r *BinanceErrorResponse) read(d *decoder) error {
+	return d.object(func(key []byte) (err error) {
+		switch string(key) {
+		case "code":
+			r.Code, err = d.int()
+		case "msg":
+			r.Msg, err = d.str()
+		default:
+			err = d.skip()
+		}
+		return err
+	})
+}
+
+// Decode decodes the JSON data into r
+func (r *BybitResponse) Decode(data []byte) error {
+	return decode(&decoder{data: data}, r.read)
+}
+
+func (r *BybitResponse) read(d *decoder) error {
+	return d.object(func(key []byte) (err error) {
+		switch string(key) {
+		case "retCode":
+			r.RetCode, err = d.int()
+		case "retMsg":
+			r.RetMsg, err = d.str()
+		case "result":
+			err = d.object(func(key []byte) (err error) {
+				switch string(key) {
+				case "category":
+					r.Result.Category, err = d.str()
+				case "list":
+					reset(d, &r.Result.List)
+					err = d.array(func() error {
+						item := grow(&r.Result.List)
+						return d.object(func(key []byte) (err error) {
+							switch string(key) {
+							case "symbol":
+								item.Symbol, err = d.str()
+							case "lastPrice":
+								item.LastPrice, err = d.str()
+							default:
+								err = d.skip()
+							}
+							return err
+						})
+					})
+				default:
+					err = d.skip()
+				}
+				return err
+			})
+		default:
+			err = d.skip()
+		}
+		return err
+	})
+}
+
+// Decode decodes the JSON data into r
+func (r *BitgetResponse) Decode(data []byte) error {
+	return decode(&decoder{data: data}, r.read)
+}
+
+func (r *BitgetResponse) read(d *decoder) error {
+	return d.object(func(key []byte) (err error) {
+		switch string(key) {
+		case "code":
+			r.Code, err = d.str()
+		case "msg":
+			r.Msg, err = d.str()
+		case "data":
+			reset(d, &r.Data)
+			err = d.array(func() error {
+				item := grow(&r.Data)
+				return d.object(func(key []byte) (err error) {
+					switch string(key) {
+					case "symbol":
+						item.Symbol, err = d.str()
+					case "lastPr":
+						item.LastPr, err = d.str()
+					default:
+						err = d.skip()
+					}
+					return err
+				})
+			})
+		default:
+			err = d.skip()
+		}
+		return err
+	})
+}
+
+// Decode decodes the JSON data into r
+func (r *KrakenResponse) Decode(data []byte) error {
+	return decode(&decoder{data: data}, r.read)
+}
+
+func (r *KrakenResponse) read(d *decoder) error {
+	return d.object(func(key []byte) (err error) {
+		switch string(key) {
+		case "error":
+			reset(d, &r.Error)
+			err = d.array(func() (err error) {
+				*grow(&r.Error), err = d.str()
+				return err
+			})
+		case "result":
+			if d.peek() == 'n' {
+				r.Result = nil
+			} else if r.Result == nil {
+				r.Result = make(map[string]struct {
+					C [2]string `json:"c"`
+				})
+			}
+			err = d.object(func(key []byte) error {
+				// Like encoding/json, every member replaces the ticker of pair
+				var ticker struct {
+					C [2]string `json:"c"`
+				}
+				err := d.object(func(key []byte) error {
+					if string(key) != "c" {
+						return d.skip()
+					}
+
+					if d.peek() == 'n' {
+						return d.skip()
+					}
+
+					i := 0
+					ticker.C = [2]string{}
+					return d.array(func() (err error) {
+						if i < len(ticker.C) {
+							ticker.C[i], err = d.str()
+						} else {
+							err = d.skip()
+						}
+						i++
+						return err
+					})
+				})
+				r.Result[string(key)] = ticker
+				return err
+			})
+		default:
+			err = d.skip()
+		}
+		return err
+	})
+}
+
+// reset empties *s ahead of reading an array into it, leaving it nil when
+// the array is null
+func reset[S ~[]E, E any](d *decoder, s *S) {
+	switch {
+	case d.peek() == 'n':
+		*s = nil
+	case *s == nil:
+		*s = S{}
+	default:
+		*s = (*s)[:0]
+	}
+}
+
+// grow appends the zero value to *s, returning a pointer to it
+func grow[S ~[]E, E any](s *S) *E {
+	var zero E
+	*s = append(*s, zero)
+	return &(*s)[len(*s)-1]
+}
+
+// decoder reads JSON values from data, following the grammar of RFC 8259.
+// Like encoding/json, it treats null as the zero value of any type. With a
+// reader r, data is read from it as the values need it.
+type decoder struct {
+	data []byte
+	pos  int
+	r    io.Reader
+	err  error // of reading r
+
+	depth int // of the objects and arrays being read
+}
+
+// maxDepth caps the nesting of objects and arrays like encoding/json
+const maxDepth = 10000
+
+// readChunk is the size data grows by when reading from a reader
+const readChunk = 512
+
+// decode reads the single value of the data of d with value. Errors reading
+// the reader of d are returned over the syntax errors they cause.
+func decode(d *decoder, value func(d *decoder) error) error {
+	err := value(d)
+	if d.peek(); err == nil && d.has(d.pos) {
+		err = d.errorf("invalid character %q after top-level value", d.data[d.pos])
+	}
+	if d.err != nil {
+		return d.err
+	}
+
+	return err
+}
+
+// more appends data read from the reader of d, reporting whether there may
+// be more to read
+func (d *decoder) more() bool {
+	if d.r == nil {
+		return false
+	}
+
+	// Bytes returned before data grows keep pointing at the former array
+	d.data = slices.Grow(d.data, readChunk)
+	n, err := d.r.Read(d.data[len(d.data):cap(d.data)])
+	d.data = d.data[:len(d.data)+n]
+	if err != nil {
+		if !errors.Is(err, io.EOF) {
+			d.err = err
+		}
+		d.r = nil
+	}
+
+	return n > 0 || d.r != nil
+}
+
+// has reports whether data has a byte at i, reading up to it as needed
+func (d *decoder) has(i int) bool {
+	for i >= len(d.data) {
+		if !d.more() {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (d *decoder) errorf(format string, args ...any) error {
+	return fmt.Errorf("invalid JSON at offset %d: %s", d.pos, fmt.Sprintf(format, args...))
+}
+
+// unexpected returns the error of a value not starting with want
+func (d *decoder) unexpected(want string) error {
+	if d.pos >= len(d.data) {
+		return d.errorf("unexpected end of input, expected %s", want)
+	}
+
+	return d.errorf("invalid character %q, expected %s", d.data[d.pos], want)
+}
+
+// peek skips whitespace and returns the next byte, 0 at the end of data
+func (d *decoder) peek() byte {
+	for d.has(d.pos) {
+		switch c := d.data[d.pos]; c {
+		case ' ', '\t', '\n', '\r':
+			d.pos++
+		default:
+			return c
+		}
+	}
+
+	return 0
+}
+
+// literal consumes lit, reporting whether it is next
+func (d *decoder) literal(lit string) bool {
+	if d.peek() == lit[0] && d.has(d.pos+len(lit)-1) && bytes.HasPrefix(d.data[d.pos:], []byte(lit)) {
+		d.pos += len(lit)
+		return true
+	}
+
+	return false
+}
+
+// object reads an object, calling member with the key of every member to read
+// its value
+func (d *decoder) object(member func(key []byte) error) error {
+	if d.literal("null") {
+		return nil
+	}
+	if d.peek() != '{' {
+		return d.unexpected("object")
+	}
+	if err := d.nest(); err != nil {
+		return err
+	}
+	defer func() { d.depth-- }()
+	d.pos++
+	if d.peek() == '}' {
+		d.pos++
+		return nil
+	}
+
+	for {
+		if d.peek() != '"' {
+			return d.unexpected("object key")
+		}
+		key, err := d.rawStr()
+		if err != nil {
+			return err
+		}
+		if d.peek() != ':' {
+			return d.unexpected("':' after object key")
+		}
+		d.pos++
+
+		if err = member(key); err != nil {
+			return err
+		}
+
+		switch d.peek() {
+		case ',':
+			d.pos++
+		case '}':
+			d.pos++
+			return nil
+		default:
+			return d.unexpected("',' or '}' after object member")
+		}
+	}
+}
+
+// nest enters an object or array, failing past maxDepth
+func (d *decoder) nest() error {
+	if d.depth++; d.depth > maxDepth {
+		return d.errorf("exceeded max depth")
+	}
+
+	return nil
+}
+
+// array reads an array, calling elem to read every element
+func (d *decoder) array(elem func() error) error {
+	if d.literal("null") {
+		return nil
+	}
+	if d.peek() != '[' {
+		return d.unexpected("array")
+	}
+	if err := d.nest(); err != nil {
+		return err
+	}
+	defer func() { d.depth-- }()
+	d.pos++
+	if d.peek() == ']' {
+		d.pos++
+		return nil
+	}
+
+	for {
+		if err := elem(); err != nil {
+			return err
+		}
+
+		switch d.peek() {
+		case ',':
+			d.pos++
+		case ']':
+			d.pos++
+			return nil
+		default:
+			return d.unexpected("',' or ']' after array element")
+		}
+	}
+}
+
+// str reads a string
+func (d *decoder) str() (string, error) {
+	if d.literal("null") {
+		return "", nil
+	}
+	if d.peek() != '"' {
+		return "", d.unexpected("string")
+	}
+
+	b, err := d.rawStr()
+	return string(b), err
+}
+
+// rawStr reads a string, returning its unquoted bytes. Unless it has escape
+// sequences or invalid UTF-8, they are a slice of data.
+func (d *decoder) rawStr() ([]byte, error) {
+	start := d.pos + 1
+	escaped, ascii := false, true
+	for i := start; d.has(i); i++ {
+		switch c := d.data[i]; {
+		case c == '\\':
+			escaped = true
+			i++ // the escaped character cannot end the string
+		case c == '"':
+			if !escaped && (ascii || utf8.Valid(d.data[start:i])) {
+				d.pos = i + 1
+				return d.data[start:i], nil
+			}
+
+			// Escapes are rare in responses, so they are left to encoding/json,
+			// which replaces invalid UTF-8 too
+			var s string
+			if err := json.Unmarshal(d.data[d.pos:i+1], &s); err != nil {
+				return nil, d.errorf("invalid string: %v", err)
+			}
+			d.pos = i + 1
+			return []byte(s), nil
+		case c >= utf8.RuneSelf:
+			ascii = false
+		case c < 0x20:
+			d.pos = i
+			return nil, d.errorf("invalid control character %q in string", c)
+		}
+	}
+
+	d.pos = len(d.data)
+	return nil, d.unexpected("end of string")
+}
+
+// number reads a number, returning its bytes
+func (d *decoder) number() ([]byte, error) {
+	c := d.peek()
+	if c != '-' && (c < '0' || c > '9') {
+		return nil, d.unexpected("number")
+	}
+
+	start := d.pos
+	for d.has(d.pos) {
+		switch c := d.data[d.pos]; {
+		case c >= '0' && c <= '9', c == '-', c == '+', c == '.', c == 'e', c == 'E':
+			d.pos++
+		default:
+			return d.data[start:d.pos], nil
+		}
+	}
+
+	return d.data[start:], nil
+}
+
+// int reads an integer
+func (d *decoder) int() (int, error) {
+	if d.literal("null") {
+		return 0, nil
+	}
+
+	start := d.pos
+	b, err := d.number()
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := strconv.Atoi(string(b))
+	if err != nil || !json.Valid(b) {
+		d.pos = start
+		return 0, d.errorf("invalid integer %s", b)
+	}
+
+	return n, nil
+}
+
+// skip reads a value of any type, discarding it
+func (d *decoder) skip() error {
+	switch c := d.peek(); {
+	case c == '{':
+		return d.object(func([]byte) error { return d.skip() })
+	case c == '[':
+		return d.array(d.skip)
+	case c == '"':
+		_, err := d.rawStr()
+		return err
+	case c == '-' || (c >= '0' && c <= '9'):
+		b, err := d.number()
+		if err == nil && !json.Valid(b) {
+			return d.errorf("invalid number %s", b)
+		}
+		return err
+	case d.literal("true"), d.literal("false"), d.literal("null"):
+		return nil
+	default:
+		return d.unexpected("value")
+	}
+}
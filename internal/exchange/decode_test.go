@@ -0,0 +1,238 @@
+package exchange
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// decodable is a response type with a hand-written decoder
+type decodable interface {
+	Decode(data []byte) error
+	read(d *decoder) error
+}
+
+// decodeTests are responses decoded the same as by encoding/json
+var decodeTests = []struct {
+	name string
+	data string
+	new  func() decodable
+}{
+	{
+		name: "binance",
+		data: `{"symbol":"BTCUSDT","price":"97123.45000000"}`,
+		new:  func() decodable { return &BinanceResponse{} },
+	},
+	{
+		name: "binance error",
+		data: `{"code":-1121,"msg":"Invalid symbol."}`,
+		new:  func() decodable { return &BinanceErrorResponse{} },
+	},
+	{
+		name: "bybit",
+		data: `{"retCode":0,"retMsg":"OK","result":{"category":"spot","list":[{"symbol":"BTCUSDT","bid1Price":"97120.5","lastPrice":"97120.55","turnover24h":"1.2e9"}]},"retExtInfo":{},"time":1736942400000}`,
+		new:  func() decodable { return &BybitResponse{} },
+	},
+	{
+		name: "bybit empty",
+		data: `{"retCode":10001,"retMsg":"Not supported symbols","result":{},"retExtInfo":{},"time":1736942400000}`,
+		new:  func() decodable { return &BybitResponse{} },
+	},
+	{
+		name: "bitget",
+		data: `{"code":"00000","msg":"success","requestTime":1736942400000,"data":[{"symbol":"BTCUSDT","high24h":"98000","lastPr":"97118.73","open":null,"ts":"1736942400000"}]}`,
+		new:  func() decodable { return &BitgetResponse{} },
+	},
+	{
+		name: "kraken",
+		data: `{"error":[],"result":{"XBTUSDT":{"a":["97125.20000","1","1.000"],"c":["97125.10000","0.00100000"],"v":["10.1","20.2"],"t":[100,200],"o":"96000.0"}}}`,
+		new:  func() decodable { return &KrakenResponse{} },
+	},
+	{
+		name: "kraken error",
+		data: `{"error":["EQuery:Unknown asset pair"]}`,
+		new:  func() decodable { return &KrakenResponse{} },
+	},
+	{
+		name: "whitespace and escapes",
+		data: " {\n\t\"symbol\" : \"BTC\\u0055SDT\",\r\n\"nested\": {\"a\": [true, false, null, -1.5e3, {\"b\": \"\\\"\"}]}, \"price\": \"1\" } ",
+		new:  func() decodable { return &BinanceResponse{} },
+	},
+	{
+		name: "null",
+		data: `{"symbol":null,"price":"1"}`,
+		new:  func() decodable { return &BinanceResponse{} },
+	},
+}
+
+func TestDecode(t *testing.T) {
+	for _, tt := range decodeTests {
+		t.Run(tt.name, func(t *testing.T) {
+			expected, actual := tt.new(), tt.new()
+			assert.NoError(t, json.Unmarshal([]byte(tt.data), expected))
+			assert.NoError(t, actual.Decode([]byte(tt.data)))
+			assert.Equal(t, expected, actual)
+		})
+	}
+}
+
+func TestDecode_Reader(t *testing.T) {
+	for _, tt := range decodeTests {
+		t.Run(tt.name, func(t *testing.T) {
+			expected, actual := tt.new(), tt.new()
+			assert.NoError(t, expected.Decode([]byte(tt.data)))
+			assert.NoError(t, decode(&decoder{r: iotest.OneByteReader(strings.NewReader(tt.data))}, actual.read))
+			assert.Equal(t, expected, actual)
+		})
+	}
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          string
+		expectedError string
+	}{
+		{name: "empty", data: ``, expectedError: "invalid JSON at offset 0: unexpected end of input, expected object"},
+		{name: "truncated", data: `{"symbol":"BTCUSDT","pri`, expectedError: "invalid JSON at offset 24: unexpected end of input, expected end of string"},
+		{name: "not an object", data: `[]`, expectedError: "invalid JSON at offset 0: invalid character '[', expected object"},
+		{name: "number price", data: `{"price":97123.45}`, expectedError: "invalid JSON at offset 9: invalid character '9', expected string"},
+		{name: "trailing data", data: `{"price":"1"}x`, expectedError: "invalid JSON at offset 13: invalid character 'x' after top-level value"},
+		{name: "missing colon", data: `{"price" "1"}`, expectedError: "invalid JSON at offset 9: invalid character '\"', expected ':' after object key"},
+		{name: "invalid literal", data: `{"x":nope,"price":"1"}`, expectedError: "invalid JSON at offset 5: invalid character 'n', expected value"},
+		{name: "invalid number", data: `{"x":1-2,"price":"1"}`, expectedError: "invalid JSON at offset 8: invalid number 1-2"},
+		{name: "leading zero", data: `{"x":01,"price":"1"}`, expectedError: "invalid JSON at offset 7: invalid number 01"},
+		{name: "nul after value", data: "{}\x00", expectedError: "invalid JSON at offset 2: invalid character '\\x00' after top-level value"},
+		{name: "too deep", data: `{"x":` + strings.Repeat("[", maxDepth), expectedError: "invalid JSON at offset 10004: exceeded max depth"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r BinanceResponse
+			assert.EqualError(t, r.Decode([]byte(tt.data)), tt.expectedError)
+			assert.EqualError(t, decode(&decoder{r: iotest.OneByteReader(strings.NewReader(tt.data))}, r.read), tt.expectedError)
+			assert.Error(t, json.Unmarshal([]byte(tt.data), &r))
+		})
+	}
+}
+
+func TestBinanceErrorResponse_Decode(t *testing.T) {
+	var r BinanceErrorResponse
+	assert.EqualError(t, r.Decode([]byte(`{"code":"-1121"}`)), "invalid JSON at offset 8: invalid character '\"', expected number")
+	assert.EqualError(t, r.Decode([]byte(`{"code":1.5}`)), "invalid JSON at offset 8: invalid integer 1.5")
+	assert.EqualError(t, r.Decode([]byte(`{"code":-01}`)), "invalid JSON at offset 8: invalid integer -01")
+}
+
+func TestKrakenResponse_Decode(t *testing.T) {
+	var r KrakenResponse
+	assert.NoError(t, r.Decode([]byte(`{"error":[],"result":{"XBTUSDT":{"c":["1","2","3"]}}}`)))
+	assert.Equal(t, [2]string{"1", "2"}, r.Result["XBTUSDT"].C)
+}
+
+func BenchmarkDecode(b *testing.B) {
+	for _, tt := range decodeTests {
+		data := []byte(tt.data)
+		b.Run(tt.name+"/decoder", func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				if err := tt.new().Decode(data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(tt.name+"/encoding_json", func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				if err := json.Unmarshal(data, tt.new()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// decodeFields are the keys of the fields of the response types
+var decodeFields = []string{
+	"symbol", "price", "code", "msg", "retCode", "retMsg", "result", "category",
+	"list", "lastPrice", "data", "lastPr", "error", "c",
+}
+
+// foldedKey reports whether data has an object key matching a field of the
+// response types only case-insensitively, which encoding/json matches and the
+// decoders don't
+func foldedKey(data []byte) bool {
+	type frame struct{ object, key bool }
+	var stack []frame
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return false
+		}
+		if d, ok := tok.(json.Delim); ok && (d == '}' || d == ']') {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		if n := len(stack); n > 0 && stack[n-1].object {
+			if key, ok := tok.(string); ok && stack[n-1].key {
+				for _, f := range decodeFields {
+					if key != f && strings.EqualFold(key, f) {
+						return true
+					}
+				}
+			}
+			stack[n-1].key = !stack[n-1].key
+		}
+		if d, ok := tok.(json.Delim); ok {
+			stack = append(stack, frame{object: d == '{', key: d == '{'})
+		}
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	for _, tt := range decodeTests {
+		f.Add([]byte(tt.data))
+	}
+	f.Add([]byte(`{"error":[],"result":{"XBTUSDT":{"c":["1","2"]},"XBTUSDT":{"c":["3"]}}}`))
+	f.Add([]byte(`{"retCode":01,"result":{"list":[{"symbol":"A"}],"list":[{"lastPrice":"1"}]}}`))
+	f.Add([]byte("{\"symbol\":\"\xff\"}"))
+	f.Add([]byte("{}\x00"))
+
+	types := []func() decodable{
+		func() decodable { return &BinanceResponse{} },
+		func() decodable { return &BinanceErrorResponse{} },
+		func() decodable { return &BybitResponse{} },
+		func() decodable { return &BitgetResponse{} },
+		func() decodable { return &KrakenResponse{} },
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if foldedKey(data) {
+			t.Skip()
+		}
+
+		for _, newResponse := range types {
+			expected, actual, read := newResponse(), newResponse(), newResponse()
+			expectedErr := json.Unmarshal(data, expected)
+			err := actual.Decode(data)
+			if (expectedErr == nil) != (err == nil) {
+				t.Fatalf("%T: decoder error %v, encoding/json error %v", actual, err, expectedErr)
+			}
+			if err == nil {
+				assert.Equal(t, expected, actual)
+			}
+
+			readErr := decode(&decoder{r: iotest.OneByteReader(bytes.NewReader(data))}, read.read)
+			if err == nil {
+				assert.NoError(t, readErr)
+				assert.Equal(t, actual, read)
+			} else {
+				assert.EqualError(t, readErr, err.Error())
+			}
+		}
+	})
+}
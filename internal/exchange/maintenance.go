@@ -0,0 +1,41 @@
+package exchange
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// maintenanceErrors are the errors exchanges answer with while their API is
+// down for maintenance. Errors without a message match any message.
+var maintenanceErrors = map[Name][]Error{
+	// -1016 SERVICE_SHUTTING_DOWN: This service is no longer available.
+	BINANCE: {{Code: "-1016"}},
+	// EService:Unavailable: The matching engine or API is offline
+	KRAKEN: {{Code: "EService", Msg: "Unavailable"}},
+}
+
+// IsMaintenance reports whether err, of a call to n, says the exchange is
+// down for maintenance: a known maintenance error or a 503 response. The text
+// of errors is not looked at, as it may hold the pair or URL of the call.
+func IsMaintenance(n Name, err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusServiceUnavailable {
+		return true
+	}
+
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		for _, m := range maintenanceErrors[n] {
+			if apiErr.Code == m.Code && (m.Msg == "" || strings.EqualFold(apiErr.Msg, m.Msg)) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,38 @@
+package exchange
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsMaintenance(t *testing.T) {
+	tests := []struct {
+		name     string
+		exchange Name
+		err      error
+		expected bool
+	}{
+		{name: "no error", exchange: BINANCE},
+		{name: "binance shutting down", exchange: BINANCE, err: &Error{Code: "-1016", Msg: "This service is no longer available."}, expected: true},
+		{name: "binance invalid symbol", exchange: BINANCE, err: &Error{Code: "-1121", Msg: "Invalid symbol."}},
+		{name: "kraken unavailable", exchange: KRAKEN, err: &Error{Code: "EService", Msg: "Unavailable"}, expected: true},
+		{name: "kraken busy", exchange: KRAKEN, err: &Error{Code: "EService", Msg: "Busy"}},
+		{name: "code of another exchange", exchange: BYBIT, err: &Error{Code: "-1016"}},
+		{name: "message", exchange: BYBIT, err: &Error{Code: "10016", Msg: "System under maintenance"}},
+		{name: "wrapped", exchange: KRAKEN, err: fmt.Errorf("parse: %w", &Error{Code: "EService", Msg: "Unavailable"}), expected: true},
+		{name: "service unavailable", exchange: BITGET, err: &StatusError{StatusCode: 503, Body: "<h1>Scheduled Maintenance</h1>"}, expected: true},
+		{name: "service unavailable with error", exchange: BYBIT, err: fmt.Errorf("wrapped: %w", &StatusError{StatusCode: 503, Err: &Error{Code: "10006"}}), expected: true},
+		{name: "other status", exchange: BITGET, err: &StatusError{StatusCode: 502, Body: "System maintenance"}},
+		{name: "pair mentioning maintenance", exchange: BINANCE, err: errors.New(`do request: Get "https://api.binance.com/api/v3/ticker/price?symbol=MAINTENANCEUSDT": context deadline exceeded`)},
+		{name: "body mentioning maintenance", exchange: BITGET, err: &StatusError{StatusCode: 400, Body: "unknown symbol MAINTENANCEUSDT"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, IsMaintenance(tt.exchange, tt.err))
+		})
+	}
+}
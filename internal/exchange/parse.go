@@ -0,0 +1,232 @@
+package exchange
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrEmpty is returned for responses without a price of the pair, e.g. of
+// pairs unknown to the exchange
+var ErrEmpty = errors.New("empty response")
+
+// Error represents an error reported in a response of an exchange
+type Error struct {
+	Code string
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("code=%s, msg=%s", e.Code, e.Msg)
+}
+
+// StatusError represents an unsuccessful response of an exchange, with the
+// error its body was parsed to, if any
+type StatusError struct {
+	StatusCode int
+	Body       string
+	Err        *Error
+}
+
+func (e *StatusError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("unexpected status code: %d, body: %s", e.StatusCode, e.Body)
+}
+
+func (e *StatusError) Unwrap() error {
+	if e.Err == nil {
+		return nil
+	}
+	return e.Err
+}
+
+// ParsePrice returns the price of pair in body, a response of the price API
+// of n
+func ParsePrice(n Name, pair string, body []byte) (float64, error) {
+	return price(n, pair, &decoder{data: body})
+}
+
+// ReadPrice returns the price of pair in body, a response of the price API
+// of n, decoding it as it is read rather than reading it first
+func ReadPrice(n Name, pair string, body io.Reader) (float64, error) {
+	return price(n, pair, &decoder{r: body})
+}
+
+func price(n Name, pair string, d *decoder) (float64, error) {
+	switch n {
+	case BINANCE:
+		return binancePrice(d)
+	case BYBIT:
+		return bybitPrice(d)
+	case BITGET:
+		return bitgetPrice(d)
+	case KRAKEN:
+		return krakenPrice(d, Symbol(KRAKEN, pair))
+	}
+
+	return 0, errors.New("unknown exchange")
+}
+
+// ParseError returns the *Error in body, an error response of the price API
+// of n, and false when body is not one
+func ParseError(n Name, body []byte) (*Error, bool) {
+	switch n {
+	case BINANCE:
+		var r BinanceErrorResponse
+		if err := r.Decode(body); err != nil {
+			return nil, false
+		}
+		return &Error{Code: strconv.Itoa(r.Code), Msg: r.Msg}, true
+	case BYBIT:
+		var r BybitResponse
+		if err := r.Decode(body); err != nil {
+			return nil, false
+		}
+		return &Error{Code: strconv.Itoa(r.RetCode), Msg: r.RetMsg}, true
+	case BITGET:
+		var r BitgetResponse
+		if err := r.Decode(body); err != nil {
+			return nil, false
+		}
+		return &Error{Code: r.Code, Msg: r.Msg}, true
+	case KRAKEN:
+		var r KrakenResponse
+		if err := r.Decode(body); err != nil || len(r.Error) == 0 {
+			return nil, false
+		}
+		return krakenError(r.Error[0]), true
+	}
+
+	return nil, false
+}
+
+// ParseBinancePrice returns the price in a response of the Binance price API
+func ParseBinancePrice(body []byte) (float64, error) {
+	return binancePrice(&decoder{data: body})
+}
+
+// ReadBinancePrice returns the price in body, a response of the Binance price
+// API, decoding it as it is read
+func ReadBinancePrice(body io.Reader) (float64, error) {
+	return binancePrice(&decoder{r: body})
+}
+
+func binancePrice(d *decoder) (float64, error) {
+	var r BinanceResponse
+	if err := decode(d, r.read); err != nil {
+		return 0, fmt.Errorf("decode response: %w", err)
+	}
+
+	return parsePrice(r.Price)
+}
+
+// ParseBybitPrice returns the price in a response of the Bybit price API
+func ParseBybitPrice(body []byte) (float64, error) {
+	return bybitPrice(&decoder{data: body})
+}
+
+// ReadBybitPrice returns the price in body, a response of the Bybit price
+// API, decoding it as it is read
+func ReadBybitPrice(body io.Reader) (float64, error) {
+	return bybitPrice(&decoder{r: body})
+}
+
+func bybitPrice(d *decoder) (float64, error) {
+	var r BybitResponse
+	if err := decode(d, r.read); err != nil {
+		return 0, fmt.Errorf("decode response: %w", err)
+	}
+
+	if len(r.Result.List) == 0 {
+		return 0, ErrEmpty
+	}
+
+	return parsePrice(r.Result.List[0].LastPrice)
+}
+
+// ParseBitgetPrice returns the price in a response of the Bitget price API
+func ParseBitgetPrice(body []byte) (float64, error) {
+	return bitgetPrice(&decoder{data: body})
+}
+
+// ReadBitgetPrice returns the price in body, a response of the Bitget price
+// API, decoding it as it is read
+func ReadBitgetPrice(body io.Reader) (float64, error) {
+	return bitgetPrice(&decoder{r: body})
+}
+
+func bitgetPrice(d *decoder) (float64, error) {
+	var r BitgetResponse
+	if err := decode(d, r.read); err != nil {
+		return 0, fmt.Errorf("decode response: %w", err)
+	}
+
+	if len(r.Data) == 0 {
+		return 0, ErrEmpty
+	}
+
+	return parsePrice(r.Data[0].LastPr)
+}
+
+// ParseKrakenPrice returns the price of symbol, a Kraken pair, in a response
+// of the Kraken price API. Kraken reports errors in responses with status
+// 200, returned as *Error.
+func ParseKrakenPrice(body []byte, symbol string) (float64, error) {
+	return krakenPrice(&decoder{data: body}, symbol)
+}
+
+// ReadKrakenPrice returns the price of symbol, a Kraken pair, in body, a
+// response of the Kraken price API, decoding it as it is read
+func ReadKrakenPrice(body io.Reader, symbol string) (float64, error) {
+	return krakenPrice(&decoder{r: body}, symbol)
+}
+
+func krakenPrice(d *decoder, symbol string) (float64, error) {
+	var r KrakenResponse
+	if err := decode(d, r.read); err != nil {
+		return 0, fmt.Errorf("decode response: %w", err)
+	}
+
+	if len(r.Error) > 0 {
+		return 0, krakenError(r.Error[0])
+	}
+
+	for key, ticker := range r.Result {
+		if key == symbol || krakenPair(key) == symbol {
+			return parsePrice(ticker.C[0])
+		}
+	}
+
+	return 0, ErrEmpty
+}
+
+// krakenPair returns the pair of a result of Kraken, named after legacy
+// assets prefixed with X for crypto and Z for fiat currencies in older
+// pairs, e.g. XBTUSD for XXBTZUSD
+func krakenPair(key string) string {
+	if len(key) == 8 && strings.ContainsRune("XZ", rune(key[0])) && strings.ContainsRune("XZ", rune(key[4])) {
+		return key[1:4] + key[5:]
+	}
+
+	return key
+}
+
+// krakenError returns the error of a Kraken error message, e.g.
+// "EQuery:Unknown asset pair"
+func krakenError(msg string) *Error {
+	code, msg, _ := strings.Cut(msg, ":")
+	return &Error{Code: code, Msg: strings.TrimSpace(msg)}
+}
+
+func parsePrice(s string) (float64, error) {
+	price, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse price: %w", err)
+	}
+
+	return price, nil
+}
@@ -0,0 +1,159 @@
+package exchange
+
+import (
+	"cmp"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var parsePriceTests = []struct {
+	name          string
+	exchange      Name
+	pair          string // BTCUSDT when empty
+	body          string
+	expectedPrice float64
+	expectedError string
+}{
+	{name: "binance", exchange: BINANCE, body: `{"symbol":"BTCUSDT","price":"97123.45000000"}`, expectedPrice: 97123.45},
+	{name: "binance invalid price", exchange: BINANCE, body: `{"symbol":"BTCUSDT","price":"n/a"}`, expectedError: `parse price: strconv.ParseFloat: parsing "n/a": invalid syntax`},
+	{name: "binance missing price", exchange: BINANCE, body: `{"symbol":"BTCUSDT"}`, expectedError: `parse price: strconv.ParseFloat: parsing "": invalid syntax`},
+	{name: "binance malformed", exchange: BINANCE, body: `{"symbol":`, expectedError: "decode response: invalid JSON at offset 10: unexpected end of input, expected string"},
+	{
+		name:          "bybit",
+		exchange:      BYBIT,
+		body:          `{"retCode":0,"retMsg":"OK","result":{"category":"spot","list":[{"symbol":"BTCUSDT","lastPrice":"97120.55"}]}}`,
+		expectedPrice: 97120.55,
+	},
+	{name: "bybit empty", exchange: BYBIT, body: `{"retCode":10001,"retMsg":"Not supported symbols","result":{}}`, expectedError: "empty response"},
+	{name: "bybit invalid price", exchange: BYBIT, body: `{"result":{"list":[{"lastPrice":""}]}}`, expectedError: `parse price: strconv.ParseFloat: parsing "": invalid syntax`},
+	{name: "bybit malformed", exchange: BYBIT, body: `[]`, expectedError: "decode response: invalid JSON at offset 0: invalid character '[', expected object"},
+	{
+		name:          "bitget",
+		exchange:      BITGET,
+		body:          `{"code":"00000","msg":"success","data":[{"symbol":"BTCUSDT","lastPr":"97118.73"}]}`,
+		expectedPrice: 97118.73,
+	},
+	{name: "bitget empty", exchange: BITGET, body: `{"code":"00000","msg":"success","data":[]}`, expectedError: "empty response"},
+	{name: "bitget invalid price", exchange: BITGET, body: `{"data":[{"lastPr":"1e"}]}`, expectedError: `parse price: strconv.ParseFloat: parsing "1e": invalid syntax`},
+	{name: "bitget malformed", exchange: BITGET, body: `{"data":{}}`, expectedError: "decode response: invalid JSON at offset 8: invalid character '{', expected array"},
+	{
+		name:          "kraken",
+		exchange:      KRAKEN,
+		body:          `{"error":[],"result":{"XBTUSDT":{"c":["97125.10000","0.00100000"]}}}`,
+		expectedPrice: 97125.1,
+	},
+	{name: "kraken error", exchange: KRAKEN, body: `{"error":["EQuery:Unknown asset pair"]}`, expectedError: "code=EQuery, msg=Unknown asset pair"},
+	{name: "kraken error without message", exchange: KRAKEN, body: `{"error":["EService"]}`, expectedError: "code=EService, msg="},
+	{name: "kraken legacy pair", exchange: KRAKEN, pair: "BTCUSD", body: `{"error":[],"result":{"XXBTZUSD":{"c":["97130.2","0.001"]}}}`, expectedPrice: 97130.2},
+	{name: "kraken empty", exchange: KRAKEN, body: `{"error":[],"result":{}}`, expectedError: "empty response"},
+	{name: "kraken other pair", exchange: KRAKEN, body: `{"error":[],"result":{"XETHZUSD":{"c":["3100.5","0.1"]}}}`, expectedError: "empty response"},
+	{name: "kraken invalid price", exchange: KRAKEN, body: `{"result":{"XBTUSDT":{"c":[]}}}`, expectedError: `parse price: strconv.ParseFloat: parsing "": invalid syntax`},
+	{name: "kraken malformed", exchange: KRAKEN, body: `{"error":"EQuery"}`, expectedError: "decode response: invalid JSON at offset 9: invalid character '\"', expected array"},
+	{name: "unknown exchange", exchange: Name(len(names)), body: `{}`, expectedError: "unknown exchange"},
+}
+
+func TestParsePrice(t *testing.T) {
+	for _, tt := range parsePriceTests {
+		t.Run(tt.name, func(t *testing.T) {
+			price, err := ParsePrice(tt.exchange, cmp.Or(tt.pair, "BTCUSDT"), []byte(tt.body))
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedPrice, price)
+		})
+	}
+}
+
+func TestReadPrice(t *testing.T) {
+	for _, tt := range parsePriceTests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Read a byte at a time, splitting every value across reads
+			price, err := ReadPrice(tt.exchange, cmp.Or(tt.pair, "BTCUSDT"), iotest.OneByteReader(strings.NewReader(tt.body)))
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedPrice, price)
+		})
+	}
+}
+
+func TestReadExchangePrice(t *testing.T) {
+	readers := map[Name]func(body io.Reader) (float64, error){
+		BINANCE: ReadBinancePrice,
+		BYBIT:   ReadBybitPrice,
+		BITGET:  ReadBitgetPrice,
+		KRAKEN:  func(body io.Reader) (float64, error) { return ReadKrakenPrice(body, "XBTUSDT") },
+	}
+
+	for _, tt := range parsePriceTests {
+		read, ok := readers[tt.exchange]
+		if !ok || tt.pair != "" {
+			continue
+		}
+
+		t.Run(tt.name, func(t *testing.T) {
+			price, err := read(iotest.OneByteReader(strings.NewReader(tt.body)))
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedPrice, price)
+		})
+	}
+}
+
+func TestReadPrice_ReadError(t *testing.T) {
+	errRead := errors.New("connection reset")
+	_, err := ReadPrice(BINANCE, "BTCUSDT", io.MultiReader(strings.NewReader(`{"symbol":"BTC`), iotest.ErrReader(errRead)))
+	assert.ErrorIs(t, err, errRead)
+	assert.EqualError(t, err, "decode response: connection reset")
+}
+
+func TestParsePrice_Errors(t *testing.T) {
+	_, err := ParseBybitPrice([]byte(`{"result":{"list":null}}`))
+	assert.ErrorIs(t, err, ErrEmpty)
+
+	_, err = ParseKrakenPrice([]byte(`{"error":["EGeneral:Too many requests"]}`), "XBTUSDT")
+	var apiErr *Error
+	assert.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, &Error{Code: "EGeneral", Msg: "Too many requests"}, apiErr)
+}
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		name          string
+		exchange      Name
+		body          string
+		expectedError *Error
+	}{
+		{name: "binance", exchange: BINANCE, body: `{"code":-1121,"msg":"Invalid symbol."}`, expectedError: &Error{Code: "-1121", Msg: "Invalid symbol."}},
+		{name: "bybit", exchange: BYBIT, body: `{"retCode":10006,"retMsg":"Too many visits!"}`, expectedError: &Error{Code: "10006", Msg: "Too many visits!"}},
+		{name: "bitget", exchange: BITGET, body: `{"code":"40034","msg":"Parameter does not exist"}`, expectedError: &Error{Code: "40034", Msg: "Parameter does not exist"}},
+		{name: "kraken", exchange: KRAKEN, body: `{"error":["EAPI:Rate limit exceeded"]}`, expectedError: &Error{Code: "EAPI", Msg: "Rate limit exceeded"}},
+		{name: "kraken without errors", exchange: KRAKEN, body: `{"error":[]}`},
+		{name: "not JSON", exchange: BINANCE, body: `<html>Bad Gateway</html>`},
+		{name: "truncated", exchange: BYBIT, body: `{"retCode":10006,"retMsg":"Too`},
+		{name: "unknown exchange", exchange: Name(len(names)), body: `{}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err, ok := ParseError(tt.exchange, []byte(tt.body))
+			assert.Equal(t, tt.expectedError != nil, ok)
+			assert.Equal(t, tt.expectedError, err)
+		})
+	}
+}
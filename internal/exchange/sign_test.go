@@ -0,0 +1,123 @@
+package exchange
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func hmacSHA256(secret, payload string) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
+
+func TestNewSigner(t *testing.T) {
+	tests := []struct {
+		name        string
+		exchange    Name
+		creds       Credentials
+		expectedErr bool
+	}{
+		{name: "binance", exchange: BINANCE, creds: Credentials{Key: "k", Secret: "s"}},
+		{name: "bybit", exchange: BYBIT, creds: Credentials{Key: "k", Secret: "s"}},
+		{name: "bitget", exchange: BITGET, creds: Credentials{Key: "k", Secret: "s", Passphrase: "p"}},
+		{name: "bitget without passphrase", exchange: BITGET, creds: Credentials{Key: "k", Secret: "s"}, expectedErr: true},
+		{name: "kraken", exchange: KRAKEN, creds: Credentials{Key: "k", Secret: "s"}, expectedErr: true},
+		{name: "no secret", exchange: BINANCE, creds: Credentials{Key: "k"}, expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSigner(tt.exchange, tt.creds)
+			if tt.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.NotNil(t, s)
+		})
+	}
+}
+
+func TestSigner_Sign_Binance(t *testing.T) {
+	// Example of the Binance API documentation
+	secret := "NhqPtmdSJYdKjVHjA7PZj4Mge3R5YNiP1e3UZjInClVN65XAbvqqM6A7H5fATj0j"
+	s, err := NewSigner(BINANCE, Credentials{Key: "key", Secret: secret})
+	assert.NoError(t, err)
+
+	query := "symbol=LTCBTC&side=BUY&type=LIMIT&timeInForce=GTC&quantity=1&price=0.1&recvWindow=5000&timestamp=1499827319559"
+	req := httptest.NewRequest(http.MethodPost, "https://api.binance.com/api/v3/order?"+query, http.NoBody)
+	assert.NoError(t, s.Sign(req, nil))
+	assert.Equal(t, query+"&signature=c8db56825ae71d6d79447849e617115f4a920fa2acdcab2b053c4b2838bd6b71", req.URL.RawQuery)
+	assert.Equal(t, "key", req.Header.Get("X-MBX-APIKEY"))
+
+	// Timestamp and receive window are added when missing
+	s.now = func() time.Time { return time.UnixMilli(1700000000000) }
+	req = httptest.NewRequest(http.MethodGet, "https://api.binance.com/api/v3/account", http.NoBody)
+	assert.NoError(t, s.Sign(req, nil))
+	signed := "timestamp=1700000000000&recvWindow=5000"
+	assert.Equal(t, signed+"&signature="+hex.EncodeToString(hmacSHA256(secret, signed)), req.URL.RawQuery)
+}
+
+func TestSigner_Sign_Bybit(t *testing.T) {
+	s, err := NewSigner(BYBIT, Credentials{Key: "key", Secret: "secret"})
+	assert.NoError(t, err)
+	s.now = func() time.Time { return time.UnixMilli(1700000000000) }
+
+	tests := []struct {
+		name    string
+		method  string
+		url     string
+		body    string
+		payload string
+	}{
+		{
+			name:    "query",
+			method:  http.MethodGet,
+			url:     "https://api.bybit.com/v5/account/wallet-balance?accountType=UNIFIED",
+			payload: "accountType=UNIFIED",
+		},
+		{
+			name:    "body",
+			method:  http.MethodPost,
+			url:     "https://api.bybit.com/v5/order/create",
+			body:    `{"category":"spot"}`,
+			payload: `{"category":"spot"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.url, http.NoBody)
+			assert.NoError(t, s.Sign(req, []byte(tt.body)))
+
+			assert.Equal(t, "key", req.Header.Get("X-BAPI-API-KEY"))
+			assert.Equal(t, "1700000000000", req.Header.Get("X-BAPI-TIMESTAMP"))
+			assert.Equal(t, "5000", req.Header.Get("X-BAPI-RECV-WINDOW"))
+			assert.Equal(t, hex.EncodeToString(hmacSHA256("secret", "1700000000000key5000"+tt.payload)), req.Header.Get("X-BAPI-SIGN"))
+		})
+	}
+}
+
+func TestSigner_Sign_Bitget(t *testing.T) {
+	s, err := NewSigner(BITGET, Credentials{Key: "key", Secret: "secret", Passphrase: "pass"})
+	assert.NoError(t, err)
+	s.now = func() time.Time { return time.UnixMilli(1700000000000) }
+
+	req := httptest.NewRequest(http.MethodGet, "https://api.bitget.com/api/v2/spot/account/assets?coin=USDT", http.NoBody)
+	assert.NoError(t, s.Sign(req, nil))
+
+	assert.Equal(t, "key", req.Header.Get("ACCESS-KEY"))
+	assert.Equal(t, "1700000000000", req.Header.Get("ACCESS-TIMESTAMP"))
+	assert.Equal(t, "pass", req.Header.Get("ACCESS-PASSPHRASE"))
+	expected := hmacSHA256("secret", "1700000000000GET/api/v2/spot/account/assets?coin=USDT")
+	assert.Equal(t, base64.StdEncoding.EncodeToString(expected), req.Header.Get("ACCESS-SIGN"))
+}
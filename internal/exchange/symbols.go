@@ -0,0 +1,74 @@
+package exchange
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// symbolAliases are the symbols of assets exchanges name differently, by
+// exchange and common symbol, e.g. Kraken's XBT for BTC
+var symbolAliases = map[Name]map[string]string{
+	KRAKEN: {"BTC": "XBT", "DOGE": "XDG"},
+}
+
+// Symbol returns pair, e.g. BTCUSDT, in the symbols of the exchange n, e.g.
+// XBTUSDT on Kraken
+func Symbol(n Name, pair string) string {
+	return translate(pair, symbolAliases[n])
+}
+
+// Pair returns the common pair of symbol, a pair in the symbols of the
+// exchange n, e.g. BTCUSDT for XBTUSDT on Kraken
+func Pair(n Name, symbol string) string {
+	common := make(map[string]string, len(symbolAliases[n]))
+	for asset, alias := range symbolAliases[n] {
+		common[alias] = asset
+	}
+
+	return translate(symbol, common)
+}
+
+// quoteAssets are the assets pairs are split on into their base and quote
+var quoteAssets = []string{
+	"USDT", "USDC", "FDUSD", "TUSD", "BUSD", "DAI", "USD",
+	"EUR", "GBP", "JPY", "CAD", "AUD", "CHF", "TRY", "BRL", "PLN",
+	"BTC", "WBTC", "ETH", "BNB", "SOL",
+}
+
+// translate replaces the base and quote assets of pair found in aliases.
+// Pairs are split on quoteAssets and aliases they end with, preferring a base
+// found in aliases, e.g. XBT and USD rather than XB and TUSD for XBTUSD, then
+// the longest quote, e.g. WBTC rather than BTC for ETHWBTC. Pairs ending with
+// none of them are left as they are.
+func translate(pair string, aliases map[string]string) string {
+	if len(aliases) == 0 {
+		return pair
+	}
+
+	var base, quote string
+	aliased := false
+	for _, asset := range slices.Concat(quoteAssets, slices.Collect(maps.Keys(aliases))) {
+		if len(pair) <= len(asset) || !strings.HasSuffix(pair, asset) {
+			continue
+		}
+
+		b := pair[:len(pair)-len(asset)]
+		_, ok := aliases[b]
+		if quote == "" || ok && !aliased || ok == aliased && len(asset) > len(quote) {
+			base, quote, aliased = b, asset, ok
+		}
+	}
+	if quote == "" {
+		return pair
+	}
+
+	if alias, ok := aliases[base]; ok {
+		base = alias
+	}
+	if alias, ok := aliases[quote]; ok {
+		quote = alias
+	}
+
+	return base + quote
+}
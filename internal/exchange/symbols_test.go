@@ -0,0 +1,39 @@
+package exchange
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSymbol(t *testing.T) {
+	tests := []struct {
+		exchange Name
+		pair     string
+		symbol   string
+	}{
+		{exchange: KRAKEN, pair: "BTCUSDT", symbol: "XBTUSDT"},
+		{exchange: KRAKEN, pair: "ETHBTC", symbol: "ETHXBT"},
+		{exchange: KRAKEN, pair: "DOGEUSD", symbol: "XDGUSD"},
+		{exchange: KRAKEN, pair: "DOGEBTC", symbol: "XDGXBT"},
+		{exchange: KRAKEN, pair: "ETHUSDT", symbol: "ETHUSDT"},
+		{exchange: KRAKEN, pair: "BTC", symbol: "BTC"},
+		{exchange: KRAKEN, pair: "ETHWBTC", symbol: "ETHWBTC"},
+		{exchange: KRAKEN, pair: "WBTCUSDT", symbol: "WBTCUSDT"},
+		{exchange: KRAKEN, pair: "WBTCBTC", symbol: "WBTCXBT"},
+		{exchange: KRAKEN, pair: "BTCWBTC", symbol: "XBTWBTC"},
+		{exchange: KRAKEN, pair: "BTCEUR", symbol: "XBTEUR"},
+		{exchange: KRAKEN, pair: "BTCUSD", symbol: "XBTUSD"},
+		{exchange: KRAKEN, pair: "BTCTUSD", symbol: "XBTTUSD"},
+		{exchange: KRAKEN, pair: "BTCFDUSD", symbol: "XBTFDUSD"},
+		{exchange: KRAKEN, pair: "BTCXYZ", symbol: "BTCXYZ"},
+		{exchange: BINANCE, pair: "BTCUSDT", symbol: "BTCUSDT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.exchange.String()+" "+tt.pair, func(t *testing.T) {
+			assert.Equal(t, tt.symbol, Symbol(tt.exchange, tt.pair))
+			assert.Equal(t, tt.pair, Pair(tt.exchange, tt.symbol))
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package exchange
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Ticker represents the last price and the 24h volume of a pair
+type Ticker struct {
+	Pair        string
+	Price       float64
+	QuoteVolume float64 // traded in the last 24h, in the quote asset
+}
+
+// BinanceMiniTickersResponse represents Binance 24h mini tickers response
+type BinanceMiniTickersResponse []struct {
+	Symbol      string `json:"symbol"`
+	LastPrice   string `json:"lastPrice"`
+	QuoteVolume string `json:"quoteVolume"`
+}
+
+// BybitTickersResponse represents Bybit spot tickers response
+type BybitTickersResponse struct {
+	RetCode int    `json:"retCode"`
+	RetMsg  string `json:"retMsg"`
+	Result  struct {
+		List []struct {
+			Symbol      string `json:"symbol"`
+			LastPrice   string `json:"lastPrice"`
+			Turnover24h string `json:"turnover24h"`
+		} `json:"list"`
+	} `json:"result"`
+}
+
+// BitgetTickersResponse represents Bitget spot tickers response
+type BitgetTickersResponse struct {
+	Code string `json:"code"`
+	Msg  string `json:"msg"`
+	Data []struct {
+		Symbol      string `json:"symbol"`
+		LastPr      string `json:"lastPr"`
+		QuoteVolume string `json:"quoteVolume"`
+	} `json:"data"`
+}
+
+// KrakenTickersResponse represents Kraken tickers response
+type KrakenTickersResponse struct {
+	Error  []string `json:"error"`
+	Result map[string]struct {
+		C [2]string `json:"c"` // last trade: [price, lot_volume]
+		V [2]string `json:"v"` // base volume: [today, last 24h]
+		P [2]string `json:"p"` // volume weighted average price: [today, last 24h]
+	} `json:"result"`
+}
+
+// ParseTickers returns the tickers in body, a response of the tickers API of
+// n, with pairs named alike on all exchanges. Tickers without a price or a
+// volume are skipped.
+func ParseTickers(n Name, body []byte) ([]Ticker, error) {
+	var tickers []Ticker
+	add := func(symbol, price, volume string) {
+		t := Ticker{Pair: Pair(n, symbol)}
+		var err1, err2 error
+		t.Price, err1 = strconv.ParseFloat(price, 64)
+		t.QuoteVolume, err2 = strconv.ParseFloat(volume, 64)
+		if err1 == nil && err2 == nil {
+			tickers = append(tickers, t)
+		}
+	}
+
+	switch n {
+	case BINANCE:
+		var r BinanceMiniTickersResponse
+		if err := json.Unmarshal(body, &r); err != nil {
+			return nil, fmt.Errorf("decode response: %w", err)
+		}
+		for _, t := range r {
+			add(t.Symbol, t.LastPrice, t.QuoteVolume)
+		}
+	case BYBIT:
+		var r BybitTickersResponse
+		if err := json.Unmarshal(body, &r); err != nil {
+			return nil, fmt.Errorf("decode response: %w", err)
+		}
+		if r.RetCode != 0 {
+			return nil, &Error{Code: strconv.Itoa(r.RetCode), Msg: r.RetMsg}
+		}
+		for _, t := range r.Result.List {
+			add(t.Symbol, t.LastPrice, t.Turnover24h)
+		}
+	case BITGET:
+		var r BitgetTickersResponse
+		if err := json.Unmarshal(body, &r); err != nil {
+			return nil, fmt.Errorf("decode response: %w", err)
+		}
+		if r.Code != "00000" {
+			return nil, &Error{Code: r.Code, Msg: r.Msg}
+		}
+		for _, t := range r.Data {
+			add(t.Symbol, t.LastPr, t.QuoteVolume)
+		}
+	case KRAKEN:
+		var r KrakenTickersResponse
+		if err := json.Unmarshal(body, &r); err != nil {
+			return nil, fmt.Errorf("decode response: %w", err)
+		}
+		if len(r.Error) > 0 {
+			return nil, krakenError(r.Error[0])
+		}
+		// Kraken reports base volumes, converted at the average price
+		for key, t := range r.Result {
+			price, err1 := strconv.ParseFloat(t.C[0], 64)
+			volume, err2 := strconv.ParseFloat(t.V[1], 64)
+			vwap, err3 := strconv.ParseFloat(t.P[1], 64)
+			if err1 == nil && err2 == nil && err3 == nil {
+				tickers = append(tickers, Ticker{Pair: Pair(n, krakenPair(key)), Price: price, QuoteVolume: volume * vwap})
+			}
+		}
+	default:
+		return nil, fmt.Errorf("tickers are not supported for %s", n)
+	}
+
+	return tickers, nil
+}
@@ -0,0 +1,66 @@
+package exchange
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExchange_TickersURL(t *testing.T) {
+	assert.Equal(t, "https://api.binance.com/api/v3/ticker/24hr?type=MINI", New(BINANCE).TickersURL())
+	assert.Equal(t, "https://api.kraken.com/0/public/Ticker", New(KRAKEN).TickersURL())
+	assert.Empty(t, New(UNISWAP).TickersURL())
+}
+
+func TestParseTickers(t *testing.T) {
+	tests := []struct {
+		name            string
+		exchange        Name
+		body            string
+		expectedTickers []Ticker
+		expectedError   string
+	}{
+		{
+			name:     "binance",
+			exchange: BINANCE,
+			body:     `[{"symbol":"BTCUSDT","lastPrice":"97000.00","quoteVolume":"1500000000.5"},{"symbol":"ETHBTC","lastPrice":"0.032","quoteVolume":"n/a"}]`,
+			expectedTickers: []Ticker{
+				{Pair: "BTCUSDT", Price: 97000, QuoteVolume: 1500000000.5},
+			},
+		},
+		{
+			name:            "bybit",
+			exchange:        BYBIT,
+			body:            `{"retCode":0,"retMsg":"OK","result":{"category":"spot","list":[{"symbol":"ETHUSDT","lastPrice":"3100.5","turnover24h":"250000000"}]}}`,
+			expectedTickers: []Ticker{{Pair: "ETHUSDT", Price: 3100.5, QuoteVolume: 250000000}},
+		},
+		{name: "bybit error", exchange: BYBIT, body: `{"retCode":10006,"retMsg":"Too many visits"}`, expectedError: "code=10006, msg=Too many visits"},
+		{
+			name:            "bitget",
+			exchange:        BITGET,
+			body:            `{"code":"00000","msg":"success","data":[{"symbol":"SOLUSDT","lastPr":"210.1","quoteVolume":"90000000"}]}`,
+			expectedTickers: []Ticker{{Pair: "SOLUSDT", Price: 210.1, QuoteVolume: 90000000}},
+		},
+		{
+			name:            "kraken",
+			exchange:        KRAKEN,
+			body:            `{"error":[],"result":{"XXBTZUSD":{"c":["97010.0","0.1"],"v":["500.0","1000.0"],"p":["96900.0","97000.0"]}}}`,
+			expectedTickers: []Ticker{{Pair: "BTCUSD", Price: 97010, QuoteVolume: 97000000}},
+		},
+		{name: "kraken error", exchange: KRAKEN, body: `{"error":["EGeneral:Too many requests"]}`, expectedError: "code=EGeneral, msg=Too many requests"},
+		{name: "malformed", exchange: BINANCE, body: `{}`, expectedError: "decode response: json: cannot unmarshal object into Go value of type exchange.BinanceMiniTickersResponse"},
+		{name: "unsupported", exchange: UNISWAP, body: `{}`, expectedError: "tickers are not supported for uniswap"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tickers, err := ParseTickers(tt.exchange, []byte(tt.body))
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedTickers, tickers)
+		})
+	}
+}
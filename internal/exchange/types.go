@@ -12,6 +12,7 @@ const (
 	BYBIT
 	BITGET
 	KRAKEN
+	UNISWAP
 )
 
 var names = [...]string{
@@ -19,6 +20,7 @@ var names = [...]string{
 	BYBIT:   "bybit",
 	BITGET:  "bitget",
 	KRAKEN:  "kraken",
+	UNISWAP: "uniswap",
 }
 
 // String returns exchange name
@@ -26,11 +28,28 @@ func (n Name) String() string {
 	return names[n]
 }
 
+// ParseName returns the exchange named s
+func ParseName(s string) (Name, error) {
+	for n, name := range names {
+		if name == s {
+			return Name(n), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown exchange %q", s)
+}
+
 // Exchange represents a cryptocurrency exchange with its configuration
 type Exchange struct {
 	Name      Name
 	BaseURL   string
+	Mirrors   []string // alternate base URLs, tried when BaseURL fails
 	PricePath string
+	StreamURL string
+	RateLimit float64 // price calls per second, unlimited when zero
+	Burst     int
+	Headers   map[string]string // sent with every price call
+	Signer    *Signer           // signs calls to the private API, if set
 }
 
 // BinanceResponse represents Binance API response
@@ -76,12 +95,94 @@ type KrakenResponse struct {
 	} `json:"result"`
 }
 
+// BinanceAccountResponse represents Binance account information response
+type BinanceAccountResponse struct {
+	Balances []struct {
+		Asset  string `json:"asset"`
+		Free   string `json:"free"`
+		Locked string `json:"locked"`
+	} `json:"balances"`
+}
+
+// BybitWalletResponse represents Bybit wallet balance response
+type BybitWalletResponse struct {
+	RetCode int    `json:"retCode"`
+	RetMsg  string `json:"retMsg"`
+	Result  struct {
+		List []struct {
+			Coin []struct {
+				Coin          string `json:"coin"`
+				WalletBalance string `json:"walletBalance"`
+				Locked        string `json:"locked"`
+			} `json:"coin"`
+		} `json:"list"`
+	} `json:"result"`
+}
+
+// BitgetAssetsResponse represents Bitget spot account assets response
+type BitgetAssetsResponse struct {
+	Code string `json:"code"`
+	Msg  string `json:"msg"`
+	Data []struct {
+		Coin      string `json:"coin"`
+		Available string `json:"available"`
+		Frozen    string `json:"frozen"`
+		Locked    string `json:"locked"`
+	} `json:"data"`
+}
+
+// BinanceMiniTicker represents Binance miniTicker stream event
+type BinanceMiniTicker struct {
+	Event  string `json:"e"`
+	Time   int64  `json:"E"`
+	Symbol string `json:"s"`
+	Close  string `json:"c"`
+}
+
+// BybitTickerMessage represents Bybit tickers stream message
+type BybitTickerMessage struct {
+	Op      string `json:"op"`
+	Success *bool  `json:"success"`
+	RetMsg  string `json:"ret_msg"`
+	Topic   string `json:"topic"`
+	Ts      int64  `json:"ts"`
+	Data    struct {
+		Symbol    string `json:"symbol"`
+		LastPrice string `json:"lastPrice"`
+	} `json:"data"`
+}
+
+// BitgetTickerMessage represents Bitget ticker channel message
+type BitgetTickerMessage struct {
+	Event  string `json:"event"`
+	Code   any    `json:"code"`
+	Msg    string `json:"msg"`
+	Action string `json:"action"`
+	Data   []struct {
+		InstID string `json:"instId"`
+		LastPr string `json:"lastPr"`
+		Ts     string `json:"ts"`
+	} `json:"data"`
+}
+
 func baseURLs() map[Name]string {
 	return map[Name]string{
 		BINANCE: "https://api.binance.com",
 		BYBIT:   "https://api.bybit.com",
 		BITGET:  "https://api.bitget.com",
 		KRAKEN:  "https://api.kraken.com",
+		// Uniswap v3 subgraph on Ethereum, queried with an API key of The
+		// Graph in an Authorization header
+		UNISWAP: "https://gateway.thegraph.com/api/subgraphs/id/5zvR82QoaXYFyDEKLZ9t6v9adgnptxYpKpSbxtgVENFV",
+	}
+}
+
+// mirrorURLs returns alternate base URLs of the public APIs. Exchanges
+// without an entry have no mirrors.
+func mirrorURLs() map[Name][]string {
+	return map[Name][]string{
+		BINANCE: {"https://api1.binance.com", "https://api2.binance.com", "https://api3.binance.com", "https://api4.binance.com"},
+		BYBIT:   {"https://api.bytick.com"},
 	}
 }
 
@@ -94,23 +195,177 @@ func pricePaths() map[Name]string {
 	}
 }
 
+// assetPaths returns endpoints describing an asset, formatted with the
+// symbol of the asset on the exchange. Exchanges describing pairs only are
+// asked for the pair of the asset in USDT. Exchanges without an entry have
+// no asset support.
+func assetPaths() map[Name]string {
+	return map[Name]string{
+		BINANCE: "api/v3/exchangeInfo?symbol=%sUSDT",
+		BYBIT:   "v5/market/instruments-info?category=spot&symbol=%sUSDT",
+		BITGET:  "api/v2/spot/public/symbols?symbol=%sUSDT",
+		KRAKEN:  "0/public/Assets?asset=%s",
+	}
+}
+
+// assetCosts returns the price calls a call of the asset description counts
+// as against rateLimits, from the weights exchanges document: 20 of
+// exchangeInfo on Binance against 2 of a price call. Exchanges without an
+// entry count it as a single call.
+func assetCosts() map[Name]int {
+	return map[Name]int{
+		BINANCE: 10,
+	}
+}
+
+// tickersPaths returns endpoints of the 24h tickers of all spot pairs.
+// Exchanges without an entry have no tickers support.
+func tickersPaths() map[Name]string {
+	return map[Name]string{
+		BINANCE: "api/v3/ticker/24hr?type=MINI",
+		BYBIT:   "v5/market/tickers?category=spot",
+		BITGET:  "api/v2/spot/market/tickers",
+		KRAKEN:  "0/public/Ticker",
+	}
+}
+
+// tickersCosts returns the price calls a call of the tickers of all pairs
+// counts as against rateLimits, from the weights exchanges document: 80 on
+// Binance against 2 of a price call. Exchanges without an entry count it as
+// a single call.
+func tickersCosts() map[Name]int {
+	return map[Name]int{
+		BINANCE: 40,
+	}
+}
+
+// balancePaths returns spot balance endpoints of the private APIs.
+// Exchanges without an entry have no balance support.
+func balancePaths() map[Name]string {
+	return map[Name]string{
+		BINANCE: "api/v3/account?omitZeroBalances=true",
+		BYBIT:   "v5/account/wallet-balance?accountType=UNIFIED",
+		BITGET:  "api/v2/spot/account/assets",
+	}
+}
+
+// streamURLs returns public ticker WebSocket endpoints. Exchanges without
+// an entry are only reachable over REST.
+func streamURLs() map[Name]string {
+	return map[Name]string{
+		BINANCE: "wss://stream.binance.com:9443/ws",
+		BYBIT:   "wss://stream.bybit.com/v5/public/spot",
+		BITGET:  "wss://ws.bitget.com/v2/ws/public",
+	}
+}
+
+// rateLimits returns price calls per second and bursts allowed per IP, at
+// about half the public limits of each exchange: Binance 6000 weight per
+// minute at weight 2 per call, Bybit 600 calls per 5s, Bitget 20 calls
+// per second, Kraken about 1 call per second and The Graph about 10 queries
+// per second.
+func rateLimits() map[Name]rateLimit {
+	return map[Name]rateLimit{
+		BINANCE: {perSecond: 25, burst: 50},
+		BYBIT:   {perSecond: 50, burst: 50},
+		BITGET:  {perSecond: 10, burst: 10},
+		KRAKEN:  {perSecond: 0.5, burst: 3},
+		UNISWAP: {perSecond: 5, burst: 10},
+	}
+}
+
+type rateLimit struct {
+	perSecond float64
+	burst     int
+}
+
 // New creates a new Exchange instance with default configuration
 func New(name Name) *Exchange {
 	return &Exchange{
 		Name:      name,
 		BaseURL:   baseURLs()[name],
+		Mirrors:   mirrorURLs()[name],
 		PricePath: pricePaths()[name],
+		StreamURL: streamURLs()[name],
+		RateLimit: rateLimits()[name].perSecond,
+		Burst:     rateLimits()[name].burst,
 	}
 }
 
+// TickersURL returns complete URL for the 24h tickers of all pairs, empty
+// for exchanges without tickers support
+func (e *Exchange) TickersURL() string {
+	path, ok := tickersPaths()[e.Name]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s", e.BaseURL, path)
+}
+
+// TickersCost returns the price calls a call of TickersURL counts as against
+// RateLimit
+func (e *Exchange) TickersCost() int {
+	if cost, ok := tickersCosts()[e.Name]; ok {
+		return cost
+	}
+	return 1
+}
+
+// AssetCost returns the price calls a call of AssetURL counts as against
+// RateLimit
+func (e *Exchange) AssetCost() int {
+	if cost, ok := assetCosts()[e.Name]; ok {
+		return cost
+	}
+	return 1
+}
+
+// AssetURL returns complete URL for the description of asset, e.g. BTC,
+// empty for exchanges without asset support
+func (e *Exchange) AssetURL(asset string) string {
+	path, ok := assetPaths()[e.Name]
+	if !ok {
+		return ""
+	}
+	if alias, ok := symbolAliases[e.Name][asset]; ok {
+		asset = alias
+	}
+	return fmt.Sprintf("%s/"+path, e.BaseURL, asset)
+}
+
+// BalanceURL returns complete URL for spot balance request, empty for
+// exchanges without balance support
+func (e *Exchange) BalanceURL() string {
+	path, ok := balancePaths()[e.Name]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s", e.BaseURL, path)
+}
+
+// BaseURLs returns the base URL followed by the mirrors of the exchange
+func (e *Exchange) BaseURLs() []string {
+	return append([]string{e.BaseURL}, e.Mirrors...)
+}
+
 // PriceURL returns complete URL for price request
 func (e *Exchange) PriceURL(pair string) string {
+	return e.PriceURLAt(e.BaseURL, pair)
+}
+
+// PriceURLAt returns complete URL for price request to the API at base,
+// e.g. a mirror, naming pair in the symbols of the exchange. Subgraph
+// queries are posted to base.
+func (e *Exchange) PriceURLAt(base, pair string) string {
+	pair = Symbol(e.Name, pair)
 	switch e.Name {
 	case BYBIT:
-		return fmt.Sprintf("%s/%s?category=spot&symbol=%s", e.BaseURL, e.PricePath, pair)
+		return fmt.Sprintf("%s/%s?category=spot&symbol=%s", base, e.PricePath, pair)
 	case KRAKEN:
-		return fmt.Sprintf("%s/%s?pair=%s", e.BaseURL, e.PricePath, pair)
+		return fmt.Sprintf("%s/%s?pair=%s", base, e.PricePath, pair)
+	case UNISWAP:
+		return base
 	default:
-		return fmt.Sprintf("%s/%s?symbol=%s", e.BaseURL, e.PricePath, pair)
+		return fmt.Sprintf("%s/%s?symbol=%s", base, e.PricePath, pair)
 	}
 }
@@ -38,30 +38,38 @@ func TestName_String(t *testing.T) {
 
 func TestNew(t *testing.T) {
 	tests := []struct {
-		name         Name
-		expectedURL  string
-		expectedPath string
+		name              Name
+		expectedURL       string
+		expectedPath      string
+		expectedStreamURL string
 	}{
 		{
-			name:         BINANCE,
-			expectedURL:  "https://api.binance.com",
-			expectedPath: "api/v3/ticker/price",
+			name:              BINANCE,
+			expectedURL:       "https://api.binance.com",
+			expectedPath:      "api/v3/ticker/price",
+			expectedStreamURL: "wss://stream.binance.com:9443/ws",
 		},
 		{
-			name:         BYBIT,
-			expectedURL:  "https://api.bybit.com",
-			expectedPath: "v5/market/tickers",
+			name:              BYBIT,
+			expectedURL:       "https://api.bybit.com",
+			expectedPath:      "v5/market/tickers",
+			expectedStreamURL: "wss://stream.bybit.com/v5/public/spot",
 		},
 		{
-			name:         BITGET,
-			expectedURL:  "https://api.bitget.com",
-			expectedPath: "api/v2/spot/market/tickers",
+			name:              BITGET,
+			expectedURL:       "https://api.bitget.com",
+			expectedPath:      "api/v2/spot/market/tickers",
+			expectedStreamURL: "wss://ws.bitget.com/v2/ws/public",
 		},
 		{
 			name:         KRAKEN,
 			expectedURL:  "https://api.kraken.com",
 			expectedPath: "0/public/Ticker",
 		},
+		{
+			name:        UNISWAP,
+			expectedURL: "https://gateway.thegraph.com/api/subgraphs/id/5zvR82QoaXYFyDEKLZ9t6v9adgnptxYpKpSbxtgVENFV",
+		},
 	}
 
 	for _, tt := range tests {
@@ -70,10 +78,51 @@ func TestNew(t *testing.T) {
 			assert.Equal(t, tt.name, e.Name)
 			assert.Equal(t, tt.expectedURL, e.BaseURL)
 			assert.Equal(t, tt.expectedPath, e.PricePath)
+			assert.Equal(t, tt.expectedStreamURL, e.StreamURL)
+			assert.Positive(t, e.RateLimit)
+			assert.Positive(t, e.Burst)
 		})
 	}
 }
 
+func TestExchange_BalanceURL(t *testing.T) {
+	assert.Equal(t, "https://api.binance.com/api/v3/account?omitZeroBalances=true", New(BINANCE).BalanceURL())
+	assert.Equal(t, "https://api.bybit.com/v5/account/wallet-balance?accountType=UNIFIED", New(BYBIT).BalanceURL())
+	assert.Equal(t, "https://api.bitget.com/api/v2/spot/account/assets", New(BITGET).BalanceURL())
+	assert.Empty(t, New(KRAKEN).BalanceURL())
+}
+
+func TestExchange_TickersCost(t *testing.T) {
+	assert.Equal(t, 40, New(BINANCE).TickersCost())
+	assert.Equal(t, 1, New(KRAKEN).TickersCost())
+
+	// The tickers call fits in the burst of every exchange
+	for _, n := range []Name{BINANCE, BYBIT, BITGET, KRAKEN} {
+		assert.LessOrEqual(t, New(n).TickersCost(), New(n).Burst, n.String())
+	}
+}
+
+func TestExchange_AssetCost(t *testing.T) {
+	assert.Equal(t, 10, New(BINANCE).AssetCost())
+	assert.Equal(t, 1, New(KRAKEN).AssetCost())
+
+	// The asset call fits in the burst of every exchange
+	for _, n := range []Name{BINANCE, BYBIT, BITGET, KRAKEN} {
+		assert.LessOrEqual(t, New(n).AssetCost(), New(n).Burst, n.String())
+	}
+}
+
+func TestParseName(t *testing.T) {
+	for _, n := range []Name{BINANCE, BYBIT, BITGET, KRAKEN, UNISWAP} {
+		got, err := ParseName(n.String())
+		assert.NoError(t, err)
+		assert.Equal(t, n, got)
+	}
+
+	_, err := ParseName("mtgox")
+	assert.Error(t, err)
+}
+
 func TestExchange_PriceURL(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -103,7 +152,13 @@ func TestExchange_PriceURL(t *testing.T) {
 			name:        "kraken price url",
 			exchange:    New(KRAKEN),
 			pair:        "BTCUSDT",
-			expectedURL: "https://api.kraken.com/0/public/Ticker?pair=BTCUSDT",
+			expectedURL: "https://api.kraken.com/0/public/Ticker?pair=XBTUSDT",
+		},
+		{
+			name:        "uniswap subgraph url",
+			exchange:    New(UNISWAP),
+			pair:        "PEPEETH",
+			expectedURL: "https://gateway.thegraph.com/api/subgraphs/id/5zvR82QoaXYFyDEKLZ9t6v9adgnptxYpKpSbxtgVENFV",
 		},
 	}
 
@@ -113,3 +168,17 @@ func TestExchange_PriceURL(t *testing.T) {
 		})
 	}
 }
+
+func TestExchange_BaseURLs(t *testing.T) {
+	assert.Equal(t, []string{
+		"https://api.binance.com",
+		"https://api1.binance.com",
+		"https://api2.binance.com",
+		"https://api3.binance.com",
+		"https://api4.binance.com",
+	}, New(BINANCE).BaseURLs())
+	assert.Equal(t, []string{"https://api.kraken.com"}, New(KRAKEN).BaseURLs())
+
+	e := New(BYBIT)
+	assert.Equal(t, "https://api.bytick.com/v5/market/tickers?category=spot&symbol=BTCUSDT", e.PriceURLAt(e.Mirrors[0], "BTCUSDT"))
+}
@@ -0,0 +1,90 @@
+package exchange
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// uniswapPoolQuery queries the tokens and prices of a pool of the Uniswap v3
+// subgraph
+const uniswapPoolQuery = `query($id: ID!) { pool(id: $id) { token0 { symbol } token1 { symbol } token0Price token1Price } }`
+
+// UniswapResponse represents a pool response of the Uniswap subgraph
+type UniswapResponse struct {
+	Data struct {
+		Pool *struct {
+			Token0      UniswapToken `json:"token0"`
+			Token1      UniswapToken `json:"token1"`
+			Token0Price string       `json:"token0Price"` // token0 per token1
+			Token1Price string       `json:"token1Price"` // token1 per token0
+		} `json:"pool"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// UniswapToken represents a token of a Uniswap pool
+type UniswapToken struct {
+	Symbol string `json:"symbol"`
+}
+
+// unwrapped are the symbols wrapped tokens stand for in pairs
+var unwrapped = map[string]string{
+	"WETH": "ETH",
+	"WBTC": "BTC",
+}
+
+// UniswapPoolQuery returns the body of a subgraph request for the pool id
+func UniswapPoolQuery(pool string) []byte {
+	body, _ := json.Marshal(map[string]any{ //nolint:errchkjson // strings always marshal
+		"query":     uniswapPoolQuery,
+		"variables": map[string]string{"id": strings.ToLower(pool)},
+	})
+
+	return body
+}
+
+// ParseUniswapPrice returns the price of pair in body, a pool response of the
+// Uniswap subgraph. The pair matches the symbols of the pool tokens in either
+// order, wrapped ETH and BTC standing for ETH and BTC, e.g. ETHUSDC for a
+// WETH/USDC pool. Subgraph errors are returned as *Error.
+func ParseUniswapPrice(body []byte, pair string) (float64, error) {
+	var r UniswapResponse
+	if err := json.Unmarshal(body, &r); err != nil {
+		return 0, fmt.Errorf("decode response: %w", err)
+	}
+
+	if len(r.Errors) > 0 {
+		return 0, &Error{Code: "subgraph", Msg: r.Errors[0].Message}
+	}
+
+	pool := r.Data.Pool
+	if pool == nil {
+		return 0, ErrEmpty
+	}
+
+	for _, base := range symbols(pool.Token0.Symbol) {
+		for _, quote := range symbols(pool.Token1.Symbol) {
+			switch pair {
+			case base + quote:
+				return parsePrice(pool.Token1Price)
+			case quote + base:
+				return parsePrice(pool.Token0Price)
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("pool trades %s/%s, not %s", pool.Token0.Symbol, pool.Token1.Symbol, pair)
+}
+
+// symbols returns the symbols a token matches in pairs
+func symbols(token string) []string {
+	token = strings.ToUpper(token)
+	if s, ok := unwrapped[token]; ok {
+		return []string{token, s}
+	}
+
+	return []string{token}
+}
@@ -0,0 +1,50 @@
+package exchange
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUniswapPoolQuery(t *testing.T) {
+	var req struct {
+		Query     string            `json:"query"`
+		Variables map[string]string `json:"variables"`
+	}
+	assert.NoError(t, json.Unmarshal(UniswapPoolQuery("0x11950D141EcB863F01007AdD7D1A342041227b58"), &req))
+	assert.Contains(t, req.Query, "pool(id: $id)")
+	assert.Equal(t, map[string]string{"id": "0x11950d141ecb863f01007add7d1a342041227b58"}, req.Variables)
+}
+
+func TestParseUniswapPrice(t *testing.T) {
+	const pool = `{"data":{"pool":{"token0":{"symbol":"PEPE"},"token1":{"symbol":"WETH"},"token0Price":"250000000","token1Price":"0.000000004"}}}`
+
+	tests := []struct {
+		name          string
+		body          string
+		pair          string
+		expectedPrice float64
+		expectedErr   string
+	}{
+		{name: "token0 in token1", body: pool, pair: "PEPEETH", expectedPrice: 0.000000004},
+		{name: "token1 in token0", body: pool, pair: "ETHPEPE", expectedPrice: 250000000},
+		{name: "wrapped symbol", body: pool, pair: "PEPEWETH", expectedPrice: 0.000000004},
+		{name: "other pair", body: pool, pair: "PEPEUSDT", expectedErr: "pool trades PEPE/WETH, not PEPEUSDT"},
+		{name: "unknown pool", body: `{"data":{"pool":null}}`, pair: "PEPEETH", expectedErr: "empty response"},
+		{name: "subgraph error", body: `{"errors":[{"message":"auth error: missing authorization header"}]}`, pair: "PEPEETH", expectedErr: "code=subgraph, msg=auth error: missing authorization header"},
+		{name: "invalid json", body: `{`, pair: "PEPEETH", expectedErr: "decode response: unexpected end of JSON input"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			price, err := ParseUniswapPrice([]byte(tt.body), tt.pair)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.InDelta(t, tt.expectedPrice, price, tt.expectedPrice*1e-9)
+		})
+	}
+}
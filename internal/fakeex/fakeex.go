@@ -0,0 +1,245 @@
+// Package fakeex provides a fake exchange serving Binance, Bybit, Bitget and
+// Kraken shaped price responses with controllable prices, delays and
+// failures, for end-to-end tests and local development without internet
+// access.
+package fakeex
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/exchange"
+)
+
+// Failure represents a failure mode of a fake exchange
+type Failure string
+
+// Failure modes
+const (
+	// None responds with the price
+	None Failure = ""
+	// Unavailable responds with 503 Service Unavailable
+	Unavailable Failure = "unavailable"
+	// RateLimited responds with 429 Too Many Requests and Retry-After
+	RateLimited Failure = "rate_limited"
+	// UnknownSymbol responds with the error of the exchange for unknown pairs
+	UnknownSymbol Failure = "unknown_symbol"
+	// Malformed responds with 200 OK and a body that is not JSON
+	Malformed Failure = "malformed"
+	// Hang does not respond until the request is canceled
+	Hang Failure = "hang"
+)
+
+// ParseFailure returns the failure mode named s
+func ParseFailure(s string) (Failure, error) {
+	switch f := Failure(s); f {
+	case None, Unavailable, RateLimited, UnknownSymbol, Malformed, Hang:
+		return f, nil
+	}
+
+	return "", fmt.Errorf("unknown failure %q", s)
+}
+
+// Server serves the price APIs of all exchanges at their paths, so a single
+// server stands in for any of them. Pairs without a price are unknown.
+type Server struct {
+	mu       sync.Mutex
+	prices   map[string]float64
+	delays   map[exchange.Name]time.Duration
+	failures map[exchange.Name]Failure
+	calls    map[exchange.Name]int
+	mux      *http.ServeMux
+}
+
+// New creates a fake exchange without prices
+func New() *Server {
+	s := &Server{
+		prices:   make(map[string]float64),
+		delays:   make(map[exchange.Name]time.Duration),
+		failures: make(map[exchange.Name]Failure),
+		calls:    make(map[exchange.Name]int),
+		mux:      http.NewServeMux(),
+	}
+
+	for _, name := range []exchange.Name{exchange.BINANCE, exchange.BYBIT, exchange.BITGET, exchange.KRAKEN} {
+		s.mux.HandleFunc("/"+exchange.New(name).PricePath, func(w http.ResponseWriter, r *http.Request) {
+			s.handlePrice(w, r, name)
+		})
+	}
+	s.mux.HandleFunc("/_fakeex/price", s.handleSetPrice)
+	s.mux.HandleFunc("/_fakeex/exchange", s.handleSetExchange)
+
+	return s
+}
+
+// SetPrice quotes pair at price on all exchanges
+func (s *Server) SetPrice(pair string, price float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prices[strings.ToUpper(pair)] = price
+}
+
+// SetDelay delays the responses of the exchange name by d
+func (s *Server) SetDelay(name exchange.Name, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delays[name] = d
+}
+
+// SetFailure makes the exchange name fail with f, None to recover
+func (s *Server) SetFailure(name exchange.Name, f Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[name] = f
+}
+
+// Calls returns the number of price calls to the exchange name
+func (s *Server) Calls(name exchange.Name) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[name]
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) handlePrice(w http.ResponseWriter, r *http.Request, name exchange.Name) {
+	pair := r.URL.Query().Get("symbol")
+	if name == exchange.KRAKEN {
+		pair = r.URL.Query().Get("pair")
+	}
+
+	s.mu.Lock()
+	s.calls[name]++
+	price, ok := s.prices[exchange.Pair(name, strings.ToUpper(pair))]
+	delay, failure := s.delays[name], s.failures[name]
+	s.mu.Unlock()
+
+	if failure == Hang {
+		<-r.Context().Done()
+		return
+	}
+	select {
+	case <-r.Context().Done():
+		return
+	case <-time.After(delay):
+	}
+
+	if !ok && failure == None {
+		failure = UnknownSymbol
+	}
+
+	switch failure {
+	case Unavailable:
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+	case RateLimited:
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+	case Malformed:
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"symbol":`))
+	case UnknownSymbol:
+		status, body := unknownSymbol(name)
+		writeJSON(w, status, body)
+	default:
+		writeJSON(w, http.StatusOK, priceResponse(name, pair, strconv.FormatFloat(price, 'f', -1, 64)))
+	}
+}
+
+// priceResponse returns the response of the exchange name quoting pair
+func priceResponse(name exchange.Name, pair, price string) any {
+	switch name {
+	case exchange.BYBIT:
+		return map[string]any{"retCode": 0, "retMsg": "OK", "result": map[string]any{
+			"category": "spot",
+			"list":     []map[string]string{{"symbol": pair, "lastPrice": price}},
+		}}
+	case exchange.BITGET:
+		return map[string]any{"code": "00000", "msg": "success", "data": []map[string]string{{"symbol": pair, "lastPr": price}}}
+	case exchange.KRAKEN:
+		return map[string]any{"error": []string{}, "result": map[string]any{pair: map[string][2]string{"c": {price, "0.01"}}}}
+	default:
+		return map[string]string{"symbol": pair, "price": price}
+	}
+}
+
+// unknownSymbol returns the status and the response of the exchange name to
+// a pair it does not list
+func unknownSymbol(name exchange.Name) (int, any) {
+	switch name {
+	case exchange.BYBIT:
+		return http.StatusOK, map[string]any{"retCode": 10001, "retMsg": "Not supported symbols", "result": map[string]any{}}
+	case exchange.BITGET:
+		return http.StatusBadRequest, map[string]string{"code": "40034", "msg": "Parameter does not exist"}
+	case exchange.KRAKEN:
+		return http.StatusOK, map[string][]string{"error": {"EQuery:Unknown asset pair"}}
+	default:
+		return http.StatusBadRequest, map[string]any{"code": -1121, "msg": "Invalid symbol."}
+	}
+}
+
+// handleSetPrice sets the price of a pair, e.g.
+// POST /_fakeex/price?pair=BTCUSDT&price=97000
+func (s *Server) handleSetPrice(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pair := r.URL.Query().Get("pair")
+	price, err := strconv.ParseFloat(r.URL.Query().Get("price"), 64)
+	if pair == "" || err != nil {
+		http.Error(w, "pair and price required", http.StatusBadRequest)
+		return
+	}
+
+	s.SetPrice(pair, price)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSetExchange sets the delay and the failure mode of an exchange, e.g.
+// POST /_fakeex/exchange?name=binance&delay=200ms&failure=unavailable
+func (s *Server) handleSetExchange(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	name, err := exchange.ParseName(q.Get("name"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if q.Has("delay") {
+		d, parseErr := time.ParseDuration(q.Get("delay"))
+		if parseErr != nil {
+			http.Error(w, "invalid delay", http.StatusBadRequest)
+			return
+		}
+		s.SetDelay(name, d)
+	}
+	if q.Has("failure") {
+		f, parseErr := ParseFailure(q.Get("failure"))
+		if parseErr != nil {
+			http.Error(w, parseErr.Error(), http.StatusBadRequest)
+			return
+		}
+		s.SetFailure(name, f)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
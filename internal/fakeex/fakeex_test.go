@@ -0,0 +1,124 @@
+package fakeex
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/ivanglie/coinmon/internal/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_Prices(t *testing.T) {
+	ex := New()
+	ex.SetPrice("BTCUSDT", 97000.5)
+	srv := httptest.NewServer(ex)
+	defer srv.Close()
+
+	tests := []struct {
+		name           exchange.Name
+		failure        Failure
+		pair           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: exchange.BINANCE, pair: "BTCUSDT", expectedStatus: http.StatusOK, expectedBody: `{"price":"97000.5","symbol":"BTCUSDT"}`},
+		{name: exchange.BYBIT, pair: "BTCUSDT", expectedStatus: http.StatusOK, expectedBody: `{"result":{"category":"spot","list":[{"lastPrice":"97000.5","symbol":"BTCUSDT"}]},"retCode":0,"retMsg":"OK"}`},
+		{name: exchange.BITGET, pair: "BTCUSDT", expectedStatus: http.StatusOK, expectedBody: `{"code":"00000","data":[{"lastPr":"97000.5","symbol":"BTCUSDT"}],"msg":"success"}`},
+		{name: exchange.KRAKEN, pair: "BTCUSDT", expectedStatus: http.StatusOK, expectedBody: `{"error":[],"result":{"XBTUSDT":{"c":["97000.5","0.01"]}}}`},
+		{name: exchange.BINANCE, pair: "NOPE", expectedStatus: http.StatusBadRequest, expectedBody: `{"code":-1121,"msg":"Invalid symbol."}`},
+		{name: exchange.KRAKEN, pair: "NOPE", expectedStatus: http.StatusOK, expectedBody: `{"error":["EQuery:Unknown asset pair"]}`},
+		{name: exchange.BYBIT, failure: Unavailable, pair: "BTCUSDT", expectedStatus: http.StatusServiceUnavailable, expectedBody: "Service Unavailable"},
+		{name: exchange.BITGET, failure: RateLimited, pair: "BTCUSDT", expectedStatus: http.StatusTooManyRequests, expectedBody: "Too Many Requests"},
+		{name: exchange.BINANCE, failure: Malformed, pair: "BTCUSDT", expectedStatus: http.StatusOK, expectedBody: `{"symbol":`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name.String()+"/"+tt.pair+"/"+string(tt.failure), func(t *testing.T) {
+			ex.SetFailure(tt.name, tt.failure)
+			defer ex.SetFailure(tt.name, None)
+
+			e := exchange.New(tt.name)
+			e.BaseURL = srv.URL
+			resp, err := http.Get(e.PriceURL(tt.pair))
+			assert.NoError(t, err)
+			defer func() { _ = resp.Body.Close() }()
+
+			body, _ := io.ReadAll(resp.Body)
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			if tt.expectedStatus == http.StatusOK && tt.failure != Malformed {
+				assert.JSONEq(t, tt.expectedBody, string(body))
+				return
+			}
+			assert.Contains(t, string(body), tt.expectedBody)
+		})
+	}
+}
+
+func TestServer_Control(t *testing.T) {
+	ex := New()
+	srv := httptest.NewServer(ex)
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/_fakeex/price?pair=ethusdt&price=3500", "", http.NoBody)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	_ = resp.Body.Close()
+	ex.mu.Lock()
+	assert.Equal(t, 3500.0, ex.prices["ETHUSDT"])
+	ex.mu.Unlock()
+
+	resp, err = http.Post(srv.URL+"/_fakeex/exchange?name=kraken&delay=150ms&failure=hang", "", http.NoBody)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	_ = resp.Body.Close()
+	ex.mu.Lock()
+	assert.Equal(t, 150*time.Millisecond, ex.delays[exchange.KRAKEN])
+	assert.Equal(t, Hang, ex.failures[exchange.KRAKEN])
+	ex.mu.Unlock()
+
+	resp, err = http.Post(srv.URL+"/_fakeex/exchange?name=kraken&failure=explode", "", http.NoBody)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	_ = resp.Body.Close()
+
+	resp, err = http.Get(srv.URL + "/_fakeex/price")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	_ = resp.Body.Close()
+}
+
+func TestServer_EndToEnd(t *testing.T) {
+	ex := New()
+	ex.SetPrice("BTCUSDT", 97000)
+	ex.SetFailure(exchange.BINANCE, Unavailable)
+	ex.SetFailure(exchange.BITGET, Hang)
+	ex.SetDelay(exchange.KRAKEN, time.Second)
+	srv := httptest.NewServer(ex)
+	defer srv.Close()
+
+	var opts []server.Option
+	for _, name := range []exchange.Name{exchange.BINANCE, exchange.BYBIT, exchange.BITGET, exchange.KRAKEN} {
+		opts = append(opts, server.WithExchangeBaseURL(name, srv.URL))
+	}
+	s := server.New("", opts...)
+
+	// Binance fails, Bitget hangs and Kraken is slow, so Bybit answers
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	price, source, err := s.Spot(ctx, "BTCUSDT")
+	assert.NoError(t, err)
+	assert.Equal(t, 97000.0, price)
+	assert.Equal(t, "bybit", source)
+	assert.Equal(t, 1, ex.Calls(exchange.BYBIT))
+
+	ex.SetFailure(exchange.BYBIT, UnknownSymbol)
+	ex.SetFailure(exchange.BITGET, None)
+	ex.SetDelay(exchange.KRAKEN, 0)
+	_, _, err = s.Spot(ctx, "NOPEUSDT")
+	assert.ErrorContains(t, err, "all exchanges failed")
+}
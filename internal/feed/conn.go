@@ -0,0 +1,194 @@
+package feed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/ivanglie/coinmon/pkg/log"
+)
+
+const (
+	pingInterval = 20 * time.Second
+	minBackoff   = time.Second
+	maxBackoff   = 30 * time.Second
+)
+
+// conn maintains a WebSocket connection to an exchange, reconnecting and
+// resubscribing to every requested pair when the connection drops
+type conn struct {
+	name    string
+	url     string
+	venue   venue
+	onQuote func(Quote)
+	client  *http.Client
+
+	mu    sync.Mutex
+	pairs map[string]bool
+	ws    *websocket.Conn
+}
+
+// add records pairs and subscribes to them if the connection is up
+func (c *conn) add(ctx context.Context, pairs []string) {
+	c.mu.Lock()
+	var added []string
+	for _, p := range pairs {
+		if !c.pairs[p] {
+			c.pairs[p] = true
+			added = append(added, p)
+		}
+	}
+	ws := c.ws
+	c.mu.Unlock()
+
+	if ws == nil || len(added) == 0 {
+		return
+	}
+
+	if err := c.write(ctx, ws, c.venue.subscribe(added)...); err != nil {
+		log.Error(fmt.Sprintf("Failed to subscribe to %s stream: %v", c.name, err))
+	}
+}
+
+func (c *conn) size() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.pairs)
+}
+
+// run keeps the connection up until ctx is canceled
+func (c *conn) run(ctx context.Context) {
+	backoff := minBackoff
+	for {
+		start := time.Now()
+		err := c.session(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// A long-lived session means the venue was healthy, start over
+		if time.Since(start) > maxBackoff {
+			backoff = minBackoff
+		}
+
+		log.Error(fmt.Sprintf("%s stream disconnected, reconnecting in %s: %v", c.name, backoff, err))
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+func (c *conn) session(ctx context.Context) error {
+	dctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	ws, _, err := websocket.Dial(dctx, c.url, &websocket.DialOptions{HTTPClient: c.client}) //nolint:bodyclose // body is closed by websocket.Dial
+	cancel()
+	if err != nil {
+		return fmt.Errorf("dial: %w", err)
+	}
+
+	defer func() { _ = ws.CloseNow() }()
+	ws.SetReadLimit(1 << 20)
+
+	c.mu.Lock()
+	c.ws = ws
+	pairs := make([]string, 0, len(c.pairs))
+	for p := range c.pairs {
+		pairs = append(pairs, p)
+	}
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		c.ws = nil
+		c.mu.Unlock()
+	}()
+
+	log.Info(fmt.Sprintf("Connected to %s stream, subscribing to %d pairs", c.name, len(pairs)))
+	if len(pairs) > 0 {
+		if err := c.write(ctx, ws, c.venue.subscribe(pairs)...); err != nil {
+			return fmt.Errorf("subscribe: %w", err)
+		}
+	}
+
+	sctx, scancel := context.WithCancel(ctx)
+	defer scancel()
+	go c.keepalive(sctx, ws)
+
+	for {
+		_, data, err := ws.Read(ctx)
+		if err != nil {
+			return fmt.Errorf("read: %w", err)
+		}
+
+		quotes, err := c.venue.parse(data)
+		if err != nil {
+			log.Error(fmt.Sprintf("Error from %s stream: %v", c.name, err))
+			continue
+		}
+
+		for _, q := range quotes {
+			c.onQuote(q)
+		}
+	}
+}
+
+func (c *conn) keepalive(ctx context.Context, ws *websocket.Conn) {
+	t := time.NewTicker(pingInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		var err error
+		if msg := c.venue.ping(); msg != nil {
+			err = c.write(ctx, ws, msg)
+		} else {
+			pctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			err = ws.Ping(pctx)
+			cancel()
+		}
+
+		if err != nil {
+			_ = ws.Close(websocket.StatusGoingAway, "keepalive failed")
+			return
+		}
+	}
+}
+
+// write sends messages as JSON, strings are sent verbatim
+func (c *conn) write(ctx context.Context, ws *websocket.Conn, msgs ...any) error {
+	for _, msg := range msgs {
+		b, ok := msg.(string)
+		var data []byte
+		if ok {
+			data = []byte(b)
+		} else {
+			var err error
+			if data, err = json.Marshal(msg); err != nil {
+				return fmt.Errorf("marshal message: %w", err)
+			}
+		}
+
+		wctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		err := ws.Write(wctx, websocket.MessageText, data)
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+// Package feed consumes exchange WebSocket ticker streams into an in-memory price table.
+package feed
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/ivanglie/coinmon/pkg/log"
+)
+
+// MaxPairs is the maximum number of pairs subscribed on every exchange
+const MaxPairs = 200
+
+// Feed keeps streamed quotes of subscribed pairs
+type Feed struct {
+	table    *Table
+	conns    []*conn
+	ctx      context.Context
+	onUpdate func(Quote)
+}
+
+// New creates a new feed for exchanges that support streaming
+func New(exchanges []*exchange.Exchange) *Feed {
+	f := &Feed{table: NewTable(), ctx: context.Background()}
+
+	for _, ex := range exchanges {
+		v, err := newVenue(ex.Name)
+		if err != nil || ex.StreamURL == "" {
+			continue
+		}
+
+		f.conns = append(f.conns, &conn{
+			name:    ex.Name.String(),
+			url:     ex.StreamURL,
+			venue:   v,
+			onQuote: f.set,
+			pairs:   make(map[string]bool),
+		})
+	}
+
+	return f
+}
+
+// OnUpdate registers fn to be called for every streamed quote. It must be
+// called before Start.
+func (f *Feed) OnUpdate(fn func(Quote)) {
+	f.onUpdate = fn
+}
+
+// SetHTTPClient makes streams connect with c, e.g. to reach exchanges
+// through proxies. It must be called before Start.
+func (f *Feed) SetHTTPClient(c *http.Client) {
+	for _, cn := range f.conns {
+		cn.client = c
+	}
+}
+
+// Start connects to every exchange stream until ctx is canceled
+func (f *Feed) Start(ctx context.Context) {
+	f.ctx = ctx
+	for _, c := range f.conns {
+		go c.run(ctx)
+	}
+}
+
+// Subscribe adds pairs to every exchange stream
+func (f *Feed) Subscribe(pairs ...string) {
+	normalized := make([]string, 0, len(pairs))
+	for _, p := range pairs {
+		normalized = append(normalized, strings.ToUpper(p))
+	}
+
+	for _, c := range f.conns {
+		if c.size()+len(normalized) > MaxPairs {
+			log.Debug("Subscription limit reached on " + c.name + " stream")
+			continue
+		}
+
+		c.add(f.ctx, normalized)
+	}
+}
+
+// Latest returns the most recent streamed quote of pair not older than maxAge
+func (f *Feed) Latest(pair string, maxAge time.Duration) (Quote, bool) {
+	return f.table.Latest(pair, maxAge)
+}
+
+func (f *Feed) set(q Quote) {
+	f.table.Set(q)
+	if f.onUpdate != nil {
+		f.onUpdate(q)
+	}
+}
@@ -0,0 +1,136 @@
+package feed
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBybit answers every subscription with a ticker message and drops the
+// first connection right after the subscription to exercise reconnects
+func fakeBybit(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var conns atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer func() { _ = c.CloseNow() }()
+
+		n := conns.Add(1)
+		for {
+			_, data, err := c.Read(r.Context())
+			if err != nil {
+				return
+			}
+
+			if n == 1 {
+				_ = c.Close(websocket.StatusGoingAway, "restart")
+				return
+			}
+
+			if !strings.Contains(string(data), "tickers.BTCUSDT") {
+				continue
+			}
+
+			msg := `{"topic":"tickers.BTCUSDT","ts":` + strconv.FormatInt(time.Now().UnixMilli(), 10) +
+				`,"data":{"symbol":"BTCUSDT","lastPrice":"99999.98"}}`
+			if err := c.Write(r.Context(), websocket.MessageText, []byte(msg)); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts, &conns
+}
+
+func TestFeed(t *testing.T) {
+	ts, conns := fakeBybit(t)
+
+	ex := exchange.New(exchange.BYBIT)
+	ex.StreamURL = "ws" + strings.TrimPrefix(ts.URL, "http")
+
+	f := New([]*exchange.Exchange{ex, exchange.New(exchange.KRAKEN)})
+	assert.Len(t, f.conns, 1, "exchanges without streams are skipped")
+
+	var updates atomic.Int32
+	f.OnUpdate(func(q Quote) {
+		if q.Pair == "BTCUSDT" {
+			updates.Add(1)
+		}
+	})
+	f.Subscribe("btcusdt")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	f.Start(ctx)
+
+	assert.Eventually(t, func() bool {
+		_, ok := f.Latest("BTCUSDT", time.Minute)
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+
+	q, _ := f.Latest("BTCUSDT", time.Minute)
+	assert.Equal(t, 99999.98, q.Price)
+	assert.Equal(t, "bybit", q.Source)
+	assert.GreaterOrEqual(t, conns.Load(), int32(2), "pairs must be resubscribed after reconnect")
+	assert.Positive(t, updates.Load())
+}
+
+// countingTransport counts requests made through it
+type countingTransport struct {
+	n atomic.Int32
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.n.Add(1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestFeed_SetHTTPClient(t *testing.T) {
+	ts, _ := fakeBybit(t)
+
+	ex := exchange.New(exchange.BYBIT)
+	ex.StreamURL = "ws" + strings.TrimPrefix(ts.URL, "http")
+
+	rt := &countingTransport{}
+	f := New([]*exchange.Exchange{ex})
+	f.SetHTTPClient(&http.Client{Transport: rt})
+	f.Subscribe("BTCUSDT")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	f.Start(ctx)
+
+	assert.Eventually(t, func() bool {
+		_, ok := f.Latest("BTCUSDT", time.Minute)
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Positive(t, rt.n.Load(), "streams must be dialed with the client")
+}
+
+func TestFeed_SubscribeLimit(t *testing.T) {
+	f := New([]*exchange.Exchange{exchange.New(exchange.BINANCE)})
+
+	pairs := make([]string, MaxPairs)
+	for i := range pairs {
+		pairs[i] = "PAIR" + strconv.Itoa(i)
+	}
+	f.Subscribe(pairs...)
+	f.Subscribe("BTCUSDT")
+
+	assert.Equal(t, MaxPairs, f.conns[0].size())
+	assert.False(t, f.conns[0].pairs["BTCUSDT"])
+}
@@ -0,0 +1,55 @@
+package feed
+
+import (
+	"sync"
+	"time"
+)
+
+// Quote represents the latest price of a pair on an exchange
+type Quote struct {
+	Pair   string
+	Price  float64
+	Source string
+	Time   time.Time
+}
+
+// Table keeps the latest quote of every pair per exchange
+type Table struct {
+	mu     sync.RWMutex
+	quotes map[string]map[string]Quote
+}
+
+// NewTable creates a new empty table
+func NewTable() *Table {
+	return &Table{quotes: make(map[string]map[string]Quote)}
+}
+
+// Set stores q as the latest quote of its pair and source
+func (t *Table) Set(q Quote) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.quotes[q.Pair] == nil {
+		t.quotes[q.Pair] = make(map[string]Quote)
+	}
+	t.quotes[q.Pair][q.Source] = q
+}
+
+// Latest returns the most recent quote of pair not older than maxAge
+func (t *Table) Latest(pair string, maxAge time.Duration) (Quote, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var latest Quote
+	for _, q := range t.quotes[pair] {
+		if q.Time.After(latest.Time) {
+			latest = q
+		}
+	}
+
+	if latest.Time.IsZero() || time.Since(latest.Time) > maxAge {
+		return Quote{}, false
+	}
+
+	return latest, true
+}
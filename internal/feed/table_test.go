@@ -0,0 +1,32 @@
+package feed
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTable(t *testing.T) {
+	now := time.Now()
+	tbl := NewTable()
+
+	_, ok := tbl.Latest("BTCUSDT", time.Minute)
+	assert.False(t, ok)
+
+	tbl.Set(Quote{Pair: "BTCUSDT", Price: 1, Source: "binance", Time: now.Add(-2 * time.Second)})
+	tbl.Set(Quote{Pair: "BTCUSDT", Price: 2, Source: "bybit", Time: now.Add(-time.Second)})
+	tbl.Set(Quote{Pair: "ETHUSDT", Price: 3, Source: "binance", Time: now.Add(-time.Hour)})
+
+	q, ok := tbl.Latest("BTCUSDT", time.Minute)
+	assert.True(t, ok)
+	assert.Equal(t, 2.0, q.Price)
+	assert.Equal(t, "bybit", q.Source)
+
+	_, ok = tbl.Latest("ETHUSDT", time.Minute)
+	assert.False(t, ok, "stale quote must not be returned")
+
+	tbl.Set(Quote{Pair: "BTCUSDT", Price: 4, Source: "binance", Time: now})
+	q, _ = tbl.Latest("BTCUSDT", time.Minute)
+	assert.Equal(t, 4.0, q.Price)
+}
@@ -0,0 +1,158 @@
+package feed
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/exchange"
+)
+
+// venue describes the WebSocket protocol of an exchange
+type venue interface {
+	// subscribe returns messages subscribing to pairs
+	subscribe(pairs []string) []any
+	// ping returns an application-level keepalive message, nil if not needed
+	ping() any
+	// parse returns quotes contained in a message
+	parse(data []byte) ([]Quote, error)
+}
+
+func newVenue(name exchange.Name) (venue, error) {
+	switch name {
+	case exchange.BINANCE:
+		return binance{}, nil
+	case exchange.BYBIT:
+		return bybit{}, nil
+	case exchange.BITGET:
+		return bitget{}, nil
+	}
+
+	return nil, fmt.Errorf("%s: streaming is not supported", name)
+}
+
+type binance struct{}
+
+func (binance) subscribe(pairs []string) []any {
+	params := make([]string, 0, len(pairs))
+	for _, p := range pairs {
+		params = append(params, strings.ToLower(p)+"@miniTicker")
+	}
+
+	return []any{map[string]any{"method": "SUBSCRIBE", "params": params, "id": time.Now().UnixNano()}}
+}
+
+func (binance) ping() any {
+	// Binance relies on protocol level pings
+	return nil
+}
+
+func (binance) parse(data []byte) ([]Quote, error) {
+	var m exchange.BinanceMiniTicker
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("decode message: %w", err)
+	}
+
+	// Subscription acknowledgements carry no event type
+	if m.Event != "24hrMiniTicker" {
+		return nil, nil
+	}
+
+	price, err := strconv.ParseFloat(m.Close, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parse price: %w", err)
+	}
+
+	return []Quote{{Pair: m.Symbol, Price: price, Source: exchange.BINANCE.String(), Time: time.UnixMilli(m.Time)}}, nil
+}
+
+type bybit struct{}
+
+func (bybit) subscribe(pairs []string) []any {
+	// Bybit accepts at most 10 topics per subscribe request
+	var msgs []any
+	for i := 0; i < len(pairs); i += 10 {
+		args := make([]string, 0, 10)
+		for _, p := range pairs[i:min(i+10, len(pairs))] {
+			args = append(args, "tickers."+p)
+		}
+		msgs = append(msgs, map[string]any{"op": "subscribe", "args": args})
+	}
+
+	return msgs
+}
+
+func (bybit) ping() any {
+	return map[string]string{"op": "ping"}
+}
+
+func (bybit) parse(data []byte) ([]Quote, error) {
+	var m exchange.BybitTickerMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("decode message: %w", err)
+	}
+
+	if m.Success != nil && !*m.Success {
+		return nil, fmt.Errorf("op=%s, msg=%s", m.Op, m.RetMsg)
+	}
+
+	if !strings.HasPrefix(m.Topic, "tickers.") || m.Data.LastPrice == "" {
+		return nil, nil
+	}
+
+	price, err := strconv.ParseFloat(m.Data.LastPrice, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parse price: %w", err)
+	}
+
+	return []Quote{{Pair: m.Data.Symbol, Price: price, Source: exchange.BYBIT.String(), Time: time.UnixMilli(m.Ts)}}, nil
+}
+
+type bitget struct{}
+
+func (bitget) subscribe(pairs []string) []any {
+	args := make([]map[string]string, 0, len(pairs))
+	for _, p := range pairs {
+		args = append(args, map[string]string{"instType": "SPOT", "channel": "ticker", "instId": p})
+	}
+
+	return []any{map[string]any{"op": "subscribe", "args": args}}
+}
+
+func (bitget) ping() any {
+	return "ping"
+}
+
+func (bitget) parse(data []byte) ([]Quote, error) {
+	if string(data) == "pong" {
+		return nil, nil
+	}
+
+	var m exchange.BitgetTickerMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("decode message: %w", err)
+	}
+
+	if m.Event == "error" {
+		return nil, fmt.Errorf("code=%v, msg=%s", m.Code, m.Msg)
+	}
+
+	quotes := make([]Quote, 0, len(m.Data))
+	for _, d := range m.Data {
+		price, err := strconv.ParseFloat(d.LastPr, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse price: %w", err)
+		}
+
+		ts, err := strconv.ParseInt(d.Ts, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse timestamp: %w", err)
+		}
+
+		quotes = append(quotes, Quote{Pair: d.InstID, Price: price, Source: exchange.BITGET.String(), Time: time.UnixMilli(ts)})
+	}
+
+	return quotes, nil
+}
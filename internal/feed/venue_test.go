@@ -0,0 +1,134 @@
+package feed
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewVenue(t *testing.T) {
+	for _, name := range []exchange.Name{exchange.BINANCE, exchange.BYBIT, exchange.BITGET} {
+		v, err := newVenue(name)
+		assert.NoError(t, err)
+		assert.NotNil(t, v)
+	}
+
+	_, err := newVenue(exchange.KRAKEN)
+	assert.EqualError(t, err, "kraken: streaming is not supported")
+}
+
+func TestVenue_subscribe(t *testing.T) {
+	b, err := json.Marshal(bybit{}.subscribe([]string{"BTCUSDT", "ETHUSDT"}))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[{"op":"subscribe","args":["tickers.BTCUSDT","tickers.ETHUSDT"]}]`, string(b))
+
+	pairs := make([]string, 25)
+	for i := range pairs {
+		pairs[i] = "BTCUSDT"
+	}
+	assert.Len(t, bybit{}.subscribe(pairs), 3)
+
+	b, err = json.Marshal(bitget{}.subscribe([]string{"BTCUSDT"}))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[{"op":"subscribe","args":[{"instType":"SPOT","channel":"ticker","instId":"BTCUSDT"}]}]`, string(b))
+
+	msgs := binance{}.subscribe([]string{"BTCUSDT"})
+	assert.Len(t, msgs, 1)
+	assert.Equal(t, []string{"btcusdt@miniTicker"}, msgs[0].(map[string]any)["params"])
+}
+
+func TestVenue_ping(t *testing.T) {
+	assert.Nil(t, binance{}.ping())
+	assert.Equal(t, map[string]string{"op": "ping"}, bybit{}.ping())
+	assert.Equal(t, "ping", bitget{}.ping())
+}
+
+func TestVenue_parse(t *testing.T) {
+	ts := time.UnixMilli(1735689600000)
+
+	tests := []struct {
+		name        string
+		venue       venue
+		data        string
+		expected    []Quote
+		expectError bool
+	}{
+		{
+			name:     "binance mini ticker",
+			venue:    binance{},
+			data:     `{"e":"24hrMiniTicker","E":1735689600000,"s":"BTCUSDT","c":"99999.99","o":"1","h":"1","l":"1"}`,
+			expected: []Quote{{Pair: "BTCUSDT", Price: 99999.99, Source: "binance", Time: ts}},
+		},
+		{
+			name:  "binance subscription ack",
+			venue: binance{},
+			data:  `{"result":null,"id":1}`,
+		},
+		{
+			name:        "binance invalid price",
+			venue:       binance{},
+			data:        `{"e":"24hrMiniTicker","E":1735689600000,"s":"BTCUSDT","c":"n/a"}`,
+			expectError: true,
+		},
+		{
+			name:     "bybit ticker",
+			venue:    bybit{},
+			data:     `{"topic":"tickers.BTCUSDT","ts":1735689600000,"type":"snapshot","data":{"symbol":"BTCUSDT","lastPrice":"99999.98"}}`,
+			expected: []Quote{{Pair: "BTCUSDT", Price: 99999.98, Source: "bybit", Time: ts}},
+		},
+		{
+			name:  "bybit pong",
+			venue: bybit{},
+			data:  `{"success":true,"ret_msg":"pong","op":"ping"}`,
+		},
+		{
+			name:        "bybit subscription error",
+			venue:       bybit{},
+			data:        `{"success":false,"ret_msg":"Invalid symbol :[tickers.INVALID]","op":"subscribe"}`,
+			expectError: true,
+		},
+		{
+			name:     "bitget ticker",
+			venue:    bitget{},
+			data:     `{"action":"snapshot","arg":{"instType":"SPOT","channel":"ticker","instId":"BTCUSDT"},"data":[{"instId":"BTCUSDT","lastPr":"99999.97","ts":"1735689600000"}]}`,
+			expected: []Quote{{Pair: "BTCUSDT", Price: 99999.97, Source: "bitget", Time: ts}},
+		},
+		{
+			name:  "bitget pong",
+			venue: bitget{},
+			data:  `pong`,
+		},
+		{
+			name:        "bitget error",
+			venue:       bitget{},
+			data:        `{"event":"error","code":30001,"msg":"instType:SPOT,channel:ticker,instId:INVALID doesn't exist"}`,
+			expectError: true,
+		},
+		{
+			name:        "malformed json",
+			venue:       bybit{},
+			data:        `{`,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quotes, err := tt.venue.parse([]byte(tt.data))
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			if tt.expected == nil {
+				assert.Empty(t, quotes)
+				return
+			}
+			assert.Equal(t, tt.expected, quotes)
+		})
+	}
+}
@@ -0,0 +1,215 @@
+// Package index computes composite prices of weighted baskets of exchange
+// quotes, e.g. a BTC index of the BTCUSDT prices of several exchanges.
+package index
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+)
+
+// DefaultMaxAge is the maximum age of a quote taken into an index
+const DefaultMaxAge = time.Minute
+
+const watchBuffer = 256
+
+var (
+	// ErrUnknown is returned for indices that are not configured
+	ErrUnknown = errors.New("unknown index")
+	// ErrNoQuotes is returned for indices none of whose components has a
+	// quote recent enough
+	ErrNoQuotes = errors.New("no recent quotes of the components")
+)
+
+// Component represents the price of a pair on an exchange weighted in an
+// index
+type Component struct {
+	Exchange string
+	Pair     string
+	Weight   float64
+}
+
+// Index represents a named weighted basket of quotes
+type Index struct {
+	Name       string
+	Components []Component
+}
+
+// Quote represents the quote of a component taken into the price of an
+// index
+type Quote struct {
+	Exchange string    `json:"exchange"`
+	Pair     string    `json:"pair"`
+	Weight   float64   `json:"weight"`
+	Price    float64   `json:"price"`
+	Time     time.Time `json:"time"`
+}
+
+// Value represents the price of an index and the quotes it is made of.
+// Time is the time of the oldest of them.
+type Value struct {
+	Name   string    `json:"name"`
+	Price  float64   `json:"price"`
+	Time   time.Time `json:"time"`
+	Quotes []Quote   `json:"quotes"`
+	// Missing are the components without a recent quote, whose weight is
+	// spread over the others
+	Missing []Component `json:"missing,omitempty"`
+}
+
+type quoteKey struct {
+	exchange string
+	pair     string
+}
+
+// Calculator keeps the latest quote of every component of its indices and
+// prices them from these. It is safe for concurrent use.
+type Calculator struct {
+	mu      sync.Mutex
+	indices map[string]Index
+	quotes  map[quoteKey]bus.PriceUpdated
+	maxAge  time.Duration
+	now     func() time.Time
+}
+
+// NewCalculator creates a calculator of indices taking quotes not older than
+// maxAge into them, DefaultMaxAge when zero
+func NewCalculator(indices []Index, maxAge time.Duration) *Calculator {
+	if maxAge <= 0 {
+		maxAge = DefaultMaxAge
+	}
+
+	c := &Calculator{
+		indices: make(map[string]Index, len(indices)),
+		quotes:  make(map[quoteKey]bus.PriceUpdated),
+		maxAge:  maxAge,
+		now:     time.Now,
+	}
+	for _, idx := range indices {
+		c.indices[idx.Name] = idx
+	}
+
+	return c
+}
+
+// Observe records e when it quotes a component of an index
+func (c *Calculator) Observe(e bus.PriceUpdated) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := quoteKey{exchange: e.Source, pair: e.Pair}
+	if !c.hasComponent(key) {
+		return
+	}
+	if last, ok := c.quotes[key]; !ok || !e.Time.Before(last.Time) {
+		c.quotes[key] = e
+	}
+}
+
+func (c *Calculator) hasComponent(key quoteKey) bool {
+	for _, idx := range c.indices {
+		for _, comp := range idx.Components {
+			if comp.Exchange == key.exchange && comp.Pair == key.pair {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Watch records every price event published to b until ctx is canceled
+func (c *Calculator) Watch(ctx context.Context, b *bus.Bus) {
+	events, unsubscribe := b.SubscribeAll(watchBuffer)
+
+	go func() {
+		defer unsubscribe()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e := <-events:
+				c.Observe(e)
+			}
+		}
+	}()
+}
+
+// Value returns the price of the index name, the weighted mean of the recent
+// quotes of its components
+func (c *Calculator) Value(name string) (Value, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	idx, ok := c.indices[name]
+	if !ok {
+		return Value{}, fmt.Errorf("%w %q", ErrUnknown, name)
+	}
+
+	v := Value{Name: name}
+	now := c.now()
+	var sum, weights float64
+	for _, comp := range idx.Components {
+		q, ok := c.quotes[quoteKey{exchange: comp.Exchange, pair: comp.Pair}]
+		if !ok || now.Sub(q.Time) > c.maxAge {
+			v.Missing = append(v.Missing, comp)
+			continue
+		}
+
+		sum += q.Price * comp.Weight
+		weights += comp.Weight
+		v.Quotes = append(v.Quotes, Quote{Exchange: comp.Exchange, Pair: comp.Pair, Weight: comp.Weight, Price: q.Price, Time: q.Time})
+		if v.Time.IsZero() || q.Time.Before(v.Time) {
+			v.Time = q.Time
+		}
+	}
+	if weights == 0 {
+		return Value{}, fmt.Errorf("%s: %w", name, ErrNoQuotes)
+	}
+	v.Price = sum / weights
+
+	return v, nil
+}
+
+// Names returns the names of the indices, sorted
+func (c *Calculator) Names() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	names := make([]string, 0, len(c.indices))
+	for name := range c.indices {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// Pairs returns the pairs of the components of all indices, sorted and
+// deduplicated
+func (c *Calculator) Pairs() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var pairs []string
+	for _, idx := range c.indices {
+		for _, comp := range idx.Components {
+			pairs = append(pairs, comp.Pair)
+		}
+	}
+	slices.Sort(pairs)
+
+	return slices.Compact(pairs)
+}
+
+// WantsAllSources reports whether pair is a component of an index, which
+// needs its quotes of every exchange
+func (c *Calculator) WantsAllSources(pair string) bool {
+	return slices.Contains(c.Pairs(), pair)
+}
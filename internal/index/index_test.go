@@ -0,0 +1,142 @@
+package index
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/stretchr/testify/assert"
+)
+
+var btcIndex = Index{Name: "BTC-INDEX", Components: []Component{
+	{Exchange: "binance", Pair: "BTCUSDT", Weight: 0.5},
+	{Exchange: "bybit", Pair: "BTCUSDT", Weight: 0.3},
+	{Exchange: "bitget", Pair: "BTCUSDT", Weight: 0.2},
+}}
+
+func TestCalculator_Value(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name            string
+		events          []bus.PriceUpdated
+		index           string
+		expectedPrice   float64
+		expectedTime    time.Time
+		expectedQuotes  int
+		expectedMissing []Component
+		expectedError   string
+	}{
+		{
+			name: "all components",
+			events: []bus.PriceUpdated{
+				{Pair: "BTCUSDT", Source: "binance", Price: 100000, Time: now.Add(-time.Second)},
+				{Pair: "BTCUSDT", Source: "bybit", Price: 100100, Time: now.Add(-2 * time.Second)},
+				{Pair: "BTCUSDT", Source: "bitget", Price: 99900, Time: now},
+			},
+			index:          "BTC-INDEX",
+			expectedPrice:  100010,
+			expectedTime:   now.Add(-2 * time.Second),
+			expectedQuotes: 3,
+		},
+		{
+			name: "missing component reweighted",
+			events: []bus.PriceUpdated{
+				{Pair: "BTCUSDT", Source: "binance", Price: 100000, Time: now},
+				{Pair: "BTCUSDT", Source: "bybit", Price: 100080, Time: now},
+				{Pair: "BTCUSDT", Source: "bitget", Price: 90000, Time: now.Add(-2 * time.Minute)},
+			},
+			index:           "BTC-INDEX",
+			expectedPrice:   100030,
+			expectedTime:    now,
+			expectedQuotes:  2,
+			expectedMissing: []Component{{Exchange: "bitget", Pair: "BTCUSDT", Weight: 0.2}},
+		},
+		{
+			name: "other pairs and exchanges ignored",
+			events: []bus.PriceUpdated{
+				{Pair: "BTCUSDT", Source: "binance", Price: 100000, Time: now},
+				{Pair: "ETHUSDT", Source: "bybit", Price: 4000, Time: now},
+				{Pair: "BTCUSDT", Source: "kraken", Price: 1, Time: now},
+			},
+			index:           "BTC-INDEX",
+			expectedPrice:   100000,
+			expectedTime:    now,
+			expectedQuotes:  1,
+			expectedMissing: btcIndex.Components[1:],
+		},
+		{
+			name: "older quote does not replace newer",
+			events: []bus.PriceUpdated{
+				{Pair: "BTCUSDT", Source: "binance", Price: 100000, Time: now},
+				{Pair: "BTCUSDT", Source: "binance", Price: 1, Time: now.Add(-time.Second)},
+			},
+			index:           "BTC-INDEX",
+			expectedPrice:   100000,
+			expectedTime:    now,
+			expectedQuotes:  1,
+			expectedMissing: btcIndex.Components[1:],
+		},
+		{
+			name:          "no quotes",
+			index:         "BTC-INDEX",
+			expectedError: "BTC-INDEX: no recent quotes of the components",
+		},
+		{
+			name:          "unknown index",
+			index:         "ETH-INDEX",
+			expectedError: `unknown index "ETH-INDEX"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCalculator([]Index{btcIndex}, 0)
+			c.now = func() time.Time { return now }
+			for _, e := range tt.events {
+				c.Observe(e)
+			}
+
+			v, err := c.Value(tt.index)
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.index, v.Name)
+			assert.InDelta(t, tt.expectedPrice, v.Price, 1e-6)
+			assert.Equal(t, tt.expectedTime, v.Time)
+			assert.Len(t, v.Quotes, tt.expectedQuotes)
+			assert.Equal(t, tt.expectedMissing, v.Missing)
+		})
+	}
+}
+
+func TestCalculator_Pairs(t *testing.T) {
+	c := NewCalculator([]Index{
+		btcIndex,
+		{Name: "ETH-INDEX", Components: []Component{{Exchange: "kraken", Pair: "ETHUSDT", Weight: 1}}},
+	}, 0)
+
+	assert.Equal(t, []string{"BTCUSDT", "ETHUSDT"}, c.Pairs())
+	assert.Equal(t, []string{"BTC-INDEX", "ETH-INDEX"}, c.Names())
+	assert.True(t, c.WantsAllSources("ETHUSDT"))
+	assert.False(t, c.WantsAllSources("SOLUSDT"))
+}
+
+func TestCalculator_Watch(t *testing.T) {
+	b := bus.New()
+	c := NewCalculator([]Index{btcIndex}, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.Watch(ctx, b)
+
+	b.Publish(bus.PriceUpdated{Pair: "BTCUSDT", Source: "bybit", Price: 100000, Time: time.Now()})
+	assert.Eventually(t, func() bool {
+		v, err := c.Value("BTC-INDEX")
+		return err == nil && v.Price == 100000
+	}, time.Second, 10*time.Millisecond)
+}
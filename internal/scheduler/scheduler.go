@@ -0,0 +1,140 @@
+// Package scheduler runs periodic background jobs.
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/ivanglie/coinmon/pkg/log"
+)
+
+// Job represents a periodic job
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Jitter   time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Status represents job run status
+type Status struct {
+	Name         string    `json:"name"`
+	Interval     string    `json:"interval"`
+	Jitter       string    `json:"jitter"`
+	Runs         int       `json:"runs"`
+	LastRun      time.Time `json:"last_run,omitzero"`
+	LastDuration string    `json:"last_duration,omitempty"`
+	LastError    string    `json:"last_error,omitempty"`
+	NextRun      time.Time `json:"next_run,omitzero"`
+}
+
+type entry struct {
+	job    Job
+	status Status
+}
+
+// Scheduler runs jobs at their intervals with random jitter
+type Scheduler struct {
+	mu      sync.Mutex
+	entries []*entry
+}
+
+// New creates a new scheduler
+func New() *Scheduler {
+	return &Scheduler{}
+}
+
+// Add registers a job. Jobs added after Start are not run, and jobs without
+// a positive interval are rejected rather than run back to back.
+func (s *Scheduler) Add(j Job) error {
+	if j.Interval <= 0 {
+		return fmt.Errorf("job %s: interval must be positive, got %s", j.Name, j.Interval)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = append(s.entries, &entry{
+		job: j,
+		status: Status{
+			Name:     j.Name,
+			Interval: j.Interval.String(),
+			Jitter:   j.Jitter.String(),
+		},
+	})
+
+	return nil
+}
+
+// Start runs every registered job in its own goroutine until ctx is canceled
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range s.entries {
+		go s.loop(ctx, e)
+	}
+}
+
+// Status returns status of every registered job
+func (s *Scheduler) Status() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]Status, 0, len(s.entries))
+	for _, e := range s.entries {
+		statuses = append(statuses, e.status)
+	}
+
+	return statuses
+}
+
+func (s *Scheduler) loop(ctx context.Context, e *entry) {
+	// The first run is spread within jitter so jobs don't start all at once
+	delay := jitter(e.job.Jitter)
+	for {
+		s.mu.Lock()
+		e.status.NextRun = time.Now().Add(delay)
+		s.mu.Unlock()
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+
+		s.run(ctx, e)
+		delay = e.job.Interval + jitter(e.job.Jitter)
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, e *entry) {
+	start := time.Now()
+	err := e.job.Run(ctx)
+	d := time.Since(start)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e.status.Runs++
+	e.status.LastRun = start
+	e.status.LastDuration = d.String()
+	e.status.LastError = ""
+	if err != nil {
+		e.status.LastError = err.Error()
+		log.Error(fmt.Sprintf("Job %s failed: %v", e.job.Name, err))
+	}
+}
+
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+
+	return rand.N(d) //nolint:gosec // jitter doesn't need a secure random source
+}
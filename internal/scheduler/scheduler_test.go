@@ -0,0 +1,96 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduler(t *testing.T) {
+	var okRuns, failRuns atomic.Int32
+
+	s := New()
+	assert.NoError(t, s.Add(Job{
+		Name:     "ok",
+		Interval: 10 * time.Millisecond,
+		Run: func(_ context.Context) error {
+			okRuns.Add(1)
+			return nil
+		},
+	}))
+	assert.NoError(t, s.Add(Job{
+		Name:     "fail",
+		Interval: 10 * time.Millisecond,
+		Jitter:   5 * time.Millisecond,
+		Run: func(_ context.Context) error {
+			failRuns.Add(1)
+			return fmt.Errorf("exchange down")
+		},
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Start(ctx)
+
+	assert.Eventually(t, func() bool {
+		return okRuns.Load() >= 3 && failRuns.Load() >= 3
+	}, time.Second, 5*time.Millisecond)
+	cancel()
+
+	statuses := s.Status()
+	assert.Len(t, statuses, 2)
+
+	assert.Equal(t, "ok", statuses[0].Name)
+	assert.Equal(t, "10ms", statuses[0].Interval)
+	assert.GreaterOrEqual(t, statuses[0].Runs, 3)
+	assert.False(t, statuses[0].LastRun.IsZero())
+	assert.False(t, statuses[0].NextRun.IsZero())
+	assert.Empty(t, statuses[0].LastError)
+
+	assert.Equal(t, "fail", statuses[1].Name)
+	assert.Equal(t, "exchange down", statuses[1].LastError)
+}
+
+func TestScheduler_Stop(t *testing.T) {
+	var runs atomic.Int32
+
+	s := New()
+	assert.NoError(t, s.Add(Job{
+		Name:     "job",
+		Interval: time.Hour,
+		Run: func(_ context.Context) error {
+			runs.Add(1)
+			return nil
+		},
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Start(ctx)
+
+	assert.Eventually(t, func() bool { return runs.Load() == 1 }, time.Second, 5*time.Millisecond)
+	cancel()
+	assert.Equal(t, int32(1), runs.Load())
+}
+
+func TestScheduler_Add(t *testing.T) {
+	s := New()
+	for _, interval := range []time.Duration{0, -time.Second} {
+		err := s.Add(Job{Name: "prewarm", Interval: interval, Run: func(context.Context) error { return nil }})
+		assert.ErrorContains(t, err, "job prewarm: interval must be positive")
+	}
+	assert.Empty(t, s.Status())
+}
+
+func TestJitter(t *testing.T) {
+	assert.Zero(t, jitter(0))
+	assert.Zero(t, jitter(-time.Second))
+
+	for range 100 {
+		d := jitter(time.Second)
+		assert.GreaterOrEqual(t, d, time.Duration(0))
+		assert.Less(t, d, time.Second)
+	}
+}
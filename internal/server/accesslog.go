@@ -0,0 +1,212 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ivanglie/coinmon/pkg/log"
+)
+
+type accessKey struct{}
+
+// accessEntry collects details of a request known only to its handler
+type accessEntry struct {
+	mu       sync.Mutex
+	sources  []string
+	pairs    []string
+	consumer string
+}
+
+// accessWriter records the status and size of a response
+type accessWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *accessWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *accessWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// Flush sends buffered data to the client. gRPC requires writers to
+// implement http.Flusher.
+func (w *accessWriter) Flush() {
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController and websocket upgrades reach the
+// underlying writer
+func (w *accessWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// statusCode returns the status of the response to r
+func (w *accessWriter) statusCode(r *http.Request) int {
+	if w.status != 0 {
+		return w.status
+	}
+
+	// Hijacked connections, e.g. WebSocket upgrades, write no header here
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return http.StatusSwitchingProtocols
+	}
+	return http.StatusOK
+}
+
+// accessLog logs every request with the exchanges its prices came from
+func (s *Server) accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		entry := &accessEntry{}
+		aw := &accessWriter{ResponseWriter: w}
+
+		id := requestID(r)
+		w.Header().Set("X-Request-Id", id)
+		ctx := log.WithFields(r.Context(), log.Fields{"request_id": id})
+
+		next.ServeHTTP(aw, r.WithContext(context.WithValue(ctx, accessKey{}, entry)))
+
+		fields := log.Fields{
+			"method":      r.Method,
+			"path":        r.URL.Path,
+			"status":      aw.statusCode(r),
+			"bytes":       aw.bytes,
+			"duration_ms": float64(time.Since(start).Microseconds()) / 1000,
+			"ip":          clientIP(r),
+		}
+		if sources := entry.get(); len(sources) > 0 {
+			fields["exchange"] = strings.Join(sources, ",")
+		}
+		if consumer, _ := entry.requester(); consumer != "" {
+			fields["consumer"] = consumer
+		}
+		fields["request_id"] = id
+		log.InfoFields("Request", fields)
+	})
+}
+
+// noteSource records the exchange a price of the request came from
+func noteSource(ctx context.Context, source string) {
+	entry, ok := ctx.Value(accessKey{}).(*accessEntry)
+	if !ok {
+		return
+	}
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if !slices.Contains(entry.sources, source) {
+		entry.sources = append(entry.sources, source)
+	}
+}
+
+// notePair records a pair the request asked the price of
+func notePair(ctx context.Context, pair string) {
+	entry, ok := ctx.Value(accessKey{}).(*accessEntry)
+	if !ok {
+		return
+	}
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if !slices.Contains(entry.pairs, pair) {
+		entry.pairs = append(entry.pairs, pair)
+	}
+}
+
+// noteConsumer records the name of the API key of the request
+func noteConsumer(ctx context.Context, name string) {
+	entry, ok := ctx.Value(accessKey{}).(*accessEntry)
+	if !ok {
+		return
+	}
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	entry.consumer = name
+}
+
+func (e *accessEntry) get() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.sources
+}
+
+// requester returns the API key name and the pairs of the request
+func (e *accessEntry) requester() (consumer string, pairs []string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.consumer, slices.Clone(e.pairs)
+}
+
+// clientIP returns the address of the client, as seen by Cloudflare when
+// the request came through it
+func clientIP(r *http.Request) string {
+	if ip := r.Header.Get("Cf-Connecting-Ip"); ip != "" {
+		return ip
+	}
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		// Connections on a Unix socket come from the proxy in front, which
+		// sets X-Real-Ip or appends the client address to X-Forwarded-For
+		if ip := r.Header.Get("X-Real-Ip"); ip != "" {
+			return ip
+		}
+		forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+		if ip := strings.TrimSpace(forwarded[len(forwarded)-1]); ip != "" {
+			return ip
+		}
+
+		// Without either, connections are told apart rather than sharing
+		// the limits of a single client
+		if id, ok := r.Context().Value(connKey{}).(uint64); ok {
+			return "unix:" + strconv.FormatUint(id, 10)
+		}
+	}
+
+	return ip
+}
+
+// connKey is the context key of the id of the connection of a request
+type connKey struct{}
+
+// connIDs numbers the connections of the server
+var connIDs atomic.Uint64
+
+// connContext returns ctx with the id of a new connection
+func connContext(ctx context.Context, _ net.Conn) context.Context {
+	return context.WithValue(ctx, connKey{}, connIDs.Add(1))
+}
+
+// requestID returns the id a proxy in front assigned to the request or a
+// new random one
+func requestID(r *http.Request) string {
+	if id := r.Header.Get("X-Request-Id"); id != "" && len(id) <= 128 {
+		return id
+	}
+
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/ivanglie/coinmon/pkg/log"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_accessLog(t *testing.T) {
+	tests := []struct {
+		name             string
+		path             string
+		header           map[string]string
+		fetchers         map[exchange.Name]Fetcher
+		expectedStatus   float64
+		expectedIP       string
+		expectedExchange any
+	}{
+		{
+			name:             "spot price",
+			path:             "/api/v1/spot/BTCUSDT",
+			fetchers:         mockFetchers(),
+			expectedStatus:   http.StatusOK,
+			expectedIP:       "192.0.2.1",
+			expectedExchange: "binance",
+		},
+		{
+			name:           "failed spot price",
+			path:           "/api/v1/spot/INVALID",
+			header:         map[string]string{"Cf-Connecting-Ip": "1.2.3.4"},
+			fetchers:       mockInvalidPairFetchers(),
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedIP:     "1.2.3.4",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			log.SetLogConfig(zerolog.InfoLevel, &buf)
+			defer log.SetLogConfig(zerolog.InfoLevel, nil)
+
+			s := &Server{
+				events:    bus.New(),
+				exchanges: exchanges[:1],
+				fetchers:  tt.fetchers,
+			}
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, http.NoBody)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			s.accessLog(s.routes()).ServeHTTP(w, req)
+
+			var entry map[string]any
+			lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+			assert.NoError(t, json.Unmarshal(lines[len(lines)-1], &entry))
+
+			assert.Equal(t, "Request", entry["message"])
+			assert.Equal(t, http.MethodGet, entry["method"])
+			assert.Equal(t, tt.path, entry["path"])
+			assert.Equal(t, tt.expectedStatus, entry["status"])
+			assert.Equal(t, float64(w.Body.Len()), entry["bytes"])
+			assert.Equal(t, tt.expectedIP, entry["ip"])
+			assert.Contains(t, entry, "duration_ms")
+			assert.Equal(t, tt.expectedExchange, entry["exchange"])
+			assert.Equal(t, w.Header().Get("X-Request-Id"), entry["request_id"])
+
+			// Entries logged while handling the request carry its id and pair
+			for _, line := range lines[:len(lines)-1] {
+				var e map[string]any
+				assert.NoError(t, json.Unmarshal(line, &e))
+				assert.Equal(t, entry["request_id"], e["request_id"])
+				assert.Equal(t, strings.TrimPrefix(tt.path, "/api/v1/spot/"), e["pair"])
+			}
+		})
+	}
+}
+
+func TestAccessWriter_Unwrap(t *testing.T) {
+	w := httptest.NewRecorder()
+	aw := &accessWriter{ResponseWriter: w}
+
+	assert.NoError(t, http.NewResponseController(aw).Flush())
+	assert.True(t, w.Flushed)
+}
+
+func TestRequestID(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	id := requestID(req)
+	assert.Len(t, id, 16)
+	assert.NotEqual(t, id, requestID(req))
+
+	req.Header.Set("X-Request-Id", "from-proxy")
+	assert.Equal(t, "from-proxy", requestID(req))
+
+	req.Header.Set("X-Request-Id", strings.Repeat("a", 129))
+	assert.Len(t, requestID(req), 16)
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     map[string]string
+		conn       uint64
+		expectedIP string
+	}{
+		{
+			name:       "tcp",
+			remoteAddr: "1.2.3.4:1234",
+			header:     map[string]string{"X-Forwarded-For": "5.6.7.8"},
+			expectedIP: "1.2.3.4",
+		},
+		{
+			name:       "cloudflare",
+			remoteAddr: "1.2.3.4:1234",
+			header:     map[string]string{"Cf-Connecting-Ip": "5.6.7.8"},
+			expectedIP: "5.6.7.8",
+		},
+		{
+			name:       "unix socket",
+			remoteAddr: "@",
+			header:     map[string]string{"X-Forwarded-For": "9.9.9.9, 5.6.7.8"},
+			expectedIP: "5.6.7.8",
+		},
+		{
+			name:       "unix socket with real IP",
+			remoteAddr: "@",
+			header:     map[string]string{"X-Real-Ip": "5.6.7.8", "X-Forwarded-For": "9.9.9.9"},
+			expectedIP: "5.6.7.8",
+		},
+		{
+			name:       "unix socket without proxy",
+			remoteAddr: "@",
+			conn:       42,
+			expectedIP: "unix:42",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.conn != 0 {
+				req = req.WithContext(context.WithValue(req.Context(), connKey{}, tt.conn))
+			}
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+
+			assert.Equal(t, tt.expectedIP, clientIP(req))
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// WithAutocert serves HTTPS with certificates for domains obtained and
+// renewed automatically from Let's Encrypt. Certificates are cached in
+// cacheDir, or in the user cache directory when it is empty.
+func WithAutocert(domains []string, email, cacheDir string) Option {
+	return func(s *Server) {
+		if cacheDir == "" {
+			dir, err := os.UserCacheDir()
+			if err != nil {
+				dir = os.TempDir()
+			}
+			cacheDir = filepath.Join(dir, "coinmon", "acme")
+		}
+
+		s.autocert = &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(domains...),
+			Cache:      autocert.DirCache(cacheDir),
+			Email:      email,
+		}
+	}
+}
+
+// StartACMEChallenge answers ACME HTTP-01 challenges on addr and redirects
+// other requests to HTTPS
+func (s *Server) StartACMEChallenge(addr string) error {
+	ln, err := s.listen(addr)
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+
+	srv := &http.Server{
+		Handler:           s.autocert.HTTPHandler(nil),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	return srv.Serve(ln)
+}
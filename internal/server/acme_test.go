@@ -0,0 +1,44 @@
+package server
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+func TestWithAutocert(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "acme")
+	s := &Server{}
+	WithAutocert([]string{"price.example.com"}, "ops@example.com", dir)(s)
+
+	assert.True(t, s.tlsEnabled())
+	assert.Equal(t, "ops@example.com", s.autocert.Email)
+	assert.Equal(t, autocert.DirCache(dir), s.autocert.Cache)
+	assert.NoError(t, s.autocert.HostPolicy(context.Background(), "price.example.com"))
+	assert.Error(t, s.autocert.HostPolicy(context.Background(), "other.example.com"))
+
+	cfg, err := s.tlsConfig()
+	assert.NoError(t, err)
+	assert.NotNil(t, cfg.GetCertificate)
+	assert.Empty(t, cfg.Certificates)
+	assert.Equal(t, []string{"h2", "http/1.1", acme.ALPNProto}, cfg.NextProtos)
+}
+
+func TestWithAutocert_defaultCache(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	s := &Server{}
+	WithAutocert([]string{"price.example.com"}, "", "")(s)
+
+	assert.Contains(t, string(s.autocert.Cache.(autocert.DirCache)), filepath.Join("coinmon", "acme"))
+}
+
+func TestServer_StartACMEChallenge(t *testing.T) {
+	s := &Server{}
+	WithAutocert([]string{"price.example.com"}, "", t.TempDir())(s)
+
+	assert.ErrorContains(t, s.StartACMEChallenge("127.0.0.1:-1"), "listen")
+}
@@ -0,0 +1,274 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/alert"
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/ivanglie/coinmon/pkg/log"
+)
+
+var errLastExchange = errors.New("the last enabled exchange cannot be disabled")
+
+// ExchangeControl represents the runtime state of an exchange
+type ExchangeControl struct {
+	Exchange     string     `json:"exchange"`
+	Enabled      bool       `json:"enabled"`
+	Priority     int        `json:"priority"`
+	Status       string     `json:"status"`
+	BlockedUntil *time.Time `json:"blocked_until,omitempty"`
+}
+
+// ExchangeControlRequest represents changes to the runtime state of an
+// exchange. Reset lifts the backoff after 429 and 418 responses and forgets
+// the outcomes of the latest calls.
+type ExchangeControlRequest struct {
+	Enabled  *bool `json:"enabled"`
+	Priority *int  `json:"priority"`
+	Reset    bool  `json:"reset"`
+}
+
+// exchangePriorities holds priorities of exchanges set at runtime, 0 when unset
+type exchangePriorities struct {
+	mu sync.Mutex
+	m  map[exchange.Name]int
+}
+
+func (p *exchangePriorities) get(name exchange.Name) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.m[name]
+}
+
+func (p *exchangePriorities) set(name exchange.Name, priority int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.m == nil {
+		p.m = make(map[exchange.Name]int)
+	}
+	p.m[name] = priority
+}
+
+// setEnabled enables or disables calls to the exchange name. The last
+// enabled exchange cannot be disabled.
+func (s *Server) setEnabled(name exchange.Name, enabled bool) error {
+	s.activeMu.Lock()
+	defer s.activeMu.Unlock()
+
+	active := s.activeExchanges()
+	names := make([]exchange.Name, 0, len(active)+1)
+	for _, ex := range active {
+		if ex.Name != name {
+			names = append(names, ex.Name)
+		}
+	}
+	if enabled {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return errLastExchange
+	}
+
+	s.setActive(names)
+	return nil
+}
+
+// HandleAdminExchanges handles /api/v1/admin/exchanges requests listing the
+// runtime state of exchanges, and PATCH /api/v1/admin/exchanges/{exchange}
+// requests changing it
+func (s *Server) HandleAdminExchanges(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPatch {
+		n, err := exchange.ParseName(strings.ToLower(r.PathValue("exchange")))
+		if err != nil || !slices.ContainsFunc(s.exchanges, func(ex *exchange.Exchange) bool { return ex.Name == n }) {
+			http.Error(w, "Exchange not found", http.StatusNotFound)
+			return
+		}
+
+		var req ExchangeControlRequest
+		if err = json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err = s.controlExchange(n, req); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(s.exchangeControls()); err != nil {
+		log.Error("Failed to encode response: " + err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// controlExchange applies req to the exchange name
+func (s *Server) controlExchange(name exchange.Name, req ExchangeControlRequest) error {
+	if req.Enabled != nil {
+		if err := s.setEnabled(name, *req.Enabled); err != nil {
+			return err
+		}
+	}
+	if req.Priority != nil {
+		s.priorities.set(name, *req.Priority)
+	}
+	if req.Reset {
+		s.limits.reset(name)
+		s.health.reset(name.String())
+	}
+
+	log.InfoFields("Exchange control changed", log.Fields{
+		"exchange": name.String(),
+		"enabled":  slices.ContainsFunc(s.activeExchanges(), func(ex *exchange.Exchange) bool { return ex.Name == name }),
+		"priority": s.priorities.get(name),
+		"reset":    req.Reset,
+	})
+
+	return nil
+}
+
+// exchangeControls returns the runtime state of every exchange
+func (s *Server) exchangeControls() []ExchangeControl {
+	active := s.activeExchanges()
+	controls := make([]ExchangeControl, 0, len(s.exchanges))
+	for _, ex := range s.exchanges {
+		c := ExchangeControl{
+			Exchange: ex.Name.String(),
+			Enabled:  slices.Contains(active, ex),
+			Priority: s.priorities.get(ex.Name),
+			Status:   s.exchangeStatus(ex.Name),
+		}
+		if until := s.limits.blockedUntil(ex.Name); time.Now().Before(until) {
+			c.BlockedUntil = &until
+		}
+		controls = append(controls, c)
+	}
+
+	return controls
+}
+
+// adminExchange represents an exchange on the admin page
+type adminExchange struct {
+	ExchangeControl
+	LastError string
+	Stats     WindowStats
+}
+
+// adminRule represents an alert rule on the admin page
+type adminRule struct {
+	alert.Rule
+	alert.State
+}
+
+// adminData represents the data of the admin page
+type adminData struct {
+	Maintenance *Maintenance
+	Exchanges   []adminExchange
+	Vars        debugVars
+	Rules       []adminRule
+}
+
+// HandleAdmin serves the admin page showing exchanges, internal state and
+// alert rules, and applies the exchange toggles posted from it
+func (s *Server) HandleAdmin(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		if status, err := s.adminAction(r); err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		http.Redirect(w, r, "/admin", http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+
+	t, err := s.templates()
+	if err != nil {
+		log.Error("Failed to parse template: " + err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err = t.ExecuteTemplate(&buf, "admin.html", s.adminData()); err != nil {
+		log.Error("Failed to execute template: " + err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if _, err = w.Write(buf.Bytes()); err != nil {
+		log.Error("Failed to write response: " + err.Error())
+	}
+}
+
+// adminAction applies the action of a form posted from the admin page:
+// start or end maintenance, enable, disable or reset the exchange, or set
+// its priority
+func (s *Server) adminAction(r *http.Request) (int, error) {
+	switch r.PostFormValue("action") {
+	case "start_maintenance":
+		s.SetMaintenance(&Maintenance{Message: r.PostFormValue("message")})
+		return http.StatusOK, nil
+	case "end_maintenance":
+		s.SetMaintenance(nil)
+		return http.StatusOK, nil
+	}
+
+	n, err := exchange.ParseName(r.PostFormValue("exchange"))
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+
+	var req ExchangeControlRequest
+	switch r.PostFormValue("action") {
+	case "enable", "disable":
+		enabled := r.PostFormValue("action") == "enable"
+		req.Enabled = &enabled
+	case "reset":
+		req.Reset = true
+	case "priority":
+		priority, convErr := strconv.Atoi(r.PostFormValue("priority"))
+		if convErr != nil {
+			return http.StatusBadRequest, errors.New("invalid priority")
+		}
+		req.Priority = &priority
+	default:
+		return http.StatusBadRequest, errors.New("unknown action")
+	}
+
+	if err = s.controlExchange(n, req); err != nil {
+		return http.StatusConflict, err
+	}
+
+	return http.StatusOK, nil
+}
+
+func (s *Server) adminData() adminData {
+	data := adminData{Maintenance: s.maintenance.Load(), Vars: s.debugVars()}
+	for _, c := range s.exchangeControls() {
+		data.Exchanges = append(data.Exchanges, adminExchange{
+			ExchangeControl: c,
+			LastError:       s.health.get(c.Exchange).LastError,
+			Stats:           s.stats.window(c.Exchange, 5*time.Minute),
+		})
+	}
+
+	if s.alerts != nil {
+		for _, r := range s.alerts.Rules() {
+			data.Rules = append(data.Rules, adminRule{Rule: r, State: s.alerts.State(r.ID)})
+		}
+	}
+
+	return data
+}
@@ -0,0 +1,209 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/alert"
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_HandleAdminExchanges(t *testing.T) {
+	s := &Server{exchanges: []*exchange.Exchange{exchange.New(exchange.BINANCE), exchange.New(exchange.BYBIT)}}
+	WithBasicAuth("admin", "secret")(s)
+	routes := s.routes()
+
+	tests := []struct {
+		name             string
+		method           string
+		path             string
+		body             string
+		expectedStatus   int
+		expectedControls []ExchangeControl
+	}{
+		{
+			name:           "list",
+			method:         http.MethodGet,
+			path:           "/api/v1/admin/exchanges",
+			expectedStatus: http.StatusOK,
+			expectedControls: []ExchangeControl{
+				{Exchange: "binance", Enabled: true, Status: "unknown"},
+				{Exchange: "bybit", Enabled: true, Status: "unknown"},
+			},
+		},
+		{
+			name:           "disable",
+			method:         http.MethodPatch,
+			path:           "/api/v1/admin/exchanges/binance",
+			body:           `{"enabled":false}`,
+			expectedStatus: http.StatusOK,
+			expectedControls: []ExchangeControl{
+				{Exchange: "binance", Status: "unknown"},
+				{Exchange: "bybit", Enabled: true, Status: "unknown"},
+			},
+		},
+		{
+			name:           "disable the last one",
+			method:         http.MethodPatch,
+			path:           "/api/v1/admin/exchanges/bybit",
+			body:           `{"enabled":false}`,
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "enable and prioritize",
+			method:         http.MethodPatch,
+			path:           "/api/v1/admin/exchanges/BINANCE",
+			body:           `{"enabled":true,"priority":-1}`,
+			expectedStatus: http.StatusOK,
+			expectedControls: []ExchangeControl{
+				{Exchange: "binance", Enabled: true, Priority: -1, Status: "unknown"},
+				{Exchange: "bybit", Enabled: true, Status: "unknown"},
+			},
+		},
+		{
+			name:           "unknown exchange",
+			method:         http.MethodPatch,
+			path:           "/api/v1/admin/exchanges/kraken",
+			body:           `{"enabled":false}`,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "invalid body",
+			method:         http.MethodPatch,
+			path:           "/api/v1/admin/exchanges/bybit",
+			body:           `{`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "patch without exchange",
+			method:         http.MethodPatch,
+			path:           "/api/v1/admin/exchanges",
+			body:           `{}`,
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	// Cases run in order, each on the state left by the previous ones
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.SetBasicAuth("admin", "secret")
+			w := httptest.NewRecorder()
+			routes.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var controls []ExchangeControl
+			assert.NoError(t, json.NewDecoder(w.Body).Decode(&controls))
+			assert.Equal(t, tt.expectedControls, controls)
+		})
+	}
+}
+
+func TestServer_controlExchange_Reset(t *testing.T) {
+	s := &Server{exchanges: []*exchange.Exchange{exchange.New(exchange.BINANCE)}}
+	ex := s.exchanges[0]
+	s.limits.observe(ex, &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"60"}}})
+	s.health.record(context.Background(), "binance", assert.AnError)
+
+	controls := s.exchangeControls()
+	assert.Equal(t, "down", controls[0].Status)
+	if assert.NotNil(t, controls[0].BlockedUntil) {
+		assert.WithinDuration(t, time.Now().Add(time.Minute), *controls[0].BlockedUntil, 5*time.Second)
+	}
+	assert.ErrorIs(t, s.limits.wait(context.Background(), ex), errRateLimited)
+
+	assert.NoError(t, s.controlExchange(exchange.BINANCE, ExchangeControlRequest{Reset: true}))
+
+	controls = s.exchangeControls()
+	assert.Equal(t, "unknown", controls[0].Status)
+	assert.Nil(t, controls[0].BlockedUntil)
+	assert.NoError(t, s.limits.wait(context.Background(), ex))
+}
+
+func TestServer_firstPriceWithDetails_Priority(t *testing.T) {
+	binance, bybit, kraken := &fakeFetcher{price: 1}, &fakeFetcher{price: 2}, &fakeFetcher{err: errors.New("unexpected status code: 503")}
+	s := &Server{
+		exchanges: []*exchange.Exchange{exchange.New(exchange.BINANCE), exchange.New(exchange.BYBIT), exchange.New(exchange.KRAKEN)},
+		fetchers:  map[exchange.Name]Fetcher{exchange.BINANCE: binance, exchange.BYBIT: bybit, exchange.KRAKEN: kraken},
+	}
+	s.priorities.set(exchange.KRAKEN, 2)
+	s.priorities.set(exchange.BYBIT, 1)
+
+	// Kraken of the highest priority fails, so Bybit of the next one answers
+	// and Binance of the lowest is not called
+	_, source, err := s.firstPriceWithDetails(context.Background(), "BTCUSDT")
+	assert.NoError(t, err)
+	assert.Equal(t, "bybit", source)
+	assert.Equal(t, int32(1), kraken.calls.Load())
+	assert.Equal(t, int32(0), binance.calls.Load())
+
+	tiers := s.aggregator().Tiers(s.priceOptions("BTCUSDT"))
+	assert.Len(t, tiers, 3)
+	assert.Equal(t, "kraken", tiers[0][0])
+	assert.Equal(t, "binance", tiers[2][0])
+}
+
+func TestServer_HandleAdmin(t *testing.T) {
+	evaluator := alert.NewEvaluator([]alert.Rule{{ID: "depeg", Pair: "USDCUSDT", Below: 0.99}}, nil)
+	s := &Server{
+		exchanges: []*exchange.Exchange{exchange.New(exchange.BINANCE), exchange.New(exchange.BYBIT)},
+		events:    bus.New(),
+		alerts:    evaluator,
+	}
+	s.health.record(context.Background(), "bybit", errors.New("unexpected status code: 503"))
+
+	w := httptest.NewRecorder()
+	s.HandleAdmin(w, httptest.NewRequest(http.MethodGet, "/admin", http.NoBody))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "binance")
+	assert.Contains(t, w.Body.String(), "unexpected status code: 503")
+	assert.Contains(t, w.Body.String(), "depeg")
+
+	tests := []struct {
+		name           string
+		form           url.Values
+		expectedStatus int
+	}{
+		{name: "disable", form: url.Values{"exchange": {"binance"}, "action": {"disable"}}, expectedStatus: http.StatusSeeOther},
+		{name: "disable the last one", form: url.Values{"exchange": {"bybit"}, "action": {"disable"}}, expectedStatus: http.StatusConflict},
+		{name: "priority", form: url.Values{"exchange": {"bybit"}, "action": {"priority"}, "priority": {"3"}}, expectedStatus: http.StatusSeeOther},
+		{name: "invalid priority", form: url.Values{"exchange": {"bybit"}, "action": {"priority"}, "priority": {"high"}}, expectedStatus: http.StatusBadRequest},
+		{name: "reset", form: url.Values{"exchange": {"bybit"}, "action": {"reset"}}, expectedStatus: http.StatusSeeOther},
+		{name: "unknown action", form: url.Values{"exchange": {"bybit"}, "action": {"delete"}}, expectedStatus: http.StatusBadRequest},
+		{name: "unknown exchange", form: url.Values{"exchange": {"mtgox"}, "action": {"reset"}}, expectedStatus: http.StatusNotFound},
+		{name: "start maintenance", form: url.Values{"action": {"start_maintenance"}, "message": {"back soon"}}, expectedStatus: http.StatusSeeOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/admin", strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec := httptest.NewRecorder()
+			s.HandleAdmin(rec, req)
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+		})
+	}
+
+	assert.Equal(t, "back soon", s.maintenance.Load().Message)
+	w = httptest.NewRecorder()
+	s.HandleAdmin(w, httptest.NewRequest(http.MethodGet, "/admin", http.NoBody))
+	assert.Contains(t, w.Body.String(), "In progress:</span> back soon")
+
+	controls := s.exchangeControls()
+	assert.False(t, controls[0].Enabled)
+	assert.Equal(t, 3, controls[1].Priority)
+	assert.Equal(t, "unknown", controls[1].Status)
+}
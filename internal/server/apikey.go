@@ -0,0 +1,81 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// APIKey represents a consumer of the API identified by a key
+type APIKey struct {
+	Name  string
+	Key   string
+	Rate  float64 // requests per second, 1 when zero
+	Burst int     // 50 when zero
+}
+
+var (
+	errAPIKeyRequired = errors.New("API key required")
+	errAPIKeyInvalid  = errors.New("invalid API key")
+)
+
+// apiKey represents a key with the limiter shared by all its requests
+type apiKey struct {
+	name    string
+	limiter *rate.Limiter
+}
+
+// apiKeys represents the known keys by key
+type apiKeys struct {
+	required bool
+	keys     map[string]*apiKey
+}
+
+// WithAPIKeys limits requests carrying a key in the X-API-Key header or the
+// api_key query parameter per key instead of per IP. Requests without a key
+// are rejected when required is set.
+func WithAPIKeys(keys []APIKey, required bool) Option {
+	return func(s *Server) {
+		s.apiKeys = &apiKeys{required: required, keys: make(map[string]*apiKey, len(keys))}
+		for _, k := range keys {
+			limit := rate.Limit(k.Rate)
+			if k.Rate == 0 {
+				limit = rate.Every(time.Second)
+			}
+			burst := k.Burst
+			if burst == 0 {
+				burst = 50
+			}
+
+			s.apiKeys.keys[k.Key] = &apiKey{name: k.Name, limiter: rate.NewLimiter(limit, burst)}
+		}
+	}
+}
+
+// identify returns the key of the request, or nil for anonymous requests
+func (a *apiKeys) identify(r *http.Request) (*apiKey, error) {
+	if a == nil {
+		return nil, nil
+	}
+
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		key = r.URL.Query().Get("api_key")
+	}
+
+	if key == "" {
+		if a.required {
+			return nil, errAPIKeyRequired
+		}
+		return nil, nil
+	}
+
+	k, ok := a.keys[key]
+	if !ok {
+		return nil, errAPIKeyInvalid
+	}
+
+	return k, nil
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_RateLimit_APIKeys(t *testing.T) {
+	tests := []struct {
+		name           string
+		required       bool
+		header         string
+		query          string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "key in header",
+			header:         "secret-a",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "key in query",
+			query:          "?api_key=secret-a",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "invalid key",
+			header:         "wrong",
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "invalid API key",
+		},
+		{
+			name:           "anonymous",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "anonymous when key required",
+			required:       true,
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "API key required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{}
+			WithAPIKeys([]APIKey{{Name: "a", Key: "secret-a"}}, tt.required)(s)
+			handler := s.rateLimit(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/spot/BTCUSDT"+tt.query, http.NoBody)
+			if tt.header != "" {
+				req.Header.Set("X-API-Key", tt.header)
+			}
+			w := httptest.NewRecorder()
+			handler(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+func TestServer_RateLimit_APIKeyQuota(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	s := &Server{metrics: newMetrics(reg)}
+	WithAPIKeys([]APIKey{{Name: "a", Key: "secret-a", Rate: 0.001, Burst: 3}, {Name: "b", Key: "secret-b"}}, false)(s)
+	ok := func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) }
+
+	// The quota of a key is shared by all routes and client addresses
+	spot, stream := s.rateLimit(ok), s.rateLimit(ok)
+	for i, h := range []http.HandlerFunc{spot, stream, spot, stream} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/spot/BTCUSDT", http.NoBody)
+		req.Header.Set("X-API-Key", "secret-a")
+		req.Header.Set("Cf-Connecting-Ip", []string{"1.1.1.1", "2.2.2.2"}[i%2])
+		w := httptest.NewRecorder()
+		h(w, req)
+
+		if i < 3 {
+			assert.Equal(t, http.StatusOK, w.Code, "request %d should pass", i+1)
+		} else {
+			assert.Equal(t, http.StatusTooManyRequests, w.Code, "request %d should be limited", i+1)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/spot/BTCUSDT", http.NoBody)
+	req.Header.Set("X-API-Key", "secret-b")
+	w := httptest.NewRecorder()
+	spot(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "other keys are not limited")
+
+	assert.Equal(t, 3.0, testutil.ToFloat64(s.metrics.apiKeys.WithLabelValues("a", "allowed")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(s.metrics.apiKeys.WithLabelValues("a", "limited")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(s.metrics.apiKeys.WithLabelValues("b", "allowed")))
+}
@@ -0,0 +1,229 @@
+package server
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/ivanglie/coinmon/pkg/log"
+)
+
+const (
+	// assetTTL is how long metadata of assets is cached
+	assetTTL = time.Hour
+	// maxAssets is the number of assets cached, beyond which the least
+	// recently requested are forgotten
+	maxAssets = 1000
+	// maxAssetLen is the length of the longest asset symbol
+	maxAssetLen = 16
+	// iconURL is the URL of the icon of an asset by lower case symbol
+	iconURL = "https://cdn.jsdelivr.net/gh/spothq/cryptocurrency-icons@master/svg/color/%s.svg"
+)
+
+// AssetResponse represents metadata of an asset normalized across exchanges
+type AssetResponse struct {
+	Symbol string `json:"symbol"`
+	Name   string `json:"name,omitempty"`
+	// Decimals are the finest precision exchanges account the asset in
+	Decimals  int      `json:"decimals"`
+	IconURL   string   `json:"icon_url"`
+	Exchanges []string `json:"exchanges"`
+	// Errors are the errors of exchanges which could not be asked by
+	// exchange name
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// assetCache keeps metadata of assets for assetTTL
+type assetCache struct {
+	mu  sync.Mutex
+	m   map[string]*list.Element // of lru
+	lru *list.List               // of *assetEntry, most recently requested first
+}
+
+type assetEntry struct {
+	asset   AssetResponse
+	expires time.Time
+}
+
+func (c *assetCache) get(symbol string) (AssetResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.m[symbol]
+	if !ok {
+		return AssetResponse{}, false
+	}
+	if entry := e.Value.(*assetEntry); time.Now().Before(entry.expires) {
+		c.lru.MoveToFront(e)
+		return entry.asset, true
+	}
+
+	c.lru.Remove(e)
+	delete(c.m, symbol)
+	return AssetResponse{}, false
+}
+
+// set caches a, forgetting the least recently requested asset when the
+// cache is full
+func (c *assetCache) set(a AssetResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.m == nil {
+		c.m = make(map[string]*list.Element)
+		c.lru = list.New()
+	}
+	entry := &assetEntry{asset: a, expires: time.Now().Add(assetTTL)}
+	if e, ok := c.m[a.Symbol]; ok {
+		e.Value = entry
+		c.lru.MoveToFront(e)
+		return
+	}
+
+	if len(c.m) >= maxAssets {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.m, oldest.Value.(*assetEntry).asset.Symbol)
+	}
+	c.m[a.Symbol] = c.lru.PushFront(entry)
+}
+
+// HandleAsset handles /api/v1/assets/{symbol} requests with the metadata of
+// an asset and the active exchanges listing it. Metadata is cached unless
+// an exchange could not be asked.
+func (s *Server) HandleAsset(w http.ResponseWriter, r *http.Request) {
+	symbol := strings.ToUpper(strings.TrimSpace(r.PathValue("symbol")))
+	if !validAsset(symbol) {
+		http.Error(w, fmt.Sprintf("invalid asset %q", symbol), http.StatusBadRequest)
+		return
+	}
+
+	a, ok := s.assetInfo.get(symbol)
+	if !ok {
+		a = s.asset(r.Context(), symbol)
+		if len(a.Errors) == 0 {
+			s.assetInfo.set(a)
+		}
+	}
+
+	switch {
+	case len(a.Exchanges) == 0 && len(a.Errors) > 0:
+		http.Error(w, "Failed to get asset from exchanges", http.StatusServiceUnavailable)
+		return
+	case len(a.Exchanges) == 0:
+		http.Error(w, fmt.Sprintf("asset %s not listed", symbol), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(a); err != nil {
+		log.Error("Failed to encode response: " + err.Error())
+	}
+}
+
+// validAsset reports whether symbol may be an asset, letters and digits
+func validAsset(symbol string) bool {
+	if symbol == "" || len(symbol) > maxAssetLen {
+		return false
+	}
+
+	return !strings.ContainsFunc(symbol, func(r rune) bool {
+		return (r < 'A' || r > 'Z') && (r < '0' || r > '9')
+	})
+}
+
+// asset asks every active exchange with asset support about symbol at once
+func (s *Server) asset(ctx context.Context, symbol string) AssetResponse {
+	a := AssetResponse{
+		Symbol:    symbol,
+		Name:      exchange.AssetName(symbol),
+		IconURL:   fmt.Sprintf(iconURL, strings.ToLower(symbol)),
+		Exchanges: []string{},
+	}
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for _, ex := range s.activeExchanges() {
+		if ex.AssetURL(symbol) == "" {
+			continue
+		}
+
+		wg.Go(func() {
+			decimals, err := s.fetchAssetDecimals(ctx, ex, symbol)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case errors.Is(err, exchange.ErrEmpty):
+			case err != nil:
+				if a.Errors == nil {
+					a.Errors = make(map[string]string)
+				}
+				a.Errors[ex.Name.String()] = err.Error()
+			default:
+				a.Exchanges = append(a.Exchanges, ex.Name.String())
+				a.Decimals = max(a.Decimals, decimals)
+			}
+		})
+	}
+	wg.Wait()
+	slices.Sort(a.Exchanges)
+
+	return a
+}
+
+// fetchAssetDecimals returns the decimals symbol is accounted in on e, and
+// exchange.ErrEmpty when e does not list it
+func (s *Server) fetchAssetDecimals(ctx context.Context, e *exchange.Exchange, symbol string) (int, error) {
+	if err := s.limits.waitN(ctx, e, e.AssetCost()); err != nil {
+		return 0, fmt.Errorf("wait for rate limit: %w", err)
+	}
+
+	release, err := s.upstreamLimit.acquire(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("wait for upstream slot: %w", err)
+	}
+	defer release()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.AssetURL(symbol), http.NoBody) //nolint:gosec // URL is constructed from static exchange configuration and a validated symbol
+	if err != nil {
+		return 0, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("do request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	s.limits.observe(e, resp)
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	if err != nil {
+		return 0, fmt.Errorf("read body: %w", err)
+	}
+
+	// Exchanges answer pairs they do not list with client errors
+	switch {
+	case rateLimited(resp), resp.StatusCode >= http.StatusInternalServerError:
+		return 0, fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, body[:min(len(body), upstreamBodyMax)])
+	case resp.StatusCode != http.StatusOK:
+		return 0, exchange.ErrEmpty
+	}
+
+	return exchange.ParseAssetDecimals(e.Name, body)
+}
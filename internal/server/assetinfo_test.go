@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockAssetResponse answers asset requests for BTC, listed on every exchange
+// but Bitget, and fails on Bybit when down
+func mockAssetResponse(down bool) mockResponseFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		url := req.URL.String()
+		respond := func(status int, body string) (*http.Response, error) {
+			return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body))}, nil
+		}
+
+		switch {
+		case strings.Contains(url, "bybit") && down:
+			return nil, errors.New("connection refused")
+		case strings.Contains(url, "binance") && strings.Contains(url, "BTCUSDT"):
+			return respond(http.StatusOK, `{"symbols":[{"symbol":"BTCUSDT","baseAsset":"BTC","baseAssetPrecision":8}]}`)
+		case strings.Contains(url, "binance"):
+			return respond(http.StatusBadRequest, `{"code":-1121,"msg":"Invalid symbol."}`)
+		case strings.Contains(url, "bybit") && strings.Contains(url, "BTCUSDT"):
+			return respond(http.StatusOK, `{"retCode":0,"result":{"list":[{"symbol":"BTCUSDT","lotSizeFilter":{"basePrecision":"0.000001"}}]}}`)
+		case strings.Contains(url, "bybit"):
+			return respond(http.StatusOK, `{"retCode":10001,"retMsg":"Not supported symbols","result":{}}`)
+		case strings.Contains(url, "bitget"):
+			return respond(http.StatusBadRequest, `{"code":"40034","msg":"Parameter does not exist"}`)
+		case strings.Contains(url, "kraken") && strings.Contains(url, "asset=XBT"):
+			return respond(http.StatusOK, `{"error":[],"result":{"XXBT":{"altname":"XBT","decimals":10}}}`)
+		default:
+			return respond(http.StatusOK, `{"error":["EQuery:Unknown asset"]}`)
+		}
+	}
+}
+
+func TestServer_HandleAsset(t *testing.T) {
+	tests := []struct {
+		name           string
+		symbol         string
+		down           bool
+		expectedStatus int
+		expectedBody   string
+		expectedAsset  AssetResponse
+	}{
+		{
+			name:           "listed",
+			symbol:         "btc",
+			expectedStatus: http.StatusOK,
+			expectedAsset: AssetResponse{
+				Symbol:    "BTC",
+				Name:      "Bitcoin",
+				Decimals:  10,
+				IconURL:   "https://cdn.jsdelivr.net/gh/spothq/cryptocurrency-icons@master/svg/color/btc.svg",
+				Exchanges: []string{"binance", "bybit", "kraken"},
+			},
+		},
+		{
+			name:           "exchange down",
+			symbol:         "BTC",
+			down:           true,
+			expectedStatus: http.StatusOK,
+			expectedAsset: AssetResponse{
+				Symbol:    "BTC",
+				Name:      "Bitcoin",
+				Decimals:  10,
+				IconURL:   "https://cdn.jsdelivr.net/gh/spothq/cryptocurrency-icons@master/svg/color/btc.svg",
+				Exchanges: []string{"binance", "kraken"},
+				Errors:    map[string]string{"bybit": "do request: connection refused"},
+			},
+		},
+		{name: "not listed", symbol: "NOPE", expectedStatus: http.StatusNotFound, expectedBody: "asset NOPE not listed\n"},
+		{name: "invalid", symbol: "BTC-USD", expectedStatus: http.StatusBadRequest, expectedBody: "invalid asset \"BTC-USD\"\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{exchanges: exchanges, client: &mockHTTPClient{doFunc: mockAssetResponse(tt.down)}}
+
+			w := httptest.NewRecorder()
+			s.routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/assets/"+tt.symbol, http.NoBody))
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				assert.Equal(t, tt.expectedBody, w.Body.String())
+				return
+			}
+
+			var a AssetResponse
+			assert.NoError(t, json.NewDecoder(w.Body).Decode(&a))
+			assert.Equal(t, tt.expectedAsset, a)
+		})
+	}
+}
+
+func TestServer_HandleAsset_Cache(t *testing.T) {
+	var calls atomic.Int32
+	mock := mockAssetResponse(false)
+	s := &Server{
+		exchanges: []*exchange.Exchange{exchange.New(exchange.BINANCE)},
+		client: &mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+			calls.Add(1)
+			return mock(req)
+		}},
+	}
+
+	for range 2 {
+		w := httptest.NewRecorder()
+		s.routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/assets/BTC", http.NoBody))
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestAssetCache(t *testing.T) {
+	var c assetCache
+	for i := range maxAssets {
+		c.set(AssetResponse{Symbol: fmt.Sprint(i)})
+	}
+
+	// Requesting an asset keeps it over the others
+	_, ok := c.get("0")
+	assert.True(t, ok)
+
+	c.set(AssetResponse{Symbol: "BTC"})
+	_, ok = c.get("BTC")
+	assert.True(t, ok, "new assets are cached when full")
+	_, ok = c.get("0")
+	assert.True(t, ok)
+	_, ok = c.get("1")
+	assert.False(t, ok, "least recently requested asset forgotten")
+	assert.Len(t, c.m, maxAssets)
+}
+
+func TestServer_fetchAssetDecimals_cost(t *testing.T) {
+	e := exchange.New(exchange.BINANCE)
+	e.RateLimit, e.Burst = 0.5, 15
+	s := &Server{client: &mockHTTPClient{doFunc: mockAssetResponse(false)}}
+
+	// A call of exchangeInfo costs 10 of the 15 calls of the burst
+	_, err := s.fetchAssetDecimals(context.Background(), e, "BTC")
+	assert.NoError(t, err)
+	_, err = s.fetchAssetDecimals(context.Background(), e, "BTC")
+	assert.ErrorIs(t, err, errRateLimited)
+}
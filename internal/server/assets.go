@@ -0,0 +1,83 @@
+package server
+
+import (
+	"html/template"
+	"io/fs"
+	"os"
+
+	"github.com/ivanglie/coinmon/web"
+)
+
+// WithWebDir reads templates and static assets from dir on disk instead of
+// the ones embedded in the binary, e.g. from web of the repository while
+// developing the dashboard
+func WithWebDir(dir string) Option {
+	return func(s *Server) {
+		s.web = os.DirFS(dir)
+	}
+}
+
+// WithDev reads templates and static assets on every request instead of
+// once, so changes to them in the web directory show up on reload
+func WithDev() Option {
+	return func(s *Server) {
+		s.dev = true
+	}
+}
+
+// webAssets represents the parsed templates of the pages by file name and
+// the static assets they link to
+type webAssets struct {
+	tmpl   *template.Template
+	static *staticFiles
+}
+
+// webFS returns the file system holding the templates and static assets
+func (s *Server) webFS() fs.FS {
+	if s.web != nil {
+		return s.web
+	}
+
+	return web.FS
+}
+
+// assets returns the templates and static assets, read on first use or on
+// every use in dev mode
+func (s *Server) assets() (*webAssets, error) {
+	if s.dev {
+		return loadAssets(s.webFS())
+	}
+
+	s.assetsOnce.Do(func() {
+		s.webAssets, s.assetsErr = loadAssets(s.webFS())
+	})
+
+	return s.webAssets, s.assetsErr
+}
+
+// templates returns the templates of the pages by file name
+func (s *Server) templates() (*template.Template, error) {
+	a, err := s.assets()
+	if err != nil {
+		return nil, err
+	}
+
+	return a.tmpl, nil
+}
+
+func loadAssets(fsys fs.FS) (*webAssets, error) {
+	static, err := loadStatic(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := template.New("").Funcs(template.FuncMap{
+		"percent": func(f float64) float64 { return f * 100 },
+		"static":  static.url,
+	}).ParseFS(fsys, "template/*.html")
+	if err != nil {
+		return nil, err
+	}
+
+	return &webAssets{tmpl: tmpl, static: static}, nil
+}
@@ -0,0 +1,232 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/ivanglie/coinmon/pkg/log"
+)
+
+// Balance represents spot holdings of an asset on an exchange
+type Balance struct {
+	Exchange string  `json:"exchange"`
+	Asset    string  `json:"asset"`
+	Free     float64 `json:"free"`
+	Locked   float64 `json:"locked"`
+}
+
+// BalancesResponse represents spot balances across exchanges with
+// credentials, and the errors of exchanges whose balances are missing
+type BalancesResponse struct {
+	Balances []Balance         `json:"balances"`
+	Errors   map[string]string `json:"errors,omitempty"`
+}
+
+// WithExchangeSigner signs calls to the private API of the exchange name,
+// serving its balances at /api/v1/balances
+func WithExchangeSigner(name exchange.Name, signer *exchange.Signer) Option {
+	return func(s *Server) {
+		for _, ex := range s.exchanges {
+			if ex.Name == name {
+				ex.Signer = signer
+			}
+		}
+	}
+}
+
+// hasSigners reports whether any exchange has credentials
+func (s *Server) hasSigners() bool {
+	return slices.ContainsFunc(s.exchanges, func(ex *exchange.Exchange) bool { return ex.Signer != nil })
+}
+
+// HandleBalances handles /api/v1/balances requests listing non-zero spot
+// balances of every exchange with credentials
+func (s *Server) HandleBalances(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(s.balances(r.Context())); err != nil {
+		log.Error("Failed to encode response: " + err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// balances fetches balances of every exchange with credentials at once
+func (s *Server) balances(ctx context.Context) BalancesResponse {
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		resp = BalancesResponse{Balances: []Balance{}}
+	)
+
+	for _, ex := range s.activeExchanges() {
+		if ex.Signer == nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func(ex *exchange.Exchange) {
+			defer wg.Done()
+			b, err := s.fetchBalances(ctx, ex)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				log.FromContext(log.WithFields(ctx, log.Fields{"exchange": ex.Name.String()})).Error(fmt.Sprintf("Error from %s: %v", ex.Name, err))
+				if resp.Errors == nil {
+					resp.Errors = make(map[string]string)
+				}
+				resp.Errors[ex.Name.String()] = err.Error()
+				return
+			}
+			resp.Balances = append(resp.Balances, b...)
+		}(ex)
+	}
+	wg.Wait()
+
+	slices.SortFunc(resp.Balances, func(a, b Balance) int {
+		return cmp.Or(cmp.Compare(a.Asset, b.Asset), cmp.Compare(a.Exchange, b.Exchange))
+	})
+
+	return resp
+}
+
+// fetchBalances returns non-zero spot balances of e. Responses are not
+// recorded at /debug/upstream as they are private.
+func (s *Server) fetchBalances(ctx context.Context, e *exchange.Exchange) ([]Balance, error) {
+	url := e.BalanceURL()
+	if url == "" {
+		return nil, fmt.Errorf("balances are not supported for %s", e.Name)
+	}
+
+	if err := s.limits.wait(ctx, e); err != nil {
+		return nil, fmt.Errorf("wait for rate limit: %w", err)
+	}
+
+	release, err := s.upstreamLimit.acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("wait for upstream slot: %w", err)
+	}
+	defer release()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody) //nolint:gosec // URL is constructed from static exchange configuration, not user input
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+	if err = e.Signer.Sign(req, nil); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	s.limits.observe(e, resp)
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, body[:min(len(body), upstreamBodyMax)])
+	}
+
+	return parseBalances(e.Name, body)
+}
+
+// parseBalances returns non-zero balances of a balance response of the
+// exchange name
+func parseBalances(name exchange.Name, body []byte) ([]Balance, error) {
+	var balances []Balance
+	add := func(asset string, free, locked float64) {
+		if free != 0 || locked != 0 {
+			balances = append(balances, Balance{Exchange: name.String(), Asset: asset, Free: free, Locked: locked})
+		}
+	}
+
+	switch name {
+	case exchange.BINANCE:
+		var r exchange.BinanceAccountResponse
+		if err := json.Unmarshal(body, &r); err != nil {
+			return nil, fmt.Errorf("decode response: %w", err)
+		}
+
+		for _, b := range r.Balances {
+			amounts, err := parseAmounts(b.Free, b.Locked)
+			if err != nil {
+				return nil, fmt.Errorf("parse %s balance: %w", b.Asset, err)
+			}
+			add(b.Asset, amounts[0], amounts[1])
+		}
+	case exchange.BYBIT:
+		var r exchange.BybitWalletResponse
+		if err := json.Unmarshal(body, &r); err != nil {
+			return nil, fmt.Errorf("decode response: %w", err)
+		}
+		if r.RetCode != 0 {
+			return nil, fmt.Errorf("code=%d, msg=%s", r.RetCode, r.RetMsg)
+		}
+
+		for _, account := range r.Result.List {
+			for _, c := range account.Coin {
+				amounts, err := parseAmounts(c.WalletBalance, c.Locked)
+				if err != nil {
+					return nil, fmt.Errorf("parse %s balance: %w", c.Coin, err)
+				}
+				add(c.Coin, amounts[0]-amounts[1], amounts[1])
+			}
+		}
+	case exchange.BITGET:
+		var r exchange.BitgetAssetsResponse
+		if err := json.Unmarshal(body, &r); err != nil {
+			return nil, fmt.Errorf("decode response: %w", err)
+		}
+		if r.Code != "00000" {
+			return nil, fmt.Errorf("code=%s, msg=%s", r.Code, r.Msg)
+		}
+
+		for _, c := range r.Data {
+			amounts, err := parseAmounts(c.Available, c.Frozen, c.Locked)
+			if err != nil {
+				return nil, fmt.Errorf("parse %s balance: %w", c.Coin, err)
+			}
+			add(c.Coin, amounts[0], amounts[1]+amounts[2])
+		}
+	default:
+		return nil, errors.New("decode response: unsupported exchange")
+	}
+
+	return balances, nil
+}
+
+// parseAmounts parses decimal amounts, empty meaning zero
+func parseAmounts(ss ...string) ([]float64, error) {
+	amounts := make([]float64, len(ss))
+	for i, s := range ss {
+		if s == "" {
+			continue
+		}
+
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, err
+		}
+		amounts[i] = v
+	}
+
+	return amounts, nil
+}
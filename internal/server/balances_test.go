@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBalances(t *testing.T) {
+	tests := []struct {
+		name        string
+		exchange    exchange.Name
+		body        string
+		expected    []Balance
+		expectedErr bool
+	}{
+		{
+			name:     "binance",
+			exchange: exchange.BINANCE,
+			body:     `{"balances":[{"asset":"BTC","free":"0.5","locked":"0.1"},{"asset":"ETH","free":"0","locked":"0"}]}`,
+			expected: []Balance{{Exchange: "binance", Asset: "BTC", Free: 0.5, Locked: 0.1}},
+		},
+		{
+			name:     "bybit",
+			exchange: exchange.BYBIT,
+			body:     `{"retCode":0,"result":{"list":[{"coin":[{"coin":"USDT","walletBalance":"100","locked":"25"},{"coin":"BTC","walletBalance":"0","locked":""}]}]}}`,
+			expected: []Balance{{Exchange: "bybit", Asset: "USDT", Free: 75, Locked: 25}},
+		},
+		{
+			name:        "bybit error",
+			exchange:    exchange.BYBIT,
+			body:        `{"retCode":10003,"retMsg":"API key is invalid."}`,
+			expectedErr: true,
+		},
+		{
+			name:     "bitget",
+			exchange: exchange.BITGET,
+			body:     `{"code":"00000","data":[{"coin":"ETH","available":"2","frozen":"0.5","locked":"0.25"}]}`,
+			expected: []Balance{{Exchange: "bitget", Asset: "ETH", Free: 2, Locked: 0.75}},
+		},
+		{
+			name:        "bitget error",
+			exchange:    exchange.BITGET,
+			body:        `{"code":"40037","msg":"Apikey does not exist"}`,
+			expectedErr: true,
+		},
+		{
+			name:        "invalid amount",
+			exchange:    exchange.BINANCE,
+			body:        `{"balances":[{"asset":"BTC","free":"abc","locked":"0"}]}`,
+			expectedErr: true,
+		},
+		{
+			name:        "invalid json",
+			exchange:    exchange.BINANCE,
+			body:        `{`,
+			expectedErr: true,
+		},
+		{
+			name:        "unsupported exchange",
+			exchange:    exchange.KRAKEN,
+			body:        `{}`,
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseBalances(tt.exchange, []byte(tt.body))
+			if tt.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestServer_HandleBalances(t *testing.T) {
+	binance, err := exchange.NewSigner(exchange.BINANCE, exchange.Credentials{Key: "bk", Secret: "bs"})
+	assert.NoError(t, err)
+	bybit, err := exchange.NewSigner(exchange.BYBIT, exchange.Credentials{Key: "yk", Secret: "ys"})
+	assert.NoError(t, err)
+
+	s := &Server{
+		exchanges: []*exchange.Exchange{exchange.New(exchange.BINANCE), exchange.New(exchange.BYBIT), exchange.New(exchange.KRAKEN)},
+		client: &mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+			switch {
+			case req.Header.Get("X-MBX-APIKEY") == "bk" && strings.Contains(req.URL.RawQuery, "signature="):
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       io.NopCloser(strings.NewReader(`{"balances":[{"asset":"ETH","free":"1","locked":"0"},{"asset":"BTC","free":"0.5","locked":"0"}]}`)),
+				}, nil
+			case req.Header.Get("X-BAPI-API-KEY") == "yk":
+				return &http.Response{StatusCode: http.StatusUnauthorized, Body: io.NopCloser(strings.NewReader(`Unauthorized`))}, nil
+			}
+			t.Errorf("unexpected request to %s", req.URL)
+			return nil, context.Canceled
+		}},
+	}
+	assert.False(t, s.hasSigners())
+	WithExchangeSigner(exchange.BINANCE, binance)(s)
+	WithExchangeSigner(exchange.BYBIT, bybit)(s)
+	assert.True(t, s.hasSigners())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/balances", http.NoBody)
+	w := httptest.NewRecorder()
+	s.HandleBalances(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+	var resp BalancesResponse
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, []Balance{
+		{Exchange: "binance", Asset: "BTC", Free: 0.5},
+		{Exchange: "binance", Asset: "ETH", Free: 1},
+	}, resp.Balances)
+	assert.Equal(t, map[string]string{"bybit": "unexpected status code: 401, body: Unauthorized"}, resp.Errors)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/balances", http.NoBody)
+	w = httptest.NewRecorder()
+	s.routes().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
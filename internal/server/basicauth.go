@@ -0,0 +1,56 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+)
+
+// WithBasicAuth requires the user and password from HTTP basic auth on the
+// index page and the jobs, stats and balances routes
+func WithBasicAuth(user, password string) Option {
+	return func(s *Server) {
+		s.basicUser = user
+		s.basicPassword = password
+	}
+}
+
+// basicAuth rejects requests without the credentials set by WithBasicAuth
+func (s *Server) basicAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.basicUser == "" && s.basicPassword == "" {
+			next(w, r)
+			return
+		}
+
+		user, password, ok := r.BasicAuth()
+		if !ok || !equalSecret(user, s.basicUser) || !equalSecret(password, s.basicPassword) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="coinmon", charset="UTF-8"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// adminAuth rejects requests to admin routes without the credentials set by
+// WithBasicAuth and, with a client CA set, without a certificate issued by it
+func (s *Server) adminAuth(next http.HandlerFunc) http.HandlerFunc {
+	auth := s.basicAuth(next)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.clientVerified(r) {
+			http.Error(w, "client certificate required", http.StatusForbidden)
+			return
+		}
+
+		auth(w, r)
+	}
+}
+
+// equalSecret compares a and b in constant time, regardless of their lengths
+func equalSecret(a, b string) bool {
+	ha, hb := sha256.Sum256([]byte(a)), sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
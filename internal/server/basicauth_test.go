@@ -0,0 +1,110 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_basicAuth(t *testing.T) {
+	tests := []struct {
+		name           string
+		enabled        bool
+		user           string
+		password       string
+		expectedStatus int
+	}{
+		{
+			name:           "disabled",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "valid credentials",
+			enabled:        true,
+			user:           "admin",
+			password:       "secret",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "wrong password",
+			enabled:        true,
+			user:           "admin",
+			password:       "wrong",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "wrong user",
+			enabled:        true,
+			user:           "root",
+			password:       "secret",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "no credentials",
+			enabled:        true,
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{}
+			if tt.enabled {
+				WithBasicAuth("admin", "secret")(s)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+			if tt.user != "" {
+				req.SetBasicAuth(tt.user, tt.password)
+			}
+			w := httptest.NewRecorder()
+			s.basicAuth(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusUnauthorized {
+				assert.Contains(t, w.Header().Get("WWW-Authenticate"), `Basic realm="coinmon"`)
+			}
+		})
+	}
+}
+
+func TestServer_adminAuth(t *testing.T) {
+	tests := []struct {
+		name           string
+		clientCA       bool
+		cert           bool
+		expectedStatus int
+	}{
+		{name: "without client CA", expectedStatus: http.StatusOK},
+		{name: "verified certificate", clientCA: true, cert: true, expectedStatus: http.StatusOK},
+		{name: "no certificate", clientCA: true, expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{}
+			WithBasicAuth("admin", "secret")(s)
+			if tt.clientCA {
+				WithClientCA("ca.pem")(s)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/exchanges", http.NoBody)
+			req.SetBasicAuth("admin", "secret")
+			if tt.cert {
+				req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}}
+			}
+			w := httptest.NewRecorder()
+			s.adminAuth(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// setCacheHeaders lets caches in front of the server, e.g. CDNs absorbing
+// polling clients, serve a price resolved at t for as long as the server
+// serves streamed quotes. Stale prices and servers without a feed are not
+// cached.
+func (s *Server) setCacheHeaders(w http.ResponseWriter, t time.Time, stale bool) {
+	age := max(time.Since(t), 0)
+	w.Header().Set("Age", strconv.Itoa(int(age.Seconds())))
+
+	ttl := time.Duration(s.feedAge.Load())
+	if s.feed == nil || stale || ttl < time.Second {
+		w.Header().Set("Cache-Control", "no-cache")
+		return
+	}
+	w.Header().Set("Cache-Control", "max-age="+strconv.Itoa(int(ttl.Seconds())))
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/ivanglie/coinmon/internal/feed"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_setCacheHeaders(t *testing.T) {
+	tests := []struct {
+		name                 string
+		feed                 bool
+		maxAge               time.Duration
+		age                  time.Duration
+		stale                bool
+		expectedCacheControl string
+		expectedAge          string
+	}{
+		{name: "streamed quote", feed: true, maxAge: 10 * time.Second, age: 3 * time.Second, expectedCacheControl: "max-age=10", expectedAge: "3"},
+		{name: "fresh quote", feed: true, maxAge: 10 * time.Second, expectedCacheControl: "max-age=10", expectedAge: "0"},
+		{name: "stale quote", feed: true, maxAge: 10 * time.Second, age: time.Minute, stale: true, expectedCacheControl: "no-cache", expectedAge: "60"},
+		{name: "max age below a second", feed: true, maxAge: 500 * time.Millisecond, expectedCacheControl: "no-cache", expectedAge: "0"},
+		{name: "without feed", expectedCacheControl: "no-cache", expectedAge: "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{}
+			if tt.feed {
+				s.feed = &mockFeed{}
+			}
+			s.SetFeedMaxAge(tt.maxAge)
+
+			w := httptest.NewRecorder()
+			s.setCacheHeaders(w, time.Now().Add(-tt.age), tt.stale)
+
+			assert.Equal(t, tt.expectedCacheControl, w.Header().Get("Cache-Control"))
+			assert.Equal(t, tt.expectedAge, w.Header().Get("Age"))
+		})
+	}
+}
+
+func TestServer_HandleSpot_CacheHeaders(t *testing.T) {
+	s := &Server{
+		events:    bus.New(),
+		exchanges: exchanges[:1],
+		fetchers:  mockFetchers(),
+		feed: &mockFeed{quotes: map[string]feed.Quote{
+			"BTCUSDT": {Pair: "BTCUSDT", Price: 100000, Source: "bybit", Time: time.Now().Add(-2 * time.Second)},
+		}},
+	}
+	s.SetFeedMaxAge(5 * time.Second)
+
+	for _, path := range []string{"/api/v1/spot/BTCUSDT", "/api/v2/spot/BTCUSDT"} {
+		w := httptest.NewRecorder()
+		s.routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, http.NoBody))
+
+		assert.Equal(t, http.StatusOK, w.Code, path)
+		assert.Equal(t, "max-age=5", w.Header().Get("Cache-Control"), path)
+		assert.Equal(t, "2", w.Header().Get("Age"), path)
+	}
+}
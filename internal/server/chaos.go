@@ -0,0 +1,92 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// Chaos represents faults injected into calls to exchanges, to exercise
+// health tracking and fallbacks between exchanges in staging. Rates are
+// probabilities from 0 to 1 of a call being affected.
+type Chaos struct {
+	Latency       time.Duration // added to calls at LatencyRate
+	LatencyRate   float64
+	ErrorRate     float64 // of calls failing with 502 Bad Gateway
+	MalformedRate float64 // of responses truncated to half of their body
+}
+
+// chaosTransport injects the faults of chaos into the calls of next
+type chaosTransport struct {
+	chaos Chaos
+	next  http.RoundTripper
+	// roll returns a random number in [0, 1)
+	roll func() float64
+}
+
+// NewChaosTransport returns a transport injecting the faults of c into the
+// calls made with next, http.DefaultTransport when nil
+func NewChaosTransport(next http.RoundTripper, c Chaos) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &chaosTransport{chaos: c, next: next, roll: rand.Float64}
+}
+
+func (t *chaosTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.roll() < t.chaos.LatencyRate {
+		if err := sleep(req.Context(), t.chaos.Latency); err != nil {
+			return nil, err
+		}
+	}
+
+	if t.roll() < t.chaos.ErrorRate {
+		body := "chaos: injected error"
+		return &http.Response{
+			Status:        "502 Bad Gateway",
+			StatusCode:    http.StatusBadGateway,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": {"text/plain"}},
+			Body:          io.NopCloser(bytes.NewReader([]byte(body))),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || t.roll() >= t.chaos.MalformedRate {
+		return resp, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+	body = body[:len(body)/2]
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Del("Content-Length")
+
+	return resp, nil
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChaosTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"symbol":"BTCUSDT","price":"97000.00"}`))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name           string
+		chaos          Chaos
+		ctxTimeout     time.Duration
+		expectedStatus int
+		expectedBody   string
+		expectedError  error
+	}{
+		{
+			name:           "no faults",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"symbol":"BTCUSDT","price":"97000.00"}`,
+		},
+		{
+			name:           "error",
+			chaos:          Chaos{ErrorRate: 1},
+			expectedStatus: http.StatusBadGateway,
+			expectedBody:   "chaos: injected error",
+		},
+		{
+			name:           "malformed",
+			chaos:          Chaos{MalformedRate: 1},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"symbol":"BTCUSDT"`,
+		},
+		{
+			name:          "latency beyond the deadline",
+			chaos:         Chaos{Latency: time.Minute, LatencyRate: 1},
+			ctxTimeout:    10 * time.Millisecond,
+			expectedError: context.DeadlineExceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.ctxTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.ctxTimeout)
+				defer cancel()
+			}
+
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, http.NoBody)
+			resp, err := NewChaosTransport(nil, tt.chaos).RoundTrip(req)
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			defer func() { _ = resp.Body.Close() }()
+
+			body, _ := io.ReadAll(resp.Body)
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			assert.Equal(t, tt.expectedBody, string(body))
+		})
+	}
+}
+
+// roundTripper makes calls with an httpClient
+type roundTripper struct {
+	c httpClient
+}
+
+func (rt roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return rt.c.Do(req)
+}
+
+func TestChaosTransport_Rates(t *testing.T) {
+	var calls int
+	next := &mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+		calls++
+		return mockSuccessfulResponse(req)
+	}}
+
+	// Rolls below the error rate fail, the others reach the exchange
+	rolls := []float64{0.9, 0.1, 0.9, 0.6, 0.9}
+	ct := &chaosTransport{chaos: Chaos{ErrorRate: 0.5}, next: roundTripper{next}, roll: func() float64 {
+		r := rolls[0]
+		rolls = rolls[1:]
+		return r
+	}}
+
+	req := httptest.NewRequest(http.MethodGet, "https://api.binance.com/api/v3/ticker/price?symbol=BTCUSDT", http.NoBody)
+	resp, err := ct.RoundTrip(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	assert.Equal(t, 0, calls)
+
+	resp, err = ct.RoundTrip(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,63 @@
+package server
+
+import "net/http"
+
+// Dashboard represents the branding of the index page. Theme is light, dark
+// or auto, following the preference of the browser.
+type Dashboard struct {
+	Title string
+	Logo  string
+	Pairs []string
+	Theme string
+}
+
+// indexData represents the data of the dashboard
+type indexData struct {
+	Dashboard
+	Prices    []watchedPair
+	Watchlist []watchedPair
+}
+
+// WithDashboard shows the title, logo and theme of d on the index page, with
+// the latest prices of its pairs
+func WithDashboard(d Dashboard) Option {
+	return func(s *Server) {
+		s.dashboard = d
+	}
+}
+
+// indexData returns the dashboard settings with the latest prices of its
+// pairs and of the pairs watched by the consumer of the request
+func (s *Server) indexData(r *http.Request) indexData {
+	data := indexData{Dashboard: s.dashboard}
+	if data.Title == "" {
+		data.Title = "Coinmon API"
+	}
+	if data.Theme == "" {
+		data.Theme = "auto"
+	}
+	data.Prices = s.latestPrices(data.Pairs)
+	if s.watchlist != nil {
+		data.Watchlist = s.latestPrices(s.watchedPairs(r))
+	}
+
+	return data
+}
+
+// latestPrices returns pairs with their latest prices, if any
+func (s *Server) latestPrices(pairs []string) []watchedPair {
+	if len(pairs) == 0 {
+		return nil
+	}
+
+	prices := make([]watchedPair, 0, len(pairs))
+	for _, p := range pairs {
+		wp := watchedPair{Pair: p}
+		if e, ok := s.events.Latest(p); ok {
+			wp.Price, wp.Source, wp.Known = e.Price, e.Source, true
+		}
+		prices = append(prices, wp)
+	}
+
+	return prices
+}
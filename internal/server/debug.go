@@ -0,0 +1,102 @@
+package server
+
+import (
+	"crypto/subtle"
+	"expvar"
+	"net"
+	"net/http"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+)
+
+// debugVars represents internal state published at /debug/vars
+type debugVars struct {
+	Goroutines int               `json:"goroutines"`
+	InFlight   int64             `json:"upstream_in_flight"`
+	Queued     int64             `json:"upstream_queued"`
+	Exchanges  map[string]string `json:"exchanges"`
+	Bus        bus.Stats         `json:"bus"`
+}
+
+// publishDebugVars publishes internal state as the "coinmon" expvar, served
+// at /debug/vars
+func (s *Server) publishDebugVars() {
+	if expvar.Get("coinmon") != nil {
+		return
+	}
+
+	expvar.Publish("coinmon", expvar.Func(func() any {
+		return s.debugVars()
+	}))
+}
+
+func (s *Server) debugVars() debugVars {
+	v := debugVars{
+		Goroutines: runtime.NumGoroutine(),
+		InFlight:   s.inFlight.Load(),
+		Queued:     s.upstreamLimit.queuedCalls(),
+		Exchanges:  make(map[string]string, len(s.exchanges)),
+		Bus:        s.events.Stats(),
+	}
+	for _, ex := range s.exchanges {
+		v.Exchanges[ex.Name.String()] = s.exchangeStatus(ex.Name)
+	}
+
+	return v
+}
+
+// gatedDebugPaths are served only to requests allowed by debugAllowed
+var gatedDebugPaths = []string{"/debug/pprof", "/debug/upstream", "/debug/vars"}
+
+// gateDebug hides profiles and upstream traffic unless debugging is enabled.
+// With a client CA set, requests must present a certificate issued by it.
+// With a token set, requests must carry it as a bearer token or the token
+// query parameter. Otherwise only direct requests from localhost are allowed.
+func (s *Server) gateDebug(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gated := slices.ContainsFunc(gatedDebugPaths, func(p string) bool { return strings.HasPrefix(r.URL.Path, p) })
+		if gated && !s.debugAllowed(r) {
+			http.NotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) debugAllowed(r *http.Request) bool {
+	if !s.debug {
+		return false
+	}
+
+	if s.clientCAFile != "" {
+		if !s.clientVerified(r) {
+			return false
+		}
+		if s.debugToken == "" {
+			return true
+		}
+	}
+
+	if s.debugToken != "" {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" {
+			token = r.URL.Query().Get("token")
+		}
+		return subtle.ConstantTimeCompare([]byte(token), []byte(s.debugToken)) == 1
+	}
+
+	// Requests forwarded by a local proxy come from loopback too
+	if r.Header.Get("Cf-Connecting-Ip") != "" || r.Header.Get("X-Forwarded-For") != "" {
+		return false
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
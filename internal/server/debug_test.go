@@ -0,0 +1,200 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_debugVars(t *testing.T) {
+	s := &Server{
+		events:    bus.New(),
+		exchanges: exchanges[:2],
+		fetchers:  mockFetchers(),
+	}
+
+	v := s.debugVars()
+	assert.Equal(t, map[string]string{"binance": "unknown", "bybit": "unknown"}, v.Exchanges)
+	assert.Equal(t, bus.Stats{}, v.Bus)
+
+	assert.NoError(t, s.Poll(context.Background(), "BTCUSDT"))
+	_, unsubscribe := s.events.Subscribe("BTCUSDT")
+	defer unsubscribe()
+
+	// The exchange losing the race may still be in flight for a moment
+	assert.Eventually(t, func() bool { return s.debugVars().InFlight == 0 }, time.Second, time.Millisecond)
+	v = s.debugVars()
+	assert.Positive(t, v.Goroutines)
+	assert.Contains(t, []string{"up", "unknown"}, v.Exchanges["bybit"])
+	assert.Equal(t, bus.Stats{Pairs: 1, Subscribers: 1, History: 1}, v.Bus)
+}
+
+func TestServer_publishDebugVars(t *testing.T) {
+	s := &Server{events: bus.New(), exchanges: exchanges[:1]}
+	s.publishDebugVars()
+	s.publishDebugVars()
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/vars", http.NoBody)
+	w := httptest.NewRecorder()
+	expvar.Handler().ServeHTTP(w, req)
+
+	var vars struct {
+		Coinmon debugVars `json:"coinmon"`
+	}
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&vars))
+	assert.Equal(t, map[string]string{"binance": "unknown"}, vars.Coinmon.Exchanges)
+}
+
+func TestServer_gateDebug(t *testing.T) {
+	tests := []struct {
+		name           string
+		enabled        bool
+		token          string
+		clientCA       bool
+		path           string
+		remoteAddr     string
+		header         map[string]string
+		query          string
+		expectedStatus int
+	}{
+		{
+			name:           "disabled",
+			remoteAddr:     "127.0.0.1:1234",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "localhost",
+			enabled:        true,
+			remoteAddr:     "127.0.0.1:1234",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "ipv6 localhost",
+			enabled:        true,
+			remoteAddr:     "[::1]:1234",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "remote",
+			enabled:        true,
+			remoteAddr:     "1.2.3.4:1234",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "forwarded by local proxy",
+			enabled:        true,
+			remoteAddr:     "127.0.0.1:1234",
+			header:         map[string]string{"X-Forwarded-For": "1.2.3.4"},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "bearer token",
+			enabled:        true,
+			token:          "secret",
+			remoteAddr:     "1.2.3.4:1234",
+			header:         map[string]string{"Authorization": "Bearer secret"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "query token",
+			enabled:        true,
+			token:          "secret",
+			remoteAddr:     "1.2.3.4:1234",
+			query:          "?token=secret",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "wrong token",
+			enabled:        true,
+			token:          "secret",
+			remoteAddr:     "1.2.3.4:1234",
+			header:         map[string]string{"Authorization": "Bearer wrong"},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "upstream localhost",
+			enabled:        true,
+			path:           "/debug/upstream",
+			remoteAddr:     "127.0.0.1:1234",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "upstream remote",
+			enabled:        true,
+			path:           "/debug/upstream",
+			remoteAddr:     "1.2.3.4:1234",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "vars remote",
+			enabled:        true,
+			path:           "/debug/vars",
+			remoteAddr:     "1.2.3.4:1234",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "client certificate required on localhost",
+			enabled:        true,
+			clientCA:       true,
+			remoteAddr:     "127.0.0.1:1234",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "token required on localhost",
+			enabled:        true,
+			token:          "secret",
+			remoteAddr:     "127.0.0.1:1234",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{}
+			if tt.enabled {
+				WithDebug(tt.token)(s)
+			}
+			if tt.clientCA {
+				WithClientCA("ca.pem")(s)
+			}
+
+			path := tt.path
+			if path == "" {
+				path = "/debug/pprof/"
+			}
+
+			req := httptest.NewRequest(http.MethodGet, path+tt.query, http.NoBody)
+			req.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			s.gateDebug(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
+
+func TestServer_gateDebug_otherPaths(t *testing.T) {
+	s := &Server{}
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/spot/BTCUSDT", http.NoBody)
+	req.RemoteAddr = "1.2.3.4:1234"
+	w := httptest.NewRecorder()
+	s.gateDebug(next).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusTeapot, w.Code)
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Deprecation represents the deprecation policy of an API version. Zero Date
+// or Sunset leaves its header out.
+type Deprecation struct {
+	// Date is when the version was or will be deprecated
+	Date time.Time
+	// Sunset is when the version stops responding
+	Sunset time.Time
+	// Link is the migration guide of the version
+	Link string
+}
+
+// WithDeprecation announces the deprecation of the API version, e.g. "v1",
+// with Deprecation (RFC 9745), Sunset (RFC 8594) and Link headers on its
+// responses
+func WithDeprecation(version string, d Deprecation) Option {
+	return func(s *Server) {
+		if s.deprecations == nil {
+			s.deprecations = map[string]Deprecation{}
+		}
+		s.deprecations["/api/"+version+"/"] = d
+	}
+}
+
+// deprecate adds the deprecation headers of the API version of the request
+func (s *Server) deprecate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for prefix, d := range s.deprecations {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				d.setHeaders(w.Header())
+				break
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (d Deprecation) setHeaders(h http.Header) {
+	if !d.Date.IsZero() {
+		h.Set("Deprecation", "@"+strconv.FormatInt(d.Date.Unix(), 10))
+	}
+	if !d.Sunset.IsZero() {
+		h.Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if d.Link != "" {
+		h.Add("Link", "<"+d.Link+`>; rel="deprecation"; type="text/html"`)
+		if !d.Sunset.IsZero() {
+			h.Add("Link", "<"+d.Link+`>; rel="sunset"; type="text/html"`)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_deprecate(t *testing.T) {
+	date := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name                string
+		deprecation         Deprecation
+		path                string
+		expectedDeprecation string
+		expectedSunset      string
+		expectedLinks       []string
+	}{
+		{
+			name:                "deprecated version",
+			deprecation:         Deprecation{Date: date, Sunset: sunset, Link: "https://coinmon.cc/docs/v2"},
+			path:                "/api/v1/spot/BTCUSDT",
+			expectedDeprecation: "@1767225600",
+			expectedSunset:      "Wed, 01 Jul 2026 00:00:00 GMT",
+			expectedLinks: []string{
+				`<https://coinmon.cc/docs/v2>; rel="deprecation"; type="text/html"`,
+				`<https://coinmon.cc/docs/v2>; rel="sunset"; type="text/html"`,
+			},
+		},
+		{
+			name:                "deprecation only",
+			deprecation:         Deprecation{Date: date},
+			path:                "/api/v1/stats",
+			expectedDeprecation: "@1767225600",
+		},
+		{
+			name:        "other version",
+			deprecation: Deprecation{Date: date, Sunset: sunset},
+			path:        "/api/v2/spot/BTCUSDT",
+		},
+		{
+			name:        "other route",
+			deprecation: Deprecation{Date: date, Sunset: sunset},
+			path:        "/healthz",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{}
+			WithDeprecation("v1", tt.deprecation)(s)
+
+			h := s.deprecate(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, http.NoBody))
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, tt.expectedDeprecation, rec.Header().Get("Deprecation"))
+			assert.Equal(t, tt.expectedSunset, rec.Header().Get("Sunset"))
+			assert.Equal(t, tt.expectedLinks, rec.Header().Values("Link"))
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockResolver answers lookups with addrs, or err when set
+type mockResolver struct {
+	addrs   []string
+	err     error
+	lookups int
+}
+
+func (r *mockResolver) LookupHost(context.Context, string) ([]string, error) {
+	r.lookups++
+	return r.addrs, r.err
+}
+
+func TestDNSCache_lookup(t *testing.T) {
+	r := &mockResolver{addrs: []string{"192.0.2.1"}}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := newDNSCache(time.Minute)
+	c.resolver = r
+	c.now = func() time.Time { return now }
+
+	for range 2 {
+		addrs, err := c.lookup(context.Background(), "api.binance.com")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"192.0.2.1"}, addrs)
+	}
+	assert.Equal(t, 1, r.lookups)
+
+	// Expired addresses are looked up again
+	now = now.Add(time.Minute)
+	r.addrs = []string{"192.0.2.2"}
+	addrs, err := c.lookup(context.Background(), "api.binance.com")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"192.0.2.2"}, addrs)
+	assert.Equal(t, 2, r.lookups)
+
+	// and served when the lookup fails
+	now = now.Add(time.Minute)
+	r.err = errors.New("no such host")
+	addrs, err = c.lookup(context.Background(), "api.binance.com")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"192.0.2.2"}, addrs)
+
+	_, err = c.lookup(context.Background(), "api.bybit.com")
+	assert.EqualError(t, err, "no such host")
+}
+
+func TestDNSCache_dialContext(t *testing.T) {
+	c := newDNSCache(time.Minute)
+	c.resolver = &mockResolver{addrs: []string{"192.0.2.1", "192.0.2.2"}}
+
+	var dialed []string
+	dial := c.dialContext(func(_ context.Context, _, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		if addr == "192.0.2.2:443" {
+			client, server := net.Pipe()
+			_ = server.Close()
+			return client, nil
+		}
+		return nil, errors.New("connection refused")
+	})
+
+	conn, err := dial(context.Background(), "tcp", "api.binance.com:443")
+	assert.NoError(t, err)
+	_ = conn.Close()
+	assert.Equal(t, []string{"192.0.2.1:443", "192.0.2.2:443"}, dialed)
+
+	// Addresses are dialed as they are
+	dialed = nil
+	_, err = dial(context.Background(), "tcp", "198.51.100.1:443")
+	assert.EqualError(t, err, "connection refused")
+	assert.Equal(t, []string{"198.51.100.1:443"}, dialed)
+}
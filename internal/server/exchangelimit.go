@@ -0,0 +1,297 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/ivanglie/coinmon/pkg/aggregator"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// maxRateDelay is the longest a call waits for the rate limit of its
+	// exchange before it is skipped
+	maxRateDelay = time.Second
+	// defaultRetryAfter is the backoff after a 429 or 418 response without
+	// a Retry-After header
+	defaultRetryAfter = time.Minute
+	// defaultMaintenanceBench is how long an exchange down for maintenance
+	// is not called
+	defaultMaintenanceBench = 5 * time.Minute
+)
+
+var (
+	errRateLimited = errors.New("exchange rate limit reached")
+	errMaintenance = errors.New("exchange down for maintenance")
+)
+
+// WithMaintenanceBench stops calling an exchange answering that it is down
+// for maintenance for d instead of 5 minutes
+func WithMaintenanceBench(d time.Duration) Option {
+	return func(s *Server) {
+		s.maintenanceBench = d
+	}
+}
+
+// backoffError marks errors of exchanges asking to back off, so they are
+// not retried right away
+type backoffError struct {
+	error
+}
+
+func (e backoffError) Unwrap() error {
+	return e.error
+}
+
+func (backoffError) Is(target error) bool {
+	return target == aggregator.ErrBackoff //nolint:errorlint // sentinel identity is what Is compares
+}
+
+// transientError marks errors of calls failing to reach an exchange or with
+// a 5xx response, which are worth retrying
+type transientError struct {
+	error
+}
+
+func (e transientError) Unwrap() error {
+	return e.error
+}
+
+func (transientError) Is(target error) bool {
+	return target == aggregator.ErrTransient //nolint:errorlint // sentinel identity is what Is compares
+}
+
+// maintenanceError marks errors of exchanges down for maintenance, which
+// are not retried and say nothing about the health of the exchange
+type maintenanceError struct {
+	error
+}
+
+func (e maintenanceError) Unwrap() error {
+	return e.error
+}
+
+func (maintenanceError) Is(target error) bool {
+	return target == errMaintenance || target == aggregator.ErrBackoff //nolint:errorlint // sentinel identity is what Is compares
+}
+
+// exchangeLimit represents the budget of calls to an exchange
+type exchangeLimit struct {
+	limiter *rate.Limiter
+
+	mu           sync.Mutex
+	blockedUntil time.Time
+	benchedUntil time.Time
+}
+
+// exchangeLimits keeps calls within the rate limits of exchanges
+type exchangeLimits struct {
+	mu sync.Mutex
+	m  map[string]*exchangeLimit
+}
+
+func (l *exchangeLimits) get(e *exchange.Exchange) *exchangeLimit {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.m == nil {
+		l.m = make(map[string]*exchangeLimit)
+	}
+
+	el, ok := l.m[e.Name.String()]
+	if !ok {
+		limit := rate.Inf
+		if e.RateLimit > 0 {
+			limit = rate.Limit(e.RateLimit)
+		}
+		el = &exchangeLimit{limiter: rate.NewLimiter(limit, e.Burst)}
+		l.m[e.Name.String()] = el
+	}
+
+	return el
+}
+
+// wait delays a call to e until its rate limit allows it. Calls made while
+// the exchange asks to back off, or which would wait longer than
+// maxRateDelay or past the deadline of ctx, are skipped.
+func (l *exchangeLimits) wait(ctx context.Context, e *exchange.Exchange) error {
+	return l.waitN(ctx, e, 1)
+}
+
+// waitN is like wait for a call counting as n calls, or as the burst of e
+// when larger so it can pass at all
+func (l *exchangeLimits) waitN(ctx context.Context, e *exchange.Exchange, n int) error {
+	el := l.get(e)
+
+	el.mu.Lock()
+	now := time.Now()
+	blocked, benched := now.Before(el.blockedUntil), now.Before(el.benchedUntil)
+	el.mu.Unlock()
+	switch {
+	case benched:
+		return maintenanceError{errMaintenance}
+	case blocked:
+		return backoffError{errRateLimited}
+	}
+
+	r := el.limiter.ReserveN(time.Now(), min(n, max(el.limiter.Burst(), 1)))
+	delay := r.Delay()
+	if deadline, ok := ctx.Deadline(); delay > maxRateDelay || (ok && time.Until(deadline) < delay) {
+		r.Cancel()
+		return errRateLimited
+	}
+	if delay == 0 {
+		return nil
+	}
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		r.Cancel()
+		return ctx.Err()
+	}
+}
+
+// rateLimited reports whether resp is a 429 Too Many Requests or a 418 ban
+// response
+func rateLimited(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusTeapot
+}
+
+// observe backs off from e for as long as a 429 Too Many Requests or a 418
+// ban response asks to
+func (l *exchangeLimits) observe(e *exchange.Exchange, resp *http.Response) {
+	if !rateLimited(resp) {
+		return
+	}
+
+	now := time.Now()
+	backoff, ok := retryAfter(resp.Header, now)
+	if !ok {
+		backoff = defaultRetryAfter
+	}
+
+	el := l.get(e)
+	el.mu.Lock()
+	defer el.mu.Unlock()
+	if until := now.Add(backoff); until.After(el.blockedUntil) {
+		el.blockedUntil = until
+	}
+}
+
+// retryAfterHeaders are the headers exchanges announce the end of their rate
+// limit windows in, in order of preference: the standard Retry-After,
+// Bybit's reset timestamp and the RateLimit-Reset of other APIs
+var retryAfterHeaders = []string{"Retry-After", "X-Bapi-Limit-Reset-Timestamp", "RateLimit-Reset", "X-RateLimit-Reset"}
+
+// retryAfter returns how long to back off for according to h. Values are
+// seconds to wait, HTTP dates, or Unix timestamps in seconds or
+// milliseconds.
+func retryAfter(h http.Header, now time.Time) (time.Duration, bool) {
+	for _, name := range retryAfterHeaders {
+		v := h.Get(name)
+		if v == "" {
+			continue
+		}
+
+		if t, err := http.ParseTime(v); err == nil {
+			return positive(t.Sub(now))
+		}
+
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			continue
+		}
+		switch {
+		case n > 1e12:
+			return positive(time.UnixMilli(n).Sub(now))
+		case n > 1e9:
+			return positive(time.Unix(n, 0).Sub(now))
+		default:
+			return time.Duration(n) * time.Second, true
+		}
+	}
+
+	return 0, false
+}
+
+// positive returns d when the time it ends at is still ahead, and at least a
+// second so calls are not resumed in a burst
+func positive(d time.Duration) (time.Duration, bool) {
+	if d <= 0 {
+		return 0, false
+	}
+
+	return max(d, time.Second), true
+}
+
+// bench stops calling e for d, as it is down for maintenance
+func (l *exchangeLimits) bench(e *exchange.Exchange, d time.Duration) {
+	el := l.get(e)
+	el.mu.Lock()
+	defer el.mu.Unlock()
+	if until := time.Now().Add(d); until.After(el.benchedUntil) {
+		el.benchedUntil = until
+	}
+}
+
+// benchedUntil returns the end of the maintenance of the exchange name
+func (l *exchangeLimits) benchedUntil(name exchange.Name) time.Time {
+	l.mu.Lock()
+	el, ok := l.m[name.String()]
+	l.mu.Unlock()
+	if !ok {
+		return time.Time{}
+	}
+
+	el.mu.Lock()
+	defer el.mu.Unlock()
+	return el.benchedUntil
+}
+
+// reset lifts the backoff and the maintenance from the exchange name
+func (l *exchangeLimits) reset(name exchange.Name) {
+	l.mu.Lock()
+	el, ok := l.m[name.String()]
+	l.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	el.mu.Lock()
+	defer el.mu.Unlock()
+	el.blockedUntil = time.Time{}
+	el.benchedUntil = time.Time{}
+}
+
+// exchangeStatus returns "maintenance" while the exchange name is down for
+// maintenance, otherwise its health status
+func (s *Server) exchangeStatus(name exchange.Name) string {
+	if time.Now().Before(s.limits.benchedUntil(name)) {
+		return "maintenance"
+	}
+
+	return s.health.get(name.String()).status()
+}
+
+// blockedUntil returns the end of the backoff from the exchange name
+func (l *exchangeLimits) blockedUntil(name exchange.Name) time.Time {
+	l.mu.Lock()
+	el, ok := l.m[name.String()]
+	l.mu.Unlock()
+	if !ok {
+		return time.Time{}
+	}
+
+	el.mu.Lock()
+	defer el.mu.Unlock()
+	return el.blockedUntil
+}
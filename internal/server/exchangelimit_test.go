@@ -0,0 +1,207 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/ivanglie/coinmon/pkg/aggregator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExchangeLimits_wait(t *testing.T) {
+	e := &exchange.Exchange{Name: exchange.KRAKEN, RateLimit: 2, Burst: 2}
+	var l exchangeLimits
+
+	// The burst passes at once
+	assert.NoError(t, l.wait(context.Background(), e))
+	assert.NoError(t, l.wait(context.Background(), e))
+
+	// The next call is delayed by about 1/rate
+	start := time.Now()
+	assert.NoError(t, l.wait(context.Background(), e))
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+
+	// Calls waiting past the deadline are skipped
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, l.wait(ctx, e), errRateLimited)
+
+	// Calls waiting longer than maxRateDelay are skipped
+	slow := &exchange.Exchange{Name: exchange.BITGET, RateLimit: 0.1, Burst: 1}
+	assert.NoError(t, l.wait(context.Background(), slow))
+	assert.ErrorIs(t, l.wait(context.Background(), slow), errRateLimited)
+}
+
+func TestExchangeLimits_waitN(t *testing.T) {
+	var l exchangeLimits
+
+	// A call costing the burst spends all of it
+	e := &exchange.Exchange{Name: exchange.BINANCE, RateLimit: 0.5, Burst: 5}
+	assert.NoError(t, l.waitN(context.Background(), e, 5))
+	assert.ErrorIs(t, l.wait(context.Background(), e), errRateLimited)
+
+	// Calls costing more than the burst cost the burst
+	e = &exchange.Exchange{Name: exchange.BYBIT, RateLimit: 0.5, Burst: 2}
+	assert.NoError(t, l.waitN(context.Background(), e, 40))
+	assert.ErrorIs(t, l.wait(context.Background(), e), errRateLimited)
+}
+
+func TestExchangeLimits_wait_unlimited(t *testing.T) {
+	e := &exchange.Exchange{Name: exchange.BINANCE}
+	var l exchangeLimits
+	for range 100 {
+		assert.NoError(t, l.wait(context.Background(), e))
+	}
+}
+
+func TestExchangeLimits_observe(t *testing.T) {
+	tests := []struct {
+		name            string
+		status          int
+		retryAfter      string
+		expectedBlocked time.Duration
+	}{
+		{name: "ok", status: http.StatusOK},
+		{name: "server error", status: http.StatusInternalServerError},
+		{name: "too many requests", status: http.StatusTooManyRequests, retryAfter: "30", expectedBlocked: 30 * time.Second},
+		{name: "banned", status: http.StatusTeapot, retryAfter: "120", expectedBlocked: 2 * time.Minute},
+		{name: "no retry after", status: http.StatusTooManyRequests, expectedBlocked: defaultRetryAfter},
+		{name: "invalid retry after", status: http.StatusTooManyRequests, retryAfter: "soon", expectedBlocked: defaultRetryAfter},
+		{name: "retry after date", status: http.StatusTooManyRequests, retryAfter: time.Now().Add(90 * time.Second).UTC().Format(http.TimeFormat), expectedBlocked: 90 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := exchange.New(exchange.BINANCE)
+			var l exchangeLimits
+
+			resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+			if tt.retryAfter != "" {
+				resp.Header.Set("Retry-After", tt.retryAfter)
+			}
+			l.observe(e, resp)
+
+			err := l.wait(context.Background(), e)
+			if tt.expectedBlocked == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, errRateLimited)
+			assert.WithinDuration(t, time.Now().Add(tt.expectedBlocked), l.get(e).blockedUntil, time.Second)
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name             string
+		headers          map[string]string
+		expectedDuration time.Duration
+		expectedOK       bool
+	}{
+		{name: "none"},
+		{name: "seconds", headers: map[string]string{"Retry-After": "30"}, expectedDuration: 30 * time.Second, expectedOK: true},
+		{name: "http date", headers: map[string]string{"Retry-After": "Thu, 01 Jan 2026 12:02:00 GMT"}, expectedDuration: 2 * time.Minute, expectedOK: true},
+		{name: "past date", headers: map[string]string{"Retry-After": "Thu, 01 Jan 2026 11:00:00 GMT"}},
+		{name: "bybit reset timestamp", headers: map[string]string{"X-Bapi-Limit-Reset-Timestamp": "1767268805500"}, expectedDuration: 5500 * time.Millisecond, expectedOK: true},
+		{name: "reset in seconds", headers: map[string]string{"RateLimit-Reset": "12"}, expectedDuration: 12 * time.Second, expectedOK: true},
+		{name: "reset unix time", headers: map[string]string{"X-RateLimit-Reset": "1767268820"}, expectedDuration: 20 * time.Second, expectedOK: true},
+		{name: "at least a second", headers: map[string]string{"X-Bapi-Limit-Reset-Timestamp": "1767268800100"}, expectedDuration: time.Second, expectedOK: true},
+		{name: "retry after first", headers: map[string]string{"Retry-After": "3", "RateLimit-Reset": "60"}, expectedDuration: 3 * time.Second, expectedOK: true},
+		{name: "invalid skipped", headers: map[string]string{"Retry-After": "later", "RateLimit-Reset": "60"}, expectedDuration: time.Minute, expectedOK: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			for k, v := range tt.headers {
+				h.Set(k, v)
+			}
+
+			d, ok := retryAfter(h, now)
+			assert.Equal(t, tt.expectedOK, ok)
+			assert.Equal(t, tt.expectedDuration, d)
+		})
+	}
+}
+
+func TestServer_fetchPrice_rateLimited(t *testing.T) {
+	s := &Server{client: &mockHTTPClient{doFunc: func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}, Body: http.NoBody}, nil
+	}}}
+	e := exchange.New(exchange.BINANCE)
+
+	_, err := s.fetchPrice(context.Background(), e, "BTCUSDT")
+	assert.EqualError(t, err, "unexpected status code: 429, body: ")
+	assert.NotErrorIs(t, err, errRateLimited)
+	assert.ErrorIs(t, err, aggregator.ErrBackoff)
+
+	// The exchange is not called again until it allows to
+	_, err = s.fetchPrice(context.Background(), e, "BTCUSDT")
+	assert.ErrorIs(t, err, errRateLimited)
+	assert.ErrorIs(t, err, aggregator.ErrBackoff)
+
+	// Both calls are reported as rate limited, with the end of the backoff
+	s.exchanges = []*exchange.Exchange{e}
+	stats := s.exchangeStats()
+	assert.Equal(t, 2, stats[0].Windows["1m"].RateLimited)
+	if assert.NotNil(t, stats[0].BackoffUntil) {
+		assert.WithinDuration(t, time.Now().Add(defaultRetryAfter), *stats[0].BackoffUntil, time.Second)
+	}
+}
+
+func TestServer_fetchPrice_maintenance(t *testing.T) {
+	var calls int
+	s := &Server{client: &mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+		calls++
+		return mockJSONResponse(&http.Response{StatusCode: http.StatusOK}, map[string]any{"error": []string{"EService:Unavailable"}})
+	}}}
+	WithMaintenanceBench(time.Hour)(s)
+	e := exchange.New(exchange.KRAKEN)
+	s.exchanges = []*exchange.Exchange{e}
+
+	_, err := s.fetchPrice(context.Background(), e, "BTCUSDT")
+	assert.EqualError(t, err, "code=EService, msg=Unavailable")
+	assert.ErrorIs(t, err, errMaintenance)
+	assert.ErrorIs(t, err, aggregator.ErrBackoff)
+
+	// The exchange is benched without counting the calls as failures
+	_, err = s.fetchPrice(context.Background(), e, "BTCUSDT")
+	assert.ErrorIs(t, err, errMaintenance)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, "maintenance", s.exchangeStatus(exchange.KRAKEN))
+
+	stats := s.exchangeStats()
+	assert.Equal(t, "maintenance", stats[0].Status)
+	assert.Empty(t, stats[0].LastError)
+	assert.Equal(t, WindowStats{}, stats[0].Windows["1m"])
+	if assert.NotNil(t, stats[0].MaintenanceUntil) {
+		assert.WithinDuration(t, time.Now().Add(time.Hour), *stats[0].MaintenanceUntil, time.Second)
+	}
+
+	s.limits.reset(exchange.KRAKEN)
+	assert.Equal(t, "unknown", s.exchangeStatus(exchange.KRAKEN))
+}
+
+func TestServer_fetchPrice_maintenancePair(t *testing.T) {
+	s := &Server{client: &mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+		<-req.Context().Done()
+		return nil, &url.Error{Op: "Get", URL: req.URL.String(), Err: req.Context().Err()}
+	}}}
+	e := exchange.New(exchange.BINANCE)
+	s.exchanges = []*exchange.Exchange{e}
+
+	// Errors holding a pair mentioning maintenance don't bench the exchange
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err := s.fetchPrice(ctx, e, "MAINTENANCEUSDT")
+	assert.ErrorContains(t, err, "MAINTENANCEUSDT")
+	assert.NotErrorIs(t, err, errMaintenance)
+	assert.NotEqual(t, "maintenance", s.exchangeStatus(exchange.BINANCE))
+}
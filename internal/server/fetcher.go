@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/ivanglie/coinmon/pkg/aggregator"
+)
+
+// Limits of the bodies of responses from exchanges. Price responses are well
+// under a kilobyte, so larger ones are rejected rather than decoded.
+const (
+	maxResponseBody = 64 << 10
+	maxErrorBody    = 4 << 10
+)
+
+// Fetcher fetches prices from a single exchange. Exchanges are called over
+// their REST APIs unless WithFetcher sets another transport for them, e.g. a
+// cache of a WebSocket feed or a test double.
+type Fetcher interface {
+	FetchPrice(ctx context.Context, pair string) (aggregator.Quote, error)
+}
+
+// WithFetcher fetches prices from the exchange name with f
+func WithFetcher(name exchange.Name, f Fetcher) Option {
+	return func(s *Server) {
+		if s.fetchers == nil {
+			s.fetchers = make(map[exchange.Name]Fetcher)
+		}
+		s.fetchers[name] = f
+	}
+}
+
+// pairServer is implemented by fetchers serving only some pairs, e.g. of a
+// DEX, which are not called for others
+type pairServer interface {
+	Serves(pair string) bool
+}
+
+// serves reports whether e is called for pair, not when its fetcher or its
+// symbol list leave it out
+func (s *Server) serves(e *exchange.Exchange, pair string) bool {
+	if ps, ok := s.fetchers[e.Name].(pairServer); ok {
+		return ps.Serves(pair)
+	}
+
+	return s.symbols.lists(e.Name, pair)
+}
+
+// fetcher returns the fetcher of e, its REST API by default
+func (s *Server) fetcher(e *exchange.Exchange) Fetcher {
+	if f, ok := s.fetchers[e.Name]; ok {
+		return f
+	}
+
+	switch e.Name {
+	case exchange.BINANCE:
+		return &binanceFetcher{s: s, ex: e}
+	case exchange.BYBIT:
+		return &bybitFetcher{s: s, ex: e}
+	case exchange.BITGET:
+		return &bitgetFetcher{s: s, ex: e}
+	case exchange.KRAKEN:
+		return &krakenFetcher{s: s, ex: e}
+	}
+
+	return noFetcher{name: e.Name}
+}
+
+// binanceFetcher fetches prices from the REST API of Binance
+type binanceFetcher struct {
+	s  *Server
+	ex *exchange.Exchange
+}
+
+func (f *binanceFetcher) FetchPrice(ctx context.Context, pair string) (aggregator.Quote, error) {
+	return f.s.fetchREST(ctx, f.ex, pair, exchange.ReadBinancePrice)
+}
+
+// bybitFetcher fetches prices from the REST API of Bybit
+type bybitFetcher struct {
+	s  *Server
+	ex *exchange.Exchange
+}
+
+func (f *bybitFetcher) FetchPrice(ctx context.Context, pair string) (aggregator.Quote, error) {
+	return f.s.fetchREST(ctx, f.ex, pair, exchange.ReadBybitPrice)
+}
+
+// bitgetFetcher fetches prices from the REST API of Bitget
+type bitgetFetcher struct {
+	s  *Server
+	ex *exchange.Exchange
+}
+
+func (f *bitgetFetcher) FetchPrice(ctx context.Context, pair string) (aggregator.Quote, error) {
+	return f.s.fetchREST(ctx, f.ex, pair, exchange.ReadBitgetPrice)
+}
+
+// krakenFetcher fetches prices from the REST API of Kraken, which answers
+// with the prices of its own names of pairs and reports errors with status 200
+type krakenFetcher struct {
+	s  *Server
+	ex *exchange.Exchange
+}
+
+func (f *krakenFetcher) FetchPrice(ctx context.Context, pair string) (aggregator.Quote, error) {
+	symbol := exchange.Symbol(exchange.KRAKEN, pair)
+	return f.s.fetchREST(ctx, f.ex, pair, func(body io.Reader) (float64, error) {
+		return exchange.ReadKrakenPrice(body, symbol)
+	})
+}
+
+// noFetcher fails calls to exchanges without a REST fetcher
+type noFetcher struct {
+	name exchange.Name
+}
+
+func (f noFetcher) FetchPrice(context.Context, string) (aggregator.Quote, error) {
+	return aggregator.Quote{}, fmt.Errorf("no fetcher of %s", f.name)
+}
+
+// priceReader returns the price in body, a response of a price API
+type priceReader func(body io.Reader) (float64, error)
+
+// fetchREST quotes pair from the REST API of e, reading its responses with
+// read, honoring the rate limits of e and the limit of concurrent upstream
+// requests
+func (s *Server) fetchREST(ctx context.Context, e *exchange.Exchange, pair string, read priceReader) (aggregator.Quote, error) {
+	price, err := s.fetchHTTP(ctx, e, pair, read)
+	if err != nil {
+		return aggregator.Quote{}, err
+	}
+
+	return aggregator.Quote{Pair: pair, Price: price, Source: e.Name.String(), Time: time.Now().UTC()}, nil
+}
@@ -0,0 +1,368 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/ivanglie/coinmon/internal/fakeex"
+	"github.com/ivanglie/coinmon/internal/vcr"
+	"github.com/ivanglie/coinmon/pkg/aggregator"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeFetcher quotes every pair at price after delay, at time or now, or
+// fails with err
+type fakeFetcher struct {
+	price float64
+	time  time.Time
+	delay time.Duration
+	err   error
+	calls atomic.Int32
+}
+
+func (f *fakeFetcher) FetchPrice(ctx context.Context, pair string) (aggregator.Quote, error) {
+	f.calls.Add(1)
+	select {
+	case <-ctx.Done():
+		return aggregator.Quote{}, ctx.Err()
+	case <-time.After(f.delay):
+	}
+	if f.err != nil {
+		return aggregator.Quote{}, f.err
+	}
+
+	if f.time.IsZero() {
+		return aggregator.Quote{Pair: pair, Price: f.price, Time: time.Now()}, nil
+	}
+
+	return aggregator.Quote{Pair: pair, Price: f.price, Time: f.time}, nil
+}
+
+// routedFetcher fetches the listed pairs with their fetchers, other pairs with
+// next
+type routedFetcher struct {
+	pairs map[string]Fetcher
+	next  Fetcher
+}
+
+func (f *routedFetcher) FetchPrice(ctx context.Context, pair string) (aggregator.Quote, error) {
+	if pf, ok := f.pairs[pair]; ok {
+		return pf.FetchPrice(ctx, pair)
+	}
+
+	return f.next.FetchPrice(ctx, pair)
+}
+
+// mockFetchers quotes every pair on the exchanges at the prices of
+// mockSuccessfulResponse
+func mockFetchers() map[exchange.Name]Fetcher {
+	return mockDelayedFetchers(nil)
+}
+
+// mockDelayedFetchers are mockFetchers answering after the delays by
+// exchange name
+func mockDelayedFetchers(delays map[string]time.Duration) map[exchange.Name]Fetcher {
+	return map[exchange.Name]Fetcher{
+		exchange.BINANCE: &fakeFetcher{price: 99999.99, delay: delays["binance"]},
+		exchange.BYBIT:   &fakeFetcher{price: 99999.98, delay: delays["bybit"]},
+		exchange.BITGET:  &fakeFetcher{price: 99999.97, delay: delays["bitget"]},
+		exchange.KRAKEN:  &fakeFetcher{price: 99999.96, delay: delays["kraken"]},
+	}
+}
+
+// mockPairErrorFetchers fail like the exchanges asked for a pair they don't
+// list, Binance with binanceCode and binanceMsg
+func mockPairErrorFetchers(binanceCode int, binanceMsg string) map[exchange.Name]Fetcher {
+	statusErr := func(code, msg string) error {
+		return &exchange.StatusError{StatusCode: http.StatusBadRequest, Err: &exchange.Error{Code: code, Msg: msg}}
+	}
+
+	return map[exchange.Name]Fetcher{
+		exchange.BINANCE: &fakeFetcher{err: statusErr(strconv.Itoa(binanceCode), binanceMsg)},
+		exchange.BYBIT:   &fakeFetcher{err: statusErr("10001", "Not supported symbols")},
+		exchange.BITGET:  &fakeFetcher{err: statusErr("40034", "Parameter does not exist")},
+		exchange.KRAKEN:  &fakeFetcher{err: &exchange.Error{Code: "EQuery", Msg: "Unknown asset pair"}},
+	}
+}
+
+// mockInvalidPairFetchers and mockEmptyPairFetchers fail like the exchanges
+// asked for an invalid and an empty pair
+func mockInvalidPairFetchers() map[exchange.Name]Fetcher {
+	return mockPairErrorFetchers(-1100, "Illegal characters found in parameter 'symbol'; legal range is '^[A-Z0-9_.]{1,20}$'.")
+}
+
+func mockEmptyPairFetchers() map[exchange.Name]Fetcher {
+	return mockPairErrorFetchers(-1105, "Parameter 'symbol' was empty.")
+}
+
+// mockErrorFetchers fail like the exchanges answering mockErrorResponse
+func mockErrorFetchers() map[exchange.Name]Fetcher {
+	badRequest := &exchange.StatusError{StatusCode: http.StatusBadRequest, Err: &exchange.Error{Code: "400", Msg: "Bad Request"}}
+
+	return map[exchange.Name]Fetcher{
+		exchange.BINANCE: &fakeFetcher{err: badRequest},
+		exchange.BYBIT:   &fakeFetcher{err: badRequest},
+		exchange.BITGET:  &fakeFetcher{err: badRequest},
+		exchange.KRAKEN:  &fakeFetcher{err: &exchange.StatusError{StatusCode: http.StatusForbidden, Body: "Forbidden"}},
+	}
+}
+
+// mockPairFetchers fetch the listed pairs with their fetchers, other pairs
+// with mockFetchers
+func mockPairFetchers(pairs map[string]map[exchange.Name]Fetcher) map[exchange.Name]Fetcher {
+	fetchers := mockFetchers()
+	for name, next := range fetchers {
+		pf := &routedFetcher{pairs: make(map[string]Fetcher, len(pairs)), next: next}
+		for pair, pairFetchers := range pairs {
+			pf.pairs[pair] = pairFetchers[name]
+		}
+		fetchers[name] = pf
+	}
+
+	return fetchers
+}
+
+func TestWithFetcher(t *testing.T) {
+	quoted := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	binance := &fakeFetcher{price: 42, time: quoted}
+	s, fake := fakeExchanges(t)
+	fake.SetPrice("BTCUSDT", 97000.5)
+	WithFetcher(exchange.BINANCE, binance)(s)
+
+	// Quotes keep the time of the fetcher and get the name of the exchange
+	s.SetExchanges(exchange.BINANCE)
+	q, err := s.latestQuote(context.Background(), "BTCUSDT")
+	assert.NoError(t, err)
+	assert.Equal(t, aggregator.Quote{Pair: "BTCUSDT", Price: 42, Source: "binance", Time: quoted}, q)
+	assert.Equal(t, "up", s.health.get("binance").status())
+	assert.Zero(t, fake.Calls(exchange.BINANCE))
+
+	binance.err = errors.New("unexpected status code: 503")
+	_, _, err = s.firstPriceWithDetails(context.Background(), "BTCUSDT")
+	assert.ErrorContains(t, err, "binance: unexpected status code: 503")
+	assert.Equal(t, "down", s.health.get("binance").status())
+	assert.Equal(t, int32(2), binance.calls.Load())
+
+	// Bybit without a fetcher is called over its REST API
+	s.SetExchanges(exchange.BYBIT)
+	price, source, err := s.firstPriceWithDetails(context.Background(), "BTCUSDT")
+	assert.NoError(t, err)
+	assert.Equal(t, "bybit", source)
+	assert.Equal(t, 97000.5, price)
+	assert.Equal(t, 1, fake.Calls(exchange.BYBIT))
+}
+
+// fakeExchanges returns a server calling all exchanges at a fake exchange
+func fakeExchanges(t *testing.T) (*Server, *fakeex.Server) {
+	fake := fakeex.New()
+	ts := httptest.NewServer(fake)
+	t.Cleanup(ts.Close)
+
+	s := &Server{client: ts.Client()}
+	for _, name := range []exchange.Name{exchange.BINANCE, exchange.BYBIT, exchange.BITGET, exchange.KRAKEN} {
+		s.exchanges = append(s.exchanges, exchange.New(name))
+		WithExchangeBaseURL(name, ts.URL)(s)
+	}
+
+	return s, fake
+}
+
+func TestFetchers(t *testing.T) {
+	tests := []struct {
+		name          string
+		exchange      exchange.Name
+		pair          string
+		failure       fakeex.Failure
+		expectedPrice float64
+		expectedError string
+		expectedIs    error
+	}{
+		{name: "binance", exchange: exchange.BINANCE, pair: "BTCUSDT", expectedPrice: 97000.5},
+		{name: "bybit", exchange: exchange.BYBIT, pair: "BTCUSDT", expectedPrice: 97000.5},
+		{name: "bitget", exchange: exchange.BITGET, pair: "BTCUSDT", expectedPrice: 97000.5},
+		{name: "kraken", exchange: exchange.KRAKEN, pair: "BTCUSDT", expectedPrice: 97000.5},
+		{name: "binance unknown pair", exchange: exchange.BINANCE, pair: "NOPEUSDT", expectedError: "code=-1121, msg=Invalid symbol."},
+		{name: "bybit unknown pair", exchange: exchange.BYBIT, pair: "NOPEUSDT", expectedError: "empty response"},
+		{name: "bitget unknown pair", exchange: exchange.BITGET, pair: "NOPEUSDT", expectedError: "code=40034, msg=Parameter does not exist"},
+		{name: "kraken unknown pair", exchange: exchange.KRAKEN, pair: "NOPEUSDT", expectedError: "code=EQuery, msg=Unknown asset pair"},
+		{
+			name:          "unavailable",
+			exchange:      exchange.BYBIT,
+			pair:          "BTCUSDT",
+			failure:       fakeex.Unavailable,
+			expectedError: "unexpected status code: 503, body: Service Unavailable\n",
+			expectedIs:    aggregator.ErrTransient,
+		},
+		{
+			name:          "rate limited",
+			exchange:      exchange.BITGET,
+			pair:          "BTCUSDT",
+			failure:       fakeex.RateLimited,
+			expectedError: "unexpected status code: 429, body: Too Many Requests\n",
+			expectedIs:    aggregator.ErrBackoff,
+		},
+		{
+			name:          "malformed",
+			exchange:      exchange.KRAKEN,
+			pair:          "BTCUSDT",
+			failure:       fakeex.Malformed,
+			expectedError: "decode response: invalid JSON at offset 10: unexpected end of input, expected value",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, fake := fakeExchanges(t)
+			fake.SetPrice("BTCUSDT", 97000.5)
+			fake.SetFailure(tt.exchange, tt.failure)
+
+			q, err := s.fetcher(s.exchanges[tt.exchange]).FetchPrice(context.Background(), tt.pair)
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				if tt.expectedIs != nil {
+					assert.ErrorIs(t, err, tt.expectedIs)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, aggregator.Quote{Pair: tt.pair, Price: tt.expectedPrice, Source: tt.name, Time: q.Time}, q)
+			assert.WithinDuration(t, time.Now(), q.Time, time.Second)
+			assert.Equal(t, 1, fake.Calls(tt.exchange))
+		})
+	}
+}
+
+func TestServer_fetcher(t *testing.T) {
+	s := &Server{}
+	assert.IsType(t, &binanceFetcher{}, s.fetcher(exchange.New(exchange.BINANCE)))
+	assert.IsType(t, &bybitFetcher{}, s.fetcher(exchange.New(exchange.BYBIT)))
+	assert.IsType(t, &bitgetFetcher{}, s.fetcher(exchange.New(exchange.BITGET)))
+	assert.IsType(t, &krakenFetcher{}, s.fetcher(exchange.New(exchange.KRAKEN)))
+
+	_, err := s.fetcher(exchange.New(exchange.UNISWAP)).FetchPrice(context.Background(), "PEPEETH")
+	assert.EqualError(t, err, "no fetcher of uniswap")
+}
+
+// TestFetchers_Replay parses responses of the exchanges recorded with
+// -record in testdata/upstream.json
+func TestFetchers_Replay(t *testing.T) {
+	replayer, err := vcr.NewReplayer("testdata/upstream.json")
+	assert.NoError(t, err)
+
+	s := &Server{}
+	WithTransport(replayer)(s)
+
+	tests := []struct {
+		name          exchange.Name
+		pair          string
+		expectedPrice float64
+		expectedError string
+	}{
+		{name: exchange.BINANCE, pair: "BTCUSDT", expectedPrice: 97123.45},
+		{name: exchange.BINANCE, pair: "NOPEUSDT", expectedError: "code=-1121, msg=Invalid symbol."},
+		{name: exchange.BYBIT, pair: "BTCUSDT", expectedPrice: 97120.55},
+		{name: exchange.BYBIT, pair: "NOPEUSDT", expectedError: "empty response"},
+		{name: exchange.BITGET, pair: "BTCUSDT", expectedPrice: 97118.73},
+		{name: exchange.BITGET, pair: "NOPEUSDT", expectedError: "code=40034, msg=Parameter NOPEUSDT does not exist"},
+		{name: exchange.KRAKEN, pair: "BTCUSDT", expectedPrice: 97125.1},
+		{name: exchange.KRAKEN, pair: "NOPEUSDT", expectedError: "code=EQuery, msg=Unknown asset pair"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name.String()+"/"+tt.pair, func(t *testing.T) {
+			q, err := s.fetcher(exchange.New(tt.name)).FetchPrice(context.Background(), tt.pair)
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.pair, q.Pair)
+			assert.Equal(t, tt.expectedPrice, q.Price)
+			assert.Equal(t, tt.name.String(), q.Source)
+			assert.WithinDuration(t, time.Now(), q.Time, time.Second)
+		})
+	}
+}
+
+func TestFetchers_Headers(t *testing.T) {
+	var got http.Header
+	s := &Server{
+		exchanges: []*exchange.Exchange{exchange.New(exchange.BINANCE), exchange.New(exchange.BYBIT)},
+		client: &mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+			got = req.Header.Clone()
+			return mockSuccessfulResponse(req)
+		}},
+	}
+	WithExchangeHeaders(exchange.BINANCE, map[string]string{"X-MBX-APIKEY": "key", "User-Agent": "custom"})(s)
+
+	_, err := s.fetcher(s.exchanges[0]).FetchPrice(context.Background(), "BTCUSDT")
+	assert.NoError(t, err)
+	assert.Equal(t, "key", got.Get("X-MBX-APIKEY"))
+	assert.Equal(t, "custom", got.Get("User-Agent"))
+
+	_, _ = s.fetcher(s.exchanges[1]).FetchPrice(context.Background(), "BTCUSDT")
+	assert.Empty(t, got.Get("X-MBX-APIKEY"))
+	assert.Equal(t, userAgent, got.Get("User-Agent"))
+}
+
+func TestFetchers_BodyLimit(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		body          string
+		readErr       error // after body
+		expectedError string
+	}{
+		{
+			name:          "oversized response",
+			status:        http.StatusOK,
+			body:          `{"symbol":"BTCUSDT","price":"1","pad":"` + strings.Repeat("x", maxResponseBody) + `"}`,
+			expectedError: fmt.Sprintf("response exceeds %d bytes", maxResponseBody),
+		},
+		{
+			name:          "oversized padding after price",
+			status:        http.StatusOK,
+			body:          `{"symbol":"BTCUSDT","price":"1"}` + strings.Repeat(" ", maxResponseBody),
+			expectedError: fmt.Sprintf("response exceeds %d bytes", maxResponseBody),
+		},
+		{
+			name:          "read error",
+			status:        http.StatusOK,
+			body:          `{"symbol":"BTCUSDT","pr`,
+			readErr:       errors.New("connection reset"),
+			expectedError: "read body: connection reset",
+		},
+		{
+			name:          "oversized error response",
+			status:        http.StatusBadRequest,
+			body:          `{"code":-1121,"msg":"` + strings.Repeat("x", maxErrorBody) + `"}`,
+			expectedError: "unexpected status code: 400, body: {\"code\":-1121,\"msg\":\"" + strings.Repeat("x", maxErrorBody-21),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{client: &mockHTTPClient{doFunc: func(*http.Request) (*http.Response, error) {
+				body := io.Reader(strings.NewReader(tt.body))
+				if tt.readErr != nil {
+					body = io.MultiReader(body, iotest.ErrReader(tt.readErr))
+				}
+				return &http.Response{StatusCode: tt.status, Body: io.NopCloser(body)}, nil
+			}}}
+
+			_, err := s.fetcher(exchange.New(exchange.BINANCE)).FetchPrice(context.Background(), "BTCUSDT")
+			assert.EqualError(t, err, tt.expectedError)
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// fieldSelection is the ?fields of a request, e.g. price,source, limiting
+// responses to the fields clients need. It is nil when unset.
+type fieldSelection []string
+
+// parseFields returns the ?fields of r, which must name JSON fields of the
+// struct type T
+func parseFields[T any](r *http.Request) (fieldSelection, error) {
+	v := r.URL.Query().Get("fields")
+	if v == "" {
+		return nil, nil
+	}
+
+	known := jsonFields(reflect.TypeFor[T]())
+	var f fieldSelection
+	for name := range strings.SplitSeq(v, ",") {
+		if name = strings.TrimSpace(name); name == "" || slices.Contains(f, name) {
+			continue
+		}
+		if !slices.Contains(known, name) {
+			return nil, fmt.Errorf("unknown field %q, expected one of %s", name, strings.Join(known, ", "))
+		}
+		f = append(f, name)
+	}
+
+	return f, nil
+}
+
+// jsonFields returns the names of the JSON fields of the struct type t
+func jsonFields(t reflect.Type) []string {
+	var names []string
+	for field := range t.Fields() {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// apply returns v, an object or an array of objects, with only the selected
+// fields, or v itself when none are
+func (f fieldSelection) apply(v any) (any, error) {
+	if f == nil {
+		return v, nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) > 0 && b[0] == '[' {
+		var objects []map[string]json.RawMessage
+		if err := json.Unmarshal(b, &objects); err != nil {
+			return nil, err
+		}
+		for _, o := range objects {
+			f.filter(o)
+		}
+		return objects, nil
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(b, &object); err != nil {
+		return nil, err
+	}
+	f.filter(object)
+
+	return object, nil
+}
+
+func (f fieldSelection) filter(object map[string]json.RawMessage) {
+	for name := range object {
+		if !slices.Contains(f, name) {
+			delete(object, name)
+		}
+	}
+}
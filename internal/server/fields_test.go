@@ -0,0 +1,68 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFields(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		expectedFields fieldSelection
+		expectedError  string
+	}{
+		{name: "unset", query: ""},
+		{name: "fields", query: "?fields=price,source", expectedFields: fieldSelection{"price", "source"}},
+		{name: "spaces and duplicates", query: "?fields=price,+price,,source", expectedFields: fieldSelection{"price", "source"}},
+		{name: "unknown field", query: "?fields=price,Price", expectedError: `unknown field "Price", expected one of pair, price, source, stale, time`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, err := parseFields[DetailedResponse](httptest.NewRequest(http.MethodGet, "/"+tt.query, http.NoBody))
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedFields, fields)
+		})
+	}
+}
+
+func TestServer_HandleSpot_Fields(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "detailed", query: "?details=true&fields=price,source", expectedStatus: http.StatusOK, expectedBody: `{"price":99999.99,"source":"binance"}`},
+		{name: "implies detailed", query: "?fields=price", expectedStatus: http.StatusOK, expectedBody: `{"price":99999.99}`},
+		{name: "unknown field", query: "?fields=latency", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{
+				events:    bus.New(),
+				exchanges: exchanges[:1],
+				fetchers:  mockFetchers(),
+			}
+
+			w := httptest.NewRecorder()
+			s.routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/spot/BTCUSDT"+tt.query, http.NoBody))
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,316 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/graph-gophers/graphql-go"
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/ivanglie/coinmon/pkg/log"
+)
+
+const (
+	maxGraphQLBody  = 64 << 10
+	maxGraphQLDepth = 5
+)
+
+const graphqlSchema = `
+scalar Time
+
+type Query {
+	# Fastest price of a pair
+	price(pair: String!): Price!
+	# Prices of a pair quoted by every exchange
+	ticker(pair: String!): [Quote!]!
+	# Recent prices of a pair, newest first
+	history(pair: String!, limit: Int = 50): [Price!]!
+	# Exchanges prices are resolved from
+	exchanges: [Exchange!]!
+}
+
+type Subscription {
+	# Current price of a pair followed by every update
+	price(pair: String!): Price!
+}
+
+type Price {
+	pair: String!
+	price: Float!
+	source: String!
+	time: Time!
+}
+
+type Quote {
+	exchange: String!
+	price: Float
+	error: String
+}
+
+type Exchange {
+	name: String!
+	baseUrl: String!
+	streaming: Boolean!
+	# "up", "down" or "unknown" depending on the latest request
+	status: String!
+	lastSuccess: Time
+	lastError: String
+}
+`
+
+type graphqlRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// HandleGraphQL handles /graphql requests. Queries are sent as POST,
+// subscriptions over a WebSocket using the graphql-transport-ws protocol.
+func (s *Server) HandleGraphQL(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		s.handleGraphQLWS(w, r)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req graphqlRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxGraphQLBody)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	resp := s.graphqlSchema().Exec(withFieldBudget(r.Context()), req.Query, req.OperationName, req.Variables)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Error("Failed to encode response: " + err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) graphqlSchema() *graphql.Schema {
+	s.schemaOnce.Do(func() {
+		s.schema = graphql.MustParseSchema(graphqlSchema, &graphqlResolver{s: s}, graphql.MaxDepth(maxGraphQLDepth))
+	})
+
+	return s.schema
+}
+
+// fieldBudgetKey is the context key of the price and ticker fields an
+// operation may still resolve
+type fieldBudgetKey struct{}
+
+// withFieldBudget returns ctx allowing maxBatchPairs price and ticker fields,
+// so aliases can't make one operation call exchanges for any number of pairs
+func withFieldBudget(ctx context.Context) context.Context {
+	budget := new(atomic.Int32)
+	budget.Store(maxBatchPairs)
+
+	return context.WithValue(ctx, fieldBudgetKey{}, budget)
+}
+
+// spendField takes a field from the budget of ctx, if any
+func spendField(ctx context.Context) error {
+	budget, ok := ctx.Value(fieldBudgetKey{}).(*atomic.Int32)
+	if ok && budget.Add(-1) < 0 {
+		return fmt.Errorf("too many price and ticker fields, max %d", maxBatchPairs)
+	}
+
+	return nil
+}
+
+// graphqlResolver is the root resolver of the GraphQL schema
+type graphqlResolver struct {
+	s *Server
+}
+
+func (g *graphqlResolver) Query() *queryResolver {
+	return &queryResolver{s: g.s}
+}
+
+func (g *graphqlResolver) Subscription() *subscriptionResolver {
+	return &subscriptionResolver{s: g.s}
+}
+
+// queryResolver resolves GraphQL queries
+type queryResolver struct {
+	s *Server
+}
+
+func (g *queryResolver) Price(ctx context.Context, args struct{ Pair string }) (*priceResolver, error) {
+	pair := strings.ToUpper(strings.TrimSpace(args.Pair))
+	if pair == "" {
+		return nil, fmt.Errorf("missing trading pair")
+	}
+	if err := spendField(ctx); err != nil {
+		return nil, err
+	}
+
+	price, source, err := g.s.price(ctx, pair)
+	if err != nil {
+		return nil, err
+	}
+
+	return &priceResolver{g.s.resolved(pair, source, price)}, nil
+}
+
+func (g *queryResolver) Ticker(ctx context.Context, args struct{ Pair string }) ([]*quoteResolver, error) {
+	pair := strings.ToUpper(strings.TrimSpace(args.Pair))
+	if pair == "" {
+		return nil, fmt.Errorf("missing trading pair")
+	}
+	if err := spendField(ctx); err != nil {
+		return nil, err
+	}
+
+	exchanges := slices.DeleteFunc(slices.Clone(g.s.activeExchanges()), func(ex *exchange.Exchange) bool {
+		return !g.s.serves(ex, pair)
+	})
+	quotes := make([]*quoteResolver, len(exchanges))
+
+	var wg sync.WaitGroup
+	for i, ex := range exchanges {
+		wg.Add(1)
+		go func(i int, ex *exchange.Exchange) {
+			defer wg.Done()
+			fq, err := g.s.fetchPrice(ctx, ex, pair)
+			g.s.health.record(ctx, ex.Name.String(), err)
+
+			q := &quoteResolver{exchange: ex.Name.String()}
+			if err != nil {
+				msg := err.Error()
+				q.err = &msg
+			} else {
+				q.price = &fq.Price
+			}
+			quotes[i] = q
+		}(i, ex)
+	}
+	wg.Wait()
+
+	return quotes, nil
+}
+
+func (g *queryResolver) History(args struct {
+	Pair  string
+	Limit int32
+}) []*priceResolver {
+	pair := strings.ToUpper(strings.TrimSpace(args.Pair))
+
+	var prices []*priceResolver
+	for _, u := range g.s.events.History(pair, int(args.Limit)) {
+		prices = append(prices, &priceResolver{u})
+	}
+
+	return prices
+}
+
+func (g *queryResolver) Exchanges() []*exchangeResolver {
+	var exchanges []*exchangeResolver
+	for _, ex := range g.s.activeExchanges() {
+		exchanges = append(exchanges, &exchangeResolver{ex: ex, health: g.s.health.get(ex.Name.String())})
+	}
+
+	return exchanges
+}
+
+// subscriptionResolver resolves GraphQL subscriptions from the same events
+// that feed the SSE and WebSocket APIs
+type subscriptionResolver struct {
+	s *Server
+}
+
+func (g *subscriptionResolver) Price(ctx context.Context, args struct{ Pair string }) (<-chan *priceResolver, error) {
+	pair := strings.ToUpper(strings.TrimSpace(args.Pair))
+	if pair == "" {
+		return nil, fmt.Errorf("missing trading pair")
+	}
+
+	// Subscribe before resolving the current price so no update is missed
+	ch, unsubscribe := g.s.events.Subscribe(pair)
+
+	price, source, err := g.s.price(ctx, pair)
+	if err != nil {
+		unsubscribe()
+		return nil, err
+	}
+
+	out := make(chan *priceResolver)
+	go func() {
+		defer close(out)
+		defer unsubscribe()
+
+		u := bus.PriceUpdated{Pair: pair, Price: price, Source: source, Time: time.Now().UTC()}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case out <- &priceResolver{u}:
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case u = <-ch:
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+type priceResolver struct {
+	u bus.PriceUpdated
+}
+
+func (r *priceResolver) Pair() string       { return r.u.Pair }
+func (r *priceResolver) Price() float64     { return r.u.Price }
+func (r *priceResolver) Source() string     { return r.u.Source }
+func (r *priceResolver) Time() graphql.Time { return graphql.Time{Time: r.u.Time} }
+
+type quoteResolver struct {
+	exchange string
+	price    *float64
+	err      *string
+}
+
+func (r *quoteResolver) Exchange() string { return r.exchange }
+func (r *quoteResolver) Price() *float64  { return r.price }
+func (r *quoteResolver) Error() *string   { return r.err }
+
+type exchangeResolver struct {
+	ex     *exchange.Exchange
+	health exchangeHealth
+}
+
+func (r *exchangeResolver) Name() string    { return r.ex.Name.String() }
+func (r *exchangeResolver) BaseURL() string { return r.ex.BaseURL }
+func (r *exchangeResolver) Streaming() bool { return r.ex.StreamURL != "" }
+func (r *exchangeResolver) Status() string  { return r.health.status() }
+
+func (r *exchangeResolver) LastSuccess() *graphql.Time {
+	if r.health.LastSuccess.IsZero() {
+		return nil
+	}
+
+	return &graphql.Time{Time: r.health.LastSuccess}
+}
+
+func (r *exchangeResolver) LastError() *string {
+	if r.health.LastError == "" {
+		return nil
+	}
+
+	return &r.health.LastError
+}
@@ -0,0 +1,230 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/stretchr/testify/assert"
+)
+
+type graphqlResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func graphqlQuery(t *testing.T, s *Server, body string) (int, graphqlResponse) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	s.HandleGraphQL(w, req)
+
+	var resp graphqlResponse
+	if w.Code == http.StatusOK {
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	}
+
+	return w.Code, resp
+}
+
+// aliasedPrices returns a request body of a query of n aliased price fields
+func aliasedPrices(n int) string {
+	var b strings.Builder
+	for i := range n {
+		fmt.Fprintf(&b, `p%d: price(pair: \"BTCUSDT\") { price } `, i)
+	}
+
+	return `{"query":"{ ` + b.String() + `}"}`
+}
+
+func TestServer_HandleGraphQL(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		body           string
+		allExchanges   bool
+		fetchers       map[exchange.Name]Fetcher
+		expectedStatus int
+		expectedData   string
+		expectedError  string
+	}{
+		{
+			name:           "price",
+			body:           `{"query":"{ price(pair: \"btcusdt\") { pair price source } }"}`,
+			fetchers:       mockFetchers(),
+			expectedStatus: http.StatusOK,
+			expectedData:   `{"price":{"pair":"BTCUSDT","price":99999.99,"source":"binance"}}`,
+		},
+		{
+			name:           "price with variables",
+			body:           `{"query":"query($p: String!) { price(pair: $p) { price } }","variables":{"p":"BTCUSDT"}}`,
+			fetchers:       mockFetchers(),
+			expectedStatus: http.StatusOK,
+			expectedData:   `{"price":{"price":99999.99}}`,
+		},
+		{
+			name:           "price of unknown pair",
+			body:           `{"query":"{ price(pair: \"INVALID\") { price } }"}`,
+			fetchers:       mockInvalidPairFetchers(),
+			expectedStatus: http.StatusOK,
+			expectedData:   `null`,
+			expectedError:  "all exchanges failed",
+		},
+		{
+			name:           "missing pair",
+			body:           `{"query":"{ price(pair: \" \") { price } }"}`,
+			fetchers:       mockFetchers(),
+			expectedStatus: http.StatusOK,
+			expectedData:   `null`,
+			expectedError:  "missing trading pair",
+		},
+		{
+			name:           "ticker",
+			body:           `{"query":"{ ticker(pair: \"BTCUSDT\") { exchange price error } }"}`,
+			allExchanges:   true,
+			fetchers:       mockFetchers(),
+			expectedStatus: http.StatusOK,
+			expectedData: `{"ticker":[` +
+				`{"exchange":"binance","price":99999.99,"error":null},` +
+				`{"exchange":"bybit","price":99999.98,"error":null},` +
+				`{"exchange":"bitget","price":99999.97,"error":null},` +
+				`{"exchange":"kraken","price":99999.96,"error":null}]}`,
+		},
+		{
+			name:           "ticker with failing exchanges",
+			body:           `{"query":"{ ticker(pair: \"BTCUSDT\") { exchange price error } }"}`,
+			allExchanges:   true,
+			fetchers:       mockErrorFetchers(),
+			expectedStatus: http.StatusOK,
+			expectedData: `{"ticker":[` +
+				`{"exchange":"binance","price":null,"error":"code=400, msg=Bad Request"},` +
+				`{"exchange":"bybit","price":null,"error":"code=400, msg=Bad Request"},` +
+				`{"exchange":"bitget","price":null,"error":"code=400, msg=Bad Request"},` +
+				`{"exchange":"kraken","price":null,"error":"unexpected status code: 403, body: Forbidden"}]}`,
+		},
+		{
+			name:           "aliased prices at limit",
+			body:           aliasedPrices(maxBatchPairs),
+			fetchers:       mockFetchers(),
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "too many aliased prices",
+			body:           aliasedPrices(maxBatchPairs + 1),
+			fetchers:       mockFetchers(),
+			expectedStatus: http.StatusOK,
+			expectedData:   `null`,
+			expectedError:  "too many price and ticker fields, max 20",
+		},
+		{
+			name:           "invalid query",
+			body:           `{"query":"{ unknown }"}`,
+			fetchers:       mockFetchers(),
+			expectedStatus: http.StatusOK,
+			expectedError:  `Cannot query field "unknown" on type "Query".`,
+		},
+		{
+			name:           "invalid body",
+			body:           `{`,
+			fetchers:       mockFetchers(),
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "method not allowed",
+			method:         http.MethodGet,
+			fetchers:       mockFetchers(),
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{
+				events:    bus.New(),
+				exchanges: exchanges[:1],
+				fetchers:  tt.fetchers,
+			}
+			if tt.allExchanges {
+				s.exchanges = exchanges
+			}
+
+			if tt.method != "" {
+				req := httptest.NewRequest(tt.method, "/graphql", http.NoBody)
+				w := httptest.NewRecorder()
+				s.HandleGraphQL(w, req)
+				assert.Equal(t, tt.expectedStatus, w.Code)
+				return
+			}
+
+			code, resp := graphqlQuery(t, s, tt.body)
+			assert.Equal(t, tt.expectedStatus, code)
+			if code != http.StatusOK {
+				return
+			}
+
+			if tt.expectedData != "" {
+				assert.JSONEq(t, tt.expectedData, string(resp.Data))
+			}
+			if tt.expectedError == "" {
+				assert.Empty(t, resp.Errors)
+				return
+			}
+
+			assert.NotEmpty(t, resp.Errors)
+			assert.Contains(t, resp.Errors[0].Message, tt.expectedError)
+		})
+	}
+}
+
+func TestServer_HandleGraphQL_History(t *testing.T) {
+	s := &Server{exchanges: exchanges, events: bus.New()}
+
+	t0 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 3 {
+		s.events.Publish(bus.PriceUpdated{Pair: "BTCUSDT", Price: float64(100 + i), Source: "binance", Time: t0.Add(time.Duration(i) * time.Second)})
+	}
+
+	_, resp := graphqlQuery(t, s, `{"query":"{ history(pair: \"btcusdt\", limit: 2) { price time } }"}`)
+	assert.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"history":[{"price":102,"time":"2025-01-01T00:00:02Z"},{"price":101,"time":"2025-01-01T00:00:01Z"}]}`, string(resp.Data))
+
+	_, resp = graphqlQuery(t, s, `{"query":"{ history(pair: \"ETHUSDT\") { price } }"}`)
+	assert.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"history":[]}`, string(resp.Data))
+}
+
+func TestServer_HandleGraphQL_Exchanges(t *testing.T) {
+	s := &Server{
+		events:    bus.New(),
+		exchanges: exchanges,
+		fetchers:  mockFetchers(),
+	}
+
+	_, resp := graphqlQuery(t, s, `{"query":"{ exchanges { name status lastSuccess } }"}`)
+	assert.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"exchanges":[`+
+		`{"name":"binance","status":"unknown","lastSuccess":null},`+
+		`{"name":"bybit","status":"unknown","lastSuccess":null},`+
+		`{"name":"bitget","status":"unknown","lastSuccess":null},`+
+		`{"name":"kraken","status":"unknown","lastSuccess":null}]}`, string(resp.Data))
+
+	_, resp = graphqlQuery(t, s, `{"query":"{ ticker(pair: \"BTCUSDT\") { price } }"}`)
+	assert.Empty(t, resp.Errors)
+
+	_, resp = graphqlQuery(t, s, `{"query":"{ exchanges { name baseUrl streaming status lastError } }"}`)
+	assert.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"exchanges":[`+
+		`{"name":"binance","baseUrl":"https://api.binance.com","streaming":true,"status":"up","lastError":null},`+
+		`{"name":"bybit","baseUrl":"https://api.bybit.com","streaming":true,"status":"up","lastError":null},`+
+		`{"name":"bitget","baseUrl":"https://api.bitget.com","streaming":true,"status":"up","lastError":null},`+
+		`{"name":"kraken","baseUrl":"https://api.kraken.com","streaming":false,"status":"up","lastError":null}]}`, string(resp.Data))
+}
@@ -0,0 +1,286 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/graph-gophers/graphql-go"
+	"github.com/ivanglie/coinmon/pkg/log"
+)
+
+const (
+	graphqlWSProtocol = "graphql-transport-ws"
+	graphqlInitWait   = 10 * time.Second
+)
+
+// graphql-transport-ws close codes
+const (
+	closeBadRequest       websocket.StatusCode = 4400
+	closeUnauthorized     websocket.StatusCode = 4401
+	closeUnsupported      websocket.StatusCode = 4406
+	closeInitTimeout      websocket.StatusCode = 4408
+	closeSubscriberExists websocket.StatusCode = 4409
+	closeTooManyInit      websocket.StatusCode = 4429
+)
+
+// graphqlWSMessage represents a graphql-transport-ws protocol message
+type graphqlWSMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// graphqlWSSession tracks operations of a single graphql-transport-ws connection
+type graphqlWSSession struct {
+	s     *Server
+	c     *websocket.Conn
+	out   chan graphqlWSMessage
+	mu    sync.Mutex
+	acked bool
+	ops   map[string]context.CancelFunc
+}
+
+// handleGraphQLWS serves GraphQL operations over a WebSocket.
+// See https://github.com/enisdenjo/graphql-ws/blob/master/PROTOCOL.md
+func (s *Server) handleGraphQLWS(w http.ResponseWriter, r *http.Request) {
+	release, ok := s.acquireSubscriber(w)
+	if !ok {
+		return
+	}
+	defer release()
+
+	// Connections outlive the server read and write timeouts
+	rc := http.NewResponseController(w)
+	if err := rc.SetReadDeadline(time.Time{}); err != nil {
+		log.Debug("Failed to clear read deadline: " + err.Error())
+	}
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		log.Debug("Failed to clear write deadline: " + err.Error())
+	}
+
+	c, err := websocket.Accept(w, r, &websocket.AcceptOptions{
+		Subprotocols:       []string{graphqlWSProtocol},
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		log.Error("Failed to accept websocket: " + err.Error())
+		return
+	}
+
+	defer func() { _ = c.CloseNow() }()
+
+	if c.Subprotocol() != graphqlWSProtocol {
+		_ = c.Close(closeUnsupported, "Subprotocol not acceptable")
+		return
+	}
+
+	ctx, cancel := s.streamContext(context.WithoutCancel(r.Context()))
+	defer cancel()
+
+	sess := &graphqlWSSession{
+		s:   s,
+		c:   c,
+		out: make(chan graphqlWSMessage, 16),
+		ops: make(map[string]context.CancelFunc),
+	}
+
+	go func() {
+		defer cancel()
+		sess.read(ctx)
+	}()
+
+	init := time.AfterFunc(graphqlInitWait, func() {
+		sess.mu.Lock()
+		defer sess.mu.Unlock()
+		if !sess.acked {
+			_ = c.Close(closeInitTimeout, "Connection initialisation timeout")
+		}
+	})
+	defer init.Stop()
+
+	for {
+		var msg graphqlWSMessage
+		select {
+		case <-ctx.Done():
+			_ = c.Close(websocket.StatusNormalClosure, "")
+			return
+		case msg = <-sess.out:
+		}
+
+		wctx, wcancel := context.WithTimeout(ctx, 10*time.Second)
+		err := wsjson.Write(wctx, c, msg)
+		wcancel()
+		if err != nil {
+			log.Debug("Failed to write websocket message: " + err.Error())
+			return
+		}
+	}
+}
+
+func (sess *graphqlWSSession) read(ctx context.Context) {
+	defer sess.stopAll()
+
+	for {
+		// wsjson would close the connection with its own code on invalid JSON
+		_, b, err := sess.c.Read(ctx)
+		if err != nil {
+			var ce websocket.CloseError
+			if !errors.As(err, &ce) && ctx.Err() == nil {
+				log.Debug("Failed to read websocket message: " + err.Error())
+			}
+			return
+		}
+
+		var msg graphqlWSMessage
+		if err := json.Unmarshal(b, &msg); err != nil {
+			_ = sess.c.Close(closeBadRequest, "Invalid message received")
+			return
+		}
+
+		switch msg.Type {
+		case "connection_init":
+			sess.mu.Lock()
+			acked := sess.acked
+			sess.acked = true
+			sess.mu.Unlock()
+
+			if acked {
+				_ = sess.c.Close(closeTooManyInit, "Too many initialisation requests")
+				return
+			}
+			sess.send(ctx, graphqlWSMessage{Type: "connection_ack"})
+		case "ping":
+			sess.send(ctx, graphqlWSMessage{Type: "pong"})
+		case "pong":
+		case "subscribe":
+			if !sess.initialized() {
+				_ = sess.c.Close(closeUnauthorized, "Unauthorized")
+				return
+			}
+			if !sess.start(ctx, msg) {
+				return
+			}
+		case "complete":
+			sess.stop(msg.ID)
+		default:
+			_ = sess.c.Close(closeBadRequest, "Invalid message received")
+			return
+		}
+	}
+}
+
+func (sess *graphqlWSSession) initialized() bool {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	return sess.acked
+}
+
+// start runs the operation of a subscribe message. It returns false when
+// the connection has been closed due to a protocol violation.
+func (sess *graphqlWSSession) start(ctx context.Context, msg graphqlWSMessage) bool {
+	var req graphqlRequest
+	if msg.ID == "" || json.Unmarshal(msg.Payload, &req) != nil {
+		_ = sess.c.Close(closeBadRequest, "Invalid message received")
+		return false
+	}
+
+	sess.mu.Lock()
+	if _, ok := sess.ops[msg.ID]; ok {
+		sess.mu.Unlock()
+		_ = sess.c.Close(closeSubscriberExists, "Subscriber for "+msg.ID+" already exists")
+		return false
+	}
+	if len(sess.ops) >= maxSubscriptions {
+		sess.mu.Unlock()
+		sess.send(ctx, graphqlWSMessage{ID: msg.ID, Type: "error", Payload: graphqlErrors("too many subscriptions")})
+		return true
+	}
+
+	octx, cancel := context.WithCancel(ctx)
+	sess.ops[msg.ID] = cancel
+	sess.mu.Unlock()
+
+	go sess.run(octx, msg.ID, req)
+	return true
+}
+
+// run forwards results of an operation until it ends or the client completes it
+func (sess *graphqlWSSession) run(ctx context.Context, id string, req graphqlRequest) {
+	defer sess.stop(id)
+
+	results, err := sess.s.graphqlSchema().Subscribe(withFieldBudget(ctx), req.Query, req.OperationName, req.Variables)
+	if err != nil {
+		sess.send(ctx, graphqlWSMessage{ID: id, Type: "error", Payload: graphqlErrors(err.Error())})
+		return
+	}
+
+	first := true
+	for res := range results {
+		resp, ok := res.(*graphql.Response)
+		if !ok {
+			continue
+		}
+
+		// Errors without data mean the operation was rejected before execution
+		if first && resp.Data == nil && len(resp.Errors) > 0 {
+			b, err := json.Marshal(resp.Errors)
+			if err != nil {
+				log.Error("Failed to encode response: " + err.Error())
+				return
+			}
+			sess.send(ctx, graphqlWSMessage{ID: id, Type: "error", Payload: b})
+			return
+		}
+		first = false
+
+		b, err := json.Marshal(resp)
+		if err != nil {
+			log.Error("Failed to encode response: " + err.Error())
+			continue
+		}
+		sess.send(ctx, graphqlWSMessage{ID: id, Type: "next", Payload: b})
+	}
+
+	// Operations completed by the client must not be completed again
+	if ctx.Err() == nil {
+		sess.send(ctx, graphqlWSMessage{ID: id, Type: "complete"})
+	}
+}
+
+func (sess *graphqlWSSession) stop(id string) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	if cancel, ok := sess.ops[id]; ok {
+		cancel()
+		delete(sess.ops, id)
+	}
+}
+
+func (sess *graphqlWSSession) stopAll() {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	for id, cancel := range sess.ops {
+		cancel()
+		delete(sess.ops, id)
+	}
+}
+
+func (sess *graphqlWSSession) send(ctx context.Context, msg graphqlWSMessage) {
+	select {
+	case <-ctx.Done():
+	case sess.out <- msg:
+	}
+}
+
+func graphqlErrors(msg string) json.RawMessage {
+	b, _ := json.Marshal([]map[string]string{{"message": msg}})
+	return b
+}
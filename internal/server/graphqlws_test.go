@@ -0,0 +1,230 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/stretchr/testify/assert"
+)
+
+func dialGraphQLWS(t *testing.T, s *Server, subprotocols ...string) (*websocket.Conn, context.Context) {
+	t.Helper()
+
+	ts := httptest.NewServer(http.HandlerFunc(s.HandleGraphQL))
+	t.Cleanup(ts.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	if subprotocols == nil {
+		subprotocols = []string{graphqlWSProtocol}
+	}
+
+	c, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(ts.URL, "http")+"/graphql", &websocket.DialOptions{Subprotocols: subprotocols})
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = c.CloseNow() })
+
+	return c, ctx
+}
+
+func readGraphQLWS(t *testing.T, ctx context.Context, c *websocket.Conn) graphqlWSMessage {
+	t.Helper()
+
+	var msg graphqlWSMessage
+	assert.NoError(t, wsjson.Read(ctx, c, &msg))
+	return msg
+}
+
+func initGraphQLWS(t *testing.T, ctx context.Context, c *websocket.Conn) {
+	t.Helper()
+
+	assert.NoError(t, wsjson.Write(ctx, c, graphqlWSMessage{Type: "connection_init"}))
+	assert.Equal(t, "connection_ack", readGraphQLWS(t, ctx, c).Type)
+}
+
+func subscribeMessage(id, query string) graphqlWSMessage {
+	b, _ := json.Marshal(graphqlRequest{Query: query})
+	return graphqlWSMessage{ID: id, Type: "subscribe", Payload: b}
+}
+
+func TestServer_HandleGraphQL_Subscription(t *testing.T) {
+	s := &Server{
+		events:    bus.New(),
+		exchanges: exchanges[:1],
+		fetchers:  mockFetchers(),
+	}
+	c, ctx := dialGraphQLWS(t, s)
+	initGraphQLWS(t, ctx, c)
+
+	assert.NoError(t, wsjson.Write(ctx, c, subscribeMessage("1", `subscription { price(pair: "btcusdt") { pair price source } }`)))
+
+	msg := readGraphQLWS(t, ctx, c)
+	assert.Equal(t, "next", msg.Type)
+	assert.Equal(t, "1", msg.ID)
+	assert.JSONEq(t, `{"data":{"price":{"pair":"BTCUSDT","price":99999.99,"source":"binance"}}}`, string(msg.Payload))
+
+	s.events.Publish(bus.PriceUpdated{Pair: "BTCUSDT", Price: 100000, Source: "bybit", Time: time.Now()})
+	msg = readGraphQLWS(t, ctx, c)
+	assert.Equal(t, "next", msg.Type)
+	assert.JSONEq(t, `{"data":{"price":{"pair":"BTCUSDT","price":100000,"source":"bybit"}}}`, string(msg.Payload))
+
+	assert.NoError(t, wsjson.Write(ctx, c, graphqlWSMessage{ID: "1", Type: "complete"}))
+	assert.Eventually(t, func() bool {
+		return s.events.Subscribers("BTCUSDT") == 0
+	}, time.Second, 5*time.Millisecond)
+
+	assert.NoError(t, wsjson.Write(ctx, c, graphqlWSMessage{Type: "ping"}))
+	assert.Equal(t, "pong", readGraphQLWS(t, ctx, c).Type)
+}
+
+func TestServer_HandleGraphQL_SubscriptionQuery(t *testing.T) {
+	s := &Server{
+		events:    bus.New(),
+		exchanges: exchanges[:1],
+		fetchers:  mockFetchers(),
+	}
+	c, ctx := dialGraphQLWS(t, s)
+	initGraphQLWS(t, ctx, c)
+
+	assert.NoError(t, wsjson.Write(ctx, c, subscribeMessage("q", `{ exchanges { name } }`)))
+
+	msg := readGraphQLWS(t, ctx, c)
+	assert.Equal(t, "next", msg.Type)
+	assert.JSONEq(t, `{"data":{"exchanges":[{"name":"binance"}]}}`, string(msg.Payload))
+
+	msg = readGraphQLWS(t, ctx, c)
+	assert.Equal(t, "complete", msg.Type)
+	assert.Equal(t, "q", msg.ID)
+}
+
+func TestServer_HandleGraphQL_SubscriptionErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		fetchers      map[exchange.Name]Fetcher
+		expectedError string
+	}{
+		{
+			name:          "unknown pair",
+			query:         `subscription { price(pair: "INVALID") { price } }`,
+			fetchers:      mockInvalidPairFetchers(),
+			expectedError: "all exchanges failed",
+		},
+		{
+			name:          "missing pair",
+			query:         `subscription { price(pair: "") { price } }`,
+			fetchers:      mockFetchers(),
+			expectedError: "missing trading pair",
+		},
+		{
+			name:          "invalid query",
+			query:         `subscription { unknown }`,
+			fetchers:      mockFetchers(),
+			expectedError: `Cannot query field "unknown" on type "Subscription".`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{
+				events:    bus.New(),
+				exchanges: exchanges[:1],
+				fetchers:  tt.fetchers,
+			}
+			c, ctx := dialGraphQLWS(t, s)
+			initGraphQLWS(t, ctx, c)
+
+			assert.NoError(t, wsjson.Write(ctx, c, subscribeMessage("1", tt.query)))
+
+			msg := readGraphQLWS(t, ctx, c)
+			assert.Equal(t, "error", msg.Type)
+			assert.Equal(t, "1", msg.ID)
+			var errs []struct {
+				Message string `json:"message"`
+			}
+			assert.NoError(t, json.Unmarshal(msg.Payload, &errs))
+			assert.NotEmpty(t, errs)
+			assert.Contains(t, errs[0].Message, tt.expectedError)
+		})
+	}
+}
+
+func TestServer_HandleGraphQL_SubscriptionProtocol(t *testing.T) {
+	tests := []struct {
+		name          string
+		subprotocol   string
+		messages      []any
+		expectedClose websocket.StatusCode
+	}{
+		{
+			name:          "unsupported subprotocol",
+			subprotocol:   "graphql-ws",
+			expectedClose: closeUnsupported,
+		},
+		{
+			name:          "subscribe before init",
+			messages:      []any{subscribeMessage("1", `subscription { price(pair: "BTCUSDT") { price } }`)},
+			expectedClose: closeUnauthorized,
+		},
+		{
+			name:          "repeated init",
+			messages:      []any{graphqlWSMessage{Type: "connection_init"}, graphqlWSMessage{Type: "connection_init"}},
+			expectedClose: closeTooManyInit,
+		},
+		{
+			name:          "unknown message type",
+			messages:      []any{graphqlWSMessage{Type: "start"}},
+			expectedClose: closeBadRequest,
+		},
+		{
+			name:          "invalid message",
+			messages:      []any{"not an object"},
+			expectedClose: closeBadRequest,
+		},
+		{
+			name: "duplicate id",
+			messages: []any{
+				graphqlWSMessage{Type: "connection_init"},
+				subscribeMessage("1", `subscription { price(pair: "BTCUSDT") { price } }`),
+				subscribeMessage("1", `subscription { price(pair: "BTCUSDT") { price } }`),
+			},
+			expectedClose: closeSubscriberExists,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{
+				events:    bus.New(),
+				exchanges: exchanges[:1],
+				fetchers:  mockFetchers(),
+			}
+
+			var subprotocols []string
+			if tt.subprotocol != "" {
+				subprotocols = []string{tt.subprotocol}
+			}
+			c, ctx := dialGraphQLWS(t, s, subprotocols...)
+
+			for _, m := range tt.messages {
+				assert.NoError(t, wsjson.Write(ctx, c, m))
+			}
+
+			var err error
+			for err == nil {
+				var msg graphqlWSMessage
+				err = wsjson.Read(ctx, c, &msg)
+			}
+			assert.Equal(t, tt.expectedClose, websocket.CloseStatus(err))
+		})
+	}
+}
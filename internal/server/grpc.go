@@ -0,0 +1,330 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	coinmonv1 "github.com/ivanglie/coinmon/api/coinmon/v1"
+	"github.com/ivanglie/coinmon/internal/bus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const maxBatchPairs = 20
+
+// grpcService implements coinmon.v1.PriceService
+type grpcService struct {
+	coinmonv1.UnimplementedPriceServiceServer
+	s *Server
+}
+
+// StartGRPC serves the gRPC API on addr, or on the listener set with
+// WithGRPCListener
+func (s *Server) StartGRPC(addr string) error {
+	lis := s.grpcLn
+	if lis == nil {
+		var err error
+		if lis, err = s.listen(addr); err != nil {
+			return fmt.Errorf("listen: %w", err)
+		}
+	}
+	lis = s.limitConns(lis)
+
+	gs := s.grpcServer()
+	s.grpc.Store(gs)
+
+	return gs.Serve(lis)
+}
+
+// stopGRPC stops the gRPC API started with StartGRPC, waiting for active
+// calls to complete until ctx is done
+func (s *Server) stopGRPC(ctx context.Context) {
+	gs := s.grpc.Load()
+	if gs == nil {
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		gs.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		gs.Stop()
+	}
+}
+
+func (s *Server) grpcServer() *grpc.Server {
+	ips := newIPLimiters()
+	gs := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(s.unaryMaintenance, s.unaryAdmit(ips)),
+		grpc.ChainStreamInterceptor(s.streamMaintenance, s.streamAdmit(ips)),
+	)
+	coinmonv1.RegisterPriceServiceServer(gs, &grpcService{s: s})
+	return gs
+}
+
+// grpcMetadata are the metadata of gRPC calls authenticating and limiting
+// them like the headers of HTTP requests
+var grpcMetadata = []string{"X-API-Key", "Authorization", "Cf-Connecting-Ip", "X-Forwarded-For"}
+
+// admitCall authenticates and rate limits a gRPC call like rateLimit does
+// HTTP requests, returning the context of the call with its consumer
+func (s *Server) admitCall(ctx context.Context, method string, ips *ipLimiters) (context.Context, error) {
+	r := &http.Request{Method: http.MethodPost, URL: &url.URL{Path: method}, Header: make(http.Header)}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, k := range grpcMetadata {
+		if v := md.Get(k); len(v) > 0 {
+			r.Header.Set(k, v[0])
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		r.RemoteAddr = p.Addr.String()
+	}
+
+	consumer, limiter, err := s.admit(r.WithContext(ctx), ips)
+	switch {
+	case errors.Is(err, errTooManyRequests):
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	case consumer != "":
+		ctx = context.WithValue(ctx, consumerKey{}, consumer)
+	}
+
+	return context.WithValue(ctx, limiterKey{}, limiter), nil
+}
+
+// unaryAdmit authenticates and rate limits unary gRPC calls
+func (s *Server) unaryAdmit(ips *ipLimiters) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := s.admitCall(ctx, info.FullMethod, ips)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// streamAdmit authenticates and rate limits gRPC streams when they start
+func (s *Server) streamAdmit(ips *ipLimiters) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := s.admitCall(ss.Context(), info.FullMethod, ips)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &admittedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// admittedStream is a gRPC stream with the context of its consumer
+type admittedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (a *admittedStream) Context() context.Context {
+	return a.ctx
+}
+
+// GetSpotPrice returns the fastest price of a pair
+func (g *grpcService) GetSpotPrice(ctx context.Context, req *coinmonv1.GetSpotPriceRequest) (*coinmonv1.GetSpotPriceResponse, error) {
+	pair := strings.ToUpper(strings.TrimSpace(req.GetPair()))
+	if pair == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing trading pair")
+	}
+
+	p, err := g.spotPrice(ctx, pair)
+	if err != nil {
+		return nil, status.Error(priceCode(err), err.Error())
+	}
+
+	return &coinmonv1.GetSpotPriceResponse{Price: p}, nil
+}
+
+// GetBatch returns prices of several pairs at once
+func (g *grpcService) GetBatch(ctx context.Context, req *coinmonv1.GetBatchRequest) (*coinmonv1.GetBatchResponse, error) {
+	if len(req.GetPairs()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "missing trading pairs")
+	}
+	if len(req.GetPairs()) > maxBatchPairs {
+		return nil, status.Errorf(codes.InvalidArgument, "too many trading pairs, max %d", maxBatchPairs)
+	}
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		resp = &coinmonv1.GetBatchResponse{}
+	)
+
+	for _, pair := range req.GetPairs() {
+		pair = strings.ToUpper(strings.TrimSpace(pair))
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p, err := g.spotPrice(ctx, pair)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				resp.Errors = append(resp.Errors, &coinmonv1.PairError{Pair: pair, Message: err.Error()})
+				return
+			}
+			resp.Prices = append(resp.Prices, p)
+		}()
+	}
+	wg.Wait()
+
+	return resp, nil
+}
+
+// ListExchanges returns exchanges prices are resolved from
+func (g *grpcService) ListExchanges(_ context.Context, _ *coinmonv1.ListExchangesRequest) (*coinmonv1.ListExchangesResponse, error) {
+	resp := &coinmonv1.ListExchangesResponse{}
+	for _, ex := range g.s.activeExchanges() {
+		resp.Exchanges = append(resp.Exchanges, &coinmonv1.Exchange{
+			Name:      ex.Name.String(),
+			BaseUrl:   ex.BaseURL,
+			Streaming: ex.StreamURL != "",
+		})
+	}
+
+	return resp, nil
+}
+
+// StreamPrices sends the current prices of pairs followed by every update.
+// Every pair but the first takes a token from the limiter of the call.
+func (g *grpcService) StreamPrices(req *coinmonv1.StreamPricesRequest, stream grpc.ServerStreamingServer[coinmonv1.SpotPrice]) error {
+	var pairs []string
+	seen := make(map[string]bool)
+	for _, p := range req.GetPairs() {
+		p = strings.ToUpper(strings.TrimSpace(p))
+		if p != "" && !seen[p] {
+			seen[p] = true
+			pairs = append(pairs, p)
+		}
+	}
+
+	if len(pairs) == 0 {
+		return status.Error(codes.InvalidArgument, "missing trading pairs")
+	}
+	if len(pairs) > maxSubscriptions {
+		return status.Errorf(codes.InvalidArgument, "too many trading pairs, max %d", maxSubscriptions)
+	}
+
+	if !charge(stream.Context(), len(pairs)-1) {
+		return status.Error(codes.ResourceExhausted, errTooManyRequests.Error())
+	}
+
+	release, ok := g.s.takeSubscriber()
+	if !ok {
+		return status.Error(codes.Unavailable, "too many subscribers")
+	}
+	defer release()
+
+	ctx, cancel := g.s.streamContext(stream.Context())
+	defer cancel()
+
+	out := make(chan bus.PriceUpdated, 16)
+
+	// Subscribe before resolving current prices so no update is missed
+	for _, pair := range pairs {
+		ch, unsubscribe := g.s.events.Subscribe(pair)
+		defer unsubscribe()
+
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case u := <-ch:
+					select {
+					case <-ctx.Done():
+						return
+					case out <- u:
+					}
+				}
+			}
+		}()
+	}
+
+	for _, pair := range pairs {
+		price, source, err := g.s.price(ctx, pair)
+		if err != nil {
+			return status.Errorf(priceCode(err), "%s: %v", pair, err)
+		}
+
+		if err := stream.Send(&coinmonv1.SpotPrice{
+			Pair:   pair,
+			Price:  price,
+			Source: source,
+			Time:   timestamppb.New(time.Now()),
+		}); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			if g.s.streams().Err() != nil {
+				return status.Error(codes.Unavailable, "server shutting down")
+			}
+			return status.FromContextError(ctx.Err()).Err()
+		case u := <-out:
+			if err := stream.Send(&coinmonv1.SpotPrice{
+				Pair:   u.Pair,
+				Price:  u.Price,
+				Source: u.Source,
+				Time:   timestamppb.New(u.Time),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// priceCode returns the status code of err resolving a price
+func priceCode(err error) codes.Code {
+	if errors.Is(err, ErrPairForbidden) {
+		return codes.PermissionDenied
+	}
+
+	return codes.Unavailable
+}
+
+func (g *grpcService) spotPrice(ctx context.Context, pair string) (*coinmonv1.SpotPrice, error) {
+	if pair == "" {
+		return nil, fmt.Errorf("missing trading pair")
+	}
+
+	price, source, err := g.s.price(ctx, pair)
+	if err != nil {
+		return nil, err
+	}
+
+	g.s.resolved(pair, source, price)
+
+	return &coinmonv1.SpotPrice{
+		Pair:   pair,
+		Price:  price,
+		Source: source,
+		Time:   timestamppb.New(time.Now()),
+	}, nil
+}
@@ -0,0 +1,378 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	coinmonv1 "github.com/ivanglie/coinmon/api/coinmon/v1"
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func grpcClient(t *testing.T, s *Server) coinmonv1.PriceServiceClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	gs := s.grpcServer()
+	go func() { _ = gs.Serve(lis) }()
+	t.Cleanup(gs.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return coinmonv1.NewPriceServiceClient(conn)
+}
+
+func TestServer_StartGRPC_listener(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+	s := &Server{exchanges: exchanges[:1]}
+	WithGRPCListener(lis)(s)
+
+	errc := make(chan error, 1)
+	go func() { errc <- s.StartGRPC("127.0.0.1:-1") }()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	resp, err := coinmonv1.NewPriceServiceClient(conn).ListExchanges(context.Background(), &coinmonv1.ListExchangesRequest{})
+	assert.NoError(t, err)
+	assert.Len(t, resp.GetExchanges(), 1)
+
+	_ = lis.Close()
+	assert.Error(t, <-errc)
+}
+
+func TestServer_Shutdown_grpc(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+	s := &Server{
+		events:    bus.New(),
+		exchanges: exchanges[:1],
+		fetchers:  mockFetchers(),
+		listener:  &mockHTTPServer{shutdownFunc: func(context.Context) error { return nil }},
+	}
+	WithGRPCListener(lis)(s)
+
+	errc := make(chan error, 1)
+	go func() { errc <- s.StartGRPC("") }()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	stream, err := coinmonv1.NewPriceServiceClient(conn).StreamPrices(context.Background(), &coinmonv1.StreamPricesRequest{Pairs: []string{"BTCUSDT"}})
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	assert.NoError(t, s.Shutdown(ctx))
+	assert.Less(t, time.Since(start), time.Second)
+	assert.NoError(t, <-errc)
+
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func TestGRPC_GetSpotPrice(t *testing.T) {
+	tests := []struct {
+		name           string
+		pair           string
+		fetchers       map[exchange.Name]Fetcher
+		expectedCode   codes.Code
+		expectedPrice  float64
+		expectedSource string
+	}{
+		{
+			name:           "success",
+			pair:           "btcusdt",
+			fetchers:       mockFetchers(),
+			expectedCode:   codes.OK,
+			expectedPrice:  99999.99,
+			expectedSource: "binance",
+		},
+		{
+			name:         "missing pair",
+			pair:         " ",
+			fetchers:     mockFetchers(),
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "all exchanges fail",
+			pair:         "INVALID",
+			fetchers:     mockInvalidPairFetchers(),
+			expectedCode: codes.Unavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := grpcClient(t, &Server{
+				events:    bus.New(),
+				exchanges: exchanges[:1],
+				fetchers:  tt.fetchers,
+			})
+
+			resp, err := c.GetSpotPrice(context.Background(), &coinmonv1.GetSpotPriceRequest{Pair: tt.pair})
+			assert.Equal(t, tt.expectedCode, status.Code(err))
+			if tt.expectedCode != codes.OK {
+				return
+			}
+
+			assert.Equal(t, "BTCUSDT", resp.GetPrice().GetPair())
+			assert.Equal(t, tt.expectedPrice, resp.GetPrice().GetPrice())
+			assert.Equal(t, tt.expectedSource, resp.GetPrice().GetSource())
+			assert.NotNil(t, resp.GetPrice().GetTime())
+		})
+	}
+}
+
+func TestGRPC_GetBatch(t *testing.T) {
+	c := grpcClient(t, &Server{
+		events:    bus.New(),
+		exchanges: exchanges[:1],
+		fetchers: mockPairFetchers(map[string]map[exchange.Name]Fetcher{
+			"INVALID": mockInvalidPairFetchers(),
+		}),
+	})
+
+	resp, err := c.GetBatch(context.Background(), &coinmonv1.GetBatchRequest{Pairs: []string{"BTCUSDT", "invalid"}})
+	assert.NoError(t, err)
+	assert.Len(t, resp.GetPrices(), 1)
+	assert.Equal(t, "BTCUSDT", resp.GetPrices()[0].GetPair())
+	assert.Len(t, resp.GetErrors(), 1)
+	assert.Equal(t, "INVALID", resp.GetErrors()[0].GetPair())
+
+	_, err = c.GetBatch(context.Background(), &coinmonv1.GetBatchRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = c.GetBatch(context.Background(), &coinmonv1.GetBatchRequest{Pairs: make([]string, maxBatchPairs+1)})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGRPC_ListExchanges(t *testing.T) {
+	c := grpcClient(t, &Server{exchanges: exchanges, events: bus.New()})
+
+	resp, err := c.ListExchanges(context.Background(), &coinmonv1.ListExchangesRequest{})
+	assert.NoError(t, err)
+	assert.Len(t, resp.GetExchanges(), 4)
+	assert.Equal(t, "binance", resp.GetExchanges()[0].GetName())
+	assert.True(t, resp.GetExchanges()[0].GetStreaming())
+	assert.False(t, resp.GetExchanges()[3].GetStreaming())
+}
+
+func TestGRPC_StreamPrices(t *testing.T) {
+	s := &Server{
+		events:    bus.New(),
+		exchanges: exchanges[:1],
+		fetchers:  mockFetchers(),
+	}
+	c := grpcClient(t, s)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := c.StreamPrices(ctx, &coinmonv1.StreamPricesRequest{Pairs: []string{"btcusdt", "BTCUSDT"}})
+	assert.NoError(t, err)
+
+	p, err := stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, "BTCUSDT", p.GetPair())
+	assert.Equal(t, 99999.99, p.GetPrice())
+
+	s.events.Publish(bus.PriceUpdated{Pair: "BTCUSDT", Price: 100000, Source: "bybit", Time: time.Now()})
+
+	p, err = stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, 100000.0, p.GetPrice())
+	assert.Equal(t, "bybit", p.GetSource())
+}
+
+func TestGRPC_StreamPrices_Errors(t *testing.T) {
+	tests := []struct {
+		name         string
+		pairs        []string
+		expectedCode codes.Code
+	}{
+		{
+			name:         "missing pairs",
+			pairs:        []string{""},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "invalid pair",
+			pairs:        []string{"INVALID"},
+			expectedCode: codes.Unavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := grpcClient(t, &Server{
+				events:    bus.New(),
+				exchanges: exchanges,
+				fetchers:  mockInvalidPairFetchers(),
+			})
+
+			stream, err := c.StreamPrices(context.Background(), &coinmonv1.StreamPricesRequest{Pairs: tt.pairs})
+			assert.NoError(t, err)
+
+			_, err = stream.Recv()
+			assert.Equal(t, tt.expectedCode, status.Code(err))
+		})
+	}
+}
+
+func TestGRPC_StreamPrices_MaxSubscribers(t *testing.T) {
+	s := &Server{
+		events:    bus.New(),
+		exchanges: exchanges[:1],
+		fetchers:  mockFetchers(),
+	}
+	WithLimits(Limits{MaxSubscribers: 1})(s)
+	c := grpcClient(t, s)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := c.StreamPrices(ctx, &coinmonv1.StreamPricesRequest{Pairs: []string{"BTCUSDT"}})
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+	assert.NoError(t, err)
+
+	// The open stream takes the only slot
+	second, err := c.StreamPrices(ctx, &coinmonv1.StreamPricesRequest{Pairs: []string{"BTCUSDT"}})
+	assert.NoError(t, err)
+	_, err = second.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	cancel()
+	assert.Eventually(t, func() bool { return s.subscribers.Load() == 0 }, time.Second, 5*time.Millisecond)
+}
+
+func TestGRPC_StreamPrices_charged(t *testing.T) {
+	tests := []struct {
+		name         string
+		pairs        []string
+		expectedCode codes.Code
+	}{
+		{name: "token per pair", pairs: []string{"BTCUSDT", "ETHUSDT"}, expectedCode: codes.OK},
+		{name: "too many pairs", pairs: []string{"BTCUSDT", "ETHUSDT", "SOLUSDT"}, expectedCode: codes.ResourceExhausted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{
+				events:    bus.New(),
+				exchanges: exchanges[:1],
+				fetchers:  mockFetchers(),
+			}
+			WithAPIKeys([]APIKey{{Name: "app", Key: "secret", Rate: 0.001, Burst: 2}}, true)(s)
+			c := grpcClient(t, s)
+
+			ctx, cancel := context.WithCancel(metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "secret"))
+			defer cancel()
+
+			stream, err := c.StreamPrices(ctx, &coinmonv1.StreamPricesRequest{Pairs: tt.pairs})
+			assert.NoError(t, err)
+			_, err = stream.Recv()
+			assert.Equal(t, tt.expectedCode, status.Code(err))
+		})
+	}
+}
+
+func TestGRPC_APIKeys(t *testing.T) {
+	tests := []struct {
+		name         string
+		key          string
+		calls        int
+		expectedCode codes.Code
+	}{
+		{name: "valid key", key: "secret", calls: 1, expectedCode: codes.OK},
+		{name: "missing key", calls: 1, expectedCode: codes.Unauthenticated},
+		{name: "invalid key", key: "wrong", calls: 1, expectedCode: codes.Unauthenticated},
+		{name: "rate limited", key: "secret", calls: 3, expectedCode: codes.ResourceExhausted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{exchanges: exchanges, events: bus.New()}
+			WithAPIKeys([]APIKey{{Name: "app", Key: "secret", Rate: 0.001, Burst: 2}}, true)(s)
+			c := grpcClient(t, s)
+
+			ctx := context.Background()
+			if tt.key != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "x-api-key", tt.key)
+			}
+
+			var err error
+			for range tt.calls {
+				_, err = c.ListExchanges(ctx, &coinmonv1.ListExchangesRequest{})
+			}
+			assert.Equal(t, tt.expectedCode, status.Code(err))
+			if tt.expectedCode == codes.OK {
+				return
+			}
+
+			stream, err := c.StreamPrices(ctx, &coinmonv1.StreamPricesRequest{Pairs: []string{"BTCUSDT"}})
+			assert.NoError(t, err)
+			_, err = stream.Recv()
+			assert.Equal(t, tt.expectedCode, status.Code(err))
+		})
+	}
+}
+
+func TestGRPC_JWT(t *testing.T) {
+	v, err := NewJWTVerifier(context.Background(), JWT{Secret: "secret"})
+	assert.NoError(t, err)
+	token := signHS256(t, "secret", jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))})
+
+	tests := []struct {
+		name          string
+		authorization string
+		expectedCode  codes.Code
+	}{
+		{name: "valid token", authorization: "Bearer " + token, expectedCode: codes.OK},
+		{name: "invalid token", authorization: "Bearer " + signHS256(t, "wrong", jwt.RegisteredClaims{}), expectedCode: codes.Unauthenticated},
+		{name: "missing token", expectedCode: codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{exchanges: exchanges, events: bus.New()}
+			WithJWT(v, true)(s)
+			c := grpcClient(t, s)
+
+			ctx := context.Background()
+			if tt.authorization != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "authorization", tt.authorization)
+			}
+
+			_, err := c.ListExchanges(ctx, &coinmonv1.ListExchangesRequest{})
+			assert.Equal(t, tt.expectedCode, status.Code(err))
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ivanglie/coinmon/pkg/log"
+)
+
+// WithH2C accepts HTTP/2 without TLS from clients with prior knowledge,
+// e.g. behind a service mesh, and serves the gRPC API next to the HTTP
+// routes to them
+func WithH2C() Option {
+	return func(s *Server) {
+		s.h2c = true
+	}
+}
+
+// handler returns the HTTP routes wrapped in middleware, the outermost first
+func (s *Server) handler() http.Handler {
+	mws := []Middleware{s.accessLog}
+	if s.h3 != nil {
+		mws = append(mws, s.altSvc)
+	}
+	if s.h2c {
+		mws = append(mws, s.routeGRPC)
+	}
+	if len(s.deprecations) > 0 {
+		mws = append(mws, s.deprecate)
+	}
+	mws = append(mws, s.gateDebug, recoverPanics, securityHeaders, s.cors, compress)
+
+	return chain(s.routes(), mws...)
+}
+
+// protocols returns the protocols served on the listener
+func (s *Server) protocols() *http.Protocols {
+	p := new(http.Protocols)
+	p.SetHTTP1(true)
+	p.SetHTTP2(true)
+	p.SetUnencryptedHTTP2(s.h2c)
+	return p
+}
+
+// routeGRPC passes gRPC requests to the gRPC API and other requests to next
+func (s *Server) routeGRPC(next http.Handler) http.Handler {
+	gs := s.grpcServer()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Streams outlive the server timeouts
+		rc := http.NewResponseController(w)
+		if err := rc.SetReadDeadline(time.Time{}); err != nil {
+			log.Debug("Failed to clear read deadline: " + err.Error())
+		}
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+			log.Debug("Failed to clear write deadline: " + err.Error())
+		}
+
+		gs.ServeHTTP(w, r)
+	})
+}
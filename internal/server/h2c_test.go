@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	coinmonv1 "github.com/ivanglie/coinmon/api/coinmon/v1"
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestServer_h2c(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	s := &Server{
+		events:    bus.New(),
+		exchanges: exchanges[:1],
+		fetchers:  mockFetchers(),
+	}
+	WithH2C()(s)
+	srv := &http.Server{Handler: s.handler(), Protocols: s.protocols(), ReadHeaderTimeout: time.Second}
+	go func() { _ = srv.Serve(ln) }()
+	defer func() { _ = srv.Close() }()
+
+	t.Run("http", func(t *testing.T) {
+		p := new(http.Protocols)
+		p.SetUnencryptedHTTP2(true)
+		client := &http.Client{Transport: &http.Transport{Protocols: p}}
+		defer client.CloseIdleConnections()
+
+		resp, err := client.Get("http://" + ln.Addr().String() + "/debug/upstream")
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer func() { _ = resp.Body.Close() }()
+		assert.Equal(t, "HTTP/2.0", resp.Proto)
+	})
+
+	t.Run("grpc", func(t *testing.T) {
+		conn, err := grpc.NewClient(ln.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		assert.NoError(t, err)
+		defer func() { _ = conn.Close() }()
+
+		resp, err := coinmonv1.NewPriceServiceClient(conn).GetSpotPrice(context.Background(), &coinmonv1.GetSpotPriceRequest{Pair: "BTCUSDT"})
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, 99999.99, resp.GetPrice().GetPrice())
+	})
+}
+
+func TestServer_protocols(t *testing.T) {
+	s := &Server{}
+	assert.False(t, s.protocols().UnencryptedHTTP2())
+	assert.True(t, s.protocols().HTTP2())
+
+	WithH2C()(s)
+	assert.True(t, s.protocols().UnencryptedHTTP2())
+	assert.True(t, s.protocols().HTTP1())
+}
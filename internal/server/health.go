@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HandleHealthz handles /healthz liveness probes. It answers as long as the
+// process serves requests, regardless of the state of the exchanges.
+func (s *Server) HandleHealthz(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write([]byte("ok"))
+}
+
+// HandleReadyz handles /readyz readiness probes. The server is ready once
+// any exchange has been reached, and stops being ready when shutdown starts.
+func (s *Server) HandleReadyz(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("Cache-Control", "no-store")
+
+	switch {
+	case s.draining.Load():
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+	case !s.reached.Load():
+		http.Error(w, "no exchange reached yet", http.StatusServiceUnavailable)
+	default:
+		_, _ = w.Write([]byte("ok"))
+	}
+}
+
+// exchangeHealth represents the outcome of the latest calls to an exchange
+type exchangeHealth struct {
+	LastSuccess   time.Time
+	LastError     string
+	LastErrorTime time.Time
+}
+
+// status returns "up" when the latest call succeeded, "down" when it failed
+// and "unknown" before the first call
+func (h exchangeHealth) status() string {
+	switch {
+	case h.LastSuccess.IsZero() && h.LastErrorTime.IsZero():
+		return "unknown"
+	case h.LastSuccess.After(h.LastErrorTime):
+		return "up"
+	default:
+		return "down"
+	}
+}
+
+// healthTracker records call outcomes per exchange
+type healthTracker struct {
+	mu sync.Mutex
+	m  map[string]exchangeHealth
+}
+
+// record stores the outcome of a call. Calls aborted by ctx, e.g. losers of
+// the price race, calls never made for lack of a free upstream slot or rate
+// limit budget, and calls to exchanges down for maintenance say nothing
+// about the exchange and are ignored.
+func (t *healthTracker) record(ctx context.Context, name string, err error) {
+	if err != nil && (ctx.Err() != nil || errors.Is(err, errUpstreamBusy) || errors.Is(err, errRateLimited) || errors.Is(err, errMaintenance)) {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.m == nil {
+		t.m = make(map[string]exchangeHealth)
+	}
+
+	h := t.m[name]
+	if err != nil {
+		h.LastError, h.LastErrorTime = err.Error(), time.Now()
+	} else {
+		h.LastSuccess = time.Now()
+	}
+	t.m[name] = h
+}
+
+func (t *healthTracker) get(name string) exchangeHealth {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.m[name]
+}
+
+// reset forgets the outcomes of calls to the exchange name
+func (t *healthTracker) reset(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.m, name)
+}
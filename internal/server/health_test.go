@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthTracker(t *testing.T) {
+	var h healthTracker
+	assert.Equal(t, "unknown", h.get("binance").status())
+
+	h.record(context.Background(), "binance", nil)
+	assert.Equal(t, "up", h.get("binance").status())
+
+	h.record(context.Background(), "binance", fmt.Errorf("code=-1003, msg=Too many requests"))
+	assert.Equal(t, "down", h.get("binance").status())
+	assert.Equal(t, "code=-1003, msg=Too many requests", h.get("binance").LastError)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	h.record(ctx, "bybit", context.Canceled)
+	assert.Equal(t, "unknown", h.get("bybit").status(), "aborted calls are ignored")
+}
+
+func TestServer_HandleHealthz(t *testing.T) {
+	s := &Server{
+		exchanges: exchanges[:1],
+		fetchers:  mockErrorFetchers(),
+	}
+	_, _ = s.fetchPrice(context.Background(), exchanges[0], "BTCUSDT")
+
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		req := httptest.NewRequest(method, "/healthz", http.NoBody)
+		w := httptest.NewRecorder()
+		s.HandleHealthz(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code, method)
+	}
+}
+
+func TestServer_HandleReadyz(t *testing.T) {
+	tests := []struct {
+		name           string
+		fetchers       map[exchange.Name]Fetcher
+		draining       bool
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "exchange reached",
+			fetchers:       mockFetchers(),
+			expectedStatus: http.StatusOK,
+			expectedBody:   "ok",
+		},
+		{
+			name:           "no exchange reached",
+			fetchers:       mockErrorFetchers(),
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   "no exchange reached yet",
+		},
+		{
+			name:           "draining",
+			fetchers:       mockFetchers(),
+			draining:       true,
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   "shutting down",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{
+				exchanges: exchanges[:1],
+				fetchers:  tt.fetchers,
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/readyz", http.NoBody)
+			w := httptest.NewRecorder()
+			s.HandleReadyz(w, req)
+			assert.Equal(t, http.StatusServiceUnavailable, w.Code, "not ready before the first call")
+
+			_, _ = s.fetchPrice(context.Background(), exchanges[0], "BTCUSDT")
+			s.draining.Store(tt.draining)
+
+			w = httptest.NewRecorder()
+			s.HandleReadyz(w, req)
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
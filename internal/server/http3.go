@@ -0,0 +1,52 @@
+package server
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// WithHTTP3 serves HTTP/3 on the UDP address addr next to HTTPS and
+// advertises it to HTTPS clients with the Alt-Svc header
+func WithHTTP3(addr string) Option {
+	return func(s *Server) {
+		s.h3 = &http3.Server{Addr: addr}
+	}
+}
+
+// StartHTTP3 serves HTTP/3 on the address set with WithHTTP3 with the
+// certificates of HTTPS and the connection limits. It returns nil once the
+// server is shut down.
+func (s *Server) StartHTTP3() error {
+	cfg, err := s.tlsConfig()
+	if err != nil {
+		return err
+	}
+
+	ln, err := quic.ListenAddrEarly(cmp.Or(s.h3.Addr, ":https"), http3.ConfigureTLSConfig(cfg), &quic.Config{Allow0RTT: true})
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+	defer func() { _ = ln.Close() }()
+
+	if err := s.h3.ServeListener(s.limitQUICConns(ln)); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// altSvc advertises HTTP/3 on responses sent over TCP
+func (s *Server) altSvc(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor < 3 {
+			// Fails until the HTTP/3 listener is up
+			_ = s.h3.SetQUICHeaders(w.Header())
+		}
+		next.ServeHTTP(w, r)
+	})
+}
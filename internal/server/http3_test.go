@@ -0,0 +1,112 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_StartHTTP3(t *testing.T) {
+	certFile, keyFile, cert := writeCert(t, t.TempDir())
+
+	// Pick a free UDP port
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := pc.LocalAddr().String()
+	_ = pc.Close()
+
+	s := &Server{}
+	WithTLS(certFile, keyFile)(s)
+	WithHTTP3(addr)(s)
+	s.h3.Handler = s.handler()
+
+	errc := make(chan error, 1)
+	go func() { errc <- s.StartHTTP3() }()
+	defer func() {
+		assert.NoError(t, s.h3.Close())
+		assert.NoError(t, <-errc)
+	}()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	tr := &http3.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS13}}
+	defer func() { _ = tr.Close() }()
+	client := &http.Client{Transport: tr, Timeout: 5 * time.Second}
+
+	var resp *http.Response
+	assert.Eventually(t, func() bool {
+		resp, err = client.Get("https://" + addr + "/debug/upstream")
+		return err == nil
+	}, 5*time.Second, 50*time.Millisecond)
+	if !assert.NotNil(t, resp) {
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+	assert.Equal(t, "HTTP/3.0", resp.Proto)
+	assert.Empty(t, resp.Header.Get("Alt-Svc"))
+
+	// HTTP/3 is advertised over TCP
+	_, port, _ := net.SplitHostPort(addr)
+	w := httptest.NewRecorder()
+	s.handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/upstream", http.NoBody))
+	assert.Equal(t, `h3=":`+port+`"; ma=2592000`, w.Header().Get("Alt-Svc"))
+}
+
+func TestServer_StartHTTP3_limits(t *testing.T) {
+	certFile, keyFile, cert := writeCert(t, t.TempDir())
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := pc.LocalAddr().String()
+	_ = pc.Close()
+
+	s := &Server{}
+	WithTLS(certFile, keyFile)(s)
+	WithHTTP3(addr)(s)
+	WithLimits(Limits{MaxConnsPerIP: 1})(s)
+	s.h3.Handler = s.handler()
+
+	errc := make(chan error, 1)
+	go func() { errc <- s.StartHTTP3() }()
+	defer func() {
+		assert.NoError(t, s.h3.Close())
+		assert.NoError(t, <-errc)
+	}()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	newClient := func() *http.Client {
+		tr := &http3.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS13}}
+		t.Cleanup(func() { _ = tr.Close() })
+		return &http.Client{Transport: tr, Timeout: 2 * time.Second}
+	}
+
+	first := newClient()
+	assert.Eventually(t, func() bool {
+		resp, err := first.Get("https://" + addr + "/debug/upstream")
+		if err != nil {
+			return false
+		}
+		_ = resp.Body.Close()
+		return true
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// A second connection of the client is closed while the first is open
+	_, err = newClient().Get("https://" + addr + "/debug/upstream")
+	assert.Error(t, err)
+}
+
+func TestServer_StartHTTP3_invalidCert(t *testing.T) {
+	s := &Server{}
+	WithTLS("missing.pem", "missing.pem")(s)
+	WithHTTP3("127.0.0.1:0")(s)
+
+	assert.ErrorContains(t, s.StartHTTP3(), "load certificate")
+}
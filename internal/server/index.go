@@ -0,0 +1,40 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/ivanglie/coinmon/internal/index"
+	"github.com/ivanglie/coinmon/pkg/log"
+)
+
+// WithIndices serves the composite indices of c at /api/v1/index/{name}.
+// Polling fetches quotes from every exchange for the pairs of their
+// components, which c takes from the bus.
+func WithIndices(c *index.Calculator) Option {
+	return func(s *Server) {
+		s.indices = c
+	}
+}
+
+// HandleCompositeIndex handles /api/v1/index/{name} requests with the price
+// of a composite index and the quotes it is made of
+func (s *Server) HandleCompositeIndex(w http.ResponseWriter, r *http.Request) {
+	v, err := s.indices.Value(strings.ToUpper(r.PathValue("name")))
+	switch {
+	case errors.Is(err, index.ErrUnknown):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error("Failed to encode response: " + err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
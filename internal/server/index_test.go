@@ -0,0 +1,68 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/ivanglie/coinmon/internal/index"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_HandleCompositeIndex(t *testing.T) {
+	c := index.NewCalculator([]index.Index{
+		{Name: "BTC-INDEX", Components: []index.Component{
+			{Exchange: "binance", Pair: "BTCUSDT", Weight: 3},
+			{Exchange: "bybit", Pair: "BTCUSDT", Weight: 1},
+		}},
+		{Name: "ETH-INDEX", Components: []index.Component{{Exchange: "kraken", Pair: "ETHUSDT", Weight: 1}}},
+	}, time.Minute)
+	now := time.Now().UTC()
+	c.Observe(bus.PriceUpdated{Pair: "BTCUSDT", Source: "binance", Price: 100000, Time: now})
+	c.Observe(bus.PriceUpdated{Pair: "BTCUSDT", Source: "bybit", Price: 100400, Time: now})
+
+	s := &Server{}
+	WithIndices(c)(s)
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "index", path: "/api/v1/index/btc-index", expectedStatus: http.StatusOK, expectedBody: `{"name":"BTC-INDEX","price":100100,`},
+		{name: "no quotes", path: "/api/v1/index/ETH-INDEX", expectedStatus: http.StatusServiceUnavailable, expectedBody: "ETH-INDEX: no recent quotes of the components\n"},
+		{name: "unknown index", path: "/api/v1/index/SOL-INDEX", expectedStatus: http.StatusNotFound, expectedBody: "unknown index \"SOL-INDEX\"\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, http.NoBody))
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+func TestServer_Poll_Indices(t *testing.T) {
+	s := &Server{
+		events:    bus.New(),
+		exchanges: exchanges,
+		fetchers:  mockFetchers(),
+	}
+	WithIndices(index.NewCalculator([]index.Index{
+		{Name: "BTC-INDEX", Components: []index.Component{{Exchange: "bybit", Pair: "BTCUSDT", Weight: 1}}},
+	}, 0))(s)
+
+	assert.NoError(t, s.Poll(t.Context(), "BTCUSDT"))
+
+	sources := map[string]bool{}
+	for _, e := range s.events.History("BTCUSDT", 10) {
+		sources[e.Source] = true
+	}
+	assert.Len(t, sources, len(exchanges))
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MicahParks/keyfunc/v3"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWT represents settings of bearer tokens issued by an identity provider
+type JWT struct {
+	Secret   string // verifies HS256 tokens
+	JWKSURL  string // serves the keys verifying RS256 tokens
+	Issuer   string // required iss claim, if set
+	Audience string // required aud claim, if set
+}
+
+var (
+	errTokenRequired       = errors.New("bearer token required")
+	errTokenInvalid        = errors.New("invalid bearer token")
+	errCredentialsRequired = errors.New("API key or bearer token required")
+)
+
+// JWTVerifier verifies bearer tokens
+type JWTVerifier struct {
+	secret []byte
+	jwks   keyfunc.Keyfunc
+	parser *jwt.Parser
+}
+
+// NewJWTVerifier returns a verifier of tokens signed with the secret or a
+// key of the JWKS. The JWKS is refreshed hourly and on unknown key ids
+// until ctx is done.
+func NewJWTVerifier(ctx context.Context, cfg JWT) (*JWTVerifier, error) {
+	if cfg.Secret == "" && cfg.JWKSURL == "" {
+		return nil, errors.New("create jwt verifier: secret or jwks url required")
+	}
+
+	v := &JWTVerifier{}
+	var methods []string
+	if cfg.Secret != "" {
+		v.secret = []byte(cfg.Secret)
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if cfg.JWKSURL != "" {
+		jwks, err := keyfunc.NewDefaultCtx(ctx, []string{cfg.JWKSURL})
+		if err != nil {
+			return nil, fmt.Errorf("create jwks client: %w", err)
+		}
+		v.jwks = jwks
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	v.parser = jwt.NewParser(opts...)
+
+	return v, nil
+}
+
+// verify returns the claims of a valid token
+func (v *JWTVerifier) verify(token string) (*jwt.RegisteredClaims, error) {
+	claims := &jwt.RegisteredClaims{}
+	if _, err := v.parser.ParseWithClaims(token, claims, v.key); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// key returns the key verifying the signature of t. Only the methods
+// configured in NewJWTVerifier reach it.
+func (v *JWTVerifier) key(t *jwt.Token) (any, error) {
+	if t.Method == jwt.SigningMethodHS256 {
+		return v.secret, nil
+	}
+
+	return v.jwks.Keyfunc(t)
+}
+
+// jwtAuth represents the verifier of bearer tokens on API routes
+type jwtAuth struct {
+	verifier *JWTVerifier
+	required bool
+}
+
+// WithJWT accepts requests to API routes with a bearer token verified by v
+// in the Authorization header, next to API keys. They are rate limited per
+// IP. Requests with an invalid token are rejected, as are requests without
+// a token or API key when required is set.
+func WithJWT(v *JWTVerifier, required bool) Option {
+	return func(s *Server) {
+		s.jwt = &jwtAuth{verifier: v, required: required}
+	}
+}
+
+// authenticate returns the key of the request, or nil for requests with a
+// bearer token and anonymous requests
+func (s *Server) authenticate(r *http.Request) (*apiKey, error) {
+	key, err := s.apiKeys.identify(r)
+	if s.jwt == nil || key != nil || errors.Is(err, errAPIKeyInvalid) {
+		return key, err
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		switch {
+		case err != nil || (s.jwt.required && s.apiKeys != nil):
+			return nil, errCredentialsRequired
+		case s.jwt.required:
+			return nil, errTokenRequired
+		}
+		return nil, nil
+	}
+
+	if _, verr := s.jwt.verifier.verify(token); verr != nil {
+		return nil, errTokenInvalid
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,238 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func signHS256(t *testing.T, secret string, claims jwt.RegisteredClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	assert.NoError(t, err)
+	return token
+}
+
+// serveJWKS serves key with the key id kid
+func serveJWKS(t *testing.T, key *rsa.PublicKey, kid string) string {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": kid,
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	}))
+	t.Cleanup(ts.Close)
+	return ts.URL
+}
+
+func TestNewJWTVerifier(t *testing.T) {
+	_, err := NewJWTVerifier(context.Background(), JWT{})
+	assert.Error(t, err)
+
+	_, err = NewJWTVerifier(context.Background(), JWT{JWKSURL: "not a url"})
+	assert.Error(t, err)
+}
+
+func TestJWTVerifier_verify(t *testing.T) {
+	valid := jwt.RegisteredClaims{
+		Subject:   "alice",
+		Issuer:    "https://id.example.com",
+		Audience:  jwt.ClaimStrings{"coinmon"},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+	expired := valid
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	noExpiry := valid
+	noExpiry.ExpiresAt = nil
+	otherIssuer := valid
+	otherIssuer.Issuer = "https://evil.example.com"
+	otherAudience := valid
+	otherAudience.Audience = jwt.ClaimStrings{"other"}
+
+	v, err := NewJWTVerifier(context.Background(), JWT{
+		Secret:   "secret",
+		Issuer:   "https://id.example.com",
+		Audience: "coinmon",
+	})
+	assert.NoError(t, err)
+
+	none, err := jwt.NewWithClaims(jwt.SigningMethodNone, valid).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		token       string
+		expectedErr bool
+	}{
+		{name: "valid", token: signHS256(t, "secret", valid)},
+		{name: "wrong secret", token: signHS256(t, "wrong", valid), expectedErr: true},
+		{name: "expired", token: signHS256(t, "secret", expired), expectedErr: true},
+		{name: "no expiry", token: signHS256(t, "secret", noExpiry), expectedErr: true},
+		{name: "other issuer", token: signHS256(t, "secret", otherIssuer), expectedErr: true},
+		{name: "other audience", token: signHS256(t, "secret", otherAudience), expectedErr: true},
+		{name: "unsigned", token: none, expectedErr: true},
+		{name: "malformed", token: "abc", expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := v.verify(tt.token)
+			if tt.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "alice", claims.Subject)
+		})
+	}
+}
+
+func TestJWTVerifier_verify_JWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	v, err := NewJWTVerifier(ctx, JWT{JWKSURL: serveJWKS(t, &key.PublicKey, "k1")})
+	assert.NoError(t, err)
+
+	claims := jwt.RegisteredClaims{Subject: "bob", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}
+	sign := func(k *rsa.PrivateKey, kid string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = kid
+		s, serr := token.SignedString(k)
+		assert.NoError(t, serr)
+		return s
+	}
+
+	got, err := v.verify(sign(key, "k1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "bob", got.Subject)
+
+	_, err = v.verify(sign(other, "k1"))
+	assert.Error(t, err, "signed by another key")
+
+	_, err = v.verify(sign(key, "k2"))
+	assert.Error(t, err, "unknown key id")
+
+	_, err = v.verify(signHS256(t, "secret", claims))
+	assert.Error(t, err, "HS256 without a secret")
+}
+
+func TestServer_RateLimit_JWT(t *testing.T) {
+	v, err := NewJWTVerifier(context.Background(), JWT{Secret: "secret"})
+	assert.NoError(t, err)
+	token := signHS256(t, "secret", jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))})
+
+	tests := []struct {
+		name           string
+		required       bool
+		apiKeys        bool
+		apiKey         string
+		authorization  string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "valid token",
+			authorization:  "Bearer " + token,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "invalid token",
+			authorization:  "Bearer " + signHS256(t, "wrong", jwt.RegisteredClaims{}),
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "invalid bearer token",
+		},
+		{
+			name:           "anonymous",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "anonymous when token required",
+			required:       true,
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "bearer token required",
+		},
+		{
+			name:           "basic auth when token required",
+			required:       true,
+			authorization:  "Basic dXNlcjpwYXNz",
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "bearer token required",
+		},
+		{
+			name:           "API key when token required",
+			required:       true,
+			apiKeys:        true,
+			apiKey:         "secret-a",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "token when API key required",
+			apiKeys:        true,
+			authorization:  "Bearer " + token,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "anonymous when API key required",
+			apiKeys:        true,
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "API key or bearer token required",
+		},
+		{
+			name:           "invalid API key with valid token",
+			apiKeys:        true,
+			apiKey:         "wrong",
+			authorization:  "Bearer " + token,
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "invalid API key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{}
+			WithJWT(v, tt.required)(s)
+			if tt.apiKeys {
+				WithAPIKeys([]APIKey{{Name: "a", Key: "secret-a"}}, true)(s)
+			}
+			handler := s.rateLimit(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/spot/BTCUSDT", http.NoBody)
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			handler(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+			if tt.expectedStatus == http.StatusUnauthorized {
+				assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ivanglie/coinmon/pkg/log"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// Limits represents bounds of the resources a client may take of the server.
+// Zero fields fall back to defaults or lift the limit.
+type Limits struct {
+	MaxHeaderBytes    int           // 64 KiB when zero
+	ReadHeaderTimeout time.Duration // 2s when zero
+
+	// MaxConns bounds the open connections, MaxConnsPerIP the connections
+	// of a single client, over HTTP, gRPC and HTTP/3 together. Connections
+	// over them are closed right away. Behind a proxy, all clients share its
+	// address.
+	MaxConns      int
+	MaxConnsPerIP int
+	// MaxStreamsPerConn bounds the concurrent requests on an HTTP/2
+	// connection, 250 when zero
+	MaxStreamsPerConn int
+
+	// MaxSubscribers bounds the open event streams, WebSocket connections
+	// and gRPC price streams, answering further ones with 503 Service
+	// Unavailable, or Unavailable over gRPC
+	MaxSubscribers int
+}
+
+const (
+	defaultMaxHeaderBytes    = 64 << 10
+	defaultReadHeaderTimeout = 2 * time.Second
+)
+
+// WithLimits bounds the resources clients may take of the server
+func WithLimits(l Limits) Option {
+	return func(s *Server) {
+		s.serverLimits = l
+	}
+}
+
+// httpServer returns the HTTP server of h with the limits applied
+func (s *Server) httpServer(h http.Handler) *http.Server {
+	srv := &http.Server{
+		Addr:              s.addr,
+		Handler:           h,
+		Protocols:         s.protocols(),
+		ReadHeaderTimeout: s.serverLimits.ReadHeaderTimeout,
+		ReadTimeout:       5 * time.Second,
+		WriteTimeout:      10 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		MaxHeaderBytes:    s.serverLimits.MaxHeaderBytes,
+		ConnContext:       connContext,
+	}
+	if srv.ReadHeaderTimeout <= 0 {
+		srv.ReadHeaderTimeout = defaultReadHeaderTimeout
+	}
+	if srv.MaxHeaderBytes <= 0 {
+		srv.MaxHeaderBytes = defaultMaxHeaderBytes
+	}
+	if s.serverLimits.MaxStreamsPerConn > 0 {
+		srv.HTTP2 = &http.HTTP2Config{MaxConcurrentStreams: s.serverLimits.MaxStreamsPerConn}
+	}
+
+	return srv
+}
+
+// takeSubscriber takes a slot of the subscribers, reporting whether there
+// was one left. release frees the slot.
+func (s *Server) takeSubscriber() (release func(), ok bool) {
+	n := s.subscribers.Add(1)
+	release = func() { s.subscribers.Add(-1) }
+	if maxSubs := s.serverLimits.MaxSubscribers; maxSubs > 0 && n > int64(maxSubs) {
+		release()
+		return nil, false
+	}
+
+	return release, true
+}
+
+// acquireSubscriber takes a slot of the subscribers, answering w with 503
+// Service Unavailable when there is none left. release frees the slot.
+func (s *Server) acquireSubscriber(w http.ResponseWriter) (release func(), ok bool) {
+	release, ok = s.takeSubscriber()
+	if !ok {
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, "Too many subscribers", http.StatusServiceUnavailable)
+	}
+
+	return release, ok
+}
+
+// connLimits counts the open connections of all listeners against the
+// limits of the total and per-IP connections
+type connLimits struct {
+	maxConns      int
+	maxConnsPerIP int
+
+	mu    sync.Mutex
+	conns int
+	perIP map[string]int
+}
+
+// connLimits returns the connection limits shared by the listeners, nil when
+// there are none
+func (s *Server) connLimits() *connLimits {
+	if s.serverLimits.MaxConns <= 0 && s.serverLimits.MaxConnsPerIP <= 0 {
+		return nil
+	}
+
+	s.connsOnce.Do(func() {
+		s.conns = &connLimits{
+			maxConns:      s.serverLimits.MaxConns,
+			maxConnsPerIP: s.serverLimits.MaxConnsPerIP,
+			perIP:         make(map[string]int),
+		}
+	})
+	return s.conns
+}
+
+// limitListener closes the connections accepted over the limits
+type limitListener struct {
+	net.Listener
+	limits *connLimits
+}
+
+// limitConns wraps ln in the connection limits, ln itself when there are
+// none
+func (s *Server) limitConns(ln net.Listener) net.Listener {
+	limits := s.connLimits()
+	if limits == nil {
+		return ln
+	}
+
+	return &limitListener{Listener: ln, limits: limits}
+}
+
+func (l *limitListener) Accept() (net.Conn, error) {
+	for {
+		c, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		ip := addrIP(c.RemoteAddr())
+		if l.limits.acquire(ip) {
+			return &limitConn{Conn: c, release: sync.OnceFunc(func() { l.limits.release(ip) })}, nil
+		}
+
+		log.Debug("Closing connection over the limits from " + c.RemoteAddr().String())
+		_ = c.Close()
+	}
+}
+
+// limitQUICListener closes the QUIC connections accepted over the limits
+type limitQUICListener struct {
+	http3.QUICListener
+	limits *connLimits
+}
+
+// limitQUICConns wraps ln in the connection limits, ln itself when there
+// are none
+func (s *Server) limitQUICConns(ln http3.QUICListener) http3.QUICListener {
+	limits := s.connLimits()
+	if limits == nil {
+		return ln
+	}
+
+	return &limitQUICListener{QUICListener: ln, limits: limits}
+}
+
+func (l *limitQUICListener) Accept(ctx context.Context) (*quic.Conn, error) {
+	for {
+		c, err := l.QUICListener.Accept(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		ip := addrIP(c.RemoteAddr())
+		if l.limits.acquire(ip) {
+			context.AfterFunc(c.Context(), func() { l.limits.release(ip) })
+			return c, nil
+		}
+
+		log.Debug("Closing connection over the limits from " + c.RemoteAddr().String())
+		_ = c.CloseWithError(quic.ApplicationErrorCode(http3.ErrCodeExcessiveLoad), "too many connections")
+	}
+}
+
+func (l *connLimits) acquire(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.maxConns > 0 && l.conns >= l.maxConns {
+		return false
+	}
+	if l.maxConnsPerIP > 0 && ip != "" && l.perIP[ip] >= l.maxConnsPerIP {
+		return false
+	}
+
+	l.conns++
+	if ip != "" {
+		l.perIP[ip]++
+	}
+	return true
+}
+
+func (l *connLimits) release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.conns--
+	if ip == "" {
+		return
+	}
+	if l.perIP[ip]--; l.perIP[ip] <= 0 {
+		delete(l.perIP, ip)
+	}
+}
+
+// addrIP returns the IP address of the peer at addr, empty for connections
+// other than TCP or UDP, e.g. over Unix domain sockets
+func addrIP(addr net.Addr) string {
+	switch addr := addr.(type) {
+	case *net.TCPAddr:
+		return addr.IP.String()
+	case *net.UDPAddr:
+		return addr.IP.String()
+	default:
+		return ""
+	}
+}
+
+// limitConn frees its slot in the limits once closed
+type limitConn struct {
+	net.Conn
+	release func()
+}
+
+func (c *limitConn) Close() error {
+	c.release()
+	return c.Conn.Close()
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_httpServer(t *testing.T) {
+	s := &Server{addr: ":8080"}
+	srv := s.httpServer(http.NotFoundHandler())
+	assert.Equal(t, 64<<10, srv.MaxHeaderBytes)
+	assert.Equal(t, 2*time.Second, srv.ReadHeaderTimeout)
+	assert.Nil(t, srv.HTTP2)
+
+	WithLimits(Limits{MaxHeaderBytes: 8 << 10, ReadHeaderTimeout: time.Second, MaxStreamsPerConn: 10})(s)
+	srv = s.httpServer(http.NotFoundHandler())
+	assert.Equal(t, 8<<10, srv.MaxHeaderBytes)
+	assert.Equal(t, time.Second, srv.ReadHeaderTimeout)
+	assert.Equal(t, 10, srv.HTTP2.MaxConcurrentStreams)
+}
+
+func TestServer_acquireSubscriber(t *testing.T) {
+	s := &Server{}
+	WithLimits(Limits{MaxSubscribers: 1})(s)
+
+	release, ok := s.acquireSubscriber(httptest.NewRecorder())
+	assert.True(t, ok)
+
+	w := httptest.NewRecorder()
+	_, ok = s.acquireSubscriber(w)
+	assert.False(t, ok)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+
+	release()
+	_, ok = s.acquireSubscriber(httptest.NewRecorder())
+	assert.True(t, ok)
+}
+
+func TestServer_limitConns(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	s := &Server{}
+	assert.Equal(t, ln, s.limitConns(ln))
+
+	WithLimits(Limits{MaxConnsPerIP: 1})(s)
+	ln = s.limitConns(ln)
+	defer func() { _ = ln.Close() }()
+
+	accepted := make(chan net.Conn)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				close(accepted)
+				return
+			}
+			accepted <- c
+		}
+	}()
+
+	dial := func() net.Conn {
+		c, err := net.Dial("tcp", ln.Addr().String())
+		assert.NoError(t, err)
+		return c
+	}
+
+	first := dial()
+	defer func() { _ = first.Close() }()
+	c := <-accepted
+
+	// The second connection of the client is closed without being accepted
+	second := dial()
+	assert.NoError(t, second.SetReadDeadline(time.Now().Add(time.Second)))
+	_, err = second.Read(make([]byte, 1))
+	assert.Error(t, err)
+	var netErr net.Error
+	assert.False(t, errors.As(err, &netErr) && netErr.Timeout(), "connection not closed")
+
+	// Closing the first connection frees its slot
+	assert.NoError(t, c.Close())
+	third := dial()
+	defer func() { _ = third.Close() }()
+	select {
+	case c = <-accepted:
+		_ = c.Close()
+	case <-time.After(time.Second):
+		t.Fatal("connection not accepted")
+	}
+}
+
+func TestServer_limitConns_shared(t *testing.T) {
+	s := &Server{}
+	WithLimits(Limits{MaxConns: 1})(s)
+
+	listen := func() net.Listener {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		t.Cleanup(func() { _ = ln.Close() })
+		return s.limitConns(ln)
+	}
+	httpLn, grpcLn := listen(), listen()
+
+	first, err := net.Dial("tcp", httpLn.Addr().String())
+	assert.NoError(t, err)
+	defer func() { _ = first.Close() }()
+	c, err := httpLn.Accept()
+	assert.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	// The connection to the other listener is over the total
+	second, err := net.Dial("tcp", grpcLn.Addr().String())
+	assert.NoError(t, err)
+	defer func() { _ = second.Close() }()
+	go func() {
+		if c, err := grpcLn.Accept(); err == nil {
+			_ = c.Close()
+		}
+	}()
+	assert.NoError(t, second.SetReadDeadline(time.Now().Add(time.Second)))
+	_, err = second.Read(make([]byte, 1))
+	var netErr net.Error
+	assert.False(t, errors.As(err, &netErr) && netErr.Timeout(), "connection not closed")
+	limits := s.connLimits()
+	limits.mu.Lock()
+	defer limits.mu.Unlock()
+	assert.Equal(t, 1, limits.conns)
+}
+
+func TestServer_HandleStream_MaxSubscribers(t *testing.T) {
+	s := &Server{}
+	WithLimits(Limits{MaxSubscribers: 1})(s)
+	s.subscribers.Add(1)
+
+	w := httptest.NewRecorder()
+	s.HandleStream(w, httptest.NewRequest(http.MethodGet, "/api/v1/stream/BTCUSDT", http.NoBody))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "Too many subscribers\n", w.Body.String())
+}
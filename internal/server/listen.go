@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// unixPrefix marks addresses of Unix domain sockets, e.g.
+// unix:///run/coinmon.sock
+const unixPrefix = "unix://"
+
+// listen announces on addr, a Unix domain socket or a TCP address sharing
+// the port with other processes when reusePort is set
+func (s *Server) listen(addr string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, unixPrefix); ok {
+		return listenUnix(path)
+	}
+
+	var lc net.ListenConfig
+	if s.reusePort {
+		lc.Control = reusePort
+	}
+
+	return lc.Listen(context.Background(), "tcp", addr)
+}
+
+// listenUnix listens on a socket at path accessible to the user and group
+// of the process. A socket left behind by a process killed before closing
+// it is replaced, a socket another process still listens on is not.
+func listenUnix(path string) (net.Listener, error) {
+	if fi, err := os.Stat(path); err == nil && fi.Mode().Type() == fs.ModeSocket {
+		conn, err := net.Dial("unix", path)
+		switch {
+		case err == nil:
+			_ = conn.Close()
+			return nil, fmt.Errorf("socket %s is in use", path)
+		case !errors.Is(err, syscall.ECONNREFUSED):
+			return nil, fmt.Errorf("check socket: %w", err)
+		}
+
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("remove stale socket: %w", err)
+		}
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(path, 0o660); err != nil { //nolint:gosec // the proxy in front connects as a member of the group
+		_ = ln.Close()
+		return nil, fmt.Errorf("set socket permissions: %w", err)
+	}
+
+	return ln, nil
+}
@@ -0,0 +1,94 @@
+//go:build linux
+
+package server
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_listen(t *testing.T) {
+	tests := []struct {
+		name        string
+		reusePort   bool
+		expectError bool
+	}{
+		{
+			name:      "reuse port",
+			reusePort: true,
+		},
+		{
+			name:        "address in use",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{reusePort: tt.reusePort}
+
+			first, err := s.listen("127.0.0.1:0")
+			assert.NoError(t, err)
+			defer func() { _ = first.Close() }()
+
+			second, err := s.listen(first.Addr().String())
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			_ = second.Close()
+		})
+	}
+}
+
+func TestServer_listen_unix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "coinmon.sock")
+	s := &Server{}
+
+	stale, err := s.listen("unix://" + path)
+	assert.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = stale.Close()
+
+	ln, err := s.listen("unix://" + path)
+	assert.NoError(t, err, "stale socket is replaced")
+	defer func() { _ = ln.Close() }()
+
+	fi, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o660), fi.Mode().Perm())
+
+	conn, err := net.Dial("unix", path)
+	assert.NoError(t, err)
+	_ = conn.Close()
+}
+
+func TestServer_listen_unixInUse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "coinmon.sock")
+	s := &Server{}
+
+	ln, err := s.listen("unix://" + path)
+	assert.NoError(t, err)
+	defer func() { _ = ln.Close() }()
+
+	_, err = s.listen("unix://" + path)
+	assert.ErrorContains(t, err, "is in use")
+
+	conn, err := net.Dial("unix", path)
+	assert.NoError(t, err, "live socket is kept")
+	_ = conn.Close()
+}
+
+func TestServer_listen_unixNotSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "coinmon.sock")
+	assert.NoError(t, os.WriteFile(path, nil, 0o600))
+
+	_, err := (&Server{}).listen("unix://" + path)
+	assert.Error(t, err, "regular files are not replaced")
+}
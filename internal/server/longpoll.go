@@ -0,0 +1,121 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/ivanglie/coinmon/pkg/log"
+)
+
+const (
+	defaultWait = 30 * time.Second
+	maxWait     = 60 * time.Second
+)
+
+// HandleNext handles /api/v1/spot/{pair}/next?since=<ts>&wait=<duration> requests.
+// It responds with the latest update of pair published after since, blocking
+// until the price changes or wait elapses (204 No Content).
+func (s *Server) HandleNext(w http.ResponseWriter, r *http.Request) {
+	pair := strings.ToUpper(r.PathValue("pair"))
+	if s.forbidPair(w, pair) {
+		return
+	}
+
+	since := time.Now()
+	if v := r.URL.Query().Get("since"); v != "" {
+		t, err := parseSince(v)
+		if err != nil {
+			http.Error(w, "Invalid since parameter", http.StatusBadRequest)
+			return
+		}
+		since = t
+	}
+
+	wait := defaultWait
+	if v := r.URL.Query().Get("wait"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			http.Error(w, "Invalid wait parameter", http.StatusBadRequest)
+			return
+		}
+		wait = min(d, maxWait)
+	}
+
+	ch, unsubscribe := s.events.Subscribe(pair)
+	defer unsubscribe()
+
+	last, ok := s.events.Latest(pair)
+	if !ok {
+		// Nothing is known about the pair yet, resolve its current price
+		price, source, err := s.price(r.Context(), pair)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		last = s.resolved(pair, source, price)
+	}
+
+	if last.Time.After(since) {
+		writeNext(w, last)
+		return
+	}
+
+	// Waiting may outlast the server write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(wait + 5*time.Second)); err != nil {
+		log.Debug("Failed to extend write deadline: " + err.Error())
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.streams().Done():
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case <-timer.C:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case u := <-ch:
+			// Updates repeating the known price are not a change
+			if u.Price == last.Price {
+				continue
+			}
+			writeNext(w, u)
+			return
+		}
+	}
+}
+
+func writeNext(w http.ResponseWriter, u bus.PriceUpdated) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(u); err != nil {
+		log.Error("Failed to encode response: " + err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// parseSince parses RFC 3339 timestamps or unix time in seconds or milliseconds
+func parseSince(v string) (time.Time, error) {
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if n > 1e12 {
+			return time.UnixMilli(n), nil
+		}
+		return time.Unix(n, 0), nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse since: %w", err)
+	}
+
+	return t, nil
+}
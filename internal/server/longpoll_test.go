@@ -0,0 +1,140 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_HandleNext(t *testing.T) {
+	since := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		query          string
+		last           *bus.PriceUpdated
+		publish        []bus.PriceUpdated
+		expectedStatus int
+		expectedPrice  float64
+	}{
+		{
+			name:           "newer update returns immediately",
+			query:          "?since=1735689600",
+			last:           &bus.PriceUpdated{Pair: "BTCUSDT", Price: 1, Time: since.Add(time.Second)},
+			expectedStatus: http.StatusOK,
+			expectedPrice:  1,
+		},
+		{
+			name:  "waits for price change",
+			query: "?since=2025-01-01T00:00:01Z",
+			last:  &bus.PriceUpdated{Pair: "BTCUSDT", Price: 1, Time: since},
+			publish: []bus.PriceUpdated{
+				{Pair: "BTCUSDT", Price: 1, Time: since.Add(2 * time.Second)},
+				{Pair: "BTCUSDT", Price: 2, Time: since.Add(3 * time.Second)},
+			},
+			expectedStatus: http.StatusOK,
+			expectedPrice:  2,
+		},
+		{
+			name:           "no change within wait",
+			query:          "?since=1735689600000&wait=50ms",
+			last:           &bus.PriceUpdated{Pair: "BTCUSDT", Price: 1, Time: since},
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "unknown pair resolves current price",
+			query:          "?since=1735689600",
+			expectedStatus: http.StatusOK,
+			expectedPrice:  99999.99,
+		},
+		{
+			name:           "invalid since",
+			query:          "?since=yesterday",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid wait",
+			query:          "?wait=-1s",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{
+				events:    bus.New(),
+				exchanges: exchanges[:1],
+				fetchers:  mockFetchers(),
+			}
+			if tt.last != nil {
+				s.events.Publish(*tt.last)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/spot/btcusdt/next"+tt.query, http.NoBody)
+			w := httptest.NewRecorder()
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				s.routes().ServeHTTP(w, req)
+			}()
+
+			if len(tt.publish) > 0 {
+				assert.Eventually(t, func() bool {
+					return s.events.Subscribers("BTCUSDT") == 1
+				}, time.Second, 5*time.Millisecond)
+
+				for _, u := range tt.publish {
+					s.events.Publish(u)
+				}
+			}
+
+			select {
+			case <-done:
+			case <-time.After(2 * time.Second):
+				t.Fatal("handler did not return")
+			}
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusOK {
+				var u bus.PriceUpdated
+				assert.NoError(t, json.NewDecoder(w.Body).Decode(&u))
+				assert.Equal(t, tt.expectedPrice, u.Price)
+			}
+		})
+	}
+}
+
+func TestServer_HandleNext_Unavailable(t *testing.T) {
+	s := &Server{
+		events:    bus.New(),
+		exchanges: exchanges,
+		fetchers:  mockInvalidPairFetchers(),
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/spot/INVALID/next", http.NoBody)
+	w := httptest.NewRecorder()
+
+	s.routes().ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "all exchanges failed")
+}
+
+func TestParseSince(t *testing.T) {
+	expected := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, v := range []string{"1735689600", "1735689600000", "2025-01-01T00:00:00Z"} {
+		ts, err := parseSince(v)
+		assert.NoError(t, err)
+		assert.True(t, expected.Equal(ts), v)
+	}
+
+	_, err := parseSince("soon")
+	assert.Error(t, err)
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ivanglie/coinmon/pkg/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultMaintenanceMessage is announced when maintenance starts without one
+const defaultMaintenanceMessage = "Down for planned maintenance"
+
+// Maintenance represents a planned maintenance announced to API clients.
+// Until is an estimate, maintenance lasts until it is ended.
+type Maintenance struct {
+	Message string     `json:"message"`
+	Until   *time.Time `json:"until,omitempty"`
+}
+
+// MaintenanceResponse represents the response of API routes during maintenance
+type MaintenanceResponse struct {
+	Error string `json:"error"`
+	Maintenance
+}
+
+// MaintenanceStatus represents whether maintenance is in progress
+type MaintenanceStatus struct {
+	Enabled bool `json:"enabled"`
+	*Maintenance
+}
+
+// SetMaintenance answers API requests with 503 Service Unavailable and the
+// notice of m from now on, or serves them again when m is nil. Health
+// checks, the index and admin pages are not affected.
+func (s *Server) SetMaintenance(m *Maintenance) {
+	if m != nil {
+		c := *m
+		if c.Message == "" {
+			c.Message = defaultMaintenanceMessage
+		}
+		m = &c
+	}
+	s.maintenance.Store(m)
+
+	if m != nil {
+		log.Info("Maintenance started: " + m.Message)
+	} else {
+		log.Info("Maintenance ended")
+	}
+}
+
+// serveMaintenance writes the maintenance notice when maintenance is in
+// progress, and reports whether it did
+func (s *Server) serveMaintenance(w http.ResponseWriter) bool {
+	m := s.maintenance.Load()
+	if m == nil {
+		return false
+	}
+
+	if m.Until != nil {
+		if wait := time.Until(*m.Until); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	if err := json.NewEncoder(w).Encode(MaintenanceResponse{Error: "maintenance", Maintenance: *m}); err != nil {
+		log.Error("Failed to encode response: " + err.Error())
+	}
+
+	return true
+}
+
+// HandleAdminMaintenance handles /api/v1/admin/maintenance requests: GET
+// returns the maintenance status, PUT starts maintenance with the notice of
+// the body and DELETE ends it
+func (s *Server) HandleAdminMaintenance(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPut:
+		var m Maintenance
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&m); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		s.SetMaintenance(&m)
+	case http.MethodDelete:
+		s.SetMaintenance(nil)
+	}
+
+	m := s.maintenance.Load()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(MaintenanceStatus{Enabled: m != nil, Maintenance: m}); err != nil {
+		log.Error("Failed to encode response: " + err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// maintenanceErr returns the gRPC status of calls during maintenance
+func (s *Server) maintenanceErr() error {
+	if m := s.maintenance.Load(); m != nil {
+		return status.Error(codes.Unavailable, m.Message)
+	}
+
+	return nil
+}
+
+// unaryMaintenance rejects unary gRPC calls during maintenance
+func (s *Server) unaryMaintenance(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := s.maintenanceErr(); err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+// streamMaintenance rejects gRPC streams during maintenance
+func (s *Server) streamMaintenance(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := s.maintenanceErr(); err != nil {
+		return err
+	}
+
+	return handler(srv, ss)
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	coinmonv1 "github.com/ivanglie/coinmon/api/coinmon/v1"
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestServer_SetMaintenance(t *testing.T) {
+	s := &Server{exchanges: exchanges[:1], fetchers: mockFetchers(), events: bus.New()}
+	handler := s.rateLimit(s.HandleSpot)
+	spot := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/api/v1/spot/BTCUSDT", http.NoBody))
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, spot().Code)
+
+	until := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	s.SetMaintenance(&Maintenance{Until: &until})
+
+	w := spot()
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Contains(t, []string{"3599", "3600"}, w.Header().Get("Retry-After"))
+
+	var resp MaintenanceResponse
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, "maintenance", resp.Error)
+	assert.Equal(t, defaultMaintenanceMessage, resp.Message)
+	assert.Equal(t, until, *resp.Until)
+
+	// Health checks stay green
+	w = httptest.NewRecorder()
+	s.HandleHealthz(w, httptest.NewRequest(http.MethodGet, "/healthz", http.NoBody))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	s.SetMaintenance(nil)
+	assert.Equal(t, http.StatusOK, spot().Code)
+}
+
+func TestServer_HandleAdminMaintenance(t *testing.T) {
+	s := &Server{}
+	WithBasicAuth("admin", "secret")(s)
+	routes := s.routes()
+
+	tests := []struct {
+		name           string
+		method         string
+		body           string
+		expectedStatus int
+		expected       MaintenanceStatus
+	}{
+		{
+			name:           "off",
+			method:         http.MethodGet,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "start",
+			method:         http.MethodPut,
+			body:           `{"message":"Migrating to the new Kraken API"}`,
+			expectedStatus: http.StatusOK,
+			expected:       MaintenanceStatus{Enabled: true, Maintenance: &Maintenance{Message: "Migrating to the new Kraken API"}},
+		},
+		{
+			name:           "invalid body",
+			method:         http.MethodPut,
+			body:           `{`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "end",
+			method:         http.MethodDelete,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "invalid method",
+			method:         http.MethodPost,
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/v1/admin/maintenance", strings.NewReader(tt.body))
+			req.SetBasicAuth("admin", "secret")
+			w := httptest.NewRecorder()
+			routes.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var st MaintenanceStatus
+			assert.NoError(t, json.NewDecoder(w.Body).Decode(&st))
+			assert.Equal(t, tt.expected, st)
+		})
+	}
+}
+
+func TestGRPC_Maintenance(t *testing.T) {
+	s := &Server{exchanges: exchanges, events: bus.New()}
+	c := grpcClient(t, s)
+	s.SetMaintenance(&Maintenance{Message: "back soon"})
+
+	_, err := c.ListExchanges(context.Background(), &coinmonv1.ListExchangesRequest{})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, "back soon", status.Convert(err).Message())
+
+	stream, err := c.StreamPrices(context.Background(), &coinmonv1.StreamPricesRequest{Pairs: []string{"BTCUSDT"}})
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	s.SetMaintenance(nil)
+	_, err = c.ListExchanges(context.Background(), &coinmonv1.ListExchangesRequest{})
+	assert.NoError(t, err)
+}
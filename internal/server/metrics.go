@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metrics represents operational metrics of the server
+type metrics struct {
+	upstream *prometheus.HistogramVec
+	apiKeys  *prometheus.CounterVec
+}
+
+// newMetrics creates server metrics registered in reg
+func newMetrics(reg prometheus.Registerer) *metrics {
+	m := &metrics{
+		upstream: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "coinmon_upstream_request_duration_seconds",
+			Help:    "Duration of exchange API calls by exchange and outcome",
+			Buckets: []float64{.025, .05, .1, .15, .2, .3, .5, .75, 1, 2, 5},
+		}, []string{"exchange", "outcome"}),
+		apiKeys: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "coinmon_api_key_requests_total",
+			Help: "Requests of API key consumers by key name and outcome",
+		}, []string{"key", "outcome"}),
+	}
+	reg.MustRegister(m.upstream, m.apiKeys)
+
+	return m
+}
+
+// observeUpstream records the duration of an exchange call. Calls aborted by
+// ctx, e.g. losers of the price race, are recorded as canceled, and calls to
+// exchanges down for maintenance as maintenance.
+func (m *metrics) observeUpstream(ctx context.Context, name string, err error, d time.Duration) {
+	if m == nil {
+		return
+	}
+
+	outcome := "success"
+	switch {
+	case err != nil && ctx.Err() != nil:
+		outcome = "canceled"
+	case errors.Is(err, errMaintenance):
+		outcome = "maintenance"
+	case err != nil:
+		outcome = "error"
+	}
+
+	m.upstream.WithLabelValues(name, outcome).Observe(d.Seconds())
+}
+
+// observeAPIKey counts a request made with the key name, rejected by its rate
+// limit unless allowed
+func (m *metrics) observeAPIKey(name string, allowed bool) {
+	if m == nil {
+		return
+	}
+
+	outcome := "allowed"
+	if !allowed {
+		outcome = "limited"
+	}
+
+	m.apiKeys.WithLabelValues(name, outcome).Inc()
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_chain(t *testing.T) {
+	var order []string
+	mw := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	h := chain(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		order = append(order, "handler")
+	}), mw("first"), mw("second"))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	assert.Equal(t, []string{"first", "second", "handler"}, order)
+}
+
+func Test_recoverPanics(t *testing.T) {
+	h := recoverPanics(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "Internal server error\n", w.Body.String())
+
+	h = recoverPanics(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	})
+}
+
+func Test_compress(t *testing.T) {
+	body := strings.Repeat(`{"price":97123.45}`, 100)
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		status         int
+		expectGzip     bool
+	}{
+		{name: "gzip", acceptEncoding: "gzip, deflate", status: http.StatusOK, expectGzip: true},
+		{name: "sniffed type", acceptEncoding: "gzip", status: http.StatusOK, expectGzip: true},
+		{name: "no gzip", acceptEncoding: "deflate, br", status: http.StatusOK},
+		{name: "gzip refused", acceptEncoding: "gzip;q=0, br", status: http.StatusOK},
+		{name: "event stream", acceptEncoding: "gzip", contentType: "text/event-stream", status: http.StatusOK},
+		{name: "no content", acceptEncoding: "gzip", status: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := compress(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				if tt.status == http.StatusNoContent {
+					w.WriteHeader(tt.status)
+					return
+				}
+				_, _ = io.WriteString(w, body)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+			if !tt.expectGzip {
+				assert.Empty(t, w.Header().Get("Content-Encoding"))
+				if tt.status == http.StatusOK {
+					assert.Equal(t, body, w.Body.String())
+				}
+				return
+			}
+
+			assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+			assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+			gr, err := gzip.NewReader(w.Body)
+			assert.NoError(t, err)
+			decompressed, err := io.ReadAll(gr)
+			assert.NoError(t, err)
+			assert.Equal(t, body, string(decompressed))
+		})
+	}
+}
+
+func TestServer_cors(t *testing.T) {
+	tests := []struct {
+		name           string
+		origins        []string
+		method         string
+		origin         string
+		expectedStatus int
+		expectedOrigin string
+	}{
+		{name: "allowed origin", origins: []string{"https://app.example.com"}, method: http.MethodGet, origin: "https://app.example.com", expectedStatus: http.StatusOK, expectedOrigin: "https://app.example.com"},
+		{name: "any origin", origins: []string{"*"}, method: http.MethodGet, origin: "https://other.example.com", expectedStatus: http.StatusOK, expectedOrigin: "https://other.example.com"},
+		{name: "other origin", origins: []string{"https://app.example.com"}, method: http.MethodGet, origin: "https://other.example.com", expectedStatus: http.StatusOK},
+		{name: "disabled", method: http.MethodGet, origin: "https://app.example.com", expectedStatus: http.StatusOK},
+		{name: "preflight", origins: []string{"https://app.example.com"}, method: http.MethodOptions, origin: "https://app.example.com", expectedStatus: http.StatusNoContent, expectedOrigin: "https://app.example.com"},
+		{name: "preflight of other origin", origins: []string{"https://app.example.com"}, method: http.MethodOptions, origin: "https://other.example.com", expectedStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{}
+			WithCORS(tt.origins...)(s)
+
+			h := s.cors(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodGet {
+					w.WriteHeader(http.StatusMethodNotAllowed)
+				}
+			}))
+
+			req := httptest.NewRequest(tt.method, "/api/v1/spot/BTCUSDT", http.NoBody)
+			req.Header.Set("Origin", tt.origin)
+			if tt.method == http.MethodOptions {
+				req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Equal(t, tt.expectedOrigin, w.Header().Get("Access-Control-Allow-Origin"))
+			if tt.expectedStatus == http.StatusNoContent {
+				assert.Equal(t, "GET, POST, PUT, PATCH, DELETE", w.Header().Get("Access-Control-Allow-Methods"))
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/ivanglie/coinmon/pkg/log"
+)
+
+// WithExchangeMirrors calls the exchange name at mirrors, in order, when its
+// base URL fails, instead of its public mirrors
+func WithExchangeMirrors(name exchange.Name, mirrors []string) Option {
+	return func(s *Server) {
+		for _, ex := range s.exchanges {
+			if ex.Name == name {
+				ex.Mirrors = make([]string, len(mirrors))
+				for i, m := range mirrors {
+					ex.Mirrors[i] = strings.TrimSuffix(m, "/")
+				}
+			}
+		}
+	}
+}
+
+// exchangeMirrors keeps the base URL of each exchange which last answered,
+// so calls start at a working mirror rather than a failing base URL
+type exchangeMirrors struct {
+	mu sync.Mutex
+	m  map[string]string
+}
+
+// order returns the base URLs of e starting at the one which last answered
+func (m *exchangeMirrors) order(e *exchange.Exchange) []string {
+	urls := e.BaseURLs()
+
+	m.mu.Lock()
+	current := m.m[e.Name.String()]
+	m.mu.Unlock()
+
+	for i, u := range urls {
+		if u == current {
+			return append(urls[i:], urls[:i]...)
+		}
+	}
+
+	return urls
+}
+
+// use makes calls to e start at base
+func (m *exchangeMirrors) use(e *exchange.Exchange, base string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.m == nil {
+		m.m = make(map[string]string)
+	}
+	m.m[e.Name.String()] = base
+}
+
+// fetchHTTP requests the price of pair from the REST API of e, failing over
+// to its mirrors when a base URL is unreachable or answers a server error.
+// The error of the last mirror is returned when every one fails.
+func (s *Server) fetchHTTP(ctx context.Context, e *exchange.Exchange, pair string, read priceReader) (price float64, err error) {
+	urls := s.mirrors.order(e)
+	for i, base := range urls {
+		var failover bool
+		price, failover, err = s.fetchURL(ctx, e, e.PriceURLAt(base, pair), pair, read)
+		if err == nil {
+			if i > 0 {
+				log.FromContext(log.WithFields(ctx, log.Fields{"exchange": e.Name.String()})).Info("Failed over to " + base)
+				s.mirrors.use(e, base)
+			}
+			return price, nil
+		}
+		if !failover {
+			return 0, err
+		}
+	}
+
+	// Failures worth trying at another mirror are worth retrying too
+	return 0, transientError{err}
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/ivanglie/coinmon/pkg/aggregator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_fetchHTTP_mirrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		responses     map[string]int // status by host, unreachable when missing
+		expectedPrice float64
+		expectedErr   string
+		transient     bool
+		expectedHosts []string
+	}{
+		{
+			name:          "base URL",
+			responses:     map[string]int{"api.binance.com": http.StatusOK},
+			expectedPrice: 99999.99,
+			expectedHosts: []string{"api.binance.com"},
+		},
+		{
+			name:          "unreachable base URL",
+			responses:     map[string]int{"api1.binance.com": http.StatusOK},
+			expectedPrice: 99999.99,
+			expectedHosts: []string{"api.binance.com", "api1.binance.com"},
+		},
+		{
+			name:          "server errors",
+			responses:     map[string]int{"api.binance.com": http.StatusBadGateway, "api1.binance.com": http.StatusServiceUnavailable, "api2.binance.com": http.StatusOK},
+			expectedPrice: 99999.99,
+			expectedHosts: []string{"api.binance.com", "api1.binance.com", "api2.binance.com"},
+		},
+		{
+			name:          "client error",
+			responses:     map[string]int{"api.binance.com": http.StatusBadRequest, "api1.binance.com": http.StatusOK},
+			expectedErr:   "unexpected status code: 400, body: ",
+			expectedHosts: []string{"api.binance.com"},
+		},
+		{
+			name:          "every mirror down",
+			responses:     map[string]int{"api.binance.com": http.StatusBadGateway},
+			expectedErr:   "do request: connection refused",
+			transient:     true,
+			expectedHosts: []string{"api.binance.com", "api1.binance.com", "api2.binance.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hosts []string
+			s := &Server{client: &mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+				hosts = append(hosts, req.URL.Host)
+				status, ok := tt.responses[req.URL.Host]
+				switch {
+				case !ok:
+					return nil, errors.New("connection refused")
+				case status != http.StatusOK:
+					return &http.Response{StatusCode: status, Body: http.NoBody}, nil
+				}
+				return mockSuccessfulResponse(req)
+			}}}
+			e := exchange.New(exchange.BINANCE)
+			s.exchanges = []*exchange.Exchange{e}
+			WithExchangeMirrors(exchange.BINANCE, []string{"https://api1.binance.com/", "https://api2.binance.com"})(s)
+
+			q, err := s.fetcher(e).FetchPrice(context.Background(), "BTCUSDT")
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				assert.Equal(t, tt.transient, errors.Is(err, aggregator.ErrTransient))
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedPrice, q.Price)
+			assert.Equal(t, tt.expectedHosts, hosts)
+		})
+	}
+}
+
+func TestServer_fetchHTTP_mirrors_sticky(t *testing.T) {
+	var hosts []string
+	s := &Server{client: &mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+		hosts = append(hosts, req.URL.Host)
+		if req.URL.Host == "api.binance.com" {
+			return nil, errors.New("connection refused")
+		}
+		return mockSuccessfulResponse(req)
+	}}}
+	e := exchange.New(exchange.BINANCE)
+	s.exchanges = []*exchange.Exchange{e}
+
+	for range 2 {
+		_, err := s.fetcher(e).FetchPrice(context.Background(), "BTCUSDT")
+		assert.NoError(t, err)
+	}
+
+	// Calls after a failover start at the mirror which answered
+	assert.Equal(t, []string{"api.binance.com", "api1.binance.com", "api1.binance.com"}, hosts)
+}
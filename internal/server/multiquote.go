@@ -0,0 +1,191 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ivanglie/coinmon/pkg/log"
+)
+
+// maxQuotes bounds the quote currencies of a request
+const maxQuotes = 10
+
+// bridges are the currencies cross-rates are computed through, in order of
+// preference
+var bridges = []string{"USDT", "BTC"}
+
+// AssetQuote represents the price of an asset in a quote currency and the
+// pairs it was computed from, or the error resolving it
+type AssetQuote struct {
+	Price float64  `json:"price,omitempty"`
+	Pairs []string `json:"pairs,omitempty"`
+	Error string   `json:"error,omitempty"`
+}
+
+// MultiQuoteResponse represents the prices of an asset in several quote
+// currencies by currency
+type MultiQuoteResponse struct {
+	Asset  string                `json:"asset"`
+	Quotes map[string]AssetQuote `json:"quotes"`
+}
+
+// handleMultiQuote responds with the price of asset in the comma-separated
+// quote currencies, e.g. USDT,EUR,BTC. Every pair resolved after the first
+// takes a token from the limiter of the request.
+func (s *Server) handleMultiQuote(w http.ResponseWriter, r *http.Request, asset, quotes string, timeout time.Duration) {
+	var currencies []string
+	for c := range strings.SplitSeq(quotes, ",") {
+		if c = strings.ToUpper(strings.TrimSpace(c)); c != "" {
+			currencies = append(currencies, c)
+		}
+	}
+	if len(currencies) > maxQuotes {
+		http.Error(w, "too many quote currencies, max "+strconv.Itoa(maxQuotes), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	rs := &rates{s: s, ctx: ctx, m: make(map[string]*pairRate)}
+	resp := MultiQuoteResponse{Asset: asset, Quotes: make(map[string]AssetQuote, len(currencies))}
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		resolved bool
+	)
+	for _, c := range currencies {
+		wg.Go(func() {
+			var q AssetQuote
+			price, pairs, err := rs.convert(asset, c)
+			if err != nil {
+				q.Error = err.Error()
+			} else {
+				q.Price, q.Pairs = price, pairs
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			resp.Quotes[c] = q
+			resolved = resolved || err == nil
+		})
+	}
+	wg.Wait()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case resolved:
+	case rs.limited:
+		w.WriteHeader(http.StatusTooManyRequests)
+	default:
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Error("Failed to encode response: " + err.Error())
+	}
+}
+
+// rates resolves the prices of pairs once per request, as cross-rates of
+// several currencies share them
+type rates struct {
+	s       *Server
+	ctx     context.Context //nolint:containedctx // rates live for a single request
+	mu      sync.Mutex
+	m       map[string]*pairRate
+	limited bool
+}
+
+// pairRate is the price of a pair, resolved once
+type pairRate struct {
+	once    sync.Once
+	allowed bool
+	price   float64
+	err     error
+}
+
+// get returns the price of pair
+func (rs *rates) get(pair string) (float64, error) {
+	rs.mu.Lock()
+	r, ok := rs.m[pair]
+	if !ok {
+		// The token taken admitting the request covers its first pair
+		r = &pairRate{allowed: len(rs.m) == 0 || charge(rs.ctx, 1)}
+		rs.m[pair] = r
+		rs.limited = rs.limited || !r.allowed
+	}
+	rs.mu.Unlock()
+
+	r.once.Do(func() {
+		if !r.allowed {
+			r.err = errTooManyRequests
+			return
+		}
+		r.price, _, r.err = rs.s.price(rs.ctx, pair)
+	})
+
+	return r.price, r.err
+}
+
+// direct returns the price of base in quote from their pair, or the inverse
+// of the price of the reversed pair
+func (rs *rates) direct(base, quote string) (float64, []string, error) {
+	price, err := rs.get(base + quote)
+	if err == nil {
+		return price, []string{base + quote}, nil
+	}
+
+	inverse, invErr := rs.get(quote + base)
+	if invErr != nil || inverse == 0 {
+		return 0, nil, err
+	}
+
+	return 1 / inverse, []string{quote + base}, nil
+}
+
+// convert returns the price of asset in quote, directly or as a cross-rate
+// through a bridge currency
+func (rs *rates) convert(asset, quote string) (float64, []string, error) {
+	if asset == quote {
+		return 1, nil, nil
+	}
+
+	if price, pairs, err := rs.direct(asset, quote); err == nil {
+		return price, pairs, nil
+	}
+
+	for _, bridge := range bridges {
+		if bridge == asset || bridge == quote {
+			continue
+		}
+
+		assetPrice, assetPairs, err := rs.direct(asset, bridge)
+		if err != nil {
+			continue
+		}
+		quotePrice, quotePairs, err := rs.direct(quote, bridge)
+		if err != nil || quotePrice == 0 {
+			continue
+		}
+
+		return assetPrice / quotePrice, append(assetPairs, quotePairs...), nil
+	}
+
+	rs.mu.Lock()
+	limited := rs.limited
+	rs.mu.Unlock()
+	if limited {
+		return 0, nil, fmt.Errorf("no rate of %s in %s: %w", asset, quote, errTooManyRequests)
+	}
+
+	return 0, nil, fmt.Errorf("no rate of %s in %s", asset, quote)
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/ivanglie/coinmon/pkg/aggregator"
+	"github.com/stretchr/testify/assert"
+)
+
+// pairFetcher quotes the pairs in prices and fails for the others
+type pairFetcher map[string]float64
+
+func (f pairFetcher) FetchPrice(_ context.Context, pair string) (aggregator.Quote, error) {
+	price, ok := f[pair]
+	if !ok {
+		return aggregator.Quote{}, errors.New("unknown pair")
+	}
+
+	return aggregator.Quote{Pair: pair, Price: price, Time: time.Now()}, nil
+}
+
+func TestServer_HandleSpot_Quotes(t *testing.T) {
+	prices := pairFetcher{"ETHUSDT": 4000, "EURUSDT": 1.25, "BTCUSDT": 100000, "USDTTRY": 40, "SOLBTC": 0.002}
+
+	tests := []struct {
+		name             string
+		path             string
+		expectedStatus   int
+		expectedResponse MultiQuoteResponse
+	}{
+		{
+			name:           "direct, inverse and cross-rates",
+			path:           "/api/v1/spot/ETH?quotes=USDT,eur,BTC,TRY,ETH",
+			expectedStatus: http.StatusOK,
+			expectedResponse: MultiQuoteResponse{Asset: "ETH", Quotes: map[string]AssetQuote{
+				"USDT": {Price: 4000, Pairs: []string{"ETHUSDT"}},
+				"EUR":  {Price: 3200, Pairs: []string{"ETHUSDT", "EURUSDT"}},
+				"BTC":  {Price: 0.04, Pairs: []string{"ETHUSDT", "BTCUSDT"}},
+				"TRY":  {Price: 160000, Pairs: []string{"ETHUSDT", "USDTTRY"}},
+				"ETH":  {Price: 1},
+			}},
+		},
+		{
+			name:           "inverse pair",
+			path:           "/api/v1/spot/USDT?quotes=BTC",
+			expectedStatus: http.StatusOK,
+			expectedResponse: MultiQuoteResponse{Asset: "USDT", Quotes: map[string]AssetQuote{
+				"BTC": {Price: 0.00001, Pairs: []string{"BTCUSDT"}},
+			}},
+		},
+		{
+			name:           "cross-rate through BTC",
+			path:           "/api/v1/spot/SOL?quotes=USDT,XYZ",
+			expectedStatus: http.StatusOK,
+			expectedResponse: MultiQuoteResponse{Asset: "SOL", Quotes: map[string]AssetQuote{
+				"USDT": {Price: 200, Pairs: []string{"SOLBTC", "BTCUSDT"}},
+				"XYZ":  {Error: "no rate of SOL in XYZ"},
+			}},
+		},
+		{
+			name:           "no rates",
+			path:           "/api/v1/spot/XYZ?quotes=USDT",
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedResponse: MultiQuoteResponse{Asset: "XYZ", Quotes: map[string]AssetQuote{
+				"USDT": {Error: "no rate of XYZ in USDT"},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{
+				exchanges: []*exchange.Exchange{exchange.New(exchange.BINANCE)},
+				fetchers:  map[exchange.Name]Fetcher{exchange.BINANCE: prices},
+			}
+
+			w := httptest.NewRecorder()
+			s.routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, http.NoBody))
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+			var resp MultiQuoteResponse
+			assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+			assert.Equal(t, tt.expectedResponse.Asset, resp.Asset)
+			assert.Len(t, resp.Quotes, len(tt.expectedResponse.Quotes))
+			for c, expected := range tt.expectedResponse.Quotes {
+				assert.InDelta(t, expected.Price, resp.Quotes[c].Price, 1e-9, c)
+				assert.Equal(t, expected.Pairs, resp.Quotes[c].Pairs, c)
+				assert.Equal(t, expected.Error, resp.Quotes[c].Error, c)
+			}
+		})
+	}
+}
+
+func TestServer_HandleSpot_TooManyQuotes(t *testing.T) {
+	s := &Server{}
+
+	w := httptest.NewRecorder()
+	s.routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/spot/BTC?quotes=A,B,C,D,E,F,G,H,I,J,K", http.NoBody))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "too many quote currencies, max 10\n", w.Body.String())
+}
+
+func TestServer_HandleSpot_Quotes_charged(t *testing.T) {
+	s := &Server{
+		exchanges: []*exchange.Exchange{exchange.New(exchange.BINANCE)},
+		fetchers:  map[exchange.Name]Fetcher{exchange.BINANCE: pairFetcher{"ETHUSDT": 4000, "GBPUSDT": 1.25}},
+	}
+	// ETH in GBP resolves ETHGBP, GBPETH, ETHUSDT and GBPUSDT, taking a
+	// token for each but the first
+	WithAPIKeys([]APIKey{{Name: "a", Key: "secret-a", Rate: 0.001, Burst: 3}, {Name: "b", Key: "secret-b", Rate: 0.001, Burst: 4}}, true)(s)
+
+	tests := []struct {
+		key              string
+		expectedStatus   int
+		expectedResponse AssetQuote
+	}{
+		{key: "secret-a", expectedStatus: http.StatusTooManyRequests, expectedResponse: AssetQuote{Error: "no rate of ETH in GBP: too many requests"}},
+		{key: "secret-b", expectedStatus: http.StatusOK, expectedResponse: AssetQuote{Price: 3200, Pairs: []string{"ETHUSDT", "GBPUSDT"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/spot/ETH?quotes=GBP", http.NoBody)
+			r.Header.Set("X-API-Key", tt.key)
+			w := httptest.NewRecorder()
+			s.routes().ServeHTTP(w, r)
+			assert.Equal(t, tt.expectedStatus, w.Code)
+
+			var resp MultiQuoteResponse
+			assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+			assert.Equal(t, tt.expectedResponse, resp.Quotes["GBP"])
+		})
+	}
+}
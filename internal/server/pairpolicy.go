@@ -0,0 +1,75 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+)
+
+// ErrPairForbidden is returned for pairs the operator does not let the
+// server serve
+var ErrPairForbidden = errors.New("pair not served")
+
+// pairPolicy restricts the pairs served to the allowed ones, all when none
+// are, except the denied ones. Both are patterns of path.Match, e.g. BTC*.
+type pairPolicy struct {
+	allow []string
+	deny  []string
+}
+
+// WithPairPolicy serves only pairs matching a pattern of allow, any pair
+// when empty, and none matching a pattern of deny, e.g. *USDT and LUNA*,
+// so a public instance is not a free proxy to the exchanges
+func WithPairPolicy(allow, deny []string) Option {
+	return func(s *Server) {
+		s.pairs = pairPolicy{allow: upperPatterns(allow), deny: upperPatterns(deny)}
+	}
+}
+
+func upperPatterns(patterns []string) []string {
+	upper := make([]string, len(patterns))
+	for i, p := range patterns {
+		upper[i] = strings.ToUpper(strings.TrimSpace(p))
+	}
+
+	return upper
+}
+
+// allows reports whether pair may be served
+func (p pairPolicy) allows(pair string) bool {
+	if slices.ContainsFunc(p.deny, matches(pair)) {
+		return false
+	}
+
+	return len(p.allow) == 0 || slices.ContainsFunc(p.allow, matches(pair))
+}
+
+func matches(pair string) func(pattern string) bool {
+	return func(pattern string) bool {
+		ok, _ := path.Match(pattern, pair)
+		return ok
+	}
+}
+
+// checkPair returns ErrPairForbidden for pairs the server may not serve
+func (s *Server) checkPair(pair string) error {
+	if !s.pairs.allows(pair) {
+		return fmt.Errorf("%s: %w", pair, ErrPairForbidden)
+	}
+
+	return nil
+}
+
+// forbidPair answers 403 for pairs the server may not serve and reports
+// whether it did
+func (s *Server) forbidPair(w http.ResponseWriter, pair string) bool {
+	if err := s.checkPair(pair); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return true
+	}
+
+	return false
+}
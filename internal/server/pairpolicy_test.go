@@ -0,0 +1,80 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPairPolicy_Allows(t *testing.T) {
+	tests := []struct {
+		name     string
+		allow    []string
+		deny     []string
+		pair     string
+		expected bool
+	}{
+		{name: "no policy", pair: "DOGEUSDT", expected: true},
+		{name: "allowed", allow: []string{"BTC*", "ETH*"}, pair: "ETHUSDT", expected: true},
+		{name: "not allowed", allow: []string{"BTC*", "ETH*"}, pair: "DOGEUSDT"},
+		{name: "denied", deny: []string{"LUNA*"}, pair: "LUNAUSDT"},
+		{name: "not denied", deny: []string{"LUNA*"}, pair: "BTCUSDT", expected: true},
+		{name: "deny wins", allow: []string{"*USDT"}, deny: []string{"LUNAUSDT"}, pair: "LUNAUSDT"},
+		{name: "lower case patterns", allow: []string{"btc*"}, pair: "BTCUSDT", expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{}
+			WithPairPolicy(tt.allow, tt.deny)(s)
+
+			assert.Equal(t, tt.expected, s.pairs.allows(tt.pair))
+		})
+	}
+}
+
+func TestServer_PairPolicy(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "v1 allowed", path: "/api/v1/spot/btcusdt", expectedStatus: http.StatusOK, expectedBody: "99999.99"},
+		{name: "v1 forbidden", path: "/api/v1/spot/dogeusdt", expectedStatus: http.StatusForbidden, expectedBody: "DOGEUSDT: pair not served\n"},
+		{name: "stream forbidden", path: "/api/v1/stream/DOGEUSDT", expectedStatus: http.StatusForbidden, expectedBody: "DOGEUSDT: pair not served\n"},
+		{name: "next forbidden", path: "/api/v1/spot/DOGEUSDT/next", expectedStatus: http.StatusForbidden, expectedBody: "DOGEUSDT: pair not served\n"},
+		{
+			name:           "v2 forbidden",
+			path:           "/api/v2/spot/DOGEUSDT",
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `"errors":[{"code":"forbidden","message":"DOGEUSDT: pair not served","source":"DOGEUSDT"}]`,
+		},
+		{
+			name:           "v2 batch",
+			path:           "/api/v2/spot?pairs=BTCUSDT,DOGEUSDT",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"errors":[{"code":"forbidden","message":"DOGEUSDT: pair not served","source":"DOGEUSDT"}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{
+				events:    bus.New(),
+				exchanges: exchanges[:1],
+				fetchers:  mockFetchers(),
+			}
+			WithPairPolicy([]string{"BTC*"}, nil)(s)
+
+			w := httptest.NewRecorder()
+			s.routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, http.NoBody))
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
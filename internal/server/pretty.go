@@ -0,0 +1,65 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+)
+
+// formatPretty is the ?format of human-friendly spot responses
+const formatPretty = "pretty"
+
+// quoteCurrencies are the quote currencies pairs are split at for display,
+// longer ones first so USDT is not taken for USD
+var quoteCurrencies = []string{"FDUSD", "USDT", "USDC", "TUSD", "BUSD", "USD", "EUR", "GBP", "TRY", "BRL", "DAI", "BTC", "ETH", "BNB"}
+
+// displayPair returns pair with its currencies separated, e.g. BTC/USDT, or
+// pair itself when the quote currency is not known
+func displayPair(pair string) string {
+	for _, quote := range quoteCurrencies {
+		if base, ok := strings.CutSuffix(pair, quote); ok && base != "" {
+			return base + "/" + quote
+		}
+	}
+
+	return pair
+}
+
+// formatPrice returns price with thousands separators and without trailing
+// zeros, e.g. 99,999.99
+func formatPrice(price float64) string {
+	s := strconv.FormatFloat(price, 'f', -1, 64)
+
+	sign := ""
+	if s[0] == '-' {
+		sign, s = "-", s[1:]
+	}
+	integer, fraction, hasFraction := strings.Cut(s, ".")
+
+	var b strings.Builder
+	b.WriteString(sign)
+	for i, d := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(d)
+	}
+	if hasFraction {
+		b.WriteString("." + fraction)
+	}
+
+	return b.String()
+}
+
+// pretty returns e as a line for curl users and shell prompts, e.g.
+// "BTC/USDT 99,999.99 (binance, 12:01:33Z)"
+func pretty(e bus.PriceUpdated, stale bool) string {
+	details := e.Source + ", " + e.Time.UTC().Format(time.TimeOnly) + "Z"
+	if stale {
+		details += ", stale"
+	}
+
+	return displayPair(e.Pair) + " " + formatPrice(e.Price) + " (" + details + ")"
+}
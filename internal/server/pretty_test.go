@@ -0,0 +1,86 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatPrice(t *testing.T) {
+	tests := []struct {
+		price    float64
+		expected string
+	}{
+		{price: 99999.99, expected: "99,999.99"},
+		{price: 1234567.5, expected: "1,234,567.5"},
+		{price: 100000, expected: "100,000"},
+		{price: 999, expected: "999"},
+		{price: 0.00001234, expected: "0.00001234"},
+		{price: -1234.5, expected: "-1,234.5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			assert.Equal(t, tt.expected, formatPrice(tt.price))
+		})
+	}
+}
+
+func TestDisplayPair(t *testing.T) {
+	tests := []struct {
+		pair     string
+		expected string
+	}{
+		{pair: "BTCUSDT", expected: "BTC/USDT"},
+		{pair: "ETHUSD", expected: "ETH/USD"},
+		{pair: "SOLBTC", expected: "SOL/BTC"},
+		{pair: "ETHFDUSD", expected: "ETH/FDUSD"},
+		{pair: "USDT", expected: "USDT"},
+		{pair: "FOOBAR", expected: "FOOBAR"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pair, func(t *testing.T) {
+			assert.Equal(t, tt.expected, displayPair(tt.pair))
+		})
+	}
+}
+
+func TestPretty(t *testing.T) {
+	e := bus.PriceUpdated{Pair: "BTCUSDT", Price: 99999.99, Source: "binance", Time: time.Date(2026, 1, 1, 12, 1, 33, 0, time.UTC)}
+
+	assert.Equal(t, "BTC/USDT 99,999.99 (binance, 12:01:33Z)", pretty(e, false))
+	assert.Equal(t, "BTC/USDT 99,999.99 (binance, 12:01:33Z, stale)", pretty(e, true))
+}
+
+func TestServer_HandleSpot_Pretty(t *testing.T) {
+	tests := []struct {
+		name           string
+		format         string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "pretty", format: "pretty", expectedStatus: http.StatusOK, expectedBody: "BTC/USDT 99,999.99 (binance, "},
+		{name: "invalid", format: "fancy", expectedStatus: http.StatusBadRequest, expectedBody: "invalid format \"fancy\"\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{
+				events:    bus.New(),
+				exchanges: exchanges[:1],
+				fetchers:  mockFetchers(),
+			}
+
+			w := httptest.NewRecorder()
+			s.routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/spot/btcusdt?format="+tt.format, http.NoBody))
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
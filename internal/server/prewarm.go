@@ -0,0 +1,54 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Prewarm opens connections to the REST API of every exchange, at the mirror
+// calls start at, so price requests skip DNS lookups and TLS handshakes.
+// Run periodically it keeps idle connections from timing out. Any answer
+// warms a connection, only exchanges which cannot be reached fail.
+// Exchanges with their own fetcher are skipped.
+func (s *Server) Prewarm(ctx context.Context) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, e := range s.exchanges {
+		if _, ok := s.fetchers[e.Name]; ok {
+			continue
+		}
+		base := s.mirrors.order(e)[0]
+		wg.Go(func() {
+			if err := s.warm(ctx, base); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", e.Name, err))
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// warm makes a HEAD request to base, leaving its connection idle for reuse
+func (s *Server) warm(ctx context.Context, base string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, base, http.NoBody)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("do request: %w", err)
+	}
+
+	return resp.Body.Close()
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_Prewarm(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []string
+	)
+	s := &Server{
+		exchanges: []*exchange.Exchange{exchange.New(exchange.BINANCE), exchange.New(exchange.BYBIT), exchange.New(exchange.KRAKEN)},
+		fetchers:  map[exchange.Name]Fetcher{exchange.KRAKEN: pairFetcher{}},
+		client: &mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			requests = append(requests, req.Method+" "+req.URL.String())
+			mu.Unlock()
+			if req.URL.Host == "api.bybit.com" {
+				return nil, errors.New("connection refused")
+			}
+			return &http.Response{StatusCode: http.StatusNotFound, Body: http.NoBody}, nil
+		}},
+	}
+	s.mirrors.use(s.exchanges[0], "https://api1.binance.com")
+
+	err := s.Prewarm(context.Background())
+	assert.EqualError(t, err, "bybit: do request: connection refused")
+	assert.ElementsMatch(t, []string{"HEAD https://api1.binance.com", "HEAD https://api.bybit.com"}, requests)
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof" //nolint:gosec // profiles are gated by gateDebug
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// routes returns a mux with all HTTP routes of the server. The routes of
+// optional features are only registered when they are enabled. Requests to
+// other paths get 404 Not Found, and with other methods 405 Method Not
+// Allowed.
+func (s *Server) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /{$}", s.basicAuth(s.HandleIndex))
+	mux.HandleFunc("GET /static/{name...}", s.HandleStatic)
+	mux.HandleFunc("GET /favicon.ico", s.HandleWellKnown("favicon.ico"))
+	mux.HandleFunc("GET /robots.txt", s.HandleWellKnown("robots.txt"))
+	mux.HandleFunc("GET /.well-known/security.txt", s.HandleSecurityTxt)
+	mux.HandleFunc("GET /api/v1/spot/{pair}", s.rateLimit(s.HandleSpot))
+	mux.HandleFunc("GET /api/v1/spot/{pair}/next", s.rateLimit(s.HandleNext))
+	mux.HandleFunc("/api/v2/", s.rateLimit(s.v2Routes().ServeHTTP))
+	if s.indices != nil {
+		mux.HandleFunc("GET /api/v1/index/{name}", s.rateLimit(s.HandleCompositeIndex))
+	}
+	mux.HandleFunc("GET /api/v1/assets/{symbol}", s.rateLimit(s.HandleAsset))
+	mux.HandleFunc("GET /api/v1/top", s.rateLimit(s.HandleTop))
+	mux.HandleFunc("GET /api/v1/stream/{pair}", s.rateLimit(s.HandleStream))
+	mux.HandleFunc("GET /ws", s.rateLimit(s.HandleWS))
+	// Routes of a handler with several methods share its rate limit
+	graphql := s.rateLimit(s.HandleGraphQL)
+	mux.HandleFunc("GET /graphql", graphql)
+	mux.HandleFunc("POST /graphql", graphql)
+	mux.HandleFunc("POST /rpc", s.rateLimit(s.HandleRPC))
+	if s.scheduler != nil {
+		mux.HandleFunc("GET /api/v1/jobs", s.basicAuth(s.HandleJobs))
+	}
+	mux.HandleFunc("GET /api/v1/stats", s.basicAuth(s.HandleStats))
+	if s.watchlist != nil {
+		watchlist := s.rateLimit(s.HandleWatchlist)
+		mux.HandleFunc("GET /api/v1/watchlist", watchlist)
+		mux.HandleFunc("PUT /api/v1/watchlist", watchlist)
+		mux.HandleFunc("POST /api/v1/watchlist", watchlist)
+		mux.HandleFunc("DELETE /api/v1/watchlist/{pair}", watchlist)
+	}
+	if s.usage != nil {
+		mux.HandleFunc("GET /api/v1/usage", s.rateLimit(s.HandleUsage))
+	}
+	if s.hasSigners() {
+		mux.HandleFunc("GET /api/v1/balances", s.basicAuth(s.HandleBalances))
+	}
+	if s.basicUser != "" || s.basicPassword != "" {
+		mux.HandleFunc("GET /api/v1/admin/exchanges", s.adminAuth(s.HandleAdminExchanges))
+		mux.HandleFunc("PATCH /api/v1/admin/exchanges/{exchange}", s.adminAuth(s.HandleAdminExchanges))
+		mux.HandleFunc("GET /api/v1/admin/maintenance", s.adminAuth(s.HandleAdminMaintenance))
+		mux.HandleFunc("PUT /api/v1/admin/maintenance", s.adminAuth(s.HandleAdminMaintenance))
+		mux.HandleFunc("DELETE /api/v1/admin/maintenance", s.adminAuth(s.HandleAdminMaintenance))
+		if s.usage != nil {
+			mux.HandleFunc("GET /api/v1/admin/usage", s.adminAuth(s.HandleAdminUsage))
+		}
+		// Forms posted to the admin page from other sites would carry the
+		// basic auth credentials of the browser
+		admin := http.NewCrossOriginProtection().Handler(s.adminAuth(s.HandleAdmin))
+		mux.Handle("GET /admin", admin)
+		mux.Handle("POST /admin", admin)
+	}
+	mux.HandleFunc("GET /healthz", s.HandleHealthz)
+	mux.HandleFunc("GET /readyz", s.HandleReadyz)
+	mux.HandleFunc("GET /debug/upstream", s.HandleUpstream)
+	mux.Handle("GET /metrics", promhttp.HandlerFor(s.registry, promhttp.HandlerOpts{}))
+
+	// Profiles and expvars are registered here rather than on the default mux
+	// their packages register on, and profiles are gated by gateDebug
+	mux.HandleFunc("GET /debug/pprof/", pprof.Index)
+	mux.HandleFunc("GET /debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("GET /debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("POST /debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("GET /debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("GET /debug/pprof/trace", pprof.Trace)
+	mux.Handle("GET /debug/vars", expvar.Handler())
+
+	return mux
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_routes(t *testing.T) {
+	plain := New("")
+	admin := New("", WithBasicAuth("admin", "secret"))
+
+	serve := func(s *Server, path string) int {
+		w := httptest.NewRecorder()
+		s.listener.(*http.Server).Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, http.NoBody))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve(plain, "/healthz"))
+	assert.Equal(t, http.StatusNotFound, serve(plain, "/debug/vars"))
+	assert.Equal(t, http.StatusNotFound, serve(plain, "/debug/pprof/"))
+	assert.Equal(t, http.StatusNotFound, serve(plain, "/api/v1/admin/maintenance"))
+	assert.Equal(t, http.StatusUnauthorized, serve(admin, "/api/v1/admin/maintenance"))
+
+	// Routes stay off the default mux
+	_, pattern := http.DefaultServeMux.Handler(httptest.NewRequest(http.MethodGet, "/api/v1/spot/BTCUSDT", http.NoBody))
+	assert.Empty(t, pattern)
+}
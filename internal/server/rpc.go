@@ -0,0 +1,296 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/ivanglie/coinmon/pkg/log"
+)
+
+const maxRPCBody = 64 << 10
+
+// JSON-RPC 2.0 error codes
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcUnavailable    = -32000
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// BatchResponse represents prices of several pairs resolved at once
+type BatchResponse struct {
+	Prices []DetailedResponse `json:"prices"`
+	Errors []PairError        `json:"errors,omitempty"`
+}
+
+// PairError represents a pair that failed to resolve
+type PairError struct {
+	Pair    string `json:"pair"`
+	Message string `json:"message"`
+}
+
+// HandleRPC handles /rpc JSON-RPC 2.0 requests.
+// Supported methods are spot.price ({"pair":"BTCUSDT"} or ["BTCUSDT"]) and
+// spot.batch ({"pairs":["BTCUSDT","ETHUSDT"]} or ["BTCUSDT","ETHUSDT"]).
+func (s *Server) HandleRPC(w http.ResponseWriter, r *http.Request) {
+	var body json.RawMessage
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRPCBody)).Decode(&body); err != nil {
+		s.writeRPC(w, rpcResponse{JSONRPC: "2.0", Error: &rpcError{Code: rpcParseError, Message: "Parse error"}, ID: json.RawMessage("null")})
+		return
+	}
+
+	ctx := r.Context()
+
+	// A batch is an array of requests answered with an array of responses
+	if body = bytes.TrimSpace(body); len(body) > 0 && body[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil || len(batch) == 0 {
+			s.writeRPC(w, rpcResponse{JSONRPC: "2.0", Error: &rpcError{Code: rpcInvalidRequest, Message: "Invalid Request"}, ID: json.RawMessage("null")})
+			return
+		}
+		if len(batch) > maxBatchPairs {
+			s.writeRPC(w, rpcResponse{JSONRPC: "2.0", Error: &rpcError{Code: rpcInvalidRequest, Message: fmt.Sprintf("too many requests in batch, max %d", maxBatchPairs)}, ID: json.RawMessage("null")})
+			return
+		}
+
+		// The pairs of all requests count, so spot.batch requests can't
+		// multiply the pairs one HTTP request resolves
+		var pairs int
+		for _, raw := range batch {
+			pairs += rpcPairs(raw)
+		}
+		if pairs > maxBatchPairs {
+			s.writeRPC(w, rpcResponse{JSONRPC: "2.0", Error: &rpcError{Code: rpcInvalidRequest, Message: fmt.Sprintf("too many trading pairs in batch, max %d", maxBatchPairs)}, ID: json.RawMessage("null")})
+			return
+		}
+
+		responses := make([]*rpcResponse, len(batch))
+		var wg sync.WaitGroup
+		for i, raw := range batch {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				responses[i] = s.callRPC(ctx, raw)
+			}()
+		}
+		wg.Wait()
+
+		var out []rpcResponse
+		for _, resp := range responses {
+			if resp != nil {
+				out = append(out, *resp)
+			}
+		}
+
+		// A batch of notifications gets no response at all
+		if len(out) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		s.writeRPC(w, out)
+		return
+	}
+
+	resp := s.callRPC(ctx, body)
+	if resp == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	s.writeRPC(w, resp)
+}
+
+// callRPC executes a single request. Notifications (requests without an id)
+// return nil.
+func (s *Server) callRPC(ctx context.Context, raw json.RawMessage) *rpcResponse {
+	var req rpcRequest
+	if err := json.Unmarshal(raw, &req); err != nil || req.JSONRPC != "2.0" || req.Method == "" {
+		return &rpcResponse{JSONRPC: "2.0", Error: &rpcError{Code: rpcInvalidRequest, Message: "Invalid Request"}, ID: json.RawMessage("null")}
+	}
+
+	result, err := s.rpcMethod(ctx, req.Method, req.Params)
+	if req.ID == nil {
+		return nil
+	}
+
+	resp := &rpcResponse{JSONRPC: "2.0", ID: req.ID}
+	if err != nil {
+		resp.Error = err
+	} else {
+		resp.Result = result
+	}
+
+	return resp
+}
+
+func (s *Server) rpcMethod(ctx context.Context, method string, params json.RawMessage) (any, *rpcError) {
+	switch method {
+	case "spot.price":
+		var p struct {
+			Pair string `json:"pair"`
+		}
+		if !decodeRPCParams(params, &p, &p.Pair) {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "Invalid params"}
+		}
+
+		pair := strings.ToUpper(strings.TrimSpace(p.Pair))
+		if pair == "" {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "missing trading pair"}
+		}
+
+		price, source, err := s.price(ctx, pair)
+		if err != nil {
+			return nil, &rpcError{Code: rpcUnavailable, Message: err.Error()}
+		}
+		s.resolved(pair, source, price)
+
+		return DetailedResponse{Pair: pair, Price: price, Source: source}, nil
+	case "spot.batch":
+		var p struct {
+			Pairs []string `json:"pairs"`
+		}
+		if !decodeRPCParams(params, &p, &p.Pairs) {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "Invalid params"}
+		}
+		if len(p.Pairs) == 0 {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "missing trading pairs"}
+		}
+		if len(p.Pairs) > maxBatchPairs {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: fmt.Sprintf("too many trading pairs, max %d", maxBatchPairs)}
+		}
+
+		return s.batch(ctx, p.Pairs), nil
+	}
+
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: "Method not found"}
+}
+
+// rpcPairs returns the number of pairs a request resolves, 0 for invalid
+// requests
+func rpcPairs(raw json.RawMessage) int {
+	var req rpcRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return 0
+	}
+
+	switch req.Method {
+	case "spot.price":
+		return 1
+	case "spot.batch":
+		var p struct {
+			Pairs []string `json:"pairs"`
+		}
+		if decodeRPCParams(req.Params, &p, &p.Pairs) {
+			return len(p.Pairs)
+		}
+	}
+
+	return 0
+}
+
+// decodeRPCParams decodes by-name params into named or by-position params
+// into positional
+func decodeRPCParams(params json.RawMessage, named, positional any) bool {
+	params = bytes.TrimSpace(params)
+	if len(params) == 0 {
+		return true
+	}
+
+	if params[0] != '[' {
+		return json.Unmarshal(params, named) == nil
+	}
+
+	// A single positional param may be given as an array itself
+	if json.Unmarshal(params, positional) == nil {
+		return true
+	}
+
+	var args []json.RawMessage
+	if err := json.Unmarshal(params, &args); err != nil || len(args) != 1 {
+		return false
+	}
+
+	return json.Unmarshal(args[0], positional) == nil
+}
+
+// batch resolves prices of pairs concurrently
+func (s *Server) batch(ctx context.Context, pairs []string) BatchResponse {
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		resp = BatchResponse{Prices: []DetailedResponse{}}
+	)
+
+	for _, pair := range pairs {
+		pair = strings.ToUpper(strings.TrimSpace(pair))
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var (
+				price  float64
+				source string
+				err    error
+			)
+			if pair == "" {
+				err = fmt.Errorf("missing trading pair")
+			} else {
+				price, source, err = s.price(ctx, pair)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				resp.Errors = append(resp.Errors, PairError{Pair: pair, Message: err.Error()})
+				return
+			}
+			resp.Prices = append(resp.Prices, DetailedResponse{Pair: pair, Price: price, Source: source})
+		}()
+	}
+	wg.Wait()
+
+	for _, p := range resp.Prices {
+		s.resolved(p.Pair, p.Source, p.Price)
+	}
+
+	return resp
+}
+
+func (s *Server) writeRPC(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error("Failed to encode response: " + err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
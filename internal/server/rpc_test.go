@@ -0,0 +1,185 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_HandleRPC(t *testing.T) {
+	tests := []struct {
+		name             string
+		method           string
+		body             string
+		fetchers         map[exchange.Name]Fetcher
+		expectedStatus   int
+		expectedResponse string
+	}{
+		{
+			name:             "spot.price by name",
+			body:             `{"jsonrpc":"2.0","method":"spot.price","params":{"pair":"btcusdt"},"id":1}`,
+			fetchers:         mockFetchers(),
+			expectedStatus:   http.StatusOK,
+			expectedResponse: `{"jsonrpc":"2.0","result":{"pair":"BTCUSDT","price":99999.99,"source":"binance"},"id":1}`,
+		},
+		{
+			name:             "spot.price by position",
+			body:             `{"jsonrpc":"2.0","method":"spot.price","params":["BTCUSDT"],"id":"a"}`,
+			fetchers:         mockFetchers(),
+			expectedStatus:   http.StatusOK,
+			expectedResponse: `{"jsonrpc":"2.0","result":{"pair":"BTCUSDT","price":99999.99,"source":"binance"},"id":"a"}`,
+		},
+		{
+			name:             "spot.price of unknown pair",
+			body:             `{"jsonrpc":"2.0","method":"spot.price","params":{"pair":"INVALID"},"id":1}`,
+			fetchers:         mockPairErrorFetchers(-1121, "Invalid symbol."),
+			expectedStatus:   http.StatusOK,
+			expectedResponse: `{"jsonrpc":"2.0","error":{"code":-32000,"message":"{\"message\":\"all exchanges failed\",\"errors\":[\"binance: code=-1121, msg=Invalid symbol.\"]}"},"id":1}`,
+		},
+		{
+			name:             "spot.price without pair",
+			body:             `{"jsonrpc":"2.0","method":"spot.price","id":1}`,
+			fetchers:         mockFetchers(),
+			expectedStatus:   http.StatusOK,
+			expectedResponse: `{"jsonrpc":"2.0","error":{"code":-32602,"message":"missing trading pair"},"id":1}`,
+		},
+		{
+			name:             "spot.price with invalid params",
+			body:             `{"jsonrpc":"2.0","method":"spot.price","params":{"pair":1},"id":1}`,
+			fetchers:         mockFetchers(),
+			expectedStatus:   http.StatusOK,
+			expectedResponse: `{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid params"},"id":1}`,
+		},
+		{
+			name: "spot.batch",
+			body: `{"jsonrpc":"2.0","method":"spot.batch","params":{"pairs":["BTCUSDT","INVALID"]},"id":1}`,
+			fetchers: mockPairFetchers(map[string]map[exchange.Name]Fetcher{
+				"INVALID": mockPairErrorFetchers(-1121, "Invalid symbol."),
+			}),
+			expectedStatus: http.StatusOK,
+			expectedResponse: `{"jsonrpc":"2.0","result":{` +
+				`"prices":[{"pair":"BTCUSDT","price":99999.99,"source":"binance"}],` +
+				`"errors":[{"pair":"INVALID","message":"{\"message\":\"all exchanges failed\",\"errors\":[\"binance: code=-1121, msg=Invalid symbol.\"]}"}]` +
+				`},"id":1}`,
+		},
+		{
+			name:             "spot.batch by position",
+			body:             `{"jsonrpc":"2.0","method":"spot.batch","params":["BTCUSDT"],"id":1}`,
+			fetchers:         mockFetchers(),
+			expectedStatus:   http.StatusOK,
+			expectedResponse: `{"jsonrpc":"2.0","result":{"prices":[{"pair":"BTCUSDT","price":99999.99,"source":"binance"}]},"id":1}`,
+		},
+		{
+			name:             "spot.batch without pairs",
+			body:             `{"jsonrpc":"2.0","method":"spot.batch","params":{"pairs":[]},"id":1}`,
+			fetchers:         mockFetchers(),
+			expectedStatus:   http.StatusOK,
+			expectedResponse: `{"jsonrpc":"2.0","error":{"code":-32602,"message":"missing trading pairs"},"id":1}`,
+		},
+		{
+			name:             "spot.batch with too many pairs",
+			body:             `{"jsonrpc":"2.0","method":"spot.batch","params":{"pairs":[` + strings.Repeat(`"BTCUSDT",`, maxBatchPairs) + `"ETHUSDT"]},"id":1}`,
+			fetchers:         mockFetchers(),
+			expectedStatus:   http.StatusOK,
+			expectedResponse: `{"jsonrpc":"2.0","error":{"code":-32602,"message":"too many trading pairs, max 20"},"id":1}`,
+		},
+		{
+			name:             "unknown method",
+			body:             `{"jsonrpc":"2.0","method":"spot.unknown","id":1}`,
+			fetchers:         mockFetchers(),
+			expectedStatus:   http.StatusOK,
+			expectedResponse: `{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":1}`,
+		},
+		{
+			name:             "invalid request",
+			body:             `{"jsonrpc":"1.0","method":"spot.price","id":1}`,
+			fetchers:         mockFetchers(),
+			expectedStatus:   http.StatusOK,
+			expectedResponse: `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}`,
+		},
+		{
+			name:             "parse error",
+			body:             `{"jsonrpc":"2.0",`,
+			fetchers:         mockFetchers(),
+			expectedStatus:   http.StatusOK,
+			expectedResponse: `{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error"},"id":null}`,
+		},
+		{
+			name:           "notification",
+			body:           `{"jsonrpc":"2.0","method":"spot.price","params":{"pair":"BTCUSDT"}}`,
+			fetchers:       mockFetchers(),
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name: "batch",
+			body: `[` +
+				`{"jsonrpc":"2.0","method":"spot.price","params":{"pair":"BTCUSDT"},"id":1},` +
+				`{"jsonrpc":"2.0","method":"spot.price","params":{"pair":"BTCUSDT"}},` +
+				`{"jsonrpc":"2.0","method":"spot.unknown","id":2},` +
+				`1]`,
+			fetchers:       mockFetchers(),
+			expectedStatus: http.StatusOK,
+			expectedResponse: `[` +
+				`{"jsonrpc":"2.0","result":{"pair":"BTCUSDT","price":99999.99,"source":"binance"},"id":1},` +
+				`{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":2},` +
+				`{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}]`,
+		},
+		{
+			name: "batch with too many pairs",
+			body: `[` +
+				`{"jsonrpc":"2.0","method":"spot.batch","params":{"pairs":[` + strings.Repeat(`"BTCUSDT",`, maxBatchPairs-1) + `"ETHUSDT"]},"id":1},` +
+				`{"jsonrpc":"2.0","method":"spot.price","params":{"pair":"BTCUSDT"},"id":2}]`,
+			fetchers:         mockFetchers(),
+			expectedStatus:   http.StatusOK,
+			expectedResponse: `{"jsonrpc":"2.0","error":{"code":-32600,"message":"too many trading pairs in batch, max 20"},"id":null}`,
+		},
+		{
+			name:             "empty batch",
+			body:             `[]`,
+			fetchers:         mockFetchers(),
+			expectedStatus:   http.StatusOK,
+			expectedResponse: `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}`,
+		},
+		{
+			name:           "batch of notifications",
+			body:           `[{"jsonrpc":"2.0","method":"spot.price","params":{"pair":"BTCUSDT"}}]`,
+			fetchers:       mockFetchers(),
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "method not allowed",
+			method:         http.MethodGet,
+			fetchers:       mockFetchers(),
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{
+				events:    bus.New(),
+				exchanges: exchanges[:1],
+				fetchers:  tt.fetchers,
+			}
+
+			method := http.MethodPost
+			if tt.method != "" {
+				method = tt.method
+			}
+
+			req := httptest.NewRequest(method, "/rpc", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			s.routes().ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedResponse != "" {
+				assert.JSONEq(t, tt.expectedResponse, w.Body.String())
+			}
+		})
+	}
+}
@@ -3,22 +3,38 @@ package server
 
 import (
 	"bytes"
-	"compress/gzip"
+	"cmp"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"html/template"
 	"io"
+	"io/fs"
 	"net"
 	"net/http"
-	"strconv"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/graph-gophers/graphql-go"
+	"github.com/ivanglie/coinmon/internal/alert"
+	"github.com/ivanglie/coinmon/internal/bus"
 	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/ivanglie/coinmon/internal/feed"
+	"github.com/ivanglie/coinmon/internal/index"
+	"github.com/ivanglie/coinmon/internal/scheduler"
+	"github.com/ivanglie/coinmon/internal/usage"
+	"github.com/ivanglie/coinmon/internal/watchlist"
+	"github.com/ivanglie/coinmon/pkg/aggregator"
 	"github.com/ivanglie/coinmon/pkg/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/crypto/acme/autocert"
 	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
 )
 
 // DetailedResponse represents detailed price response
@@ -26,6 +42,10 @@ type DetailedResponse struct {
 	Pair   string  `json:"pair"`
 	Price  float64 `json:"price"`
 	Source string  `json:"source"`
+	// Stale is set when the exchanges did not answer within the ?timeout of
+	// the request and the latest price resolved before is returned, at Time
+	Stale bool       `json:"stale,omitempty"`
+	Time  *time.Time `json:"time,omitempty"`
 }
 
 type ipLimiter struct {
@@ -33,23 +53,311 @@ type ipLimiter struct {
 	lastSeen time.Time
 }
 
+// ipLimiters limits requests per client IP, forgetting IPs idle for 10
+// minutes
+type ipLimiters struct {
+	mu sync.Mutex
+	m  map[string]*ipLimiter
+}
+
+func newIPLimiters() *ipLimiters {
+	l := &ipLimiters{m: make(map[string]*ipLimiter)}
+
+	go func() {
+		for range time.Tick(5 * time.Minute) {
+			l.mu.Lock()
+			for ip, il := range l.m {
+				if time.Since(il.lastSeen) > 10*time.Minute {
+					delete(l.m, ip)
+				}
+			}
+			l.mu.Unlock()
+		}
+	}()
+
+	return l
+}
+
+// get returns the limiter of ip
+func (l *ipLimiters) get(ip string) *rate.Limiter {
+	l.mu.Lock()
+	il, ok := l.m[ip]
+	if !ok {
+		il = &ipLimiter{limiter: rate.NewLimiter(rate.Every(1*time.Second), 50)}
+		l.m[ip] = il
+	}
+	il.lastSeen = time.Now()
+	l.mu.Unlock()
+
+	return il.limiter
+}
+
 type httpServer interface {
-	ListenAndServe() error
+	Serve(l net.Listener) error
+	Shutdown(ctx context.Context) error
 }
 
 type httpClient interface {
 	Do(req *http.Request) (*http.Response, error)
 }
 
+type quoteFeed interface {
+	Latest(pair string, maxAge time.Duration) (feed.Quote, bool)
+	Subscribe(pairs ...string)
+}
+
 // Server handles HTTP requests to exchanges
 type Server struct {
+	addr      string
+	reusePort bool
+	ln        net.Listener
+	grpcLn    net.Listener
+	onListen  func()
+	certFile  string
+	keyFile   string
+	autocert  *autocert.Manager
+	h2c       bool
+	apiKeys   *apiKeys
+	watchlist *watchlist.Watchlist
+	jwt       *jwtAuth
+	h3        *http3.Server
 	exchanges []*exchange.Exchange
+	active    atomic.Pointer[[]*exchange.Exchange]
+	activeMu  sync.Mutex
 	listener  httpServer
 	client    httpClient
+	fetchers  map[exchange.Name]Fetcher
+	alerts    *alert.Evaluator
+	indices   *index.Calculator
+	usage     *usage.Tracker
+	scheduler *scheduler.Scheduler
+	events    *bus.Bus
+	feed      quoteFeed
+	feedAge   atomic.Int64
+	health    healthTracker
+	upstream  upstreamLog
+	stats     statsTracker
+	registry  *prometheus.Registry
+	metrics   *metrics
+	inFlight  atomic.Int64
+
+	upstreamLimit     *upstreamLimiter
+	maxRequestTimeout time.Duration
+	maxStaleAge       time.Duration
+	retries           int
+	hedge             time.Duration
+	limits            exchangeLimits
+	mirrors           exchangeMirrors
+	assetInfo         assetCache
+	topPairs          topCache
+	symbols           symbolLists
+	maintenanceBench  time.Duration
+	priorities        exchangePriorities
+	deprecations      map[string]Deprecation
+	corsOrigins       []string
+	pairs             pairPolicy
+	serverLimits      Limits
+	subscribers       atomic.Int64
+	connsOnce         sync.Once
+	conns             *connLimits
+
+	reached     atomic.Bool
+	maintenance atomic.Pointer[Maintenance]
+	draining    atomic.Bool
+	drainDelay  time.Duration
+	streamsOnce sync.Once
+	streamsCtx  context.Context //nolint:containedctx // canceled on shutdown
+	stopStreams context.CancelFunc
+	grpc        atomic.Pointer[grpc.Server]
+
+	dashboard         Dashboard
+	securityTxtExpiry time.Duration
+	web               fs.FS
+	dev               bool
+	assetsOnce        sync.Once
+	webAssets         *webAssets
+	assetsErr         error
+
+	debug        bool
+	debugToken   string
+	clientCAFile string
+
+	basicUser     string
+	basicPassword string
+
+	schemaOnce sync.Once
+	schema     *graphql.Schema
+
+	aggOnce sync.Once
+	agg     *aggregator.Aggregator
+}
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithExchanges calls only the named exchanges instead of all of them
+func WithExchanges(names ...exchange.Name) Option {
+	return func(s *Server) {
+		s.SetExchanges(names...)
+	}
+}
+
+// SetExchanges calls only the named exchanges from now on, all of them when
+// names is empty
+func (s *Server) SetExchanges(names ...exchange.Name) {
+	s.activeMu.Lock()
+	defer s.activeMu.Unlock()
+
+	if len(names) == 0 {
+		s.active.Store(nil)
+		return
+	}
+	s.setActive(names)
+}
+
+// setActive calls only the named exchanges, with activeMu held
+func (s *Server) setActive(names []exchange.Name) {
+	active := slices.DeleteFunc(slices.Clone(s.exchanges), func(ex *exchange.Exchange) bool {
+		return !slices.Contains(names, ex.Name)
+	})
+	s.active.Store(&active)
+}
+
+// activeExchanges returns the exchanges to call
+func (s *Server) activeExchanges() []*exchange.Exchange {
+	if active := s.active.Load(); active != nil {
+		return *active
+	}
+
+	return s.exchanges
+}
+
+// WithExchangeHeaders sends headers with every call to the exchange name,
+// e.g. an API key or another User-Agent
+func WithExchangeHeaders(name exchange.Name, headers map[string]string) Option {
+	return func(s *Server) {
+		for _, ex := range s.exchanges {
+			if ex.Name == name {
+				ex.Headers = headers
+			}
+		}
+	}
+}
+
+// WithExchangeBaseURL calls the exchange name at baseURL instead of its
+// public API and its mirrors, e.g. a fake exchange of tests
+func WithExchangeBaseURL(name exchange.Name, baseURL string) Option {
+	return func(s *Server) {
+		for _, ex := range s.exchanges {
+			if ex.Name == name {
+				ex.BaseURL = strings.TrimSuffix(baseURL, "/")
+				ex.Mirrors = nil
+			}
+		}
+	}
+}
+
+// WithAlerts makes polling fetch quotes from every exchange for pairs
+// with rules comparing exchanges. Rules are evaluated by the evaluator
+// watching the bus.
+func WithAlerts(e *alert.Evaluator) Option {
+	return func(s *Server) {
+		s.alerts = e
+	}
+}
+
+// WithScheduler exposes status of the scheduler jobs at /api/v1/jobs
+func WithScheduler(sc *scheduler.Scheduler) Option {
+	return func(s *Server) {
+		s.scheduler = sc
+	}
+}
+
+// WithFeed serves streamed quotes not older than maxAge before falling back
+// to REST requests, and publishes every streamed quote to the bus
+func WithFeed(f *feed.Feed, maxAge time.Duration) Option {
+	return func(s *Server) {
+		s.feed = f
+		s.SetFeedMaxAge(maxAge)
+		f.OnUpdate(func(q feed.Quote) {
+			s.reached.Store(true)
+			s.events.Publish(bus.PriceUpdated{Pair: q.Pair, Price: q.Price, Source: q.Source, Time: q.Time.UTC()})
+		})
+	}
+}
+
+// SetFeedMaxAge serves streamed quotes not older than maxAge from now on
+func (s *Server) SetFeedMaxAge(maxAge time.Duration) {
+	s.feedAge.Store(int64(maxAge))
+}
+
+// WithBus publishes price events to b instead of a bus of the server's own,
+// so consumers outside the server receive them too
+func WithBus(b *bus.Bus) Option {
+	return func(s *Server) {
+		s.events = b
+	}
+}
+
+// WithMetrics registers server metrics in reg and serves everything
+// registered in it at /metrics
+func WithMetrics(reg *prometheus.Registry) Option {
+	return func(s *Server) {
+		s.registry = reg
+	}
+}
+
+// WithDrainDelay keeps serving requests for d after shutdown starts, failing
+// readiness probes so load balancers stop routing to the server first
+func WithDrainDelay(d time.Duration) Option {
+	return func(s *Server) {
+		s.drainDelay = d
+	}
+}
+
+// WithReusePort listens with SO_REUSEPORT, so a new process can bind the
+// same address and take over new connections while this one drains
+func WithReusePort() Option {
+	return func(s *Server) {
+		s.reusePort = true
+	}
+}
+
+// WithListener serves HTTP on ln instead of listening on the server address,
+// e.g. on a socket passed by systemd socket activation
+func WithListener(ln net.Listener) Option {
+	return func(s *Server) {
+		s.ln = ln
+	}
+}
+
+// WithGRPCListener serves the gRPC API on ln instead of listening on the
+// address passed to StartGRPC
+func WithGRPCListener(ln net.Listener) Option {
+	return func(s *Server) {
+		s.grpcLn = ln
+	}
+}
+
+// WithOnListen calls f once the server accepts HTTP connections
+func WithOnListen(f func()) Option {
+	return func(s *Server) {
+		s.onListen = f
+	}
+}
+
+// WithDebug serves profiles at /debug/pprof and recent exchange traffic at
+// /debug/upstream to requests carrying token, or to requests from localhost
+// when token is empty
+func WithDebug(token string) Option {
+	return func(s *Server) {
+		s.debug = true
+		s.debugToken = token
+	}
 }
 
 // New creates a new server instance
-func New(addr string) *Server {
+func New(addr string, opts ...Option) *Server {
 	exchanges := []*exchange.Exchange{
 		exchange.New(exchange.BINANCE),
 		exchange.New(exchange.BYBIT),
@@ -58,158 +366,277 @@ func New(addr string) *Server {
 	}
 
 	s := &Server{
+		addr:      addr,
 		exchanges: exchanges,
-		listener: &http.Server{
-			Addr:         addr,
-			ReadTimeout:  5 * time.Second,
-			WriteTimeout: 10 * time.Second,
-			IdleTimeout:  120 * time.Second,
-		},
 		client: &http.Client{
-			Timeout: 5 * time.Second,
+			Timeout:   upstreamTimeout,
+			Transport: newTransport(Transport{ForceAttemptHTTP2: true}),
 		},
+		events: bus.New(),
 	}
 
-	http.HandleFunc("/", s.HandleIndex)
-	http.HandleFunc("/api/v1/spot/", s.rateLimit(s.HandleSpot))
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.registry == nil {
+		s.registry = prometheus.NewRegistry()
+	}
+	s.metrics = newMetrics(s.registry)
+	s.publishDebugVars()
+
+	if _, err := s.assets(); err != nil {
+		log.Error("Failed to load web assets: " + err.Error())
+	}
+
+	h := s.handler()
+	s.listener = s.httpServer(h)
+	if s.h3 != nil {
+		s.h3.Handler = h
+		s.h3.IdleTimeout = 120 * time.Second
+	}
 
 	return s
 }
 
 func (s *Server) rateLimit(next http.HandlerFunc) http.HandlerFunc {
-	mu := sync.Mutex{}
-	limiters := make(map[string]*ipLimiter)
+	ips := newIPLimiters()
 
-	go func() {
-		for range time.Tick(5 * time.Minute) {
-			mu.Lock()
-			for ip, l := range limiters {
-				if time.Since(l.lastSeen) > 10*time.Minute {
-					delete(limiters, ip)
-				}
-			}
-			mu.Unlock()
+	return s.trackUsage(func(w http.ResponseWriter, r *http.Request) {
+		if s.serveMaintenance(w) {
+			return
 		}
-	}()
 
-	return func(w http.ResponseWriter, r *http.Request) {
-		ip := r.Header.Get("Cf-Connecting-Ip")
-		if ip == "" {
-			ip, _, _ = net.SplitHostPort(r.RemoteAddr)
+		consumer, limiter, err := s.admit(r, ips)
+		switch {
+		case errors.Is(err, errTooManyRequests):
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		case err != nil:
+			if s.jwt != nil {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+			}
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		case consumer != "":
+			noteConsumer(r.Context(), consumer)
+			r = r.WithContext(context.WithValue(r.Context(), consumerKey{}, consumer))
 		}
 
-		mu.Lock()
-		l, ok := limiters[ip]
-		if !ok {
-			l = &ipLimiter{limiter: rate.NewLimiter(rate.Every(1*time.Second), 50)}
-			limiters[ip] = l
-		}
-		l.lastSeen = time.Now()
-		mu.Unlock()
+		next(w, r.WithContext(context.WithValue(r.Context(), limiterKey{}, limiter)))
+	})
+}
 
-		if !l.limiter.Allow() {
-			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
-			return
+var errTooManyRequests = errors.New("too many requests")
+
+// admit authenticates r and takes a token from the limiter of its API key,
+// or from the limiter of its IP in ips without one. It returns the name of
+// the key, empty for requests with a bearer token and anonymous requests,
+// and the limiter the token was taken from.
+func (s *Server) admit(r *http.Request, ips *ipLimiters) (string, *rate.Limiter, error) {
+	key, err := s.authenticate(r)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if key != nil {
+		allowed := key.limiter.Allow()
+		s.metrics.observeAPIKey(key.name, allowed)
+		if !allowed {
+			return "", nil, errTooManyRequests
 		}
-		next(w, r)
+		return key.name, key.limiter, nil
+	}
+
+	limiter := ips.get(clientIP(r))
+	if !limiter.Allow() {
+		return "", nil, errTooManyRequests
 	}
+
+	return "", limiter, nil
+}
+
+// limiterKey is the context key of the limiter a request was admitted by
+type limiterKey struct{}
+
+// charge takes n more tokens from the limiter the request of ctx was
+// admitted by, for requests resolving several pairs at once. It reports
+// whether they were available, and is true outside of admitted requests.
+func charge(ctx context.Context, n int) bool {
+	l, _ := ctx.Value(limiterKey{}).(*rate.Limiter)
+	return n <= 0 || l == nil || l.AllowN(time.Now(), n)
 }
 
-// Start starts the server
+// Start starts the server. It returns nil once the server is shut down.
 func (s *Server) Start() error {
-	return s.listener.ListenAndServe()
+	ln := s.ln
+	if ln == nil {
+		var err error
+		if ln, err = s.listen(s.addr); err != nil {
+			return fmt.Errorf("listen: %w", err)
+		}
+	}
+	ln = s.limitConns(ln)
+
+	if s.tlsEnabled() {
+		cfg, err := s.tlsConfig()
+		if err != nil {
+			_ = ln.Close()
+			return err
+		}
+		ln = tls.NewListener(ln, cfg)
+	}
+
+	if s.onListen != nil {
+		s.onListen()
+	}
+
+	if err := s.listener.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
 }
 
-// HandleIndex serves the main page
-func (s *Server) HandleIndex(w http.ResponseWriter, r *http.Request) {
-	// Only serve root path
-	if r.URL.Path != "/" {
-		http.NotFound(w, r)
-		return
+// Shutdown fails readiness probes for the drain delay, then ends streams,
+// stops accepting connections and waits for active requests to complete
+// until ctx is done
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.draining.Store(true)
+
+	select {
+	case <-time.After(s.drainDelay):
+	case <-ctx.Done():
 	}
 
-	// Only allow GET method
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+	// Streams last until clients leave, which Shutdown would wait for
+	s.streams()
+	s.stopStreams()
+	s.stopGRPC(ctx)
+
+	if s.h3 != nil {
+		if err := s.h3.Shutdown(ctx); err != nil {
+			log.Error("Failed to shut down HTTP/3 server: " + err.Error())
+		}
 	}
 
-	// Set security headers
+	if err := s.listener.Shutdown(ctx); err != nil {
+		return fmt.Errorf("shutdown server: %w", err)
+	}
+
+	return nil
+}
+
+// streams returns the context canceled when the server shuts down
+func (s *Server) streams() context.Context {
+	s.streamsOnce.Do(func() {
+		s.streamsCtx, s.stopStreams = context.WithCancel(context.Background())
+	})
+
+	return s.streamsCtx
+}
+
+// streamContext returns ctx canceled on shutdown as well, for responses
+// streaming until the client leaves
+func (s *Server) streamContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(s.streams(), cancel)
+
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// HandleIndex serves the main page
+func (s *Server) HandleIndex(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.Header().Set("X-Content-Type-Options", "nosniff")
-	w.Header().Set("X-Frame-Options", "DENY")
-	w.Header().Set("X-XSS-Protection", "1; mode=block")
 
-	// Parse template
-	t, err := template.ParseFiles("web/template/index.html")
+	t, err := s.templates()
 	if err != nil {
 		log.Error("Failed to parse template: " + err.Error())
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Execute template with no data
 	var buf bytes.Buffer
-	if err := t.Execute(&buf, nil); err != nil {
+	if err := t.ExecuteTemplate(&buf, "index.html", s.indexData(r)); err != nil {
 		log.Error("Failed to execute template: " + err.Error())
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	content := buf.Bytes()
-
-	// Enable compression if client supports it
-	if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
-		w.Header().Set("Content-Encoding", "gzip")
-		gzw := gzip.NewWriter(w)
-		defer func() {
-			if err := gzw.Close(); err != nil {
-				log.Error("Failed to close gzip writer: " + err.Error())
-			}
-		}()
-		if _, err := gzw.Write(content); err != nil {
-			log.Error("Failed to write gzipped response: " + err.Error())
-			return
-		}
-		return
-	}
-
-	if _, err := w.Write(content); err != nil {
+	if _, err := buf.WriteTo(w); err != nil {
 		log.Error("Failed to write response: " + err.Error())
 	}
 }
 
 // HandleSpot handles /api/v1/spot/{pair} requests
 func (s *Server) HandleSpot(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	pair := strings.ToUpper(r.PathValue("pair"))
+
+	fields, err := parseFields[DetailedResponse](r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	isDetailed := r.URL.Query().Get("details") == "true" || fields != nil
 
-	pair := strings.TrimPrefix(r.URL.Path, "/api/v1/spot/")
-	if pair == "" {
-		http.Error(w, "Missing trading pair", http.StatusBadRequest)
+	format := r.URL.Query().Get("format")
+	if format != "" && format != formatPretty {
+		http.Error(w, fmt.Sprintf("invalid format %q", format), http.StatusBadRequest)
+		return
+	}
+
+	timeout, err := s.requestTimeout(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	pair = strings.ToUpper(pair)
 
-	isDetailed := r.URL.Query().Get("details") == "true"
+	// With ?quotes the path names the asset rather than the pair
+	if quotes := r.URL.Query().Get("quotes"); quotes != "" {
+		s.handleMultiQuote(w, r, pair, quotes, timeout)
+		return
+	}
 
-	price, source, err := s.firstPriceWithDetails(r.Context(), pair)
+	if s.forbidPair(w, pair) {
+		return
+	}
+
+	e, stale, err := s.quote(r.Context(), pair, timeout)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusServiceUnavailable)
 		return
 	}
+	price, source := e.Price, e.Source
+	if stale {
+		w.Header().Set("Warning", staleWarning)
+	}
+	s.setCacheHeaders(w, e.Time, stale)
 
-	if isDetailed {
+	switch {
+	case format == formatPretty:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if _, err := fmt.Fprintln(w, pretty(e, stale)); err != nil {
+			log.Error("Failed to write response: " + err.Error())
+		}
+	case isDetailed:
 		w.Header().Set("Content-Type", "application/json")
-		response := DetailedResponse{Pair: pair, Price: price, Source: source}
-		if err := json.NewEncoder(w).Encode(response); err != nil {
+		resp := DetailedResponse{Pair: pair, Price: price, Source: source, Stale: stale}
+		if stale {
+			resp.Time = &e.Time
+		}
+		response, err := fields.apply(resp)
+		if err == nil {
+			err = json.NewEncoder(w).Encode(response)
+		}
+		if err != nil {
 			log.Error("Failed to encode response: " + err.Error())
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
-	} else {
+	default:
 		w.Header().Set("Content-Type", "text/plain")
 		if _, err := fmt.Fprintf(w, "%g", price); err != nil {
 			log.Error("Failed to write response: " + err.Error())
@@ -219,190 +646,280 @@ func (s *Server) HandleSpot(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (s *Server) firstPriceWithDetails(ctx context.Context, pair string) (price float64, source string, err error) {
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
+// HandleJobs handles /api/v1/jobs requests
+func (s *Server) HandleJobs(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.scheduler.Status()); err != nil {
+		log.Error("Failed to encode response: " + err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
 
-	type result struct {
-		price  float64
-		source string
-		err    error
+// Spot resolves the price of pair and the exchange it came from, as
+// /api/v1/spot/{pair} does, and publishes it to the bus
+func (s *Server) Spot(ctx context.Context, pair string) (price float64, source string, err error) {
+	pair = strings.ToUpper(pair)
+	if price, source, err = s.price(ctx, pair); err != nil {
+		return 0, "", err
 	}
 
-	type errorResponse struct {
-		Message string   `json:"message"`
-		Errors  []string `json:"errors"`
+	s.resolved(pair, source, price)
+	return price, source, nil
+}
+
+// Poll resolves the price of pair in the background and publishes it to
+// the bus. Pairs with rules comparing exchanges and pairs of indices get
+// quotes from every exchange.
+func (s *Server) Poll(ctx context.Context, pair string) error {
+	price, source, err := s.price(ctx, pair)
+	if err != nil {
+		return err
 	}
 
-	results := make(chan result, len(s.exchanges))
+	s.resolved(pair, source, price)
+	if (s.alerts != nil && s.alerts.WantsAllSources(pair)) || (s.indices != nil && s.indices.WantsAllSources(pair)) {
+		s.pollOthers(ctx, pair, source)
+	}
 
-	for _, ex := range s.exchanges {
-		go func(ex *exchange.Exchange) {
-			p, e := s.fetchPrice(ctx, ex, pair)
-			select {
-			case <-ctx.Done():
-				return
-			case results <- result{p, ex.Name.String(), e}:
-			}
-		}(ex)
+	return nil
+}
+
+// resolved publishes a resolved price to the bus
+func (s *Server) resolved(pair, source string, price float64) bus.PriceUpdated {
+	e := bus.PriceUpdated{Pair: pair, Price: price, Source: source, Time: time.Now().UTC()}
+	s.events.Publish(e)
+	return e
+}
+
+// price returns a fresh streamed quote of pair when available, otherwise the
+// fastest exchange response. Pairs resolved over REST are added to the feed.
+func (s *Server) price(ctx context.Context, pair string) (price float64, source string, err error) {
+	q, err := s.latestQuote(ctx, pair)
+	return q.Price, q.Source, err
+}
+
+// latestQuote is price returning the time of the quote too, when it was
+// streamed or fetched
+func (s *Server) latestQuote(ctx context.Context, pair string) (q aggregator.Quote, err error) {
+	if err := s.checkPair(pair); err != nil {
+		return aggregator.Quote{}, err
 	}
+	notePair(ctx, pair)
 
-	var errors []string
-	for i := 0; i < len(s.exchanges); i++ {
-		r := <-results
-		if r.err != nil {
-			errMsg := fmt.Sprintf("%s: %v", r.source, r.err)
-			log.Error("Error from " + errMsg)
-			errors = append(errors, errMsg)
-			continue
+	ctx = log.WithFields(ctx, log.Fields{"pair": pair})
+	defer func() {
+		if err == nil {
+			noteSource(ctx, q.Source)
 		}
+	}()
 
-		log.Info(fmt.Sprintf("Got price %.2f from %s", r.price, r.source))
-		cancel()
-		return r.price, r.source, nil
+	if s.feed != nil {
+		if fq, ok := s.feed.Latest(pair, time.Duration(s.feedAge.Load())); ok {
+			return aggregator.Quote{Pair: fq.Pair, Price: fq.Price, Source: fq.Source, Time: fq.Time.UTC()}, nil
+		}
 	}
 
-	errResp := errorResponse{
-		Message: "all exchanges failed",
-		Errors:  errors,
+	q, err = s.firstQuote(ctx, pair)
+	if err == nil && s.feed != nil {
+		s.feed.Subscribe(pair)
 	}
 
-	b, err := json.Marshal(errResp)
-	if err != nil {
-		return 0, "", fmt.Errorf("failed to marshal error response: %v", err)
-	}
+	return q, err
+}
 
-	log.Error(string(b))
-	return 0, "", fmt.Errorf("%s", string(b))
+// firstPriceWithDetails resolves the price of pair from the active exchanges,
+// racing them by priority
+func (s *Server) firstPriceWithDetails(ctx context.Context, pair string) (price float64, source string, err error) {
+	q, err := s.firstQuote(ctx, pair)
+	return q.Price, q.Source, err
 }
 
-func (s *Server) fetchPrice(ctx context.Context, e *exchange.Exchange, pair string) (float64, error) {
-	url := e.PriceURL(pair)
-	log.Info(fmt.Sprintf("Requesting %s price for %s: %s", e.Name, pair, url))
+// firstQuote is firstPriceWithDetails returning the quote of the exchange
+func (s *Server) firstQuote(ctx context.Context, pair string) (aggregator.Quote, error) {
+	return s.aggregator().Price(ctx, pair, s.priceOptions(pair))
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody) //nolint:gosec // URL is constructed from static exchange configuration, not user input
-	if err != nil {
-		return 0, fmt.Errorf("create request: %w", err)
-	}
+// aggregator returns the aggregator of all exchanges of s, built on first use
+func (s *Server) aggregator() *aggregator.Aggregator {
+	s.aggOnce.Do(func() {
+		sources := make([]aggregator.Source, 0, len(s.exchanges))
+		for _, ex := range s.exchanges {
+			sources = append(sources, aggregator.Source{
+				Name: ex.Name.String(),
+				Fetch: func(ctx context.Context, pair string) (aggregator.Quote, error) {
+					q, err := s.fetchPrice(ctx, ex, pair)
+					s.health.record(ctx, ex.Name.String(), err)
+					return q, err
+				},
+			})
+		}
+		s.agg = aggregator.New(sources...)
+	})
 
-	resp, err := s.client.Do(req)
-	if err != nil {
-		return 0, fmt.Errorf("do request: %w", err)
+	return s.agg
+}
+
+// priceOptions returns the active exchanges serving pair, their priorities
+// and the retries and hedging of calls to them
+func (s *Server) priceOptions(pair string) aggregator.Options {
+	active := s.activeExchanges()
+	if served := slices.DeleteFunc(slices.Clone(active), func(ex *exchange.Exchange) bool {
+		return !s.serves(ex, pair)
+	}); len(served) > 0 {
+		active = served
+	}
+	opts := aggregator.Options{
+		Sources:    make([]string, 0, len(active)),
+		Priorities: make(map[string]int, len(active)),
+		Retries:    s.retries,
+		Hedge:      s.hedge,
+	}
+	for _, ex := range active {
+		opts.Sources = append(opts.Sources, ex.Name.String())
+		opts.Priorities[ex.Name.String()] = s.priorities.get(ex.Name)
 	}
 
-	defer func() { _ = resp.Body.Close() }()
+	return opts
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return 0, fmt.Errorf("read body: %w", err)
-	}
+// pollOthers publishes quotes of pair from every exchange but source
+func (s *Server) pollOthers(ctx context.Context, pair, source string) {
+	var wg sync.WaitGroup
+	for _, ex := range s.activeExchanges() {
+		if ex.Name.String() == source || !s.serves(ex, pair) {
+			continue
+		}
 
-	if resp.StatusCode != http.StatusOK {
-		switch e.Name {
-		case exchange.BINANCE:
-			var r exchange.BinanceErrorResponse
-			if err := json.Unmarshal(body, &r); err != nil {
-				return 0, fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, body)
+		wg.Add(1)
+		go func(ex *exchange.Exchange) {
+			defer wg.Done()
+			q, err := s.fetchPrice(ctx, ex, pair)
+			s.health.record(ctx, ex.Name.String(), err)
+			if err != nil {
+				log.FromContext(log.WithFields(ctx, log.Fields{"exchange": ex.Name.String()})).Error(fmt.Sprintf("Error from %s: %v", ex.Name, err))
+				return
 			}
 
-			return 0, fmt.Errorf("code=%d, msg=%s", r.Code, r.Msg)
-		case exchange.BYBIT:
-			var r exchange.BybitResponse
-			if err := json.Unmarshal(body, &r); err != nil {
-				return 0, fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, body)
-			}
+			s.resolved(pair, q.Source, q.Price)
+		}(ex)
+	}
+	wg.Wait()
+}
 
-			return 0, fmt.Errorf("code=%d, msg=%s", r.RetCode, r.RetMsg)
-		case exchange.BITGET:
-			var r exchange.BitgetResponse
-			if err := json.Unmarshal(body, &r); err != nil {
-				return 0, fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, body)
-			}
+// fetchPrice returns the quote of pair on e from its fetcher, recording the
+// outcome of the call. Quotes without a source or time get the name of e and
+// the time they were fetched at.
+func (s *Server) fetchPrice(ctx context.Context, e *exchange.Exchange, pair string) (q aggregator.Quote, err error) {
+	s.inFlight.Add(1)
+	defer func(start time.Time) {
+		s.inFlight.Add(-1)
+		d := time.Since(start)
+		s.metrics.observeUpstream(ctx, e.Name.String(), err, d)
+		s.stats.record(ctx, e.Name.String(), err, d)
+		if err == nil {
+			s.reached.Store(true)
+		}
+	}(time.Now())
 
-			return 0, fmt.Errorf("code=%s, msg=%s", r.Code, r.Msg)
-		case exchange.KRAKEN:
-			var r exchange.KrakenResponse
-			if err := json.Unmarshal(body, &r); err != nil {
-				return 0, fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, body)
+	q, err = s.fetcher(e).FetchPrice(ctx, pair)
+	if err != nil {
+		if !errors.Is(err, errMaintenance) && exchange.IsMaintenance(e.Name, err) {
+			bench := s.maintenanceBench
+			if bench <= 0 {
+				bench = defaultMaintenanceBench
 			}
+			s.limits.bench(e, bench)
+			err = maintenanceError{err}
 		}
-
-		return 0, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return aggregator.Quote{}, err
 	}
 
-	resp.Body = io.NopCloser(bytes.NewReader(body))
+	q.Pair = pair
+	q.Source = cmp.Or(q.Source, e.Name.String())
+	if q.Time.IsZero() {
+		q.Time = time.Now().UTC()
+	}
+	return q, nil
+}
 
-	switch e.Name {
-	case exchange.BINANCE:
-		var r exchange.BinanceResponse
-		if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
-			return 0, fmt.Errorf("decode response: %w", err)
-		}
+// fetchURL requests the price of pair from the REST API of e at url, reading
+// it with read. Failed calls worth trying at another mirror are reported by
+// failover.
+func (s *Server) fetchURL(ctx context.Context, e *exchange.Exchange, url, pair string, read priceReader) (price float64, failover bool, err error) {
+	if err = s.limits.wait(ctx, e); err != nil {
+		return 0, false, fmt.Errorf("wait for rate limit: %w", err)
+	}
 
-		price, err := strconv.ParseFloat(r.Price, 64)
+	release, err := s.upstreamLimit.acquire(ctx)
+	if err != nil {
+		return 0, false, fmt.Errorf("wait for upstream slot: %w", err)
+	}
+	defer release()
+
+	var (
+		status int
+		body   []byte // of the upstream log, valid until the call returns
+	)
+	defer func(start time.Time) {
+		call := UpstreamCall{Time: start, Exchange: e.Name.String(), URL: url, Status: status, Body: string(body), DurationMs: float64(time.Since(start).Microseconds()) / 1000}
 		if err != nil {
-			return 0, fmt.Errorf("parse price: %w", err)
+			call.Error = err.Error()
 		}
+		s.upstream.add(call)
+	}(time.Now())
 
-		return price, nil
-	case exchange.BYBIT:
-		var r exchange.BybitResponse
-		if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
-			return 0, fmt.Errorf("decode response: %w", err)
-		}
+	log.FromContext(log.WithFields(ctx, log.Fields{"exchange": e.Name.String()})).Info(fmt.Sprintf("Requesting %s price for %s: %s", e.Name, pair, url))
 
-		if len(r.Result.List) == 0 {
-			return 0, fmt.Errorf("empty response")
-		}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody) //nolint:gosec // URL is constructed from static exchange configuration, not user input
+	if err != nil {
+		return 0, false, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
 
-		price, err := strconv.ParseFloat(r.Result.List[0].LastPrice, 64)
-		if err != nil {
-			return 0, fmt.Errorf("parse price: %w", err)
-		}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, ctx.Err() == nil, fmt.Errorf("do request: %w", err)
+	}
 
-		return price, nil
-	case exchange.BITGET:
-		var r exchange.BitgetResponse
-		if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
-			return 0, fmt.Errorf("decode response: %w", err)
-		}
+	defer func() { _ = resp.Body.Close() }()
 
-		if len(r.Data) == 0 {
-			return 0, fmt.Errorf("empty response")
-		}
+	s.limits.observe(e, resp)
+	status = resp.StatusCode
 
-		price, err := strconv.ParseFloat(r.Data[0].LastPr, 64)
-		if err != nil {
-			return 0, fmt.Errorf("parse price: %w", err)
+	if resp.StatusCode != http.StatusOK {
+		// Error responses are only read for their message, so their bodies
+		// are cut short rather than rejected
+		if body, err = io.ReadAll(io.LimitReader(resp.Body, maxErrorBody)); err != nil {
+			return 0, ctx.Err() == nil, fmt.Errorf("read body: %w", err)
 		}
 
-		return price, nil
-	case exchange.KRAKEN:
-		var r exchange.KrakenResponse
-		if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
-			return 0, fmt.Errorf("decode response: %w", err)
+		statusErr := &exchange.StatusError{StatusCode: resp.StatusCode, Body: string(body)}
+		if apiErr, ok := exchange.ParseError(e.Name, body); ok {
+			statusErr.Err = apiErr
 		}
-
-		if len(r.Error) > 0 {
-			p := strings.SplitN(r.Error[0], ":", 2)
-			code, msg := p[0], ""
-			if len(p) == 2 {
-				msg = strings.TrimSpace(p[1])
-			}
-			return 0, fmt.Errorf("code=%s, msg=%s", code, msg)
+		if rateLimited(resp) {
+			return 0, false, backoffError{statusErr}
 		}
 
-		for _, ticker := range r.Result {
-			price, err := strconv.ParseFloat(ticker.C[0], 64)
-			if err != nil {
-				return 0, fmt.Errorf("parse price: %w", err)
-			}
-
-			return price, nil
-		}
+		return 0, resp.StatusCode >= http.StatusInternalServerError, statusErr
+	}
 
-		return 0, fmt.Errorf("empty response")
+	// Prices are decoded as they are read, keeping the head of the body for
+	// the upstream log
+	br := &bodyReader{r: http.MaxBytesReader(nil, resp.Body, maxResponseBody)}
+	price, err = read(br)
+	body = br.head
+
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(br.err, &tooLarge):
+		return 0, false, fmt.Errorf("response exceeds %d bytes", maxResponseBody)
+	case br.err != nil:
+		return 0, ctx.Err() == nil, fmt.Errorf("read body: %w", br.err)
 	}
 
-	return 0, fmt.Errorf("unknown exchange")
+	return price, false, err
 }
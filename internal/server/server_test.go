@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -15,16 +16,28 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ivanglie/coinmon/internal/alert"
+	"github.com/ivanglie/coinmon/internal/bus"
 	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/ivanglie/coinmon/internal/feed"
+	"github.com/ivanglie/coinmon/internal/scheduler"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 )
 
 type mockHTTPServer struct {
-	listenAndServeFunc func() error
+	serveFunc    func(l net.Listener) error
+	shutdownFunc func(ctx context.Context) error
 }
 
-func (m *mockHTTPServer) ListenAndServe() error {
-	return m.listenAndServeFunc()
+func (m *mockHTTPServer) Serve(l net.Listener) error {
+	defer func() { _ = l.Close() }()
+	return m.serveFunc(l)
+}
+
+func (m *mockHTTPServer) Shutdown(ctx context.Context) error {
+	return m.shutdownFunc(ctx)
 }
 
 type mockHTTPClient struct {
@@ -122,7 +135,7 @@ func mockSuccessfulResponse(req *http.Request) (*http.Response, error) {
 			Result: map[string]struct {
 				C [2]string `json:"c"`
 			}{
-				"USDTZUSD": {
+				req.URL.Query().Get("pair"): {
 					C: [2]string{"99999.96", "1.00"},
 				},
 			},
@@ -134,28 +147,6 @@ func mockSuccessfulResponse(req *http.Request) (*http.Response, error) {
 	}
 }
 
-func mockSuccessfulResponseWithDelay(delays map[string]time.Duration) mockResponseFunc {
-	return func(req *http.Request) (*http.Response, error) {
-		var e string
-		switch {
-		case strings.Contains(req.URL.String(), "binance"):
-			e = "binance"
-		case strings.Contains(req.URL.String(), "bybit"):
-			e = "bybit"
-		case strings.Contains(req.URL.String(), "bitget"):
-			e = "bitget"
-		case strings.Contains(req.URL.String(), "kraken"):
-			e = "kraken"
-		}
-
-		if delay, ok := delays[e]; ok {
-			time.Sleep(delay)
-		}
-
-		return mockSuccessfulResponse(req)
-	}
-}
-
 func mockErrorResponse(req *http.Request) (*http.Response, error) {
 	resp := &http.Response{
 		StatusCode: http.StatusBadRequest,
@@ -193,30 +184,6 @@ func mockErrorResponse(req *http.Request) (*http.Response, error) {
 	}
 }
 
-func mockPairErrorResponse(binanceCode int, binanceMsg string) mockResponseFunc {
-	return func(req *http.Request) (*http.Response, error) {
-		resp := &http.Response{StatusCode: http.StatusBadRequest}
-		switch {
-		case strings.Contains(req.URL.String(), "binance"):
-			return mockJSONResponse(resp, exchange.BinanceErrorResponse{Code: binanceCode, Msg: binanceMsg})
-		case strings.Contains(req.URL.String(), "bybit"):
-			return mockJSONResponse(resp, exchange.BybitResponse{RetCode: 10001, RetMsg: "Not supported symbols"})
-		case strings.Contains(req.URL.String(), "bitget"):
-			return mockJSONResponse(resp, exchange.BitgetResponse{Code: "40034", Msg: "Parameter does not exist"})
-		case strings.Contains(req.URL.String(), "kraken"):
-			resp.StatusCode = http.StatusOK
-			return mockJSONResponse(resp, exchange.KrakenResponse{Error: []string{"EQuery:Unknown asset pair"}})
-		default:
-			return nil, fmt.Errorf("unknown exchange in URL: %s", req.URL.String())
-		}
-	}
-}
-
-var (
-	mockInvalidPairResponse = mockPairErrorResponse(-1100, "Illegal characters found in parameter 'symbol'; legal range is '^[A-Z0-9_.]{1,20}$'.")
-	mockEmptyPairResponse   = mockPairErrorResponse(-1105, "Parameter 'symbol' was empty.")
-)
-
 func mockJSONResponse(resp *http.Response, data interface{}) (*http.Response, error) {
 	jsonResponse, err := json.Marshal(data)
 	if err != nil {
@@ -230,6 +197,7 @@ func mockJSONResponse(resp *http.Response, data interface{}) (*http.Response, er
 func TestServer_Start(t *testing.T) {
 	tests := []struct {
 		name        string
+		addr        string
 		serverError error
 		expectError bool
 	}{
@@ -238,18 +206,34 @@ func TestServer_Start(t *testing.T) {
 			serverError: nil,
 			expectError: false,
 		},
+		{
+			name:        "server is shut down",
+			serverError: http.ErrServerClosed,
+			expectError: false,
+		},
 		{
 			name:        "server fails to start",
 			serverError: fmt.Errorf("failed to start server"),
 			expectError: true,
 		},
+		{
+			name:        "invalid address",
+			addr:        "127.0.0.1:-1",
+			expectError: true,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			addr := tt.addr
+			if addr == "" {
+				addr = "127.0.0.1:0"
+			}
+
 			s := &Server{
+				addr: addr,
 				listener: &mockHTTPServer{
-					listenAndServeFunc: func() error {
+					serveFunc: func(net.Listener) error {
 						return tt.serverError
 					},
 				},
@@ -258,14 +242,125 @@ func TestServer_Start(t *testing.T) {
 			err := s.Start()
 			if tt.expectError {
 				assert.Error(t, err)
-				assert.Equal(t, tt.serverError.Error(), err.Error())
+				if tt.serverError != nil {
+					assert.Equal(t, tt.serverError.Error(), err.Error())
+				}
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestServer_Start_listener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	var served net.Listener
+	listening := false
+	s := &Server{
+		addr: "127.0.0.1:-1",
+		listener: &mockHTTPServer{
+			serveFunc: func(l net.Listener) error {
+				assert.True(t, listening, "notified before serving")
+				served = l
+				return http.ErrServerClosed
+			},
+		},
+	}
+	WithListener(ln)(s)
+	WithOnListen(func() { listening = true })(s)
+
+	assert.NoError(t, s.Start())
+	assert.Equal(t, ln, served)
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	tests := []struct {
+		name        string
+		drainDelay  time.Duration
+		shutdownErr error
+		expectError bool
+	}{
+		{
+			name: "shutdown",
+		},
+		{
+			name:       "drain delay",
+			drainDelay: 20 * time.Millisecond,
+		},
+		{
+			name:        "shutdown fails",
+			shutdownErr: context.DeadlineExceeded,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var shutdownAt time.Time
+			s := &Server{
+				listener: &mockHTTPServer{
+					shutdownFunc: func(context.Context) error {
+						shutdownAt = time.Now()
+						return tt.shutdownErr
+					},
+				},
+			}
+			WithDrainDelay(tt.drainDelay)(s)
+
+			start := time.Now()
+			err := s.Shutdown(context.Background())
+			if tt.expectError {
+				assert.ErrorIs(t, err, tt.shutdownErr)
 			} else {
 				assert.NoError(t, err)
 			}
+			assert.True(t, s.draining.Load())
+			assert.GreaterOrEqual(t, shutdownAt.Sub(start), tt.drainDelay)
 		})
 	}
 }
 
+func TestServer_Shutdown_streams(t *testing.T) {
+	s := &Server{
+		events:    bus.New(),
+		exchanges: exchanges[:1],
+		fetchers:  mockFetchers(),
+	}
+	s.listener = s.httpServer(s.routes())
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go func() { _ = s.listener.Serve(ln) }()
+
+	stream, err := http.Get("http://" + ln.Addr().String() + "/api/v1/stream/BTCUSDT")
+	assert.NoError(t, err)
+	defer func() { _ = stream.Body.Close() }()
+
+	next := make(chan int, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/api/v1/spot/BTCUSDT/next?since=" + time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+		if assert.NoError(t, err) {
+			_ = resp.Body.Close()
+			next <- resp.StatusCode
+		}
+	}()
+	assert.Eventually(t, func() bool { return s.subscribers.Load() == 1 && s.events.Subscribers("BTCUSDT") == 2 }, time.Second, 5*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	assert.NoError(t, s.Shutdown(ctx))
+	assert.Less(t, time.Since(start), time.Second)
+
+	// Streams end instead of holding the shutdown until its deadline
+	_, err = io.ReadAll(stream.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, <-next)
+}
+
 func TestServer_HandleIndex(t *testing.T) {
 	tmpDir := t.TempDir()
 	templateDir := filepath.Join(tmpDir, "web", "template")
@@ -287,11 +382,6 @@ func TestServer_HandleIndex(t *testing.T) {
 	err = os.WriteFile(filepath.Join(templateDir, "index.html"), []byte(indexHTML), 0o600)
 	assert.NoError(t, err)
 
-	oldWd, err := os.Getwd()
-	assert.NoError(t, err)
-	defer func() { _ = os.Chdir(oldWd) }()
-	assert.NoError(t, os.Chdir(tmpDir))
-
 	tests := []struct {
 		name           string
 		method         string
@@ -357,13 +447,13 @@ func TestServer_HandleIndex(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := &Server{
+				events:    bus.New(),
 				exchanges: exchanges,
+				web:       os.DirFS(filepath.Join(tmpDir, "web")),
 				listener: &mockHTTPServer{
-					listenAndServeFunc: func() error { return nil },
-				},
-				client: &mockHTTPClient{
-					doFunc: mockSuccessfulResponse,
+					serveFunc: func(net.Listener) error { return nil },
 				},
+				fetchers: mockFetchers(),
 			}
 
 			req := httptest.NewRequest(tt.method, tt.path, http.NoBody)
@@ -372,7 +462,7 @@ func TestServer_HandleIndex(t *testing.T) {
 			}
 
 			w := httptest.NewRecorder()
-			s.HandleIndex(w, req)
+			s.handler().ServeHTTP(w, req)
 
 			assert.Equal(t, tt.expectedStatus, w.Code)
 
@@ -405,24 +495,65 @@ func TestServer_HandleIndex(t *testing.T) {
 	}
 }
 
+func TestServer_HandleIndex_Embedded(t *testing.T) {
+	s := &Server{events: bus.New(), exchanges: exchanges}
+
+	w := httptest.NewRecorder()
+	s.routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "/api/v1/spot/")
+	// Prices of the examples update from the WebSocket API
+	assert.Contains(t, w.Body.String(), `data-pair="BTCUSDT"`)
+	assert.Regexp(t, `src="/static/dashboard\.[0-9a-f]{8}\.js"`, w.Body.String())
+}
+
+func TestServer_HandleIndex_Dev(t *testing.T) {
+	for _, dev := range []bool{false, true} {
+		t.Run(fmt.Sprintf("dev=%t", dev), func(t *testing.T) {
+			tmpDir := t.TempDir()
+			templateDir := filepath.Join(tmpDir, "web", "template")
+			assert.NoError(t, os.MkdirAll(templateDir, 0o750))
+			assert.NoError(t, os.WriteFile(filepath.Join(templateDir, "index.html"), []byte("before"), 0o600))
+
+			s := &Server{events: bus.New(), exchanges: exchanges, web: os.DirFS(filepath.Join(tmpDir, "web")), dev: dev}
+
+			w := httptest.NewRecorder()
+			s.routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+			assert.Equal(t, "before", w.Body.String())
+
+			assert.NoError(t, os.WriteFile(filepath.Join(templateDir, "index.html"), []byte("after"), 0o600))
+
+			// Templates are parsed once unless in dev mode
+			want := "before"
+			if dev {
+				want = "after"
+			}
+			w = httptest.NewRecorder()
+			s.routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+			assert.Equal(t, want, w.Body.String())
+		})
+	}
+}
+
 func TestServer_HandleIndex_TemplateNotFound(t *testing.T) {
 	s := &Server{
+		events:    bus.New(),
 		exchanges: exchanges,
+		web:       os.DirFS(t.TempDir()),
 		listener: &mockHTTPServer{
-			listenAndServeFunc: func() error { return nil },
-		},
-		client: &mockHTTPClient{
-			doFunc: mockSuccessfulResponse,
+			serveFunc: func(net.Listener) error { return nil },
 		},
+		fetchers: mockFetchers(),
 	}
 
 	req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
 	w := httptest.NewRecorder()
 
-	s.HandleIndex(w, req)
+	s.handler().ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
-	assert.Contains(t, w.Body.String(), "no such file or directory")
+	assert.Contains(t, w.Body.String(), "pattern matches no files")
 }
 
 func TestServer_HandleIndex_TemplateFail(t *testing.T) {
@@ -453,25 +584,20 @@ func TestServer_HandleIndex_TemplateFail(t *testing.T) {
 			err = os.WriteFile(filepath.Join(templateDir, "index.html"), []byte(tt.html), 0o600)
 			assert.NoError(t, err)
 
-			oldWd, err := os.Getwd()
-			assert.NoError(t, err)
-			defer func() { _ = os.Chdir(oldWd) }()
-			assert.NoError(t, os.Chdir(tmpDir))
-
 			s := &Server{
+				events:    bus.New(),
 				exchanges: exchanges,
+				web:       os.DirFS(filepath.Join(tmpDir, "web")),
 				listener: &mockHTTPServer{
-					listenAndServeFunc: func() error { return nil },
-				},
-				client: &mockHTTPClient{
-					doFunc: mockSuccessfulResponse,
+					serveFunc: func(net.Listener) error { return nil },
 				},
+				fetchers: mockFetchers(),
 			}
 
 			req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
 			w := httptest.NewRecorder()
 
-			s.HandleIndex(w, req)
+			s.handler().ServeHTTP(w, req)
 
 			assert.Equal(t, http.StatusInternalServerError, w.Code)
 			assert.Contains(t, w.Body.String(), tt.expectedBody)
@@ -494,11 +620,6 @@ func TestServer_HandleIndex_GzipCompression(t *testing.T) {
 	err = os.WriteFile(filepath.Join(templateDir, "index.html"), []byte(largeHTML), 0o600)
 	assert.NoError(t, err)
 
-	oldWd, err := os.Getwd()
-	assert.NoError(t, err)
-	defer func() { _ = os.Chdir(oldWd) }()
-	assert.NoError(t, os.Chdir(tmpDir))
-
 	tests := []struct {
 		name           string
 		acceptEncoding string
@@ -524,13 +645,13 @@ func TestServer_HandleIndex_GzipCompression(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := &Server{
+				events:    bus.New(),
 				exchanges: exchanges,
+				web:       os.DirFS(filepath.Join(tmpDir, "web")),
 				listener: &mockHTTPServer{
-					listenAndServeFunc: func() error { return nil },
-				},
-				client: &mockHTTPClient{
-					doFunc: mockSuccessfulResponse,
+					serveFunc: func(net.Listener) error { return nil },
 				},
+				fetchers: mockFetchers(),
 			}
 
 			req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
@@ -539,7 +660,7 @@ func TestServer_HandleIndex_GzipCompression(t *testing.T) {
 			}
 
 			w := httptest.NewRecorder()
-			s.HandleIndex(w, req)
+			s.handler().ServeHTTP(w, req)
 
 			assert.Equal(t, http.StatusOK, w.Code)
 
@@ -566,7 +687,7 @@ func TestServer_HandleSpot(t *testing.T) {
 		name             string
 		method           string
 		path             string
-		mockResponse     mockResponseFunc
+		fetchers         map[exchange.Name]Fetcher
 		expectedStatus   int
 		expectedResponse string
 		expectedContains bool
@@ -575,7 +696,7 @@ func TestServer_HandleSpot(t *testing.T) {
 			name:   "successful price request",
 			method: http.MethodGet,
 			path:   "/api/v1/spot/BTCUSDT",
-			mockResponse: mockSuccessfulResponseWithDelay(map[string]time.Duration{
+			fetchers: mockDelayedFetchers(map[string]time.Duration{
 				"binance": 50 * time.Millisecond,
 				"bybit":   100 * time.Millisecond,
 				"bitget":  150 * time.Millisecond,
@@ -589,7 +710,7 @@ func TestServer_HandleSpot(t *testing.T) {
 			name:   "successful detailed request",
 			method: http.MethodGet,
 			path:   "/api/v1/spot/BTCUSDT?details=true",
-			mockResponse: mockSuccessfulResponseWithDelay(map[string]time.Duration{
+			fetchers: mockDelayedFetchers(map[string]time.Duration{
 				"binance": 50 * time.Millisecond,
 				"bybit":   100 * time.Millisecond,
 				"bitget":  150 * time.Millisecond,
@@ -603,25 +724,25 @@ func TestServer_HandleSpot(t *testing.T) {
 			name:             "method not allowed",
 			method:           http.MethodPost,
 			path:             "/api/v1/spot/BTCUSDT",
-			mockResponse:     mockSuccessfulResponse,
+			fetchers:         mockFetchers(),
 			expectedStatus:   http.StatusMethodNotAllowed,
-			expectedResponse: "Method not allowed\n",
+			expectedResponse: "Method Not Allowed\n",
 			expectedContains: false,
 		},
 		{
 			name:             "missing pair",
 			method:           http.MethodGet,
 			path:             "/api/v1/spot/",
-			mockResponse:     mockSuccessfulResponse,
-			expectedStatus:   http.StatusBadRequest,
-			expectedResponse: "Missing trading pair\n",
+			fetchers:         mockFetchers(),
+			expectedStatus:   http.StatusNotFound,
+			expectedResponse: "404 page not found\n",
 			expectedContains: false,
 		},
 		{
 			name:             "invalid pair",
 			method:           http.MethodGet,
 			path:             "/api/v1/spot/INVALID",
-			mockResponse:     mockInvalidPairResponse,
+			fetchers:         mockInvalidPairFetchers(),
 			expectedStatus:   http.StatusServiceUnavailable,
 			expectedResponse: "all exchanges failed",
 			expectedContains: true,
@@ -631,16 +752,15 @@ func TestServer_HandleSpot(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := &Server{
+				events:    bus.New(),
 				exchanges: exchanges,
-				client: &mockHTTPClient{
-					doFunc: tt.mockResponse,
-				},
+				fetchers:  tt.fetchers,
 			}
 
 			req := httptest.NewRequest(tt.method, tt.path, http.NoBody)
 			w := httptest.NewRecorder()
 
-			s.HandleSpot(w, req)
+			s.routes().ServeHTTP(w, req)
 
 			assert.Equal(t, tt.expectedStatus, w.Code)
 
@@ -662,7 +782,7 @@ func TestServer_firstPriceWithDetails(t *testing.T) {
 	tests := []struct {
 		name           string
 		pair           string
-		mockResponse   mockResponseFunc
+		fetchers       map[exchange.Name]Fetcher
 		expectedPrice  float64
 		expectedSource string
 		expectError    bool
@@ -671,7 +791,7 @@ func TestServer_firstPriceWithDetails(t *testing.T) {
 		{
 			name: "successful response from first exchange",
 			pair: "BTCUSDT",
-			mockResponse: mockSuccessfulResponseWithDelay(map[string]time.Duration{
+			fetchers: mockDelayedFetchers(map[string]time.Duration{
 				"binance": 50 * time.Millisecond,
 				"bybit":   100 * time.Millisecond,
 				"bitget":  150 * time.Millisecond,
@@ -682,10 +802,10 @@ func TestServer_firstPriceWithDetails(t *testing.T) {
 			expectError:    false,
 		},
 		{
-			name:         "all exchanges fail",
-			pair:         "INVALID",
-			mockResponse: mockInvalidPairResponse,
-			expectError:  true,
+			name:        "all exchanges fail",
+			pair:        "INVALID",
+			fetchers:    mockInvalidPairFetchers(),
+			expectError: true,
 			expectedErrors: []string{
 				"bitget: code=40034, msg=Parameter does not exist",
 				"bybit: code=10001, msg=Not supported symbols",
@@ -694,10 +814,10 @@ func TestServer_firstPriceWithDetails(t *testing.T) {
 			},
 		},
 		{
-			name:         "empty pair",
-			pair:         "",
-			mockResponse: mockEmptyPairResponse,
-			expectError:  true,
+			name:        "empty pair",
+			pair:        "",
+			fetchers:    mockEmptyPairFetchers(),
+			expectError: true,
 			expectedErrors: []string{
 				"binance: code=-1105, msg=Parameter 'symbol' was empty.",
 				"bybit: code=10001, msg=Not supported symbols",
@@ -710,10 +830,9 @@ func TestServer_firstPriceWithDetails(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := &Server{
+				events:    bus.New(),
 				exchanges: exchanges,
-				client: &mockHTTPClient{
-					doFunc: tt.mockResponse,
-				},
+				fetchers:  tt.fetchers,
 			}
 
 			price, source, err := s.firstPriceWithDetails(context.Background(), tt.pair)
@@ -776,134 +895,321 @@ func TestServer_RateLimit_DifferentIPs(t *testing.T) {
 	}
 }
 
-func TestServer_fetchPrice(t *testing.T) {
-	setupTest()
-	defer teardownTest()
+type mockNotifier struct {
+	triggered chan alert.Event
+}
+
+func (m *mockNotifier) Trigger(_ context.Context, e alert.Event) error {
+	m.triggered <- e
+	return nil
+}
+
+func (m *mockNotifier) Resolve(_ context.Context, _ alert.Event) error {
+	return nil
+}
+
+func TestServer_HandleSpot_Events(t *testing.T) {
+	s := &Server{
+		events:    bus.New(),
+		exchanges: exchanges[:1],
+		fetchers:  mockFetchers(),
+	}
+	events, unsubscribe := s.events.Subscribe("BTCUSDT")
+	defer unsubscribe()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/spot/btcusdt", http.NoBody)
+	w := httptest.NewRecorder()
+	s.routes().ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	select {
+	case e := <-events:
+		assert.Equal(t, "BTCUSDT", e.Pair)
+		assert.Equal(t, 99999.99, e.Price)
+		assert.Equal(t, "binance", e.Source)
+	default:
+		t.Fatal("price event was not published")
+	}
+}
+
+func TestServer_Poll_Spread(t *testing.T) {
+	n := &mockNotifier{triggered: make(chan alert.Event, 1)}
+	// Only the widest spread, between Kraken and Binance, breaches the rule
+	// whatever order the quotes arrive in
+	evaluator := alert.NewEvaluator([]alert.Rule{{ID: "btc-spread", Pair: "BTCUSDT", Spread: 0.000025}}, n)
+	s := &Server{
+		events:    bus.New(),
+		exchanges: exchanges,
+		fetchers:  mockFetchers(),
+		alerts:    evaluator,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	evaluator.Watch(ctx, s.events)
+
+	assert.NoError(t, s.Poll(ctx, "BTCUSDT"))
+	assert.Len(t, s.events.History("BTCUSDT", 10), len(exchanges))
+
+	select {
+	case e := <-n.triggered:
+		assert.Equal(t, "btc-spread", e.Rule.ID)
+		assert.Equal(t, "BTCUSDT spread 0.00% between kraken (99999.96) and binance (99999.99)", e.Summary)
+	case <-time.After(time.Second):
+		t.Fatal("spread alert was not triggered")
+	}
+}
+
+func TestServer_HandleJobs(t *testing.T) {
+	sc := scheduler.New()
+	assert.NoError(t, sc.Add(scheduler.Job{Name: "poll", Interval: time.Minute, Run: func(context.Context) error { return nil }}))
+	s := &Server{scheduler: sc}
 
 	tests := []struct {
-		name          string
-		exchange      *exchange.Exchange
-		pair          string
-		mockResponse  mockResponseFunc
-		expectedPrice float64
-		expectError   bool
+		name           string
+		method         string
+		expectedStatus int
+		expectedBody   string
 	}{
 		{
-			name:          "binance success",
-			exchange:      exchanges[0],
-			pair:          "BTCUSDT",
-			mockResponse:  mockSuccessfulResponse,
-			expectedPrice: 99999.99,
-		},
-		{
-			name:          "bybit success",
-			exchange:      exchanges[1],
-			pair:          "BTCUSDT",
-			mockResponse:  mockSuccessfulResponse,
-			expectedPrice: 99999.98,
-		},
-		{
-			name:          "bitget success",
-			exchange:      exchanges[2],
-			pair:          "BTCUSDT",
-			mockResponse:  mockSuccessfulResponse,
-			expectedPrice: 99999.97,
-		},
-		{
-			name:         "binance error",
-			exchange:     exchanges[0],
-			pair:         "BTCUSDT",
-			mockResponse: mockErrorResponse,
-			expectError:  true,
-		},
-		{
-			name:         "bybit error",
-			exchange:     exchanges[1],
-			pair:         "BTCUSDT",
-			mockResponse: mockErrorResponse,
-			expectError:  true,
+			name:           "list jobs",
+			method:         http.MethodGet,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"name":"poll","interval":"1m0s"`,
 		},
 		{
-			name:         "bitget error",
-			exchange:     exchanges[2],
-			pair:         "BTCUSDT",
-			mockResponse: mockErrorResponse,
-			expectError:  true,
+			name:           "method not allowed",
+			method:         http.MethodPost,
+			expectedStatus: http.StatusMethodNotAllowed,
+			expectedBody:   "Method Not Allowed",
 		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/v1/jobs", http.NoBody)
+			w := httptest.NewRecorder()
+
+			s.routes().ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+func TestServer_Spot(t *testing.T) {
+	s := &Server{
+		events:    bus.New(),
+		exchanges: exchanges,
+		fetchers:  mockFetchers(),
+	}
+
+	price, source, err := s.Spot(context.Background(), "btcusdt")
+	assert.NoError(t, err)
+	e, ok := s.events.Latest("BTCUSDT")
+	assert.True(t, ok)
+	assert.Equal(t, price, e.Price)
+	assert.Equal(t, source, e.Source)
+
+	// Exchanges still answering the first call read the fetchers of s
+	s = &Server{events: bus.New(), exchanges: exchanges, fetchers: mockInvalidPairFetchers()}
+	_, _, err = s.Spot(context.Background(), "INVALID")
+	assert.Error(t, err)
+}
+
+func TestServer_Poll(t *testing.T) {
+	tests := []struct {
+		name           string
+		fetchers       map[exchange.Name]Fetcher
+		expectedEvents int
+		expectError    bool
+	}{
 		{
-			name:         "binance invalid pair",
-			exchange:     exchanges[0],
-			pair:         "INVALID",
-			mockResponse: mockInvalidPairResponse,
-			expectError:  true,
+			name:           "success publishes price",
+			fetchers:       mockFetchers(),
+			expectedEvents: 1,
 		},
 		{
-			name:         "bybit invalid pair",
-			exchange:     exchanges[1],
-			pair:         "INVALID",
-			mockResponse: mockInvalidPairResponse,
-			expectError:  true,
+			name:        "all exchanges fail",
+			fetchers:    mockInvalidPairFetchers(),
+			expectError: true,
 		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &mockNotifier{triggered: make(chan alert.Event, 1)}
+			s := &Server{
+				events:    bus.New(),
+				exchanges: exchanges,
+				fetchers:  tt.fetchers,
+				alerts:    alert.NewEvaluator([]alert.Rule{{ID: "btc", Pair: "BTCUSDT", Above: 1}}, n),
+			}
+
+			err := s.Poll(context.Background(), "BTCUSDT")
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Len(t, s.events.History("BTCUSDT", 10), tt.expectedEvents)
+		})
+	}
+}
+
+type mockFeed struct {
+	quotes     map[string]feed.Quote
+	subscribed []string
+}
+
+func (m *mockFeed) Latest(pair string, _ time.Duration) (feed.Quote, bool) {
+	q, ok := m.quotes[pair]
+	return q, ok
+}
+
+func (m *mockFeed) Subscribe(pairs ...string) {
+	m.subscribed = append(m.subscribed, pairs...)
+}
+
+func TestServer_price_Feed(t *testing.T) {
+	tests := []struct {
+		name               string
+		pair               string
+		fetchers           map[exchange.Name]Fetcher
+		expectedPrice      float64
+		expectedSource     string
+		expectedSubscribed []string
+		expectError        bool
+	}{
 		{
-			name:         "bitget invalid pair",
-			exchange:     exchanges[2],
-			pair:         "INVALID",
-			mockResponse: mockInvalidPairResponse,
-			expectError:  true,
+			name:           "streamed quote",
+			pair:           "BTCUSDT",
+			fetchers:       mockFetchers(),
+			expectedPrice:  100000,
+			expectedSource: "bybit",
 		},
 		{
-			name:         "binance empty pair",
-			exchange:     exchanges[0],
-			pair:         "",
-			mockResponse: mockEmptyPairResponse,
-			expectError:  true,
+			name:               "fallback to rest subscribes pair",
+			pair:               "ETHUSDT",
+			fetchers:           mockFetchers(),
+			expectedPrice:      99999.99,
+			expectedSource:     "binance",
+			expectedSubscribed: []string{"ETHUSDT"},
 		},
 		{
-			name:         "bybit empty pair",
-			exchange:     exchanges[1],
-			pair:         "",
-			mockResponse: mockEmptyPairResponse,
-			expectError:  true,
+			name:        "invalid pair is not subscribed",
+			pair:        "INVALID",
+			fetchers:    mockInvalidPairFetchers(),
+			expectError: true,
 		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &mockFeed{quotes: map[string]feed.Quote{
+				"BTCUSDT": {Pair: "BTCUSDT", Price: 100000, Source: "bybit", Time: time.Now()},
+			}}
+			s := &Server{
+				events:    bus.New(),
+				exchanges: exchanges[:1],
+				fetchers:  tt.fetchers,
+				feed:      f,
+			}
+
+			price, source, err := s.price(context.Background(), tt.pair)
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedPrice, price)
+				assert.Equal(t, tt.expectedSource, source)
+			}
+			assert.Equal(t, tt.expectedSubscribed, f.subscribed)
+		})
+	}
+}
+
+func TestWithFeed(t *testing.T) {
+	f := feed.New(nil)
+	s := &Server{}
+	WithFeed(f, time.Second)(s)
+
+	assert.Equal(t, int64(time.Second), s.feedAge.Load())
+	assert.NotNil(t, s.feed)
+}
+
+func TestWithExchanges(t *testing.T) {
+	s := &Server{exchanges: []*exchange.Exchange{
+		exchange.New(exchange.BINANCE),
+		exchange.New(exchange.BYBIT),
+		exchange.New(exchange.KRAKEN),
+	}}
+	activeNames := func() []exchange.Name {
+		var names []exchange.Name
+		for _, ex := range s.activeExchanges() {
+			names = append(names, ex.Name)
+		}
+		return names
+	}
+
+	WithExchanges(exchange.KRAKEN, exchange.BINANCE)(s)
+	assert.Equal(t, []exchange.Name{exchange.BINANCE, exchange.KRAKEN}, activeNames())
+	assert.Len(t, s.exchanges, 3)
+
+	s.SetExchanges(exchange.BYBIT)
+	assert.Equal(t, []exchange.Name{exchange.BYBIT}, activeNames())
+
+	s.SetExchanges()
+	assert.Equal(t, []exchange.Name{exchange.BINANCE, exchange.BYBIT, exchange.KRAKEN}, activeNames())
+}
+
+func TestServer_fetchPrice_Metrics(t *testing.T) {
+	tests := []struct {
+		name            string
+		fetchers        map[exchange.Name]Fetcher
+		cancel          bool
+		expectedOutcome string
+	}{
 		{
-			name:          "kraken success",
-			exchange:      exchanges[3],
-			pair:          "USDTUSD",
-			mockResponse:  mockSuccessfulResponse,
-			expectedPrice: 99999.96,
+			name:            "success",
+			fetchers:        mockFetchers(),
+			expectedOutcome: "success",
 		},
 		{
-			name:         "kraken error",
-			exchange:     exchanges[3],
-			pair:         "USDTUSD",
-			mockResponse: mockErrorResponse,
-			expectError:  true,
+			name:            "error",
+			fetchers:        mockErrorFetchers(),
+			expectedOutcome: "error",
 		},
 		{
-			name:         "kraken invalid pair",
-			exchange:     exchanges[3],
-			pair:         "INVALID",
-			mockResponse: mockInvalidPairResponse,
-			expectError:  true,
+			name:            "canceled",
+			fetchers:        mockDelayedFetchers(map[string]time.Duration{"binance": time.Hour}),
+			cancel:          true,
+			expectedOutcome: "canceled",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			ctx := context.Background()
-
-			server.client = &mockHTTPClient{
-				doFunc: tt.mockResponse,
+			reg := prometheus.NewRegistry()
+			s := &Server{
+				fetchers: tt.fetchers,
+				metrics:  newMetrics(reg),
 			}
 
-			price, err := server.fetchPrice(ctx, tt.exchange, tt.pair)
-			if tt.expectError {
-				assert.Error(t, err)
-				return
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.cancel {
+				cancel()
 			}
+			defer cancel()
 
-			assert.NoError(t, err)
-			assert.Equal(t, tt.expectedPrice, price)
+			_, _ = s.fetchPrice(ctx, exchanges[0], "BTCUSDT")
+
+			// Looking up the recorded series must not add another one
+			assert.Equal(t, 1, testutil.CollectAndCount(s.metrics.upstream))
+			s.metrics.upstream.WithLabelValues("binance", tt.expectedOutcome)
+			assert.Equal(t, 1, testutil.CollectAndCount(s.metrics.upstream))
 		})
 	}
 }
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ivanglie/coinmon/pkg/log"
+)
+
+// staticFile represents a static asset held in memory
+type staticFile struct {
+	name string
+	hash string
+	body []byte
+}
+
+// staticFiles holds the static assets by name, e.g. dashboard.css, and by
+// name fingerprinted with the hash of their content, e.g.
+// dashboard.3f2a9c1b.css, so fingerprinted URLs can be cached forever
+type staticFiles struct {
+	byName        map[string]*staticFile
+	byFingerprint map[string]*staticFile
+}
+
+// loadStatic reads the static assets under static/ of fsys, none when the
+// directory is missing
+func loadStatic(fsys fs.FS) (*staticFiles, error) {
+	sf := &staticFiles{byName: make(map[string]*staticFile), byFingerprint: make(map[string]*staticFile)}
+
+	err := fs.WalkDir(fsys, "static", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		body, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(body)
+		f := &staticFile{name: strings.TrimPrefix(p, "static/"), hash: hex.EncodeToString(sum[:4]), body: body}
+		sf.byName[f.name] = f
+		sf.byFingerprint[f.fingerprinted()] = f
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	return sf, nil
+}
+
+// fingerprinted returns the name with the hash before the extension
+func (f *staticFile) fingerprinted() string {
+	ext := path.Ext(f.name)
+	return strings.TrimSuffix(f.name, ext) + "." + f.hash + ext
+}
+
+// url returns the fingerprinted URL of the asset name for templates, the
+// plain one for unknown assets
+func (sf *staticFiles) url(name string) string {
+	if f, ok := sf.byName[name]; ok {
+		return "/static/" + f.fingerprinted()
+	}
+
+	return "/static/" + name
+}
+
+// HandleStatic serves the static assets at /static/. Fingerprinted names
+// are cached for a year, plain names are revalidated on every use.
+func (s *Server) HandleStatic(w http.ResponseWriter, r *http.Request) {
+	a, err := s.assets()
+	if err != nil {
+		log.Error("Failed to load static assets: " + err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	name := r.PathValue("name")
+	cacheControl := "public, max-age=31536000, immutable"
+	f, ok := a.static.byFingerprint[name]
+	if !ok {
+		cacheControl = "no-cache"
+		if f, ok = a.static.byName[name]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	serveStaticFile(w, r, f, cacheControl)
+}
+
+// HandleWellKnown returns a handler serving the static asset name at a path
+// clients look for by convention, e.g. favicon.ico at /favicon.ico
+func (s *Server) HandleWellKnown(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a, err := s.assets()
+		if err != nil {
+			log.Error("Failed to load static assets: " + err.Error())
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		f, ok := a.static.byName[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		serveStaticFile(w, r, f, "public, max-age=86400")
+	}
+}
+
+// defaultSecurityTxtExpiry is how long security.txt is valid from the time it
+// is served
+const defaultSecurityTxtExpiry = 365 * 24 * time.Hour
+
+// WithSecurityTxtExpiry serves security.txt valid for d from the time it is
+// served, a year by default
+func WithSecurityTxtExpiry(d time.Duration) Option {
+	return func(s *Server) {
+		s.securityTxtExpiry = d
+	}
+}
+
+// HandleSecurityTxt serves security.txt at /.well-known/security.txt with
+// an Expires field added, so it never serves an expired one. The field moves
+// a day at a time, keeping the file the same while browsers cache it.
+func (s *Server) HandleSecurityTxt(w http.ResponseWriter, r *http.Request) {
+	a, err := s.assets()
+	if err != nil {
+		log.Error("Failed to load static assets: " + err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	f, ok := a.static.byName["security.txt"]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	expiry := s.securityTxtExpiry
+	if expiry <= 0 {
+		expiry = defaultSecurityTxtExpiry
+	}
+	expires := time.Now().UTC().Add(expiry).Truncate(24 * time.Hour)
+	body := fmt.Appendf(slices.Clip(f.body), "Expires: %s\n", expires.Format(time.RFC3339))
+
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, f.name, time.Time{}, bytes.NewReader(body))
+}
+
+func serveStaticFile(w http.ResponseWriter, r *http.Request, f *staticFile, cacheControl string) {
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("ETag", `"`+f.hash+`"`)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, f.name, time.Time{}, bytes.NewReader(f.body))
+}
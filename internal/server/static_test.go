@@ -0,0 +1,154 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_HandleStatic(t *testing.T) {
+	s := &Server{}
+	a, err := s.assets()
+	assert.NoError(t, err)
+	js := a.static.url("dashboard.js")
+	assert.Regexp(t, `^/static/dashboard\.[0-9a-f]{8}\.js$`, js)
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+		cacheControl   string
+		contentType    string
+	}{
+		{name: "fingerprinted", method: http.MethodGet, path: js, expectedStatus: http.StatusOK, cacheControl: "public, max-age=31536000, immutable", contentType: "text/javascript; charset=utf-8"},
+		{name: "head", method: http.MethodHead, path: js, expectedStatus: http.StatusOK, cacheControl: "public, max-age=31536000, immutable"},
+		{name: "plain name", method: http.MethodGet, path: "/static/dashboard.css", expectedStatus: http.StatusOK, cacheControl: "no-cache", contentType: "text/css; charset=utf-8"},
+		{name: "stale fingerprint", method: http.MethodGet, path: "/static/dashboard.00000000.js", expectedStatus: http.StatusNotFound},
+		{name: "unknown", method: http.MethodGet, path: "/static/app.js", expectedStatus: http.StatusNotFound},
+		{name: "post", method: http.MethodPost, path: js, expectedStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.routes().ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, http.NoBody))
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Equal(t, tt.cacheControl, w.Header().Get("Cache-Control"))
+			if tt.contentType != "" {
+				assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestServer_HandleStatic_Revalidate(t *testing.T) {
+	s := &Server{}
+	a, err := s.assets()
+	assert.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	s.routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, a.static.url("icon.svg"), http.NoBody))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/static/icon.svg", http.NoBody)
+	req.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	s.routes().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+}
+
+func TestServer_HandleStatic_Dev(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "template"), 0o750))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "template", "index.html"), []byte(`{{static "app.css"}}`), 0o600))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "static"), 0o750))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "static", "app.css"), []byte("a {}"), 0o600))
+
+	s := &Server{web: os.DirFS(dir), dev: true}
+	a, err := s.assets()
+	assert.NoError(t, err)
+	before := a.static.url("app.css")
+
+	// Changed assets get a new fingerprint right away in dev mode
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "static", "app.css"), []byte("b {}"), 0o600))
+	a, err = s.assets()
+	assert.NoError(t, err)
+	assert.NotEqual(t, before, a.static.url("app.css"))
+
+	w := httptest.NewRecorder()
+	s.routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, a.static.url("app.css"), http.NoBody))
+	assert.Equal(t, "b {}", w.Body.String())
+}
+
+func TestServer_HandleSecurityTxt(t *testing.T) {
+	tests := []struct {
+		name           string
+		expiry         time.Duration
+		expectedExpiry time.Duration
+	}{
+		{name: "default", expectedExpiry: 365 * 24 * time.Hour},
+		{name: "configured", expiry: 30 * 24 * time.Hour, expectedExpiry: 30 * 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{}
+			WithSecurityTxtExpiry(tt.expiry)(s)
+
+			w := httptest.NewRecorder()
+			s.routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/.well-known/security.txt", http.NoBody))
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+			lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+			assert.True(t, strings.HasPrefix(lines[0], "Contact: "))
+			expires, ok := strings.CutPrefix(lines[len(lines)-1], "Expires: ")
+			assert.True(t, ok)
+			tm, err := time.Parse(time.RFC3339, expires)
+			assert.NoError(t, err)
+			assert.WithinDuration(t, time.Now().Add(tt.expectedExpiry), tm, 24*time.Hour)
+			assert.Equal(t, tm.Truncate(24*time.Hour), tm)
+		})
+	}
+}
+
+func TestServer_HandleWellKnown(t *testing.T) {
+	s := &Server{}
+
+	tests := []struct {
+		name           string
+		asset          string
+		method         string
+		expectedStatus int
+		contentType    string
+		expectedBody   string
+	}{
+		{name: "favicon", asset: "favicon.ico", method: http.MethodGet, expectedStatus: http.StatusOK, contentType: "image/vnd.microsoft.icon"},
+		{name: "robots", asset: "robots.txt", method: http.MethodGet, expectedStatus: http.StatusOK, contentType: "text/plain; charset=utf-8", expectedBody: "User-agent: *"},
+		{name: "security", asset: ".well-known/security.txt", method: http.MethodGet, expectedStatus: http.StatusOK, contentType: "text/plain; charset=utf-8", expectedBody: "Contact: "},
+		{name: "missing", asset: "humans.txt", method: http.MethodGet, expectedStatus: http.StatusNotFound},
+		{name: "post", asset: "robots.txt", method: http.MethodPost, expectedStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.routes().ServeHTTP(w, httptest.NewRequest(tt.method, "/"+tt.asset, http.NoBody))
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"))
+				assert.Equal(t, "public, max-age=86400", w.Header().Get("Cache-Control"))
+				assert.Contains(t, w.Body.String(), tt.expectedBody)
+			}
+		})
+	}
+}
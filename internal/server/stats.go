@@ -0,0 +1,184 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ivanglie/coinmon/pkg/aggregator"
+	"github.com/ivanglie/coinmon/pkg/log"
+)
+
+const (
+	statsBucket  = 10 * time.Second
+	statsBuckets = int(time.Hour / statsBucket)
+)
+
+// statsWindows are the windows exchange statistics are reported over
+var statsWindows = []struct {
+	name string
+	d    time.Duration
+}{
+	{"1m", time.Minute},
+	{"5m", 5 * time.Minute},
+	{"1h", time.Hour},
+}
+
+// callBucket aggregates exchange calls made within one statsBucket
+type callBucket struct {
+	index       int64
+	calls       int
+	errors      int
+	rateLimited int
+	latency     time.Duration
+}
+
+// statsTracker aggregates exchange calls of the last hour in buckets
+type statsTracker struct {
+	mu  sync.Mutex
+	m   map[string]*[statsBuckets]callBucket
+	now func() time.Time
+}
+
+// ExchangeStats represents call statistics of an exchange
+type ExchangeStats struct {
+	Exchange      string     `json:"exchange"`
+	Status        string     `json:"status"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorTime *time.Time `json:"last_error_time,omitempty"`
+	// BackoffUntil is the end of the backoff the exchange asked for with a
+	// 429 or 418 response, until which it is not called
+	BackoffUntil *time.Time `json:"backoff_until,omitempty"`
+	// MaintenanceUntil is the end of the period the exchange is not called
+	// for after answering that it is down for maintenance
+	MaintenanceUntil *time.Time             `json:"maintenance_until,omitempty"`
+	Windows          map[string]WindowStats `json:"windows"`
+}
+
+// WindowStats represents exchange calls made within a window
+type WindowStats struct {
+	Calls  int `json:"calls"`
+	Errors int `json:"errors"`
+	// RateLimited are the errors of calls rejected by the exchange's rate
+	// limit or skipped while backing off from it
+	RateLimited  int     `json:"rate_limited"`
+	SuccessRate  float64 `json:"success_rate"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+}
+
+// record adds a call to the current bucket. Calls aborted by ctx, e.g.
+// losers of the price race, and calls to exchanges down for maintenance say
+// nothing about the exchange and are ignored.
+func (t *statsTracker) record(ctx context.Context, name string, err error, d time.Duration) {
+	if err != nil && (ctx.Err() != nil || errors.Is(err, errMaintenance)) {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.m == nil {
+		t.m = make(map[string]*[statsBuckets]callBucket)
+	}
+	ring, ok := t.m[name]
+	if !ok {
+		ring = &[statsBuckets]callBucket{}
+		t.m[name] = ring
+	}
+
+	idx := t.index()
+	b := &ring[idx%int64(statsBuckets)]
+	if b.index != idx {
+		*b = callBucket{index: idx}
+	}
+
+	b.calls++
+	b.latency += d
+	if err != nil {
+		b.errors++
+	}
+	if errors.Is(err, aggregator.ErrBackoff) {
+		b.rateLimited++
+	}
+}
+
+// window sums calls of name made within the last d
+func (t *statsTracker) window(name string, d time.Duration) WindowStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var (
+		ws      WindowStats
+		latency time.Duration
+	)
+	if ring, ok := t.m[name]; ok {
+		idx := t.index()
+		oldest := idx - int64(d/statsBucket)
+		for _, b := range ring {
+			if b.index > oldest && b.index <= idx {
+				ws.Calls += b.calls
+				ws.Errors += b.errors
+				ws.RateLimited += b.rateLimited
+				latency += b.latency
+			}
+		}
+	}
+
+	if ws.Calls > 0 {
+		ws.SuccessRate = float64(ws.Calls-ws.Errors) / float64(ws.Calls)
+		ws.AvgLatencyMs = float64(latency.Microseconds()) / float64(ws.Calls) / 1000
+	}
+
+	return ws
+}
+
+func (t *statsTracker) index() int64 {
+	now := time.Now
+	if t.now != nil {
+		now = t.now
+	}
+	return now().UnixNano() / int64(statsBucket)
+}
+
+// HandleStats handles /api/v1/stats requests
+func (s *Server) HandleStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.exchangeStats()); err != nil {
+		log.Error("Failed to encode response: " + err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) exchangeStats() []ExchangeStats {
+	stats := make([]ExchangeStats, 0, len(s.exchanges))
+	for _, ex := range s.exchanges {
+		name := ex.Name.String()
+		h := s.health.get(name)
+
+		es := ExchangeStats{
+			Exchange:  name,
+			Status:    s.exchangeStatus(ex.Name),
+			LastError: h.LastError,
+			Windows:   make(map[string]WindowStats, len(statsWindows)),
+		}
+		if !h.LastErrorTime.IsZero() {
+			es.LastErrorTime = &h.LastErrorTime
+		}
+		if until := s.limits.blockedUntil(ex.Name); time.Now().Before(until) {
+			es.BackoffUntil = &until
+		}
+		if until := s.limits.benchedUntil(ex.Name); time.Now().Before(until) {
+			es.MaintenanceUntil = &until
+		}
+		for _, win := range statsWindows {
+			es.Windows[win.name] = s.stats.window(name, win.d)
+		}
+
+		stats = append(stats, es)
+	}
+
+	return stats
+}
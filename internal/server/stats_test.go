@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatsTracker(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tr := &statsTracker{now: func() time.Time { return now }}
+	ctx := context.Background()
+
+	assert.Equal(t, WindowStats{}, tr.window("binance", time.Minute))
+
+	// An hour ago, outside every window
+	now = now.Add(-time.Hour)
+	tr.record(ctx, "binance", errors.New("timeout"), time.Second)
+
+	// Ten minutes ago
+	now = now.Add(50 * time.Minute)
+	tr.record(ctx, "binance", errors.New("timeout"), 500*time.Millisecond)
+
+	// Now
+	now = now.Add(10 * time.Minute)
+	tr.record(ctx, "binance", nil, 100*time.Millisecond)
+	tr.record(ctx, "binance", nil, 200*time.Millisecond)
+	tr.record(ctx, "bybit", nil, 50*time.Millisecond)
+
+	// Race losers are not counted
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	tr.record(canceled, "binance", context.Canceled, time.Millisecond)
+
+	assert.Equal(t, WindowStats{Calls: 2, SuccessRate: 1, AvgLatencyMs: 150}, tr.window("binance", time.Minute))
+	assert.Equal(t, WindowStats{Calls: 2, SuccessRate: 1, AvgLatencyMs: 150}, tr.window("binance", 5*time.Minute))
+	assert.Equal(t, WindowStats{Calls: 3, Errors: 1, SuccessRate: 2.0 / 3, AvgLatencyMs: 800.0 / 3}, tr.window("binance", time.Hour))
+	assert.Equal(t, WindowStats{Calls: 1, SuccessRate: 1, AvgLatencyMs: 50}, tr.window("bybit", time.Hour))
+}
+
+func TestServer_HandleStats(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		fetchers       map[exchange.Name]Fetcher
+		expectedStatus int
+		expectedStats  ExchangeStats
+	}{
+		{
+			name:           "success",
+			method:         http.MethodGet,
+			fetchers:       mockFetchers(),
+			expectedStatus: http.StatusOK,
+			expectedStats: ExchangeStats{
+				Exchange: "binance",
+				Status:   "up",
+				Windows: map[string]WindowStats{
+					"1m": {Calls: 1, SuccessRate: 1},
+					"5m": {Calls: 1, SuccessRate: 1},
+					"1h": {Calls: 1, SuccessRate: 1},
+				},
+			},
+		},
+		{
+			name:           "error",
+			method:         http.MethodGet,
+			fetchers:       mockErrorFetchers(),
+			expectedStatus: http.StatusOK,
+			expectedStats: ExchangeStats{
+				Exchange:  "binance",
+				Status:    "down",
+				LastError: "code=400, msg=Bad Request",
+				Windows: map[string]WindowStats{
+					"1m": {Calls: 1, Errors: 1},
+					"5m": {Calls: 1, Errors: 1},
+					"1h": {Calls: 1, Errors: 1},
+				},
+			},
+		},
+		{
+			name:           "method not allowed",
+			method:         http.MethodPost,
+			fetchers:       mockFetchers(),
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{
+				exchanges: exchanges[:1],
+				fetchers:  tt.fetchers,
+			}
+			_, _, _ = s.firstPriceWithDetails(context.Background(), "BTCUSDT")
+
+			req := httptest.NewRequest(tt.method, "/api/v1/stats", http.NoBody)
+			w := httptest.NewRecorder()
+			s.routes().ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var stats []ExchangeStats
+			assert.NoError(t, json.NewDecoder(w.Body).Decode(&stats))
+			assert.Len(t, stats, 1)
+
+			// Latency and error time depend on the clock
+			got := stats[0]
+			for k, ws := range got.Windows {
+				assert.GreaterOrEqual(t, ws.AvgLatencyMs, 0.0)
+				ws.AvgLatencyMs = 0
+				got.Windows[k] = ws
+			}
+			assert.Equal(t, tt.expectedStats.LastError != "", got.LastErrorTime != nil)
+			got.LastErrorTime = nil
+
+			assert.Equal(t, tt.expectedStats, got)
+		})
+	}
+}
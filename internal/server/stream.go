@@ -0,0 +1,97 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/ivanglie/coinmon/pkg/log"
+)
+
+const heartbeatInterval = 15 * time.Second
+
+// HandleStream handles /api/v1/stream/{pair} requests with Server-Sent Events
+func (s *Server) HandleStream(w http.ResponseWriter, r *http.Request) {
+	pair := strings.ToUpper(r.PathValue("pair"))
+	if s.forbidPair(w, pair) {
+		return
+	}
+
+	release, ok := s.acquireSubscriber(w)
+	if !ok {
+		return
+	}
+	defer release()
+
+	ctx, cancel := s.streamContext(r.Context())
+	defer cancel()
+
+	// Streams outlive the server write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		log.Debug("Failed to clear write deadline: " + err.Error())
+	}
+
+	ch, unsubscribe := s.events.Subscribe(pair)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	// Send the current price right away instead of waiting for the next poll
+	price, source, err := s.price(ctx, pair)
+	if err != nil {
+		writeEvent(w, "error", err.Error())
+	} else {
+		writeUpdate(w, bus.PriceUpdated{Pair: pair, Price: price, Source: source, Time: time.Now().UTC()})
+	}
+	_ = rc.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case u := <-ch:
+			if !writeUpdate(w, u) {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeUpdate(w http.ResponseWriter, u bus.PriceUpdated) bool {
+	b, err := json.Marshal(u)
+	if err != nil {
+		log.Error("Failed to encode update: " + err.Error())
+		return false
+	}
+
+	return writeEvent(w, "price", string(b))
+}
+
+func writeEvent(w http.ResponseWriter, event, data string) bool {
+	// Multi-line data must be split into several data fields
+	data = strings.ReplaceAll(data, "\n", "\ndata: ")
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		log.Debug("Failed to write event: " + err.Error())
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_HandleStream(t *testing.T) {
+	s := &Server{
+		events:    bus.New(),
+		exchanges: exchanges[:1],
+		fetchers:  mockFetchers(),
+	}
+
+	ts := httptest.NewServer(s.routes())
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/api/v1/stream/btcusdt", http.NoBody)
+	assert.NoError(t, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	events := make(chan string, 2)
+	go func() {
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() {
+			if strings.HasPrefix(sc.Text(), "data: ") {
+				events <- sc.Text()
+			}
+		}
+	}()
+
+	select {
+	case e := <-events:
+		assert.Contains(t, e, `"pair":"BTCUSDT","price":99999.99,"source":"binance"`)
+	case <-time.After(time.Second):
+		t.Fatal("initial price was not sent")
+	}
+
+	assert.Eventually(t, func() bool {
+		return s.events.Subscribers("BTCUSDT") == 1
+	}, time.Second, 5*time.Millisecond)
+
+	s.events.Publish(bus.PriceUpdated{Pair: "BTCUSDT", Price: 100000, Source: "bybit"})
+
+	select {
+	case e := <-events:
+		assert.Contains(t, e, `"pair":"BTCUSDT","price":100000,"source":"bybit"`)
+	case <-time.After(time.Second):
+		t.Fatal("update was not sent")
+	}
+}
+
+func TestServer_HandleStream_Errors(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "method not allowed",
+			method:         http.MethodPost,
+			path:           "/api/v1/stream/BTCUSDT",
+			expectedStatus: http.StatusMethodNotAllowed,
+			expectedBody:   "Method Not Allowed\n",
+		},
+		{
+			name:           "missing pair",
+			method:         http.MethodGet,
+			path:           "/api/v1/stream/",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "404 page not found\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{exchanges: exchanges, events: bus.New()}
+
+			req := httptest.NewRequest(tt.method, tt.path, http.NoBody)
+			w := httptest.NewRecorder()
+
+			s.routes().ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Equal(t, tt.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestServer_HandleStream_InitialError(t *testing.T) {
+	s := &Server{
+		events:    bus.New(),
+		exchanges: exchanges,
+		fetchers:  mockInvalidPairFetchers(),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/stream/INVALID", http.NoBody).WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	s.routes().ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "event: error\ndata: ")
+}
+
+func TestWriteEvent(t *testing.T) {
+	w := httptest.NewRecorder()
+	assert.True(t, writeEvent(w, "error", "line1\nline2"))
+	assert.Equal(t, "event: error\ndata: line1\ndata: line2\n\n", w.Body.String())
+}
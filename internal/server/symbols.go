@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ivanglie/coinmon/internal/exchange"
+)
+
+// symbolLists keeps the pairs listed on exchanges, from their tickers, so
+// prices are not asked from exchanges not listing a pair
+type symbolLists struct {
+	mu sync.RWMutex
+	m  map[exchange.Name]map[string]struct{}
+}
+
+// set replaces the pairs listed on the exchange name
+func (l *symbolLists) set(name exchange.Name, tickers []exchange.Ticker) {
+	pairs := make(map[string]struct{}, len(tickers))
+	for _, t := range tickers {
+		pairs[t.Pair] = struct{}{}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.m == nil {
+		l.m = make(map[exchange.Name]map[string]struct{})
+	}
+	l.m[name] = pairs
+}
+
+// lists reports whether the exchange name lists pair, or may list it for
+// exchanges without a known list
+func (l *symbolLists) lists(name exchange.Name, pair string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	pairs, ok := l.m[name]
+	if !ok {
+		return true
+	}
+	_, ok = pairs[pair]
+	return ok
+}
+
+// RefreshSymbols fetches the pairs listed on every active exchange with
+// tickers support, keeping the previous list of exchanges which fail
+func (s *Server) RefreshSymbols(ctx context.Context) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, ex := range s.activeExchanges() {
+		if ex.TickersURL() == "" {
+			continue
+		}
+
+		wg.Go(func() {
+			tickers, err := s.fetchTickers(ctx, ex)
+			if err == nil && len(tickers) == 0 {
+				err = errors.New("no pairs listed")
+			}
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", ex.Name, err))
+				mu.Unlock()
+				return
+			}
+
+			s.symbols.set(ex.Name, tickers)
+		})
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_RefreshSymbols(t *testing.T) {
+	binance, bybit, kraken := exchange.New(exchange.BINANCE), exchange.New(exchange.BYBIT), exchange.New(exchange.KRAKEN)
+	s := &Server{
+		exchanges: []*exchange.Exchange{binance, bybit},
+		client:    &mockHTTPClient{doFunc: mockTickersResponse(false)},
+	}
+
+	// Exchanges may list any pair until their list is known
+	assert.True(t, s.serves(bybit, "BTCUSDT"))
+
+	assert.NoError(t, s.RefreshSymbols(context.Background()))
+	assert.True(t, s.serves(binance, "BTCUSDT"))
+	assert.False(t, s.serves(bybit, "BTCUSDT"))
+	assert.True(t, s.serves(bybit, "ETHUSDT"))
+	assert.True(t, s.serves(kraken, "BTCUSDT"))
+	assert.Equal(t, []string{"binance"}, s.priceOptions("BTCUSDT").Sources)
+
+	// Pairs no exchange lists are asked from all of them
+	assert.Equal(t, []string{"binance", "bybit"}, s.priceOptions("NEWUSDT").Sources)
+
+	// Exchanges failing keep their previous list
+	s.client = &mockHTTPClient{doFunc: mockTickersResponse(true)}
+	err := s.RefreshSymbols(context.Background())
+	assert.ErrorContains(t, err, "bybit")
+	assert.False(t, s.serves(bybit, "BTCUSDT"))
+	assert.True(t, s.serves(bybit, "SOLUSDC"))
+}
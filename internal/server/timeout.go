@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+)
+
+// defaultMaxRequestTimeout caps the ?timeout of requests, staying below the
+// write timeout of responses
+const defaultMaxRequestTimeout = 5 * time.Second
+
+// defaultMaxStaleAge is the age of the oldest price returned as stale
+const defaultMaxStaleAge = time.Minute
+
+// staleWarning marks responses with a price resolved before the request
+const staleWarning = `110 - "Response is Stale"`
+
+// WithMaxRequestTimeout caps the ?timeout clients bound the resolution of a
+// price by at d instead of 5s
+func WithMaxRequestTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.maxRequestTimeout = d
+	}
+}
+
+// WithMaxStaleAge returns prices resolved up to d ago as stale when the
+// exchanges do not answer in time, instead of up to a minute ago
+func WithMaxStaleAge(d time.Duration) Option {
+	return func(s *Server) {
+		s.maxStaleAge = d
+	}
+}
+
+// WithUpstreamRetries calls a failing exchange again up to n times within
+// the deadline of the request
+func WithUpstreamRetries(n int) Option {
+	return func(s *Server) {
+		s.retries = n
+	}
+}
+
+// WithHedge calls the exchanges of the next priority when the called ones
+// have not answered within d, without waiting for all of them to fail
+func WithHedge(d time.Duration) Option {
+	return func(s *Server) {
+		s.hedge = d
+	}
+}
+
+// requestTimeout returns the ?timeout of r, e.g. 800ms, capped at the
+// maximum. It is the maximum when unset, so retries and fallbacks between
+// exchanges end before the response times out.
+func (s *Server) requestTimeout(r *http.Request) (time.Duration, error) {
+	maxTimeout := s.maxRequestTimeout
+	if maxTimeout <= 0 {
+		maxTimeout = defaultMaxRequestTimeout
+	}
+
+	v := r.URL.Query().Get("timeout")
+	if v == "" {
+		return maxTimeout, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid timeout %q", v)
+	}
+
+	return min(d, maxTimeout), nil
+}
+
+// quote resolves the price of pair within timeout, without a bound of its
+// own when zero, and publishes it to the bus. When the exchanges do not
+// answer in time, the latest price published for pair is returned as stale
+// unless it is older than the maximum stale age.
+func (s *Server) quote(ctx context.Context, pair string, timeout time.Duration) (e bus.PriceUpdated, stale bool, err error) {
+	priceCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		priceCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	q, err := s.latestQuote(priceCtx, pair)
+	if err == nil {
+		e = s.resolved(pair, q.Source, q.Price)
+		e.Time = q.Time
+		return e, false, nil
+	}
+
+	// Attempts ending at the deadline may fail before priceCtx is done
+	if timeout > 0 && ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+		maxAge := s.maxStaleAge
+		if maxAge <= 0 {
+			maxAge = defaultMaxStaleAge
+		}
+		if last, ok := s.events.Latest(pair); ok && time.Since(last.Time) <= maxAge {
+			return last, true, nil
+		}
+	}
+
+	return bus.PriceUpdated{}, false, err
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/ivanglie/coinmon/pkg/aggregator"
+	"github.com/stretchr/testify/assert"
+)
+
+// hangingFetcher does not answer until the call is canceled
+type hangingFetcher struct{}
+
+func (hangingFetcher) FetchPrice(ctx context.Context, _ string) (aggregator.Quote, error) {
+	<-ctx.Done()
+	return aggregator.Quote{}, ctx.Err()
+}
+
+func TestServer_requestTimeout(t *testing.T) {
+	tests := []struct {
+		query         string
+		expected      time.Duration
+		expectedError string
+	}{
+		{query: "", expected: 2 * time.Second},
+		{query: "?timeout=800ms", expected: 800 * time.Millisecond},
+		{query: "?timeout=1m", expected: 2 * time.Second},
+		{query: "?timeout=fast", expectedError: `invalid timeout "fast"`},
+		{query: "?timeout=-1s", expectedError: `invalid timeout "-1s"`},
+	}
+
+	s := &Server{}
+	WithMaxRequestTimeout(2 * time.Second)(s)
+	for _, tt := range tests {
+		d, err := s.requestTimeout(httptest.NewRequest(http.MethodGet, "/api/v1/spot/BTCUSDT"+tt.query, http.NoBody))
+		if tt.expectedError != "" {
+			assert.EqualError(t, err, tt.expectedError, tt.query)
+			continue
+		}
+		assert.NoError(t, err, tt.query)
+		assert.Equal(t, tt.expected, d, tt.query)
+	}
+
+	d, _ := (&Server{}).requestTimeout(httptest.NewRequest(http.MethodGet, "/api/v1/spot/BTCUSDT?timeout=1h", http.NoBody))
+	assert.Equal(t, defaultMaxRequestTimeout, d)
+}
+
+func TestServer_HandleSpot_Timeout(t *testing.T) {
+	s := &Server{
+		exchanges: []*exchange.Exchange{exchange.New(exchange.BINANCE)},
+		fetchers:  map[exchange.Name]Fetcher{exchange.BINANCE: hangingFetcher{}},
+		events:    bus.New(),
+	}
+
+	// Nothing resolved before, so the request fails at its deadline
+	w := httptest.NewRecorder()
+	start := time.Now()
+	s.routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/spot/BTCUSDT?timeout=50ms", http.NoBody))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Contains(t, w.Body.String(), `"pending":["binance"]`)
+
+	w = httptest.NewRecorder()
+	s.routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v2/spot/BTCUSDT?timeout=50ms", http.NoBody))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), `"errors":[{"code":"deadline_exceeded","message":"BTCUSDT: no answer before the deadline","source":"binance"}]`)
+
+	resolvedAt := time.Now().Add(-10 * time.Second).UTC()
+	s.events.Publish(bus.PriceUpdated{Pair: "BTCUSDT", Price: 97000, Source: "bybit", Time: resolvedAt})
+
+	w = httptest.NewRecorder()
+	s.routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/spot/BTCUSDT?timeout=50ms&details=true", http.NoBody))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, staleWarning, w.Header().Get("Warning"))
+	assert.JSONEq(t, `{"pair":"BTCUSDT","price":97000,"source":"bybit","stale":true,"time":"`+resolvedAt.Format(time.RFC3339Nano)+`"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	s.routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v2/spot/BTCUSDT?timeout=50ms", http.NoBody))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var env struct {
+		Data Quote `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&env))
+	assert.Equal(t, Quote{Pair: "BTCUSDT", Price: "97000", Source: "bybit", Time: resolvedAt, Stale: true}, env.Data)
+
+	// Prices older than the maximum stale age are not returned
+	WithMaxStaleAge(5 * time.Second)(s)
+	w = httptest.NewRecorder()
+	s.routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/spot/BTCUSDT?timeout=50ms", http.NoBody))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	w = httptest.NewRecorder()
+	s.routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/spot/BTCUSDT?timeout=soon", http.NoBody))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Requests without a timeout are bounded by the maximum
+	WithMaxRequestTimeout(50 * time.Millisecond)(s)
+	w = httptest.NewRecorder()
+	start = time.Now()
+	s.routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/spot/ETHUSDT", http.NoBody))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Less(t, time.Since(start), time.Second)
+}
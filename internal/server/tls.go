@@ -0,0 +1,87 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"golang.org/x/crypto/acme"
+)
+
+// WithTLS serves HTTPS with the certificate chain and private key in the
+// PEM files certFile and keyFile
+func WithTLS(certFile, keyFile string) Option {
+	return func(s *Server) {
+		s.certFile = certFile
+		s.keyFile = keyFile
+	}
+}
+
+// WithClientCA requires certificates issued by a CA in the PEM bundle
+// caFile from clients of the debug endpoints and the admin routes. Other
+// routes stay open to clients without a certificate.
+func WithClientCA(caFile string) Option {
+	return func(s *Server) {
+		s.clientCAFile = caFile
+	}
+}
+
+// clientVerified reports whether r presents a certificate issued by the
+// client CA, always true without one
+func (s *Server) clientVerified(r *http.Request) bool {
+	return s.clientCAFile == "" || (r.TLS != nil && len(r.TLS.VerifiedChains) > 0)
+}
+
+// tlsEnabled reports whether the server serves HTTPS
+func (s *Server) tlsEnabled() bool {
+	return s.autocert != nil || s.certFile != "" || s.keyFile != ""
+}
+
+// tlsConfig returns settings for the certificates of the server allowing
+// TLS 1.3 and TLS 1.2 with forward secret AEAD cipher suites only
+func (s *Server) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+		CurvePreferences: []tls.CurveID{tls.X25519MLKEM768, tls.X25519, tls.CurveP256},
+		NextProtos:       []string{"h2", "http/1.1"},
+	}
+
+	if s.clientCAFile != "" {
+		pem, err := os.ReadFile(s.clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read client ca: %w", err)
+		}
+
+		cfg.ClientCAs = x509.NewCertPool()
+		if !cfg.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("parse client ca: no certificates found")
+		}
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	if s.autocert != nil {
+		cfg.GetCertificate = s.autocert.GetCertificate
+		// Let's Encrypt may validate with the TLS-ALPN-01 challenge too
+		cfg.NextProtos = append(cfg.NextProtos, acme.ALPNProto)
+		return cfg, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load certificate: %w", err)
+	}
+	cfg.Certificates = []tls.Certificate{cert}
+
+	return cfg, nil
+}
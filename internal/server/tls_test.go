@@ -0,0 +1,271 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeCert writes a self-signed certificate for 127.0.0.1 and its key to
+// dir, returning the file paths and the certificate
+func writeCert(t *testing.T, dir string) (certFile, keyFile string, cert *x509.Certificate) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.NoError(t, err)
+	cert, err = x509.ParseCertificate(der)
+	assert.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+
+	return certFile, keyFile, cert
+}
+
+func TestServer_Start_TLS(t *testing.T) {
+	certFile, keyFile, cert := writeCert(t, t.TempDir())
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	s := &Server{
+		listener: &http.Server{
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(r.Proto))
+			}),
+			ReadHeaderTimeout: time.Second,
+		},
+	}
+	WithListener(ln)(s)
+	WithTLS(certFile, keyFile)(s)
+
+	errc := make(chan error, 1)
+	go func() { errc <- s.Start() }()
+	defer func() {
+		assert.NoError(t, s.listener.Shutdown(t.Context()))
+		assert.NoError(t, <-errc)
+	}()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	tests := []struct {
+		name          string
+		maxVersion    uint16
+		expectedProto string
+		expectError   bool
+	}{
+		{
+			name:          "tls 1.3",
+			expectedProto: "HTTP/2.0",
+		},
+		{
+			name:          "tls 1.2",
+			maxVersion:    tls.VersionTLS12,
+			expectedProto: "HTTP/2.0",
+		},
+		{
+			name:        "tls 1.1",
+			maxVersion:  tls.VersionTLS11,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &http.Client{Transport: &http.Transport{
+				TLSClientConfig:   &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS10, MaxVersion: tt.maxVersion}, //nolint:gosec // checks old versions are refused
+				ForceAttemptHTTP2: true,
+			}}
+			defer client.CloseIdleConnections()
+
+			resp, err := client.Get("https://" + ln.Addr().String() + "/")
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer func() { _ = resp.Body.Close() }()
+			assert.Equal(t, tt.expectedProto, resp.Proto)
+		})
+	}
+}
+
+func TestServer_Start_TLSInvalidCert(t *testing.T) {
+	s := &Server{addr: "127.0.0.1:0", listener: &mockHTTPServer{}}
+	WithTLS(filepath.Join(t.TempDir(), "missing.pem"), filepath.Join(t.TempDir(), "missing.pem"))(s)
+
+	assert.ErrorContains(t, s.Start(), "load certificate")
+}
+
+// newClientCert returns a CA certificate in PEM and a client certificate
+// issued by it
+func newClientCert(t *testing.T) (caPEM []byte, cert tls.Certificate) {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	assert.NoError(t, err)
+	ca, err := x509.ParseCertificate(caDER)
+	assert.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	assert.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestServer_Start_clientCA(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, serverCert := writeCert(t, dir)
+
+	caPEM, clientCert := newClientCert(t)
+	caFile := filepath.Join(dir, "ca.pem")
+	assert.NoError(t, os.WriteFile(caFile, caPEM, 0o600))
+	_, untrusted := newClientCert(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	s := &Server{}
+	s.listener = &http.Server{
+		Handler: s.gateDebug(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})),
+		ReadHeaderTimeout: time.Second,
+	}
+	WithListener(ln)(s)
+	WithTLS(certFile, keyFile)(s)
+	WithClientCA(caFile)(s)
+	WithDebug("")(s)
+
+	errc := make(chan error, 1)
+	go func() { errc <- s.Start() }()
+	defer func() {
+		assert.NoError(t, s.listener.Shutdown(t.Context()))
+		assert.NoError(t, <-errc)
+	}()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(serverCert)
+
+	tests := []struct {
+		name           string
+		path           string
+		cert           *tls.Certificate
+		expectedStatus int
+		expectError    bool
+	}{
+		{
+			name:           "public route without certificate",
+			path:           "/api/v1/spot/BTCUSDT",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "debug route without certificate",
+			path:           "/debug/upstream",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "debug route with certificate",
+			path:           "/debug/upstream",
+			cert:           &clientCert,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:        "untrusted certificate",
+			path:        "/debug/upstream",
+			cert:        &untrusted,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tlsCfg := &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+			if tt.cert != nil {
+				tlsCfg.Certificates = []tls.Certificate{*tt.cert}
+			}
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsCfg}}
+			defer client.CloseIdleConnections()
+
+			resp, err := client.Get("https://" + ln.Addr().String() + tt.path)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer func() { _ = resp.Body.Close() }()
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+		})
+	}
+}
+
+func TestServer_tlsConfig_invalidClientCA(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, _ := writeCert(t, dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	assert.NoError(t, os.WriteFile(caFile, []byte("not a certificate"), 0o600))
+
+	s := &Server{}
+	WithTLS(certFile, keyFile)(s)
+	WithClientCA(caFile)(s)
+
+	_, err := s.tlsConfig()
+	assert.ErrorContains(t, err, "parse client ca")
+
+	WithClientCA(filepath.Join(dir, "missing.pem"))(s)
+	_, err = s.tlsConfig()
+	assert.ErrorContains(t, err, "read client ca")
+}
@@ -0,0 +1,231 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/ivanglie/coinmon/pkg/log"
+)
+
+const (
+	defaultTopLimit = 20
+	maxTopLimit     = 100
+	// topTTL is how long the tickers of exchanges are ranked from before
+	// they are fetched again
+	topTTL = time.Minute
+	// maxTickersBody bounds responses of tickers of all pairs, a few MiB
+	maxTickersBody = 16 << 20
+)
+
+// usdQuotes are the quote assets volumes are compared in, all worth about a
+// dollar
+var usdQuotes = []string{"USDT", "USDC", "USD"}
+
+// TopPair represents a pair ranked by its volume across exchanges
+type TopPair struct {
+	Pair string `json:"pair"`
+	// Volume is the quote volume of the last 24h summed across exchanges
+	Volume float64 `json:"volume"`
+	// Price is the last price on the exchange trading the pair most
+	Price     float64  `json:"price"`
+	Exchanges []string `json:"exchanges"`
+}
+
+// TopResponse represents the top pairs of the tickers fetched at Time, and
+// the errors of exchanges whose tickers are missing by exchange name
+type TopResponse struct {
+	By     string            `json:"by"`
+	Pairs  []TopPair         `json:"pairs"`
+	Time   time.Time         `json:"time"`
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// topCache keeps the pairs ranked from the last tickers any exchange answered
+// for topTTL. Ranking holds the lock, so concurrent requests wait for one
+// fetch of the tickers.
+type topCache struct {
+	mu   sync.Mutex
+	resp TopResponse
+}
+
+// HandleTop handles /api/v1/top requests listing the pairs quoted in dollars
+// with the highest 24h volume across the active exchanges, e.g.
+// /api/v1/top?by=volume&limit=20
+func (s *Server) HandleTop(w http.ResponseWriter, r *http.Request) {
+	by := cmp.Or(r.URL.Query().Get("by"), "volume")
+	if by != "volume" {
+		http.Error(w, fmt.Sprintf("invalid by %q, expected volume", by), http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultTopLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxTopLimit {
+			http.Error(w, fmt.Sprintf("invalid limit %q, expected 1 to %d", raw, maxTopLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	resp := s.top(r.Context())
+	if len(resp.Pairs) == 0 && len(resp.Errors) > 0 {
+		http.Error(w, "Failed to get tickers from exchanges", http.StatusServiceUnavailable)
+		return
+	}
+
+	pairs := make([]TopPair, 0, limit)
+	for _, p := range resp.Pairs {
+		if len(pairs) == limit {
+			break
+		}
+		if s.pairs.allows(p.Pair) {
+			pairs = append(pairs, p)
+		}
+	}
+	resp.Pairs = pairs
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Error("Failed to encode response: " + err.Error())
+	}
+}
+
+// top returns all pairs ranked by volume, from tickers at most topTTL old
+func (s *Server) top(ctx context.Context) TopResponse {
+	s.topPairs.mu.Lock()
+	defer s.topPairs.mu.Unlock()
+
+	if time.Since(s.topPairs.resp.Time) < topTTL {
+		return s.topPairs.resp
+	}
+
+	resp := s.rankTickers(ctx)
+	if len(resp.Pairs) > 0 {
+		s.topPairs.resp = resp
+	}
+
+	return resp
+}
+
+// rankTickers fetches the tickers of every active exchange with tickers
+// support at once and ranks the pairs quoted in dollars by volume
+func (s *Server) rankTickers(ctx context.Context) TopResponse {
+	resp := TopResponse{By: "volume", Pairs: []TopPair{}, Time: time.Now().UTC()}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		byPair  = make(map[string]*TopPair)
+		largest = make(map[string]float64) // volume on the exchange trading a pair most
+	)
+	for _, ex := range s.activeExchanges() {
+		if ex.TickersURL() == "" {
+			continue
+		}
+
+		wg.Go(func() {
+			tickers, err := s.fetchTickers(ctx, ex)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if resp.Errors == nil {
+					resp.Errors = make(map[string]string)
+				}
+				resp.Errors[ex.Name.String()] = err.Error()
+				return
+			}
+
+			for _, t := range tickers {
+				if !quotedInDollars(t.Pair) || t.QuoteVolume <= 0 {
+					continue
+				}
+
+				p, ok := byPair[t.Pair]
+				if !ok {
+					p = &TopPair{Pair: t.Pair}
+					byPair[t.Pair] = p
+				}
+				p.Volume += t.QuoteVolume
+				p.Exchanges = append(p.Exchanges, ex.Name.String())
+				if t.QuoteVolume > largest[t.Pair] {
+					largest[t.Pair] = t.QuoteVolume
+					p.Price = t.Price
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	for _, p := range byPair {
+		slices.Sort(p.Exchanges)
+		resp.Pairs = append(resp.Pairs, *p)
+	}
+	slices.SortFunc(resp.Pairs, func(a, b TopPair) int {
+		return cmp.Or(cmp.Compare(b.Volume, a.Volume), cmp.Compare(a.Pair, b.Pair))
+	})
+
+	return resp
+}
+
+// quotedInDollars reports whether pair is quoted in one of usdQuotes
+func quotedInDollars(pair string) bool {
+	return slices.ContainsFunc(usdQuotes, func(quote string) bool {
+		return len(pair) > len(quote) && strings.HasSuffix(pair, quote)
+	})
+}
+
+// fetchTickers returns the 24h tickers of all pairs of e, charging the rate
+// limit of e with the cost of the call
+func (s *Server) fetchTickers(ctx context.Context, e *exchange.Exchange) ([]exchange.Ticker, error) {
+	if err := s.limits.waitN(ctx, e, e.TickersCost()); err != nil {
+		return nil, fmt.Errorf("wait for rate limit: %w", err)
+	}
+
+	release, err := s.upstreamLimit.acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("wait for upstream slot: %w", err)
+	}
+	defer release()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.TickersURL(), http.NoBody) //nolint:gosec // URL is constructed from static exchange configuration, not user input
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	s.limits.observe(e, resp)
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxTickersBody+1))
+	if err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+	if len(body) > maxTickersBody {
+		return nil, fmt.Errorf("response exceeds %d bytes", maxTickersBody)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, body[:min(len(body), upstreamBodyMax)])
+	}
+
+	return exchange.ParseTickers(e.Name, body)
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockTickersResponse answers tickers requests of every exchange, and fails
+// on Bybit when down
+func mockTickersResponse(down bool) mockResponseFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		url := req.URL.String()
+		respond := func(status int, body string) (*http.Response, error) {
+			return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body))}, nil
+		}
+
+		switch {
+		case strings.Contains(url, "bybit") && down:
+			return nil, errors.New("connection refused")
+		case strings.Contains(url, "binance"):
+			return respond(http.StatusOK, `[{"symbol":"BTCUSDT","lastPrice":"97000","quoteVolume":"1000"},{"symbol":"ETHUSDT","lastPrice":"3100","quoteVolume":"800"},{"symbol":"ETHBTC","lastPrice":"0.032","quoteVolume":"5000"}]`)
+		case strings.Contains(url, "bybit"):
+			return respond(http.StatusOK, `{"retCode":0,"retMsg":"OK","result":{"list":[{"symbol":"ETHUSDT","lastPrice":"3101","turnover24h":"900"},{"symbol":"SOLUSDC","lastPrice":"210","turnover24h":"100"}]}}`)
+		case strings.Contains(url, "bitget"):
+			return respond(http.StatusServiceUnavailable, `{"code":"40010","msg":"Service unavailable"}`)
+		default:
+			return respond(http.StatusOK, `{"error":[],"result":{"XXBTZUSD":{"c":["97010","0.1"],"v":["1","2"],"p":["96000","100"]}}}`)
+		}
+	}
+}
+
+func TestServer_HandleTop(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		down           bool
+		exchanges      []*exchange.Exchange
+		expectedStatus int
+		expectedBody   string
+		expectedPairs  []TopPair
+		expectedErrors map[string]string
+	}{
+		{
+			name:           "by volume",
+			exchanges:      []*exchange.Exchange{exchange.New(exchange.BINANCE), exchange.New(exchange.BYBIT), exchange.New(exchange.KRAKEN)},
+			expectedStatus: http.StatusOK,
+			expectedPairs: []TopPair{
+				{Pair: "ETHUSDT", Volume: 1700, Price: 3101, Exchanges: []string{"binance", "bybit"}},
+				{Pair: "BTCUSDT", Volume: 1000, Price: 97000, Exchanges: []string{"binance"}},
+				{Pair: "BTCUSD", Volume: 200, Price: 97010, Exchanges: []string{"kraken"}},
+				{Pair: "SOLUSDC", Volume: 100, Price: 210, Exchanges: []string{"bybit"}},
+			},
+		},
+		{
+			name:           "limit",
+			query:          "?by=volume&limit=1",
+			exchanges:      []*exchange.Exchange{exchange.New(exchange.BINANCE), exchange.New(exchange.BYBIT)},
+			expectedStatus: http.StatusOK,
+			expectedPairs:  []TopPair{{Pair: "ETHUSDT", Volume: 1700, Price: 3101, Exchanges: []string{"binance", "bybit"}}},
+		},
+		{
+			name:           "exchanges down",
+			down:           true,
+			exchanges:      []*exchange.Exchange{exchange.New(exchange.BINANCE), exchange.New(exchange.BYBIT), exchange.New(exchange.BITGET)},
+			expectedStatus: http.StatusOK,
+			expectedPairs: []TopPair{
+				{Pair: "BTCUSDT", Volume: 1000, Price: 97000, Exchanges: []string{"binance"}},
+				{Pair: "ETHUSDT", Volume: 800, Price: 3100, Exchanges: []string{"binance"}},
+			},
+			expectedErrors: map[string]string{
+				"bitget": `unexpected status code: 503, body: {"code":"40010","msg":"Service unavailable"}`,
+				"bybit":  "do request: connection refused",
+			},
+		},
+		{
+			name:           "all exchanges down",
+			exchanges:      []*exchange.Exchange{exchange.New(exchange.BITGET)},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   "Failed to get tickers from exchanges\n",
+		},
+		{
+			name:           "invalid by",
+			query:          "?by=trades",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "invalid by \"trades\", expected volume\n",
+		},
+		{
+			name:           "invalid limit",
+			query:          "?limit=101",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "invalid limit \"101\", expected 1 to 100\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{exchanges: tt.exchanges, client: &mockHTTPClient{doFunc: mockTickersResponse(tt.down)}}
+
+			w := httptest.NewRecorder()
+			s.routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/top"+tt.query, http.NoBody))
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				assert.Equal(t, tt.expectedBody, w.Body.String())
+				return
+			}
+
+			var resp TopResponse
+			assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+			assert.Equal(t, "volume", resp.By)
+			assert.Equal(t, tt.expectedPairs, resp.Pairs)
+			assert.Equal(t, tt.expectedErrors, resp.Errors)
+		})
+	}
+}
+
+func TestServer_HandleTop_PairPolicy(t *testing.T) {
+	s := &Server{exchanges: []*exchange.Exchange{exchange.New(exchange.BINANCE)}, client: &mockHTTPClient{doFunc: mockTickersResponse(false)}}
+	WithPairPolicy(nil, []string{"ETH*"})(s)
+
+	w := httptest.NewRecorder()
+	s.routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/top", http.NoBody))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp TopResponse
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, []TopPair{{Pair: "BTCUSDT", Volume: 1000, Price: 97000, Exchanges: []string{"binance"}}}, resp.Pairs)
+}
+
+func TestServer_HandleTop_Cache(t *testing.T) {
+	var calls atomic.Int32
+	mock := mockTickersResponse(false)
+	s := &Server{
+		exchanges: []*exchange.Exchange{exchange.New(exchange.BINANCE)},
+		client: &mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+			calls.Add(1)
+			return mock(req)
+		}},
+	}
+
+	for _, query := range []string{"", "?limit=1"} {
+		w := httptest.NewRecorder()
+		s.routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/top"+query, http.NoBody))
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	assert.Equal(t, int32(1), calls.Load())
+}
@@ -0,0 +1,249 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/exchange"
+)
+
+// upstreamTimeout bounds a call to an exchange including reading the response
+const upstreamTimeout = 5 * time.Second
+
+// userAgent identifies calls to exchanges, some of which throttle the
+// default Go user agent
+const userAgent = "coinmon (+https://github.com/ivanglie/coinmon)"
+
+// directProxy in Transport.Proxies connects to an exchange without a proxy
+const directProxy = "direct"
+
+// Transport represents settings of connections to exchanges
+type Transport struct {
+	MaxIdleConnsPerHost int           // 16 when zero
+	IdleConnTimeout     time.Duration // 90s when zero
+	TLSHandshakeTimeout time.Duration // 5s when zero
+	ForceAttemptHTTP2   bool
+	// DNSCacheTTL is how long addresses of exchange hosts are cached, not
+	// at all when zero
+	DNSCacheTTL time.Duration
+
+	// Proxy is the URL of an http, https or socks5 proxy for all exchanges,
+	// the proxy of the environment when empty
+	Proxy string
+	// Proxies are proxy URLs or "direct" by exchange name, overriding Proxy
+	Proxies map[string]string
+	// BaseURLs and Mirrors are the endpoints of exchanges by exchange name
+	// replacing their default ones, as set with WithExchangeBaseURL and
+	// WithExchangeMirrors, so Proxies apply to them
+	BaseURLs map[string]string
+	Mirrors  map[string][]string
+
+	// CAFile is a PEM bundle of CAs trusted next to the system ones, e.g. of
+	// a TLS-intercepting proxy
+	CAFile string
+	// CertFile and KeyFile are PEM files of a client certificate
+	CertFile string
+	KeyFile  string
+	// MinTLSVersion is "1.2" or "1.3", 1.2 when empty
+	MinTLSVersion string
+}
+
+// NewTransport returns a transport keeping connections to exchanges open
+// for reuse by all calls
+func NewTransport(cfg Transport) (*http.Transport, error) {
+	t := newTransport(cfg)
+
+	tlsCfg, err := upstreamTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	t.TLSClientConfig = tlsCfg
+
+	var global *url.URL
+	if cfg.Proxy != "" {
+		u, err := parseProxy(cfg.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		global = u
+	}
+
+	// Proxies by host of the REST and stream endpoints, nil for direct
+	byHost := make(map[string]*url.URL)
+	for name, raw := range cfg.Proxies {
+		n, err := exchange.ParseName(name)
+		if err != nil {
+			return nil, fmt.Errorf("parse proxy: %w", err)
+		}
+
+		var u *url.URL
+		if raw != directProxy {
+			if u, err = parseProxy(raw); err != nil {
+				return nil, err
+			}
+		}
+
+		ex, err := proxiedExchange(n, cfg)
+		if err != nil {
+			return nil, err
+		}
+		for _, endpoint := range append(ex.BaseURLs(), ex.StreamURL) {
+			if eu, perr := url.Parse(endpoint); perr == nil && eu.Host != "" {
+				byHost[eu.Hostname()] = u
+			}
+		}
+	}
+
+	if global == nil && len(byHost) == 0 {
+		return t, nil
+	}
+
+	t.Proxy = func(req *http.Request) (*url.URL, error) {
+		if u, ok := byHost[req.URL.Hostname()]; ok {
+			return u, nil
+		}
+		if global != nil {
+			return global, nil
+		}
+		return http.ProxyFromEnvironment(req)
+	}
+
+	return t, nil
+}
+
+// proxiedExchange returns exchange n with the base URL and mirrors of cfg
+func proxiedExchange(n exchange.Name, cfg Transport) (*exchange.Exchange, error) {
+	ex := exchange.New(n)
+	for name, baseURL := range cfg.BaseURLs {
+		if bn, err := exchange.ParseName(name); err != nil {
+			return nil, fmt.Errorf("parse base URL: %w", err)
+		} else if bn == n {
+			ex.BaseURL = strings.TrimSuffix(baseURL, "/")
+			ex.Mirrors = nil
+		}
+	}
+	for name, mirrors := range cfg.Mirrors {
+		if mn, err := exchange.ParseName(name); err != nil {
+			return nil, fmt.Errorf("parse mirrors: %w", err)
+		} else if mn == n {
+			ex.Mirrors = mirrors
+		}
+	}
+
+	return ex, nil
+}
+
+// newTransport returns a clone of the default transport with the connection
+// settings of cfg
+func newTransport(cfg Transport) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	t.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	if t.MaxIdleConnsPerHost == 0 {
+		t.MaxIdleConnsPerHost = 16
+	}
+	t.IdleConnTimeout = cfg.IdleConnTimeout
+	if t.IdleConnTimeout == 0 {
+		t.IdleConnTimeout = 90 * time.Second
+	}
+	t.TLSHandshakeTimeout = cfg.TLSHandshakeTimeout
+	if t.TLSHandshakeTimeout == 0 {
+		t.TLSHandshakeTimeout = 5 * time.Second
+	}
+	t.ForceAttemptHTTP2 = cfg.ForceAttemptHTTP2
+	if cfg.DNSCacheTTL > 0 {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		t.DialContext = newDNSCache(cfg.DNSCacheTTL).dialContext(dialer.DialContext)
+	}
+
+	return t
+}
+
+// upstreamTLSConfig returns TLS settings of connections to exchanges and
+// proxies
+func upstreamTLSConfig(cfg Transport) (*tls.Config, error) {
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	switch cfg.MinTLSVersion {
+	case "", "1.2":
+	case "1.3":
+		tlsCfg.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("parse min tls version: unsupported version %q", cfg.MinTLSVersion)
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read upstream ca: %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("parse upstream ca: no certificates found")
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load upstream client certificate: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsCfg, nil
+}
+
+// parseProxy returns the URL of an http, https or socks5 proxy
+func parseProxy(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parse proxy: %w", err)
+	}
+
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("parse proxy: unsupported scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("parse proxy: no host in %q", raw)
+	}
+
+	return u, nil
+}
+
+// WithTransport makes calls to exchanges with rt
+func WithTransport(rt http.RoundTripper) Option {
+	return func(s *Server) {
+		timeout := upstreamTimeout
+		if c, ok := s.client.(*http.Client); ok && c.Timeout > 0 {
+			timeout = c.Timeout
+		}
+		s.client = &http.Client{Timeout: timeout, Transport: rt}
+	}
+}
+
+// WithUpstreamTimeout bounds a call to an exchange by d instead of 5s
+func WithUpstreamTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		var rt http.RoundTripper
+		if c, ok := s.client.(*http.Client); ok {
+			rt = c.Transport
+		}
+		s.client = &http.Client{Timeout: d, Transport: rt}
+	}
+}
@@ -0,0 +1,228 @@
+package server
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTransport(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      Transport
+		expected Transport
+	}{
+		{
+			name:     "defaults",
+			expected: Transport{MaxIdleConnsPerHost: 16, IdleConnTimeout: 90 * time.Second, TLSHandshakeTimeout: 5 * time.Second},
+		},
+		{
+			name: "custom",
+			cfg: Transport{
+				MaxIdleConnsPerHost: 4,
+				IdleConnTimeout:     time.Minute,
+				TLSHandshakeTimeout: time.Second,
+				ForceAttemptHTTP2:   true,
+			},
+			expected: Transport{
+				MaxIdleConnsPerHost: 4,
+				IdleConnTimeout:     time.Minute,
+				TLSHandshakeTimeout: time.Second,
+				ForceAttemptHTTP2:   true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, err := NewTransport(tt.cfg)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, Transport{
+				MaxIdleConnsPerHost: tr.MaxIdleConnsPerHost,
+				IdleConnTimeout:     tr.IdleConnTimeout,
+				TLSHandshakeTimeout: tr.TLSHandshakeTimeout,
+				ForceAttemptHTTP2:   tr.ForceAttemptHTTP2,
+			})
+			assert.NotSame(t, http.DefaultTransport, tr)
+		})
+	}
+}
+
+func TestNewTransport_proxies(t *testing.T) {
+	tr, err := NewTransport(Transport{
+		Proxy: "http://proxy.example.com:3128",
+		Proxies: map[string]string{
+			"binance": "socks5://eu.example.com:1080",
+			"kraken":  "direct",
+		},
+	})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		url           string
+		expectedProxy string
+	}{
+		{url: "https://api.binance.com/api/v3/ticker/price", expectedProxy: "socks5://eu.example.com:1080"},
+		{url: "https://stream.binance.com:9443/ws", expectedProxy: "socks5://eu.example.com:1080"},
+		{url: "https://api.bybit.com/v5/market/tickers", expectedProxy: "http://proxy.example.com:3128"},
+		{url: "https://api.kraken.com/0/public/Ticker"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, http.NoBody)
+			u, perr := tr.Proxy(req)
+			assert.NoError(t, perr)
+			if tt.expectedProxy == "" {
+				assert.Nil(t, u)
+				return
+			}
+			assert.Equal(t, tt.expectedProxy, u.String())
+		})
+	}
+}
+
+func TestNewTransport_proxiesEndpoints(t *testing.T) {
+	tr, err := NewTransport(Transport{
+		Proxy:    "http://proxy.example.com:3128",
+		Proxies:  map[string]string{"binance": "socks5://eu.example.com:1080", "bybit": "direct"},
+		BaseURLs: map[string]string{"binance": "https://api.binance.us/"},
+		Mirrors:  map[string][]string{"binance": {"https://api1.binance.us"}, "bybit": {"https://api.bytick.com"}},
+	})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		url           string
+		expectedProxy string
+	}{
+		{url: "https://api.binance.us/api/v3/ticker/price", expectedProxy: "socks5://eu.example.com:1080"},
+		{url: "https://api1.binance.us/api/v3/ticker/price", expectedProxy: "socks5://eu.example.com:1080"},
+		{url: "https://api.bytick.com/v5/market/tickers"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			u, perr := tr.Proxy(httptest.NewRequest(http.MethodGet, tt.url, http.NoBody))
+			assert.NoError(t, perr)
+			if tt.expectedProxy == "" {
+				assert.Nil(t, u)
+				return
+			}
+			assert.Equal(t, tt.expectedProxy, u.String())
+		})
+	}
+}
+
+func TestNewTransport_invalidProxy(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Transport
+	}{
+		{name: "unsupported scheme", cfg: Transport{Proxy: "ftp://proxy.example.com"}},
+		{name: "no host", cfg: Transport{Proxy: "http://"}},
+		{name: "unknown exchange", cfg: Transport{Proxies: map[string]string{"mtgox": "http://proxy.example.com"}}},
+		{name: "invalid exchange proxy", cfg: Transport{Proxies: map[string]string{"bybit": "proxy.example.com"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewTransport(tt.cfg)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestWithTransport(t *testing.T) {
+	tr, err := NewTransport(Transport{})
+	assert.NoError(t, err)
+	s := &Server{}
+	WithTransport(tr)(s)
+
+	c, ok := s.client.(*http.Client)
+	assert.True(t, ok)
+	assert.Same(t, tr, c.Transport)
+	assert.Equal(t, upstreamTimeout, c.Timeout)
+
+	WithUpstreamTimeout(time.Second)(s)
+	c, ok = s.client.(*http.Client)
+	assert.True(t, ok)
+	assert.Same(t, tr, c.Transport)
+	assert.Equal(t, time.Second, c.Timeout)
+
+	// The timeout is kept when the transport is set afterwards
+	s = &Server{}
+	WithUpstreamTimeout(time.Second)(s)
+	WithTransport(tr)(s)
+	c, ok = s.client.(*http.Client)
+	assert.True(t, ok)
+	assert.Same(t, tr, c.Transport)
+	assert.Equal(t, time.Second, c.Timeout)
+}
+
+func TestNewTransport_CAFile(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	assert.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0o600))
+
+	tests := []struct {
+		name        string
+		cfg         Transport
+		expectedErr bool
+	}{
+		{name: "system CAs only", expectedErr: true},
+		{name: "CA bundle", cfg: Transport{CAFile: caFile}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, err := NewTransport(tt.cfg)
+			assert.NoError(t, err)
+			defer tr.CloseIdleConnections()
+
+			resp, err := (&http.Client{Transport: tr}).Get(ts.URL)
+			if tt.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			_ = resp.Body.Close()
+		})
+	}
+}
+
+func TestNewTransport_TLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, _ := writeCert(t, dir)
+	invalidCA := filepath.Join(dir, "invalid.pem")
+	assert.NoError(t, os.WriteFile(invalidCA, []byte("not a certificate"), 0o600))
+
+	tr, err := NewTransport(Transport{CertFile: certFile, KeyFile: keyFile, MinTLSVersion: "1.3"})
+	assert.NoError(t, err)
+	assert.Len(t, tr.TLSClientConfig.Certificates, 1)
+	assert.Equal(t, uint16(tls.VersionTLS13), tr.TLSClientConfig.MinVersion)
+
+	tr, err = NewTransport(Transport{})
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), tr.TLSClientConfig.MinVersion)
+
+	for name, cfg := range map[string]Transport{
+		"missing CA file":     {CAFile: filepath.Join(dir, "missing.pem")},
+		"invalid CA file":     {CAFile: invalidCA},
+		"missing key":         {CertFile: certFile},
+		"unsupported version": {MinTLSVersion: "1.1"},
+	} {
+		_, err = NewTransport(cfg)
+		assert.Error(t, err, name)
+	}
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/ivanglie/coinmon/pkg/aggregator"
+	"github.com/ivanglie/coinmon/pkg/log"
+)
+
+// WithUniswap prices pairs from the Uniswap v3 pools mapped to them by pool
+// id, e.g. {"PEPEETH": "0x11950d141ecb863f01007add7d1a342041227b58"}, queried
+// from its subgraph. Uniswap is only called for the mapped pairs.
+func WithUniswap(pools map[string]string) Option {
+	return func(s *Server) {
+		ex := exchange.New(exchange.UNISWAP)
+		s.exchanges = append(s.exchanges, ex)
+
+		f := &uniswapFetcher{s: s, ex: ex, pools: make(map[string]string, len(pools))}
+		for pair, pool := range pools {
+			f.pools[strings.ToUpper(pair)] = pool
+		}
+		WithFetcher(exchange.UNISWAP, f)(s)
+	}
+}
+
+// uniswapFetcher fetches prices of pairs from their Uniswap pools
+type uniswapFetcher struct {
+	s     *Server
+	ex    *exchange.Exchange
+	pools map[string]string
+}
+
+// Serves reports whether pair has a pool
+func (f *uniswapFetcher) Serves(pair string) bool {
+	_, ok := f.pools[pair]
+	return ok
+}
+
+func (f *uniswapFetcher) FetchPrice(ctx context.Context, pair string) (aggregator.Quote, error) {
+	pool, ok := f.pools[pair]
+	if !ok {
+		return aggregator.Quote{}, fmt.Errorf("no pool for %s", pair)
+	}
+
+	price, err := f.s.fetchPool(ctx, f.ex, pool, pair)
+	if err != nil {
+		return aggregator.Quote{}, err
+	}
+
+	return aggregator.Quote{Pair: pair, Price: price, Source: f.ex.Name.String(), Time: time.Now().UTC()}, nil
+}
+
+// fetchPool queries the price of pair from the pool of e, a subgraph
+func (s *Server) fetchPool(ctx context.Context, e *exchange.Exchange, pool, pair string) (price float64, err error) {
+	url := e.PriceURL(pair)
+
+	if err = s.limits.wait(ctx, e); err != nil {
+		return 0, fmt.Errorf("wait for rate limit: %w", err)
+	}
+
+	release, err := s.upstreamLimit.acquire(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("wait for upstream slot: %w", err)
+	}
+	defer release()
+
+	var (
+		status int
+		body   []byte
+	)
+	defer func(start time.Time) {
+		call := UpstreamCall{Time: start, Exchange: e.Name.String(), URL: url, Status: status, Body: string(body), DurationMs: float64(time.Since(start).Microseconds()) / 1000}
+		if err != nil {
+			call.Error = err.Error()
+		}
+		s.upstream.add(call)
+	}(time.Now())
+
+	log.FromContext(log.WithFields(ctx, log.Fields{"exchange": e.Name.String()})).Info(fmt.Sprintf("Requesting %s price for %s: pool %s", e.Name, pair, pool))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(exchange.UniswapPoolQuery(pool)))
+	if err != nil {
+		return 0, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		if ctx.Err() == nil {
+			return 0, transientError{fmt.Errorf("do request: %w", err)}
+		}
+		return 0, fmt.Errorf("do request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	s.limits.observe(e, resp)
+	status = resp.StatusCode
+	if body, err = io.ReadAll(io.LimitReader(resp.Body, maxResponseBody+1)); err != nil {
+		return 0, fmt.Errorf("read body: %w", err)
+	}
+	if len(body) > maxResponseBody {
+		return 0, fmt.Errorf("response exceeds %d bytes", maxResponseBody)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		statusErr := &exchange.StatusError{StatusCode: resp.StatusCode, Body: string(body)}
+		if rateLimited(resp) {
+			return 0, backoffError{statusErr}
+		}
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return 0, transientError{statusErr}
+		}
+		return 0, statusErr
+	}
+
+	return exchange.ParseUniswapPrice(body, pair)
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_Uniswap(t *testing.T) {
+	const pool = "0x11950d141ecb863f01007add7d1a342041227b58"
+
+	var (
+		mu      sync.Mutex
+		queries []string
+	)
+	s := &Server{
+		events:    bus.New(),
+		exchanges: []*exchange.Exchange{exchange.New(exchange.BINANCE)},
+		client: &mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+			if req.URL.Host != "gateway.thegraph.com" {
+				if strings.Contains(req.URL.RawQuery, "PEPE") {
+					return mockErrorResponse(req)
+				}
+				return mockSuccessfulResponse(req)
+			}
+
+			body, _ := io.ReadAll(req.Body)
+			mu.Lock()
+			queries = append(queries, req.Method+" "+req.Header.Get("Authorization")+" "+string(body))
+			mu.Unlock()
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(
+				`{"data":{"pool":{"token0":{"symbol":"PEPE"},"token1":{"symbol":"WETH"},"token0Price":"250000000","token1Price":"0.000000004"}}}`,
+			))}, nil
+		}},
+	}
+	WithUniswap(map[string]string{"pepeeth": pool})(s)
+	WithExchangeHeaders(exchange.UNISWAP, map[string]string{"Authorization": "Bearer key"})(s)
+
+	price, source, err := s.price(context.Background(), "PEPEETH")
+	assert.NoError(t, err)
+	assert.Equal(t, "uniswap", source)
+	assert.InDelta(t, 0.000000004, price, 1e-18)
+	if assert.Len(t, queries, 1) {
+		assert.True(t, strings.HasPrefix(queries[0], "POST Bearer key {"))
+		assert.Contains(t, queries[0], pool)
+	}
+
+	// Pairs without a pool are not asked for
+	price, source, err = s.price(context.Background(), "BTCUSDT")
+	assert.NoError(t, err)
+	assert.Equal(t, "binance", source)
+	assert.Equal(t, 99999.99, price)
+	assert.Len(t, queries, 1)
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ivanglie/coinmon/pkg/log"
+)
+
+const (
+	upstreamLogSize = 100
+	upstreamBodyMax = 512
+)
+
+// UpstreamCall represents a summary of an exchange API call
+type UpstreamCall struct {
+	Time       time.Time `json:"time"`
+	Exchange   string    `json:"exchange"`
+	URL        string    `json:"url"`
+	Status     int       `json:"status,omitempty"`
+	Body       string    `json:"body,omitempty"`
+	DurationMs float64   `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// bodyReader reads the body of a response, keeping its head for the upstream
+// log and the error reading it
+type bodyReader struct {
+	r    io.Reader
+	head []byte // one byte over upstreamBodyMax at most, telling it was cut
+	err  error
+}
+
+func (b *bodyReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	b.head = append(b.head, p[:min(n, upstreamBodyMax+1-len(b.head))]...)
+	if err != nil && !errors.Is(err, io.EOF) {
+		b.err = err
+	}
+
+	return n, err
+}
+
+// upstreamLog keeps the most recent exchange API calls
+type upstreamLog struct {
+	mu    sync.Mutex
+	calls []UpstreamCall
+	next  int
+}
+
+func (l *upstreamLog) add(c UpstreamCall) {
+	if len(c.Body) > upstreamBodyMax {
+		// Cut on a rune boundary to keep the body valid UTF-8
+		n := upstreamBodyMax
+		for n > 0 && !utf8.RuneStart(c.Body[n]) {
+			n--
+		}
+		c.Body = c.Body[:n] + "..."
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.calls) < upstreamLogSize {
+		l.calls = append(l.calls, c)
+		return
+	}
+	l.calls[l.next] = c
+	l.next = (l.next + 1) % upstreamLogSize
+}
+
+// recent returns calls of exchange, or of every exchange when it is empty,
+// newest first
+func (l *upstreamLog) recent(exchange string) []UpstreamCall {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	calls := make([]UpstreamCall, 0, len(l.calls))
+	for i := range len(l.calls) {
+		c := l.calls[(l.next-1-i+2*len(l.calls))%len(l.calls)]
+		if exchange == "" || c.Exchange == exchange {
+			calls = append(calls, c)
+		}
+	}
+
+	return calls
+}
+
+// HandleUpstream handles /debug/upstream requests
+func (s *Server) HandleUpstream(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.upstream.recent(r.URL.Query().Get("exchange"))); err != nil {
+		log.Error("Failed to encode response: " + err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
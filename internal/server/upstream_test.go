@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpstreamLog(t *testing.T) {
+	var l upstreamLog
+	assert.Empty(t, l.recent(""))
+
+	for i := range upstreamLogSize + 5 {
+		ex := "binance"
+		if i%2 == 1 {
+			ex = "bybit"
+		}
+		l.add(UpstreamCall{Exchange: ex, URL: fmt.Sprint(i)})
+	}
+
+	calls := l.recent("")
+	assert.Len(t, calls, upstreamLogSize)
+	assert.Equal(t, fmt.Sprint(upstreamLogSize+4), calls[0].URL)
+	assert.Equal(t, "5", calls[len(calls)-1].URL)
+
+	bybit := l.recent("bybit")
+	assert.Len(t, bybit, upstreamLogSize/2)
+	assert.Equal(t, fmt.Sprint(upstreamLogSize+3), bybit[0].URL)
+}
+
+func TestUpstreamLog_truncate(t *testing.T) {
+	var l upstreamLog
+	l.add(UpstreamCall{Body: strings.Repeat("é", upstreamBodyMax)})
+
+	body := l.recent("")[0].Body
+	assert.True(t, utf8.ValidString(body))
+	assert.LessOrEqual(t, len(body), upstreamBodyMax+len("..."))
+	assert.True(t, strings.HasSuffix(body, "..."))
+}
+
+func TestServer_HandleUpstream(t *testing.T) {
+	s := &Server{
+		exchanges: exchanges[:2],
+		client:    &mockHTTPClient{doFunc: mockErrorResponse},
+	}
+	ctx := context.Background()
+	_, _ = s.fetchPrice(ctx, exchanges[0], "BTCUSDT")
+	_, _ = s.fetchPrice(ctx, exchanges[1], "BTCUSDT")
+
+	tests := []struct {
+		name           string
+		method         string
+		query          string
+		expectedStatus int
+		expectedCalls  []string
+	}{
+		{
+			name:           "all exchanges",
+			method:         http.MethodGet,
+			expectedStatus: http.StatusOK,
+			expectedCalls:  []string{"bybit", "binance"},
+		},
+		{
+			name:           "one exchange",
+			method:         http.MethodGet,
+			query:          "?exchange=binance",
+			expectedStatus: http.StatusOK,
+			expectedCalls:  []string{"binance"},
+		},
+		{
+			name:           "method not allowed",
+			method:         http.MethodPost,
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/debug/upstream"+tt.query, http.NoBody)
+			w := httptest.NewRecorder()
+			s.routes().ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var calls []UpstreamCall
+			assert.NoError(t, json.NewDecoder(w.Body).Decode(&calls))
+
+			var names []string
+			for _, c := range calls {
+				names = append(names, c.Exchange)
+				assert.Equal(t, http.StatusBadRequest, c.Status)
+				assert.NotEmpty(t, c.Body)
+				assert.Equal(t, "code=400, msg=Bad Request", c.Error)
+				assert.Contains(t, c.URL, "BTCUSDT")
+			}
+			assert.Equal(t, tt.expectedCalls, names)
+		})
+	}
+}
+
+func TestBodyReader(t *testing.T) {
+	body := strings.Repeat("x", 2*upstreamBodyMax)
+	br := &bodyReader{r: iotest.HalfReader(strings.NewReader(body))}
+
+	b, err := io.ReadAll(br)
+	assert.NoError(t, err)
+	assert.Equal(t, body, string(b))
+	assert.Equal(t, body[:upstreamBodyMax+1], string(br.head))
+	assert.NoError(t, br.err)
+
+	errRead := errors.New("connection reset")
+	br = &bodyReader{r: iotest.ErrReader(errRead)}
+	_, err = io.ReadAll(br)
+	assert.ErrorIs(t, err, errRead)
+	assert.ErrorIs(t, br.err, errRead)
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+var errUpstreamBusy = errors.New("too many exchange calls in flight")
+
+// upstreamLimiter bounds the number of concurrent exchange calls
+type upstreamLimiter struct {
+	slots  chan struct{}
+	wait   time.Duration
+	queued atomic.Int64
+}
+
+// WithUpstreamLimit allows at most n exchange calls in flight. Further calls
+// queue for a free slot for up to wait, or as long as their request when
+// wait is zero, and fail once it elapses.
+func WithUpstreamLimit(n int, wait time.Duration) Option {
+	return func(s *Server) {
+		s.upstreamLimit = &upstreamLimiter{slots: make(chan struct{}, n), wait: wait}
+	}
+}
+
+// acquire waits for a free slot. The returned func releases it.
+func (l *upstreamLimiter) acquire(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	release := func() { <-l.slots }
+	select {
+	case l.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	l.queued.Add(1)
+	defer l.queued.Add(-1)
+
+	var timeout <-chan time.Time
+	if l.wait > 0 {
+		t := time.NewTimer(l.wait)
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return release, nil
+	case <-timeout:
+		return nil, errUpstreamBusy
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// queuedCalls returns the number of calls waiting for a slot
+func (l *upstreamLimiter) queuedCalls() int64 {
+	if l == nil {
+		return 0
+	}
+	return l.queued.Load()
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpstreamLimiter_acquire(t *testing.T) {
+	s := &Server{}
+	WithUpstreamLimit(2, 50*time.Millisecond)(s)
+	l := s.upstreamLimit
+
+	release1, err := l.acquire(context.Background())
+	assert.NoError(t, err)
+	release2, err := l.acquire(context.Background())
+	assert.NoError(t, err)
+
+	// The third call times out waiting for a slot
+	_, err = l.acquire(context.Background())
+	assert.ErrorIs(t, err, errUpstreamBusy)
+
+	// ...unless a slot is released meanwhile
+	done := make(chan error)
+	go func() {
+		release, aerr := l.acquire(context.Background())
+		if aerr == nil {
+			release()
+		}
+		done <- aerr
+	}()
+	assert.Eventually(t, func() bool { return l.queuedCalls() == 1 }, time.Second, time.Millisecond)
+	release1()
+	assert.NoError(t, <-done)
+	assert.Zero(t, l.queuedCalls())
+
+	// Waiting ends with the request
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	WithUpstreamLimit(0, 0)(s)
+	_, err = s.upstreamLimit.acquire(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	release2()
+}
+
+func TestUpstreamLimiter_nil(t *testing.T) {
+	var l *upstreamLimiter
+	release, err := l.acquire(context.Background())
+	assert.NoError(t, err)
+	release()
+	assert.Zero(t, l.queuedCalls())
+}
+
+func TestServer_fetchPrice_upstreamBusy(t *testing.T) {
+	s, _ := fakeExchanges(t)
+	WithUpstreamLimit(1, time.Millisecond)(s)
+	release, err := s.upstreamLimit.acquire(context.Background())
+	assert.NoError(t, err)
+	defer release()
+
+	_, err = s.fetchPrice(context.Background(), exchanges[0], "BTCUSDT")
+	assert.ErrorIs(t, err, errUpstreamBusy)
+
+	s.health.record(context.Background(), exchanges[0].Name.String(), err)
+	assert.Equal(t, "unknown", s.health.get(exchanges[0].Name.String()).status())
+}
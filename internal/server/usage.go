@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/ivanglie/coinmon/internal/usage"
+	"github.com/ivanglie/coinmon/pkg/log"
+)
+
+// UsageResponse represents the usage of the consumer of a request
+type UsageResponse struct {
+	Consumer string `json:"consumer"`
+	usage.Usage
+}
+
+// WithUsage accounts the requests to API routes in t, by the name of their
+// API key or their IP. API key consumers get their usage at /api/v1/usage,
+// and admins everyone's at /api/v1/admin/usage.
+func WithUsage(t *usage.Tracker) Option {
+	return func(s *Server) {
+		s.usage = t
+	}
+}
+
+// trackUsage accounts every request to next, including rejected ones
+func (s *Server) trackUsage(next http.HandlerFunc) http.HandlerFunc {
+	if s.usage == nil {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		entry, ok := r.Context().Value(accessKey{}).(*accessEntry)
+		if !ok {
+			entry = &accessEntry{}
+			r = r.WithContext(context.WithValue(r.Context(), accessKey{}, entry))
+		}
+		aw := &accessWriter{ResponseWriter: w}
+
+		next(aw, r)
+
+		name, pairs := entry.requester()
+		s.usage.Record(usageConsumer(r, name), pairs, aw.statusCode(r), aw.bytes)
+	}
+}
+
+// usageConsumer returns the consumer r is accounted to, the name of its API
+// key or its IP
+func usageConsumer(r *http.Request, name string) string {
+	if name != "" {
+		return "key:" + name
+	}
+
+	return "ip:" + clientIP(r)
+}
+
+// HandleUsage handles /api/v1/usage requests with the usage of the API key
+// consumer of the request. The usage of IPs is for admins only, as the IP of
+// a request can be claimed with headers.
+func (s *Server) HandleUsage(w http.ResponseWriter, r *http.Request) {
+	name := consumer(r.Context())
+	if name == "" {
+		http.Error(w, errAPIKeyRequired.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	id := usageConsumer(r, name)
+	u, _ := s.usage.Get(id)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(UsageResponse{Consumer: id, Usage: u}); err != nil {
+		log.Error("Failed to encode response: " + err.Error())
+	}
+}
+
+// HandleAdminUsage handles /api/v1/admin/usage requests with the usage of
+// every consumer by consumer
+func (s *Server) HandleAdminUsage(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.usage.All()); err != nil {
+		log.Error("Failed to encode response: " + err.Error())
+	}
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/ivanglie/coinmon/internal/usage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_Usage(t *testing.T) {
+	tracker := usage.New()
+	s := &Server{
+		events:    bus.New(),
+		exchanges: exchanges[:1],
+		fetchers:  mockFetchers(),
+	}
+	WithUsage(tracker)(s)
+	WithAPIKeys([]APIKey{{Name: "partner", Key: "secret"}}, false)(s)
+	WithBasicAuth("admin", "password")(s)
+	WithPairPolicy(nil, []string{"LUNA*"})(s)
+	h := s.handler()
+
+	requests := []struct {
+		path   string
+		apiKey string
+	}{
+		{path: "/api/v1/spot/BTCUSDT"},
+		{path: "/api/v2/spot?pairs=BTCUSDT,ETHUSDT"},
+		{path: "/api/v1/spot/BTCUSDT", apiKey: "secret"},
+		{path: "/api/v1/spot/BTCUSDT", apiKey: "wrong"},
+		// Forbidden pairs are not accounted as pairs
+		{path: "/api/v1/spot/LUNAUSDT"},
+		// Routes outside the API are not accounted
+		{path: "/healthz"},
+	}
+	for _, req := range requests {
+		r := httptest.NewRequest(http.MethodGet, req.path, http.NoBody)
+		if req.apiKey != "" {
+			r.Header.Set("X-API-Key", req.apiKey)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	u, ok := tracker.Get("ip:192.0.2.1")
+	assert.True(t, ok)
+	assert.Equal(t, int64(4), u.Requests)
+	assert.Equal(t, map[int]int64{http.StatusOK: 2, http.StatusUnauthorized: 1, http.StatusForbidden: 1}, u.Statuses)
+	assert.Equal(t, map[string]int64{"BTCUSDT": 2, "ETHUSDT": 1}, u.Pairs)
+	assert.Positive(t, u.Bytes)
+
+	u, ok = tracker.Get("key:partner")
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), u.Requests)
+	assert.Equal(t, map[string]int64{"BTCUSDT": 1}, u.Pairs)
+
+	// Consumers get their own usage
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/usage", http.NoBody)
+	r.Header.Set("X-API-Key", "secret")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp UsageResponse
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, "key:partner", resp.Consumer)
+	assert.Equal(t, int64(1), resp.Requests)
+
+	// Anonymous consumers don't get the usage of their IP, which anyone can
+	// claim
+	r = httptest.NewRequest(http.MethodGet, "/api/v1/usage", http.NoBody)
+	r.Header.Set("Cf-Connecting-Ip", "192.0.2.1")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.NotContains(t, w.Body.String(), "BTCUSDT")
+
+	// Admins get everyone's
+	r = httptest.NewRequest(http.MethodGet, "/api/v1/admin/usage", http.NoBody)
+	r.SetBasicAuth("admin", "password")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var all map[string]usage.Usage
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&all))
+	assert.Len(t, all, 2)
+	assert.Equal(t, int64(5), all["ip:192.0.2.1"].Requests)
+	assert.Equal(t, int64(2), all["key:partner"].Requests)
+}
+
+func TestServer_Usage_Disabled(t *testing.T) {
+	s := &Server{}
+
+	w := httptest.NewRecorder()
+	s.routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/usage", http.NoBody))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,257 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ivanglie/coinmon/pkg/aggregator"
+	"github.com/ivanglie/coinmon/pkg/log"
+)
+
+// Envelope represents every /api/v2 response: the data of the request, its
+// metadata, and the errors preventing the whole or parts of the data
+type Envelope struct {
+	Data   any        `json:"data"`
+	Meta   Meta       `json:"meta"`
+	Errors []APIError `json:"errors"`
+}
+
+// Meta represents the metadata of a v2 response
+type Meta struct {
+	RequestID string    `json:"request_id,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// APIError represents an error of a v2 response. Source is the exchange or
+// pair the error is about, if any.
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Source  string `json:"source,omitempty"`
+}
+
+// Quote represents a price in v2 responses. Price is a decimal string, so
+// clients parsing JSON numbers as floats do not lose precision.
+type Quote struct {
+	Pair   string    `json:"pair"`
+	Price  string    `json:"price"`
+	Source string    `json:"source"`
+	Time   time.Time `json:"time"`
+	// Stale is set when the exchanges did not answer within the ?timeout of
+	// the request and the latest price resolved before is returned
+	Stale bool `json:"stale,omitempty"`
+}
+
+// ExchangeV2 represents an exchange prices are resolved from in v2 responses
+type ExchangeV2 struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	Streaming bool   `json:"streaming"`
+}
+
+// v2 error codes
+const (
+	codeBadRequest       = "bad_request"
+	codeNotFound         = "not_found"
+	codeMethodNotAllowed = "method_not_allowed"
+	codeDeadline         = "deadline_exceeded"
+	codeUnavailable      = "exchanges_unavailable"
+	codeInternal         = "internal"
+	codeForbidden        = "forbidden"
+)
+
+// v2Routes returns the mux of the /api/v2 routes, answering requests to
+// other routes with v2 errors:
+//   - GET /api/v2/spot/{pair} returns the quote of a pair
+//   - GET /api/v2/spot?pairs=A,B returns the quotes of up to 20 pairs
+//   - GET /api/v2/exchanges lists the exchanges prices are resolved from
+func (s *Server) v2Routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v2/spot", s.spotBatchV2)
+	mux.HandleFunc("GET /api/v2/spot/{pair}", s.spotV2)
+	mux.HandleFunc("GET /api/v2/exchanges", s.exchangesV2)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			writeEnvelope(w, r, http.StatusMethodNotAllowed, nil, APIError{Code: codeMethodNotAllowed, Message: "method not allowed"})
+			return
+		}
+		writeEnvelope(w, r, http.StatusNotFound, nil, APIError{Code: codeNotFound, Message: "no such resource"})
+	})
+
+	return mux
+}
+
+func (s *Server) spotV2(w http.ResponseWriter, r *http.Request) {
+	pair := strings.ToUpper(r.PathValue("pair"))
+	if err := s.checkPair(pair); err != nil {
+		writeEnvelope(w, r, http.StatusForbidden, nil, APIError{Code: codeForbidden, Message: err.Error(), Source: pair})
+		return
+	}
+
+	fields, err := parseFields[Quote](r)
+	if err != nil {
+		writeEnvelope(w, r, http.StatusBadRequest, nil, APIError{Code: codeBadRequest, Message: err.Error()})
+		return
+	}
+	timeout, err := s.requestTimeout(r)
+	if err != nil {
+		writeEnvelope(w, r, http.StatusBadRequest, nil, APIError{Code: codeBadRequest, Message: err.Error()})
+		return
+	}
+
+	q, errs := s.quoteV2(r, pair, timeout)
+	if errs != nil {
+		writeEnvelope(w, r, http.StatusServiceUnavailable, nil, errs...)
+		return
+	}
+
+	s.setCacheHeaders(w, q.Time, q.Stale)
+	writeSelected(w, r, http.StatusOK, fields, q)
+}
+
+func (s *Server) spotBatchV2(w http.ResponseWriter, r *http.Request) {
+	var pairs []string
+	for p := range strings.SplitSeq(r.URL.Query().Get("pairs"), ",") {
+		if p = strings.ToUpper(strings.TrimSpace(p)); p != "" && !slices.Contains(pairs, p) {
+			pairs = append(pairs, p)
+		}
+	}
+	switch {
+	case len(pairs) == 0:
+		writeEnvelope(w, r, http.StatusBadRequest, nil, APIError{Code: codeBadRequest, Message: "missing trading pairs"})
+		return
+	case len(pairs) > maxBatchPairs:
+		writeEnvelope(w, r, http.StatusBadRequest, nil, APIError{Code: codeBadRequest, Message: "too many trading pairs, max " + strconv.Itoa(maxBatchPairs)})
+		return
+	}
+	fields, err := parseFields[Quote](r)
+	if err != nil {
+		writeEnvelope(w, r, http.StatusBadRequest, nil, APIError{Code: codeBadRequest, Message: err.Error()})
+		return
+	}
+	timeout, err := s.requestTimeout(r)
+	if err != nil {
+		writeEnvelope(w, r, http.StatusBadRequest, nil, APIError{Code: codeBadRequest, Message: err.Error()})
+		return
+	}
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		quotes = make([]*Quote, len(pairs))
+		errs   []APIError
+	)
+	for i, pair := range pairs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q, qErrs := s.quoteV2(r, pair, timeout)
+
+			mu.Lock()
+			defer mu.Unlock()
+			quotes[i] = q
+			errs = append(errs, qErrs...)
+		}()
+	}
+	wg.Wait()
+
+	// Quotes keep the order of the requested pairs, failed ones are dropped
+	data := make([]Quote, 0, len(quotes))
+	for _, q := range quotes {
+		if q != nil {
+			data = append(data, *q)
+		}
+	}
+
+	status := http.StatusOK
+	if len(data) == 0 {
+		status = http.StatusServiceUnavailable
+	}
+	writeSelected(w, r, status, fields, data, errs...)
+}
+
+// quoteV2 resolves the price of pair within timeout, or returns the errors
+// of the exchanges about it
+func (s *Server) quoteV2(r *http.Request, pair string, timeout time.Duration) (*Quote, []APIError) {
+	e, stale, err := s.quote(r.Context(), pair, timeout)
+	if err != nil {
+		if errors.Is(err, ErrPairForbidden) {
+			return nil, []APIError{{Code: codeForbidden, Message: err.Error(), Source: pair}}
+		}
+
+		var exErr *aggregator.Error
+		if !errors.As(err, &exErr) {
+			return nil, []APIError{{Code: codeUnavailable, Message: err.Error(), Source: pair}}
+		}
+
+		errs := make([]APIError, 0, len(exErr.Errors))
+		for _, e := range exErr.Errors {
+			name, msg, _ := strings.Cut(e, ": ")
+			errs = append(errs, APIError{Code: codeUnavailable, Message: pair + ": " + msg, Source: name})
+		}
+		for _, name := range exErr.Pending {
+			errs = append(errs, APIError{Code: codeDeadline, Message: pair + ": no answer before the deadline", Source: name})
+		}
+		if len(errs) == 0 {
+			errs = append(errs, APIError{Code: codeUnavailable, Message: exErr.Message, Source: pair})
+		}
+		return nil, errs
+	}
+
+	return &Quote{Pair: pair, Price: strconv.FormatFloat(e.Price, 'f', -1, 64), Source: e.Source, Time: e.Time, Stale: stale}, nil
+}
+
+func (s *Server) exchangesV2(w http.ResponseWriter, r *http.Request) {
+	active := s.activeExchanges()
+	data := make([]ExchangeV2, 0, len(active))
+	for _, ex := range active {
+		data = append(data, ExchangeV2{
+			Name:      ex.Name.String(),
+			Status:    s.exchangeStatus(ex.Name),
+			Streaming: ex.StreamURL != "",
+		})
+	}
+
+	writeEnvelope(w, r, http.StatusOK, data)
+}
+
+// writeSelected writes the selected fields of data and errs in the v2
+// envelope with status
+func writeSelected(w http.ResponseWriter, r *http.Request, status int, fields fieldSelection, data any, errs ...APIError) {
+	selected, err := fields.apply(data)
+	if err != nil {
+		log.Error("Failed to select fields: " + err.Error())
+		writeEnvelope(w, r, http.StatusInternalServerError, nil, APIError{Code: codeInternal, Message: "internal server error"})
+		return
+	}
+
+	writeEnvelope(w, r, status, selected, errs...)
+}
+
+// writeEnvelope writes data and errs in the v2 envelope with status
+func writeEnvelope(w http.ResponseWriter, r *http.Request, status int, data any, errs ...APIError) {
+	if errs == nil {
+		errs = []APIError{}
+	}
+
+	env := Envelope{
+		Data:   data,
+		Meta:   Meta{RequestID: w.Header().Get("X-Request-Id"), Time: time.Now().UTC()},
+		Errors: errs,
+	}
+	if env.Meta.RequestID == "" {
+		env.Meta.RequestID = r.Header.Get("X-Request-Id")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(env); err != nil {
+		log.Error("Failed to encode response: " + err.Error())
+	}
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_HandleV2(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		fetchers       map[exchange.Name]Fetcher
+		expectedStatus int
+		expectedData   string
+		expectedErrors []APIError
+	}{
+		{
+			name:           "spot",
+			method:         http.MethodGet,
+			path:           "/api/v2/spot/btcusdt",
+			fetchers:       mockFetchers(),
+			expectedStatus: http.StatusOK,
+			expectedData:   `{"pair":"BTCUSDT","price":"99999.99","source":"binance"}`,
+		},
+		{
+			name:           "spot failed",
+			method:         http.MethodGet,
+			path:           "/api/v2/spot/INVALID",
+			fetchers:       mockPairErrorFetchers(-1121, "Invalid symbol."),
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedData:   `null`,
+			expectedErrors: []APIError{{Code: codeUnavailable, Message: "INVALID: code=-1121, msg=Invalid symbol.", Source: "binance"}},
+		},
+		{
+			name:   "batch",
+			method: http.MethodGet,
+			path:   "/api/v2/spot?pairs=BTCUSDT,invalid,BTCUSDT",
+			fetchers: mockPairFetchers(map[string]map[exchange.Name]Fetcher{
+				"INVALID": mockPairErrorFetchers(-1121, "Invalid symbol."),
+			}),
+			expectedStatus: http.StatusOK,
+			expectedData:   `[{"pair":"BTCUSDT","price":"99999.99","source":"binance"}]`,
+			expectedErrors: []APIError{{Code: codeUnavailable, Message: "INVALID: code=-1121, msg=Invalid symbol.", Source: "binance"}},
+		},
+		{
+			name:           "spot fields",
+			method:         http.MethodGet,
+			path:           "/api/v2/spot/btcusdt?fields=price,source",
+			fetchers:       mockFetchers(),
+			expectedStatus: http.StatusOK,
+			expectedData:   `{"price":"99999.99","source":"binance"}`,
+		},
+		{
+			name:           "batch fields",
+			method:         http.MethodGet,
+			path:           "/api/v2/spot?pairs=BTCUSDT&fields=pair,price",
+			fetchers:       mockFetchers(),
+			expectedStatus: http.StatusOK,
+			expectedData:   `[{"pair":"BTCUSDT","price":"99999.99"}]`,
+		},
+		{
+			name:           "unknown field",
+			method:         http.MethodGet,
+			path:           "/api/v2/spot/btcusdt?fields=price,latency",
+			expectedStatus: http.StatusBadRequest,
+			expectedData:   `null`,
+			expectedErrors: []APIError{{Code: codeBadRequest, Message: `unknown field "latency", expected one of pair, price, source, time, stale`}},
+		},
+		{
+			name:           "batch without pairs",
+			method:         http.MethodGet,
+			path:           "/api/v2/spot?pairs=,",
+			expectedStatus: http.StatusBadRequest,
+			expectedData:   `null`,
+			expectedErrors: []APIError{{Code: codeBadRequest, Message: "missing trading pairs"}},
+		},
+		{
+			name:           "exchanges",
+			method:         http.MethodGet,
+			path:           "/api/v2/exchanges",
+			expectedStatus: http.StatusOK,
+			expectedData:   `[{"name":"binance","status":"unknown","streaming":true}]`,
+		},
+		{
+			name:           "unknown resource",
+			method:         http.MethodGet,
+			path:           "/api/v2/spot/BTCUSDT/next",
+			expectedStatus: http.StatusNotFound,
+			expectedData:   `null`,
+			expectedErrors: []APIError{{Code: codeNotFound, Message: "no such resource"}},
+		},
+		{
+			name:           "method not allowed",
+			method:         http.MethodPost,
+			path:           "/api/v2/exchanges",
+			expectedStatus: http.StatusMethodNotAllowed,
+			expectedData:   `null`,
+			expectedErrors: []APIError{{Code: codeMethodNotAllowed, Message: "method not allowed"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{exchanges: exchanges[:1], fetchers: tt.fetchers, events: bus.New()}
+
+			rec := httptest.NewRecorder()
+			rec.Header().Set("X-Request-Id", "req-1")
+			s.routes().ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, http.NoBody))
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+			var env struct {
+				Data   json.RawMessage `json:"data"`
+				Meta   Meta            `json:"meta"`
+				Errors []APIError      `json:"errors"`
+			}
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&env))
+			assert.Equal(t, "req-1", env.Meta.RequestID)
+			assert.False(t, env.Meta.Time.IsZero())
+			if tt.expectedErrors == nil {
+				tt.expectedErrors = []APIError{}
+			}
+			assert.Equal(t, tt.expectedErrors, env.Errors)
+
+			// Quote times vary, compare the rest of the data
+			var data any
+			assert.NoError(t, json.Unmarshal(env.Data, &data))
+			stripTimes(data)
+			actual, _ := json.Marshal(data)
+			assert.JSONEq(t, tt.expectedData, string(actual))
+		})
+	}
+}
+
+// stripTimes removes the time fields of decoded quotes
+func stripTimes(v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		delete(v, "time")
+	case []any:
+		for _, e := range v {
+			stripTimes(e)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/ivanglie/coinmon/internal/watchlist"
+	"github.com/ivanglie/coinmon/pkg/log"
+)
+
+type consumerKey struct{}
+
+// WatchlistResponse represents the pairs on a watchlist
+type WatchlistResponse struct {
+	Pairs []string `json:"pairs"`
+}
+
+// watchedPair represents a pair on the dashboard with its latest price
+type watchedPair struct {
+	Pair   string
+	Price  float64
+	Source string
+	Known  bool
+}
+
+// WithWatchlist serves the watchlists of API key consumers at
+// /api/v1/watchlist and shows watched pairs on the dashboard
+func WithWatchlist(w *watchlist.Watchlist) Option {
+	return func(s *Server) {
+		s.watchlist = w
+	}
+}
+
+// consumer returns the name of the API key of the request, empty for
+// anonymous requests
+func consumer(ctx context.Context) string {
+	name, _ := ctx.Value(consumerKey{}).(string)
+	return name
+}
+
+// HandleWatchlist handles /api/v1/watchlist requests of API key consumers:
+// GET lists the pairs, PUT replaces them and POST adds to them with a JSON
+// body of pairs, and DELETE /api/v1/watchlist/{pair} removes a pair
+func (s *Server) HandleWatchlist(w http.ResponseWriter, r *http.Request) {
+	owner := consumer(r.Context())
+	if owner == "" {
+		http.Error(w, errAPIKeyRequired.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var (
+		pairs []string
+		err   error
+	)
+	switch r.Method {
+	case http.MethodGet:
+		pairs = s.watchlist.Get(owner)
+	case http.MethodPut, http.MethodPost:
+		var req WatchlistResponse
+		if err = json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if r.Method == http.MethodPut {
+			pairs, err = s.watchlist.Set(owner, req.Pairs)
+		} else {
+			pairs, err = s.watchlist.Add(owner, req.Pairs...)
+		}
+	case http.MethodDelete:
+		pairs, err = s.watchlist.Remove(owner, r.PathValue("pair"))
+	}
+
+	if errors.Is(err, watchlist.ErrInvalidPair) || errors.Is(err, watchlist.ErrTooManyPairs) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Error("Failed to update watchlist: " + err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if pairs == nil {
+		pairs = []string{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err = json.NewEncoder(w).Encode(WatchlistResponse{Pairs: pairs}); err != nil {
+		log.Error("Failed to encode response: " + err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// watchedPairs returns the pairs watched by the consumer of the API key of
+// the request. Watchlists are private, so requests without a key get none
+// and see the pairs of the dashboard only.
+func (s *Server) watchedPairs(r *http.Request) []string {
+	if s.watchlist == nil {
+		return nil
+	}
+
+	if key, err := s.apiKeys.identify(r); err == nil && key != nil {
+		return s.watchlist.Get(key.name)
+	}
+
+	return nil
+}
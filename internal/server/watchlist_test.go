@@ -0,0 +1,183 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/ivanglie/coinmon/internal/watchlist"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_HandleWatchlist(t *testing.T) {
+	s := &Server{}
+	WithAPIKeys([]APIKey{{Name: "a", Key: "secret-a"}, {Name: "b", Key: "secret-b"}}, false)(s)
+	WithWatchlist(watchlist.New())(s)
+	routes := s.routes()
+
+	tests := []struct {
+		name           string
+		key            string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+		expectedPairs  []string
+	}{
+		{
+			name:           "anonymous",
+			method:         http.MethodGet,
+			path:           "/api/v1/watchlist",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "empty",
+			key:            "secret-a",
+			method:         http.MethodGet,
+			path:           "/api/v1/watchlist",
+			expectedStatus: http.StatusOK,
+			expectedPairs:  []string{},
+		},
+		{
+			name:           "replace",
+			key:            "secret-a",
+			method:         http.MethodPut,
+			path:           "/api/v1/watchlist",
+			body:           `{"pairs":["btcusdt","ETHUSDT"]}`,
+			expectedStatus: http.StatusOK,
+			expectedPairs:  []string{"BTCUSDT", "ETHUSDT"},
+		},
+		{
+			name:           "add",
+			key:            "secret-a",
+			method:         http.MethodPost,
+			path:           "/api/v1/watchlist",
+			body:           `{"pairs":["SOLUSDT"]}`,
+			expectedStatus: http.StatusOK,
+			expectedPairs:  []string{"BTCUSDT", "ETHUSDT", "SOLUSDT"},
+		},
+		{
+			name:           "remove",
+			key:            "secret-a",
+			method:         http.MethodDelete,
+			path:           "/api/v1/watchlist/ethusdt",
+			expectedStatus: http.StatusOK,
+			expectedPairs:  []string{"BTCUSDT", "SOLUSDT"},
+		},
+		{
+			name:           "other consumer",
+			key:            "secret-b",
+			method:         http.MethodGet,
+			path:           "/api/v1/watchlist",
+			expectedStatus: http.StatusOK,
+			expectedPairs:  []string{},
+		},
+		{
+			name:           "invalid pair",
+			key:            "secret-a",
+			method:         http.MethodPost,
+			path:           "/api/v1/watchlist",
+			body:           `{"pairs":["BTC/USDT"]}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid body",
+			key:            "secret-a",
+			method:         http.MethodPut,
+			path:           "/api/v1/watchlist",
+			body:           `{`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "delete without pair",
+			key:            "secret-a",
+			method:         http.MethodDelete,
+			path:           "/api/v1/watchlist",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:           "unchanged after errors",
+			key:            "secret-a",
+			method:         http.MethodGet,
+			path:           "/api/v1/watchlist",
+			expectedStatus: http.StatusOK,
+			expectedPairs:  []string{"BTCUSDT", "SOLUSDT"},
+		},
+	}
+
+	// Cases run in order, each on the watchlists left by the previous ones
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.key != "" {
+				req.Header.Set("X-API-Key", tt.key)
+			}
+			w := httptest.NewRecorder()
+			routes.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var resp WatchlistResponse
+			assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+			assert.Equal(t, tt.expectedPairs, resp.Pairs)
+		})
+	}
+}
+
+func TestServer_indexData(t *testing.T) {
+	s := &Server{events: bus.New()}
+	assert.Empty(t, s.indexData(httptest.NewRequest(http.MethodGet, "/", http.NoBody)).Watchlist)
+
+	wl := watchlist.New()
+	_, err := wl.Set("a", []string{"BTCUSDT"})
+	assert.NoError(t, err)
+	_, err = wl.Set("b", []string{"ETHUSDT"})
+	assert.NoError(t, err)
+	WithAPIKeys([]APIKey{{Name: "a", Key: "secret-a"}}, false)(s)
+	WithWatchlist(wl)(s)
+	s.resolved("BTCUSDT", "binance", 97000.5)
+
+	// Watchlists of consumers are not shown to anonymous visitors
+	data := s.indexData(httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	assert.Empty(t, data.Watchlist)
+
+	data = s.indexData(httptest.NewRequest(http.MethodGet, "/?api_key=secret-a", http.NoBody))
+	assert.Equal(t, []watchedPair{{Pair: "BTCUSDT", Price: 97000.5, Source: "binance", Known: true}}, data.Watchlist)
+}
+
+func TestServer_indexData_Dashboard(t *testing.T) {
+	s := &Server{events: bus.New()}
+	data := s.indexData(httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	assert.Equal(t, "Coinmon API", data.Title)
+	assert.Equal(t, "auto", data.Theme)
+	assert.Empty(t, data.Prices)
+
+	WithDashboard(Dashboard{Title: "Acme Prices", Pairs: []string{"BTCUSDT", "ETHUSDT"}, Theme: "dark"})(s)
+	s.resolved("ETHUSDT", "bybit", 3500)
+
+	data = s.indexData(httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	assert.Equal(t, "Acme Prices", data.Title)
+	assert.Equal(t, "dark", data.Theme)
+	assert.Equal(t, []watchedPair{
+		{Pair: "BTCUSDT"},
+		{Pair: "ETHUSDT", Price: 3500, Source: "bybit", Known: true},
+	}, data.Prices)
+
+	w := httptest.NewRecorder()
+	s.routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	assert.Contains(t, w.Body.String(), `<html lang="en" data-theme="dark">`)
+	assert.Contains(t, w.Body.String(), "<title>Acme Prices</title>")
+	assert.Contains(t, w.Body.String(), `<code data-pair="ETHUSDT">3500</code>`)
+}
+
+func TestConsumer(t *testing.T) {
+	assert.Empty(t, consumer(context.Background()))
+	assert.Equal(t, "a", consumer(context.WithValue(context.Background(), consumerKey{}, "a")))
+}
@@ -0,0 +1,240 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/ivanglie/coinmon/pkg/log"
+)
+
+const maxSubscriptions = 50
+
+// wsRequest represents a client message on the WebSocket API
+type wsRequest struct {
+	Type  string   `json:"type"`
+	Pairs []string `json:"pairs"`
+}
+
+// wsMessage represents a server message on the WebSocket API
+type wsMessage struct {
+	Type    string     `json:"type"`
+	Pair    string     `json:"pair,omitempty"`
+	Price   float64    `json:"price,omitempty"`
+	Source  string     `json:"source,omitempty"`
+	Pairs   []string   `json:"pairs,omitempty"`
+	Message string     `json:"message,omitempty"`
+	Time    *time.Time `json:"time,omitempty"`
+}
+
+// wsSession tracks subscriptions of a single WebSocket connection
+type wsSession struct {
+	s       *Server
+	out     chan wsMessage
+	mu      sync.Mutex
+	subs    map[string]context.CancelFunc
+	charged bool
+}
+
+// HandleWS handles /ws requests.
+// Clients send {"type":"subscribe","pairs":["BTCUSDT"]} or
+// {"type":"unsubscribe","pairs":["BTCUSDT"]} and receive price, heartbeat
+// and error messages. Every subscription but the first takes a token from
+// the limiter of the connection request.
+func (s *Server) HandleWS(w http.ResponseWriter, r *http.Request) {
+	release, ok := s.acquireSubscriber(w)
+	if !ok {
+		return
+	}
+	defer release()
+
+	// Connections outlive the server read and write timeouts
+	rc := http.NewResponseController(w)
+	if err := rc.SetReadDeadline(time.Time{}); err != nil {
+		log.Debug("Failed to clear read deadline: " + err.Error())
+	}
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		log.Debug("Failed to clear write deadline: " + err.Error())
+	}
+
+	// The API is public and cookie-less, so any origin may connect
+	c, err := websocket.Accept(w, r, &websocket.AcceptOptions{InsecureSkipVerify: true})
+	if err != nil {
+		log.Error("Failed to accept websocket: " + err.Error())
+		return
+	}
+
+	defer func() { _ = c.CloseNow() }()
+
+	ctx, cancel := s.streamContext(context.WithoutCancel(r.Context()))
+	defer cancel()
+
+	sess := &wsSession{
+		s:    s,
+		out:  make(chan wsMessage, 16),
+		subs: make(map[string]context.CancelFunc),
+	}
+
+	go func() {
+		defer cancel()
+		sess.read(ctx, c)
+	}()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		var msg wsMessage
+		select {
+		case <-ctx.Done():
+			_ = c.Close(websocket.StatusNormalClosure, "")
+			return
+		case msg = <-sess.out:
+		case t := <-heartbeat.C:
+			t = t.UTC()
+			msg = wsMessage{Type: "heartbeat", Time: &t}
+		}
+
+		wctx, wcancel := context.WithTimeout(ctx, 10*time.Second)
+		err := wsjson.Write(wctx, c, msg)
+		wcancel()
+		if err != nil {
+			log.Debug("Failed to write websocket message: " + err.Error())
+			return
+		}
+	}
+}
+
+func (sess *wsSession) read(ctx context.Context, c *websocket.Conn) {
+	defer sess.unsubscribeAll()
+
+	for {
+		var req wsRequest
+		if err := wsjson.Read(ctx, c, &req); err != nil {
+			var ce websocket.CloseError
+			if !errors.As(err, &ce) && ctx.Err() == nil {
+				log.Debug("Failed to read websocket message: " + err.Error())
+			}
+			return
+		}
+
+		pairs := make([]string, 0, len(req.Pairs))
+		for _, p := range req.Pairs {
+			if p = strings.ToUpper(strings.TrimSpace(p)); p != "" {
+				pairs = append(pairs, p)
+			}
+		}
+
+		switch req.Type {
+		case "subscribe":
+			sess.subscribe(ctx, pairs)
+		case "unsubscribe":
+			sess.unsubscribe(pairs)
+		default:
+			sess.send(ctx, wsMessage{Type: "error", Message: "unknown message type: " + req.Type})
+		}
+	}
+}
+
+func (sess *wsSession) subscribe(ctx context.Context, pairs []string) {
+	if len(pairs) == 0 {
+		sess.send(ctx, wsMessage{Type: "error", Message: "missing trading pairs"})
+		return
+	}
+
+	var added, rejected, limited []string
+	sess.mu.Lock()
+	for _, pair := range pairs {
+		if _, ok := sess.subs[pair]; ok {
+			continue
+		}
+		if len(sess.subs) >= maxSubscriptions {
+			rejected = append(rejected, pair)
+			continue
+		}
+		// The token taken admitting the connection covers its first pair
+		if sess.charged && !charge(ctx, 1) {
+			limited = append(limited, pair)
+			continue
+		}
+		sess.charged = true
+
+		pctx, cancel := context.WithCancel(ctx)
+		sess.subs[pair] = cancel
+		added = append(added, pair)
+		go sess.forward(pctx, pair)
+	}
+	sess.mu.Unlock()
+
+	if len(added) > 0 {
+		sess.send(ctx, wsMessage{Type: "subscribed", Pairs: added})
+	}
+	if len(rejected) > 0 {
+		sess.send(ctx, wsMessage{Type: "error", Pairs: rejected, Message: "too many subscriptions"})
+	}
+	if len(limited) > 0 {
+		sess.send(ctx, wsMessage{Type: "error", Pairs: limited, Message: errTooManyRequests.Error()})
+	}
+}
+
+func (sess *wsSession) unsubscribe(pairs []string) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	for _, pair := range pairs {
+		if cancel, ok := sess.subs[pair]; ok {
+			cancel()
+			delete(sess.subs, pair)
+		}
+	}
+}
+
+func (sess *wsSession) unsubscribeAll() {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	for pair, cancel := range sess.subs {
+		cancel()
+		delete(sess.subs, pair)
+	}
+}
+
+// forward pushes updates of pair to the connection until ctx is canceled
+func (sess *wsSession) forward(ctx context.Context, pair string) {
+	ch, unsubscribe := sess.s.events.Subscribe(pair)
+	defer unsubscribe()
+
+	// Send the current price right away instead of waiting for the next poll
+	price, source, err := sess.s.price(ctx, pair)
+	if err != nil {
+		sess.send(ctx, wsMessage{Type: "error", Pair: pair, Message: err.Error()})
+	} else {
+		sess.send(ctx, priceMessage(bus.PriceUpdated{Pair: pair, Price: price, Source: source, Time: time.Now().UTC()}))
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case u := <-ch:
+			sess.send(ctx, priceMessage(u))
+		}
+	}
+}
+
+func (sess *wsSession) send(ctx context.Context, msg wsMessage) {
+	select {
+	case <-ctx.Done():
+	case sess.out <- msg:
+	}
+}
+
+func priceMessage(u bus.PriceUpdated) wsMessage {
+	return wsMessage{Type: "price", Pair: u.Pair, Price: u.Price, Source: u.Source, Time: &u.Time}
+}
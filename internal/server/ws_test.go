@@ -0,0 +1,179 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/ivanglie/coinmon/internal/exchange"
+	"github.com/stretchr/testify/assert"
+)
+
+func dialWS(t *testing.T, s *Server) (*websocket.Conn, context.Context) {
+	t.Helper()
+
+	ts := httptest.NewServer(http.HandlerFunc(s.HandleWS))
+	t.Cleanup(ts.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	c, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(ts.URL, "http")+"/ws", nil)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = c.CloseNow() })
+
+	return c, ctx
+}
+
+func readWS(t *testing.T, ctx context.Context, c *websocket.Conn) wsMessage {
+	t.Helper()
+
+	var msg wsMessage
+	assert.NoError(t, wsjson.Read(ctx, c, &msg))
+	return msg
+}
+
+func TestServer_HandleWS(t *testing.T) {
+	s := &Server{
+		events:    bus.New(),
+		exchanges: exchanges[:1],
+		fetchers:  mockFetchers(),
+	}
+	c, ctx := dialWS(t, s)
+
+	assert.NoError(t, wsjson.Write(ctx, c, wsRequest{Type: "subscribe", Pairs: []string{"btcusdt"}}))
+
+	msg := readWS(t, ctx, c)
+	assert.Equal(t, "subscribed", msg.Type)
+	assert.Equal(t, []string{"BTCUSDT"}, msg.Pairs)
+
+	msg = readWS(t, ctx, c)
+	assert.Equal(t, "price", msg.Type)
+	assert.Equal(t, "BTCUSDT", msg.Pair)
+	assert.Equal(t, 99999.99, msg.Price)
+	assert.Equal(t, "binance", msg.Source)
+
+	assert.Eventually(t, func() bool {
+		return s.events.Subscribers("BTCUSDT") == 1
+	}, time.Second, 5*time.Millisecond)
+
+	s.events.Publish(bus.PriceUpdated{Pair: "BTCUSDT", Price: 100000, Source: "bybit"})
+	msg = readWS(t, ctx, c)
+	assert.Equal(t, "price", msg.Type)
+	assert.Equal(t, 100000.0, msg.Price)
+
+	assert.NoError(t, wsjson.Write(ctx, c, wsRequest{Type: "unsubscribe", Pairs: []string{"BTCUSDT"}}))
+	assert.Eventually(t, func() bool {
+		return s.events.Subscribers("BTCUSDT") == 0
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestServer_HandleWS_Errors(t *testing.T) {
+	tests := []struct {
+		name            string
+		request         wsRequest
+		fetchers        map[exchange.Name]Fetcher
+		expectedMessage string
+	}{
+		{
+			name:            "unknown message type",
+			request:         wsRequest{Type: "ping"},
+			expectedMessage: "unknown message type: ping",
+		},
+		{
+			name:            "missing pairs",
+			request:         wsRequest{Type: "subscribe", Pairs: []string{" "}},
+			expectedMessage: "missing trading pairs",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, ctx := dialWS(t, &Server{exchanges: exchanges, events: bus.New()})
+
+			assert.NoError(t, wsjson.Write(ctx, c, tt.request))
+
+			msg := readWS(t, ctx, c)
+			assert.Equal(t, "error", msg.Type)
+			assert.Equal(t, tt.expectedMessage, msg.Message)
+		})
+	}
+}
+
+func TestServer_HandleWS_PriceError(t *testing.T) {
+	c, ctx := dialWS(t, &Server{
+		events:    bus.New(),
+		exchanges: exchanges,
+		fetchers:  mockInvalidPairFetchers(),
+	})
+
+	assert.NoError(t, wsjson.Write(ctx, c, wsRequest{Type: "subscribe", Pairs: []string{"INVALID"}}))
+	assert.Equal(t, "subscribed", readWS(t, ctx, c).Type)
+
+	msg := readWS(t, ctx, c)
+	assert.Equal(t, "error", msg.Type)
+	assert.Equal(t, "INVALID", msg.Pair)
+	assert.Contains(t, msg.Message, "all exchanges failed")
+}
+
+func TestServer_HandleWS_charged(t *testing.T) {
+	s := &Server{
+		events:    bus.New(),
+		exchanges: exchanges[:1],
+		fetchers:  mockFetchers(),
+	}
+	WithAPIKeys([]APIKey{{Name: "app", Key: "secret", Rate: 0.001, Burst: 3}}, true)(s)
+	ts := httptest.NewServer(s.rateLimit(s.HandleWS))
+	t.Cleanup(ts.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	c, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(ts.URL, "http")+"/ws", &websocket.DialOptions{
+		HTTPHeader: http.Header{"X-Api-Key": []string{"secret"}},
+	})
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = c.CloseNow() })
+
+	// The connection took a token covering BTCUSDT, leaving two
+	assert.NoError(t, wsjson.Write(ctx, c, wsRequest{Type: "subscribe", Pairs: []string{"BTCUSDT", "ETHUSDT", "SOLUSDT", "XRPUSDT"}}))
+
+	for {
+		msg := readWS(t, ctx, c)
+		if msg.Type == "error" {
+			assert.Equal(t, "too many requests", msg.Message)
+			assert.Equal(t, []string{"XRPUSDT"}, msg.Pairs)
+			return
+		}
+	}
+}
+
+func TestServer_HandleWS_TooManySubscriptions(t *testing.T) {
+	c, ctx := dialWS(t, &Server{
+		events:    bus.New(),
+		exchanges: exchanges[:1],
+		fetchers:  mockFetchers(),
+	})
+
+	pairs := make([]string, 0, maxSubscriptions+1)
+	for i := range maxSubscriptions + 1 {
+		pairs = append(pairs, fmt.Sprintf("PAIR%d", i))
+	}
+	assert.NoError(t, wsjson.Write(ctx, c, wsRequest{Type: "subscribe", Pairs: pairs}))
+
+	for {
+		msg := readWS(t, ctx, c)
+		if msg.Type == "error" {
+			assert.Equal(t, "too many subscriptions", msg.Message)
+			assert.Equal(t, []string{fmt.Sprintf("PAIR%d", maxSubscriptions)}, msg.Pairs)
+			return
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/ivanglie/coinmon/pkg/log"
+)
+
+const (
+	bufferSize     = 256
+	publishTimeout = 10 * time.Second
+)
+
+// buffered publishes the price updates of a bus from a queue of bufferSize
+// updates, so a slow system drops updates rather than blocking the bus
+type buffered struct {
+	name string // of the system, in logs
+	// batch caps the updates already queued passed to publish at once, one
+	// update when 0
+	batch   int
+	publish func(ctx context.Context, batch []bus.PriceUpdated) error
+	close   func() error // optional
+}
+
+// start publishes updates until ctx is canceled, then closes the system
+func (s buffered) start(ctx context.Context, b *bus.Bus) {
+	events, unsubscribe := b.SubscribeAll(bufferSize)
+
+	go func() {
+		defer unsubscribe()
+		defer func() {
+			if s.close == nil {
+				return
+			}
+			if err := s.close(); err != nil {
+				log.Error(fmt.Sprintf("Failed to close %s: %v", s.name, err))
+			}
+		}()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case u := <-events:
+				batch := []bus.PriceUpdated{u}
+				for len(batch) < s.batch && len(events) > 0 {
+					batch = append(batch, <-events)
+				}
+
+				if err := s.publish(ctx, batch); err != nil {
+					updates := batch[0].Pair
+					if len(batch) > 1 {
+						updates = fmt.Sprintf("%d updates", len(batch))
+					}
+					log.Error(fmt.Sprintf("Failed to publish %s to %s: %v", updates, s.name, err))
+				}
+			}
+		}
+	}()
+}
+
+// single returns the publishing of batches of one update with publish
+func single(publish func(ctx context.Context, u bus.PriceUpdated) error) func(context.Context, []bus.PriceUpdated) error {
+	return func(ctx context.Context, batch []bus.PriceUpdated) error {
+		return publish(ctx, batch[0])
+	}
+}
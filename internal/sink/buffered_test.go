@@ -0,0 +1,74 @@
+package sink
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuffered(t *testing.T) {
+	tests := []struct {
+		name          string
+		batch         int
+		expectedSizes []int
+	}{
+		{name: "single", expectedSizes: []int{1, 1, 1, 1, 1}},
+		{name: "batches", batch: 3, expectedSizes: []int{1, 3, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			publishing, unblock := make(chan struct{}, 5), make(chan struct{})
+			batches := make(chan []bus.PriceUpdated, 5)
+			closed := make(chan struct{})
+			s := buffered{
+				name:  "test",
+				batch: tt.batch,
+				publish: func(_ context.Context, batch []bus.PriceUpdated) error {
+					publishing <- struct{}{}
+					<-unblock
+					batches <- batch
+					return nil
+				},
+				close: func() error {
+					close(closed)
+					return nil
+				},
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			b := bus.New()
+			s.start(ctx, b)
+
+			// Updates queue up while the first one is being published
+			for i, pair := range []string{"BTCUSDT", "ETHUSDT", "SOLUSDT", "XRPUSDT", "BNBUSDT"} {
+				b.Publish(bus.PriceUpdated{Pair: pair, Price: 1, Source: "binance", Time: time.Now()})
+				if i == 0 {
+					<-publishing
+				}
+			}
+			close(unblock)
+
+			var sizes []int
+			for range tt.expectedSizes {
+				select {
+				case batch := <-batches:
+					sizes = append(sizes, len(batch))
+				case <-time.After(time.Second):
+					t.Fatal("updates were not published")
+				}
+			}
+			assert.Equal(t, tt.expectedSizes, sizes)
+
+			cancel()
+			select {
+			case <-closed:
+			case <-time.After(time.Second):
+				t.Fatal("sink was not closed")
+			}
+		})
+	}
+}
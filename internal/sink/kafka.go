@@ -0,0 +1,68 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/segmentio/kafka-go"
+)
+
+// DefaultKafkaTopic is the topic price updates are produced to
+const DefaultKafkaTopic = "coinmon.prices"
+
+const maxKafkaBatch = 100
+
+type kafkaWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// Kafka produces price updates to a Kafka topic keyed by pair, so updates
+// of a pair land in the same partition in order
+type Kafka struct {
+	writer kafkaWriter
+}
+
+// NewKafka creates a new Kafka producer for the given brokers and topic
+func NewKafka(brokers []string, topic string) (*Kafka, error) {
+	if len(brokers) == 0 {
+		return nil, fmt.Errorf("missing kafka brokers")
+	}
+	if topic == "" {
+		topic = DefaultKafkaTopic
+	}
+
+	return &Kafka{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			BatchTimeout: 100 * time.Millisecond,
+			WriteTimeout: publishTimeout,
+			RequiredAcks: kafka.RequireOne,
+		},
+	}, nil
+}
+
+// Start produces updates until ctx is canceled, whatever else is already
+// queued in the same request
+func (k *Kafka) Start(ctx context.Context, b *bus.Bus) {
+	buffered{name: "Kafka", batch: maxKafkaBatch, publish: k.produce, close: k.writer.Close}.start(ctx, b)
+}
+
+func (k *Kafka) produce(ctx context.Context, batch []bus.PriceUpdated) error {
+	msgs := make([]kafka.Message, 0, len(batch))
+	for _, u := range batch {
+		b, err := json.Marshal(u)
+		if err != nil {
+			return fmt.Errorf("marshal update: %w", err)
+		}
+
+		msgs = append(msgs, kafka.Message{Key: []byte(u.Pair), Value: b, Time: u.Time})
+	}
+
+	return k.writer.WriteMessages(ctx, msgs...)
+}
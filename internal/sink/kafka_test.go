@@ -0,0 +1,118 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockKafkaWriter struct {
+	mu       sync.Mutex
+	err      error
+	messages []kafka.Message
+	closed   chan struct{}
+}
+
+func (m *mockKafkaWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msgs...)
+	return m.err
+}
+
+func (m *mockKafkaWriter) Close() error {
+	close(m.closed)
+	return nil
+}
+
+func (m *mockKafkaWriter) written() []kafka.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]kafka.Message(nil), m.messages...)
+}
+
+func TestNewKafka(t *testing.T) {
+	tests := []struct {
+		name          string
+		brokers       []string
+		topic         string
+		expectedTopic string
+		expectedError string
+	}{
+		{
+			name:          "default topic",
+			brokers:       []string{"localhost:9092"},
+			expectedTopic: DefaultKafkaTopic,
+		},
+		{
+			name:          "custom topic",
+			brokers:       []string{"kafka-1:9092", "kafka-2:9092"},
+			topic:         "prices",
+			expectedTopic: "prices",
+		},
+		{
+			name:          "missing brokers",
+			expectedError: "missing kafka brokers",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := NewKafka(tt.brokers, tt.topic)
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				return
+			}
+
+			assert.NoError(t, err)
+			w := k.writer.(*kafka.Writer)
+			assert.Equal(t, tt.expectedTopic, w.Topic)
+			assert.IsType(t, &kafka.Hash{}, w.Balancer)
+		})
+	}
+}
+
+func TestKafka(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{name: "success"},
+		{name: "write error", err: fmt.Errorf("leader not available")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &mockKafkaWriter{err: tt.err, closed: make(chan struct{})}
+			k := &Kafka{writer: w}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			b := bus.New()
+			k.Start(ctx, b)
+
+			ts := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+			b.Publish(bus.PriceUpdated{Pair: "BTCUSDT", Price: 97000.01, Source: "binance", Time: ts})
+			b.Publish(bus.PriceUpdated{Pair: "ETHUSDT", Price: 3500, Source: "bybit", Time: ts})
+
+			assert.Eventually(t, func() bool { return len(w.written()) == 2 }, time.Second, 5*time.Millisecond)
+			msgs := w.written()
+			assert.Equal(t, "BTCUSDT", string(msgs[0].Key))
+			assert.JSONEq(t, `{"pair":"BTCUSDT","price":97000.01,"source":"binance","time":"2025-01-01T00:00:00Z"}`, string(msgs[0].Value))
+			assert.Equal(t, ts, msgs[0].Time)
+			assert.Equal(t, "ETHUSDT", string(msgs[1].Key))
+
+			cancel()
+			select {
+			case <-w.closed:
+			case <-time.After(time.Second):
+				t.Fatal("writer was not closed")
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+// Package sink delivers price updates to external systems.
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/ivanglie/coinmon/pkg/log"
+)
+
+// DefaultMQTTTopic is the topic prefix price updates are published under
+const DefaultMQTTTopic = "coinmon/spot"
+
+type mqttClient interface {
+	Connect() mqtt.Token
+	Publish(topic string, qos byte, retained bool, payload any) mqtt.Token
+	Disconnect(quiesce uint)
+}
+
+// MQTTConfig represents MQTT broker connection settings
+type MQTTConfig struct {
+	Broker   string
+	ClientID string
+	Username string
+	Password string
+	Topic    string
+	QoS      byte
+	Retained bool
+}
+
+// MQTT publishes price updates to an MQTT broker on <topic>/<pair>
+type MQTT struct {
+	topic    string
+	qos      byte
+	retained bool
+	client   mqttClient
+}
+
+// NewMQTT creates a new MQTT publisher. The connection is established by Start.
+func NewMQTT(cfg MQTTConfig) (*MQTT, error) {
+	if cfg.QoS > 2 {
+		return nil, fmt.Errorf("invalid qos: %d", cfg.QoS)
+	}
+
+	topic := cfg.Topic
+	if topic == "" {
+		topic = DefaultMQTTTopic
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(cfg.ClientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetMaxReconnectInterval(30 * time.Second).
+		SetOnConnectHandler(func(mqtt.Client) {
+			log.Info("Connected to MQTT broker " + cfg.Broker)
+		}).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			log.Error("Lost connection to MQTT broker: " + err.Error())
+		})
+
+	return &MQTT{
+		topic:    topic,
+		qos:      cfg.QoS,
+		retained: cfg.Retained,
+		client:   mqtt.NewClient(opts),
+	}, nil
+}
+
+// Start connects to the broker and publishes updates until ctx is canceled.
+// The broker may be unavailable on start, the client keeps retrying.
+func (m *MQTT) Start(ctx context.Context, b *bus.Bus) {
+	m.client.Connect()
+
+	buffered{
+		name:    "MQTT",
+		publish: single(m.publish),
+		close: func() error {
+			m.client.Disconnect(250)
+			return nil
+		},
+	}.start(ctx, b)
+}
+
+func (m *MQTT) publish(_ context.Context, u bus.PriceUpdated) error {
+	b, err := json.Marshal(u)
+	if err != nil {
+		return fmt.Errorf("marshal update: %w", err)
+	}
+
+	t := m.client.Publish(m.topic+"/"+u.Pair, m.qos, m.retained, b)
+	if !t.WaitTimeout(publishTimeout) {
+		return fmt.Errorf("publish timed out")
+	}
+
+	return t.Error()
+}
@@ -0,0 +1,144 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockToken struct {
+	err error
+}
+
+func (t *mockToken) Wait() bool                     { return true }
+func (t *mockToken) WaitTimeout(time.Duration) bool { return true }
+func (t *mockToken) Error() error                   { return t.err }
+
+func (t *mockToken) Done() <-chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}
+
+type mqttMessage struct {
+	topic    string
+	qos      byte
+	retained bool
+	payload  string
+}
+
+type mockMQTTClient struct {
+	mu           sync.Mutex
+	err          error
+	messages     []mqttMessage
+	disconnected chan struct{}
+}
+
+func (m *mockMQTTClient) Connect() mqtt.Token {
+	return &mockToken{}
+}
+
+func (m *mockMQTTClient) Publish(topic string, qos byte, retained bool, payload any) mqtt.Token {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, mqttMessage{topic, qos, retained, string(payload.([]byte))})
+	return &mockToken{err: m.err}
+}
+
+func (m *mockMQTTClient) Disconnect(uint) {
+	close(m.disconnected)
+}
+
+func (m *mockMQTTClient) published() []mqttMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]mqttMessage(nil), m.messages...)
+}
+
+func TestNewMQTT(t *testing.T) {
+	tests := []struct {
+		name          string
+		cfg           MQTTConfig
+		expectedTopic string
+		expectedError string
+	}{
+		{
+			name:          "defaults",
+			cfg:           MQTTConfig{Broker: "tcp://localhost:1883"},
+			expectedTopic: DefaultMQTTTopic,
+		},
+		{
+			name:          "custom topic",
+			cfg:           MQTTConfig{Broker: "tcp://localhost:1883", Topic: "home/crypto", QoS: 1, Retained: true},
+			expectedTopic: "home/crypto",
+		},
+		{
+			name:          "invalid qos",
+			cfg:           MQTTConfig{Broker: "tcp://localhost:1883", QoS: 3},
+			expectedError: "invalid qos: 3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewMQTT(tt.cfg)
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedTopic, m.topic)
+			assert.Equal(t, tt.cfg.QoS, m.qos)
+			assert.Equal(t, tt.cfg.Retained, m.retained)
+		})
+	}
+}
+
+func TestMQTT(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{name: "success"},
+		{name: "publish error", err: fmt.Errorf("not connected")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockMQTTClient{err: tt.err, disconnected: make(chan struct{})}
+			m := &MQTT{
+				topic:    "coinmon/spot",
+				qos:      1,
+				retained: true,
+				client:   client,
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			b := bus.New()
+			m.Start(ctx, b)
+
+			b.Publish(bus.PriceUpdated{Pair: "BTCUSDT", Price: 97000.01, Source: "binance", Time: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)})
+			b.Publish(bus.PriceUpdated{Pair: "ETHUSDT", Price: 3500, Source: "bybit", Time: time.Date(2025, 1, 1, 0, 0, 1, 0, time.UTC)})
+
+			assert.Eventually(t, func() bool { return len(client.published()) == 2 }, time.Second, 5*time.Millisecond)
+			assert.Equal(t, []mqttMessage{
+				{"coinmon/spot/BTCUSDT", 1, true, `{"pair":"BTCUSDT","price":97000.01,"source":"binance","time":"2025-01-01T00:00:00Z"}`},
+				{"coinmon/spot/ETHUSDT", 1, true, `{"pair":"ETHUSDT","price":3500,"source":"bybit","time":"2025-01-01T00:00:01Z"}`},
+			}, client.published())
+
+			cancel()
+			select {
+			case <-client.disconnected:
+			case <-time.After(time.Second):
+				t.Fatal("client was not disconnected")
+			}
+		})
+	}
+}
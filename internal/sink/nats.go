@@ -0,0 +1,71 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/ivanglie/coinmon/pkg/log"
+	"github.com/nats-io/nats.go"
+)
+
+// DefaultNATSSubject is the subject prefix price updates are published under
+const DefaultNATSSubject = "coinmon.spot"
+
+type natsConn interface {
+	Publish(subj string, data []byte) error
+	Drain() error
+}
+
+// NATS publishes price updates to NATS subjects <subject>.<pair>
+type NATS struct {
+	subject string
+	conn    natsConn
+}
+
+// NewNATS connects to the NATS server at url. The server may be unavailable
+// on start, the connection keeps retrying.
+func NewNATS(url, subject string) (*NATS, error) {
+	if subject == "" {
+		subject = DefaultNATSSubject
+	}
+
+	nc, err := nats.Connect(url,
+		nats.Name("coinmon"),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(2*time.Second),
+		nats.ConnectHandler(func(*nats.Conn) {
+			log.Info("Connected to NATS " + url)
+		}),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				log.Error("Lost connection to NATS: " + err.Error())
+			}
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("connect nats: %w", err)
+	}
+
+	return &NATS{
+		subject: subject,
+		conn:    nc,
+	}, nil
+}
+
+// Start publishes updates until ctx is canceled
+func (n *NATS) Start(ctx context.Context, b *bus.Bus) {
+	buffered{name: "NATS", publish: single(n.publish), close: n.conn.Drain}.start(ctx, b)
+}
+
+func (n *NATS) publish(_ context.Context, u bus.PriceUpdated) error {
+	b, err := json.Marshal(u)
+	if err != nil {
+		return fmt.Errorf("marshal update: %w", err)
+	}
+
+	return n.conn.Publish(n.subject+"."+u.Pair, b)
+}
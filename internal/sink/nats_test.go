@@ -0,0 +1,88 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+type natsMessage struct {
+	subject string
+	data    string
+}
+
+type mockNATSConn struct {
+	mu       sync.Mutex
+	err      error
+	messages []natsMessage
+	drained  chan struct{}
+}
+
+func (m *mockNATSConn) Publish(subj string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, natsMessage{subj, string(data)})
+	return m.err
+}
+
+func (m *mockNATSConn) Drain() error {
+	close(m.drained)
+	return nil
+}
+
+func (m *mockNATSConn) published() []natsMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]natsMessage(nil), m.messages...)
+}
+
+func TestNATS(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{name: "success"},
+		{name: "publish error", err: fmt.Errorf("nats: connection closed")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &mockNATSConn{err: tt.err, drained: make(chan struct{})}
+			n := &NATS{subject: DefaultNATSSubject, conn: conn}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			b := bus.New()
+			n.Start(ctx, b)
+
+			b.Publish(bus.PriceUpdated{Pair: "BTCUSDT", Price: 97000.01, Source: "binance", Time: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)})
+
+			assert.Eventually(t, func() bool { return len(conn.published()) == 1 }, time.Second, 5*time.Millisecond)
+			assert.Equal(t, []natsMessage{
+				{"coinmon.spot.BTCUSDT", `{"pair":"BTCUSDT","price":97000.01,"source":"binance","time":"2025-01-01T00:00:00Z"}`},
+			}, conn.published())
+
+			cancel()
+			select {
+			case <-conn.drained:
+			case <-time.After(time.Second):
+				t.Fatal("connection was not drained")
+			}
+		})
+	}
+}
+
+func TestNewNATS(t *testing.T) {
+	n, err := NewNATS("nats://127.0.0.1:1", "")
+	assert.NoError(t, err, "unavailable server is retried in the background")
+	assert.Equal(t, DefaultNATSSubject, n.subject)
+	n.conn.(*nats.Conn).Close()
+
+	_, err = NewNATS("://invalid", "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,64 @@
+package sink
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Prometheus exports the latest prices of pairs as gauges, one series per
+// pair labeled with the exchange the price came from
+type Prometheus struct {
+	pairs   []string
+	price   *prometheus.GaugeVec
+	updated *prometheus.GaugeVec
+	// sources holds the source of the series of each pair, only used by observe
+	sources map[string]string
+}
+
+// NewPrometheus creates a new exporter of the given pairs registered in reg
+func NewPrometheus(reg prometheus.Registerer, pairs []string) *Prometheus {
+	p := &Prometheus{
+		price: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "coinmon_spot_price",
+			Help: "Latest spot price of a trading pair and the exchange it came from",
+		}, []string{"pair", "source"}),
+		updated: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "coinmon_spot_price_updated_timestamp_seconds",
+			Help: "Unix time of the latest spot price of a trading pair and the exchange it came from",
+		}, []string{"pair", "source"}),
+		sources: make(map[string]string),
+	}
+	for _, pair := range pairs {
+		p.pairs = append(p.pairs, strings.ToUpper(pair))
+	}
+	reg.MustRegister(p.price, p.updated)
+
+	return p
+}
+
+// Start updates gauges until ctx is canceled
+func (p *Prometheus) Start(ctx context.Context, b *bus.Bus) {
+	buffered{name: "Prometheus", publish: single(p.observe)}.start(ctx, b)
+}
+
+func (p *Prometheus) observe(_ context.Context, u bus.PriceUpdated) error {
+	if !slices.Contains(p.pairs, u.Pair) {
+		return nil
+	}
+
+	// Drop the series of the previous source so a pair has a single price
+	if prev, ok := p.sources[u.Pair]; ok && prev != u.Source {
+		p.price.DeleteLabelValues(u.Pair, prev)
+		p.updated.DeleteLabelValues(u.Pair, prev)
+	}
+	p.sources[u.Pair] = u.Source
+
+	p.price.WithLabelValues(u.Pair, u.Source).Set(u.Price)
+	p.updated.WithLabelValues(u.Pair, u.Source).Set(float64(u.Time.UnixMilli()) / 1000)
+
+	return nil
+}
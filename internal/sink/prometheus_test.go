@@ -0,0 +1,44 @@
+package sink
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrometheus(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	p := NewPrometheus(reg, []string{"btcusdt", "ETHUSDT"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := bus.New()
+	p.Start(ctx, b)
+
+	ts := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	b.Publish(bus.PriceUpdated{Pair: "BTCUSDT", Price: 97000.01, Source: "binance", Time: ts})
+	b.Publish(bus.PriceUpdated{Pair: "BTCUSDT", Price: 97000.02, Source: "bybit", Time: ts})
+	b.Publish(bus.PriceUpdated{Pair: "SOLUSDT", Price: 200, Source: "binance", Time: ts})
+	b.Publish(bus.PriceUpdated{Pair: "BTCUSDT", Price: 97000.03, Source: "binance", Time: ts.Add(time.Second)})
+
+	b.Publish(bus.PriceUpdated{Pair: "ETHUSDT", Price: 3500.01, Source: "kraken", Time: ts})
+
+	assert.Eventually(t, func() bool {
+		return testutil.CollectAndCount(p.price) == 2 && testutil.CollectAndCount(p.updated) == 2
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, float64(ts.Unix()+1), testutil.ToFloat64(p.updated.WithLabelValues("BTCUSDT", "binance")))
+
+	expected := `
+# HELP coinmon_spot_price Latest spot price of a trading pair and the exchange it came from
+# TYPE coinmon_spot_price gauge
+coinmon_spot_price{pair="BTCUSDT",source="binance"} 97000.03
+coinmon_spot_price{pair="ETHUSDT",source="kraken"} 3500.01
+`
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "coinmon_spot_price"))
+}
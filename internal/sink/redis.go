@@ -0,0 +1,59 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultRedisChannel is the channel prefix price updates are published under
+const DefaultRedisChannel = "coinmon:spot"
+
+type redisClient interface {
+	Publish(ctx context.Context, channel string, message any) *redis.IntCmd
+	Close() error
+}
+
+// Redis publishes price updates to Redis pub/sub channels <channel>:<pair>
+type Redis struct {
+	channel string
+	client  redisClient
+}
+
+// NewRedis creates a new Redis publisher for the server at url,
+// e.g. redis://:password@localhost:6379/0
+func NewRedis(url, channel string) (*Redis, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("parse redis url: %w", err)
+	}
+
+	if channel == "" {
+		channel = DefaultRedisChannel
+	}
+
+	return &Redis{
+		channel: channel,
+		client:  redis.NewClient(opts),
+	}, nil
+}
+
+// Start publishes updates until ctx is canceled
+func (r *Redis) Start(ctx context.Context, b *bus.Bus) {
+	buffered{name: "Redis", publish: single(r.publish), close: r.client.Close}.start(ctx, b)
+}
+
+func (r *Redis) publish(ctx context.Context, u bus.PriceUpdated) error {
+	b, err := json.Marshal(u)
+	if err != nil {
+		return fmt.Errorf("marshal update: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, publishTimeout)
+	defer cancel()
+
+	return r.client.Publish(ctx, r.channel+":"+u.Pair, b).Err()
+}
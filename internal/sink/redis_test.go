@@ -0,0 +1,122 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/bus"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+type redisMessage struct {
+	channel string
+	message string
+}
+
+type mockRedisClient struct {
+	mu       sync.Mutex
+	err      error
+	messages []redisMessage
+	closed   chan struct{}
+}
+
+func (m *mockRedisClient) Publish(ctx context.Context, channel string, message any) *redis.IntCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, redisMessage{channel, string(message.([]byte))})
+
+	cmd := redis.NewIntCmd(ctx)
+	cmd.SetErr(m.err)
+	return cmd
+}
+
+func (m *mockRedisClient) Close() error {
+	close(m.closed)
+	return nil
+}
+
+func (m *mockRedisClient) published() []redisMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]redisMessage(nil), m.messages...)
+}
+
+func TestNewRedis(t *testing.T) {
+	tests := []struct {
+		name            string
+		url             string
+		channel         string
+		expectedChannel string
+		expectError     bool
+	}{
+		{
+			name:            "default channel",
+			url:             "redis://localhost:6379/0",
+			expectedChannel: DefaultRedisChannel,
+		},
+		{
+			name:            "custom channel",
+			url:             "redis://:secret@localhost:6379",
+			channel:         "prices",
+			expectedChannel: "prices",
+		},
+		{
+			name:        "invalid url",
+			url:         "http://localhost:6379",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewRedis(tt.url, tt.channel)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedChannel, r.channel)
+			assert.NoError(t, r.client.Close())
+		})
+	}
+}
+
+func TestRedis(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{name: "success"},
+		{name: "publish error", err: fmt.Errorf("connection refused")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockRedisClient{err: tt.err, closed: make(chan struct{})}
+			r := &Redis{channel: DefaultRedisChannel, client: client}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			b := bus.New()
+			r.Start(ctx, b)
+
+			b.Publish(bus.PriceUpdated{Pair: "BTCUSDT", Price: 97000.01, Source: "binance", Time: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)})
+
+			assert.Eventually(t, func() bool { return len(client.published()) == 1 }, time.Second, 5*time.Millisecond)
+			assert.Equal(t, []redisMessage{
+				{"coinmon:spot:BTCUSDT", `{"pair":"BTCUSDT","price":97000.01,"source":"binance","time":"2025-01-01T00:00:00Z"}`},
+			}, client.published())
+
+			cancel()
+			select {
+			case <-client.closed:
+			case <-time.After(time.Second):
+				t.Fatal("client was not closed")
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+// Package telemetry provides push-based export of metrics.
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// DefaultInterval is how often metrics are pushed by default
+const DefaultInterval = time.Minute
+
+// OTLP pushes metrics gathered from a Prometheus registry to an OTLP/HTTP
+// collector
+type OTLP struct {
+	provider *sdkmetric.MeterProvider
+}
+
+// NewOTLP creates a new pusher of metrics gathered from g to the collector
+// at endpoint, e.g. https://otlp.example.com/v1/metrics. Headers are sent with
+// every request, e.g. API keys of managed platforms.
+func NewOTLP(ctx context.Context, endpoint string, headers map[string]string, interval time.Duration, g prometheus.Gatherer) (*OTLP, error) {
+	// The exporter falls back to its defaults on an invalid url silently
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parse otlp endpoint: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid otlp endpoint: %s", endpoint)
+	}
+
+	exp, err := otlpmetrichttp.New(ctx, otlpmetrichttp.WithEndpointURL(endpoint), otlpmetrichttp.WithHeaders(headers))
+	if err != nil {
+		return nil, fmt.Errorf("create otlp exporter: %w", err)
+	}
+
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	reader := sdkmetric.NewPeriodicReader(exp,
+		sdkmetric.WithInterval(interval),
+		sdkmetric.WithProducer(&producer{gatherer: g, start: time.Now()}),
+	)
+
+	return &OTLP{
+		provider: sdkmetric.NewMeterProvider(
+			sdkmetric.WithReader(reader),
+			sdkmetric.WithResource(resource.NewSchemaless(attribute.String("service.name", "coinmon"))),
+		),
+	}, nil
+}
+
+// Flush pushes metrics right away
+func (o *OTLP) Flush(ctx context.Context) error {
+	return o.provider.ForceFlush(ctx)
+}
+
+// Shutdown pushes metrics for the last time and stops pushing
+func (o *OTLP) Shutdown(ctx context.Context) error {
+	return o.provider.Shutdown(ctx)
+}
+
+// producer converts gathered Prometheus metrics to OpenTelemetry metrics
+type producer struct {
+	gatherer prometheus.Gatherer
+	start    time.Time
+}
+
+func (p *producer) Produce(context.Context) ([]metricdata.ScopeMetrics, error) {
+	families, err := p.gatherer.Gather()
+	if err != nil {
+		return nil, fmt.Errorf("gather metrics: %w", err)
+	}
+
+	now := time.Now()
+	sm := metricdata.ScopeMetrics{Scope: instrumentation.Scope{Name: "github.com/ivanglie/coinmon"}}
+	for _, mf := range families {
+		m := metricdata.Metrics{Name: mf.GetName(), Description: mf.GetHelp()}
+
+		switch mf.GetType() {
+		case dto.MetricType_GAUGE:
+			var g metricdata.Gauge[float64]
+			for _, pm := range mf.GetMetric() {
+				g.DataPoints = append(g.DataPoints, metricdata.DataPoint[float64]{
+					Attributes: attributes(pm), Time: now, Value: pm.GetGauge().GetValue(),
+				})
+			}
+			m.Data = g
+		case dto.MetricType_COUNTER:
+			s := metricdata.Sum[float64]{Temporality: metricdata.CumulativeTemporality, IsMonotonic: true}
+			for _, pm := range mf.GetMetric() {
+				s.DataPoints = append(s.DataPoints, metricdata.DataPoint[float64]{
+					Attributes: attributes(pm), StartTime: p.start, Time: now, Value: pm.GetCounter().GetValue(),
+				})
+			}
+			m.Data = s
+		case dto.MetricType_HISTOGRAM:
+			h := metricdata.Histogram[float64]{Temporality: metricdata.CumulativeTemporality}
+			for _, pm := range mf.GetMetric() {
+				h.DataPoints = append(h.DataPoints, histogramPoint(pm.GetHistogram(), attributes(pm), p.start, now))
+			}
+			m.Data = h
+		default:
+			continue
+		}
+
+		sm.Metrics = append(sm.Metrics, m)
+	}
+
+	return []metricdata.ScopeMetrics{sm}, nil
+}
+
+// histogramPoint converts cumulative Prometheus buckets to OpenTelemetry
+// bucket counts, the last one counting values above every bound
+func histogramPoint(h *dto.Histogram, attrs attribute.Set, start, now time.Time) metricdata.HistogramDataPoint[float64] {
+	dp := metricdata.HistogramDataPoint[float64]{
+		Attributes: attrs,
+		StartTime:  start,
+		Time:       now,
+		Count:      h.GetSampleCount(),
+		Sum:        h.GetSampleSum(),
+	}
+
+	var prev uint64
+	for _, b := range h.GetBucket() {
+		if math.IsInf(b.GetUpperBound(), 1) {
+			continue
+		}
+		dp.Bounds = append(dp.Bounds, b.GetUpperBound())
+		dp.BucketCounts = append(dp.BucketCounts, b.GetCumulativeCount()-prev)
+		prev = b.GetCumulativeCount()
+	}
+	dp.BucketCounts = append(dp.BucketCounts, h.GetSampleCount()-prev)
+
+	return dp
+}
+
+func attributes(pm *dto.Metric) attribute.Set {
+	kvs := make([]attribute.KeyValue, 0, len(pm.GetLabel()))
+	for _, l := range pm.GetLabel() {
+		kvs = append(kvs, attribute.String(l.GetName(), l.GetValue()))
+	}
+
+	return attribute.NewSet(kvs...)
+}
@@ -0,0 +1,132 @@
+package telemetry
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	collectorpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func testRegistry() *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+
+	price := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "coinmon_spot_price", Help: "price"}, []string{"pair", "source"})
+	price.WithLabelValues("BTCUSDT", "binance").Set(97000.01)
+
+	requests := prometheus.NewCounter(prometheus.CounterOpts{Name: "coinmon_requests_total", Help: "requests"})
+	requests.Add(3)
+
+	latency := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "coinmon_latency_seconds", Help: "latency", Buckets: []float64{.1, .5}})
+	for _, v := range []float64{.05, .2, .3, 1} {
+		latency.Observe(v)
+	}
+
+	reg.MustRegister(price, requests, latency)
+	return reg
+}
+
+func TestProducer(t *testing.T) {
+	p := &producer{gatherer: testRegistry(), start: time.Now()}
+
+	sms, err := p.Produce(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, sms, 1)
+
+	metrics := make(map[string]metricdata.Aggregation)
+	for _, m := range sms[0].Metrics {
+		metrics[m.Name] = m.Data
+	}
+	assert.Len(t, metrics, 3)
+
+	g := metrics["coinmon_spot_price"].(metricdata.Gauge[float64])
+	assert.Equal(t, 97000.01, g.DataPoints[0].Value)
+	pair, _ := g.DataPoints[0].Attributes.Value("pair")
+	assert.Equal(t, "BTCUSDT", pair.AsString())
+
+	s := metrics["coinmon_requests_total"].(metricdata.Sum[float64])
+	assert.True(t, s.IsMonotonic)
+	assert.Equal(t, 3.0, s.DataPoints[0].Value)
+
+	h := metrics["coinmon_latency_seconds"].(metricdata.Histogram[float64])
+	assert.Equal(t, []float64{.1, .5}, h.DataPoints[0].Bounds)
+	assert.Equal(t, []uint64{1, 2, 1}, h.DataPoints[0].BucketCounts)
+	assert.Equal(t, uint64(4), h.DataPoints[0].Count)
+}
+
+func TestOTLP(t *testing.T) {
+	received := make(chan *collectorpb.ExportMetricsServiceRequest, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/metrics", r.URL.Path)
+		assert.Equal(t, "secret", r.Header.Get("Api-Key"))
+
+		b, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		var req collectorpb.ExportMetricsServiceRequest
+		assert.NoError(t, proto.Unmarshal(b, &req))
+		received <- &req
+
+		w.Header().Set("Content-Type", "application/x-protobuf")
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	o, err := NewOTLP(ctx, ts.URL+"/v1/metrics", map[string]string{"Api-Key": "secret"}, time.Hour, testRegistry())
+	assert.NoError(t, err)
+	defer func() { assert.NoError(t, o.Shutdown(ctx)) }()
+
+	assert.NoError(t, o.Flush(ctx))
+
+	select {
+	case req := <-received:
+		var names []string
+		for _, rm := range req.GetResourceMetrics() {
+			for _, sm := range rm.GetScopeMetrics() {
+				for _, m := range sm.GetMetrics() {
+					names = append(names, m.GetName())
+				}
+			}
+		}
+		assert.ElementsMatch(t, []string{"coinmon_spot_price", "coinmon_requests_total", "coinmon_latency_seconds"}, names)
+	case <-time.After(5 * time.Second):
+		t.Fatal("metrics were not pushed")
+	}
+}
+
+func TestNewOTLP_InvalidEndpoint(t *testing.T) {
+	tests := []struct {
+		name          string
+		endpoint      string
+		expectedError string
+	}{
+		{
+			name:          "missing scheme",
+			endpoint:      "otlp.example.com/v1/metrics",
+			expectedError: "invalid otlp endpoint: otlp.example.com/v1/metrics",
+		},
+		{
+			name:          "unsupported scheme",
+			endpoint:      "grpc://otlp.example.com:4317",
+			expectedError: "invalid otlp endpoint: grpc://otlp.example.com:4317",
+		},
+		{
+			name:          "malformed",
+			endpoint:      "://invalid",
+			expectedError: `parse otlp endpoint: parse "://invalid": missing protocol scheme`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewOTLP(context.Background(), tt.endpoint, nil, 0, prometheus.NewRegistry())
+			assert.EqualError(t, err, tt.expectedError)
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package usage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Store is the interface that wraps usage persistence methods
+type Store interface {
+	Load() (map[string]Usage, error)
+	Save(consumers map[string]Usage) error
+}
+
+// FileStore persists usage as a JSON file
+type FileStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileStore creates a new file store at path
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// Load reads usage from the file. Missing file yields no usage.
+func (f *FileStore) Load() (map[string]Usage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]Usage{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read usage: %w", err)
+	}
+
+	consumers := map[string]Usage{}
+	if err := json.Unmarshal(b, &consumers); err != nil {
+		return nil, fmt.Errorf("parse usage: %w", err)
+	}
+
+	return consumers, nil
+}
+
+// Save writes usage to the file atomically
+func (f *FileStore) Save(consumers map[string]Usage) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b, err := json.MarshalIndent(consumers, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal usage: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write usage: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close temp file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("rename temp file: %w", err)
+	}
+
+	return nil
+}
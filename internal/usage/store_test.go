@@ -0,0 +1,35 @@
+package usage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+	f := NewFileStore(path)
+
+	consumers, err := f.Load()
+	assert.NoError(t, err)
+	assert.Empty(t, consumers)
+
+	seen := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	saved := map[string]Usage{"key:partner": {Requests: 2, Bytes: 16, Statuses: map[int]int64{200: 1, 429: 1}, Pairs: map[string]int64{"BTCUSDT": 2}, FirstSeen: seen, LastSeen: seen}}
+	assert.NoError(t, f.Save(saved))
+
+	consumers, err = f.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, saved, consumers)
+}
+
+func TestFileStore_LoadInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+	assert.NoError(t, os.WriteFile(path, []byte("{"), 0o600))
+
+	_, err := NewFileStore(path).Load()
+	assert.Error(t, err)
+}
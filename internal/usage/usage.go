@@ -0,0 +1,214 @@
+// Package usage accounts the requests of API consumers, by API key or by IP,
+// for fair-use enforcement and auditing.
+package usage
+
+import (
+	"cmp"
+	"container/list"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ivanglie/coinmon/pkg/log"
+)
+
+// DefaultMaxConsumers is the number of consumers accounted, beyond which the
+// least recently seen are forgotten
+const DefaultMaxConsumers = 10000
+
+// MaxPairs is the number of pairs accounted per consumer. Requests of other
+// pairs are counted under OtherPairs, bounding the usage of consumers asking
+// for made up pairs.
+const (
+	MaxPairs   = 100
+	OtherPairs = "other"
+)
+
+// Usage represents the requests of a consumer
+type Usage struct {
+	Requests int64 `json:"requests"`
+	// Bytes are the bytes of the response bodies
+	Bytes int64 `json:"bytes"`
+	// Statuses are the numbers of responses by status code
+	Statuses map[int]int64 `json:"statuses"`
+	// Pairs are the numbers of requests of each pair
+	Pairs     map[string]int64 `json:"pairs,omitempty"`
+	FirstSeen time.Time        `json:"first_seen"`
+	LastSeen  time.Time        `json:"last_seen"`
+}
+
+func (u Usage) clone() Usage {
+	u.Statuses = maps.Clone(u.Statuses)
+	u.Pairs = maps.Clone(u.Pairs)
+	return u
+}
+
+// Tracker accounts the usage of every consumer. It is safe for concurrent
+// use.
+type Tracker struct {
+	mu           sync.Mutex
+	consumers    map[string]*list.Element // of lru
+	lru          *list.List               // of *consumer, most recently seen first
+	store        Store
+	dirty        bool
+	maxConsumers int
+	now          func() time.Time
+}
+
+// consumer represents the usage of a consumer in the lru of a Tracker
+type consumer struct {
+	name  string
+	usage Usage
+}
+
+// Option configures a Tracker
+type Option func(*Tracker)
+
+// WithStore persists usage in store
+func WithStore(store Store) Option {
+	return func(t *Tracker) {
+		t.store = store
+	}
+}
+
+// WithMaxConsumers accounts up to n consumers instead of
+// DefaultMaxConsumers
+func WithMaxConsumers(n int) Option {
+	return func(t *Tracker) {
+		t.maxConsumers = n
+	}
+}
+
+// New creates a tracker, loaded from the store if set
+func New(opts ...Option) *Tracker {
+	t := &Tracker{
+		consumers:    make(map[string]*list.Element),
+		lru:          list.New(),
+		maxConsumers: DefaultMaxConsumers,
+		now:          time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	if t.store != nil {
+		consumers, err := t.store.Load()
+		if err != nil {
+			log.Error("Failed to load usage: " + err.Error())
+		}
+
+		// The most recently seen consumers are added last, to the front
+		names := slices.SortedFunc(maps.Keys(consumers), func(a, b string) int {
+			return cmp.Compare(consumers[a].LastSeen.UnixNano(), consumers[b].LastSeen.UnixNano())
+		})
+		for _, name := range names {
+			t.evict()
+			t.consumers[name] = t.lru.PushFront(&consumer{name: name, usage: consumers[name]})
+		}
+	}
+
+	return t
+}
+
+// Record accounts a request of consumer name for pairs, answered with status
+// and a body of bytes
+func (t *Tracker) Record(name string, pairs []string, status, bytes int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now().UTC()
+	e, ok := t.consumers[name]
+	if ok {
+		t.lru.MoveToFront(e)
+	} else {
+		t.evict()
+		e = t.lru.PushFront(&consumer{name: name, usage: Usage{Statuses: make(map[int]int64), FirstSeen: now}})
+		t.consumers[name] = e
+	}
+	u := &e.Value.(*consumer).usage
+
+	u.Requests++
+	u.Bytes += int64(bytes)
+	u.Statuses[status]++
+	for _, pair := range pairs {
+		if u.Pairs == nil {
+			u.Pairs = make(map[string]int64)
+		}
+		if _, ok := u.Pairs[pair]; !ok && len(u.Pairs) >= MaxPairs {
+			pair = OtherPairs
+		}
+		u.Pairs[pair]++
+	}
+	u.LastSeen = now
+	t.dirty = true
+}
+
+// evict forgets the least recently seen consumer when the tracker is full,
+// with mu held
+func (t *Tracker) evict() {
+	if t.maxConsumers <= 0 || len(t.consumers) < t.maxConsumers {
+		return
+	}
+
+	oldest := t.lru.Back()
+	t.lru.Remove(oldest)
+	delete(t.consumers, oldest.Value.(*consumer).name)
+}
+
+// Get returns the usage of consumer name
+func (t *Tracker) Get(name string) (Usage, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.consumers[name]
+	if !ok {
+		return Usage{}, false
+	}
+
+	return e.Value.(*consumer).usage.clone(), true
+}
+
+// All returns the usage of every consumer by consumer
+func (t *Tracker) All() map[string]Usage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.all()
+}
+
+// all returns a copy of the usage of every consumer, with mu held
+func (t *Tracker) all() map[string]Usage {
+	all := make(map[string]Usage, len(t.consumers))
+	for name, e := range t.consumers {
+		all[name] = e.Value.(*consumer).usage.clone()
+	}
+
+	return all
+}
+
+// Save writes the usage to the store when it changed since the last save
+func (t *Tracker) Save() error {
+	if t.store == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	if !t.dirty {
+		t.mu.Unlock()
+		return nil
+	}
+	all := t.all()
+	t.dirty = false
+	t.mu.Unlock()
+
+	if err := t.store.Save(all); err != nil {
+		t.mu.Lock()
+		t.dirty = true
+		t.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package usage
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memStore keeps usage in memory, failing saves with err
+type memStore struct {
+	consumers map[string]Usage
+	saves     int
+	err       error
+}
+
+func (m *memStore) Load() (map[string]Usage, error) {
+	return m.consumers, nil
+}
+
+func (m *memStore) Save(consumers map[string]Usage) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.saves++
+	m.consumers = consumers
+	return nil
+}
+
+func TestTracker_Record(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+
+	tr := New()
+	tr.now = func() time.Time { return now }
+
+	tr.Record("ip:192.0.2.1", []string{"BTCUSDT"}, 200, 9)
+	now = now.Add(time.Second)
+	tr.Record("ip:192.0.2.1", []string{"BTCUSDT", "ETHUSDT"}, 200, 40)
+	tr.Record("ip:192.0.2.1", nil, 429, 18)
+	tr.Record("key:partner", nil, 401, 17)
+
+	u, ok := tr.Get("ip:192.0.2.1")
+	assert.True(t, ok)
+	assert.Equal(t, Usage{
+		Requests:  3,
+		Bytes:     67,
+		Statuses:  map[int]int64{200: 2, 429: 1},
+		Pairs:     map[string]int64{"BTCUSDT": 2, "ETHUSDT": 1},
+		FirstSeen: start,
+		LastSeen:  start.Add(time.Second),
+	}, u)
+
+	// Usage returned is a copy
+	u.Pairs["BTCUSDT"] = 100
+	u, _ = tr.Get("ip:192.0.2.1")
+	assert.Equal(t, int64(2), u.Pairs["BTCUSDT"])
+
+	_, ok = tr.Get("ip:192.0.2.2")
+	assert.False(t, ok)
+	assert.Len(t, tr.All(), 2)
+}
+
+func TestTracker_MaxConsumers(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tr := New(WithMaxConsumers(2))
+	tr.now = func() time.Time { return now }
+
+	for _, consumer := range []string{"ip:a", "ip:b", "ip:a", "ip:c"} {
+		now = now.Add(time.Second)
+		tr.Record(consumer, nil, 200, 1)
+	}
+
+	_, ok := tr.Get("ip:b")
+	assert.False(t, ok, "least recently seen consumer forgotten")
+	assert.Len(t, tr.All(), 2)
+}
+
+func TestTracker_MaxConsumers_Load(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := &memStore{consumers: map[string]Usage{
+		"ip:a": {Requests: 1, LastSeen: now.Add(2 * time.Second)},
+		"ip:b": {Requests: 1, LastSeen: now},
+		"ip:c": {Requests: 1, LastSeen: now.Add(time.Second)},
+	}}
+	tr := New(WithStore(store), WithMaxConsumers(2))
+
+	_, ok := tr.Get("ip:b")
+	assert.False(t, ok, "least recently seen consumer forgotten")
+
+	// ip:c was seen before ip:a, so it goes next
+	tr.Record("ip:d", nil, 200, 1)
+	_, ok = tr.Get("ip:c")
+	assert.False(t, ok)
+	_, ok = tr.Get("ip:a")
+	assert.True(t, ok)
+}
+
+func TestTracker_MaxPairs(t *testing.T) {
+	tr := New()
+
+	pairs := make([]string, MaxPairs+2)
+	for i := range pairs {
+		pairs[i] = fmt.Sprintf("PAIR%dUSDT", i)
+	}
+	tr.Record("ip:a", pairs, 200, 1)
+	tr.Record("ip:a", pairs[:1], 200, 1)
+
+	u, _ := tr.Get("ip:a")
+	assert.Len(t, u.Pairs, MaxPairs+1)
+	assert.Equal(t, int64(2), u.Pairs[OtherPairs])
+	assert.Equal(t, int64(2), u.Pairs["PAIR0USDT"])
+}
+
+func TestTracker_Save(t *testing.T) {
+	store := &memStore{consumers: map[string]Usage{"ip:a": {Requests: 5, Statuses: map[int]int64{200: 5}}}}
+	tr := New(WithStore(store))
+
+	u, ok := tr.Get("ip:a")
+	assert.True(t, ok)
+	assert.Equal(t, int64(5), u.Requests)
+
+	// Nothing changed since loading
+	assert.NoError(t, tr.Save())
+	assert.Equal(t, 0, store.saves)
+
+	tr.Record("ip:a", nil, 200, 1)
+	store.err = errors.New("disk full")
+	assert.EqualError(t, tr.Save(), "disk full")
+
+	store.err = nil
+	assert.NoError(t, tr.Save())
+	assert.Equal(t, 1, store.saves)
+	assert.Equal(t, int64(6), store.consumers["ip:a"].Requests)
+
+	assert.NoError(t, tr.Save())
+	assert.Equal(t, 1, store.saves)
+}
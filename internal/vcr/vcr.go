@@ -0,0 +1,265 @@
+// Package vcr records responses of exchanges to a cassette file and replays
+// them deterministically, for tests and offline runs against real payloads.
+package vcr
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Interaction represents a recorded request and the response to it
+type Interaction struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body"`
+}
+
+// Cassette represents the interactions of a recording in the order they
+// were made
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Load reads the cassette of path
+func Load(path string) (*Cassette, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read cassette: %w", err)
+	}
+
+	var c Cassette
+	if err = json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("parse cassette %s: %w", path, err)
+	}
+
+	return &c, nil
+}
+
+// Save writes c to path
+func (c *Cassette) Save(path string) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode cassette: %w", err)
+	}
+
+	if err = os.WriteFile(path, b, 0o600); err != nil {
+		return fmt.Errorf("write cassette: %w", err)
+	}
+
+	return nil
+}
+
+// secretParams are the query parameters signed requests carry credentials in,
+// recorded and matched with their values redacted
+var secretParams = []string{"api_key", "apikey", "access_token", "token", "signature", "sign"}
+
+// redacted returns the URL of u with the values of secretParams redacted
+func redacted(u *url.URL) string {
+	q := u.Query()
+	found := false
+	for k := range q {
+		if slices.Contains(secretParams, strings.ToLower(k)) {
+			q.Set(k, "REDACTED")
+			found = true
+		}
+	}
+	if !found {
+		return u.String()
+	}
+
+	c := *u
+	c.RawQuery = q.Encode()
+
+	return c.String()
+}
+
+// Recorder passes requests on to the next transport and appends their
+// responses to the cassette, which is valid after every one of them. Headers
+// of requests are not recorded, and secretParams are redacted.
+type Recorder struct {
+	path string
+	next http.RoundTripper
+
+	mu     sync.Mutex
+	file   *os.File
+	closed bool
+	// end is the offset of the end of the last interaction in file
+	end int64
+}
+
+// cassetteHead and cassetteTail enclose the interactions appended to a
+// cassette file
+const (
+	cassetteHead = "{\n  \"interactions\": ["
+	cassetteTail = "\n  ]\n}\n"
+)
+
+// NewRecorder creates a recorder to path of the responses of next,
+// http.DefaultTransport when nil
+func NewRecorder(path string, next http.RoundTripper) *Recorder {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &Recorder{path: path, next: next}
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	header := resp.Header.Clone()
+	header.Del("Set-Cookie")
+
+	err = r.record(Interaction{
+		Method: req.Method,
+		URL:    redacted(req.URL),
+		Status: resp.StatusCode,
+		Header: header,
+		Body:   string(body),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// record writes i over the tail of the cassette file followed by the tail,
+// creating the file on the first interaction
+func (r *Recorder) record(i Interaction) error {
+	b, err := json.MarshalIndent(i, "    ", "  ")
+	if err != nil {
+		return fmt.Errorf("encode interaction: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return errClosed
+	}
+
+	sep := ",\n    "
+	if r.file == nil {
+		if r.file, err = os.OpenFile(r.path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600); err != nil {
+			return fmt.Errorf("create cassette: %w", err)
+		}
+		if _, err = r.file.WriteString(cassetteHead); err != nil {
+			return fmt.Errorf("write cassette: %w", err)
+		}
+		r.end = int64(len(cassetteHead))
+		sep = "\n    "
+	}
+
+	entry := append([]byte(sep), b...)
+	if _, err = r.file.WriteAt(append(entry, cassetteTail...), r.end); err != nil {
+		return fmt.Errorf("write cassette: %w", err)
+	}
+	r.end += int64(len(entry))
+
+	return nil
+}
+
+// errClosed is returned for requests to a closed recorder
+var errClosed = errors.New("vcr: recorder closed")
+
+// Close closes the cassette file, failing later requests
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closed = true
+	if r.file == nil {
+		return nil
+	}
+
+	return r.file.Close()
+}
+
+// Replayer responds to requests with the recorded responses to the same
+// method and URL, in the order they were recorded. The last response to a
+// request is repeated once all of them have been replayed.
+type Replayer struct {
+	mu    sync.Mutex
+	queue map[string][]Interaction
+}
+
+// ErrNotRecorded is returned for requests without a recorded response
+var ErrNotRecorded = errors.New("vcr: no recorded response")
+
+// NewReplayer creates a replayer of the cassette of path
+func NewReplayer(path string) (*Replayer, error) {
+	c, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewCassetteReplayer(c), nil
+}
+
+// NewCassetteReplayer creates a replayer of c
+func NewCassetteReplayer(c *Cassette) *Replayer {
+	r := &Replayer{queue: make(map[string][]Interaction)}
+	for _, i := range c.Interactions {
+		k := i.Method + " " + i.URL
+		r.queue[k] = append(r.queue[k], i)
+	}
+
+	return r
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	k := req.Method + " " + redacted(req.URL)
+
+	r.mu.Lock()
+	queue := r.queue[k]
+	if len(queue) == 0 {
+		r.mu.Unlock()
+		return nil, fmt.Errorf("%w to %s", ErrNotRecorded, k)
+	}
+	i := queue[0]
+	if len(queue) > 1 {
+		r.queue[k] = queue[1:]
+	}
+	r.mu.Unlock()
+
+	header := i.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", i.Status, http.StatusText(i.Status)),
+		StatusCode:    i.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(i.Body))),
+		ContentLength: int64(len(i.Body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,116 @@
+package vcr
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func get(t *testing.T, rt http.RoundTripper, url string) (int, string) {
+	t.Helper()
+
+	req, _ := http.NewRequest(http.MethodGet, url, http.NoBody)
+	resp, err := rt.RoundTrip(req)
+	if !assert.NoError(t, err) {
+		return 0, ""
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestRecordReplay(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Header().Set("X-Mbx-Used-Weight", "2")
+		if r.URL.Query().Get("symbol") != "BTCUSDT" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"code":-1121,"msg":"Invalid symbol."}`))
+			return
+		}
+		if calls.Add(1) == 1 {
+			_, _ = w.Write([]byte(`{"symbol":"BTCUSDT","price":"97000.00"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"symbol":"BTCUSDT","price":"97001.00"}`))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "upstream.json")
+	rec := NewRecorder(path, nil)
+	status, body := get(t, rec, srv.URL+"?symbol=BTCUSDT")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `{"symbol":"BTCUSDT","price":"97000.00"}`, body)
+	get(t, rec, srv.URL+"?symbol=BTCUSDT")
+	get(t, rec, srv.URL+"?symbol=NOPE")
+
+	c, err := Load(path)
+	assert.NoError(t, err)
+	assert.Len(t, c.Interactions, 3)
+	assert.Empty(t, c.Interactions[0].Header.Get("Set-Cookie"))
+	assert.Equal(t, "2", c.Interactions[0].Header.Get("X-Mbx-Used-Weight"))
+
+	// Responses replay in order, the last one repeating
+	srv.Close()
+	rep, err := NewReplayer(path)
+	assert.NoError(t, err)
+	for _, expected := range []string{"97000.00", "97001.00", "97001.00"} {
+		status, body = get(t, rep, srv.URL+"?symbol=BTCUSDT")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Contains(t, body, expected)
+	}
+
+	status, body = get(t, rep, srv.URL+"?symbol=NOPE")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, body, "Invalid symbol.")
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"?symbol=ETHUSDT", http.NoBody)
+	_, err = rep.RoundTrip(req)
+	assert.ErrorIs(t, err, ErrNotRecorded)
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	_, err := Load(filepath.Join(t.TempDir(), "missing.json"))
+	assert.ErrorContains(t, err, "read cassette")
+}
+
+func TestRecorder_Redacted(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"balance":"1"}`))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "upstream.json")
+	rec := NewRecorder(path, nil)
+	get(t, rec, srv.URL+"?symbol=BTCUSDT&timestamp=1&signature=secret")
+	get(t, rec, srv.URL+"?apiKey=secret")
+	assert.NoError(t, rec.Close())
+
+	c, err := Load(path)
+	assert.NoError(t, err)
+	if assert.Len(t, c.Interactions, 2) {
+		assert.Equal(t, srv.URL+"?signature=REDACTED&symbol=BTCUSDT&timestamp=1", c.Interactions[0].URL)
+		assert.Equal(t, srv.URL+"?apiKey=REDACTED", c.Interactions[1].URL)
+	}
+
+	// Requests signed with other credentials replay the same responses
+	rep := NewCassetteReplayer(c)
+	status, body := get(t, rep, srv.URL+"?symbol=BTCUSDT&timestamp=1&signature=other")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `{"balance":"1"}`, body)
+
+	// Closed recorders record nothing more
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, http.NoBody)
+	_, err = rec.RoundTrip(req)
+	assert.ErrorIs(t, err, errClosed)
+	c, err = Load(path)
+	assert.NoError(t, err)
+	assert.Len(t, c.Interactions, 2)
+}
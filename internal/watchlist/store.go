@@ -0,0 +1,81 @@
+package watchlist
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Store is the interface that wraps watchlist persistence methods
+type Store interface {
+	Load() (map[string][]string, error)
+	Save(lists map[string][]string) error
+}
+
+// FileStore persists watchlists as a JSON file
+type FileStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileStore creates a new file store at path
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// Load reads watchlists from the file. Missing file yields no watchlists.
+func (f *FileStore) Load() (map[string][]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string][]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read watchlists: %w", err)
+	}
+
+	lists := map[string][]string{}
+	if err := json.Unmarshal(b, &lists); err != nil {
+		return nil, fmt.Errorf("parse watchlists: %w", err)
+	}
+
+	return lists, nil
+}
+
+// Save writes watchlists to the file atomically
+func (f *FileStore) Save(lists map[string][]string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b, err := json.MarshalIndent(lists, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal watchlists: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write watchlists: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close temp file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("rename temp file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package watchlist
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "watchlists.json")
+	f := NewFileStore(path)
+
+	lists, err := f.Load()
+	assert.NoError(t, err)
+	assert.Empty(t, lists)
+
+	assert.NoError(t, f.Save(map[string][]string{"partner": {"BTCUSDT", "ETHUSDT"}}))
+
+	lists, err = f.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]string{"partner": {"BTCUSDT", "ETHUSDT"}}, lists)
+}
+
+func TestFileStore_LoadInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "watchlists.json")
+	assert.NoError(t, os.WriteFile(path, []byte("{"), 0o600))
+
+	_, err := NewFileStore(path).Load()
+	assert.Error(t, err)
+}
+
+func TestFileStore_SaveMissingDir(t *testing.T) {
+	f := NewFileStore(filepath.Join(t.TempDir(), "missing", "watchlists.json"))
+	assert.Error(t, f.Save(map[string][]string{}))
+}
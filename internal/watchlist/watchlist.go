@@ -0,0 +1,162 @@
+// Package watchlist keeps the pairs API consumers watch.
+package watchlist
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/ivanglie/coinmon/pkg/log"
+)
+
+// MaxPairs is the maximum number of pairs on a watchlist
+const MaxPairs = 50
+
+var (
+	// ErrInvalidPair is returned for pairs other than 2 to 20 letters and digits
+	ErrInvalidPair = errors.New("invalid pair")
+	// ErrTooManyPairs is returned when a watchlist would exceed MaxPairs
+	ErrTooManyPairs = fmt.Errorf("watchlists are limited to %d pairs", MaxPairs)
+)
+
+var pairRe = regexp.MustCompile(`^[A-Z0-9]{2,20}$`)
+
+// Watchlist represents the pairs watched by every consumer
+type Watchlist struct {
+	mu    sync.RWMutex
+	lists map[string][]string
+	store Store
+}
+
+// Option configures a Watchlist
+type Option func(*Watchlist)
+
+// WithStore persists watchlists in store
+func WithStore(store Store) Option {
+	return func(w *Watchlist) {
+		w.store = store
+	}
+}
+
+// New creates watchlists, loaded from the store if set
+func New(opts ...Option) *Watchlist {
+	w := &Watchlist{lists: make(map[string][]string)}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	if w.store != nil {
+		lists, err := w.store.Load()
+		if err != nil {
+			log.Error("Failed to load watchlists: " + err.Error())
+		}
+
+		for owner, pairs := range lists {
+			w.lists[owner] = pairs
+		}
+	}
+
+	return w
+}
+
+// Get returns the pairs watched by owner
+func (w *Watchlist) Get(owner string) []string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return slices.Clone(w.lists[owner])
+}
+
+// Set replaces the pairs watched by owner
+func (w *Watchlist) Set(owner string, pairs []string) ([]string, error) {
+	normalized, err := normalize(pairs)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.update(owner, func([]string) []string { return normalized })
+}
+
+// Add adds pairs to the watchlist of owner
+func (w *Watchlist) Add(owner string, pairs ...string) ([]string, error) {
+	normalized, err := normalize(pairs)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.update(owner, func(current []string) []string {
+		for _, p := range normalized {
+			if !slices.Contains(current, p) {
+				current = append(current, p)
+			}
+		}
+		return current
+	})
+}
+
+// Remove removes pair from the watchlist of owner
+func (w *Watchlist) Remove(owner, pair string) ([]string, error) {
+	pair = strings.ToUpper(pair)
+	return w.update(owner, func(current []string) []string {
+		return slices.DeleteFunc(current, func(p string) bool { return p == pair })
+	})
+}
+
+// Pairs returns the pairs watched by anyone in alphabetical order
+func (w *Watchlist) Pairs() []string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	var pairs []string
+	for _, list := range w.lists {
+		pairs = append(pairs, list...)
+	}
+	slices.Sort(pairs)
+
+	return slices.Compact(pairs)
+}
+
+// update replaces the watchlist of owner with the result of fn and saves
+// all watchlists
+func (w *Watchlist) update(owner string, fn func(current []string) []string) ([]string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	pairs := fn(slices.Clone(w.lists[owner]))
+	if len(pairs) > MaxPairs {
+		return nil, ErrTooManyPairs
+	}
+
+	if len(pairs) == 0 {
+		delete(w.lists, owner)
+	} else {
+		w.lists[owner] = pairs
+	}
+
+	if w.store != nil {
+		if err := w.store.Save(w.lists); err != nil {
+			log.Error("Failed to save watchlists: " + err.Error())
+		}
+	}
+
+	return slices.Clone(pairs), nil
+}
+
+// normalize returns valid pairs in upper case without duplicates
+func normalize(pairs []string) ([]string, error) {
+	normalized := make([]string, 0, len(pairs))
+	for _, p := range pairs {
+		p = strings.ToUpper(strings.TrimSpace(p))
+		if !pairRe.MatchString(p) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidPair, p)
+		}
+		if !slices.Contains(normalized, p) {
+			normalized = append(normalized, p)
+		}
+	}
+
+	return normalized, nil
+}
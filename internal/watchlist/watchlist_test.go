@@ -0,0 +1,67 @@
+package watchlist
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchlist(t *testing.T) {
+	w := New()
+	assert.Empty(t, w.Get("a"))
+
+	pairs, err := w.Add("a", "btcusdt", "ETHUSDT", "BTCUSDT")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"BTCUSDT", "ETHUSDT"}, pairs)
+
+	pairs, err = w.Add("a", "SOLUSDT", "ETHUSDT")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"BTCUSDT", "ETHUSDT", "SOLUSDT"}, pairs)
+
+	_, err = w.Set("b", []string{"XRPUSDT", "BTCUSDT"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"BTCUSDT", "ETHUSDT", "SOLUSDT", "XRPUSDT"}, w.Pairs())
+
+	pairs, err = w.Remove("a", "ethusdt")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"BTCUSDT", "SOLUSDT"}, pairs)
+	assert.Equal(t, []string{"BTCUSDT", "SOLUSDT"}, w.Get("a"))
+
+	_, err = w.Set("b", nil)
+	assert.NoError(t, err)
+	assert.Empty(t, w.Get("b"))
+	assert.Equal(t, []string{"BTCUSDT", "SOLUSDT"}, w.Pairs())
+}
+
+func TestWatchlist_invalid(t *testing.T) {
+	w := New()
+
+	for _, pair := range []string{"", "B", "BTC/USDT", "../etc", "AVERYLONGPAIRNAMEOVER20"} {
+		_, err := w.Add("a", pair)
+		assert.ErrorIs(t, err, ErrInvalidPair, pair)
+	}
+
+	many := make([]string, MaxPairs+1)
+	for i := range many {
+		many[i] = "PAIR" + strconv.Itoa(i)
+	}
+	_, err := w.Set("a", many)
+	assert.ErrorIs(t, err, ErrTooManyPairs)
+
+	_, err = w.Set("a", many[:MaxPairs])
+	assert.NoError(t, err)
+	_, err = w.Add("a", many[MaxPairs])
+	assert.ErrorIs(t, err, ErrTooManyPairs)
+	assert.Len(t, w.Get("a"), MaxPairs, "failed updates must not change the watchlist")
+}
+
+func TestWatchlist_store(t *testing.T) {
+	store := NewFileStore(filepath.Join(t.TempDir(), "watchlists.json"))
+
+	_, err := New(WithStore(store)).Add("a", "BTCUSDT")
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"BTCUSDT"}, New(WithStore(store)).Get("a"))
+}
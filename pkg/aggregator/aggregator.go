@@ -0,0 +1,310 @@
+// Package aggregator resolves the price of a trading pair from several
+// exchanges at once, answering with the fastest of them.
+package aggregator
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/ivanglie/coinmon/pkg/log"
+)
+
+// FetchFunc returns the quote of pair on a single exchange. Quotes without a
+// Pair, Source or Time get the pair, the name of the source and the time
+// they were returned at.
+type FetchFunc func(ctx context.Context, pair string) (Quote, error)
+
+// Source represents an exchange prices are fetched from
+type Source struct {
+	Name  string
+	Fetch FetchFunc
+}
+
+// Quote represents the price of a pair and the exchange it came from
+type Quote struct {
+	Pair   string
+	Price  float64
+	Source string
+	Time   time.Time
+}
+
+// Options represents options of a single Price call
+type Options struct {
+	// Sources are the names of the sources to call, all of them when empty
+	Sources []string
+	// Priorities of sources by name, 0 when unset. Sources of the highest
+	// priority are raced first, the lower ones only when all of them fail.
+	Priorities map[string]int
+	// Retries are the attempts made again at a source failing with a
+	// transient error or running out of time, 0 by default
+	Retries int
+	// Hedge calls the sources of the next priority when the called ones have
+	// not answered within Hedge, without waiting for all of them to fail.
+	// Zero disables hedging.
+	Hedge time.Duration
+}
+
+// ErrBackoff marks errors of sources asking not to be called for a while,
+// e.g. after a 429 Too Many Requests response. They are not retried.
+var ErrBackoff = errors.New("source backing off")
+
+// ErrTransient marks errors of sources worth calling again, e.g. network
+// errors and 5xx responses. Other errors, e.g. of unknown pairs, are not
+// retried.
+var ErrTransient = errors.New("transient source error")
+
+const (
+	msgFailed   = "all exchanges failed"
+	msgDeadline = "deadline exceeded"
+	// retryDelay is the wait before the first retry at a source, doubling
+	// before every next one
+	retryDelay = 50 * time.Millisecond
+)
+
+// Error represents the failures of all sources to quote a pair, or the
+// sources still pending at the deadline of the call. Its message is the
+// JSON encoding of it.
+type Error struct {
+	Message string   `json:"message"`
+	Errors  []string `json:"errors"`
+	Pending []string `json:"pending,omitempty"`
+}
+
+func (e *Error) Error() string {
+	b, _ := json.Marshal(e) // strings always encode
+	return string(b)
+}
+
+// Unwrap returns context.DeadlineExceeded for calls running out of time
+func (e *Error) Unwrap() error {
+	if e.Message == msgDeadline {
+		return context.DeadlineExceeded
+	}
+
+	return nil
+}
+
+// Aggregator races sources for prices. It is safe for concurrent use.
+type Aggregator struct {
+	sources []Source
+}
+
+// New creates an aggregator of sources
+func New(sources ...Source) *Aggregator {
+	return &Aggregator{sources: sources}
+}
+
+// result represents the outcome of calling a source
+type result struct {
+	quote  Quote
+	source string
+	err    error
+}
+
+// Price races the sources of the highest priority for the price of pair,
+// falling back to the sources of lower priorities when all of them fail.
+// The returned error is an *Error when no source could quote pair.
+//
+// When ctx has a deadline, retries and fallbacks share the time left: every
+// priority gets an even share of it, split evenly between the attempts at a
+// source, and the sources of the last priority get all that is left. At the
+// deadline, the error names the sources still pending.
+func (a *Aggregator) Price(ctx context.Context, pair string, opts Options) (Quote, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	tiers := a.tiers(opts)
+	results := make(chan result, len(a.sources))
+
+	var (
+		errs    []string
+		pending []string // called sources yet to answer, in call order
+		next    int      // index of the next tier to call
+		hedge   <-chan time.Time
+	)
+	call := func() {
+		deadline := tierDeadline(ctx, len(tiers)-next)
+		for _, src := range tiers[next] {
+			pending = append(pending, src.Name)
+			go func(src Source) {
+				q, err := fetch(ctx, src, pair, deadline, opts.Retries)
+				// results is buffered for every source, so the send never blocks
+				results <- result{q, src.Name, err}
+			}(src)
+		}
+		next++
+
+		hedge = nil
+		if opts.Hedge > 0 && next < len(tiers) {
+			hedge = time.After(opts.Hedge)
+		}
+	}
+
+	if len(tiers) > 0 {
+		call()
+	}
+	for len(pending) > 0 {
+		select {
+		case r := <-results:
+			if r.err != nil && (ctx.Err() != nil || expired(ctx, r.err)) {
+				return Quote{}, deadlineError(ctx, errs, pending)
+			}
+			pending = slices.DeleteFunc(pending, func(name string) bool { return name == r.source })
+
+			l := log.FromContext(log.WithFields(ctx, log.Fields{"exchange": r.source}))
+			if r.err == nil {
+				l.Info(fmt.Sprintf("Got price %.2f from %s", r.quote.Price, r.source))
+				return r.quote, nil
+			}
+
+			errMsg := fmt.Sprintf("%s: %v", r.source, r.err)
+			l.Error("Error from " + errMsg)
+			errs = append(errs, errMsg)
+			if len(pending) == 0 && next < len(tiers) {
+				call()
+			}
+		case <-hedge:
+			log.FromContext(ctx).Info(fmt.Sprintf("No price within %s, calling sources of lower priority", opts.Hedge))
+			call()
+		case <-ctx.Done():
+			return Quote{}, deadlineError(ctx, errs, pending)
+		}
+	}
+
+	err := &Error{Message: msgFailed, Errors: errs}
+	log.FromContext(ctx).Error(err.Error())
+	return Quote{}, err
+}
+
+// expired reports whether err is an attempt running out of time at the
+// deadline of ctx. The timer of an attempt ending at that deadline may fire
+// before ctx is done.
+func expired(ctx context.Context, err error) bool {
+	deadline, ok := ctx.Deadline()
+	return ok && errors.Is(err, context.DeadlineExceeded) && !time.Now().Before(deadline)
+}
+
+// deadlineError returns the error of a call running out of time with the
+// sources still pending, or ctx.Err() when it was canceled
+func deadlineError(ctx context.Context, errs, pending []string) error {
+	if err := ctx.Err(); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	err := &Error{Message: msgDeadline, Errors: errs, Pending: slices.Clone(pending)}
+	if err.Errors == nil {
+		err.Errors = []string{}
+	}
+	log.FromContext(ctx).Error(err.Error())
+	return err
+}
+
+// tierDeadline returns the end of the share of the time left until the
+// deadline of ctx for the next of tiersLeft tiers, zero without a deadline
+func tierDeadline(ctx context.Context, tiersLeft int) time.Time {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return time.Time{}
+	}
+
+	now := time.Now()
+	return now.Add(deadline.Sub(now) / time.Duration(tiersLeft))
+}
+
+// fetch calls src for the price of pair up to 1+retries times until
+// deadline, unbounded when zero, giving every attempt an even share of the
+// time left. Only attempts failing with ErrTransient or running out of time
+// are retried, after retryDelay doubling at every retry.
+func fetch(ctx context.Context, src Source, pair string, deadline time.Time, retries int) (Quote, error) {
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			delay := retryDelay << (attempt - 1)
+			if !deadline.IsZero() && time.Until(deadline) <= delay {
+				return Quote{}, err
+			}
+
+			t := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return Quote{}, err
+			case <-t.C:
+			}
+		}
+
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if !deadline.IsZero() {
+			now := time.Now()
+			attemptCtx, cancel = context.WithDeadline(ctx, now.Add(deadline.Sub(now)/time.Duration(retries+1-attempt)))
+		}
+
+		var q Quote
+		q, err = src.Fetch(attemptCtx, pair)
+		timedOut := attemptCtx.Err() != nil
+		cancel()
+		if err == nil {
+			return complete(q, src, pair), nil
+		}
+		if ctx.Err() != nil || errors.Is(err, ErrBackoff) || !(timedOut || errors.Is(err, ErrTransient)) {
+			return Quote{}, err
+		}
+	}
+
+	return Quote{}, err
+}
+
+// complete fills the Pair, Source and Time missing from q, a quote of pair
+// by src
+func complete(q Quote, src Source, pair string) Quote {
+	q.Pair = cmp.Or(q.Pair, pair)
+	q.Source = cmp.Or(q.Source, src.Name)
+	if q.Time.IsZero() {
+		q.Time = time.Now().UTC()
+	}
+
+	return q
+}
+
+// Tiers returns the names of the sources Price calls with opts, grouped by
+// priority, highest first
+func (a *Aggregator) Tiers(opts Options) [][]string {
+	tiers := a.tiers(opts)
+	names := make([][]string, 0, len(tiers))
+	for _, tier := range tiers {
+		var tierNames []string
+		for _, src := range tier {
+			tierNames = append(tierNames, src.Name)
+		}
+		names = append(names, tierNames)
+	}
+
+	return names
+}
+
+func (a *Aggregator) tiers(opts Options) [][]Source {
+	sources := slices.Clone(a.sources)
+	if len(opts.Sources) > 0 {
+		sources = slices.DeleteFunc(sources, func(src Source) bool {
+			return !slices.Contains(opts.Sources, src.Name)
+		})
+	}
+	slices.SortStableFunc(sources, func(a, b Source) int {
+		return cmp.Compare(opts.Priorities[b.Name], opts.Priorities[a.Name])
+	})
+
+	var tiers [][]Source
+	for i, src := range sources {
+		if i == 0 || opts.Priorities[src.Name] != opts.Priorities[sources[i-1].Name] {
+			tiers = append(tiers, nil)
+		}
+		tiers[len(tiers)-1] = append(tiers[len(tiers)-1], src)
+	}
+
+	return tiers
+}
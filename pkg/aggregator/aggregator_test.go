@@ -0,0 +1,218 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fixed(price float64, delay time.Duration, err error) FetchFunc {
+	return func(ctx context.Context, _ string) (Quote, error) {
+		select {
+		case <-ctx.Done():
+			return Quote{}, ctx.Err()
+		case <-time.After(delay):
+		}
+		return Quote{Price: price}, err
+	}
+}
+
+func TestAggregator_Price(t *testing.T) {
+	a := New(
+		Source{Name: "slow", Fetch: fixed(1, 100*time.Millisecond, nil)},
+		Source{Name: "fast", Fetch: fixed(2, 0, nil)},
+		Source{Name: "broken", Fetch: fixed(0, 0, errors.New("unexpected status code: 503"))},
+	)
+
+	q, err := a.Price(context.Background(), "BTCUSDT", Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, "BTCUSDT", q.Pair)
+	assert.Equal(t, 2.0, q.Price)
+	assert.Equal(t, "fast", q.Source)
+	assert.False(t, q.Time.IsZero())
+
+	// Quotes keep the time and source their fetchers report
+	quoted := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	q, err = New(Source{Name: "feed", Fetch: func(context.Context, string) (Quote, error) {
+		return Quote{Price: 3, Source: "binance", Time: quoted}, nil
+	}}).Price(context.Background(), "BTCUSDT", Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, Quote{Pair: "BTCUSDT", Price: 3, Source: "binance", Time: quoted}, q)
+
+	_, err = a.Price(context.Background(), "BTCUSDT", Options{Sources: []string{"broken"}})
+	var aggErr *Error
+	assert.True(t, errors.As(err, &aggErr))
+	assert.Equal(t, []string{"broken: unexpected status code: 503"}, aggErr.Errors)
+	assert.EqualError(t, err, `{"message":"all exchanges failed","errors":["broken: unexpected status code: 503"]}`)
+}
+
+func TestAggregator_Price_Priorities(t *testing.T) {
+	var lowCalls atomic.Int32
+	a := New(
+		Source{Name: "low", Fetch: func(context.Context, string) (Quote, error) {
+			lowCalls.Add(1)
+			return Quote{Price: 1}, nil
+		}},
+		Source{Name: "mid", Fetch: fixed(2, 0, nil)},
+		Source{Name: "high", Fetch: fixed(0, 0, errors.New("timeout"))},
+	)
+	opts := Options{Priorities: map[string]int{"high": 2, "mid": 1}}
+
+	// high fails, so mid answers and low is not called
+	q, err := a.Price(context.Background(), "BTCUSDT", opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "mid", q.Source)
+	assert.Equal(t, int32(0), lowCalls.Load())
+
+	assert.Equal(t, [][]string{{"high"}, {"mid"}, {"low"}}, a.Tiers(opts))
+	assert.Equal(t, [][]string{{"low", "mid"}}, a.Tiers(Options{Sources: []string{"low", "mid"}}))
+}
+
+func hang(ctx context.Context, _ string) (Quote, error) {
+	<-ctx.Done()
+	return Quote{}, ctx.Err()
+}
+
+func TestAggregator_Price_Retries(t *testing.T) {
+	var calls atomic.Int32
+	flaky := func(context.Context, string) (Quote, error) {
+		if calls.Add(1) == 1 {
+			return Quote{}, fmt.Errorf("unexpected status code: 503: %w", ErrTransient)
+		}
+		return Quote{Price: 3}, nil
+	}
+	a := New(Source{Name: "flaky", Fetch: flaky})
+
+	_, err := a.Price(context.Background(), "BTCUSDT", Options{})
+	assert.Error(t, err)
+
+	calls.Store(0)
+	q, err := a.Price(context.Background(), "BTCUSDT", Options{Retries: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 3.0, q.Price)
+	assert.Equal(t, int32(2), calls.Load())
+
+	// Retries wait for retryDelay, and not past the deadline
+	calls.Store(0)
+	ctx, cancel := context.WithTimeout(context.Background(), retryDelay/2)
+	defer cancel()
+	_, err = a.Price(ctx, "BTCUSDT", Options{Retries: 1})
+	assert.ErrorContains(t, err, "unexpected status code: 503")
+	assert.Equal(t, int32(1), calls.Load())
+
+	// Errors which are not transient are not retried
+	calls.Store(0)
+	invalid := New(Source{Name: "invalid", Fetch: func(context.Context, string) (Quote, error) {
+		calls.Add(1)
+		return Quote{}, errors.New("code=-1121, msg=Invalid symbol.")
+	}})
+	_, err = invalid.Price(context.Background(), "BTCUSDT", Options{Retries: 2})
+	assert.ErrorContains(t, err, "Invalid symbol.")
+	assert.Equal(t, int32(1), calls.Load())
+
+	// Sources backing off are not called again
+	calls.Store(0)
+	limited := New(Source{Name: "limited", Fetch: func(context.Context, string) (Quote, error) {
+		calls.Add(1)
+		return Quote{}, fmt.Errorf("unexpected status code: 429: %w", ErrBackoff)
+	}})
+	_, err = limited.Price(context.Background(), "BTCUSDT", Options{Retries: 2})
+	assert.ErrorContains(t, err, "unexpected status code: 429")
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestAggregator_Price_Hedge(t *testing.T) {
+	a := New(
+		Source{Name: "slow", Fetch: fixed(1, time.Second, nil)},
+		Source{Name: "backup", Fetch: fixed(2, 0, nil)},
+	)
+	opts := Options{Priorities: map[string]int{"slow": 1}, Hedge: 20 * time.Millisecond}
+
+	start := time.Now()
+	q, err := a.Price(context.Background(), "BTCUSDT", opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "backup", q.Source)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+func TestAggregator_Price_Budget(t *testing.T) {
+	// The hanging source of the highest priority gets half of the budget,
+	// leaving the other half to the fallback
+	a := New(
+		Source{Name: "hanging", Fetch: hang},
+		Source{Name: "fallback", Fetch: fixed(2, 0, nil)},
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	q, err := a.Price(ctx, "BTCUSDT", Options{Priorities: map[string]int{"hanging": 1}})
+	assert.NoError(t, err)
+	assert.Equal(t, "fallback", q.Source)
+	assert.NoError(t, ctx.Err())
+
+	// The first attempt hangs for half of the budget, the retry answers
+	var calls atomic.Int32
+	retried := New(Source{Name: "retried", Fetch: func(ctx context.Context, pair string) (Quote, error) {
+		if calls.Add(1) == 1 {
+			return hang(ctx, pair)
+		}
+		return Quote{Price: 3}, nil
+	}})
+	ctx, cancel = context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	q, err = retried.Price(ctx, "BTCUSDT", Options{Retries: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 3.0, q.Price)
+	assert.NoError(t, ctx.Err())
+}
+
+func TestAggregator_Price_Deadline(t *testing.T) {
+	a := New(
+		Source{Name: "hanging", Fetch: hang},
+		Source{Name: "broken", Fetch: fixed(0, 0, errors.New("unexpected status code: 503"))},
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := a.Price(ctx, "BTCUSDT", Options{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	var aggErr *Error
+	assert.True(t, errors.As(err, &aggErr))
+	assert.Equal(t, &Error{Message: "deadline exceeded", Errors: []string{"broken: unexpected status code: 503"}, Pending: []string{"hanging"}}, aggErr)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = a.Price(ctx, "BTCUSDT", Options{})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestExpired(t *testing.T) {
+	past, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Millisecond))
+	defer cancel()
+	future, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		err      error
+		expected bool
+	}{
+		{name: "at deadline", ctx: past, err: fmt.Errorf("do request: %w", context.DeadlineExceeded), expected: true},
+		{name: "before deadline", ctx: future, err: context.DeadlineExceeded},
+		{name: "other error", ctx: past, err: errors.New("unexpected status code: 503")},
+		{name: "no deadline", ctx: context.Background(), err: context.DeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, expired(tt.ctx, tt.err))
+		})
+	}
+}
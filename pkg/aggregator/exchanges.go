@@ -0,0 +1,143 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ivanglie/coinmon/internal/exchange"
+)
+
+const (
+	// maxResponseBody caps the price responses of exchanges read
+	maxResponseBody = 64 << 10
+	// maxErrorBody caps the error responses of exchanges read
+	maxErrorBody = 4 << 10
+	userAgent    = "coinmon-aggregator"
+)
+
+// Binance returns the source of prices from the public REST API of Binance,
+// called with client, http.DefaultClient when nil
+func Binance(client *http.Client) Source {
+	return exchangeSource(exchange.BINANCE, client)
+}
+
+// Bybit returns the source of prices from the public REST API of Bybit,
+// called with client, http.DefaultClient when nil
+func Bybit(client *http.Client) Source {
+	return exchangeSource(exchange.BYBIT, client)
+}
+
+// Bitget returns the source of prices from the public REST API of Bitget,
+// called with client, http.DefaultClient when nil
+func Bitget(client *http.Client) Source {
+	return exchangeSource(exchange.BITGET, client)
+}
+
+// Kraken returns the source of prices from the public REST API of Kraken,
+// called with client, http.DefaultClient when nil
+func Kraken(client *http.Client) Source {
+	return exchangeSource(exchange.KRAKEN, client)
+}
+
+// Exchanges returns the sources of all built-in exchanges, called with
+// client, http.DefaultClient when nil
+func Exchanges(client *http.Client) []Source {
+	return []Source{Binance(client), Bybit(client), Bitget(client), Kraken(client)}
+}
+
+// markedError is an error of a source matching mark too, e.g. ErrTransient,
+// without changing its message
+type markedError struct {
+	err  error
+	mark error
+}
+
+func (e *markedError) Error() string { return e.err.Error() }
+
+func (e *markedError) Unwrap() []error { return []error{e.err, e.mark} }
+
+// bodyReader keeps the error reading r other than its end, telling failed
+// reads from malformed responses
+type bodyReader struct {
+	r   io.Reader
+	err error
+}
+
+func (b *bodyReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err != nil && b.err == nil {
+		b.err = err
+	}
+	return n, err
+}
+
+// exchangeSource returns the source of prices from the REST API of the
+// exchange n
+func exchangeSource(n exchange.Name, client *http.Client) Source {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	e := exchange.New(n)
+
+	return Source{
+		Name: n.String(),
+		Fetch: func(ctx context.Context, pair string) (Quote, error) {
+			price, err := fetchExchange(ctx, client, e, pair)
+			if err != nil {
+				return Quote{}, err
+			}
+
+			return Quote{Pair: pair, Price: price, Source: n.String(), Time: time.Now().UTC()}, nil
+		},
+	}
+}
+
+// fetchExchange requests the price of pair from the REST API of e. Network
+// errors and 5xx responses are ErrTransient, 429 and 418 responses ErrBackoff.
+func fetchExchange(ctx context.Context, client *http.Client, e *exchange.Exchange, pair string) (float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.PriceURL(pair), http.NoBody)
+	if err != nil {
+		return 0, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, &markedError{fmt.Errorf("do request: %w", err), ErrTransient}
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		if err != nil {
+			return 0, &markedError{fmt.Errorf("read body: %w", err), ErrTransient}
+		}
+
+		statusErr := &exchange.StatusError{StatusCode: resp.StatusCode, Body: string(body)}
+		if apiErr, ok := exchange.ParseError(e.Name, body); ok {
+			statusErr.Err = apiErr
+		}
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusTeapot:
+			return 0, &markedError{statusErr, ErrBackoff}
+		case resp.StatusCode >= http.StatusInternalServerError:
+			return 0, &markedError{statusErr, ErrTransient}
+		}
+		return 0, statusErr
+	}
+
+	body := &bodyReader{r: io.LimitReader(resp.Body, maxResponseBody)}
+	price, err := exchange.ReadPrice(e.Name, pair, body)
+	if body.err != nil && !errors.Is(body.err, io.EOF) {
+		return 0, &markedError{fmt.Errorf("read body: %w", body.err), ErrTransient}
+	}
+
+	return price, err
+}
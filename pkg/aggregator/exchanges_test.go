@@ -0,0 +1,125 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// respond answers every request with status and body
+func respond(status int, body string) *http.Client {
+	return &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+}
+
+func TestExchanges(t *testing.T) {
+	var hosts []string
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		hosts = append(hosts, req.URL.Host)
+		return nil, errors.New("connection refused")
+	})}
+
+	sources := Exchanges(client)
+	for _, src := range sources {
+		_, err := src.Fetch(context.Background(), "BTCUSDT")
+		assert.ErrorIs(t, err, ErrTransient, src.Name)
+	}
+	assert.Equal(t, []string{"api.binance.com", "api.bybit.com", "api.bitget.com", "api.kraken.com"}, hosts)
+	assert.Equal(t, "kraken", sources[3].Name)
+}
+
+func TestExchangeSources(t *testing.T) {
+	tests := []struct {
+		name          string
+		source        func(*http.Client) Source
+		status        int
+		body          string
+		expectedPrice float64
+		expectedError string
+		expectedIs    error
+	}{
+		{
+			name:          "binance",
+			source:        Binance,
+			status:        http.StatusOK,
+			body:          `{"symbol":"BTCUSDT","price":"97123.45"}`,
+			expectedPrice: 97123.45,
+		},
+		{
+			name:          "bybit",
+			source:        Bybit,
+			status:        http.StatusOK,
+			body:          `{"retCode":0,"retMsg":"OK","result":{"category":"spot","list":[{"symbol":"BTCUSDT","lastPrice":"97120.55"}]}}`,
+			expectedPrice: 97120.55,
+		},
+		{
+			name:          "bitget",
+			source:        Bitget,
+			status:        http.StatusOK,
+			body:          `{"code":"00000","msg":"success","data":[{"symbol":"BTCUSDT","lastPr":"97118.73"}]}`,
+			expectedPrice: 97118.73,
+		},
+		{
+			name:          "kraken",
+			source:        Kraken,
+			status:        http.StatusOK,
+			body:          `{"error":[],"result":{"XBTUSDT":{"c":["97125.10000","0.001"]}}}`,
+			expectedPrice: 97125.1,
+		},
+		{
+			name:          "unknown pair",
+			source:        Binance,
+			status:        http.StatusBadRequest,
+			body:          `{"code":-1121,"msg":"Invalid symbol."}`,
+			expectedError: "code=-1121, msg=Invalid symbol.",
+		},
+		{
+			name:          "rate limited",
+			source:        Binance,
+			status:        http.StatusTooManyRequests,
+			body:          `{"code":-1003,"msg":"Too many requests."}`,
+			expectedError: "code=-1003, msg=Too many requests.",
+			expectedIs:    ErrBackoff,
+		},
+		{
+			name:          "server error",
+			source:        Kraken,
+			status:        http.StatusBadGateway,
+			body:          "Bad Gateway",
+			expectedError: "unexpected status code: 502, body: Bad Gateway",
+			expectedIs:    ErrTransient,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := tt.source(respond(tt.status, tt.body))
+			q, err := src.Fetch(context.Background(), "BTCUSDT")
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				if tt.expectedIs != nil {
+					assert.ErrorIs(t, err, tt.expectedIs)
+				} else {
+					assert.NotErrorIs(t, err, ErrTransient)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedPrice, q.Price)
+			assert.Equal(t, src.Name, q.Source)
+			assert.False(t, q.Time.IsZero())
+		})
+	}
+}
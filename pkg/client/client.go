@@ -0,0 +1,276 @@
+// Package client provides a Go client of the coinmon API.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+	defaultRetries = 2
+	defaultBackoff = 200 * time.Millisecond
+	// maxRetryAfter caps how long a Retry-After header makes the client wait
+	maxRetryAfter = 30 * time.Second
+	// maxErrorBody caps the error responses of streams read
+	maxErrorBody = 4 << 10
+	userAgent    = "coinmon-go-client"
+)
+
+// Quote represents the price of a pair and the exchange it came from
+type Quote struct {
+	Pair   string    `json:"pair"`
+	Price  float64   `json:"price"`
+	Source string    `json:"source"`
+	Time   time.Time `json:"time"`
+}
+
+// APIError represents an error reported by the API. Source is the exchange
+// or pair the error is about, if any.
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Source  string `json:"source,omitempty"`
+}
+
+// Error represents a failed request, or the pairs of a batch that could not
+// be quoted
+type Error struct {
+	StatusCode int
+	Errors     []APIError
+}
+
+func (e *Error) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, ae := range e.Errors {
+		if ae.Source != "" {
+			msgs = append(msgs, ae.Source+": "+ae.Message)
+			continue
+		}
+		msgs = append(msgs, ae.Message)
+	}
+
+	return fmt.Sprintf("coinmon: status %d: %s", e.StatusCode, strings.Join(msgs, "; "))
+}
+
+// Client calls the API of a coinmon instance. It is safe for concurrent use.
+type Client struct {
+	baseURL string
+	http    *http.Client
+	apiKey  string
+	timeout time.Duration
+	retries int
+	backoff time.Duration
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sends requests with hc instead of http.DefaultClient
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.http = hc
+	}
+}
+
+// WithAPIKey sends key in the X-API-Key header of every request
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// WithTimeout limits every attempt of a request to d, 10s by default
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = d
+	}
+}
+
+// WithRetries retries failed requests n times, 2 by default, waiting
+// backoff before the first retry and twice as long before every next one.
+// Requests failing with network errors, 429 or 5xx responses are retried.
+func WithRetries(n int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = n
+		c.backoff = backoff
+	}
+}
+
+// New creates a client of the instance at baseURL, e.g. https://coinmon.cc
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		http:    http.DefaultClient,
+		timeout: defaultTimeout,
+		retries: defaultRetries,
+		backoff: defaultBackoff,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// envelope represents a v2 API response
+type envelope struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []APIError      `json:"errors"`
+}
+
+// quote represents a quote of the v2 API with its price as a decimal string
+type quote struct {
+	Pair   string    `json:"pair"`
+	Price  string    `json:"price"`
+	Source string    `json:"source"`
+	Time   time.Time `json:"time"`
+}
+
+func (q quote) parse() (Quote, error) {
+	price, err := strconv.ParseFloat(q.Price, 64)
+	if err != nil {
+		return Quote{}, fmt.Errorf("parse price of %s: %w", q.Pair, err)
+	}
+
+	return Quote{Pair: q.Pair, Price: price, Source: q.Source, Time: q.Time}, nil
+}
+
+// GetSpot returns the price of pair, e.g. BTCUSDT
+func (c *Client) GetSpot(ctx context.Context, pair string) (Quote, error) {
+	var q quote
+	if err := c.get(ctx, "/api/v2/spot/"+url.PathEscape(pair), &q, false); err != nil {
+		return Quote{}, err
+	}
+
+	return q.parse()
+}
+
+// GetBatch returns the prices of up to 20 pairs in the order requested.
+// When only some of the pairs could be quoted, the quotes of the others are
+// returned with an *Error naming the failed ones.
+func (c *Client) GetBatch(ctx context.Context, pairs ...string) ([]Quote, error) {
+	var qs []quote
+	err := c.get(ctx, "/api/v2/spot?pairs="+url.QueryEscape(strings.Join(pairs, ",")), &qs, true)
+	if qs == nil {
+		return nil, err
+	}
+
+	quotes := make([]Quote, 0, len(qs))
+	for _, q := range qs {
+		parsed, parseErr := q.parse()
+		if parseErr != nil {
+			return nil, parseErr
+		}
+		quotes = append(quotes, parsed)
+	}
+
+	return quotes, err
+}
+
+// get decodes the data of the v2 response to the request of path into v,
+// retrying failed attempts. With partial set, the data of a successful
+// response is decoded even when it reports errors, which are returned.
+func (c *Client) get(ctx context.Context, path string, v any, partial bool) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		var wait time.Duration
+		wait, err = c.attempt(ctx, path, v, partial)
+		if wait < 0 || attempt >= c.retries {
+			return err
+		}
+
+		if wait == 0 {
+			wait = c.backoff << attempt
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(wait):
+		}
+	}
+}
+
+// attempt makes a single request. It returns how long to wait before
+// retrying: negative when the request must not be retried, zero for the
+// default backoff.
+func (c *Client) attempt(ctx context.Context, path string, v any, partial bool) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	req, err := c.newRequest(ctx, path)
+	if err != nil {
+		return -1, err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("read body: %w", err)
+	}
+
+	var env envelope
+	decodeErr := json.Unmarshal(body, &env)
+	if resp.StatusCode != http.StatusOK {
+		// Errors outside of the v2 API, e.g. of authentication, are plain text
+		if decodeErr != nil || len(env.Errors) == 0 {
+			env.Errors = []APIError{{Message: strings.TrimSpace(string(body))}}
+		}
+		err = &Error{StatusCode: resp.StatusCode, Errors: env.Errors}
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+			return retryAfter(resp), err
+		}
+		return -1, err
+	}
+	if decodeErr != nil {
+		return -1, fmt.Errorf("decode response: %w", decodeErr)
+	}
+
+	if err = json.Unmarshal(env.Data, v); err != nil {
+		return -1, fmt.Errorf("decode data: %w", err)
+	}
+	if partial && len(env.Errors) > 0 {
+		return -1, &Error{StatusCode: resp.StatusCode, Errors: env.Errors}
+	}
+
+	return -1, nil
+}
+
+func (c *Client) newRequest(ctx context.Context, path string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", userAgent)
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+
+	return req, nil
+}
+
+// retryAfter returns the wait of the Retry-After header of resp in seconds,
+// zero when unset
+func retryAfter(resp *http.Response) time.Duration {
+	sec, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || sec <= 0 {
+		return 0
+	}
+
+	return min(time.Duration(sec)*time.Second, maxRetryAfter)
+}
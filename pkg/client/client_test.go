@@ -0,0 +1,209 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_GetSpot(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v2/spot/BTCUSDT", r.URL.Path)
+		assert.Equal(t, "secret", r.Header.Get("X-API-Key"))
+
+		// The first attempt fails and is retried
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = fmt.Fprint(w, `{"data":null,"errors":[{"code":"exchanges_unavailable","message":"BTCUSDT: timeout","source":"binance"}]}`)
+			return
+		}
+		_, _ = fmt.Fprint(w, `{"data":{"pair":"BTCUSDT","price":"97000.01","source":"bybit","time":"2026-01-01T00:00:00Z"},"errors":[]}`)
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, WithAPIKey("secret"), WithRetries(1, time.Millisecond))
+	q, err := c.GetSpot(context.Background(), "BTCUSDT")
+	assert.NoError(t, err)
+	assert.Equal(t, Quote{Pair: "BTCUSDT", Price: 97000.01, Source: "bybit", Time: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}, q)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestClient_GetSpot_Errors(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		body          string
+		expectedCalls int32
+		expectedError string
+	}{
+		{
+			name:          "unavailable is retried",
+			status:        http.StatusServiceUnavailable,
+			body:          `{"data":null,"errors":[{"code":"exchanges_unavailable","message":"BTCUSDT: timeout","source":"binance"}]}`,
+			expectedCalls: 3,
+			expectedError: "coinmon: status 503: binance: BTCUSDT: timeout",
+		},
+		{
+			name:          "bad request is not retried",
+			status:        http.StatusBadRequest,
+			body:          `{"data":null,"errors":[{"code":"bad_request","message":"missing trading pair"}]}`,
+			expectedCalls: 1,
+			expectedError: "coinmon: status 400: missing trading pair",
+		},
+		{
+			name:          "plain text error",
+			status:        http.StatusUnauthorized,
+			body:          "unknown API key\n",
+			expectedCalls: 1,
+			expectedError: "coinmon: status 401: unknown API key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				calls.Add(1)
+				w.WriteHeader(tt.status)
+				_, _ = fmt.Fprint(w, tt.body)
+			}))
+			defer srv.Close()
+
+			c := New(srv.URL, WithRetries(2, time.Millisecond))
+			_, err := c.GetSpot(context.Background(), "BTCUSDT")
+			assert.EqualError(t, err, tt.expectedError)
+
+			var apiErr *Error
+			assert.True(t, errors.As(err, &apiErr))
+			assert.Equal(t, tt.status, apiErr.StatusCode)
+			assert.Equal(t, tt.expectedCalls, calls.Load())
+		})
+	}
+}
+
+func TestClient_GetSpot_Timeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, WithTimeout(20*time.Millisecond), WithRetries(0, 0))
+	_, err := c.GetSpot(context.Background(), "BTCUSDT")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected time.Duration
+	}{
+		{header: "", expected: 0},
+		{header: "2", expected: 2 * time.Second},
+		{header: "3600", expected: maxRetryAfter},
+		{header: "Wed, 21 Oct 2015 07:28:00 GMT", expected: 0},
+	}
+
+	for _, tt := range tests {
+		resp := &http.Response{Header: http.Header{}}
+		resp.Header.Set("Retry-After", tt.header)
+		assert.Equal(t, tt.expected, retryAfter(resp), tt.header)
+	}
+}
+
+func TestClient_GetBatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v2/spot", r.URL.Path)
+		assert.Equal(t, "BTCUSDT,NOPE", r.URL.Query().Get("pairs"))
+		_, _ = fmt.Fprint(w, `{"data":[{"pair":"BTCUSDT","price":"97000.01","source":"binance","time":"2026-01-01T00:00:00Z"}],"errors":[{"code":"exchanges_unavailable","message":"NOPE: invalid symbol","source":"binance"}]}`)
+	}))
+	defer srv.Close()
+
+	quotes, err := New(srv.URL).GetBatch(context.Background(), "BTCUSDT", "NOPE")
+	assert.EqualError(t, err, "coinmon: status 200: binance: NOPE: invalid symbol")
+	assert.Len(t, quotes, 1)
+	assert.Equal(t, 97000.01, quotes[0].Price)
+}
+
+func TestClient_StreamPrices(t *testing.T) {
+	var conns atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/stream/BTCUSDT", r.URL.Path)
+		w.Header().Set("Content-Type", "text/event-stream")
+
+		// The first stream drops after one price and is reconnected
+		price := 97000 + conns.Add(1)
+		_, _ = fmt.Fprintf(w, "event: price\ndata: {\"pair\":\"BTCUSDT\",\"price\":%d,\"source\":\"binance\",\"time\":\"2026-01-01T00:00:00Z\"}\n\n", price)
+		_, _ = fmt.Fprint(w, ": ping\n\nevent: error\ndata: all exchanges failed\n\n")
+	}))
+	defer srv.Close()
+
+	var prices []float64
+	stop := errors.New("enough")
+	err := New(srv.URL, WithRetries(0, time.Millisecond)).StreamPrices(context.Background(), func(q Quote) error {
+		prices = append(prices, q.Price)
+		if len(prices) == 2 {
+			return stop
+		}
+		return nil
+	}, "BTCUSDT")
+
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, []float64{97001, 97002}, prices)
+}
+
+func TestClient_StreamPrices_Canceled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := New(srv.URL, WithRetries(0, time.Millisecond)).StreamPrices(ctx, func(Quote) error { return nil }, "BTCUSDT")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	err = New(srv.URL).StreamPrices(context.Background(), func(Quote) error { return nil })
+	assert.EqualError(t, err, "missing trading pairs")
+}
+
+func TestClient_StreamPrices_ClientError(t *testing.T) {
+	var conns atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		conns.Add(1)
+		http.Error(w, "forbidden pair", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	err := New(srv.URL, WithRetries(0, 0)).StreamPrices(context.Background(), func(Quote) error { return nil }, "BTCUSDT")
+	var apiErr *Error
+	assert.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+	assert.EqualError(t, err, "coinmon: status 403: forbidden pair")
+	assert.Equal(t, int32(1), conns.Load(), "client errors are not retried")
+}
+
+func TestClient_StreamPrices_MinBackoff(t *testing.T) {
+	var conns atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		conns.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	// Without a backoff of its own, reconnecting waits 100ms, then 200ms
+	err := New(srv.URL, WithRetries(0, 0)).StreamPrices(ctx, func(Quote) error { return nil }, "BTCUSDT")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, int32(2), conns.Load())
+}
@@ -0,0 +1,154 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// minStreamBackoff and maxStreamBackoff bound the wait before
+	// reconnecting a dropped stream
+	minStreamBackoff = 100 * time.Millisecond
+	maxStreamBackoff = 30 * time.Second
+)
+
+// StreamPrices calls fn with every price update of pairs, starting with
+// their current prices, until ctx is done or fn returns an error, which is
+// returned. Dropped streams are reconnected with backoff, streams refused
+// with a 4xx response other than 429 are not and their *Error is returned.
+// fn is never called concurrently. Streams are long-lived, so the client
+// given to WithHTTPClient must not have a Timeout.
+func (c *Client) StreamPrices(ctx context.Context, fn func(Quote) error, pairs ...string) error {
+	if len(pairs) == 0 {
+		return errors.New("missing trading pairs")
+	}
+
+	sctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		fnErr error
+	)
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if fnErr == nil {
+			fnErr = err
+		}
+		cancel()
+	}
+	for _, pair := range pairs {
+		wg.Go(func() {
+			err := c.stream(sctx, pair, func(q Quote) {
+				mu.Lock()
+				if sctx.Err() != nil {
+					mu.Unlock()
+					return
+				}
+				err := fn(q)
+				mu.Unlock()
+				if err != nil {
+					fail(err)
+				}
+			})
+			if err != nil {
+				fail(err)
+			}
+		})
+	}
+	wg.Wait()
+
+	if fnErr != nil {
+		return fnErr
+	}
+
+	return ctx.Err()
+}
+
+// stream passes the updates of pair to fn, reconnecting until ctx is done.
+// It returns the error of a stream which must not be reconnected.
+func (c *Client) stream(ctx context.Context, pair string, fn func(Quote)) error {
+	initial := max(c.backoff, minStreamBackoff)
+	backoff := initial
+	for ctx.Err() == nil {
+		received, wait, err := c.streamOnce(ctx, pair, fn)
+		if wait < 0 {
+			return err
+		}
+		if received {
+			backoff = initial
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(max(backoff, wait)):
+		}
+		backoff = min(2*backoff, maxStreamBackoff)
+	}
+
+	return nil
+}
+
+// streamOnce reads Server-Sent Events of pair until the stream ends,
+// reporting whether any price was received and, like attempt, how long to
+// wait before reconnecting: negative when the stream must not be
+// reconnected, zero for the default backoff.
+func (c *Client) streamOnce(ctx context.Context, pair string, fn func(Quote)) (bool, time.Duration, error) {
+	req, err := c.newRequest(ctx, "/api/v1/stream/"+url.PathEscape(pair))
+	if err != nil {
+		return false, -1, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return false, 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		err := &Error{StatusCode: resp.StatusCode, Errors: []APIError{{Message: strings.TrimSpace(string(body))}}}
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+			return false, retryAfter(resp), err
+		}
+		return false, -1, err
+	}
+
+	var (
+		received bool
+		event    string
+		data     []string
+	)
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case line == "":
+			// A blank line dispatches the event
+			var q Quote
+			if event == "price" && json.Unmarshal([]byte(strings.Join(data, "\n")), &q) == nil {
+				received = true
+				fn(q)
+			}
+			event, data = "", nil
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+
+	return received, 0, sc.Err()
+}
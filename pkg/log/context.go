@@ -0,0 +1,39 @@
+package log
+
+import (
+	"context"
+	"maps"
+
+	"github.com/rs/zerolog"
+)
+
+type fieldsKey struct{}
+
+// WithFields returns a copy of ctx carrying fields, added to every entry
+// logged with the logger returned by FromContext. Fields already carried
+// by ctx are kept unless overridden.
+func WithFields(ctx context.Context, fields Fields) context.Context {
+	merged := make(Fields, len(fields))
+	if parent, ok := ctx.Value(fieldsKey{}).(Fields); ok {
+		maps.Copy(merged, parent)
+	}
+	maps.Copy(merged, fields)
+
+	return context.WithValue(ctx, fieldsKey{}, merged)
+}
+
+// FromContext returns a logger adding the fields carried by ctx to every
+// entry. Errors are sampled by message together with the default logger.
+func FromContext(ctx context.Context) Logger {
+	l, ok := logger.(*Log)
+	if !ok {
+		return &Log{log: zerolog.Nop()}
+	}
+
+	fields, ok := ctx.Value(fieldsKey{}).(Fields)
+	if !ok {
+		return l
+	}
+
+	return &Log{log: l.log.With().Fields(map[string]any(fields)).Logger(), errors: l.errors}
+}
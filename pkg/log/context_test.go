@@ -0,0 +1,43 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromContext(t *testing.T) {
+	var buf bytes.Buffer
+	SetLogConfig(zerolog.DebugLevel, &buf)
+	defer SetLogConfig(zerolog.InfoLevel, nil)
+
+	ctx := WithFields(context.Background(), Fields{"request_id": "abc", "pair": "ETHUSDT"})
+	ctx = WithFields(ctx, Fields{"pair": "BTCUSDT", "exchange": "binance"})
+
+	FromContext(ctx).Info("Got price")
+	assert.Contains(t, buf.String(), `"request_id":"abc"`, "Buffer should contain parent fields")
+	assert.Contains(t, buf.String(), `"pair":"BTCUSDT"`, "Buffer should contain overridden field")
+	assert.Contains(t, buf.String(), `"exchange":"binance"`, "Buffer should contain child fields")
+	assert.Contains(t, buf.String(), `"message":"Got price"`, "Buffer should contain message")
+	buf.Reset()
+
+	FromContext(context.Background()).Debug("No fields")
+	assert.Contains(t, buf.String(), `"message":"No fields"`, "Buffer should contain message")
+	assert.NotContains(t, buf.String(), "request_id", "Buffer should not contain fields")
+	buf.Reset()
+
+	FromContext(ctx).Error("Error from binance")
+	FromContext(ctx).Error("Error from binance")
+	assert.Equal(t, 1, bytes.Count(buf.Bytes(), []byte("Error from binance")), "Errors should be sampled")
+}
+
+func TestFromContext_NoLogger(t *testing.T) {
+	saved := logger
+	logger = nil
+	defer func() { logger = saved }()
+
+	assert.NotPanics(t, func() { FromContext(context.Background()).Info("Dropped") })
+}
@@ -0,0 +1,36 @@
+package log
+
+import (
+	"io"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// DefaultMaxSize is the size in megabytes a log file is rotated at by default
+const DefaultMaxSize = 100
+
+// FileConfig represents log file rotation settings
+type FileConfig struct {
+	Path       string
+	MaxSize    int  // megabytes a file grows to before rotation
+	MaxAge     int  // days rotated files are kept for, 0 keeps them forever
+	MaxBackups int  // rotated files kept, 0 keeps all
+	Compress   bool // gzip rotated files
+}
+
+// NewFile returns a writer appending to cfg.Path that rotates the file
+// once it exceeds cfg.MaxSize and removes rotated files beyond retention
+func NewFile(cfg FileConfig) io.WriteCloser {
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = DefaultMaxSize
+	}
+
+	return &lumberjack.Logger{
+		Filename:   cfg.Path,
+		MaxSize:    cfg.MaxSize,
+		MaxAge:     cfg.MaxAge,
+		MaxBackups: cfg.MaxBackups,
+		Compress:   cfg.Compress,
+		LocalTime:  true,
+	}
+}
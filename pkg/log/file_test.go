@@ -0,0 +1,68 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+func TestNewFile(t *testing.T) {
+	tests := []struct {
+		name            string
+		cfg             FileConfig
+		expectedMaxSize int
+	}{
+		{
+			name:            "default size",
+			cfg:             FileConfig{Path: "coinmon.log"},
+			expectedMaxSize: DefaultMaxSize,
+		},
+		{
+			name:            "custom size",
+			cfg:             FileConfig{Path: "coinmon.log", MaxSize: 10, MaxAge: 7, MaxBackups: 3, Compress: true},
+			expectedMaxSize: 10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewFile(tt.cfg).(*lumberjack.Logger)
+			assert.Equal(t, tt.cfg.Path, l.Filename)
+			assert.Equal(t, tt.expectedMaxSize, l.MaxSize)
+			assert.Equal(t, tt.cfg.MaxAge, l.MaxAge)
+			assert.Equal(t, tt.cfg.MaxBackups, l.MaxBackups)
+			assert.Equal(t, tt.cfg.Compress, l.Compress)
+		})
+	}
+}
+
+func TestNewFile_Rotate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "coinmon.log")
+
+	f := NewFile(FileConfig{Path: path, MaxBackups: 1})
+	defer func() { _ = f.Close() }()
+	SetLogConfig(zerolog.InfoLevel, f)
+	defer SetLogConfig(zerolog.InfoLevel, nil)
+
+	Info("before rotation")
+	assert.NoError(t, f.(*lumberjack.Logger).Rotate())
+	Info("after rotation")
+
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), "after rotation")
+	assert.NotContains(t, string(b), "before rotation")
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	for _, e := range entries {
+		assert.True(t, strings.HasPrefix(e.Name(), "coinmon"))
+	}
+}
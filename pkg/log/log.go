@@ -4,6 +4,7 @@ package log
 import (
 	"io"
 	"os"
+	"time"
 
 	"github.com/rs/zerolog"
 )
@@ -20,9 +21,13 @@ type Logger interface {
 	Error(msg string)
 }
 
+// Fields represents structured fields of a log entry
+type Fields map[string]any
+
 // Log is the struct that implements the Logger interface
 type Log struct {
-	log zerolog.Logger
+	log    zerolog.Logger
+	errors *sampler
 }
 
 // SetDefaultLogConfig sets the default logger configuration
@@ -39,7 +44,16 @@ func SetLogConfig(level zerolog.Level, output io.Writer) {
 	}
 
 	zlogger = zerolog.New(output).Level(level).With().Timestamp().Logger()
-	logger = &Log{log: zlogger}
+	logger = &Log{log: zlogger, errors: newSampler(DefaultSampleEvery, DefaultSampleWindow)}
+}
+
+// SetErrorSampling sets how identical error messages are sampled: the first
+// one within window is logged, then every nth one with the repeat count.
+// every of 1 or less logs every error.
+func SetErrorSampling(every int, window time.Duration) {
+	if l, ok := logger.(*Log); ok {
+		l.errors = newSampler(every, window)
+	}
 }
 
 // Info logs message with INFO level
@@ -52,9 +66,18 @@ func (l *Log) Debug(msg string) {
 	l.log.Debug().Msg(msg)
 }
 
-// Error logs message with ERROR level
+// Error logs message with ERROR level. Repeated messages are sampled.
 func (l *Log) Error(msg string) {
-	l.log.Error().Msg(msg)
+	ok, n := l.errors.sample(msg)
+	if !ok {
+		return
+	}
+
+	e := l.log.Error()
+	if n > 1 {
+		e = e.Int("repeated", n)
+	}
+	e.Msg(msg)
 }
 
 // Info logs an info message
@@ -80,3 +103,12 @@ func Error(msg string) {
 		logger.Error(msg)
 	}
 }
+
+// InfoFields logs an info message with structured fields
+// msg is the message to log
+// fields are added to the entry as they are
+func InfoFields(msg string, fields Fields) {
+	if logger != nil {
+		zlogger.Info().Fields(map[string]any(fields)).Msg(msg)
+	}
+}
@@ -40,6 +40,16 @@ func TestLogLevels(t *testing.T) {
 	buf.Reset()
 }
 
+func TestInfoFields(t *testing.T) {
+	var buf bytes.Buffer
+	SetLogConfig(zerolog.InfoLevel, &buf)
+
+	InfoFields("Request", Fields{"method": "GET", "status": 200})
+	assert.Contains(t, buf.String(), `"method":"GET"`, "Buffer should contain method field")
+	assert.Contains(t, buf.String(), `"status":200`, "Buffer should contain status field")
+	assert.Contains(t, buf.String(), `"message":"Request"`, "Buffer should contain message")
+}
+
 func TestInitLogLevel(t *testing.T) {
 	var buf bytes.Buffer
 	SetLogConfig(zerolog.ErrorLevel, &buf)
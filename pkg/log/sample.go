@@ -0,0 +1,54 @@
+package log
+
+import (
+	"sync"
+	"time"
+)
+
+// Identical errors are logged once, then every DefaultSampleEvery times
+// within DefaultSampleWindow
+const (
+	DefaultSampleEvery  = 100
+	DefaultSampleWindow = time.Minute
+)
+
+// sampler counts identical messages within a window
+type sampler struct {
+	mu     sync.Mutex
+	every  int
+	window time.Duration
+	start  time.Time
+	counts map[string]int
+	now    func() time.Time
+}
+
+func newSampler(every int, window time.Duration) *sampler {
+	return &sampler{
+		every:  every,
+		window: window,
+		counts: make(map[string]int),
+		now:    time.Now,
+	}
+}
+
+// sample reports whether msg should be logged and how many times it has
+// occurred within the current window
+func (s *sampler) sample(msg string) (bool, int) {
+	if s == nil || s.every <= 1 {
+		return true, 1
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Counts start over every window, so a recurring failure is logged again
+	if now := s.now(); now.Sub(s.start) >= s.window {
+		s.start = now
+		clear(s.counts)
+	}
+
+	s.counts[msg]++
+	n := s.counts[msg]
+
+	return n == 1 || n%s.every == 0, n
+}
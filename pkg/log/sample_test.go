@@ -0,0 +1,60 @@
+package log
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSampler(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := newSampler(3, time.Minute)
+	s.now = func() time.Time { return now }
+
+	var logged []int
+	for range 7 {
+		if ok, n := s.sample("exchange down"); ok {
+			logged = append(logged, n)
+		}
+	}
+	assert.Equal(t, []int{1, 3, 6}, logged, "First and every third message should be logged")
+
+	ok, n := s.sample("another error")
+	assert.True(t, ok, "Different message should be logged")
+	assert.Equal(t, 1, n)
+
+	now = now.Add(time.Minute)
+	ok, n = s.sample("exchange down")
+	assert.True(t, ok, "Message should be logged again in a new window")
+	assert.Equal(t, 1, n)
+}
+
+func TestSampler_Disabled(t *testing.T) {
+	s := newSampler(1, time.Minute)
+	for range 3 {
+		ok, n := s.sample("exchange down")
+		assert.True(t, ok)
+		assert.Equal(t, 1, n)
+	}
+}
+
+func TestErrorSampling(t *testing.T) {
+	var buf bytes.Buffer
+	SetLogConfig(zerolog.InfoLevel, &buf)
+	SetErrorSampling(2, time.Hour)
+	defer SetLogConfig(zerolog.InfoLevel, nil)
+
+	for range 4 {
+		Error("Error from binance: timeout")
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 3, "Errors 1, 2 and 4 should be logged")
+	assert.NotContains(t, lines[0], "repeated")
+	assert.Contains(t, lines[1], `"repeated":2`)
+	assert.Contains(t, lines[2], `"repeated":4`)
+}
@@ -0,0 +1,35 @@
+// Prices update in place from the WebSocket API, reconnecting with backoff
+(function () {
+    var cells = document.querySelectorAll('[data-pair]');
+    var pairs = Array.from(new Set(Array.from(cells, function (c) { return c.dataset.pair; })));
+    if (!pairs.length || !window.WebSocket) return;
+
+    var delay = 1000;
+    function connect() {
+        var ws = new WebSocket((location.protocol === 'https:' ? 'wss://' : 'ws://') + location.host + '/ws');
+        ws.onopen = function () {
+            delay = 1000;
+            ws.send(JSON.stringify({ type: 'subscribe', pairs: pairs }));
+        };
+        ws.onmessage = function (e) {
+            var msg = JSON.parse(e.data);
+            if (msg.type !== 'price') return;
+            document.querySelectorAll('[data-pair="' + msg.pair + '"]').forEach(function (c) {
+                var old = parseFloat(c.textContent);
+                c.textContent = msg.price;
+                if (isNaN(old) || old === msg.price) return;
+                c.classList.remove('up', 'down');
+                void c.offsetWidth; // restart the animation
+                c.classList.add(msg.price > old ? 'up' : 'down');
+            });
+            document.querySelectorAll('[data-source="' + msg.pair + '"]').forEach(function (c) {
+                c.textContent = 'from ' + msg.source;
+            });
+        };
+        ws.onclose = function () {
+            setTimeout(connect, delay);
+            delay = Math.min(delay * 2, 30000);
+        };
+    }
+    connect();
+})();
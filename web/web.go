@@ -0,0 +1,10 @@
+// Package web provides the templates and static assets of the dashboard and
+// admin pages, embedded into the binary so it runs from any working directory.
+package web
+
+import "embed"
+
+// FS holds the templates under template/ and the static assets under static/
+//
+//go:embed template static
+var FS embed.FS